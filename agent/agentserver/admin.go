@@ -0,0 +1,116 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/uber/kraken/utils/handler"
+)
+
+// _adminRoutes require admin authorization once admin credentials are
+// configured, in addition to any routes configured in Config.AdminRoutes. Push
+// routes are only registered if pushing is enabled.
+var _adminRoutes = map[string]bool{
+	"DELETE /blobs/{digest}":                    true,
+	"POST /pin/{digest}":                        true,
+	"DELETE /pin/{digest}":                      true,
+	"POST /maintenance":                         true,
+	"DELETE /maintenance":                       true,
+	"PATCH /x/config/scheduler":                 true,
+	"PATCH /x/bandwidth":                        true,
+	"PUT /namespace/{namespace}/blobs/{digest}": true,
+	"PUT /tags/{tag}/digest/{digest}":           true,
+}
+
+// adminEnabled returns true if admin credentials are configured, in which case
+// admin routes require authorization.
+func (s *Server) adminEnabled() bool {
+	return s.config.AdminToken != "" || len(s.config.AdminClientNames) > 0
+}
+
+// adminRoutes returns the set of "METHOD /pattern" routes which require
+// admin authorization.
+func (s *Server) adminRoutes() map[string]bool {
+	routes := make(map[string]bool)
+	for route := range _adminRoutes {
+		routes[route] = true
+	}
+	for _, route := range s.config.AdminRoutes {
+		fields := strings.Fields(route)
+		if len(fields) != 2 {
+			continue
+		}
+		routes[strings.ToUpper(fields[0])+" "+fields[1]] = true
+	}
+	return routes
+}
+
+// requireAdmin wraps h such that unauthorized requests are rejected with 401
+// before reaching h.
+func (s *Server) requireAdmin(h handler.ErrHandler) handler.ErrHandler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if err := s.authorizeAdmin(r); err != nil {
+			s.stats.Counter("admin_unauthorized").Inc(1)
+			return err
+		}
+		return h(w, r)
+	}
+}
+
+// authorizeAdmin rejects requests to admin endpoints which neither carry the
+// configured admin token nor present a verified client certificate with one
+// of the configured admin client names.
+func (s *Server) authorizeAdmin(r *http.Request) error {
+	if s.config.AdminToken != "" {
+		token, ok := bearerToken(r)
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) == 1 {
+			return nil
+		}
+	}
+	if s.isAdminClient(r) {
+		return nil
+	}
+	return handler.ErrorStatus(http.StatusUnauthorized)
+}
+
+// bearerToken returns the token of an "Authorization: Bearer <token>" header.
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, prefix) || len(h) == len(prefix) {
+		return "", false
+	}
+	return h[len(prefix):], true
+}
+
+// isAdminClient returns true if r was sent over mutual TLS by a client whose
+// verified certificate names one of the configured admin clients.
+func (s *Server) isAdminClient(r *http.Request) bool {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return false
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	for _, allowed := range s.config.AdminClientNames {
+		for _, name := range names {
+			if name != "" && name == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
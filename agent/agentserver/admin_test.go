@@ -0,0 +1,148 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+
+	"github.com/uber/kraken/utils/handler"
+	"github.com/uber/kraken/utils/httputil"
+)
+
+func TestConfiguredAdminRouteRequiresAuthorization(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServerWithConfig(Config{
+		AdminToken:  "secret",
+		AdminRoutes: []string{"get /torrents"},
+	})
+	url := fmt.Sprintf("http://%s/torrents", addr)
+
+	_, err := httputil.Get(url)
+	require.True(httputil.IsStatus(err, http.StatusUnauthorized))
+
+	mocks.sched.EXPECT().TorrentStats().Return(nil, nil)
+
+	_, err = httputil.Get(url, httputil.SendHeaders(map[string]string{"Authorization": "Bearer secret"}))
+	require.NoError(err)
+
+	// Other routes are unaffected.
+	mocks.sched.EXPECT().BandwidthLimits().Return(uint64(800), uint64(1600))
+
+	_, err = httputil.Get(fmt.Sprintf("http://%s/x/bandwidth", addr))
+	require.NoError(err)
+}
+
+func TestAdminRoutesUnprotectedWithoutCredentials(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServerWithConfig(Config{})
+	maintenance := fmt.Sprintf("http://%s/maintenance", addr)
+
+	mocks.sched.EXPECT().SetMaintenance(true)
+	_, err := httputil.Post(maintenance)
+	require.NoError(err)
+
+	mocks.sched.EXPECT().SetMaintenance(false)
+	_, err = httputil.Delete(maintenance)
+	require.NoError(err)
+}
+
+func TestAuthorizeAdminToken(t *testing.T) {
+	tests := []struct {
+		desc          string
+		authorization string
+		authorized    bool
+	}{
+		{"bearer token", "Bearer secret", true},
+		{"wrong token", "Bearer wrong", false},
+		{"no scheme", "secret", false},
+		{"other scheme", "Basic secret", false},
+		{"empty bearer token", "Bearer ", false},
+		{"missing", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			s := New(Config{AdminToken: "secret"}, tally.NoopScope, nil, nil, nil, zap.NewNop())
+			r := httptest.NewRequest("DELETE", "/maintenance", nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			err := s.authorizeAdmin(r)
+			if test.authorized {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, http.StatusUnauthorized, err.(*handler.Error).GetStatus())
+			}
+		})
+	}
+}
+
+func TestAuthorizeAdminClientNames(t *testing.T) {
+	withCert := func(cn string, dnsNames ...string) *http.Request {
+		r := httptest.NewRequest("DELETE", "/maintenance", nil)
+		r.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{
+				Subject:  pkix.Name{CommonName: cn},
+				DNSNames: dnsNames,
+			}}},
+		}
+		return r
+	}
+	unverified := httptest.NewRequest("DELETE", "/maintenance", nil)
+	unverified.TLS = &tls.ConnectionState{}
+
+	tests := []struct {
+		desc       string
+		r          *http.Request
+		authorized bool
+	}{
+		{"common name", withCert("kraken-admin"), true},
+		{"dns name", withCert("other", "kraken-admin"), true},
+		{"unknown client", withCert("other"), false},
+		{"unverified client", unverified, false},
+		{"plaintext", httptest.NewRequest("DELETE", "/maintenance", nil), false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			s := New(
+				Config{AdminClientNames: []string{"kraken-admin"}},
+				tally.NoopScope, nil, nil, nil, zap.NewNop())
+			err := s.authorizeAdmin(test.r)
+			if test.authorized {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, http.StatusUnauthorized, err.(*handler.Error).GetStatus())
+			}
+		})
+	}
+}
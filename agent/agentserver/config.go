@@ -0,0 +1,61 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import "time"
+
+// Config defines Server configuration.
+type Config struct {
+	// PreloadConcurrency limits the number of images preloaded in parallel
+	// by a single preload request.
+	PreloadConcurrency int `yaml:"preload_concurrency"`
+
+	// PreloadTimeout bounds how long a single image may take to preload.
+	PreloadTimeout time.Duration `yaml:"preload_timeout"`
+
+	// StreamPollInterval is how often a streaming download checks whether
+	// the next piece of the blob has completed.
+	StreamPollInterval time.Duration `yaml:"stream_poll_interval"`
+
+	// AdminToken, if set, must be supplied as a bearer token in the
+	// Authorization header of destructive admin requests, such as blob
+	// eviction. Admin endpoints are unprotected if neither AdminToken nor
+	// AdminClientNames is set.
+	AdminToken string `yaml:"admin_token"`
+
+	// AdminClientNames, if set, authorizes admin requests sent over mutual
+	// TLS by clients whose verified certificate has one of these common or
+	// DNS names, as an alternative to AdminToken.
+	AdminClientNames []string `yaml:"admin_client_names"`
+
+	// AdminRoutes are additional routes which require admin authorization,
+	// as "METHOD /pattern" using the agent server's route patterns, e.g.
+	// "GET /torrents". Blob eviction, pinning, maintenance, pushes and
+	// runtime config changes always require admin authorization once admin
+	// credentials are configured.
+	AdminRoutes []string `yaml:"admin_routes"`
+}
+
+func (c Config) applyDefaults() Config {
+	if c.PreloadConcurrency == 0 {
+		c.PreloadConcurrency = 4
+	}
+	if c.PreloadTimeout == 0 {
+		c.PreloadTimeout = 10 * time.Minute
+	}
+	if c.StreamPollInterval == 0 {
+		c.StreamPollInterval = 100 * time.Millisecond
+	}
+	return c
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	rpprof "runtime/pprof"
+
+	"github.com/pressly/chi"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/torrent/networkevent"
+	"github.com/uber/kraken/utils/log"
+)
+
+// DebugHandler returns an HTTP handler which serves /debug/pprof profiles and a
+// full goroutine dump at /debug/goroutines. If events is non-nil, scheduler
+// network events are streamed at /debug/events. Profiles expose process
+// internals and are expensive to collect, so the handler should only be served
+// on a private interface.
+func DebugHandler(events *networkevent.Stream) http.Handler {
+	r := chi.NewRouter()
+
+	r.Get("/debug/goroutines", goroutinesHandler)
+
+	if events != nil {
+		r.Get("/debug/events", eventsHandler(events))
+	}
+
+	r.HandleFunc("/debug/pprof/", pprof.Index)
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	// Serves named profiles, e.g. heap and goroutine.
+	r.HandleFunc("/debug/pprof/{profile}", pprof.Index)
+
+	return r
+}
+
+// goroutinesHandler writes the stack traces of all goroutines in the same
+// format as an unrecovered panic.
+func goroutinesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rpprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// eventsHandler streams network events as server-sent events until the client
+// disconnects. Events are limited to a single torrent if the infohash query
+// parameter is set.
+func eventsHandler(stream *networkevent.Stream) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		var torrent string
+		if raw := r.URL.Query().Get("infohash"); raw != "" {
+			h, err := core.NewInfoHashFromHex(raw)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("parse infohash: %s", err), http.StatusBadRequest)
+				return
+			}
+			torrent = h.String()
+		}
+
+		events, unsubscribe := stream.Subscribe(torrent)
+		defer func() {
+			if n := unsubscribe(); n > 0 {
+				log.Warnf("Event stream client missed %d events", n)
+			}
+		}()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for {
+			select {
+			case e := <-events:
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Name, e.JSON())
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
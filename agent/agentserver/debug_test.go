@@ -0,0 +1,114 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/torrent/networkevent"
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/testutil"
+)
+
+func TestDebugHandlerGoroutines(t *testing.T) {
+	require := require.New(t)
+
+	addr, stop := testutil.StartServer(DebugHandler(nil))
+	defer stop()
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/debug/goroutines", addr))
+	require.NoError(err)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(err)
+	require.Contains(string(b), "goroutine ")
+	require.Contains(string(b), "TestDebugHandlerGoroutines")
+}
+
+func TestDebugHandlerPprof(t *testing.T) {
+	addr, stop := testutil.StartServer(DebugHandler(nil))
+	defer stop()
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/cmdline"} {
+		t.Run(path, func(t *testing.T) {
+			resp, err := httputil.Get(fmt.Sprintf("http://%s%s", addr, path))
+			require.NoError(t, err)
+			resp.Body.Close()
+		})
+	}
+}
+
+func TestServerDoesNotServePprof(t *testing.T) {
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/debug/pprof/", addr))
+	require.True(t, httputil.IsNotFound(err))
+}
+
+func TestDebugHandlerEvents(t *testing.T) {
+	require := require.New(t)
+
+	stream := networkevent.NewStream(networkevent.NewTestProducer())
+
+	addr, stop := testutil.StartServer(DebugHandler(stream))
+	defer stop()
+
+	h := core.InfoHashFixture()
+	self := core.PeerIDFixture()
+	peer := core.PeerIDFixture()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/events?infohash=%s", addr, h.Hex()))
+	require.NoError(err)
+	defer resp.Body.Close()
+	require.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	// Headers are flushed once the subscription is registered.
+	e := networkevent.ReceivePieceEvent(h, self, peer, 3)
+	stream.Produce(networkevent.ReceivePieceEvent(core.InfoHashFixture(), self, peer, 1))
+	stream.Produce(e)
+
+	r := bufio.NewReader(resp.Body)
+	line, err := r.ReadString('\n')
+	require.NoError(err)
+	require.Equal("event: receive_piece\n", line)
+	line, err = r.ReadString('\n')
+	require.NoError(err)
+	require.Equal(fmt.Sprintf("data: %s\n", e.JSON()), line)
+}
+
+func TestDebugHandlerEventsInvalidInfoHash(t *testing.T) {
+	addr, stop := testutil.StartServer(DebugHandler(networkevent.NewStream(networkevent.NewTestProducer())))
+	defer stop()
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/debug/events?infohash=foo", addr))
+	require.True(t, httputil.IsStatus(err, http.StatusBadRequest))
+}
+
+func TestDebugHandlerEventsDisabled(t *testing.T) {
+	addr, stop := testutil.StartServer(DebugHandler(nil))
+	defer stop()
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/debug/events", addr))
+	require.True(t, httputil.IsNotFound(err))
+}
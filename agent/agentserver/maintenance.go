@@ -0,0 +1,46 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"net/http"
+
+	"github.com/uber/kraken/utils/handler"
+)
+
+// enterMaintenanceHandler puts the agent into maintenance mode ahead of a
+// drain: new pull requests are rejected with 503, the agent stops announcing
+// as a seeder and reports unready, but in-flight transfers are completed.
+func (s *Server) enterMaintenanceHandler(w http.ResponseWriter, r *http.Request) error {
+	return s.setMaintenance(r, true)
+}
+
+// exitMaintenanceHandler returns the agent to normal operation.
+func (s *Server) exitMaintenanceHandler(w http.ResponseWriter, r *http.Request) error {
+	return s.setMaintenance(r, false)
+}
+
+func (s *Server) setMaintenance(r *http.Request, enabled bool) error {
+	s.sched.SetMaintenance(enabled)
+	s.maintenance.Store(enabled)
+	return nil
+}
+
+// checkMaintenance rejects new pull requests while in maintenance mode.
+func (s *Server) checkMaintenance() error {
+	if s.maintenance.Load() {
+		return handler.Errorf("agent is in maintenance").Status(http.StatusServiceUnavailable)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/utils/httputil"
+)
+
+func TestMaintenance(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+	maintenance := fmt.Sprintf("http://%s/maintenance", addr)
+
+	mocks.sched.EXPECT().SetMaintenance(true)
+	_, err := httputil.Post(maintenance, sendAdminToken())
+	require.NoError(err)
+
+	namespace := core.TagFixture()
+	d := core.DigestFixture()
+
+	_, err = httputil.Get(fmt.Sprintf("http://%s/namespace/%s/blobs/%s", addr, url.PathEscape(namespace), d))
+	require.True(httputil.IsStatus(err, http.StatusServiceUnavailable))
+
+	_, err = httputil.Get(fmt.Sprintf("http://%s/readiness", addr))
+	require.True(httputil.IsStatus(err, http.StatusServiceUnavailable))
+
+	mocks.sched.EXPECT().SetMaintenance(false)
+	_, err = httputil.Delete(maintenance, sendAdminToken())
+	require.NoError(err)
+
+	mocks.sched.EXPECT().Probe().Return(nil)
+	mocks.sched.EXPECT().CheckReadiness().Return(nil)
+	_, err = httputil.Get(fmt.Sprintf("http://%s/readiness", addr))
+	require.NoError(err)
+}
+
+func TestMaintenanceRequiresAdminToken(t *testing.T) {
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServerWithConfig(Config{AdminToken: "secret"})
+
+	_, err := httputil.Post(fmt.Sprintf("http://%s/maintenance", addr))
+	require.True(t, httputil.IsStatus(err, http.StatusUnauthorized))
+}
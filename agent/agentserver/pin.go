@@ -0,0 +1,58 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/utils/handler"
+)
+
+// pinBlobHandler exempts a cached blob from cache eviction and cleanup until
+// it is unpinned. Pins are persisted on disk, and pinned blobs are seeded
+// when the agent starts. Returns 404 if the blob is not present and 409 if
+// the blob is still being downloaded.
+func (s *Server) pinBlobHandler(w http.ResponseWriter, r *http.Request) error {
+	return s.setPinned(r, true)
+}
+
+// unpinBlobHandler releases a pin on a cached blob, such that it may be
+// evicted again.
+func (s *Server) unpinBlobHandler(w http.ResponseWriter, r *http.Request) error {
+	return s.setPinned(r, false)
+}
+
+func (s *Server) setPinned(r *http.Request, pinned bool) error {
+	d, err := parseDigest(r)
+	if err != nil {
+		return err
+	}
+	_, downloading, err := s.statBlob(d)
+	if err != nil {
+		return err
+	}
+	if downloading {
+		return handler.Errorf("blob is currently downloading").Status(http.StatusConflict)
+	}
+	// Persisted files are never deleted by cache eviction or cleanup.
+	if _, err := s.cads.Cache().SetMetadata(d.Hex(), metadata.NewPersist(pinned)); err != nil {
+		if os.IsNotExist(err) {
+			return handler.ErrorStatus(http.StatusNotFound)
+		}
+		return handler.Errorf("set persist metadata: %s", err)
+	}
+	return nil
+}
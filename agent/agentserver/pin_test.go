@@ -0,0 +1,111 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/base"
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/utils/httputil"
+)
+
+func TestPinBlobHandler(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	blob := core.NewBlobFixture()
+
+	require.NoError(store.RunDownload(mocks.cads, blob.Digest, blob.Content))
+
+	addr := mocks.startServer()
+	url := fmt.Sprintf("http://%s/pin/%s", addr, blob.Digest)
+
+	_, err := httputil.Post(url, sendAdminToken())
+	require.NoError(err)
+
+	var persist metadata.Persist
+	require.NoError(mocks.cads.Cache().GetMetadata(blob.Digest.Hex(), &persist))
+	require.True(persist.Value)
+
+	// Pinned blobs cannot be evicted.
+	require.Equal(base.ErrFilePersisted, mocks.cads.Cache().DeleteFile(blob.Digest.Hex()))
+
+	_, err = httputil.Delete(url, sendAdminToken())
+	require.NoError(err)
+
+	require.NoError(mocks.cads.Cache().DeleteFile(blob.Digest.Hex()))
+}
+
+func TestPinBlobHandlerNotFound(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	for _, send := range []func(string, ...httputil.SendOption) (*http.Response, error){
+		httputil.Post, httputil.Delete,
+	} {
+		_, err := send(fmt.Sprintf("http://%s/pin/%s", addr, core.DigestFixture()), sendAdminToken())
+		require.True(httputil.IsNotFound(err))
+	}
+}
+
+func TestPinBlobHandlerDownloadInProgress(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	blob := core.NewBlobFixture()
+
+	require.NoError(mocks.cads.CreateDownloadFile(blob.Digest.Hex(), int64(len(blob.Content))))
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Post(fmt.Sprintf("http://%s/pin/%s", addr, blob.Digest), sendAdminToken())
+	require.True(httputil.IsConflict(err))
+}
+
+func TestPinBlobHandlerAdminToken(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	blob := core.NewBlobFixture()
+
+	require.NoError(store.RunDownload(mocks.cads, blob.Digest, blob.Content))
+
+	addr := mocks.startServerWithConfig(Config{AdminToken: "secret"})
+	url := fmt.Sprintf("http://%s/pin/%s", addr, blob.Digest)
+
+	_, err := httputil.Post(url)
+	require.True(httputil.IsStatus(err, http.StatusUnauthorized))
+
+	_, err = httputil.Post(url, httputil.SendHeaders(map[string]string{
+		"Authorization": "Bearer secret",
+	}))
+	require.NoError(err)
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/utils/dockerutil"
+	"github.com/uber/kraken/utils/handler"
+)
+
+// PreloadImage identifies an image to preload.
+type PreloadImage struct {
+	Repo string `json:"repo"`
+	Tag  string `json:"tag"`
+}
+
+// PreloadResult is the outcome of preloading a single image. Digest is set
+// on success, otherwise Error describes the failure.
+type PreloadResult struct {
+	Repo   string `json:"repo"`
+	Tag    string `json:"tag"`
+	Digest string `json:"digest,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// preloadHandler downloads the manifest and layers of each image in the request
+// body, blocking until all images have either succeeded, failed, or timed out.
+func (s *Server) preloadHandler(w http.ResponseWriter, r *http.Request) error {
+	defer r.Body.Close()
+	if err := s.checkMaintenance(); err != nil {
+		return err
+	}
+	var images []PreloadImage
+	if err := json.NewDecoder(r.Body).Decode(&images); err != nil {
+		return handler.Errorf("json decode: %s", err).Status(http.StatusBadRequest)
+	}
+
+	results := make([]PreloadResult, len(images))
+	sem := make(chan struct{}, s.config.PreloadConcurrency)
+	var wg sync.WaitGroup
+	for i, img := range images {
+		wg.Add(1)
+		go func(i int, img PreloadImage) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = s.preloadWithTimeout(r.Context(), img)
+		}(i, img)
+	}
+	wg.Wait()
+
+	var failed int
+	for _, res := range results {
+		if res.Error != "" {
+			failed++
+		}
+	}
+	s.stats.Counter("preload_success").Inc(int64(len(results) - failed))
+	s.stats.Counter("preload_failure").Inc(int64(failed))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// preloadWithTimeout preloads img, giving up after the configured timeout or
+// once ctx is done. Downloads which are still in flight after a timeout are
+// left running in the scheduler.
+func (s *Server) preloadWithTimeout(ctx context.Context, img PreloadImage) PreloadResult {
+	result := PreloadResult{Repo: img.Repo, Tag: img.Tag}
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.PreloadTimeout)
+	defer cancel()
+
+	type outcome struct {
+		d   core.Digest
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		d, err := s.preload(ctx, img)
+		done <- outcome{d, err}
+	}()
+
+	select {
+	case o := <-done:
+		if o.err != nil {
+			result.Error = o.err.Error()
+		} else {
+			result.Digest = o.d.String()
+		}
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			result.Error = fmt.Sprintf("timed out after %s", s.config.PreloadTimeout)
+		} else {
+			result.Error = ctx.Err().Error()
+		}
+	}
+	return result
+}
+
+// preload resolves img to a manifest and downloads the manifest and all blobs
+// it references. Returns the manifest digest.
+func (s *Server) preload(ctx context.Context, img PreloadImage) (core.Digest, error) {
+	if img.Repo == "" || img.Tag == "" {
+		return core.Digest{}, errors.New("repo and tag are required")
+	}
+	d, err := s.tags.Get(ctx, fmt.Sprintf("%s:%s", img.Repo, img.Tag))
+	if err != nil {
+		return core.Digest{}, fmt.Errorf("get tag: %s", err)
+	}
+	if err := s.ensureDownloaded(img.Repo, d); err != nil {
+		return core.Digest{}, fmt.Errorf("download manifest: %s", err)
+	}
+	f, err := s.cads.Cache().GetFileReader(d.Hex())
+	if err != nil {
+		return core.Digest{}, fmt.Errorf("store: %s", err)
+	}
+	defer f.Close()
+	manifest, _, err := dockerutil.ParseManifestV2(f)
+	if err != nil {
+		return core.Digest{}, fmt.Errorf("parse manifest: %s", err)
+	}
+	refs, err := dockerutil.GetManifestReferences(manifest)
+	if err != nil {
+		return core.Digest{}, fmt.Errorf("get manifest references: %s", err)
+	}
+	for _, ref := range refs {
+		if err := s.ensureDownloaded(img.Repo, ref); err != nil {
+			return core.Digest{}, fmt.Errorf("download blob %s: %s", ref, err)
+		}
+	}
+	return d, nil
+}
+
+// ensureDownloaded downloads d through p2p if it is not already cached.
+func (s *Server) ensureDownloaded(namespace string, d core.Digest) error {
+	if _, err := s.cads.Cache().GetFileStat(d.Hex()); err == nil {
+		return nil
+	}
+	return s.download(namespace, d)
+}
+
+// download downloads d through p2p. Concurrent downloads of d in namespace
+// share a single scheduler download, whose result is returned to all of them.
+// Downloads in different namespaces are never shared, since whether d may be
+// downloaded depends on its namespace. Returns
+// syncutil.ErrQueueFull or syncutil.ErrQueueTimeout if too many other blobs
+// are being downloaded.
+func (s *Server) download(namespace string, d core.Digest) error {
+	_, err, shared := s.downloads.Do(namespace+":"+d.Hex(), func() (interface{}, error) {
+		if err := s.downloadLimiter.Acquire(); err != nil {
+			s.stats.Counter("download_limit_rejected").Inc(1)
+			return nil, err
+		}
+		defer s.downloadLimiter.Release()
+		return nil, s.sched.Download(namespace, d)
+	})
+	if shared {
+		s.stats.Counter("coalesced_downloads").Inc(1)
+	}
+	return err
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/utils/dockerutil"
+	"github.com/uber/kraken/utils/httputil"
+)
+
+func preload(addr string, images []PreloadImage) ([]PreloadResult, error) {
+	b, err := json.Marshal(images)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httputil.Post(
+		fmt.Sprintf("http://%s/preload", addr),
+		httputil.SendBody(bytes.NewReader(b)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var results []PreloadResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func TestPreload(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	repo := "some/repo"
+	config := core.NewBlobFixture()
+	layer1 := core.NewBlobFixture()
+	layer2 := core.NewBlobFixture()
+	manifest, raw := dockerutil.ManifestFixture(config.Digest, layer1.Digest, layer2.Digest)
+
+	mocks.tags.EXPECT().Get(gomock.Any(), repo+":latest").Return(manifest, nil)
+	mocks.sched.EXPECT().Download(repo, manifest).DoAndReturn(
+		func(namespace string, d core.Digest) error {
+			return store.RunDownload(mocks.cads, d, raw)
+		})
+	for _, blob := range []*core.BlobFixture{config, layer1, layer2} {
+		blob := blob
+		mocks.sched.EXPECT().Download(repo, blob.Digest).DoAndReturn(
+			func(namespace string, d core.Digest) error {
+				return store.RunDownload(mocks.cads, d, blob.Content)
+			})
+	}
+
+	addr := mocks.startServer()
+
+	results, err := preload(addr, []PreloadImage{{repo, "latest"}})
+	require.NoError(err)
+	require.Equal([]PreloadResult{{
+		Repo:   repo,
+		Tag:    "latest",
+		Digest: manifest.String(),
+	}}, results)
+
+	for _, d := range []core.Digest{manifest, config.Digest, layer1.Digest, layer2.Digest} {
+		_, err := mocks.cads.Cache().GetFileStat(d.Hex())
+		require.NoError(err)
+	}
+}
+
+func TestPreloadSkipsCachedBlobs(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	repo := "some/repo"
+	config := core.NewBlobFixture()
+	layer1 := core.NewBlobFixture()
+	layer2 := core.NewBlobFixture()
+	manifest, raw := dockerutil.ManifestFixture(config.Digest, layer1.Digest, layer2.Digest)
+
+	require.NoError(store.RunDownload(mocks.cads, manifest, raw))
+	for _, blob := range []*core.BlobFixture{config, layer1, layer2} {
+		require.NoError(store.RunDownload(mocks.cads, blob.Digest, blob.Content))
+	}
+
+	mocks.tags.EXPECT().Get(gomock.Any(), repo+":latest").Return(manifest, nil)
+
+	addr := mocks.startServer()
+
+	results, err := preload(addr, []PreloadImage{{repo, "latest"}})
+	require.NoError(err)
+	require.Len(results, 1)
+	require.Empty(results[0].Error)
+}
+
+func TestPreloadReportsPerImageFailures(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	repo := "some/repo"
+	blob := core.NewBlobFixture()
+	manifest, raw := dockerutil.ManifestFixture(blob.Digest, blob.Digest, blob.Digest)
+
+	mocks.tags.EXPECT().Get(gomock.Any(), repo+":good").Return(manifest, nil)
+	mocks.tags.EXPECT().Get(gomock.Any(), repo+":bad").Return(core.Digest{}, errors.New("some error"))
+	mocks.sched.EXPECT().Download(repo, manifest).DoAndReturn(
+		func(namespace string, d core.Digest) error {
+			return store.RunDownload(mocks.cads, d, raw)
+		})
+	mocks.sched.EXPECT().Download(repo, blob.Digest).DoAndReturn(
+		func(namespace string, d core.Digest) error {
+			return store.RunDownload(mocks.cads, d, blob.Content)
+		})
+
+	addr := mocks.startServer()
+
+	results, err := preload(addr, []PreloadImage{{repo, "good"}, {repo, "bad"}, {"", ""}})
+	require.NoError(err)
+	require.Len(results, 3)
+	require.Equal(manifest.String(), results[0].Digest)
+	require.Empty(results[0].Error)
+	require.Contains(results[1].Error, "get tag")
+	require.Contains(results[2].Error, "required")
+}
+
+func TestPreloadTimeout(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	repo := "some/repo"
+	manifest := core.DigestFixture()
+
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	mocks.tags.EXPECT().Get(gomock.Any(), repo+":latest").Return(manifest, nil)
+	mocks.sched.EXPECT().Download(repo, manifest).DoAndReturn(
+		func(namespace string, d core.Digest) error {
+			<-unblock
+			return errors.New("some error")
+		})
+
+	addr := mocks.startServerWithConfig(Config{PreloadTimeout: 100 * time.Millisecond})
+
+	results, err := preload(addr, []PreloadImage{{repo, "latest"}})
+	require.NoError(err)
+	require.Len(results, 1)
+	require.Contains(results[0].Error, "timed out")
+}
+
+func TestPreloadInvalidBody(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Post(
+		fmt.Sprintf("http://%s/preload", addr),
+		httputil.SendBody(bytes.NewReader([]byte("invalid"))))
+	require.True(httputil.IsStatus(err, http.StatusBadRequest))
+}
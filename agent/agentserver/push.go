@@ -0,0 +1,195 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/persistedretry/tagpush"
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/utils/dockerutil"
+	"github.com/uber/kraken/utils/handler"
+	"github.com/uber/kraken/utils/httputil"
+)
+
+// uploadBlobHandler writes a blob pushed to the agent into the cache. The blob
+// is pinned until a tag referencing it has been pushed to the origins.
+func (s *Server) uploadBlobHandler(w http.ResponseWriter, r *http.Request) error {
+	defer r.Body.Close()
+
+	namespace, err := httputil.ParseParam(r, "namespace")
+	if err != nil {
+		return err
+	}
+	d, err := parseDigest(r)
+	if err != nil {
+		return err
+	}
+	if r.ContentLength < 0 {
+		return handler.ErrorStatus(http.StatusLengthRequired)
+	}
+	if _, err := s.cads.Cache().GetFileStat(d.Hex()); err == nil {
+		// Drain the body so the connection may be reused.
+		io.Copy(ioutil.Discard, r.Body)
+		return nil
+	}
+	if err := s.writeBlob(namespace, d, r.ContentLength, r.Body); err != nil {
+		return err
+	}
+	if err := s.pushPins.PinUpload(d); err != nil {
+		return handler.Errorf("pin upload: %s", err)
+	}
+	s.stats.Counter("pushed_blobs").Inc(1)
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// writeBlob writes the content of d into the cache, verifying it against d.
+func (s *Server) writeBlob(namespace string, d core.Digest, size int64, body io.Reader) error {
+	// The download file may be left over from a failed push, in which case it
+	// is overwritten.
+	err := s.cads.CreateDownloadFile(d.Hex(), size)
+	if err != nil && !os.IsExist(err) && !s.cads.InDownloadError(err) {
+		return handler.Errorf("create download file: %s", err)
+	}
+	if err := s.cads.Download().GetOrSetMetadata(d.Hex(), metadata.NewNamespace(namespace)); err != nil {
+		return handler.Errorf("get or set namespace: %s", err)
+	}
+	f, err := s.cads.GetDownloadFileReadWriter(d.Hex())
+	if err != nil {
+		return handler.Errorf("get download file: %s", err)
+	}
+	defer f.Close()
+
+	if n, err := io.Copy(f, body); err != nil {
+		return handler.Errorf("copy body: %s", err)
+	} else if n != size {
+		return handler.Errorf("short body: got %d of %d bytes", n, size).Status(http.StatusBadRequest)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return handler.Errorf("seek: %s", err)
+	}
+	result, err := d.Digester().FromReader(f)
+	if err != nil {
+		return handler.Errorf("digest: %s", err)
+	}
+	if result != d {
+		return handler.Errorf("digest mismatch: got %s", result).Status(http.StatusBadRequest)
+	}
+	if err := s.cads.MoveDownloadFileToCache(d.Hex()); err != nil && !os.IsExist(err) {
+		return handler.Errorf("move download file to cache: %s", err)
+	}
+	return nil
+}
+
+// putTagHandler pushes tag, along with its manifest and every blob the
+// manifest references, to the origins and build-index. All blobs must have
+// been uploaded to the agent beforehand. The push is retried in the
+// background, so a 202 only means the push has been accepted.
+func (s *Server) putTagHandler(w http.ResponseWriter, r *http.Request) error {
+	tag, err := httputil.ParseParam(r, "tag")
+	if err != nil {
+		return err
+	}
+	d, err := parseDigest(r)
+	if err != nil {
+		return err
+	}
+	i := strings.LastIndex(tag, ":")
+	if i <= 0 {
+		return handler.Errorf("tag must be formatted as repo:tag").Status(http.StatusBadRequest)
+	}
+	namespace := tag[:i]
+
+	deps, err := s.resolvePushDependencies(d)
+	if err != nil {
+		return err
+	}
+	if err := s.pushPins.Acquire(deps); err != nil {
+		return handler.Errorf("pin dependencies: %s", err)
+	}
+	task := tagpush.NewTask(tag, namespace, d, deps, 0)
+	if err := s.pushManager.Add(task); err != nil {
+		s.pushPins.Cancel(deps)
+		if err == persistedretry.ErrTaskExists {
+			return handler.Errorf("tag push already in progress").Status(http.StatusConflict)
+		}
+		return handler.Errorf("add push task: %s", err)
+	}
+	s.stats.Counter("pushed_tags").Inc(1)
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+// resolvePushDependencies returns every blob referenced by manifest d,
+// including d itself, ordered such that referenced blobs precede the
+// manifests which reference them. Manifest lists are resolved recursively.
+// Returns 400 if any blob is not present in the cache.
+func (s *Server) resolvePushDependencies(d core.Digest) (core.DigestList, error) {
+	var deps core.DigestList
+	seen := make(map[core.Digest]bool)
+
+	var resolve func(d core.Digest) error
+	resolve = func(d core.Digest) error {
+		f, err := s.cads.Cache().GetFileReader(d.Hex())
+		if err != nil {
+			if os.IsNotExist(err) || s.cads.InDownloadError(err) {
+				return handler.Errorf("manifest %s not uploaded", d).Status(http.StatusBadRequest)
+			}
+			return handler.Errorf("get manifest: %s", err)
+		}
+		defer f.Close()
+
+		manifest, _, err := dockerutil.ParseManifest(f)
+		if err != nil {
+			return handler.Errorf("parse manifest %s: %s", d, err).Status(http.StatusBadRequest)
+		}
+		refs, err := dockerutil.GetManifestReferences(manifest)
+		if err != nil {
+			return handler.Errorf("get manifest references: %s", err).Status(http.StatusBadRequest)
+		}
+		for _, ref := range refs {
+			if seen[ref] {
+				continue
+			}
+			if dockerutil.IsManifestIndex(manifest) {
+				if err := resolve(ref); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := s.cads.Cache().GetFileStat(ref.Hex()); err != nil {
+				if os.IsNotExist(err) || s.cads.InDownloadError(err) {
+					return handler.Errorf("blob %s not uploaded", ref).Status(http.StatusBadRequest)
+				}
+				return handler.Errorf("stat blob: %s", err)
+			}
+			seen[ref] = true
+			deps = append(deps, ref)
+		}
+		seen[d] = true
+		deps = append(deps, d)
+		return nil
+	}
+	if err := resolve(d); err != nil {
+		return nil, err
+	}
+	return deps, nil
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/persistedretry/tagpush"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
+	mockpersistedretry "github.com/uber/kraken/mocks/lib/persistedretry"
+	"github.com/uber/kraken/utils/dockerutil"
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/testutil"
+)
+
+func (m *serverMocks) startServerWithPushManager(t *testing.T) (string, *mockpersistedretry.MockManager) {
+	ctrl := gomock.NewController(t)
+	m.cleanup.Add(ctrl.Finish)
+
+	manager := mockpersistedretry.NewMockManager(ctrl)
+
+	s := New(
+		Config{AdminToken: _adminToken}, tally.NoopScope, m.cads, m.sched, m.tags, zap.NewNop(),
+		WithPushManager(manager, tagpush.NewPins(m.cads, clock.New())))
+	addr, stop := testutil.StartServer(s.Handler())
+	m.cleanup.Add(stop)
+	return addr, manager
+}
+
+func uploadBlobWithToken(addr, token, namespace string, d core.Digest, content []byte) error {
+	_, err := httputil.Put(
+		fmt.Sprintf("http://%s/namespace/%s/blobs/%s", addr, url.PathEscape(namespace), d),
+		httputil.SendBody(bytes.NewReader(content)),
+		httputil.SendHeaders(map[string]string{"Authorization": "Bearer " + token}),
+		httputil.SendAcceptedCodes(http.StatusOK, http.StatusCreated))
+	return err
+}
+
+func uploadBlob(addr, namespace string, d core.Digest, content []byte) error {
+	return uploadBlobWithToken(addr, _adminToken, namespace, d, content)
+}
+
+func putTag(addr, tag string, d core.Digest) error {
+	_, err := httputil.Put(
+		fmt.Sprintf("http://%s/tags/%s/digest/%s", addr, url.PathEscape(tag), d),
+		sendAdminToken(),
+		httputil.SendAcceptedCodes(http.StatusAccepted))
+	return err
+}
+
+func pushPinned(t *testing.T, cads *store.CADownloadStore, d core.Digest) bool {
+	var pm metadata.Persist
+	require.NoError(t, cads.Cache().GetMetadata(d.Hex(), &pm))
+	return pm.Value
+}
+
+func TestPushRoutesDisabledByDefault(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	blob := core.NewBlobFixture()
+
+	err := uploadBlob(addr, "some/repo", blob.Digest, blob.Content)
+	require.True(httputil.IsStatus(err, http.StatusMethodNotAllowed))
+}
+
+func TestUploadBlob(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, _ := mocks.startServerWithPushManager(t)
+
+	blob := core.NewBlobFixture()
+
+	require.NoError(uploadBlob(addr, "some/repo", blob.Digest, blob.Content))
+
+	f, err := mocks.cads.Cache().GetFileReader(blob.Digest.Hex())
+	require.NoError(err)
+	defer f.Close()
+
+	require.True(pushPinned(t, mocks.cads, blob.Digest))
+
+	// Uploading the same blob again is a no-op.
+	require.NoError(uploadBlob(addr, "some/repo", blob.Digest, blob.Content))
+}
+
+func TestUploadBlobUnauthorized(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, _ := mocks.startServerWithPushManager(t)
+
+	blob := core.NewBlobFixture()
+
+	err := uploadBlobWithToken(addr, "wrong-token", "some/repo", blob.Digest, blob.Content)
+	require.True(httputil.IsStatus(err, http.StatusUnauthorized))
+}
+
+func TestUploadBlobDigestMismatch(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, _ := mocks.startServerWithPushManager(t)
+
+	blob := core.NewBlobFixture()
+
+	err := uploadBlob(addr, "some/repo", blob.Digest, []byte("some other content"))
+	require.True(httputil.IsStatus(err, http.StatusBadRequest))
+
+	_, err = mocks.cads.Cache().GetFileStat(blob.Digest.Hex())
+	require.Error(err)
+}
+
+func TestPutTag(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, manager := mocks.startServerWithPushManager(t)
+
+	repo := "some/repo"
+	config := core.NewBlobFixture()
+	layer1 := core.NewBlobFixture()
+	layer2 := core.NewBlobFixture()
+	manifest, raw := dockerutil.ManifestFixture(config.Digest, layer1.Digest, layer2.Digest)
+
+	for _, blob := range []*core.BlobFixture{config, layer1, layer2} {
+		require.NoError(uploadBlob(addr, repo, blob.Digest, blob.Content))
+	}
+	require.NoError(uploadBlob(addr, repo, manifest, raw))
+
+	tag := repo + ":latest"
+
+	manager.EXPECT().Add(tagpush.MatchTask(tagpush.NewTask(
+		tag, repo, manifest, core.DigestList{config.Digest, layer1.Digest, layer2.Digest, manifest}, 0))).Return(nil)
+
+	require.NoError(putTag(addr, tag, manifest))
+
+	var refs metadata.PushRefs
+	require.NoError(mocks.cads.Cache().GetMetadata(manifest.Hex(), &refs))
+	require.Equal(1, refs.Count)
+}
+
+func TestPutTagMissingLayer(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, _ := mocks.startServerWithPushManager(t)
+
+	repo := "some/repo"
+	config := core.NewBlobFixture()
+	layer1 := core.NewBlobFixture()
+	layer2 := core.NewBlobFixture()
+	manifest, raw := dockerutil.ManifestFixture(config.Digest, layer1.Digest, layer2.Digest)
+
+	require.NoError(uploadBlob(addr, repo, config.Digest, config.Content))
+	require.NoError(uploadBlob(addr, repo, layer1.Digest, layer1.Content))
+	require.NoError(uploadBlob(addr, repo, manifest, raw))
+
+	err := putTag(addr, repo+":latest", manifest)
+	require.True(httputil.IsStatus(err, http.StatusBadRequest))
+}
+
+func TestPutTagAlreadyInProgress(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, manager := mocks.startServerWithPushManager(t)
+
+	repo := "some/repo"
+	config := core.NewBlobFixture()
+	layer1 := core.NewBlobFixture()
+	layer2 := core.NewBlobFixture()
+	manifest, raw := dockerutil.ManifestFixture(config.Digest, layer1.Digest, layer2.Digest)
+
+	for _, blob := range []*core.BlobFixture{config, layer1, layer2} {
+		require.NoError(uploadBlob(addr, repo, blob.Digest, blob.Content))
+	}
+	require.NoError(uploadBlob(addr, repo, manifest, raw))
+
+	manager.EXPECT().Add(gomock.Any()).Return(persistedretry.ErrTaskExists)
+
+	err := putTag(addr, repo+":latest", manifest)
+	require.True(httputil.IsConflict(err))
+
+	// Rejected pushes do not hold references to the blobs.
+	var refs metadata.PushRefs
+	require.NoError(mocks.cads.Cache().GetMetadata(manifest.Hex(), &refs))
+	require.Equal(0, refs.Count)
+	require.True(pushPinned(t, mocks.cads, manifest))
+}
+
+func TestPutTagInvalidTag(t *testing.T) {
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, _ := mocks.startServerWithPushManager(t)
+
+	err := putTag(addr, "latest", core.DigestFixture())
+	require.True(t, httputil.IsStatus(err, http.StatusBadRequest))
+}
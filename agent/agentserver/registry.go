@@ -0,0 +1,160 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uber/kraken/build-index/tagclient"
+	"github.com/uber/kraken/utils/handler"
+	"github.com/uber/kraken/utils/log"
+	"github.com/uber/kraken/utils/stringset"
+)
+
+type catalogResponse struct {
+	Repositories []string `json:"repositories"`
+}
+
+type tagsListResponse struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// catalogHandler implements the docker registry catalog endpoint by listing
+// repositories from the build-index. Supports n/last pagination.
+func (s *Server) catalogHandler(w http.ResponseWriter, r *http.Request) error {
+	names, err := s.tags.List(r.Context(), "")
+	if err != nil {
+		return listError(err)
+	}
+	repos := stringset.New()
+	for _, name := range names {
+		repo, _, ok := splitTagName(name)
+		if !ok {
+			continue
+		}
+		repos.Add(repo)
+	}
+	page, err := paginate(w, r, repos.ToSlice())
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(&catalogResponse{Repositories: page}); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// tagsListHandler implements the docker registry tags list endpoint, i.e.
+// GET /v2/{repo}/tags/list, by listing tags from the build-index. Supports
+// n/last pagination. Repos may contain slashes, hence the path is parsed
+// manually.
+func (s *Server) tagsListHandler(w http.ResponseWriter, r *http.Request) error {
+	repo := strings.TrimPrefix(r.URL.Path, "/v2/")
+	if !strings.HasSuffix(repo, "/tags/list") {
+		return handler.ErrorStatus(http.StatusNotFound)
+	}
+	repo = strings.TrimSuffix(repo, "/tags/list")
+	if repo == "" {
+		return handler.ErrorStatus(http.StatusNotFound)
+	}
+	names, err := s.tags.List(r.Context(), path.Join(repo, "_manifests/tags"))
+	if err != nil {
+		return listError(err)
+	}
+	var tags []string
+	for _, name := range names {
+		tagRepo, tag, ok := splitTagName(name)
+		if !ok || tagRepo != repo {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	page, err := paginate(w, r, tags)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(&tagsListResponse{Name: repo, Tags: page}); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// splitTagName splits a build-index tag name of the form repo:tag.
+func splitTagName(name string) (repo, tag string, ok bool) {
+	parts := strings.Split(name, ":")
+	if len(parts) != 2 {
+		log.With("tag", name).Errorf("Invalid tag format, expected repo:tag")
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func listError(err error) error {
+	if tagclient.IsServerUnavailable(err) {
+		return handler.Errorf("list: %s", err).Status(http.StatusServiceUnavailable)
+	}
+	if tagclient.IsUnauthorized(err) {
+		return handler.Errorf("list: %s", err).Status(http.StatusBadGateway)
+	}
+	return handler.Errorf("list: %s", err)
+}
+
+// paginate sorts entries and returns the page selected by the "n" and "last"
+// query parameters of r, following the docker registry pagination spec:
+// entries strictly after last are returned, up to n of them. If entries
+// remain after the page, a Link header pointing to the next page is set on w.
+func paginate(w http.ResponseWriter, r *http.Request, entries []string) ([]string, error) {
+	sort.Strings(entries)
+
+	q := r.URL.Query()
+	if last := q.Get("last"); last != "" {
+		entries = entries[sort.Search(len(entries), func(i int) bool {
+			return entries[i] > last
+		}):]
+	}
+	if q.Get("n") == "" {
+		return nonNil(entries), nil
+	}
+	n, err := strconv.Atoi(q.Get("n"))
+	if err != nil || n < 0 {
+		return nil, handler.Errorf("invalid n: %q", q.Get("n")).Status(http.StatusBadRequest)
+	}
+	if len(entries) <= n {
+		return nonNil(entries), nil
+	}
+	entries = entries[:n]
+	if n > 0 {
+		next := url.Values{}
+		next.Set("n", strconv.Itoa(n))
+		next.Set("last", entries[n-1])
+		w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", r.URL.Path, next.Encode()))
+	}
+	return nonNil(entries), nil
+}
+
+// nonNil ensures empty pages are encoded as empty JSON arrays instead of null.
+func nonNil(entries []string) []string {
+	if entries == nil {
+		return []string{}
+	}
+	return entries
+}
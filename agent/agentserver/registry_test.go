@@ -0,0 +1,152 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/kraken/build-index/tagclient"
+	"github.com/uber/kraken/utils/httputil"
+)
+
+func TestCatalogHandler(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	mocks.tags.EXPECT().List(gomock.Any(), "").Return(
+		[]string{"c/d:v1", "a:v1", "a:v2", "b:v1", "invalid"}, nil)
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/v2/_catalog", addr))
+	require.NoError(err)
+	defer resp.Body.Close()
+
+	var result catalogResponse
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal([]string{"a", "b", "c/d"}, result.Repositories)
+	require.Empty(resp.Header.Get("Link"))
+}
+
+func TestCatalogHandlerPagination(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	mocks.tags.EXPECT().List(gomock.Any(), "").Return(
+		[]string{"a:v1", "b:v1", "c:v1", "d:v1"}, nil).Times(2)
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/v2/_catalog?n=2&last=a", addr))
+	require.NoError(err)
+	defer resp.Body.Close()
+
+	var result catalogResponse
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal([]string{"b", "c"}, result.Repositories)
+	require.Equal(`</v2/_catalog?last=c&n=2>; rel="next"`, resp.Header.Get("Link"))
+
+	resp, err = httputil.Get(fmt.Sprintf("http://%s/v2/_catalog?n=2&last=c", addr))
+	require.NoError(err)
+	defer resp.Body.Close()
+
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal([]string{"d"}, result.Repositories)
+	require.Empty(resp.Header.Get("Link"))
+}
+
+func TestCatalogHandlerInvalidPageSize(t *testing.T) {
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	mocks.tags.EXPECT().List(gomock.Any(), "").Return([]string{"a:v1"}, nil)
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/v2/_catalog?n=foo", addr))
+	require.True(t, httputil.IsStatus(err, http.StatusBadRequest))
+}
+
+func TestCatalogHandlerBuildIndexUnavailable(t *testing.T) {
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	mocks.tags.EXPECT().List(gomock.Any(), "").Return(nil, tagclient.ErrCircuitOpen)
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/v2/_catalog", addr))
+	require.True(t, httputil.IsStatus(err, http.StatusServiceUnavailable))
+}
+
+func TestTagsListHandler(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	mocks.tags.EXPECT().List(gomock.Any(), "foo/bar/_manifests/tags").Return(
+		[]string{"foo/bar:v2", "foo/bar:v1", "foo/bar:v3"}, nil)
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/v2/foo/bar/tags/list?n=2", addr))
+	require.NoError(err)
+	defer resp.Body.Close()
+
+	var result tagsListResponse
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal("foo/bar", result.Name)
+	require.Equal([]string{"v1", "v2"}, result.Tags)
+	require.Equal(`</v2/foo/bar/tags/list?last=v2&n=2>; rel="next"`, resp.Header.Get("Link"))
+}
+
+func TestTagsListHandlerEmpty(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	mocks.tags.EXPECT().List(gomock.Any(), "foo/_manifests/tags").Return(nil, nil)
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/v2/foo/tags/list", addr))
+	require.NoError(err)
+	defer resp.Body.Close()
+
+	var result tagsListResponse
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal([]string{}, result.Tags)
+}
+
+func TestTagsListHandlerUnknownEndpoint(t *testing.T) {
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/v2/foo/manifests/latest", addr))
+	require.True(t, httputil.IsStatus(err, http.StatusNotFound))
+}
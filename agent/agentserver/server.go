@@ -18,24 +18,30 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	_ "net/http/pprof" // Registers /debug/pprof endpoints in http.DefaultServeMux.
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/pressly/chi"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/uber-go/tally"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/middleware"
+	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/persistedretry/tagpush"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/lib/torrent/scheduler"
 	"github.com/uber/kraken/utils/handler"
 	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/log"
+	"github.com/uber/kraken/utils/syncutil"
 )
 
-// Config defines Server configuration.
-type Config struct{}
-
 // Server defines the agent HTTP server.
 type Server struct {
 	config Config
@@ -43,6 +49,56 @@ type Server struct {
 	cads   *store.CADownloadStore
 	sched  scheduler.ReloadableScheduler
 	tags   tagclient.Client
+
+	accessLog *zap.Logger
+
+	// downloads coalesces concurrent downloads of the same blob.
+	downloads singleflight.Group
+
+	// downloadLimiter limits concurrent downloads of distinct blobs.
+	downloadLimiter *syncutil.Limiter
+
+	readinessChecks []readinessCheck
+
+	// maintenance is set while the agent is being drained.
+	maintenance *atomic.Bool
+
+	// pushManager, if set, enables pushing images through the agent.
+	pushManager persistedretry.Manager
+	pushPins    *tagpush.Pins
+}
+
+type readinessCheck struct {
+	name  string
+	check func() error
+}
+
+// Option allows setting optional Server parameters.
+type Option func(*Server)
+
+// WithReadinessCheck adds a check which must pass before the agent reports
+// ready, in addition to the scheduler and store checks.
+func WithReadinessCheck(name string, check func() error) Option {
+	return func(s *Server) {
+		s.readinessChecks = append(s.readinessChecks, readinessCheck{name, check})
+	}
+}
+
+// WithDownloadLimiter limits concurrent blob downloads with l, which may be
+// shared with other components downloading blobs, e.g. the registry.
+func WithDownloadLimiter(l *syncutil.Limiter) Option {
+	return func(s *Server) { s.downloadLimiter = l }
+}
+
+// WithPushManager enables the write path of the agent, where blobs and tags
+// pushed to the agent are replicated to the origins and build-index through
+// tasks added to m. Pushed blobs are pinned in the cache through pins until
+// they have been replicated.
+func WithPushManager(m persistedretry.Manager, pins *tagpush.Pins) Option {
+	return func(s *Server) {
+		s.pushManager = m
+		s.pushPins = pins
+	}
 }
 
 // New creates a new Server.
@@ -51,36 +107,103 @@ func New(
 	stats tally.Scope,
 	cads *store.CADownloadStore,
 	sched scheduler.ReloadableScheduler,
-	tags tagclient.Client) *Server {
+	tags tagclient.Client,
+	accessLog *zap.Logger,
+	opts ...Option) *Server {
+
+	config = config.applyDefaults()
 
 	stats = stats.Tagged(map[string]string{
 		"module": "agentserver",
 	})
-	return &Server{config, stats, cads, sched, tags}
+	s := &Server{
+		config:      config,
+		stats:       stats,
+		cads:        cads,
+		sched:       sched,
+		tags:        tags,
+		accessLog:   accessLog,
+		maintenance: atomic.NewBool(false),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.downloadLimiter == nil {
+		s.downloadLimiter = syncutil.NewLimiter(syncutil.LimiterConfig{})
+	}
+	return s
 }
 
 // Handler returns the HTTP handler.
 func (s *Server) Handler() http.Handler {
 	r := chi.NewRouter()
 
+	r.Use(middleware.Tracer())
 	r.Use(middleware.StatusCounter(s.stats))
 	r.Use(middleware.LatencyTimer(s.stats))
+	r.Use(middleware.AccessLogger(s.accessLog))
+
+	// route registers h, requiring admin authorization on admin routes if
+	// admin credentials are configured.
+	admin := s.adminRoutes()
+	route := func(method, pattern string, h handler.ErrHandler) {
+		if admin[method+" "+pattern] {
+			if s.adminEnabled() {
+				h = s.requireAdmin(h)
+			}
+			delete(admin, method+" "+pattern)
+		}
+		r.Method(method, pattern, handler.Wrap(h))
+	}
+
+	route("GET", "/health", s.healthHandler)
+	route("GET", "/readiness", s.readinessCheckHandler)
+
+	route("GET", "/tags/{tag}", s.getTagHandler)
 
-	r.Get("/health", handler.Wrap(s.healthHandler))
+	// Read-only docker registry listing endpoints, served from the build-index.
+	route("GET", "/v2/_catalog", s.catalogHandler)
+	route("GET", "/v2/*", s.tagsListHandler)
 
-	r.Get("/tags/{tag}", handler.Wrap(s.getTagHandler))
+	route("GET", "/namespace/{namespace}/blobs/{digest}", s.downloadBlobHandler)
 
-	r.Get("/namespace/{namespace}/blobs/{digest}", handler.Wrap(s.downloadBlobHandler))
+	route("HEAD", "/blobs/{digest}", s.statBlobHandler)
+	route("DELETE", "/blobs/{digest}", s.deleteBlobHandler)
+
+	route("POST", "/pin/{digest}", s.pinBlobHandler)
+	route("DELETE", "/pin/{digest}", s.unpinBlobHandler)
+
+	route("POST", "/preload", s.preloadHandler)
+
+	if s.pushManager != nil {
+		route("PUT", "/namespace/{namespace}/blobs/{digest}", s.uploadBlobHandler)
+		route("PUT", "/tags/{tag}/digest/{digest}", s.putTagHandler)
+	}
 
-	r.Delete("/blobs/{digest}", handler.Wrap(s.deleteBlobHandler))
+	route("POST", "/maintenance", s.enterMaintenanceHandler)
+	route("DELETE", "/maintenance", s.exitMaintenanceHandler)
 
 	// Dangerous endpoint for running experiments.
-	r.Patch("/x/config/scheduler", handler.Wrap(s.patchSchedulerConfigHandler))
+	route("PATCH", "/x/config/scheduler", s.patchSchedulerConfigHandler)
 
-	r.Get("/x/blacklist", handler.Wrap(s.getBlacklistHandler))
+	route("GET", "/x/blacklist", s.getBlacklistHandler)
+	route("GET", "/x/banned_peers", s.getBannedPeersHandler)
 
-	// Serves /debug/pprof endpoints.
-	r.Mount("/", http.DefaultServeMux)
+	route("GET", "/torrents", s.listTorrentsHandler)
+	route("GET", "/torrents/{infohash}", s.getTorrentHandler)
+
+	route("GET", "/x/bandwidth", s.getBandwidthHandler)
+	route("PATCH", "/x/bandwidth", s.patchBandwidthHandler)
+
+	for k := range admin {
+		if !_adminRoutes[k] {
+			log.Warnf("Ignoring unknown admin route %q", k)
+		}
+	}
+
+	// Serves metrics registered with the default Prometheus registry, which
+	// includes all agent metrics when using the prometheus metrics backend.
+	r.Method("GET", "/metrics", promhttp.Handler())
 
 	return r
 }
@@ -91,19 +214,30 @@ func (s *Server) getTagHandler(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
-	d, err := s.tags.Get(tag)
+	d, err := s.tags.Get(r.Context(), tag)
 	if err != nil {
 		if err == tagclient.ErrTagNotFound {
 			return handler.ErrorStatus(http.StatusNotFound)
 		}
+		if tagclient.IsServerUnavailable(err) {
+			return handler.Errorf("get tag: %s", err).Status(http.StatusServiceUnavailable)
+		}
+		if tagclient.IsUnauthorized(err) {
+			return handler.Errorf("get tag: %s", err).Status(http.StatusBadGateway)
+		}
 		return handler.Errorf("get tag: %s", err)
 	}
 	io.WriteString(w, d.String())
 	return nil
 }
 
-// downloadBlobHandler downloads a blob through p2p.
+// downloadBlobHandler downloads a blob through p2p. Cached blobs are served
+// directly, else the blob is streamed to the client as pieces are downloaded.
+// Both support single Range requests.
 func (s *Server) downloadBlobHandler(w http.ResponseWriter, r *http.Request) error {
+	if err := s.checkMaintenance(); err != nil {
+		return err
+	}
 	namespace, err := httputil.ParseParam(r, "namespace")
 	if err != nil {
 		return err
@@ -115,37 +249,78 @@ func (s *Server) downloadBlobHandler(w http.ResponseWriter, r *http.Request) err
 	f, err := s.cads.Cache().GetFileReader(d.Hex())
 	if err != nil {
 		if os.IsNotExist(err) || s.cads.InDownloadError(err) {
-			if err := s.sched.Download(namespace, d); err != nil {
-				if err == scheduler.ErrTorrentNotFound {
-					return handler.ErrorStatus(http.StatusNotFound)
-				}
-				return handler.Errorf("download torrent: %s", err)
-			}
-			f, err = s.cads.Cache().GetFileReader(d.Hex())
-			if err != nil {
-				return handler.Errorf("store: %s", err)
-			}
-		} else {
-			return handler.Errorf("store: %s", err)
+			return s.streamBlob(w, r, namespace, d)
 		}
+		return handler.Errorf("store: %s", err)
+	}
+	defer f.Close()
+	http.ServeContent(w, r, "", time.Time{}, f)
+	return nil
+}
+
+// statBlobHandler reports whether a blob is present on the agent without
+// triggering a download. Returns 200 and the blob size if the blob is cached,
+// 202 if the blob is still being downloaded, and 404 otherwise.
+func (s *Server) statBlobHandler(w http.ResponseWriter, r *http.Request) error {
+	d, err := parseDigest(r)
+	if err != nil {
+		return err
+	}
+	info, downloading, err := s.statBlob(d)
+	if err != nil {
+		return err
 	}
-	if _, err := io.Copy(w, f); err != nil {
-		return fmt.Errorf("copy file: %s", err)
+	if downloading {
+		w.WriteHeader(http.StatusAccepted)
+		return nil
 	}
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.WriteHeader(http.StatusOK)
 	return nil
 }
 
+// deleteBlobHandler evicts a cached blob from the agent and stops seeding it.
+// Returns 404 if the blob is not present and 409 if the blob is still being
+// downloaded.
 func (s *Server) deleteBlobHandler(w http.ResponseWriter, r *http.Request) error {
 	d, err := parseDigest(r)
 	if err != nil {
 		return err
 	}
+	_, downloading, err := s.statBlob(d)
+	if err != nil {
+		return err
+	}
+	if downloading {
+		return handler.Errorf("blob is currently downloading").Status(http.StatusConflict)
+	}
 	if err := s.sched.RemoveTorrent(d); err != nil {
 		return handler.Errorf("remove torrent: %s", err)
 	}
 	return nil
 }
 
+// statBlob returns the file info of the blob of d, and whether the blob is
+// still being downloaded. Returns a 404 handler error if the blob is not
+// present in any state.
+func (s *Server) statBlob(d core.Digest) (os.FileInfo, bool, error) {
+	info, err := s.cads.Cache().GetFileStat(d.Hex())
+	if err == nil {
+		return info, false, nil
+	}
+	if !os.IsNotExist(err) && !s.cads.InDownloadError(err) {
+		return nil, false, handler.Errorf("stat cache: %s", err)
+	}
+	info, err = s.cads.Download().GetFileStat(d.Hex())
+	if err != nil {
+		if os.IsNotExist(err) || s.cads.InCacheError(err) {
+			return nil, false, handler.ErrorStatus(http.StatusNotFound)
+		}
+		return nil, false, handler.Errorf("stat download: %s", err)
+	}
+	return info, true, nil
+}
+
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) error {
 	if err := s.sched.Probe(); err != nil {
 		return handler.Errorf("probe torrent client: %s", err)
@@ -154,6 +329,44 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// readinessCheckHandler returns 200 once the agent is able to serve downloads.
+// See CheckReadiness. Unlike healthHandler, failures here do not imply the
+// agent should be restarted.
+func (s *Server) readinessCheckHandler(w http.ResponseWriter, r *http.Request) error {
+	if err := s.CheckReadiness(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "OK")
+	return nil
+}
+
+// CheckReadiness returns nil once the agent is able to serve downloads, i.e.
+// the scheduler can reach a healthy tracker, the store is initialized, and any
+// additional readiness checks pass. Agents in maintenance are never ready.
+func (s *Server) CheckReadiness() error {
+	if err := s.checkMaintenance(); err != nil {
+		return err
+	}
+	if err := s.sched.Probe(); err != nil {
+		return handler.Errorf("probe torrent client: %s", err).Status(http.StatusServiceUnavailable)
+	}
+	if err := s.sched.CheckReadiness(); err != nil {
+		return handler.Errorf("scheduler not ready: %s", err).Status(http.StatusServiceUnavailable)
+	}
+	if s.cads == nil {
+		return handler.Errorf("store not initialized").Status(http.StatusServiceUnavailable)
+	}
+	if err := s.cads.CheckReadiness(); err != nil {
+		return handler.Errorf("store not ready: %s", err).Status(http.StatusServiceUnavailable)
+	}
+	for _, c := range s.readinessChecks {
+		if err := c.check(); err != nil {
+			return handler.Errorf("%s not ready: %s", c.name, err).Status(http.StatusServiceUnavailable)
+		}
+	}
+	return nil
+}
+
 // patchSchedulerConfigHandler restarts the agent torrent scheduler with
 // the config in request body.
 func (s *Server) patchSchedulerConfigHandler(w http.ResponseWriter, r *http.Request) error {
@@ -177,15 +390,103 @@ func (s *Server) getBlacklistHandler(w http.ResponseWriter, r *http.Request) err
 	return nil
 }
 
+// getBannedPeersHandler returns all peers currently banned for misbehavior.
+func (s *Server) getBannedPeersHandler(w http.ResponseWriter, r *http.Request) error {
+	banned, err := s.sched.BannedPeers()
+	if err != nil {
+		return handler.Errorf("banned peers: %s", err)
+	}
+	if err := json.NewEncoder(w).Encode(&banned); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// listTorrentsHandler returns the progress of all torrents the scheduler is
+// currently leeching or seeding.
+func (s *Server) listTorrentsHandler(w http.ResponseWriter, r *http.Request) error {
+	stats, err := s.sched.TorrentStats()
+	if err != nil {
+		return handler.Errorf("torrent stats: %s", err)
+	}
+	if err := json.NewEncoder(w).Encode(&stats); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// getTorrentHandler returns the progress of a single torrent.
+func (s *Server) getTorrentHandler(w http.ResponseWriter, r *http.Request) error {
+	raw, err := httputil.ParseParam(r, "infohash")
+	if err != nil {
+		return err
+	}
+	h, err := core.NewInfoHashFromHex(raw)
+	if err != nil {
+		return handler.Errorf("parse infohash: %s", err).Status(http.StatusBadRequest)
+	}
+	stats, err := s.sched.TorrentStatsByInfoHash(h)
+	if err != nil {
+		if err == scheduler.ErrTorrentNotFound {
+			return handler.ErrorStatus(http.StatusNotFound)
+		}
+		return handler.Errorf("torrent stats: %s", err)
+	}
+	if err := json.NewEncoder(w).Encode(&stats); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// bandwidthLimits is the body of bandwidth admin requests and responses.
+type bandwidthLimits struct {
+	EgressBitsPerSec  uint64 `json:"egress_bits_per_sec"`
+	IngressBitsPerSec uint64 `json:"ingress_bits_per_sec"`
+}
+
+// getBandwidthHandler returns the bandwidth limits currently enforced by the
+// scheduler.
+func (s *Server) getBandwidthHandler(w http.ResponseWriter, r *http.Request) error {
+	var limits bandwidthLimits
+	limits.EgressBitsPerSec, limits.IngressBitsPerSec = s.sched.BandwidthLimits()
+	if err := json.NewEncoder(w).Encode(&limits); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// patchBandwidthHandler changes the scheduler bandwidth limits without
+// restarting the scheduler. Omitted limits are left unchanged.
+func (s *Server) patchBandwidthHandler(w http.ResponseWriter, r *http.Request) error {
+	defer r.Body.Close()
+	var limits bandwidthLimits
+	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+		return handler.Errorf("json decode: %s", err).Status(http.StatusBadRequest)
+	}
+	egress, ingress := s.sched.BandwidthLimits()
+	if limits.EgressBitsPerSec == 0 {
+		limits.EgressBitsPerSec = egress
+	}
+	if limits.IngressBitsPerSec == 0 {
+		limits.IngressBitsPerSec = ingress
+	}
+	if err := s.sched.SetBandwidthLimits(
+		limits.EgressBitsPerSec, limits.IngressBitsPerSec); err != nil {
+
+		return handler.Errorf("set bandwidth limits: %s", err).Status(http.StatusBadRequest)
+	}
+	return nil
+}
+
 func parseDigest(r *http.Request) (core.Digest, error) {
 	raw, err := httputil.ParseParam(r, "digest")
 	if err != nil {
 		return core.Digest{}, err
 	}
 	// TODO(codyg): Accept only a fully formed digest.
-	d, err := core.NewSHA256DigestFromHex(raw)
+	d, err := core.NewDigestFromHex(raw)
 	if err != nil {
-		d, err = core.ParseSHA256Digest(raw)
+		d, err = core.ParseDigest(raw)
 		if err != nil {
 			return core.Digest{}, handler.Errorf("parse digest: %s", err).Status(http.StatusBadRequest)
 		}
@@ -19,22 +19,32 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 	"github.com/uber-go/tally"
+	"go.uber.org/zap"
 
 	"github.com/uber/kraken/agent/agentclient"
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
 	"github.com/uber/kraken/lib/torrent/scheduler"
 	"github.com/uber/kraken/lib/torrent/scheduler/connstate"
+	"github.com/uber/kraken/lib/torrent/scheduler/reputation"
+	"github.com/uber/kraken/lib/torrent/storage/agentstorage"
+	"github.com/uber/kraken/lib/torrent/storage/piecereader"
 	mocktagclient "github.com/uber/kraken/mocks/build-index/tagclient"
 	mockscheduler "github.com/uber/kraken/mocks/lib/torrent/scheduler"
 	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/syncutil"
 	"github.com/uber/kraken/utils/testutil"
 )
 
@@ -61,8 +71,20 @@ func newServerMocks(t *testing.T) (*serverMocks, func()) {
 	return &serverMocks{cads, sched, tags, &cleanup}, cleanup.Run
 }
 
+// _adminToken authorizes requests to admin routes of servers started with
+// startServer.
+const _adminToken = "admin-token"
+
+func sendAdminToken() httputil.SendOption {
+	return httputil.SendHeaders(map[string]string{"Authorization": "Bearer " + _adminToken})
+}
+
 func (m *serverMocks) startServer() string {
-	s := New(Config{}, tally.NoopScope, m.cads, m.sched, m.tags)
+	return m.startServerWithConfig(Config{AdminToken: _adminToken})
+}
+
+func (m *serverMocks) startServerWithConfig(config Config) string {
+	s := New(config, tally.NoopScope, m.cads, m.sched, m.tags, zap.NewNop())
 	addr, stop := testutil.StartServer(s.Handler())
 	m.cleanup.Add(stop)
 	return addr
@@ -77,7 +99,7 @@ func TestGetTag(t *testing.T) {
 	tag := core.TagFixture()
 	d := core.DigestFixture()
 
-	mocks.tags.EXPECT().Get(tag).Return(d, nil)
+	mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(d, nil)
 
 	c := agentclient.New(mocks.startServer())
 
@@ -94,7 +116,7 @@ func TestGetTagNotFound(t *testing.T) {
 
 	tag := core.TagFixture()
 
-	mocks.tags.EXPECT().Get(tag).Return(core.Digest{}, tagclient.ErrTagNotFound)
+	mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(core.Digest{}, tagclient.ErrTagNotFound)
 
 	c := agentclient.New(mocks.startServer())
 
@@ -103,6 +125,23 @@ func TestGetTagNotFound(t *testing.T) {
 	require.Equal(agentclient.ErrTagNotFound, err)
 }
 
+func TestGetTagBuildIndexUnavailable(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	tag := core.TagFixture()
+
+	mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(core.Digest{}, tagclient.ErrCircuitOpen)
+
+	c := agentclient.New(mocks.startServer())
+
+	_, err := c.GetTag(tag)
+	require.Error(err)
+	require.True(httputil.IsStatus(err, http.StatusServiceUnavailable))
+}
+
 func TestDownload(t *testing.T) {
 	require := require.New(t)
 
@@ -127,6 +166,180 @@ func TestDownload(t *testing.T) {
 	require.Equal(string(blob.Content), string(result))
 }
 
+func TestConcurrentDownloadsShareSchedulerDownload(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	namespace := core.TagFixture()
+	blob := core.NewBlobFixture()
+
+	mocks.sched.EXPECT().Download(namespace, blob.Digest).DoAndReturn(
+		func(namespace string, d core.Digest) error {
+			time.Sleep(250 * time.Millisecond)
+			return store.RunDownload(mocks.cads, d, blob.Content)
+		})
+
+	addr := mocks.startServer()
+	c := agentclient.New(addr)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := c.Download(namespace, blob.Digest)
+			require.NoError(err)
+			result, err := ioutil.ReadAll(r)
+			require.NoError(err)
+			require.Equal(string(blob.Content), string(result))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentDownloadsInDifferentNamespacesAreNotShared(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	blob := core.NewBlobFixture()
+
+	namespaces := []string{core.TagFixture(), core.TagFixture()}
+	for _, ns := range namespaces {
+		mocks.sched.EXPECT().Download(ns, blob.Digest).DoAndReturn(
+			func(namespace string, d core.Digest) error {
+				time.Sleep(250 * time.Millisecond)
+				return scheduler.ErrTorrentNotFound
+			})
+	}
+
+	addr := mocks.startServer()
+	c := agentclient.New(addr)
+
+	var wg sync.WaitGroup
+	for _, ns := range namespaces {
+		wg.Add(1)
+		go func(ns string) {
+			defer wg.Done()
+			_, err := c.Download(ns, blob.Digest)
+			require.Error(err)
+		}(ns)
+	}
+	wg.Wait()
+}
+
+func TestDownloadRange(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	namespace := core.TagFixture()
+	blob := core.SizedBlobFixture(16, 4)
+
+	require.NoError(store.RunDownload(mocks.cads, blob.Digest, blob.Content))
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Get(
+		fmt.Sprintf("http://%s/namespace/%s/blobs/%s", addr, url.PathEscape(namespace), blob.Digest),
+		httputil.SendHeaders(map[string]string{"Range": "bytes=2-9"}),
+		httputil.SendAcceptedCodes(http.StatusPartialContent))
+	require.NoError(err)
+	defer resp.Body.Close()
+	result, err := ioutil.ReadAll(resp.Body)
+	require.NoError(err)
+	require.Equal(string(blob.Content[2:10]), string(result))
+	require.Equal("bytes 2-9/16", resp.Header.Get("Content-Range"))
+}
+
+func TestDownloadStreamsWhilePiecesComplete(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	namespace := core.TagFixture()
+	blob := core.SizedBlobFixture(16, 4)
+	mi := blob.MetaInfo
+
+	mocks.sched.EXPECT().Download(namespace, blob.Digest).DoAndReturn(
+		func(namespace string, d core.Digest) error {
+			if err := mocks.cads.CreateDownloadFile(d.Hex(), mi.Length()); err != nil {
+				return err
+			}
+			if _, err := mocks.cads.Download().SetMetadata(
+				d.Hex(), metadata.NewTorrentMeta(mi)); err != nil {
+				return err
+			}
+			tor, err := agentstorage.NewTorrent(mocks.cads, mi)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < tor.NumPieces(); i++ {
+				time.Sleep(10 * time.Millisecond)
+				start := int64(i) * mi.PieceLength()
+				end := start + tor.PieceLength(i)
+				src := piecereader.NewBuffer(blob.Content[start:end])
+				if err := tor.WritePiece(src, i); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	addr := mocks.startServerWithConfig(Config{StreamPollInterval: time.Millisecond})
+
+	resp, err := httputil.Get(
+		fmt.Sprintf("http://%s/namespace/%s/blobs/%s", addr, url.PathEscape(namespace), blob.Digest),
+		httputil.SendHeaders(map[string]string{"Range": "bytes=5-"}),
+		httputil.SendAcceptedCodes(http.StatusPartialContent))
+	require.NoError(err)
+	defer resp.Body.Close()
+	result, err := ioutil.ReadAll(resp.Body)
+	require.NoError(err)
+	require.Equal(string(blob.Content[5:]), string(result))
+	require.Equal("bytes 5-15/16", resp.Header.Get("Content-Range"))
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header  string
+		start   int64
+		length  int64
+		partial bool
+		err     bool
+	}{
+		{"", 0, 10, false, false},
+		{"bytes=0-4", 0, 5, true, false},
+		{"bytes=3-", 3, 7, true, false},
+		{"bytes=-4", 6, 4, true, false},
+		{"bytes=5-20", 5, 5, true, false},
+		{"bytes=0-1,3-4", 0, 10, false, false},
+		{"bytes=10-", 0, 0, false, true},
+		{"bytes=4-2", 0, 0, false, true},
+		{"items=0-4", 0, 0, false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			require := require.New(t)
+
+			start, length, partial, err := parseRange(test.header, 10)
+			if test.err {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+			require.Equal(test.start, start)
+			require.Equal(test.length, length)
+			require.Equal(test.partial, partial)
+		})
+	}
+}
+
 func TestDownloadNotFound(t *testing.T) {
 	require := require.New(t)
 
@@ -165,6 +378,70 @@ func TestDownloadUnknownError(t *testing.T) {
 	require.True(httputil.IsStatus(err, 500))
 }
 
+func TestDownloadInsufficientStorage(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	namespace := core.TagFixture()
+	blob := core.NewBlobFixture()
+
+	mocks.sched.EXPECT().Download(namespace, blob.Digest).Return(
+		store.InsufficientStorageError{Dir: "/tmp", Size: 10, Free: 1})
+
+	addr := mocks.startServer()
+	c := agentclient.New(addr)
+
+	_, err := c.Download(namespace, blob.Digest)
+	require.Error(err)
+	require.True(httputil.IsStatus(err, http.StatusInsufficientStorage))
+}
+
+func TestDownloadLimitExceeded(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	namespace := core.TagFixture()
+	blob1 := core.NewBlobFixture()
+	blob2 := core.NewBlobFixture()
+
+	release := make(chan struct{})
+	mocks.sched.EXPECT().Download(namespace, blob1.Digest).DoAndReturn(
+		func(namespace string, d core.Digest) error {
+			<-release
+			return store.RunDownload(mocks.cads, d, blob1.Content)
+		})
+
+	limiter := syncutil.NewLimiter(syncutil.LimiterConfig{MaxConcurrent: 1})
+	s := New(
+		Config{}, tally.NoopScope, mocks.cads, mocks.sched, mocks.tags, zap.NewNop(),
+		WithDownloadLimiter(limiter))
+	addr, stop := testutil.StartServer(s.Handler())
+	defer stop()
+
+	c := agentclient.New(addr)
+
+	errc := make(chan error)
+	go func() {
+		_, err := c.Download(namespace, blob1.Digest)
+		errc <- err
+	}()
+	require.NoError(testutil.PollUntilTrue(5*time.Second, func() bool {
+		return limiter.InFlight() == 1
+	}))
+
+	_, err := c.Download(namespace, blob2.Digest)
+	require.Error(err)
+	require.True(httputil.IsStatus(err, http.StatusTooManyRequests))
+
+	close(release)
+	require.NoError(<-errc)
+	require.Equal(0, limiter.InFlight())
+}
+
 func TestHealthHandler(t *testing.T) {
 	tests := []struct {
 		desc     string
@@ -194,6 +471,103 @@ func TestHealthHandler(t *testing.T) {
 	}
 }
 
+func TestMetricsHandler(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/metrics", addr))
+	require.NoError(err)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(err)
+	require.Contains(string(b), "go_goroutines")
+}
+
+func TestReadinessCheckHandler(t *testing.T) {
+	tests := []struct {
+		desc         string
+		probeErr     error
+		readinessErr error
+		expected     int
+	}{
+		{"probe error", errors.New("some probe error"), nil, http.StatusServiceUnavailable},
+		{"tracker unavailable", nil, errors.New("some tracker error"), http.StatusServiceUnavailable},
+		{"ready", nil, nil, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			mocks, cleanup := newServerMocks(t)
+			defer cleanup()
+
+			mocks.sched.EXPECT().Probe().Return(test.probeErr)
+			if test.probeErr == nil {
+				mocks.sched.EXPECT().CheckReadiness().Return(test.readinessErr)
+			}
+
+			addr := mocks.startServer()
+
+			_, err := httputil.Get(fmt.Sprintf("http://%s/readiness", addr))
+			if test.expected == http.StatusOK {
+				require.NoError(err)
+			} else {
+				require.True(httputil.IsStatus(err, test.expected))
+			}
+		})
+	}
+}
+
+func TestReadinessCheckHandlerRunsAdditionalChecks(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	mocks.sched.EXPECT().Probe().Return(nil).Times(2)
+	mocks.sched.EXPECT().CheckReadiness().Return(nil).Times(2)
+
+	var ready int32
+	check := func() error {
+		if atomic.LoadInt32(&ready) == 0 {
+			return errors.New("processed 1 of 2 cached blobs")
+		}
+		return nil
+	}
+	s := New(
+		Config{}, tally.NoopScope, mocks.cads, mocks.sched, mocks.tags, zap.NewNop(),
+		WithReadinessCheck("warm cache", check))
+	addr, stop := testutil.StartServer(s.Handler())
+	defer stop()
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/readiness", addr))
+	require.True(httputil.IsStatus(err, http.StatusServiceUnavailable))
+
+	atomic.StoreInt32(&ready, 1)
+	_, err = httputil.Get(fmt.Sprintf("http://%s/readiness", addr))
+	require.NoError(err)
+}
+
+func TestHealthHandlerDoesNotCheckReadiness(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	// CheckReadiness is not expected, so gomock fails the test if /health
+	// depends on tracker availability.
+	mocks.sched.EXPECT().Probe().Return(nil)
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/health", addr))
+	require.NoError(err)
+}
+
 func TestPatchSchedulerConfigHandler(t *testing.T) {
 	require := require.New(t)
 
@@ -212,7 +586,8 @@ func TestPatchSchedulerConfigHandler(t *testing.T) {
 
 	_, err = httputil.Patch(
 		fmt.Sprintf("http://%s/x/config/scheduler", addr),
-		httputil.SendBody(bytes.NewReader(b)))
+		httputil.SendBody(bytes.NewReader(b)),
+		sendAdminToken())
 	require.NoError(err)
 }
 
@@ -239,18 +614,314 @@ func TestGetBlacklistHandler(t *testing.T) {
 	require.Equal(blacklist, result)
 }
 
+func TestGetBannedPeersHandler(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	banned := []reputation.BannedPeer{{
+		PeerID:    core.PeerIDFixture(),
+		InfoHash:  core.InfoHashFixture(),
+		Remaining: time.Minute,
+	}}
+	mocks.sched.EXPECT().BannedPeers().Return(banned, nil)
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/x/banned_peers", addr))
+	require.NoError(err)
+
+	var result []reputation.BannedPeer
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(banned, result)
+}
+
+func TestListTorrentsHandler(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	stats := []scheduler.TorrentStats{{
+		InfoHash:       core.InfoHashFixture(),
+		Digest:         core.DigestFixture(),
+		Namespace:      core.TagFixture(),
+		PiecesComplete: 1,
+		PiecesTotal:    2,
+		Peers: []scheduler.PeerStats{{
+			PeerID:         core.PeerIDFixture(),
+			IP:             "10.0.0.1",
+			PiecesReceived: 1,
+			BytesReceived:  64,
+		}},
+	}}
+	mocks.sched.EXPECT().TorrentStats().Return(stats, nil)
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/torrents", addr))
+	require.NoError(err)
+
+	var result []scheduler.TorrentStats
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(stats, result)
+}
+
+func TestGetTorrentHandler(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	h := core.InfoHashFixture()
+	stats := scheduler.TorrentStats{
+		InfoHash:       h,
+		Digest:         core.DigestFixture(),
+		PiecesComplete: 2,
+		PiecesTotal:    2,
+		Complete:       true,
+		Peers:          []scheduler.PeerStats{},
+	}
+	mocks.sched.EXPECT().TorrentStatsByInfoHash(h).Return(stats, nil)
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/torrents/%s", addr, h.Hex()))
+	require.NoError(err)
+
+	var result scheduler.TorrentStats
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(stats, result)
+}
+
+func TestGetTorrentHandlerNotFound(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	h := core.InfoHashFixture()
+	mocks.sched.EXPECT().TorrentStatsByInfoHash(h).Return(
+		scheduler.TorrentStats{}, scheduler.ErrTorrentNotFound)
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/torrents/%s", addr, h.Hex()))
+	require.Error(err)
+	require.True(httputil.IsNotFound(err))
+}
+
+func TestGetTorrentHandlerInvalidInfoHash(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/torrents/invalid", addr))
+	require.Error(err)
+	require.True(httputil.IsStatus(err, http.StatusBadRequest))
+}
+
+func TestGetBandwidthHandler(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	mocks.sched.EXPECT().BandwidthLimits().Return(uint64(800), uint64(1600))
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/x/bandwidth", addr))
+	require.NoError(err)
+
+	var result bandwidthLimits
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(bandwidthLimits{800, 1600}, result)
+}
+
+func TestPatchBandwidthHandler(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	gomock.InOrder(
+		mocks.sched.EXPECT().BandwidthLimits().Return(uint64(800), uint64(1600)),
+		mocks.sched.EXPECT().SetBandwidthLimits(uint64(400), uint64(1600)).Return(nil),
+	)
+
+	_, err := httputil.Patch(
+		fmt.Sprintf("http://%s/x/bandwidth", addr),
+		httputil.SendBody(bytes.NewBufferString(`{"egress_bits_per_sec": 400}`)),
+		sendAdminToken())
+	require.NoError(err)
+}
+
+func TestPatchBandwidthHandlerInvalidLimits(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	gomock.InOrder(
+		mocks.sched.EXPECT().BandwidthLimits().Return(uint64(800), uint64(1600)),
+		mocks.sched.EXPECT().SetBandwidthLimits(uint64(1), uint64(1)).Return(errors.New("some error")),
+	)
+
+	_, err := httputil.Patch(
+		fmt.Sprintf("http://%s/x/bandwidth", addr),
+		httputil.SendBody(bytes.NewBufferString(
+			`{"egress_bits_per_sec": 1, "ingress_bits_per_sec": 1}`)),
+		sendAdminToken())
+	require.Error(err)
+	require.True(httputil.IsStatus(err, http.StatusBadRequest))
+}
+
 func TestDeleteBlobHandler(t *testing.T) {
 	require := require.New(t)
 
 	mocks, cleanup := newServerMocks(t)
 	defer cleanup()
 
-	d := core.DigestFixture()
+	blob := core.NewBlobFixture()
+
+	require.NoError(store.RunDownload(mocks.cads, blob.Digest, blob.Content))
+
+	addr := mocks.startServer()
+
+	mocks.sched.EXPECT().RemoveTorrent(blob.Digest).Return(nil)
+
+	_, err := httputil.Delete(fmt.Sprintf("http://%s/blobs/%s", addr, blob.Digest), sendAdminToken())
+	require.NoError(err)
+}
+
+func TestDeleteBlobHandlerNotFound(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Delete(
+		fmt.Sprintf("http://%s/blobs/%s", addr, core.DigestFixture()), sendAdminToken())
+	require.True(httputil.IsNotFound(err))
+}
+
+func TestDeleteBlobHandlerDownloadInProgress(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	blob := core.NewBlobFixture()
+
+	require.NoError(mocks.cads.CreateDownloadFile(blob.Digest.Hex(), int64(len(blob.Content))))
 
 	addr := mocks.startServer()
 
-	mocks.sched.EXPECT().RemoveTorrent(d).Return(nil)
+	_, err := httputil.Delete(fmt.Sprintf("http://%s/blobs/%s", addr, blob.Digest), sendAdminToken())
+	require.True(httputil.IsConflict(err))
+}
+
+func TestDeleteBlobHandlerAdminToken(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	blob := core.NewBlobFixture()
 
-	_, err := httputil.Delete(fmt.Sprintf("http://%s/blobs/%s", addr, d))
+	require.NoError(store.RunDownload(mocks.cads, blob.Digest, blob.Content))
+
+	addr := mocks.startServerWithConfig(Config{AdminToken: "secret"})
+	url := fmt.Sprintf("http://%s/blobs/%s", addr, blob.Digest)
+
+	_, err := httputil.Delete(url)
+	require.True(httputil.IsStatus(err, http.StatusUnauthorized))
+
+	_, err = httputil.Delete(url, httputil.SendHeaders(map[string]string{
+		"Authorization": "Bearer wrong",
+	}))
+	require.True(httputil.IsStatus(err, http.StatusUnauthorized))
+
+	mocks.sched.EXPECT().RemoveTorrent(blob.Digest).Return(nil)
+
+	_, err = httputil.Delete(url, httputil.SendHeaders(map[string]string{
+		"Authorization": "Bearer secret",
+	}))
 	require.NoError(err)
 }
+
+func TestDeleteBlobHandlerWithoutAdminCredentials(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	blob := core.NewBlobFixture()
+
+	require.NoError(store.RunDownload(mocks.cads, blob.Digest, blob.Content))
+
+	// Evictions are unprotected until admin credentials are configured.
+	addr := mocks.startServerWithConfig(Config{})
+
+	mocks.sched.EXPECT().RemoveTorrent(blob.Digest).Return(nil)
+
+	_, err := httputil.Delete(fmt.Sprintf("http://%s/blobs/%s", addr, blob.Digest))
+	require.NoError(err)
+}
+
+func TestStatBlobHandler(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	blob := core.NewBlobFixture()
+
+	require.NoError(store.RunDownload(mocks.cads, blob.Digest, blob.Content))
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Head(fmt.Sprintf("http://%s/blobs/%s", addr, blob.Digest))
+	require.NoError(err)
+	require.Equal(http.StatusOK, resp.StatusCode)
+	require.Equal(int64(len(blob.Content)), resp.ContentLength)
+}
+
+func TestStatBlobHandlerDownloadInProgress(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	blob := core.NewBlobFixture()
+
+	require.NoError(mocks.cads.CreateDownloadFile(blob.Digest.Hex(), int64(len(blob.Content))))
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Head(fmt.Sprintf("http://%s/blobs/%s", addr, blob.Digest))
+	require.True(httputil.IsAccepted(err))
+}
+
+func TestStatBlobHandlerNotFound(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Head(fmt.Sprintf("http://%s/blobs/%s", addr, core.DigestFixture()))
+	require.True(httputil.IsNotFound(err))
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/lib/torrent/storage/agentstorage"
+	"github.com/uber/kraken/utils/handler"
+	"github.com/uber/kraken/utils/syncutil"
+)
+
+const _streamBufferSize = 32 * 1024
+
+var errInvalidRange = errors.New("invalid range")
+
+// streamBlob downloads d and streams its content to w as pieces complete.
+func (s *Server) streamBlob(
+	w http.ResponseWriter, r *http.Request, namespace string, d core.Digest) error {
+
+	downloaded := make(chan error, 1)
+	go func() {
+		downloaded <- s.download(namespace, d)
+	}()
+
+	mi, err := agentstorage.WaitForMetaInfo(
+		s.cads, d, s.config.StreamPollInterval, downloaded)
+	if err != nil {
+		return downloadError(err)
+	}
+	if mi == nil {
+		// Download finished before streaming started.
+		f, err := s.cads.Cache().GetFileReader(d.Hex())
+		if err != nil {
+			return handler.Errorf("store: %s", err)
+		}
+		defer f.Close()
+		http.ServeContent(w, r, "", time.Time{}, f)
+		return nil
+	}
+
+	start, length, partial, err := parseRange(r.Header.Get("Range"), mi.Length())
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", mi.Length()))
+		return handler.Errorf("%s", err).Status(http.StatusRequestedRangeNotSatisfiable)
+	}
+
+	sr := agentstorage.NewStreamReader(
+		s.cads, mi, start, length, s.config.StreamPollInterval, downloaded)
+	defer sr.Close()
+
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, _streamBufferSize)
+	var wroteHeader bool
+	for {
+		n, err := sr.Read(buf)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			if !wroteHeader {
+				return downloadError(err)
+			}
+			return fmt.Errorf("stream: %s", err)
+		}
+		if !wroteHeader {
+			w.Header().Set("Accept-Ranges", "bytes")
+			if partial {
+				w.Header().Set(
+					"Content-Range",
+					fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, mi.Length()))
+				w.WriteHeader(http.StatusPartialContent)
+			}
+			wroteHeader = true
+		}
+		if _, err := w.Write(buf[:n]); err != nil {
+			return fmt.Errorf("write: %s", err)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+func downloadError(err error) error {
+	if err == scheduler.ErrTorrentNotFound {
+		return handler.ErrorStatus(http.StatusNotFound)
+	}
+	if err == scheduler.ErrMaintenance {
+		return handler.Errorf("download torrent: %s", err).Status(http.StatusServiceUnavailable)
+	}
+	if err == syncutil.ErrQueueFull || err == syncutil.ErrQueueTimeout {
+		return handler.Errorf("download torrent: %s", err).Status(http.StatusTooManyRequests)
+	}
+	if store.IsInsufficientStorage(err) {
+		return handler.Errorf("download torrent: %s", err).Status(http.StatusInsufficientStorage)
+	}
+	return handler.Errorf("download torrent: %s", err)
+}
+
+// parseRange parses a single "bytes=" Range header against a blob of size.
+// Returns the start and length of the range, and whether the header requested
+// a partial range. Multiple ranges are not supported and are treated as a
+// request for the full blob.
+func parseRange(header string, size int64) (start, length int64, partial bool, err error) {
+	if header == "" || strings.Contains(header, ",") {
+		return 0, size, false, nil
+	}
+	if !strings.HasPrefix(header, "bytes=") {
+		return 0, 0, false, errInvalidRange
+	}
+	parts := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false, errInvalidRange
+	}
+	first, last := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	if first == "" {
+		// Suffix range of the last n bytes.
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false, errInvalidRange
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, n, true, nil
+	}
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false, errInvalidRange
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, errInvalidRange
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end - start + 1, true, nil
+}
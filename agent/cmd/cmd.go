@@ -14,38 +14,59 @@
 package cmd
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/uber/kraken/agent/agentserver"
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/dockerregistry/transfer"
+	"github.com/uber/kraken/lib/hashring"
+	"github.com/uber/kraken/lib/healthcheck"
+	"github.com/uber/kraken/lib/secrets"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/lib/torrent/networkevent"
 	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/lib/tracing"
 	"github.com/uber/kraken/metrics"
 	"github.com/uber/kraken/nginx"
+	"github.com/uber/kraken/origin/blobclient"
 	"github.com/uber/kraken/utils/configutil"
+	"github.com/uber/kraken/utils/httputil"
 	"github.com/uber/kraken/utils/log"
 	"github.com/uber/kraken/utils/netutil"
+	"github.com/uber/kraken/utils/syncutil"
 
+	"github.com/andres-erbsen/clock"
 	"github.com/uber-go/tally"
 )
 
 // Flags defines agent CLI flags.
 type Flags struct {
 	PeerIP            string
+	AnnounceIP        string
 	PeerPort          int
+	AgentServerIP     string
 	AgentServerPort   int
+	AgentRegistryIP   string
 	AgentRegistryPort int
 	ConfigFile        string
 	Zone              string
 	KrakenCluster     string
 	SecretsFile       string
+	Env               bool
+	Simulate          string
+	DumpConfig        bool
+	Fsck              bool
+	TransferStore     string
 }
 
 // ParseFlags parses agent CLI flags.
@@ -53,10 +74,20 @@ func ParseFlags() *Flags {
 	var flags Flags
 	flag.StringVar(
 		&flags.PeerIP, "peer-ip", "", "ip which peer will announce itself as")
+	flag.StringVar(
+		&flags.AnnounceIP, "announce-ip", "",
+		"ip which peer will announce itself as, if different from peer-ip; "+
+			"when set, peer only listens on peer-ip")
 	flag.IntVar(
 		&flags.PeerPort, "peer-port", 0, "port which peer will announce itself as")
+	flag.StringVar(
+		&flags.AgentServerIP, "agent-server-ip", "",
+		"ip which agent server listens on, e.g. 127.0.0.1; defaults to all interfaces")
 	flag.IntVar(
 		&flags.AgentServerPort, "agent-server-port", 0, "port which agent server listens on")
+	flag.StringVar(
+		&flags.AgentRegistryIP, "agent-registry-ip", "",
+		"ip which agent registry listens on; defaults to all interfaces")
 	flag.IntVar(
 		&flags.AgentRegistryPort, "agent-registry-port", 0, "port which agent registry listens on")
 	flag.StringVar(
@@ -67,29 +98,109 @@ func ParseFlags() *Flags {
 		&flags.KrakenCluster, "cluster", "", "cluster name (e.g. prod01-zone1)")
 	flag.StringVar(
 		&flags.SecretsFile, "secrets", "", "path to a secrets YAML file to load into configuration")
+	flag.BoolVar(
+		&flags.Env, "env", false, "overlay configuration with "+configutil.EnvPrefix+"* environment variables")
+	flag.StringVar(
+		&flags.Simulate, "simulate", "",
+		"path to a workload file of images (repo:tag, one per line) to pull and discard, "+
+			"after which the agent exits without serving traffic")
+	flag.BoolVar(
+		&flags.DumpConfig, "dump-config", false,
+		"print the effective configuration as YAML, with secrets redacted, and exit")
+	flag.BoolVar(
+		&flags.Fsck, "fsck", false,
+		"verify the local store, remove corrupt and orphaned files, and exit; "+
+			"the agent must not be running")
+	flag.StringVar(
+		&flags.TransferStore, "transfer-store", "",
+		"root directory of a store to copy cached blobs and their metadata into, and exit; "+
+			"resumes an interrupted transfer; the agent must not be running")
 	flag.Parse()
 	return &flags
 }
 
-// Run runs the agent.
-func Run(flags *Flags) {
-	if flags.PeerPort == 0 {
-		panic("must specify non-zero peer port")
+// Validate returns an error describing the first invalid flag, if any. Ports
+// are required, must be within 1-65535, and must be distinct from each other.
+// The peer port may be omitted, in which case it is selected from the
+// scheduler's peer_port_range on startup. When simulating, no server ports are
+// required since no servers are started. No ports are required when dumping
+// config, checking the store or transferring the store. Listen ips, if set, must be ip addresses.
+func (f *Flags) Validate() error {
+	if f.DumpConfig || f.Fsck || f.TransferStore != "" {
+		return nil
 	}
-	if flags.AgentServerPort == 0 {
-		panic("must specify non-zero agent server port")
+	for _, ip := range []struct {
+		name string
+		ip   string
+	}{
+		{"agent-server-ip", f.AgentServerIP},
+		{"agent-registry-ip", f.AgentRegistryIP},
+	} {
+		if ip.ip != "" && net.ParseIP(ip.ip) == nil {
+			return fmt.Errorf("%s must be an ip address, got %q", ip.name, ip.ip)
+		}
 	}
-	if flags.AgentRegistryPort == 0 {
-		panic("must specify non-zero agent registry port")
+	type namedPort struct {
+		name     string
+		port     int
+		optional bool
 	}
-	var config Config
-	if err := configutil.Load(flags.ConfigFile, &config); err != nil {
-		panic(err)
+	ports := []namedPort{{"peer-port", f.PeerPort, true}}
+	if f.Simulate == "" {
+		ports = append(ports,
+			namedPort{"agent-server-port", f.AgentServerPort, false},
+			namedPort{"agent-registry-port", f.AgentRegistryPort, false})
 	}
-	if flags.SecretsFile != "" {
-		if err := configutil.Load(flags.SecretsFile, &config); err != nil {
-			panic(err)
+	seen := make(map[int]string)
+	for _, p := range ports {
+		if p.port == 0 {
+			if p.optional {
+				continue
+			}
+			return fmt.Errorf("%s is required", p.name)
+		}
+		if p.port < 1 || p.port > 65535 {
+			return fmt.Errorf("%s must be within 1-65535, got %d", p.name, p.port)
 		}
+		if other, ok := seen[p.port]; ok {
+			return fmt.Errorf("%s and %s must be distinct, both are %d", other, p.name, p.port)
+		}
+		seen[p.port] = p.name
+	}
+	return nil
+}
+
+// Run runs the agent. Flags are assumed to have been validated via
+// Flags.Validate.
+func Run(flags *Flags) {
+	if flags.DumpConfig {
+		if err := dumpConfig(os.Stdout, flags); err != nil {
+			fmt.Fprintf(os.Stderr, "Error dumping config: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	config, err := loadConfig(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %s\n", err)
+		os.Exit(1)
+	}
+
+	if flags.Fsck {
+		if err := fsck(os.Stdout, config.CADownloadStore); err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking store: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flags.TransferStore != "" {
+		if err := transferStore(os.Stdout, config.CADownloadStore, flags.TransferStore); err != nil {
+			fmt.Fprintf(os.Stderr, "Error transferring store: %s\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	zlog := log.ConfigureLogger(config.ZapLogging)
@@ -99,10 +210,21 @@ func Run(flags *Flags) {
 	if err != nil {
 		log.Fatalf("Failed to init metrics: %s", err)
 	}
-	defer closer.Close()
 
 	go metrics.EmitVersion(stats)
 
+	lc := newLifecycle(stats, clock.New())
+
+	shutdownTracing, err := tracing.Init(config.Tracing, "kraken-agent")
+	if err != nil {
+		log.Fatalf("Failed to init tracing: %s", err)
+	}
+	defer shutdownTracing()
+
+	if err := httputil.SetProxy(config.Proxy); err != nil {
+		log.Fatalf("Failed to set proxy: %s", err)
+	}
+
 	if flags.PeerIP == "" {
 		localIP, err := netutil.GetLocalIP()
 		if err != nil {
@@ -111,8 +233,32 @@ func Run(flags *Flags) {
 		flags.PeerIP = localIP
 	}
 
+	// By default the peer announces and listens on all interfaces. If an
+	// explicit announce ip is given, e.g. on multi-homed hosts, the peer only
+	// listens on peer-ip and announces the other address.
+	announceIP, bindIP := flags.PeerIP, ""
+	if flags.AnnounceIP != "" {
+		announceIP, bindIP = flags.AnnounceIP, flags.PeerIP
+	}
+
+	peerPort := flags.PeerPort
+	if peerPort == 0 {
+		if !config.Scheduler.PeerPortRange.IsSet() {
+			log.Fatal("Either peer-port or scheduler.peer_port_range is required")
+		}
+		// The agent server and registry only listen once the scheduler has
+		// started, so their ports would otherwise appear free.
+		peerPort, err = scheduler.SelectPeerPort(
+			bindIP, config.Scheduler.PeerPortRange, flags.AgentServerPort, flags.AgentRegistryPort)
+		if err != nil {
+			log.Fatalf("Error selecting peer port: %s", err)
+		}
+	}
+	log.Infof("Peer listening on port %d", peerPort)
+	stats.Gauge("peer_port").Update(float64(peerPort))
+
 	pctx, err := core.NewPeerContext(
-		config.PeerIDFactory, flags.Zone, flags.KrakenCluster, flags.PeerIP, flags.PeerPort, false)
+		config.PeerIDFactory, flags.Zone, flags.KrakenCluster, announceIP, bindIP, peerPort, false)
 	if err != nil {
 		log.Fatalf("Failed to create peer context: %s", err)
 	}
@@ -127,50 +273,178 @@ func Run(flags *Flags) {
 		log.Fatalf("Failed to create network event producer: %s", err)
 	}
 
-	trackers, err := config.Tracker.Build()
-	if err != nil {
+	// Network events can be tailed live from the debug server.
+	var eventStream *networkevent.Stream
+	if config.DebugAddr != "" {
+		eventStream = networkevent.NewStream(netevents)
+		netevents = eventStream
+	}
+
+	var trackers hashring.PassiveRing
+	if err := config.StartupRetry.Retry("tracker", func() (err error) {
+		trackers, err = config.Tracker.Build()
+		return err
+	}); err != nil {
 		log.Fatalf("Error building tracker upstream: %s", err)
 	}
 	go trackers.Monitor(nil)
 
+	// Remote trackers are opportunistic, so they are skipped if unavailable.
+	var remoteTrackers []hashring.PassiveRing
+	for i, c := range config.RemoteTrackers {
+		ring, err := c.Build()
+		if err != nil {
+			log.Errorf("Error building remote tracker upstream %d, skipping: %s", i, err)
+			continue
+		}
+		go ring.Monitor(nil)
+		remoteTrackers = append(remoteTrackers, ring)
+	}
+
 	tls, err := config.TLS.BuildClient()
 	if err != nil {
 		log.Fatalf("Error building client tls config: %s", err)
 	}
 
 	sched, err := scheduler.NewAgentScheduler(
-		config.Scheduler, stats, pctx, cads, netevents, trackers, tls)
+		config.Scheduler, stats, pctx, cads, netevents, trackers, remoteTrackers, tls)
 	if err != nil {
 		log.Fatalf("Error creating scheduler: %s", err)
 	}
 
-	buildIndexes, err := config.BuildIndex.Build()
-	if err != nil {
+	go func() {
+		n, err := seedPinnedBlobs(cads, sched)
+		if err != nil {
+			log.Errorf("Error seeding pinned blobs: %s", err)
+			return
+		}
+		log.Infof("Seeding %d pinned blobs", n)
+	}()
+
+	var serverOpts []agentserver.Option
+	if config.WarmCache.Enabled {
+		warmer := newCacheWarmer(config.WarmCache, cads, sched)
+		serverOpts = append(serverOpts, agentserver.WithReadinessCheck("warm cache", warmer.CheckReadiness))
+		go func() {
+			n, err := warmer.run()
+			if err != nil {
+				log.Errorf("Error seeding cached blobs: %s", err)
+				return
+			}
+			log.Infof("Seeding %d cached blobs", n)
+		}()
+	}
+
+	var buildIndexes healthcheck.List
+	if err := config.StartupRetry.Retry("build-index", func() (err error) {
+		buildIndexes, err = config.BuildIndex.Build()
+		return err
+	}); err != nil {
 		log.Fatalf("Error building build-index upstream: %s", err)
 	}
 
-	tagClient := tagclient.NewClusterClient(buildIndexes, tls)
+	tagClient := tagclient.NewClusterClient(
+		buildIndexes, tls,
+		tagclient.WithCache(config.TagCache),
+		tagclient.WithCircuitBreaker(config.TagBreaker, stats),
+		tagclient.WithTimeout(config.TagTimeout))
 
-	transferer := transfer.NewReadOnlyTransferer(stats, cads, tagClient, sched)
+	accessLog, err := log.New(config.AccessLog, nil)
+	if err != nil {
+		log.Fatalf("Error creating access logger: %s", err)
+	}
+
+	// Downloads through the agent server and registry share a single limit.
+	downloadLimiter := syncutil.NewLimiter(config.DownloadLimit)
+	serverOpts = append(serverOpts, agentserver.WithDownloadLimiter(downloadLimiter))
+
+	if config.Push.Enabled {
+		pushManager, pushPins, err := newPushManager(config.Push, stats, tls, cads, tagClient)
+		if err != nil {
+			log.Fatalf("Error creating push manager: %s", err)
+		}
+		serverOpts = append(serverOpts, agentserver.WithPushManager(pushManager, pushPins))
+	}
+
+	transfererOpts := []transfer.ReadOnlyOption{transfer.WithDownloadLimiter(downloadLimiter)}
+	if addr := config.Transferer.OriginFallback.Addr; addr != "" {
+		transfererOpts = append(transfererOpts, transfer.WithOriginFallback(
+			blobclient.New(addr, blobclient.WithTLS(tls))))
+	}
+	if len(config.Transferer.OriginFallback.Origins) > 0 {
+		transfererOpts = append(transfererOpts, transfer.WithOriginFallbackProvider(
+			blobclient.NewProvider(blobclient.WithTLS(tls))))
+	}
+
+	transferer, err := transfer.NewReadOnlyTransferer(
+		config.Transferer, stats, cads, tagClient, sched, accessLog, transfererOpts...)
+	if err != nil {
+		log.Fatalf("Error creating transferer: %s", err)
+	}
+
+	if flags.Simulate != "" {
+		images, err := loadWorkload(flags.Simulate)
+		if err != nil {
+			log.Fatalf("Error loading simulation workload: %s", err)
+		}
+		log.Infof("Simulating pulls of %d images...", len(images))
+		newSimulator(stats, transferer, discardFromAgent(sched, cads)).run(images)
+		sched.Stop()
+		cads.Close()
+		netevents.Close()
+		closer.Close()
+		return
+	}
 
 	registry, err := config.Registry.Build(config.Registry.ReadOnlyParameters(transferer, cads, stats))
 	if err != nil {
 		log.Fatalf("Failed to init registry: %s", err)
 	}
 
-	agentServer := agentserver.New(config.AgentServer, stats, cads, sched, tagClient)
-	addr := fmt.Sprintf(":%d", flags.AgentServerPort)
+	agentServer := agentserver.New(
+		config.AgentServer, stats, cads, sched, tagClient, accessLog, serverOpts...)
+	addr := net.JoinHostPort(flags.AgentServerIP, strconv.Itoa(flags.AgentServerPort))
+	httpServer := &http.Server{Addr: addr, Handler: agentServer.Handler()}
+	agentServerScheme := "http"
+	if config.AgentServerTLS {
+		serverTLS, err := config.TLS.BuildServer()
+		if err != nil {
+			log.Fatalf("Error building agent server tls config: %s", err)
+		}
+		httpServer.TLSConfig = serverTLS
+		agentServerScheme = "https"
+	}
 	log.Infof("Starting agent server on %s", addr)
 	go func() {
-		log.Fatal(http.ListenAndServe(addr, agentServer.Handler()))
+		var err error
+		if config.AgentServerTLS {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
 	}()
 
+	if config.DebugAddr != "" {
+		// Unlike the agent server, the debug server is not required for the
+		// agent to function, so its failure is not fatal.
+		log.Infof("Starting debug server on %s", config.DebugAddr)
+		go func() {
+			err := http.ListenAndServe(config.DebugAddr, agentserver.DebugHandler(eventStream))
+			log.Errorf("Debug server exited: %s", err)
+		}()
+	}
+
 	log.Info("Starting registry...")
 	go func() {
-		log.Fatal(registry.ListenAndServe())
+		if err := registry.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
 	}()
 
-	go heartbeat(stats)
+	go heartbeat(stats, config.HeartbeatInterval, sched, downloadLimiter)
 
 	// Wipe log files created by the old nginx process which ran as root.
 	// TODO(codyg): Swap these with the v2 log files once they are deleted.
@@ -183,20 +457,119 @@ func Run(flags *Flags) {
 		}
 	}
 
-	log.Fatal(nginx.Run(config.Nginx, map[string]interface{}{
-		"allowed_cidrs": config.AllowedCidrs,
-		"port": flags.AgentRegistryPort,
-		"registry_server": nginx.GetServer(
-			config.Registry.Docker.HTTP.Net, config.Registry.Docker.HTTP.Addr),
-		"registry_backup": config.RegistryBackup},
-		nginx.WithTLS(config.TLS)))
+	// Reload a safe subset of config on SIGHUP without interrupting active
+	// downloads.
+	hupc := make(chan os.Signal, 1)
+	signal.Notify(hupc, syscall.SIGHUP)
+	go newReloader(func() (Config, error) { return loadConfig(flags) }, config, sched).run(hupc)
+
+	go lc.waitReady(agentServer.CheckReadiness, time.Second)
+
+	shutdown := newAgentShutdown(
+		config.DrainTimeout, httpServer, registry, sched, cads, netevents, closer, lc)
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGTERM, syscall.SIGINT)
+
+	// Nginx proxies listing requests to the agent server over loopback, unless
+	// the agent server only listens on another ip.
+	agentServerIP := flags.AgentServerIP
+	if agentServerIP == "" {
+		agentServerIP = "127.0.0.1"
+	}
+	// Nginx accepts either a port or an address to listen on.
+	registryAddr := strconv.Itoa(flags.AgentRegistryPort)
+	if flags.AgentRegistryIP != "" {
+		registryAddr = net.JoinHostPort(flags.AgentRegistryIP, registryAddr)
+	}
+
+	// Nginx may receive the same termination signal as the agent, so its exit
+	// is only fatal if it happens before shutdown begins.
+	nginxErrc := make(chan error, 1)
+	go func() {
+		err := nginx.Run(config.Nginx, map[string]interface{}{
+			"allowed_cidrs": config.AllowedCidrs,
+			"port":          registryAddr,
+			"registry_server": nginx.GetServer(
+				config.Registry.Docker.HTTP.Net, config.Registry.Docker.HTTP.Addr),
+			"registry_backup": config.RegistryBackup,
+			"agent_server": net.JoinHostPort(
+				agentServerIP, strconv.Itoa(flags.AgentServerPort)),
+			"agent_server_scheme": agentServerScheme},
+			nginx.WithTLS(config.TLS))
+		if err == nil {
+			err = errors.New("exited without error")
+		}
+		nginxErrc <- err
+	}()
+
+	if err := shutdown.wait(sigc, nginxErrc); err != nil {
+		log.Fatalf("Nginx failed: %s", err)
+	}
+}
+
+// loadConfig loads agent configuration from the config file, followed by the
+// configured secrets provider and the secrets file.
+func loadConfig(flags *Flags) (Config, error) {
+	config, err := loadBaseConfig(flags)
+	if err != nil {
+		return Config{}, err
+	}
+	providers := []secrets.Config{config.Secrets}
+	if flags.SecretsFile != "" {
+		providers = append(providers, secrets.Config{
+			Provider: secrets.FileProvider,
+			File:     secrets.FileConfig{Path: flags.SecretsFile},
+		})
+	}
+	for _, c := range providers {
+		p, err := c.Build()
+		if err != nil {
+			return Config{}, err
+		}
+		if p == nil {
+			continue
+		}
+		if err := p.Load(&config); err != nil {
+			return Config{}, fmt.Errorf("load %s secrets: %s", c.Provider, err)
+		}
+	}
+	return config.applyDefaults(), nil
+}
+
+// loadBaseConfig loads agent configuration from the config file, without
+// secrets or defaults.
+func loadBaseConfig(flags *Flags) (Config, error) {
+	// Environment variables take precedence over the config file, but not
+	// over secrets.
+	load := configutil.Load
+	if flags.Env {
+		load = configutil.LoadWithEnv
+	}
+	var config Config
+	if err := load(flags.ConfigFile, &config); err != nil {
+		return Config{}, err
+	}
+	return config, nil
 }
 
 // heartbeat periodically emits a counter metric which allows us to monitor the
-// number of active agents.
-func heartbeat(stats tally.Scope) {
+// number of active agents, along with gauges of active torrents and in-flight
+// downloads to monitor agent load.
+func heartbeat(
+	stats tally.Scope,
+	interval time.Duration,
+	sched scheduler.Scheduler,
+	downloads *syncutil.Limiter) {
+
 	for {
 		stats.Counter("heartbeat").Inc(1)
-		time.Sleep(10 * time.Second)
+		stats.Gauge("inflight_downloads").Update(float64(downloads.InFlight()))
+		stats.Gauge("queued_downloads").Update(float64(downloads.Queued()))
+		if n, err := sched.NumActiveTorrents(); err != nil {
+			log.Warnf("Error getting number of active torrents: %s", err)
+		} else {
+			stats.Gauge("active_torrents").Update(float64(n))
+		}
+		time.Sleep(interval)
 	}
 }
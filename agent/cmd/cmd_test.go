@@ -0,0 +1,105 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlagsValidate(t *testing.T) {
+	tests := []struct {
+		desc  string
+		flags Flags
+		err   string
+	}{
+		{
+			"valid",
+			Flags{PeerPort: 8001, AgentServerPort: 8002, AgentRegistryPort: 8003},
+			"",
+		}, {
+			"missing peer port selected from range",
+			Flags{AgentServerPort: 8002, AgentRegistryPort: 8003},
+			"",
+		}, {
+			"missing agent server port",
+			Flags{PeerPort: 8001, AgentRegistryPort: 8003},
+			"agent-server-port is required",
+		}, {
+			"missing agent registry port",
+			Flags{PeerPort: 8001, AgentServerPort: 8002},
+			"agent-registry-port is required",
+		}, {
+			"negative port",
+			Flags{PeerPort: -1, AgentServerPort: 8002, AgentRegistryPort: 8003},
+			"peer-port must be within 1-65535, got -1",
+		}, {
+			"port too large",
+			Flags{PeerPort: 8001, AgentServerPort: 65536, AgentRegistryPort: 8003},
+			"agent-server-port must be within 1-65535, got 65536",
+		}, {
+			"duplicate ports",
+			Flags{PeerPort: 8001, AgentServerPort: 8002, AgentRegistryPort: 8001},
+			"peer-port and agent-registry-port must be distinct, both are 8001",
+		}, {
+			"simulate requires no server ports",
+			Flags{PeerPort: 8001, Simulate: "workload.txt"},
+			"",
+		}, {
+			"simulate missing peer port selected from range",
+			Flags{Simulate: "workload.txt"},
+			"",
+		}, {
+			"listen ips",
+			Flags{
+				AgentServerIP:     "127.0.0.1",
+				AgentServerPort:   8002,
+				AgentRegistryIP:   "::1",
+				AgentRegistryPort: 8003,
+			},
+			"",
+		}, {
+			"invalid agent server ip",
+			Flags{AgentServerIP: "localhost", AgentServerPort: 8002, AgentRegistryPort: 8003},
+			`agent-server-ip must be an ip address, got "localhost"`,
+		}, {
+			"invalid agent registry ip",
+			Flags{AgentServerPort: 8002, AgentRegistryIP: "10.0.0", AgentRegistryPort: 8003},
+			`agent-registry-ip must be an ip address, got "10.0.0"`,
+		}, {
+			"dump config requires no ports",
+			Flags{DumpConfig: true},
+			"",
+		}, {
+			"fsck requires no ports",
+			Flags{Fsck: true},
+			"",
+		}, {
+			"transfer store requires no ports",
+			Flags{TransferStore: "/var/cache/kraken-new"},
+			"",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := test.flags.Validate()
+			if test.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, test.err)
+			}
+		})
+	}
+}
@@ -14,16 +14,24 @@
 package cmd
 
 import (
+	"time"
+
 	"github.com/uber/kraken/agent/agentserver"
+	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/dockerregistry"
+	"github.com/uber/kraken/lib/dockerregistry/transfer"
+	"github.com/uber/kraken/lib/secrets"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/lib/torrent/networkevent"
 	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/lib/tracing"
 	"github.com/uber/kraken/lib/upstream"
 	"github.com/uber/kraken/metrics"
 	"github.com/uber/kraken/nginx"
 	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/log"
+	"github.com/uber/kraken/utils/syncutil"
 
 	"go.uber.org/zap"
 )
@@ -34,14 +42,87 @@ type Config struct {
 	Metrics         metrics.Config                 `yaml:"metrics"`
 	CADownloadStore store.CADownloadStoreConfig    `yaml:"store"`
 	Registry        dockerregistry.Config          `yaml:"registry"`
+	Transferer      transfer.ReadOnlyConfig        `yaml:"transferer"`
 	Scheduler       scheduler.Config               `yaml:"scheduler"`
 	PeerIDFactory   core.PeerIDFactory             `yaml:"peer_id_factory"`
 	NetworkEvent    networkevent.Config            `yaml:"network_event"`
 	Tracker         upstream.PassiveHashRingConfig `yaml:"tracker"`
 	BuildIndex      upstream.PassiveConfig         `yaml:"build_index"`
+	TagCache        tagclient.CacheConfig          `yaml:"tag_cache"`
+	TagBreaker      tagclient.CircuitBreakerConfig `yaml:"tag_breaker"`
+	StartupRetry    upstream.StartupRetryConfig    `yaml:"startup_retry"`
 	AgentServer     agentserver.Config             `yaml:"agentserver"`
 	RegistryBackup  string                         `yaml:"registry_backup"`
 	Nginx           nginx.Config                   `yaml:"nginx"`
 	TLS             httputil.TLSConfig             `yaml:"tls"`
 	AllowedCidrs    []string                       `yaml:"allowed_cidrs"`
+	WarmCache       WarmCacheConfig                `yaml:"warm_cache"`
+
+	// AccessLog configures structured logs of agent server requests and
+	// registry transfers. Successful requests are logged at info level and
+	// failures at error level, so setting the level to error only logs
+	// failures.
+	AccessLog log.Config `yaml:"access_log"`
+
+	// Secrets configures a provider which secret values, e.g. backend
+	// credentials, are loaded from on startup. Loaded after the config file,
+	// and before the secrets file.
+	Secrets secrets.Config `yaml:"secrets"`
+
+	// DownloadLimit limits the number of distinct blobs downloaded
+	// concurrently by the agent server and registry. Excess downloads are
+	// queued, and rejected once the queue is full. Unlimited if not set.
+	DownloadLimit syncutil.LimiterConfig `yaml:"download_limit"`
+
+	// TagTimeout bounds the duration of build-index tag requests, including
+	// retries against other build-index hosts. Unbounded if not set.
+	TagTimeout time.Duration `yaml:"tag_timeout"`
+
+	// DrainTimeout is the grace period in-flight agent server and registry
+	// requests are given to complete on shutdown before remaining components
+	// are stopped.
+	DrainTimeout time.Duration `yaml:"drain_timeout"`
+
+	// HeartbeatInterval is the interval at which heartbeat metrics are emitted.
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+
+	// RemoteTrackers are tracker clusters of other Kraken clusters, e.g. in
+	// other regions, which the agent also announces to in order to share peers
+	// across clusters. Peers from Tracker are preferred.
+	RemoteTrackers []upstream.PassiveHashRingConfig `yaml:"remote_trackers"`
+
+	// DebugAddr is the address of a separate listener serving pprof profiles
+	// and goroutine dumps, e.g. localhost:6060. Should be bound to localhost
+	// or a private interface. Disabled if not set.
+	DebugAddr string `yaml:"debug_addr"`
+
+	// Tracing configures export of OpenTelemetry spans of pulls, from the
+	// registry and agent server through to build-index and the scheduler.
+	Tracing tracing.Config `yaml:"tracing"`
+
+	// Proxy configures an HTTP or SOCKS5 proxy which requests to trackers,
+	// build-index and origins are sent through. Defaults to HTTP_PROXY.
+	Proxy httputil.ProxyConfig `yaml:"proxy"`
+
+	// Push enables pushing images through the agent, which are replicated to
+	// the origins and build-index in the background.
+	Push PushConfig `yaml:"push"`
+
+	// AgentServerTLS serves the agent server over TLS using the tls server
+	// cert and key, such that admin requests may be authorized by client
+	// certificate. See agentserver.admin_client_names.
+	AgentServerTLS bool `yaml:"agent_server_tls"`
+}
+
+func (c Config) applyDefaults() Config {
+	if c.DrainTimeout == 0 {
+		c.DrainTimeout = 30 * time.Second
+	}
+	if c.HeartbeatInterval == 0 {
+		c.HeartbeatInterval = 10 * time.Second
+	}
+	if c.AccessLog.Encoding == "" {
+		c.AccessLog.Encoding = "json"
+	}
+	return c
 }
@@ -0,0 +1,205 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const _redacted = "<redacted>"
+
+// _sensitiveKey matches config keys whose values are always redacted, even
+// when set in the config file.
+var _sensitiveKey = regexp.MustCompile(`(?i)(password|secret|token|credential)`)
+
+// dumpConfig writes the effective config loaded from flags to w as YAML. Values
+// set by secrets providers, and values of sensitive keys, are redacted.
+func dumpConfig(w io.Writer, flags *Flags) error {
+	// Base and config are loaded separately, since loading secrets mutates
+	// maps and slices shared by shallow copies.
+	base, err := loadBaseConfig(flags)
+	if err != nil {
+		return fmt.Errorf("load base config: %s", err)
+	}
+	config, err := loadConfig(flags)
+	if err != nil {
+		return fmt.Errorf("load config: %s", err)
+	}
+	b, err := redactConfig(base.applyDefaults(), config)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// redactConfig marshals config to YAML, redacting values which differ from
+// base and values of sensitive keys.
+func redactConfig(base, config Config) ([]byte, error) {
+	tree := redact(toTree(reflect.ValueOf(config)), toTree(reflect.ValueOf(base)))
+	b, err := yaml.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("yaml marshal: %s", err)
+	}
+	return b, nil
+}
+
+// redact replaces scalars in v which differ from base, or which are set under
+// sensitive keys, with a placeholder.
+func redact(v, base interface{}) interface{} {
+	switch t := v.(type) {
+	case yaml.MapSlice:
+		bm, _ := base.(yaml.MapSlice)
+		for i, item := range t {
+			key := fmt.Sprint(item.Key)
+			if _, ok := item.Value.(yaml.MapSlice); !ok &&
+				item.Value != nil && item.Value != "" && _sensitiveKey.MatchString(key) {
+				t[i].Value = _redacted
+				continue
+			}
+			t[i].Value = redact(item.Value, lookup(bm, item.Key))
+		}
+		return t
+	case []interface{}:
+		bs, _ := base.([]interface{})
+		for i := range t {
+			var b interface{}
+			if i < len(bs) {
+				b = bs[i]
+			}
+			t[i] = redact(t[i], b)
+		}
+		return t
+	default:
+		if !reflect.DeepEqual(v, base) {
+			return _redacted
+		}
+		return v
+	}
+}
+
+func lookup(m yaml.MapSlice, key interface{}) interface{} {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// toTree converts v into a tree of YAML values, keyed by yaml struct tags.
+// Unlike yaml.Marshal, fields which cannot be marshalled, e.g. functions, are
+// omitted, and durations are formatted as strings.
+func toTree(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if v.Kind() == reflect.Struct && isZero(v) {
+			// Zero structs, e.g. zap.AtomicLevel, may not be marshallable.
+			return nil
+		}
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil
+		}
+		b, err := m.MarshalText()
+		if err != nil {
+			return nil
+		}
+		return string(b)
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return toTree(v.Elem())
+	case reflect.Struct:
+		var m yaml.MapSlice
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			tag := strings.Split(f.Tag.Get("yaml"), ",")
+			if tag[0] == "-" {
+				continue
+			}
+			child := toTree(v.Field(i))
+			if len(tag) > 1 && tag[1] == "inline" {
+				if inline, ok := child.(yaml.MapSlice); ok {
+					m = append(m, inline...)
+				}
+				continue
+			}
+			if !isMarshallable(f.Type) {
+				continue
+			}
+			name := tag[0]
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			m = append(m, yaml.MapItem{Key: name, Value: child})
+		}
+		return m
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(yaml.MapSlice, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			m = append(m, yaml.MapItem{Key: fmt.Sprint(k.Interface()), Value: toTree(v.MapIndex(k))})
+		}
+		sort.Slice(m, func(i, j int) bool { return m[i].Key.(string) < m[j].Key.(string) })
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = toTree(v.Index(i))
+		}
+		return s
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil
+	default:
+		return v.Interface()
+	}
+}
+
+// isZero returns whether v is the zero value of its type.
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+func isMarshallable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return false
+	}
+	return true
+}
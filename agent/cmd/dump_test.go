@@ -0,0 +1,72 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/uber/kraken/utils/testutil"
+)
+
+func TestDumpConfig(t *testing.T) {
+	require := require.New(t)
+
+	configFile, cleanup := testutil.TempFile([]byte(`
+registry_backup: localhost:5000
+drain_timeout: 1m
+agentserver:
+  admin_token: some-token
+`))
+	defer cleanup()
+
+	secretsFile, cleanup := testutil.TempFile([]byte(`
+registry_backup: user:password@localhost:5000
+`))
+	defer cleanup()
+
+	var out bytes.Buffer
+	require.NoError(dumpConfig(&out, &Flags{ConfigFile: configFile, SecretsFile: secretsFile}))
+
+	require.Contains(out.String(), "drain_timeout: 1m0s")
+	require.Contains(out.String(), "heartbeat_interval: 10s")
+	require.Contains(out.String(), "registry_backup: <redacted>")
+	require.Contains(out.String(), "admin_token: <redacted>")
+	require.NotContains(out.String(), "user:password")
+	require.NotContains(out.String(), "some-token")
+}
+
+func TestRedactConfig(t *testing.T) {
+	require := require.New(t)
+
+	var base Config
+	base.ZapLogging.Level = zap.NewAtomicLevelAt(zapcore.WarnLevel)
+	base.AllowedCidrs = []string{"10.0.0.0/8"}
+	base.Scheduler.ConnTTL = time.Minute
+
+	config := base
+	config.AllowedCidrs = []string{"10.0.0.0/8", "192.168.0.0/16"}
+
+	b, err := redactConfig(base, config)
+	require.NoError(err)
+
+	require.Contains(string(b), "level: warn")
+	require.Contains(string(b), "conn_ttl: 1m0s")
+	require.Contains(string(b), "allowed_cidrs:\n- 10.0.0.0/8\n- <redacted>\n")
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/uber/kraken/lib/store"
+
+	"github.com/uber-go/tally"
+)
+
+// fsck checks the integrity of the store configured by config and writes a
+// summary to w. Must not run while an agent is using the store.
+func fsck(w io.Writer, config store.CADownloadStoreConfig) error {
+	cads, err := store.NewCADownloadStore(config, tally.NoopScope)
+	if err != nil {
+		return fmt.Errorf("new store: %s", err)
+	}
+	defer cads.Close()
+
+	report, err := cads.Fsck()
+	for _, name := range report.Corrupt {
+		fmt.Fprintf(w, "Removed corrupt file %s\n", name)
+	}
+	for _, name := range report.Orphaned {
+		fmt.Fprintf(w, "Removed orphaned file %s\n", name)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Verified %d cached files, removed %d corrupt and %d orphaned files\n",
+		report.Verified, len(report.Corrupt), len(report.Orphaned))
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+)
+
+func TestFsck(t *testing.T) {
+	require := require.New(t)
+
+	config, cleanup := store.CADownloadStoreConfigFixture()
+	defer cleanup()
+
+	cads, err := store.NewCADownloadStore(config, tally.NoopScope)
+	require.NoError(err)
+
+	blob := core.NewBlobFixture()
+	require.NoError(store.RunDownload(cads, blob.Digest, blob.Content))
+
+	corrupt := core.DigestFixture()
+	require.NoError(store.RunDownload(cads, corrupt, []byte("some corrupt content")))
+	cads.Close()
+
+	var out bytes.Buffer
+	require.NoError(fsck(&out, config))
+	require.Equal(
+		"Removed corrupt file "+corrupt.Hex()+"\n"+
+			"Verified 1 cached files, removed 1 corrupt and 0 orphaned files\n",
+		out.String())
+}
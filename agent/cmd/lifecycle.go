@@ -0,0 +1,107 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"sync"
+	"time"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
+
+	"github.com/uber/kraken/utils/log"
+)
+
+// Agent lifecycle states, in the order they are entered.
+const (
+	_lifecycleStarting = "starting"
+	_lifecycleReady    = "ready"
+	_lifecycleDraining = "draining"
+	_lifecycleStopped  = "stopped"
+)
+
+// lifecycle records agent lifecycle transitions as metrics and structured log
+// lines, such that they can be correlated with deployments, e.g. alerting on
+// agents which start but never become ready.
+type lifecycle struct {
+	stats   tally.Scope
+	clk     clock.Clock
+	started time.Time
+
+	mu    sync.Mutex
+	state string
+}
+
+// newLifecycle creates a lifecycle and records the starting transition.
+func newLifecycle(stats tally.Scope, clk clock.Clock) *lifecycle {
+	l := &lifecycle{stats: stats, clk: clk, started: clk.Now()}
+	l.transition(_lifecycleStarting)
+	return l
+}
+
+// transition records entering state.
+func (l *lifecycle) transition(state string) {
+	l.mu.Lock()
+	l.state = state
+	l.mu.Unlock()
+	l.record(state)
+}
+
+// transitionFrom records entering state if the current state is from. Returns
+// whether state was entered.
+func (l *lifecycle) transitionFrom(from, state string) bool {
+	l.mu.Lock()
+	ok := l.state == from
+	if ok {
+		l.state = state
+	}
+	l.mu.Unlock()
+	if ok {
+		l.record(state)
+	}
+	return ok
+}
+
+func (l *lifecycle) record(state string) {
+	now := l.clk.Now()
+	l.stats.Tagged(map[string]string{"state": state}).Counter("lifecycle").Inc(1)
+	log.With(
+		"lifecycle", state,
+		"timestamp", now.UTC().Format(time.RFC3339Nano),
+		"uptime", now.Sub(l.started).String()).Infof("Agent %s", state)
+}
+
+func (l *lifecycle) current() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state
+}
+
+// waitReady polls check every interval until it passes, and then records the
+// ready transition and the startup duration. Exits without recording if the
+// agent starts draining first.
+func (l *lifecycle) waitReady(check func() error, interval time.Duration) {
+	ticker := l.clk.Ticker(interval)
+	defer ticker.Stop()
+	for l.current() == _lifecycleStarting {
+		if err := check(); err == nil {
+			// The agent may have started draining during the check.
+			if l.transitionFrom(_lifecycleStarting, _lifecycleReady) {
+				l.stats.Timer("startup_duration").Record(l.clk.Now().Sub(l.started))
+			}
+			return
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func lifecycleCount(stats tally.TestScope, state string) int64 {
+	var n int64
+	for _, c := range stats.Snapshot().Counters() {
+		if c.Name() == "lifecycle" && c.Tags()["state"] == state {
+			n += c.Value()
+		}
+	}
+	return n
+}
+
+func TestLifecycleTransitions(t *testing.T) {
+	require := require.New(t)
+
+	stats := tally.NewTestScope("", nil)
+
+	l := newLifecycle(stats, clock.New())
+	require.Equal(int64(1), lifecycleCount(stats, _lifecycleStarting))
+
+	l.transition(_lifecycleDraining)
+	l.transition(_lifecycleStopped)
+	require.Equal(int64(1), lifecycleCount(stats, _lifecycleDraining))
+	require.Equal(int64(1), lifecycleCount(stats, _lifecycleStopped))
+	require.Equal(int64(0), lifecycleCount(stats, _lifecycleReady))
+}
+
+func TestLifecycleWaitReady(t *testing.T) {
+	require := require.New(t)
+
+	stats := tally.NewTestScope("", nil)
+	l := newLifecycle(stats, clock.New())
+
+	var checks int
+	l.waitReady(func() error {
+		checks++
+		if checks < 3 {
+			return errors.New("not ready")
+		}
+		return nil
+	}, time.Millisecond)
+
+	require.Equal(3, checks)
+	require.Equal(int64(1), lifecycleCount(stats, _lifecycleReady))
+	var startups int
+	for _, timer := range stats.Snapshot().Timers() {
+		if timer.Name() == "startup_duration" {
+			startups += len(timer.Values())
+		}
+	}
+	require.Equal(1, startups)
+}
+
+func TestLifecycleWaitReadyStopsWhenDraining(t *testing.T) {
+	require := require.New(t)
+
+	stats := tally.NewTestScope("", nil)
+	l := newLifecycle(stats, clock.New())
+
+	var checks int
+	l.waitReady(func() error {
+		checks++
+		l.transition(_lifecycleDraining)
+		return errors.New("not ready")
+	}, time.Millisecond)
+
+	require.Equal(1, checks)
+	require.Equal(int64(0), lifecycleCount(stats, _lifecycleReady))
+}
+
+func TestLifecycleWaitReadyDoesNotLeaveDraining(t *testing.T) {
+	require := require.New(t)
+
+	stats := tally.NewTestScope("", nil)
+	l := newLifecycle(stats, clock.New())
+
+	// Draining begins while the passing check is in flight.
+	l.waitReady(func() error {
+		l.transition(_lifecycleDraining)
+		return nil
+	}, time.Millisecond)
+
+	require.Equal(_lifecycleDraining, l.current())
+	require.Equal(int64(0), lifecycleCount(stats, _lifecycleReady))
+}
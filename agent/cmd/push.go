@@ -0,0 +1,103 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/uber/kraken/build-index/tagclient"
+	"github.com/uber/kraken/lib/healthcheck"
+	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/persistedretry/tagpush"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/upstream"
+	"github.com/uber/kraken/localdb"
+	"github.com/uber/kraken/origin/blobclient"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
+)
+
+// PushConfig defines the optional write path of the agent, where images
+// pushed to the agent are replicated to the origins and build-index.
+type PushConfig struct {
+	// Enabled exposes the push endpoints on the agent server. Most agents are
+	// read-only and should leave this unset.
+	Enabled bool `yaml:"enabled"`
+
+	// Origin is the origin cluster which pushed blobs are uploaded to.
+	Origin upstream.ActiveConfig `yaml:"origin"`
+
+	// LocalDB persists push tasks, such that pushes survive agent restarts.
+	LocalDB localdb.Config `yaml:"localdb"`
+
+	// Retry configures retries of failed pushes.
+	Retry persistedretry.Config `yaml:"retry"`
+
+	// UploadTTL is how long blobs uploaded to the agent stay pinned in the
+	// cache without being referenced by a tag push.
+	UploadTTL time.Duration `yaml:"upload_ttl"`
+
+	// UploadExpiryInterval is how often uploads past their TTL are unpinned.
+	UploadExpiryInterval time.Duration `yaml:"upload_expiry_interval"`
+}
+
+func (c PushConfig) applyDefaults() PushConfig {
+	if c.UploadTTL == 0 {
+		c.UploadTTL = 24 * time.Hour
+	}
+	if c.UploadExpiryInterval == 0 {
+		c.UploadExpiryInterval = 10 * time.Minute
+	}
+	return c
+}
+
+// newPushManager creates a manager which pushes tags, and the blobs they
+// reference, from cads to the origins and build-index, and the pins which keep
+// pushed blobs in cads until they have been pushed.
+func newPushManager(
+	config PushConfig,
+	stats tally.Scope,
+	tls *tls.Config,
+	cads *store.CADownloadStore,
+	tags tagclient.Client) (persistedretry.Manager, *tagpush.Pins, error) {
+
+	config = config.applyDefaults()
+
+	origins, err := config.Origin.Build(upstream.WithHealthCheck(healthcheck.Default(tls)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("build origin host list: %s", err)
+	}
+	originCluster := blobclient.NewClusterClient(
+		blobclient.NewClientResolver(blobclient.NewProvider(blobclient.WithTLS(tls)), origins))
+
+	db, err := localdb.New(config.LocalDB)
+	if err != nil {
+		return nil, nil, fmt.Errorf("new local db: %s", err)
+	}
+	pins := tagpush.NewPins(cads, clock.New())
+	go pins.ExpireUploadsEvery(config.UploadTTL, config.UploadExpiryInterval, nil)
+
+	m, err := persistedretry.NewManager(
+		config.Retry,
+		stats,
+		tagpush.NewStore(db),
+		tagpush.NewExecutor(stats, cads, originCluster, tags, pins))
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, pins, nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/utils/log"
+
+	"go.uber.org/zap"
+)
+
+// reloader applies a safe subset of configuration to a running agent without
+// restarting it, such that active downloads are not interrupted. Reloadable
+// fields are:
+//
+//   - zap.level
+//   - scheduler.conn.bandwidth.egress_bits_per_sec
+//   - scheduler.conn.bandwidth.ingress_bits_per_sec
+//   - scheduler.announcer.default_interval
+//
+// Changes to any other fields, e.g. store paths, are ignored with a warning
+// and require a restart.
+type reloader struct {
+	load   func() (Config, error)
+	config Config
+	sched  scheduler.Scheduler
+}
+
+// newReloader creates a reloader for an agent started with config. The
+// config's log level must be the level of the running logger.
+func newReloader(
+	load func() (Config, error), config Config, sched scheduler.Scheduler) *reloader {
+
+	return &reloader{load, config, sched}
+}
+
+// run reloads configuration every time a signal is received on sigc. Exits
+// when sigc is closed.
+func (r *reloader) run(sigc <-chan os.Signal) {
+	for sig := range sigc {
+		log.Infof("Received %s, reloading config", sig)
+		if err := r.reload(); err != nil {
+			log.Errorf("Error reloading config: %s", err)
+		}
+	}
+}
+
+func (r *reloader) reload() error {
+	config, err := r.load()
+	if err != nil {
+		return fmt.Errorf("load config: %s", err)
+	}
+	for _, name := range nonReloadableChanges(r.config, config) {
+		log.Warnf("Ignoring change to %s, restart required", name)
+	}
+
+	level := config.ZapLogging.Level
+	if level != (zap.AtomicLevel{}) && level.Level() != r.config.ZapLogging.Level.Level() {
+		// The current level is shared with the running logger.
+		r.config.ZapLogging.Level.SetLevel(level.Level())
+		log.Infof("Log level updated to %s", level.Level())
+	}
+
+	old := r.config.Scheduler.Conn.Bandwidth
+	bw := config.Scheduler.Conn.Bandwidth
+	if bw.EgressBitsPerSec != old.EgressBitsPerSec || bw.IngressBitsPerSec != old.IngressBitsPerSec {
+		// Unset limits keep whatever limit is currently enforced.
+		egress, ingress := r.sched.BandwidthLimits()
+		if bw.EgressBitsPerSec != 0 {
+			egress = bw.EgressBitsPerSec
+		}
+		if bw.IngressBitsPerSec != 0 {
+			ingress = bw.IngressBitsPerSec
+		}
+		if err := r.sched.SetBandwidthLimits(egress, ingress); err != nil {
+			return fmt.Errorf("set bandwidth limits: %s", err)
+		}
+		r.config.Scheduler.Conn.Bandwidth.EgressBitsPerSec = bw.EgressBitsPerSec
+		r.config.Scheduler.Conn.Bandwidth.IngressBitsPerSec = bw.IngressBitsPerSec
+		log.Infof("Bandwidth limits updated to egress=%d ingress=%d bits/sec", egress, ingress)
+	}
+
+	interval := config.Scheduler.Announcer.DefaultInterval
+	if interval != r.config.Scheduler.Announcer.DefaultInterval && interval > 0 {
+		r.sched.SetAnnounceInterval(interval)
+		r.config.Scheduler.Announcer.DefaultInterval = interval
+		log.Infof("Default announce interval updated to %s", interval)
+	}
+	return nil
+}
+
+// nonReloadableChanges returns the yaml names of the top-level fields which
+// differ between old and new, excluding reloadable fields.
+func nonReloadableChanges(old, new Config) []string {
+	var names []string
+	if zapChanged(old.ZapLogging, new.ZapLogging) {
+		names = append(names, "zap")
+	}
+	for _, c := range []*Config{&old, &new} {
+		// zap.Config holds func-valued encoders which are never DeepEqual,
+		// so it is compared separately above.
+		c.ZapLogging = zap.Config{}
+		c.Scheduler.Conn.Bandwidth.EgressBitsPerSec = 0
+		c.Scheduler.Conn.Bandwidth.IngressBitsPerSec = 0
+		c.Scheduler.Announcer.DefaultInterval = 0
+	}
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+	for i := 0; i < ov.NumField(); i++ {
+		if !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			f := ov.Type().Field(i)
+			name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if name == "" {
+				name = f.Name
+			}
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// zapChanged returns whether the non-reloadable logging fields differ between
+// old and new. The level is reloadable and ignored.
+func zapChanged(old, new zap.Config) bool {
+	return old.Encoding != new.Encoding ||
+		!reflect.DeepEqual(old.OutputPaths, new.OutputPaths) ||
+		!reflect.DeepEqual(old.ErrorOutputPaths, new.ErrorOutputPaths)
+}
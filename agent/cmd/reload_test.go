@@ -0,0 +1,179 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	mockscheduler "github.com/uber/kraken/mocks/lib/torrent/scheduler"
+)
+
+func reloaderConfigFixture() Config {
+	var c Config
+	c.ZapLogging.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	c.Scheduler.Conn.Bandwidth.EgressBitsPerSec = 800
+	c.Scheduler.Conn.Bandwidth.IngressBitsPerSec = 1600
+	c.Scheduler.Announcer.DefaultInterval = 5 * time.Second
+	c.CADownloadStore.DownloadDir = "/var/cache/download"
+	return c
+}
+
+func newTestReloader(
+	sched *mockscheduler.MockScheduler, old Config, new func(*Config)) *reloader {
+
+	return newReloader(func() (Config, error) {
+		c := reloaderConfigFixture()
+		new(&c)
+		return c, nil
+	}, old, sched)
+}
+
+func TestReloaderNoChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	r := newTestReloader(sched, reloaderConfigFixture(), func(*Config) {})
+	require.NoError(t, r.reload())
+}
+
+func TestReloaderUpdatesLogLevel(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	config := reloaderConfigFixture()
+	level := config.ZapLogging.Level
+
+	r := newTestReloader(sched, config, func(c *Config) {
+		c.ZapLogging.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	})
+	require.NoError(r.reload())
+	require.Equal(zapcore.DebugLevel, level.Level())
+}
+
+func TestReloaderUpdatesBandwidthLimits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	r := newTestReloader(sched, reloaderConfigFixture(), func(c *Config) {
+		c.Scheduler.Conn.Bandwidth.EgressBitsPerSec = 400
+	})
+
+	gomock.InOrder(
+		sched.EXPECT().BandwidthLimits().Return(uint64(800), uint64(1600)),
+		sched.EXPECT().SetBandwidthLimits(uint64(400), uint64(1600)).Return(nil),
+	)
+	require.NoError(t, r.reload())
+
+	// Subsequent reloads of the same config are no-ops.
+	require.NoError(t, r.reload())
+}
+
+func TestReloaderSetBandwidthLimitsError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	r := newTestReloader(sched, reloaderConfigFixture(), func(c *Config) {
+		c.Scheduler.Conn.Bandwidth.IngressBitsPerSec = 400
+	})
+
+	gomock.InOrder(
+		sched.EXPECT().BandwidthLimits().Return(uint64(800), uint64(1600)),
+		sched.EXPECT().SetBandwidthLimits(uint64(800), uint64(400)).Return(errors.New("some error")),
+	)
+	require.Error(t, r.reload())
+}
+
+func TestReloaderUpdatesAnnounceInterval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	r := newTestReloader(sched, reloaderConfigFixture(), func(c *Config) {
+		c.Scheduler.Announcer.DefaultInterval = 10 * time.Second
+	})
+
+	sched.EXPECT().SetAnnounceInterval(10 * time.Second)
+	require.NoError(t, r.reload())
+}
+
+func TestReloaderIgnoresNonReloadableChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	r := newTestReloader(sched, reloaderConfigFixture(), func(c *Config) {
+		c.CADownloadStore.DownloadDir = "/tmp/download"
+	})
+	require.NoError(t, r.reload())
+	require.Equal(t, "/var/cache/download", r.config.CADownloadStore.DownloadDir)
+}
+
+func TestReloaderLoadError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	r := newReloader(func() (Config, error) {
+		return Config{}, errors.New("some error")
+	}, reloaderConfigFixture(), sched)
+	require.Error(t, r.reload())
+}
+
+func TestNonReloadableChanges(t *testing.T) {
+	old := reloaderConfigFixture()
+	new := reloaderConfigFixture()
+	new.ZapLogging.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	new.Scheduler.Conn.Bandwidth.EgressBitsPerSec = 1
+	new.Scheduler.Announcer.DefaultInterval = time.Second
+	new.Scheduler.ConnTTL = time.Minute
+	new.CADownloadStore.DownloadDir = "/tmp/download"
+	new.DrainTimeout = time.Minute
+
+	require.Equal(t,
+		[]string{"store", "scheduler", "drain_timeout"},
+		nonReloadableChanges(old, new))
+}
+
+func TestNonReloadableChangesIgnoresZapEncoders(t *testing.T) {
+	old := reloaderConfigFixture()
+	old.ZapLogging.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	new := reloaderConfigFixture()
+	new.ZapLogging.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	require.Empty(t, nonReloadableChanges(old, new))
+
+	new.ZapLogging.OutputPaths = []string{"/var/log/kraken.log"}
+
+	require.Equal(t, []string{"zap"}, nonReloadableChanges(old, new))
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/torrent/networkevent"
+	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/utils/log"
+)
+
+// shutdownStep is a single named teardown routine.
+type shutdownStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// drainer is a server which stops accepting requests and drains in-flight
+// requests on Shutdown.
+type drainer interface {
+	Shutdown(ctx context.Context) error
+}
+
+// shutdownCoordinator sequences agent teardown once a termination signal is
+// received. Steps run in the order they were added and share a single drain
+// deadline.
+type shutdownCoordinator struct {
+	drainTimeout time.Duration
+	steps        []shutdownStep
+}
+
+func newShutdownCoordinator(drainTimeout time.Duration) *shutdownCoordinator {
+	return &shutdownCoordinator{drainTimeout: drainTimeout}
+}
+
+// newAgentShutdown creates a shutdownCoordinator which stops accepting agent
+// server and registry requests, drains in-flight requests, and then tears down
+// the scheduler, store, network event producer, and metrics, in that order.
+// Image pulls through the registry are therefore completed before the
+// scheduler stops serving their blobs. The draining and stopped transitions
+// are recorded in lc, before metrics are closed.
+func newAgentShutdown(
+	drainTimeout time.Duration,
+	server *http.Server,
+	registry drainer,
+	sched scheduler.Scheduler,
+	cads *store.CADownloadStore,
+	netevents networkevent.Producer,
+	metrics io.Closer,
+	lc *lifecycle) *shutdownCoordinator {
+
+	c := newShutdownCoordinator(drainTimeout)
+	c.add("agent server", func(ctx context.Context) error {
+		lc.transition(_lifecycleDraining)
+		return server.Shutdown(ctx)
+	})
+	c.add("registry", func(ctx context.Context) error {
+		return registry.Shutdown(ctx)
+	})
+	c.add("scheduler", func(context.Context) error {
+		sched.Stop()
+		return nil
+	})
+	c.add("store", func(context.Context) error {
+		cads.Close()
+		return nil
+	})
+	c.add("network events", func(context.Context) error {
+		return netevents.Close()
+	})
+	c.add("metrics", func(context.Context) error {
+		lc.transition(_lifecycleStopped)
+		return metrics.Close()
+	})
+	return c
+}
+
+func (c *shutdownCoordinator) add(name string, run func(ctx context.Context) error) {
+	c.steps = append(c.steps, shutdownStep{name, run})
+}
+
+// wait blocks until either a signal is received on sigc, in which case all
+// steps are executed, or an error is received on errc, in which case the error
+// is returned and no steps are executed.
+func (c *shutdownCoordinator) wait(sigc <-chan os.Signal, errc <-chan error) error {
+	select {
+	case sig := <-sigc:
+		log.Infof("Received %s, shutting down with %s drain timeout", sig, c.drainTimeout)
+		c.shutdown()
+		return nil
+	case err := <-errc:
+		return err
+	}
+}
+
+// shutdown executes all steps. Failed steps are logged and do not prevent
+// subsequent steps from running.
+func (c *shutdownCoordinator) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.drainTimeout)
+	defer cancel()
+
+	for _, s := range c.steps {
+		log.Infof("Stopping %s...", s.name)
+		if err := s.run(ctx); err != nil {
+			log.Errorf("Error stopping %s: %s", s.name, err)
+		}
+	}
+	log.Info("Shutdown complete")
+}
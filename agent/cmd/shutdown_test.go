@@ -0,0 +1,173 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/torrent/networkevent"
+	mockscheduler "github.com/uber/kraken/mocks/lib/torrent/scheduler"
+)
+
+type recordingCloser struct {
+	closed bool
+}
+
+func (c *recordingCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+type recordingDrainer struct {
+	drained bool
+}
+
+func (d *recordingDrainer) Shutdown(context.Context) error {
+	d.drained = true
+	return nil
+}
+
+type recordingProducer struct {
+	*networkevent.TestProducer
+	closed bool
+}
+
+func (p *recordingProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestShutdownCoordinatorRunsStepsInOrderOnSignal(t *testing.T) {
+	require := require.New(t)
+
+	var order []string
+	c := newShutdownCoordinator(time.Second)
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		c.add(name, func(context.Context) error {
+			order = append(order, name)
+			return nil
+		})
+	}
+
+	sigc := make(chan os.Signal, 1)
+	sigc <- syscall.SIGTERM
+	require.NoError(c.wait(sigc, make(chan error)))
+	require.Equal([]string{"a", "b", "c"}, order)
+}
+
+func TestShutdownCoordinatorContinuesAfterFailedStep(t *testing.T) {
+	require := require.New(t)
+
+	var ran bool
+	c := newShutdownCoordinator(time.Second)
+	c.add("fails", func(context.Context) error { return errors.New("some error") })
+	c.add("succeeds", func(context.Context) error {
+		ran = true
+		return nil
+	})
+
+	c.shutdown()
+	require.True(ran)
+}
+
+func TestShutdownCoordinatorStepsShareDrainDeadline(t *testing.T) {
+	require := require.New(t)
+
+	c := newShutdownCoordinator(50 * time.Millisecond)
+	c.add("blocks", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	var err error
+	c.add("after", func(ctx context.Context) error {
+		err = ctx.Err()
+		return nil
+	})
+
+	start := time.Now()
+	c.shutdown()
+	require.True(time.Since(start) < time.Second)
+	require.Equal(context.DeadlineExceeded, err)
+}
+
+func TestShutdownCoordinatorReturnsErrorWithoutRunningSteps(t *testing.T) {
+	require := require.New(t)
+
+	var ran bool
+	c := newShutdownCoordinator(time.Second)
+	c.add("step", func(context.Context) error {
+		ran = true
+		return nil
+	})
+
+	errc := make(chan error, 1)
+	errc <- errors.New("some error")
+	require.Error(c.wait(make(chan os.Signal), errc))
+	require.False(ran)
+}
+
+func TestAgentShutdownStopsAllComponents(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	server := &http.Server{Addr: "localhost:0"}
+	serverErrc := make(chan error, 1)
+	go func() { serverErrc <- server.ListenAndServe() }()
+
+	registry := &recordingDrainer{}
+
+	// In-flight pulls through the registry must be drained before the
+	// scheduler stops serving their blobs.
+	sched := mockscheduler.NewMockScheduler(ctrl)
+	sched.EXPECT().Stop().Do(func() {
+		require.True(registry.drained)
+	})
+
+	netevents := &recordingProducer{TestProducer: networkevent.NewTestProducer()}
+	metrics := &recordingCloser{}
+
+	stats := tally.NewTestScope("", nil)
+	lc := newLifecycle(stats, clock.New())
+
+	c := newAgentShutdown(time.Second, server, registry, sched, cads, netevents, metrics, lc)
+
+	sigc := make(chan os.Signal, 1)
+	sigc <- syscall.SIGINT
+	require.NoError(c.wait(sigc, make(chan error)))
+
+	require.Equal(http.ErrServerClosed, <-serverErrc)
+	require.True(registry.drained)
+	require.True(netevents.closed)
+	require.True(metrics.closed)
+	require.Equal(int64(1), lifecycleCount(stats, _lifecycleDraining))
+	require.Equal(int64(1), lifecycleCount(stats, _lifecycleStopped))
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/dockerregistry/transfer"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/utils/dockerutil"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/uber-go/tally"
+)
+
+// simulator replays a scripted pull workload through the same transferer the
+// agent registry uses, emitting timing metrics and discarding downloaded blobs.
+// Used for capacity planning without serving real traffic.
+type simulator struct {
+	stats      tally.Scope
+	transferer transfer.ImageTransferer
+	discard    func(core.Digest) error
+	platform   string
+}
+
+func newSimulator(
+	stats tally.Scope,
+	transferer transfer.ImageTransferer,
+	discard func(core.Digest) error) *simulator {
+
+	return &simulator{
+		stats: stats.Tagged(map[string]string{
+			"module": "simulate",
+		}),
+		transferer: transferer,
+		discard:    discard,
+		platform:   runtime.GOOS + "/" + runtime.GOARCH,
+	}
+}
+
+// discardFromAgent returns a discard function which removes blobs from both
+// the scheduler and cads.
+func discardFromAgent(
+	sched scheduler.Scheduler, cads *store.CADownloadStore) func(core.Digest) error {
+
+	return func(d core.Digest) error {
+		if err := sched.RemoveTorrent(d); err != nil && err != scheduler.ErrTorrentNotFound {
+			return fmt.Errorf("remove torrent: %s", err)
+		}
+		if err := cads.Cache().DeleteFile(d.Hex()); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("delete cache file: %s", err)
+		}
+		return nil
+	}
+}
+
+// run pulls each image sequentially. Failed pulls are logged and counted, and
+// do not stop the simulation.
+func (s *simulator) run(images []string) {
+	start := time.Now()
+	for _, image := range images {
+		if err := s.pull(image); err != nil {
+			s.stats.Counter("image_pull_errors").Inc(1)
+			log.With("image", image).Errorf("Error simulating pull: %s", err)
+		}
+	}
+	s.stats.Timer("workload_latency").Record(time.Since(start))
+}
+
+// pull resolves image, downloads its manifest and every blob it references,
+// and then discards them.
+func (s *simulator) pull(image string) error {
+	repo, err := parseImage(image)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	d, err := s.transferer.GetTag(context.Background(), image)
+	if err != nil {
+		return fmt.Errorf("get tag: %s", err)
+	}
+	s.stats.Timer("tag_resolve_latency").Record(time.Since(start))
+
+	var downloaded []core.Digest
+	defer func() {
+		for _, d := range downloaded {
+			if err := s.discard(d); err != nil {
+				log.With("blob", d).Errorf("Error discarding simulated download: %s", err)
+			}
+		}
+	}()
+
+	refs, err := s.downloadManifest(repo, d, &downloaded)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if err := s.downloadBlob(repo, ref); err != nil {
+			return err
+		}
+		downloaded = append(downloaded, ref)
+	}
+
+	s.stats.Timer("image_pull_latency").Record(time.Since(start))
+	s.stats.Counter("images_pulled").Inc(1)
+	return nil
+}
+
+// downloadManifest downloads manifest d and returns the blobs it references.
+// Image indexes are resolved to the manifest of s's platform.
+func (s *simulator) downloadManifest(
+	repo string, d core.Digest, downloaded *[]core.Digest) ([]core.Digest, error) {
+
+	download := s.transferer.Download
+	if md, ok := s.transferer.(transfer.ManifestDownloader); ok {
+		download = md.DownloadManifest
+	}
+	f, err := download(context.Background(), repo, d)
+	if err != nil {
+		return nil, fmt.Errorf("download manifest: %s", err)
+	}
+	defer f.Close()
+	*downloaded = append(*downloaded, d)
+
+	manifest, _, err := dockerutil.ParseManifest(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse manifest: %s", err)
+	}
+	if dockerutil.IsManifestIndex(manifest) {
+		pd, err := dockerutil.GetPlatformManifest(manifest, s.platform)
+		if err != nil {
+			return nil, fmt.Errorf("get platform manifest: %s", err)
+		}
+		return s.downloadManifest(repo, pd, downloaded)
+	}
+	refs, err := dockerutil.GetManifestReferences(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("get manifest references: %s", err)
+	}
+	return refs, nil
+}
+
+func (s *simulator) downloadBlob(repo string, d core.Digest) error {
+	start := time.Now()
+	f, err := s.transferer.Download(context.Background(), repo, d)
+	if err != nil {
+		return fmt.Errorf("download blob %s: %s", d, err)
+	}
+	defer f.Close()
+	s.stats.Timer("blob_download_latency").Record(time.Since(start))
+	s.stats.Counter("bytes_downloaded").Inc(f.Size())
+	return nil
+}
+
+// parseImage returns the repo of an image formatted as "repo:tag".
+func parseImage(image string) (repo string, err error) {
+	i := strings.LastIndex(image, ":")
+	if i <= 0 || i == len(image)-1 || strings.Contains(image[i:], "/") {
+		return "", fmt.Errorf("invalid image %q: expected repo:tag", image)
+	}
+	return image[:i], nil
+}
+
+// parseWorkload parses a workload of images to pull, one per line. Blank lines
+// and lines starting with "#" are ignored.
+func parseWorkload(r io.Reader) ([]string, error) {
+	var images []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := parseImage(line); err != nil {
+			return nil, err
+		}
+		images = append(images, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %s", err)
+	}
+	return images, nil
+}
+
+// loadWorkload parses the workload file at path.
+func loadWorkload(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseWorkload(f)
+}
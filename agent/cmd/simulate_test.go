@@ -0,0 +1,108 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/dockerregistry/transfer"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/utils/dockerutil"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func TestParseWorkload(t *testing.T) {
+	require := require.New(t)
+
+	images, err := parseWorkload(strings.NewReader(`
+# Base images.
+library/ubuntu:18.04
+
+uber/kraken-agent:v0.1.0
+`))
+	require.NoError(err)
+	require.Equal([]string{"library/ubuntu:18.04", "uber/kraken-agent:v0.1.0"}, images)
+}
+
+func TestParseWorkloadInvalidImage(t *testing.T) {
+	for _, image := range []string{"ubuntu", "ubuntu:", ":latest", "localhost:5000/ubuntu"} {
+		t.Run(image, func(t *testing.T) {
+			_, err := parseWorkload(strings.NewReader(image))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSimulatorPull(t *testing.T) {
+	require := require.New(t)
+
+	cas, cleanup := store.CAStoreFixture()
+	defer cleanup()
+
+	transferer := transfer.NewTestTransferer(cas)
+
+	config := core.NewBlobFixture()
+	layer1 := core.NewBlobFixture()
+	layer2 := core.NewBlobFixture()
+	manifest, raw := dockerutil.ManifestFixture(config.Digest, layer1.Digest, layer2.Digest)
+
+	require.NoError(cas.CreateCacheFile(manifest.Hex(), bytes.NewReader(raw)))
+	for _, blob := range []*core.BlobFixture{config, layer1, layer2} {
+		require.NoError(cas.CreateCacheFile(blob.Digest.Hex(), bytes.NewReader(blob.Content)))
+	}
+	require.NoError(transferer.PutTag(context.Background(), "repo:tag", manifest))
+
+	var discarded []core.Digest
+	s := newSimulator(tally.NoopScope, transferer, func(d core.Digest) error {
+		discarded = append(discarded, d)
+		return nil
+	})
+
+	require.NoError(s.pull("repo:tag"))
+	require.ElementsMatch(
+		[]core.Digest{manifest, config.Digest, layer1.Digest, layer2.Digest}, discarded)
+}
+
+func TestSimulatorPullDiscardsOnError(t *testing.T) {
+	require := require.New(t)
+
+	cas, cleanup := store.CAStoreFixture()
+	defer cleanup()
+
+	transferer := transfer.NewTestTransferer(cas)
+
+	config := core.NewBlobFixture()
+	layer1 := core.NewBlobFixture()
+	layer2 := core.NewBlobFixture()
+	manifest, raw := dockerutil.ManifestFixture(config.Digest, layer1.Digest, layer2.Digest)
+
+	// Layers are missing.
+	require.NoError(cas.CreateCacheFile(manifest.Hex(), bytes.NewReader(raw)))
+	require.NoError(transferer.PutTag(context.Background(), "repo:tag", manifest))
+
+	var discarded []core.Digest
+	s := newSimulator(tally.NoopScope, transferer, func(d core.Digest) error {
+		discarded = append(discarded, d)
+		return nil
+	})
+
+	require.Error(s.pull("repo:tag"))
+	require.Equal([]core.Digest{manifest}, discarded)
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/uber/kraken/lib/store"
+
+	"github.com/uber-go/tally"
+)
+
+// transferStore copies the cached blobs of the store configured by config,
+// along with their metadata, into a store rooted at root, and writes a summary
+// to w. Must not run while an agent is using either store.
+func transferStore(w io.Writer, config store.CADownloadStoreConfig, root string) error {
+	// Files must not be removed from the source while they are copied.
+	config.CacheEviction = store.EvictionConfig{}
+	config.CacheExpiry = store.ExpiryConfig{}
+	config.DownloadCleanup.Disabled = true
+	config.CacheCleanup.Disabled = true
+
+	src, err := store.NewCADownloadStore(config, tally.NoopScope)
+	if err != nil {
+		return fmt.Errorf("new source store: %s", err)
+	}
+	defer src.Close()
+
+	dst, err := store.NewCADownloadStore(store.CADownloadStoreConfig{
+		DownloadDir: filepath.Join(root, "download"),
+		CacheDir:    filepath.Join(root, "cache"),
+	}, tally.NoopScope)
+	if err != nil {
+		return fmt.Errorf("new destination store: %s", err)
+	}
+	defer dst.Close()
+
+	report, err := src.TransferTo(dst)
+	for _, name := range report.Corrupt {
+		fmt.Fprintf(w, "Skipped corrupt file %s\n", name)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Copied %d cached files, skipped %d already present and %d corrupt files\n",
+		len(report.Copied), len(report.Skipped), len(report.Corrupt))
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+)
+
+func TestTransferStore(t *testing.T) {
+	require := require.New(t)
+
+	config, cleanup := store.CADownloadStoreConfigFixture()
+	defer cleanup()
+
+	root, err := ioutil.TempDir("", "transfer")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	cads, err := store.NewCADownloadStore(config, tally.NoopScope)
+	require.NoError(err)
+
+	blob := core.NewBlobFixture()
+	require.NoError(store.RunDownload(cads, blob.Digest, blob.Content))
+	cads.Close()
+
+	var out bytes.Buffer
+	require.NoError(transferStore(&out, config, root))
+	require.Equal(
+		"Copied 1 cached files, skipped 0 already present and 0 corrupt files\n", out.String())
+
+	_, err = os.Stat(filepath.Join(root, "cache"))
+	require.NoError(err)
+
+	// Transferring again is a no-op.
+	out.Reset()
+	require.NoError(transferStore(&out, config, root))
+	require.Equal(
+		"Copied 0 cached files, skipped 1 already present and 0 corrupt files\n", out.String())
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/utils/log"
+)
+
+// WarmCacheConfig defines seeding of cached blobs on agent startup.
+type WarmCacheConfig struct {
+	// Enabled starts seeding blobs which are already cached on disk when the
+	// agent starts, instead of waiting for them to be requested again.
+	Enabled bool `yaml:"enabled"`
+
+	// MaxBlobs limits the number of blobs which are seeded on startup. The most
+	// recently accessed blobs are seeded first.
+	MaxBlobs int `yaml:"max_blobs"`
+
+	// Workers is the number of blobs which are verified and seeded concurrently.
+	Workers int `yaml:"workers"`
+
+	// Verify hashes the content of each blob before it is seeded. Corrupt blobs
+	// are evicted from the cache instead.
+	Verify bool `yaml:"verify"`
+
+	// ReadyFraction is the fraction of blobs which must be processed before the
+	// agent reports ready. Defaults to 0, i.e. readiness does not wait for
+	// seeding.
+	ReadyFraction float64 `yaml:"ready_fraction"`
+}
+
+func (c WarmCacheConfig) applyDefaults() WarmCacheConfig {
+	if c.MaxBlobs == 0 {
+		c.MaxBlobs = 100
+	}
+	if c.Workers == 0 {
+		c.Workers = 4
+	}
+	return c
+}
+
+type cachedBlob struct {
+	digest     core.Digest
+	lastAccess time.Time
+}
+
+// cacheWarmer seeds blobs which are already cached on disk, and tracks its
+// progress for readiness checks.
+type cacheWarmer struct {
+	config WarmCacheConfig
+	cads   *store.CADownloadStore
+	sched  scheduler.Scheduler
+
+	// total is the number of blobs to process, or -1 while the cache is still
+	// being listed. total and processed are accessed atomically.
+	total     int64
+	processed int64
+}
+
+func newCacheWarmer(
+	config WarmCacheConfig, cads *store.CADownloadStore, sched scheduler.Scheduler) *cacheWarmer {
+
+	return &cacheWarmer{
+		config: config.applyDefaults(),
+		cads:   cads,
+		sched:  sched,
+		total:  -1,
+	}
+}
+
+// run seeds up to config.MaxBlobs blobs in the cache, most recently accessed
+// first. Returns the number of blobs seeded.
+func (w *cacheWarmer) run() (int, error) {
+	blobs, err := w.listBlobs()
+	if err != nil {
+		// Do not block readiness on a cache which cannot be listed.
+		atomic.StoreInt64(&w.total, 0)
+		return 0, err
+	}
+	if len(blobs) > w.config.MaxBlobs {
+		blobs = blobs[:w.config.MaxBlobs]
+	}
+	atomic.StoreInt64(&w.total, int64(len(blobs)))
+
+	digests := make(chan core.Digest)
+	var seeded int64
+	var wg sync.WaitGroup
+	for i := 0; i < w.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range digests {
+				if err := w.seed(d); err != nil {
+					log.With("blob", d).Infof("Skipping cached blob: %s", err)
+				} else {
+					atomic.AddInt64(&seeded, 1)
+				}
+				atomic.AddInt64(&w.processed, 1)
+			}
+		}()
+	}
+	for _, b := range blobs {
+		digests <- b.digest
+	}
+	close(digests)
+	wg.Wait()
+
+	return int(seeded), nil
+}
+
+// listBlobs returns all blobs in the cache, most recently accessed first.
+func (w *cacheWarmer) listBlobs() ([]cachedBlob, error) {
+	names, err := w.cads.ListCacheFileNames()
+	if err != nil {
+		return nil, err
+	}
+	var blobs []cachedBlob
+	for _, name := range names {
+		d, err := core.NewDigestFromHex(name)
+		if err != nil {
+			continue
+		}
+		var lat metadata.LastAccessTime
+		if err := w.cads.Cache().GetMetadata(name, &lat); err != nil {
+			// Blobs which have never been read are seeded last.
+			lat.Time = time.Time{}
+		}
+		blobs = append(blobs, cachedBlob{d, lat.Time})
+	}
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].lastAccess.After(blobs[j].lastAccess)
+	})
+	return blobs, nil
+}
+
+func (w *cacheWarmer) seed(d core.Digest) error {
+	if w.config.Verify {
+		if err := w.cads.VerifyCacheFile(d.Hex()); err != nil {
+			return fmt.Errorf("verify: %s", err)
+		}
+	}
+	return w.sched.Seed(d)
+}
+
+// CheckReadiness returns an error until config.ReadyFraction of the blobs to
+// seed have been processed.
+func (w *cacheWarmer) CheckReadiness() error {
+	if w.config.ReadyFraction == 0 {
+		return nil
+	}
+	total := atomic.LoadInt64(&w.total)
+	if total < 0 {
+		return errors.New("listing cached blobs")
+	}
+	processed := atomic.LoadInt64(&w.processed)
+	if float64(processed) < w.config.ReadyFraction*float64(total) {
+		return fmt.Errorf("processed %d of %d cached blobs", processed, total)
+	}
+	return nil
+}
+
+// seedPinnedBlobs seeds all cached blobs pinned via the agent server,
+// regardless of whether the warm cache is enabled. Returns the number of blobs
+// seeded.
+func seedPinnedBlobs(cads *store.CADownloadStore, sched scheduler.Scheduler) (int, error) {
+	names, err := cads.ListCacheFileNames()
+	if err != nil {
+		return 0, err
+	}
+	var seeded int
+	for _, name := range names {
+		d, err := core.NewDigestFromHex(name)
+		if err != nil {
+			continue
+		}
+		var persist metadata.Persist
+		if err := cads.Cache().GetMetadata(name, &persist); err != nil || !persist.Value {
+			continue
+		}
+		if err := sched.Seed(d); err != nil {
+			log.With("blob", d).Infof("Skipping pinned blob: %s", err)
+			continue
+		}
+		seeded++
+	}
+	return seeded, nil
+}
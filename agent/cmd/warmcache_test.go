@@ -0,0 +1,278 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
+	mockscheduler "github.com/uber/kraken/mocks/lib/torrent/scheduler"
+	"github.com/uber/kraken/utils/randutil"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedCachedBlobsMostRecentlyAccessedFirst(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	now := time.Now()
+	var blobs []*core.BlobFixture
+	for i := 0; i < 3; i++ {
+		blob := core.NewBlobFixture()
+		require.NoError(store.RunDownload(cads, blob.Digest, blob.Content))
+		lat := metadata.NewLastAccessTime(now.Add(time.Duration(i) * time.Hour))
+		_, err := cads.Cache().SetMetadata(blob.Digest.Hex(), lat)
+		require.NoError(err)
+		blobs = append(blobs, blob)
+	}
+
+	gomock.InOrder(
+		sched.EXPECT().Seed(blobs[2].Digest).Return(nil),
+		sched.EXPECT().Seed(blobs[1].Digest).Return(nil),
+	)
+
+	config := WarmCacheConfig{Enabled: true, MaxBlobs: 2, Workers: 1}
+	n, err := newCacheWarmer(config, cads, sched).run()
+	require.NoError(err)
+	require.Equal(2, n)
+}
+
+func TestSeedCachedBlobsSkipsErrors(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	good := core.NewBlobFixture()
+	bad := core.NewBlobFixture()
+	for _, blob := range []*core.BlobFixture{good, bad} {
+		require.NoError(store.RunDownload(cads, blob.Digest, blob.Content))
+	}
+
+	sched.EXPECT().Seed(good.Digest).Return(nil)
+	sched.EXPECT().Seed(bad.Digest).Return(errors.New("some error"))
+
+	n, err := newCacheWarmer(WarmCacheConfig{Enabled: true}, cads, sched).run()
+	require.NoError(err)
+	require.Equal(1, n)
+}
+
+func TestSeedCachedBlobsSupportsSHA512(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	digester, err := core.NewDigesterForAlgo(core.SHA512)
+	require.NoError(err)
+	content := randutil.Text(64)
+	d, err := digester.FromBytes(content)
+	require.NoError(err)
+	require.NoError(store.RunDownload(cads, d, content))
+
+	sched.EXPECT().Seed(d).Return(nil)
+
+	n, err := newCacheWarmer(WarmCacheConfig{Enabled: true}, cads, sched).run()
+	require.NoError(err)
+	require.Equal(1, n)
+}
+
+func TestCacheWarmerSeedsConcurrently(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	var mu sync.Mutex
+	var inflight, maxInflight int
+	for i := 0; i < 8; i++ {
+		blob := core.NewBlobFixture()
+		require.NoError(store.RunDownload(cads, blob.Digest, blob.Content))
+		sched.EXPECT().Seed(blob.Digest).DoAndReturn(func(core.Digest) error {
+			mu.Lock()
+			inflight++
+			if inflight > maxInflight {
+				maxInflight = inflight
+			}
+			mu.Unlock()
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			inflight--
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	n, err := newCacheWarmer(WarmCacheConfig{Enabled: true, Workers: 4}, cads, sched).run()
+	require.NoError(err)
+	require.Equal(8, n)
+	require.True(maxInflight > 1)
+	require.True(maxInflight <= 4)
+}
+
+func TestCacheWarmerVerifyEvictsCorruptBlobs(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	good := core.NewBlobFixture()
+	require.NoError(store.RunDownload(cads, good.Digest, good.Content))
+
+	corrupt := core.NewBlobFixture()
+	require.NoError(store.RunDownload(cads, corrupt.Digest, []byte("some corrupt content")))
+
+	sched.EXPECT().Seed(good.Digest).Return(nil)
+
+	n, err := newCacheWarmer(WarmCacheConfig{Enabled: true, Verify: true}, cads, sched).run()
+	require.NoError(err)
+	require.Equal(1, n)
+
+	_, err = cads.Cache().GetFileStat(corrupt.Digest.Hex())
+	require.True(os.IsNotExist(err))
+}
+
+func TestCacheWarmerReadiness(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	var blobs []*core.BlobFixture
+	for i := 0; i < 4; i++ {
+		blob := core.NewBlobFixture()
+		require.NoError(store.RunDownload(cads, blob.Digest, blob.Content))
+		blobs = append(blobs, blob)
+	}
+
+	w := newCacheWarmer(WarmCacheConfig{Enabled: true, Workers: 1, ReadyFraction: 0.5}, cads, sched)
+
+	// Not ready until the cache has been listed.
+	require.Error(w.CheckReadiness())
+
+	var checks []error
+	for _, blob := range blobs {
+		sched.EXPECT().Seed(blob.Digest).DoAndReturn(func(core.Digest) error {
+			checks = append(checks, w.CheckReadiness())
+			return nil
+		})
+	}
+
+	n, err := w.run()
+	require.NoError(err)
+	require.Equal(4, n)
+
+	// Ready once half of the blobs have been processed.
+	require.Len(checks, 4)
+	require.Error(checks[0])
+	require.Error(checks[1])
+	require.NoError(checks[2])
+	require.NoError(checks[3])
+	require.NoError(w.CheckReadiness())
+}
+
+func TestCacheWarmerReadinessDisabled(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	w := newCacheWarmer(WarmCacheConfig{Enabled: true}, cads, sched)
+	require.NoError(w.CheckReadiness())
+}
+
+func TestSeedPinnedBlobs(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	sched := mockscheduler.NewMockScheduler(ctrl)
+
+	pinned := core.NewBlobFixture()
+	unpinned := core.NewBlobFixture()
+	unset := core.NewBlobFixture()
+	for _, blob := range []*core.BlobFixture{pinned, unpinned, unset} {
+		require.NoError(store.RunDownload(cads, blob.Digest, blob.Content))
+	}
+	_, err := cads.Cache().SetMetadata(pinned.Digest.Hex(), metadata.NewPersist(true))
+	require.NoError(err)
+	_, err = cads.Cache().SetMetadata(unpinned.Digest.Hex(), metadata.NewPersist(false))
+	require.NoError(err)
+
+	// Pinned blobs of any digest algorithm are seeded.
+	digester, err := core.NewDigesterForAlgo(core.SHA512)
+	require.NoError(err)
+	content := randutil.Text(64)
+	sha512, err := digester.FromBytes(content)
+	require.NoError(err)
+	require.NoError(store.RunDownload(cads, sha512, content))
+	_, err = cads.Cache().SetMetadata(sha512.Hex(), metadata.NewPersist(true))
+	require.NoError(err)
+
+	sched.EXPECT().Seed(pinned.Digest).Return(nil)
+	sched.EXPECT().Seed(sha512).Return(nil)
+
+	n, err := seedPinnedBlobs(cads, sched)
+	require.NoError(err)
+	require.Equal(2, n)
+}
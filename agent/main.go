@@ -13,8 +13,18 @@
 // limitations under the License.
 package main
 
-import "github.com/uber/kraken/agent/cmd"
+import (
+	"fmt"
+	"os"
+
+	"github.com/uber/kraken/agent/cmd"
+)
 
 func main() {
-	cmd.Run(cmd.ParseFlags())
+	flags := cmd.ParseFlags()
+	if err := flags.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid flags: %s\n", err)
+		os.Exit(2)
+	}
+	cmd.Run(flags)
 }
@@ -16,6 +16,7 @@ package cmd
 import (
 	"flag"
 
+	"github.com/andres-erbsen/clock"
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/build-index/tagserver"
 	"github.com/uber/kraken/build-index/tagstore"
@@ -143,7 +144,8 @@ func Run(flags *Flags) {
 		config.TagReplication,
 		stats,
 		tagReplicationStore,
-		tagReplicationExecutor)
+		persistedretry.NewDeadlineExecutor(
+			tagReplicationExecutor, tagReplicationStore, clock.New()))
 	if err != nil {
 		log.Fatalf("Error creating tag replication manager: %s", err)
 	}
@@ -0,0 +1,107 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagclient
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/andres-erbsen/clock"
+)
+
+// BackoffConfig defines how long a cluster client skips a build-index host
+// after a network error. The window doubles with each consecutive failure of
+// the host, up to Max.
+type BackoffConfig struct {
+	Initial time.Duration `yaml:"initial"`
+	Max     time.Duration `yaml:"max"`
+}
+
+func (c BackoffConfig) applyDefaults() BackoffConfig {
+	if c.Initial == 0 {
+		c.Initial = time.Second
+	}
+	if c.Max == 0 {
+		c.Max = time.Minute
+	}
+	return c
+}
+
+type hostState struct {
+	failures int
+	until    time.Time
+}
+
+// hostBackoff tracks per-host backoff windows.
+type hostBackoff struct {
+	config BackoffConfig
+	clk    clock.Clock
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+func newHostBackoff(config BackoffConfig, clk clock.Clock) *hostBackoff {
+	return &hostBackoff{
+		config: config.applyDefaults(),
+		clk:    clk,
+		hosts:  make(map[string]*hostState),
+	}
+}
+
+// order returns addrs in random order, with hosts in a backoff window last.
+func (b *hostBackoff) order(addrs []string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clk.Now()
+	var available, backedOff []string
+	for _, i := range rand.Perm(len(addrs)) {
+		addr := addrs[i]
+		if h, ok := b.hosts[addr]; ok && now.Before(h.until) {
+			backedOff = append(backedOff, addr)
+		} else {
+			available = append(available, addr)
+		}
+	}
+	return append(available, backedOff...)
+}
+
+// failed starts a new backoff window for addr.
+func (b *hostBackoff) failed(addr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h, ok := b.hosts[addr]
+	if !ok {
+		h = &hostState{}
+		b.hosts[addr] = h
+	}
+	window := b.config.Initial << uint(h.failures)
+	if window <= 0 || window > b.config.Max {
+		window = b.config.Max
+	} else {
+		h.failures++
+	}
+	h.until = b.clk.Now().Add(window)
+}
+
+// succeeded clears the backoff of addr.
+func (b *hostBackoff) succeeded(addr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.hosts, addr)
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostBackoffOrdersBackedOffHostsLast(t *testing.T) {
+	require := require.New(t)
+
+	b := newHostBackoff(BackoffConfig{}, clock.NewMock())
+
+	b.failed("a")
+
+	for i := 0; i < 10; i++ {
+		addrs := b.order([]string{"a", "b", "c"})
+		require.Len(addrs, 3)
+		require.Equal("a", addrs[2])
+	}
+
+	b.succeeded("a")
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		seen[b.order([]string{"a", "b", "c"})[2]] = true
+	}
+	require.True(seen["a"])
+}
+
+func TestHostBackoffWindowGrowsExponentially(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	b := newHostBackoff(BackoffConfig{Initial: time.Second, Max: 3 * time.Second}, clk)
+
+	for _, window := range []time.Duration{
+		time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second,
+	} {
+		b.failed("a")
+		require.Equal(clk.Now().Add(window), b.hosts["a"].until)
+		clk.Add(window)
+		require.Equal("a", b.order([]string{"a"})[0])
+	}
+}
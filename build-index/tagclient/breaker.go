@@ -0,0 +1,163 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
+)
+
+// ErrCircuitOpen is returned by cluster client requests which are rejected
+// because the build-index circuit breaker is open. It is a kind of
+// ErrServerUnavailable.
+var ErrCircuitOpen error = &Error{
+	ErrServerUnavailable, errors.New("build-index circuit breaker is open")}
+
+// CircuitBreakerConfig defines a circuit breaker around build-index requests.
+// After Failures consecutive failed requests, the breaker opens and rejects
+// all requests for Cooldown. The breaker then lets through up to
+// HalfOpenRequests probe requests, closing again if a probe succeeds and
+// re-opening if one fails.
+type CircuitBreakerConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	Failures         int           `yaml:"failures"`
+	Cooldown         time.Duration `yaml:"cooldown"`
+	HalfOpenRequests int           `yaml:"half_open_requests"`
+}
+
+func (c CircuitBreakerConfig) applyDefaults() CircuitBreakerConfig {
+	if c.Failures == 0 {
+		c.Failures = 5
+	}
+	if c.Cooldown == 0 {
+		c.Cooldown = 10 * time.Second
+	}
+	if c.HalfOpenRequests == 0 {
+		c.HalfOpenRequests = 1
+	}
+	return c
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// circuitBreaker tracks the health of the build-index cluster as a whole.
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+	clk    clock.Clock
+	stats  tally.Scope
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+func newCircuitBreaker(
+	config CircuitBreakerConfig, clk clock.Clock, stats tally.Scope) *circuitBreaker {
+
+	b := &circuitBreaker{
+		config: config.applyDefaults(),
+		clk:    clk,
+		stats:  stats.SubScope("circuit_breaker"),
+	}
+	b.emitState()
+	return b
+}
+
+// allow returns whether a request may be sent. Every allowed request must be
+// followed by a call to record.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerOpen {
+		if b.clk.Now().Before(b.openedAt.Add(b.config.Cooldown)) {
+			b.stats.Counter("rejected").Inc(1)
+			return false
+		}
+		b.transition(breakerHalfOpen)
+	}
+	if b.state == breakerHalfOpen {
+		if b.probes >= b.config.HalfOpenRequests {
+			b.stats.Counter("rejected").Inc(1)
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+// record records the result of an allowed request.
+func (b *circuitBreaker) record(healthy bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerClosed:
+		if healthy {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.config.Failures {
+			b.transition(breakerOpen)
+		}
+	case breakerHalfOpen:
+		b.probes--
+		if healthy {
+			b.transition(breakerClosed)
+		} else {
+			b.transition(breakerOpen)
+		}
+	}
+}
+
+func (b *circuitBreaker) transition(s breakerState) {
+	b.state = s
+	b.failures = 0
+	b.probes = 0
+	if s == breakerOpen {
+		b.openedAt = b.clk.Now()
+	}
+	b.stats.Tagged(map[string]string{"state": s.String()}).Counter("transitions").Inc(1)
+	b.emitState()
+}
+
+func (b *circuitBreaker) emitState() {
+	b.stats.Gauge("state").Update(float64(b.state))
+}
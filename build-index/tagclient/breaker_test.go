@@ -0,0 +1,78 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func TestCircuitBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	require := require.New(t)
+
+	b := newCircuitBreaker(CircuitBreakerConfig{Failures: 3}, clock.NewMock(), tally.NoopScope)
+
+	for i := 0; i < 2; i++ {
+		require.True(b.allow())
+		b.record(false)
+	}
+
+	// A success resets the failure count.
+	require.True(b.allow())
+	b.record(true)
+
+	for i := 0; i < 3; i++ {
+		require.True(b.allow())
+		b.record(false)
+	}
+	require.Equal(breakerOpen, b.state)
+	require.False(b.allow())
+}
+
+func TestCircuitBreakerHalfOpenProbe(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	config := CircuitBreakerConfig{Failures: 1, Cooldown: 10 * time.Second, HalfOpenRequests: 1}
+	b := newCircuitBreaker(config, clk, tally.NoopScope)
+
+	require.True(b.allow())
+	b.record(false)
+	require.False(b.allow())
+
+	clk.Add(config.Cooldown)
+
+	// Only a single probe is allowed through while half-open.
+	require.True(b.allow())
+	require.Equal(breakerHalfOpen, b.state)
+	require.False(b.allow())
+
+	// Failed probe re-opens the breaker for another cooldown.
+	b.record(false)
+	require.Equal(breakerOpen, b.state)
+	require.False(b.allow())
+
+	clk.Add(config.Cooldown)
+
+	// Successful probe closes the breaker.
+	require.True(b.allow())
+	b.record(true)
+	require.Equal(breakerClosed, b.state)
+	require.True(b.allow())
+	require.True(b.allow())
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagclient
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/andres-erbsen/clock"
+)
+
+// CacheConfig defines the in-memory tag cache of a cluster client. Since tags
+// are mutable, TTL should be kept short.
+type CacheConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	TTL        time.Duration `yaml:"ttl"`
+	MaxEntries int           `yaml:"max_entries"`
+}
+
+func (c CacheConfig) applyDefaults() CacheConfig {
+	if c.TTL == 0 {
+		c.TTL = 5 * time.Second
+	}
+	if c.MaxEntries == 0 {
+		c.MaxEntries = 10000
+	}
+	return c
+}
+
+type cacheEntry struct {
+	tag     string
+	d       core.Digest
+	expires time.Time
+}
+
+// tagCache is an LRU cache of tag to digest whose entries expire after a TTL.
+type tagCache struct {
+	config CacheConfig
+	clk    clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func newTagCache(config CacheConfig, clk clock.Clock) *tagCache {
+	return &tagCache{
+		config:  config.applyDefaults(),
+		clk:     clk,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// get returns the cached digest of tag, if present and unexpired.
+func (c *tagCache) get(tag string) (core.Digest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[tag]
+	if !ok {
+		return core.Digest{}, false
+	}
+	entry := e.Value.(*cacheEntry)
+	if !c.clk.Now().Before(entry.expires) {
+		c.lru.Remove(e)
+		delete(c.entries, tag)
+		return core.Digest{}, false
+	}
+	c.lru.MoveToFront(e)
+	return entry.d, true
+}
+
+// set caches d as the digest of tag, evicting the least recently used entry
+// if the cache is full.
+func (c *tagCache) set(tag string, d core.Digest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.clk.Now().Add(c.config.TTL)
+	if e, ok := c.entries[tag]; ok {
+		entry := e.Value.(*cacheEntry)
+		entry.d = d
+		entry.expires = expires
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[tag] = c.lru.PushFront(&cacheEntry{tag, d, expires})
+	for c.lru.Len() > c.config.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).tag)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagCacheExpiresEntries(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	c := newTagCache(CacheConfig{TTL: time.Minute}, clk)
+
+	d := core.DigestFixture()
+	c.set("repo:tag", d)
+
+	result, ok := c.get("repo:tag")
+	require.True(ok)
+	require.Equal(d, result)
+
+	clk.Add(time.Minute)
+
+	_, ok = c.get("repo:tag")
+	require.False(ok)
+}
+
+func TestTagCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	require := require.New(t)
+
+	c := newTagCache(CacheConfig{MaxEntries: 2}, clock.NewMock())
+
+	c.set("a", core.DigestFixture())
+	c.set("b", core.DigestFixture())
+
+	// Touch a so that b is evicted.
+	_, ok := c.get("a")
+	require.True(ok)
+
+	c.set("c", core.DigestFixture())
+
+	_, ok = c.get("a")
+	require.True(ok)
+	_, ok = c.get("b")
+	require.False(ok)
+	_, ok = c.get("c")
+	require.True(ok)
+}
@@ -15,38 +15,56 @@ package tagclient
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/healthcheck"
+	"github.com/uber/kraken/lib/tracing"
+	"github.com/uber/kraken/utils/errutil"
 	"github.com/uber/kraken/utils/httputil"
-)
 
-// Client errors.
-var (
-	ErrTagNotFound = errors.New("tag not found")
+	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Client wraps tagserver endpoints.
+// _maxAttempts is the number of hosts a cluster client request is attempted
+// against before giving up.
+const _maxAttempts = 3
+
+// _getManyConcurrency limits concurrent gets when falling back from batch
+// requests.
+const _getManyConcurrency = 10
+
+// Client wraps tagserver endpoints. Requests are aborted once ctx is done.
 type Client interface {
-	Put(tag string, d core.Digest) error
-	PutAndReplicate(tag string, d core.Digest) error
-	Get(tag string) (core.Digest, error)
-	Has(tag string) (bool, error)
-	List(prefix string) ([]string, error)
-	ListRepository(repo string) ([]string, error)
-	Replicate(tag string) error
-	Origin() (string, error)
+	Put(ctx context.Context, tag string, d core.Digest) error
+	PutAndReplicate(ctx context.Context, tag string, d core.Digest) error
+	Get(ctx context.Context, tag string) (core.Digest, error)
+	GetMany(ctx context.Context, tags []string) (map[string]core.Digest, error)
+	GetUncached(ctx context.Context, tag string) (core.Digest, error)
+	ResolvePlatform(ctx context.Context, repo, tag, platform string) (core.Digest, error)
+	Watch(repo, tag string) *Watcher
+	Has(ctx context.Context, tag string) (bool, error)
+	List(ctx context.Context, prefix string) ([]string, error)
+	ListRepository(ctx context.Context, repo string) ([]string, error)
+	Replicate(ctx context.Context, tag string) error
+	Origin(ctx context.Context) (string, error)
 
 	DuplicateReplicate(
+		ctx context.Context,
 		tag string, d core.Digest, dependencies core.DigestList, delay time.Duration) error
-	DuplicatePut(tag string, d core.Digest, delay time.Duration) error
+	DuplicatePut(ctx context.Context, tag string, d core.Digest, delay time.Duration) error
 }
 
 type singleClient struct {
@@ -59,27 +77,48 @@ func NewSingleClient(addr string, config *tls.Config) Client {
 	return &singleClient{addr, config}
 }
 
-func (c *singleClient) Put(tag string, d core.Digest) error {
-	_, err := httputil.Put(
+// send sends an HTTP request which is aborted once ctx is done. Returns
+// TimeoutError if the deadline of ctx expired before the request completed,
+// and classifies other failures as ErrServerUnavailable or ErrUnauthorized.
+func (c *singleClient) send(
+	ctx context.Context, method, u string, options ...httputil.SendOption) (*http.Response, error) {
+
+	ctx, span := tracing.Start(
+		ctx, "tagclient."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("addr", c.addr)))
+	options = append(options, httputil.SendContext(ctx), httputil.SendTLS(c.tls))
+	resp, err := httputil.Send(method, u, options...)
+	tracing.End(span, err)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, TimeoutError{method, u}
+		}
+		return nil, classify(err)
+	}
+	return resp, nil
+}
+
+func (c *singleClient) Put(ctx context.Context, tag string, d core.Digest) error {
+	_, err := c.send(
+		ctx, "PUT",
 		fmt.Sprintf("http://%s/tags/%s/digest/%s", c.addr, url.PathEscape(tag), d.String()),
-		httputil.SendTimeout(30*time.Second),
-		httputil.SendTLS(c.tls))
+		httputil.SendTimeout(30*time.Second))
 	return err
 }
 
-func (c *singleClient) PutAndReplicate(tag string, d core.Digest) error {
-	_, err := httputil.Put(
-		fmt.Sprintf("http://%s/tags/%s/digest/%s?replicate=true", c.addr, url.PathEscape(tag), d.String()),
-		httputil.SendTimeout(30*time.Second),
-		httputil.SendTLS(c.tls))
+func (c *singleClient) PutAndReplicate(ctx context.Context, tag string, d core.Digest) error {
+	_, err := c.send(
+		ctx, "PUT", fmt.Sprintf(
+			"http://%s/tags/%s/digest/%s?replicate=true", c.addr, url.PathEscape(tag), d.String()),
+		httputil.SendTimeout(30*time.Second))
 	return err
 }
 
-func (c *singleClient) Get(tag string) (core.Digest, error) {
-	resp, err := httputil.Get(
-		fmt.Sprintf("http://%s/tags/%s", c.addr, url.PathEscape(tag)),
-		httputil.SendTimeout(10*time.Second),
-		httputil.SendTLS(c.tls))
+func (c *singleClient) Get(ctx context.Context, tag string) (core.Digest, error) {
+	resp, err := c.send(
+		ctx, "GET", fmt.Sprintf("http://%s/tags/%s", c.addr, url.PathEscape(tag)),
+		httputil.SendTimeout(10*time.Second))
 	if err != nil {
 		if httputil.IsNotFound(err) {
 			return core.Digest{}, ErrTagNotFound
@@ -91,18 +130,163 @@ func (c *singleClient) Get(tag string) (core.Digest, error) {
 	if err != nil {
 		return core.Digest{}, fmt.Errorf("read body: %s", err)
 	}
-	d, err := core.ParseSHA256Digest(string(b))
+	d, err := core.ParseDigest(string(b))
 	if err != nil {
 		return core.Digest{}, fmt.Errorf("new digest: %s", err)
 	}
 	return d, nil
 }
 
-func (c *singleClient) Has(tag string) (bool, error) {
-	_, err := httputil.Head(
-		fmt.Sprintf("http://%s/tags/%s", c.addr, url.PathEscape(tag)),
-		httputil.SendTimeout(10*time.Second),
-		httputil.SendTLS(c.tls))
+// GetUncached is equivalent to Get, as singleClient does not cache tags.
+func (c *singleClient) GetUncached(ctx context.Context, tag string) (core.Digest, error) {
+	return c.Get(ctx, tag)
+}
+
+// GetManyRequest defines a GetMany request body.
+type GetManyRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// GetMany resolves tags in a single batch request, returning a map of tag to
+// digest. Tags which are not found are omitted from the result. Falls back to
+// concurrent individual gets if the build-index does not support batch
+// requests.
+func (c *singleClient) GetMany(ctx context.Context, tags []string) (map[string]core.Digest, error) {
+	b, err := json.Marshal(GetManyRequest{tags})
+	if err != nil {
+		return nil, fmt.Errorf("json marshal: %s", err)
+	}
+	resp, err := c.send(
+		ctx, "POST", fmt.Sprintf("http://%s/batch/tags", c.addr),
+		httputil.SendBody(bytes.NewReader(b)),
+		httputil.SendTimeout(60*time.Second))
+	if err != nil {
+		if httputil.IsNotFound(err) {
+			return getConcurrently(func(tag string) (core.Digest, error) {
+				return c.Get(ctx, tag)
+			}, tags)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result map[string]core.Digest
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("json decode: %s", err)
+	}
+	return result, nil
+}
+
+// getConcurrently resolves tags with get using a bounded number of concurrent
+// requests.
+func getConcurrently(
+	get func(tag string) (core.Digest, error), tags []string) (map[string]core.Digest, error) {
+
+	var mu sync.Mutex
+	var firstErr error
+	result := make(map[string]core.Digest, len(tags))
+	sem := make(chan struct{}, _getManyConcurrency)
+	var wg sync.WaitGroup
+	for _, tag := range tags {
+		wg.Add(1)
+		go func(tag string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			d, err := get(tag)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				result[tag] = d
+			} else if err != ErrTagNotFound && firstErr == nil {
+				firstErr = fmt.Errorf("get %s: %s", tag, err)
+			}
+		}(tag)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+// ResolvePlatform returns the digest of the manifest of repo:tag for platform,
+// formatted as "os/arch[/variant]". If the tag refers to a manifest list, the
+// matching per-platform manifest is returned, else the tag's own manifest.
+// Returns ErrTagNotFound if the tag does not exist or has no manifest for
+// platform.
+func (c *singleClient) ResolvePlatform(
+	ctx context.Context, repo, tag, platform string) (core.Digest, error) {
+
+	resp, err := c.send(
+		ctx, "GET", fmt.Sprintf(
+			"http://%s/tags/%s/resolve?platform=%s",
+			c.addr, url.PathEscape(fmt.Sprintf("%s:%s", repo, tag)), url.QueryEscape(platform)),
+		httputil.SendTimeout(30*time.Second))
+	if err != nil {
+		if httputil.IsNotFound(err) {
+			return core.Digest{}, ErrTagNotFound
+		}
+		return core.Digest{}, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return core.Digest{}, fmt.Errorf("read body: %s", err)
+	}
+	d, err := core.ParseDigest(string(b))
+	if err != nil {
+		return core.Digest{}, fmt.Errorf("new digest: %s", err)
+	}
+	return d, nil
+}
+
+// Watch watches repo:tag for digest changes by long-polling the build-index.
+// Falls back to periodic polling if the build-index does not support watch.
+func (c *singleClient) Watch(repo, tag string) *Watcher {
+	t := fmt.Sprintf("%s:%s", repo, tag)
+	return newWatcher(
+		func(last core.Digest) (core.Digest, error) { return c.watch(context.Background(), t, last) },
+		func() (core.Digest, error) { return c.Get(context.Background(), t) },
+		_watchPollInterval)
+}
+
+func (c *singleClient) watch(
+	ctx context.Context, tag string, last core.Digest) (core.Digest, error) {
+
+	u := fmt.Sprintf(
+		"http://%s/tags/%s/watch?timeout=%s", c.addr, url.PathEscape(tag), _watchTimeout)
+	if last.Hex() != "" {
+		u += "&digest=" + url.QueryEscape(last.String())
+	}
+	resp, err := c.send(
+		ctx, "GET", u,
+		httputil.SendTimeout(_watchTimeout+10*time.Second),
+		httputil.SendAcceptedCodes(http.StatusOK, http.StatusNotModified))
+	if err != nil {
+		if httputil.IsNotFound(err) {
+			return core.Digest{}, errWatchUnsupported
+		}
+		return core.Digest{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return last, nil
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return core.Digest{}, fmt.Errorf("read body: %s", err)
+	}
+	d, err := core.ParseDigest(string(b))
+	if err != nil {
+		return core.Digest{}, fmt.Errorf("new digest: %s", err)
+	}
+	return d, nil
+}
+
+func (c *singleClient) Has(ctx context.Context, tag string) (bool, error) {
+	_, err := c.send(
+		ctx, "HEAD", fmt.Sprintf("http://%s/tags/%s", c.addr, url.PathEscape(tag)),
+		httputil.SendTimeout(10*time.Second))
 	if err != nil {
 		if httputil.IsNotFound(err) {
 			return false, nil
@@ -112,11 +296,10 @@ func (c *singleClient) Has(tag string) (bool, error) {
 	return true, nil
 }
 
-func (c *singleClient) List(prefix string) ([]string, error) {
-	resp, err := httputil.Get(
-		fmt.Sprintf("http://%s/list/%s", c.addr, prefix),
-		httputil.SendTimeout(60*time.Second),
-		httputil.SendTLS(c.tls))
+func (c *singleClient) List(ctx context.Context, prefix string) ([]string, error) {
+	resp, err := c.send(
+		ctx, "GET", fmt.Sprintf("http://%s/list/%s", c.addr, prefix),
+		httputil.SendTimeout(60*time.Second))
 	if err != nil {
 		return nil, err
 	}
@@ -129,11 +312,10 @@ func (c *singleClient) List(prefix string) ([]string, error) {
 }
 
 // XXX: Deprecated. Use List instead.
-func (c *singleClient) ListRepository(repo string) ([]string, error) {
-	resp, err := httputil.Get(
-		fmt.Sprintf("http://%s/repositories/%s/tags", c.addr, url.PathEscape(repo)),
-		httputil.SendTimeout(60*time.Second),
-		httputil.SendTLS(c.tls))
+func (c *singleClient) ListRepository(ctx context.Context, repo string) ([]string, error) {
+	resp, err := c.send(
+		ctx, "GET", fmt.Sprintf("http://%s/repositories/%s/tags", c.addr, url.PathEscape(repo)),
+		httputil.SendTimeout(60*time.Second))
 	if err != nil {
 		return nil, err
 	}
@@ -150,11 +332,10 @@ type ReplicateRequest struct {
 	Dependencies []core.Digest `json:"dependencies"`
 }
 
-func (c *singleClient) Replicate(tag string) error {
-	_, err := httputil.Post(
-		fmt.Sprintf("http://%s/remotes/tags/%s", c.addr, url.PathEscape(tag)),
-		httputil.SendTimeout(15*time.Second),
-		httputil.SendTLS(c.tls))
+func (c *singleClient) Replicate(ctx context.Context, tag string) error {
+	_, err := c.send(
+		ctx, "POST", fmt.Sprintf("http://%s/remotes/tags/%s", c.addr, url.PathEscape(tag)),
+		httputil.SendTimeout(15*time.Second))
 	return err
 }
 
@@ -165,20 +346,20 @@ type DuplicateReplicateRequest struct {
 }
 
 func (c *singleClient) DuplicateReplicate(
+	ctx context.Context,
 	tag string, d core.Digest, dependencies core.DigestList, delay time.Duration) error {
 
 	b, err := json.Marshal(DuplicateReplicateRequest{dependencies, delay})
 	if err != nil {
 		return fmt.Errorf("json marshal: %s", err)
 	}
-	_, err = httputil.Post(
-		fmt.Sprintf(
+	_, err = c.send(
+		ctx, "POST", fmt.Sprintf(
 			"http://%s/internal/duplicate/remotes/tags/%s/digest/%s",
 			c.addr, url.PathEscape(tag), d.String()),
 		httputil.SendBody(bytes.NewReader(b)),
 		httputil.SendTimeout(10*time.Second),
-		httputil.SendRetry(),
-		httputil.SendTLS(c.tls))
+		httputil.SendRetry())
 	return err
 }
 
@@ -187,27 +368,27 @@ type DuplicatePutRequest struct {
 	Delay time.Duration `json:"delay"`
 }
 
-func (c *singleClient) DuplicatePut(tag string, d core.Digest, delay time.Duration) error {
+func (c *singleClient) DuplicatePut(
+	ctx context.Context, tag string, d core.Digest, delay time.Duration) error {
+
 	b, err := json.Marshal(DuplicatePutRequest{delay})
 	if err != nil {
 		return fmt.Errorf("json marshal: %s", err)
 	}
-	_, err = httputil.Put(
-		fmt.Sprintf(
+	_, err = c.send(
+		ctx, "PUT", fmt.Sprintf(
 			"http://%s/internal/duplicate/tags/%s/digest/%s",
 			c.addr, url.PathEscape(tag), d.String()),
 		httputil.SendBody(bytes.NewReader(b)),
 		httputil.SendTimeout(10*time.Second),
-		httputil.SendRetry(),
-		httputil.SendTLS(c.tls))
+		httputil.SendRetry())
 	return err
 }
 
-func (c *singleClient) Origin() (string, error) {
-	resp, err := httputil.Get(
-		fmt.Sprintf("http://%s/origin", c.addr),
-		httputil.SendTimeout(5*time.Second),
-		httputil.SendTLS(c.tls))
+func (c *singleClient) Origin(ctx context.Context) (string, error) {
+	resp, err := c.send(
+		ctx, "GET", fmt.Sprintf("http://%s/origin", c.addr),
+		httputil.SendTimeout(5*time.Second))
 	if err != nil {
 		return "", err
 	}
@@ -220,91 +401,292 @@ func (c *singleClient) Origin() (string, error) {
 }
 
 type clusterClient struct {
-	hosts healthcheck.List
-	tls   *tls.Config
+	hosts   healthcheck.List
+	tls     *tls.Config
+	timeout time.Duration
+	cache   *tagCache
+	backoff *hostBackoff
+	breaker *circuitBreaker
+}
+
+// Option allows setting optional clusterClient parameters.
+type Option func(*clusterClient)
+
+// WithCache configures a cluster client to cache resolved tags in memory.
+// Cached digests are returned by Get and GetMany until they expire. Has no
+// effect if config is not enabled.
+func WithCache(config CacheConfig) Option {
+	return func(cc *clusterClient) {
+		if config.Enabled {
+			cc.cache = newTagCache(config, clock.New())
+		}
+	}
+}
+
+// WithBackoff configures how long a cluster client skips hosts which failed
+// with network errors.
+func WithBackoff(config BackoffConfig) Option {
+	return func(cc *clusterClient) { cc.backoff = newHostBackoff(config, clock.New()) }
+}
+
+// WithCircuitBreaker configures a cluster client to stop sending requests to
+// the build-index for a cooldown after consecutive failures, failing fast with
+// ErrCircuitOpen instead. Has no effect if config is not enabled.
+func WithCircuitBreaker(config CircuitBreakerConfig, stats tally.Scope) Option {
+	return func(cc *clusterClient) {
+		if config.Enabled {
+			cc.breaker = newCircuitBreaker(config, clock.New(), stats.Tagged(map[string]string{
+				"module": "tagclient",
+			}))
+		}
+	}
+}
+
+// WithTimeout bounds the total duration of cluster client requests, including
+// attempts against multiple hosts. Requests which exceed timeout fail with
+// TimeoutError. Watch long-polls are not affected. Has no effect if timeout
+// is 0.
+func WithTimeout(timeout time.Duration) Option {
+	return func(cc *clusterClient) { cc.timeout = timeout }
 }
 
 // NewClusterClient creates a Client which operates on tagserver instances as
-// a cluster.
-func NewClusterClient(hosts healthcheck.List, config *tls.Config) Client {
-	return &clusterClient{hosts, config}
+// a cluster. Requests are spread randomly across hosts, skipping hosts which
+// recently failed with network errors.
+func NewClusterClient(hosts healthcheck.List, config *tls.Config, opts ...Option) Client {
+	cc := &clusterClient{
+		hosts:   hosts,
+		tls:     config,
+		backoff: newHostBackoff(BackoffConfig{}, clock.New()),
+	}
+	for _, opt := range opts {
+		opt(cc)
+	}
+	return cc
+}
+
+// clusterRequest runs a request against a single tagserver instance.
+type clusterRequest func(ctx context.Context, c Client) error
+
+// do runs request under the configured timeout. See run.
+func (cc *clusterClient) do(ctx context.Context, request clusterRequest) error {
+	if cc.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cc.timeout)
+		defer cancel()
+	}
+	return cc.run(ctx, request)
+}
+
+// run runs request against up to _maxAttempts hosts, moving on to the next
+// host only on network errors. If every attempt fails, returns an error naming
+// each host tried. If a circuit breaker is configured, requests are rejected
+// with ErrCircuitOpen while the breaker is open.
+func (cc *clusterClient) run(ctx context.Context, request clusterRequest) error {
+	if cc.breaker == nil {
+		_, err := cc.attempt(ctx, request)
+		return err
+	}
+	if !cc.breaker.allow() {
+		return ErrCircuitOpen
+	}
+	healthy, err := cc.attempt(ctx, request)
+	cc.breaker.record(healthy)
+	return err
 }
 
-func (cc *clusterClient) do(request func(c Client) error) error {
-	addrs := cc.hosts.Resolve().Sample(3)
+// attempt runs request against the cluster. Returns whether a host responded
+// without a network or server error, along with the result of request.
+func (cc *clusterClient) attempt(ctx context.Context, request clusterRequest) (bool, error) {
+	addrs := cc.backoff.order(cc.hosts.Resolve().ToSlice())
 	if len(addrs) == 0 {
-		return errors.New("cluster client: no hosts could be resolved")
+		return false, &Error{
+			ErrServerUnavailable, errors.New("cluster client: no hosts could be resolved")}
 	}
-	var err error
-	for addr := range addrs {
-		err = request(NewSingleClient(addr, cc.tls))
-		if httputil.IsNetworkError(err) {
+	if len(addrs) > _maxAttempts {
+		addrs = addrs[:_maxAttempts]
+	}
+	var errs []error
+	for _, addr := range addrs {
+		err := request(ctx, NewSingleClient(addr, cc.tls))
+		if _, ok := err.(TimeoutError); ok {
+			// The deadline is shared by all attempts, so there is no time
+			// left to try another host.
+			cc.hosts.Failed(addr)
+			cc.backoff.failed(addr)
+			return false, err
+		}
+		if err != nil && ctx.Err() == context.Canceled {
+			// The caller gave up, which says nothing about the host.
+			return true, ctx.Err()
+		}
+		if httputil.IsNetworkError(cause(err)) {
 			cc.hosts.Failed(addr)
+			cc.backoff.failed(addr)
+			errs = append(errs, fmt.Errorf("%s: %s", addr, err))
 			continue
 		}
-		break
+		cc.backoff.succeeded(addr)
+		return !isServerError(cause(err)), err
 	}
-	return err
+	return false, &Error{
+		ErrServerUnavailable,
+		fmt.Errorf("cluster client: all hosts failed: %s", errutil.Join(errs)),
+	}
+}
+
+func (cc *clusterClient) Put(ctx context.Context, tag string, d core.Digest) error {
+	err := cc.do(ctx, func(ctx context.Context, c Client) error { return c.Put(ctx, tag, d) })
+	if err != nil {
+		return err
+	}
+	cc.cacheTag(tag, d)
+	return nil
+}
+
+func (cc *clusterClient) PutAndReplicate(ctx context.Context, tag string, d core.Digest) error {
+	err := cc.do(ctx, func(ctx context.Context, c Client) error {
+		return c.PutAndReplicate(ctx, tag, d)
+	})
+	if err != nil {
+		return err
+	}
+	cc.cacheTag(tag, d)
+	return nil
+}
+
+func (cc *clusterClient) Get(ctx context.Context, tag string) (core.Digest, error) {
+	if cc.cache != nil {
+		if d, ok := cc.cache.get(tag); ok {
+			return d, nil
+		}
+	}
+	return cc.GetUncached(ctx, tag)
+}
+
+// GetUncached resolves tag from the build-index, bypassing the cache, and
+// caches the result.
+func (cc *clusterClient) GetUncached(ctx context.Context, tag string) (d core.Digest, err error) {
+	err = cc.do(ctx, func(ctx context.Context, c Client) error {
+		d, err = c.Get(ctx, tag)
+		return err
+	})
+	if err == nil {
+		cc.cacheTag(tag, d)
+	}
+	return
 }
 
-func (cc *clusterClient) Put(tag string, d core.Digest) error {
-	return cc.do(func(c Client) error { return c.Put(tag, d) })
+func (cc *clusterClient) cacheTag(tag string, d core.Digest) {
+	if cc.cache != nil {
+		cc.cache.set(tag, d)
+	}
 }
 
-func (cc *clusterClient) PutAndReplicate(tag string, d core.Digest) error {
-	return cc.do(func(c Client) error { return c.PutAndReplicate(tag, d) })
+func (cc *clusterClient) GetMany(
+	ctx context.Context, tags []string) (map[string]core.Digest, error) {
+
+	result := make(map[string]core.Digest, len(tags))
+	var misses []string
+	for _, tag := range tags {
+		if cc.cache != nil {
+			if d, ok := cc.cache.get(tag); ok {
+				result[tag] = d
+				continue
+			}
+		}
+		misses = append(misses, tag)
+	}
+	if len(misses) == 0 {
+		return result, nil
+	}
+	var resolved map[string]core.Digest
+	err := cc.do(ctx, func(ctx context.Context, c Client) (err error) {
+		resolved, err = c.GetMany(ctx, misses)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for tag, d := range resolved {
+		cc.cacheTag(tag, d)
+		result[tag] = d
+	}
+	return result, nil
 }
 
-func (cc *clusterClient) Get(tag string) (d core.Digest, err error) {
-	err = cc.do(func(c Client) error {
-		d, err = c.Get(tag)
+func (cc *clusterClient) ResolvePlatform(
+	ctx context.Context, repo, tag, platform string) (d core.Digest, err error) {
+
+	err = cc.do(ctx, func(ctx context.Context, c Client) error {
+		d, err = c.ResolvePlatform(ctx, repo, tag, platform)
 		return err
 	})
 	return
 }
 
-func (cc *clusterClient) Has(tag string) (ok bool, err error) {
-	err = cc.do(func(c Client) error {
-		ok, err = c.Has(tag)
+func (cc *clusterClient) Watch(repo, tag string) *Watcher {
+	t := fmt.Sprintf("%s:%s", repo, tag)
+	return newWatcher(
+		func(last core.Digest) (d core.Digest, err error) {
+			// Long-polls are bounded by the watch timeout instead.
+			err = cc.run(context.Background(), func(ctx context.Context, c Client) error {
+				d, err = c.(*singleClient).watch(ctx, t, last)
+				return err
+			})
+			return
+		},
+		func() (core.Digest, error) { return cc.Get(context.Background(), t) },
+		_watchPollInterval)
+}
+
+func (cc *clusterClient) Has(ctx context.Context, tag string) (ok bool, err error) {
+	err = cc.do(ctx, func(ctx context.Context, c Client) error {
+		ok, err = c.Has(ctx, tag)
 		return err
 	})
 	return
 }
 
-func (cc *clusterClient) List(prefix string) (tags []string, err error) {
-	err = cc.do(func(c Client) error {
-		tags, err = c.List(prefix)
+func (cc *clusterClient) List(ctx context.Context, prefix string) (tags []string, err error) {
+	err = cc.do(ctx, func(ctx context.Context, c Client) error {
+		tags, err = c.List(ctx, prefix)
 		return err
 	})
 	return
 }
 
-func (cc *clusterClient) ListRepository(repo string) (tags []string, err error) {
-	err = cc.do(func(c Client) error {
-		tags, err = c.ListRepository(repo)
+func (cc *clusterClient) ListRepository(
+	ctx context.Context, repo string) (tags []string, err error) {
+
+	err = cc.do(ctx, func(ctx context.Context, c Client) error {
+		tags, err = c.ListRepository(ctx, repo)
 		return err
 	})
 	return
 }
 
-func (cc *clusterClient) Replicate(tag string) error {
-	return cc.do(func(c Client) error { return c.Replicate(tag) })
+func (cc *clusterClient) Replicate(ctx context.Context, tag string) error {
+	return cc.do(ctx, func(ctx context.Context, c Client) error { return c.Replicate(ctx, tag) })
 }
 
-func (cc *clusterClient) Origin() (origin string, err error) {
-	err = cc.do(func(c Client) error {
-		origin, err = c.Origin()
+func (cc *clusterClient) Origin(ctx context.Context) (origin string, err error) {
+	err = cc.do(ctx, func(ctx context.Context, c Client) error {
+		origin, err = c.Origin(ctx)
 		return err
 	})
 	return
 }
 
 func (cc *clusterClient) DuplicateReplicate(
+	ctx context.Context,
 	tag string, d core.Digest, dependencies core.DigestList, delay time.Duration) error {
 
 	return errors.New("duplicate replicate not supported on cluster client")
 }
 
-func (cc *clusterClient) DuplicatePut(tag string, d core.Digest, delay time.Duration) error {
+func (cc *clusterClient) DuplicatePut(
+	ctx context.Context, tag string, d core.Digest, delay time.Duration) error {
+
 	return errors.New("duplicate put not supported on cluster client")
 }
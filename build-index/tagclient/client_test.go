@@ -0,0 +1,276 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/healthcheck"
+	"github.com/uber/kraken/lib/hostlist"
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/pressly/chi"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func TestGetManyFallsBackToConcurrentGets(t *testing.T) {
+	require := require.New(t)
+
+	tags := map[string]core.Digest{
+		"repo:a": core.DigestFixture(),
+		"repo:b": core.DigestFixture(),
+	}
+
+	// Build-index without the batch endpoint.
+	r := chi.NewRouter()
+	r.Get("/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		d, ok := tags[chi.URLParam(r, "tag")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, d.String())
+	})
+	addr, stop := testutil.StartServer(r)
+	defer stop()
+
+	client := NewSingleClient(addr, nil)
+
+	result, err := client.GetMany(context.Background(), []string{"repo:a", "repo:b", "repo:missing"})
+	require.NoError(err)
+	require.Equal(tags, result)
+}
+
+func TestClusterClientCache(t *testing.T) {
+	require := require.New(t)
+
+	d := core.DigestFixture()
+	var gets int32
+
+	r := chi.NewRouter()
+	r.Get("/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&gets, 1)
+		io.WriteString(w, d.String())
+	})
+	addr, stop := testutil.StartServer(r)
+	defer stop()
+
+	client := NewClusterClient(
+		healthcheck.NoopFailed(hostlist.Fixture(addr)), nil,
+		WithCache(CacheConfig{Enabled: true, TTL: time.Minute}))
+
+	for i := 0; i < 3; i++ {
+		result, err := client.Get(context.Background(), "repo:tag")
+		require.NoError(err)
+		require.Equal(d, result)
+	}
+	require.Equal(int32(1), atomic.LoadInt32(&gets))
+
+	result, err := client.GetUncached(context.Background(), "repo:tag")
+	require.NoError(err)
+	require.Equal(d, result)
+	require.Equal(int32(2), atomic.LoadInt32(&gets))
+}
+
+// deadAddr returns the address of a server which is no longer listening.
+func deadAddr() string {
+	addr, stop := testutil.StartServer(chi.NewRouter())
+	stop()
+	return addr
+}
+
+func TestClusterClientFailsOverToHealthyHost(t *testing.T) {
+	require := require.New(t)
+
+	d := core.DigestFixture()
+
+	r := chi.NewRouter()
+	r.Get("/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, d.String())
+	})
+	addr, stop := testutil.StartServer(r)
+	defer stop()
+
+	client := NewClusterClient(
+		healthcheck.NoopFailed(hostlist.Fixture(deadAddr(), addr)), nil)
+
+	for i := 0; i < 5; i++ {
+		result, err := client.Get(context.Background(), "repo:tag")
+		require.NoError(err)
+		require.Equal(d, result)
+	}
+}
+
+func TestClusterClientAggregatesErrorsOnTotalFailure(t *testing.T) {
+	require := require.New(t)
+
+	addr1 := deadAddr()
+	addr2 := deadAddr()
+
+	client := NewClusterClient(healthcheck.NoopFailed(hostlist.Fixture(addr1, addr2)), nil)
+
+	_, err := client.Get(context.Background(), "repo:tag")
+	require.Error(err)
+	require.Contains(err.Error(), addr1)
+	require.Contains(err.Error(), addr2)
+}
+
+func TestClusterClientCircuitBreakerOpensOnServerErrors(t *testing.T) {
+	require := require.New(t)
+
+	var gets int32
+
+	r := chi.NewRouter()
+	r.Get("/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&gets, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	addr, stop := testutil.StartServer(r)
+	defer stop()
+
+	client := NewClusterClient(
+		healthcheck.NoopFailed(hostlist.Fixture(addr)), nil,
+		WithCircuitBreaker(CircuitBreakerConfig{
+			Enabled:  true,
+			Failures: 2,
+			Cooldown: time.Minute,
+		}, tally.NoopScope))
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Get(context.Background(), "repo:tag")
+		require.Error(err)
+		require.NotEqual(ErrCircuitOpen, err)
+	}
+
+	_, err := client.Get(context.Background(), "repo:tag")
+	require.Equal(ErrCircuitOpen, err)
+	require.Equal(int32(2), atomic.LoadInt32(&gets))
+}
+
+func TestClusterClientCircuitBreakerIgnoresNotFound(t *testing.T) {
+	require := require.New(t)
+
+	r := chi.NewRouter()
+	r.Get("/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	addr, stop := testutil.StartServer(r)
+	defer stop()
+
+	client := NewClusterClient(
+		healthcheck.NoopFailed(hostlist.Fixture(addr)), nil,
+		WithCircuitBreaker(CircuitBreakerConfig{Enabled: true, Failures: 1}, tally.NoopScope))
+
+	for i := 0; i < 3; i++ {
+		_, err := client.Get(context.Background(), "repo:tag")
+		require.Equal(ErrTagNotFound, err)
+	}
+}
+
+func TestClusterClientTimeout(t *testing.T) {
+	require := require.New(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	r := chi.NewRouter()
+	r.Get("/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	})
+	addr, stop := testutil.StartServer(r)
+	defer stop()
+
+	client := NewClusterClient(
+		healthcheck.NoopFailed(hostlist.Fixture(addr)), nil,
+		WithTimeout(100*time.Millisecond))
+
+	start := time.Now()
+	_, err := client.Get(context.Background(), "repo:tag")
+	require.IsType(TimeoutError{}, err)
+	require.True(time.Since(start) < 5*time.Second)
+}
+
+func TestClusterClientCancel(t *testing.T) {
+	require := require.New(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	r := chi.NewRouter()
+	r.Get("/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	})
+	addr, stop := testutil.StartServer(r)
+	defer stop()
+
+	client := NewClusterClient(healthcheck.NoopFailed(hostlist.Fixture(addr)), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	_, err := client.Get(ctx, "repo:tag")
+	require.Equal(context.Canceled, err)
+}
+
+func TestSingleClientClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		desc         string
+		status       int
+		unavailable  bool
+		unauthorized bool
+	}{
+		{"server error", http.StatusInternalServerError, true, false},
+		{"unauthorized", http.StatusUnauthorized, false, true},
+		{"forbidden", http.StatusForbidden, false, true},
+		{"bad request", http.StatusBadRequest, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			r := chi.NewRouter()
+			r.Get("/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+			})
+			addr, stop := testutil.StartServer(r)
+			defer stop()
+
+			_, err := NewSingleClient(addr, nil).Get(context.Background(), "repo:tag")
+			require.Error(err)
+			require.Equal(test.unavailable, IsServerUnavailable(err))
+			require.Equal(test.unauthorized, IsUnauthorized(err))
+		})
+	}
+}
+
+func TestClusterClientTotalFailureIsServerUnavailable(t *testing.T) {
+	client := NewClusterClient(healthcheck.NoopFailed(hostlist.Fixture(deadAddr())), nil)
+
+	_, err := client.Get(context.Background(), "repo:tag")
+	require.True(t, IsServerUnavailable(err))
+	require.False(t, IsServerUnavailable(ErrTagNotFound))
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/uber/kraken/utils/httputil"
+)
+
+// Client errors.
+var (
+	ErrTagNotFound = errors.New("tag not found")
+
+	// ErrServerUnavailable is the kind of errors caused by the build-index
+	// being unreachable or failing with 5XX responses.
+	ErrServerUnavailable = errors.New("build-index unavailable")
+
+	// ErrUnauthorized is the kind of errors caused by the build-index
+	// rejecting the client with 401 or 403 responses.
+	ErrUnauthorized = errors.New("build-index unauthorized")
+)
+
+// Error is a failed request of some kind, i.e. ErrServerUnavailable or
+// ErrUnauthorized, caused by Cause.
+type Error struct {
+	Kind  error
+	Cause error
+}
+
+func (e *Error) Error() string {
+	return e.Cause.Error()
+}
+
+// Is returns true if target is the kind of e.
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+// Unwrap returns the cause of e.
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// TimeoutError occurs when a request is abandoned because the deadline of its
+// context expired. It is a kind of ErrServerUnavailable.
+type TimeoutError struct {
+	Method string
+	URL    string
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("build-index request timed out: %s %s", e.Method, e.URL)
+}
+
+// Is returns true if target is ErrServerUnavailable.
+func (e TimeoutError) Is(target error) bool {
+	return target == ErrServerUnavailable
+}
+
+// IsServerUnavailable returns true if err is a kind of ErrServerUnavailable.
+func IsServerUnavailable(err error) bool {
+	return isKind(err, ErrServerUnavailable)
+}
+
+// IsUnauthorized returns true if err is a kind of ErrUnauthorized.
+func IsUnauthorized(err error) bool {
+	return isKind(err, ErrUnauthorized)
+}
+
+// isKind returns true if err, or any error it wraps via an Unwrap method, is
+// kind or reports being of kind via an Is method.
+func isKind(err, kind error) bool {
+	for err != nil {
+		if err == kind {
+			return true
+		}
+		if k, ok := err.(interface{ Is(error) bool }); ok && k.Is(kind) {
+			return true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+		err = u.Unwrap()
+	}
+	return false
+}
+
+// classify wraps err with its kind, if known.
+func classify(err error) error {
+	switch {
+	case httputil.IsNetworkError(err), isServerError(err):
+		return &Error{ErrServerUnavailable, err}
+	case httputil.IsStatus(err, http.StatusUnauthorized), httputil.IsForbidden(err):
+		return &Error{ErrUnauthorized, err}
+	}
+	return err
+}
+
+// cause returns the cause of err if err is an Error, else err.
+func cause(err error) error {
+	if e, ok := err.(*Error); ok {
+		return e.Cause
+	}
+	return err
+}
+
+// isServerError returns true if err is a 5XX StatusError.
+func isServerError(err error) bool {
+	serr, ok := err.(httputil.StatusError)
+	return ok && serr.Status >= 500
+}
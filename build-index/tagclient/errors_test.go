@@ -0,0 +1,51 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type wrappedError struct {
+	cause error
+}
+
+func (e wrappedError) Error() string { return "wrapped: " + e.cause.Error() }
+
+func (e wrappedError) Unwrap() error { return e.cause }
+
+func TestIsServerUnavailable(t *testing.T) {
+	tests := []struct {
+		desc     string
+		err      error
+		expected bool
+	}{
+		{"kind", ErrServerUnavailable, true},
+		{"error of kind", &Error{ErrServerUnavailable, errors.New("some error")}, true},
+		{"timeout", TimeoutError{"GET", "http://build-index"}, true},
+		{"wrapped error of kind", wrappedError{ErrCircuitOpen}, true},
+		{"wrapped timeout", wrappedError{wrappedError{TimeoutError{"GET", "http://build-index"}}}, true},
+		{"other kind", &Error{ErrUnauthorized, errors.New("some error")}, false},
+		{"wrapped other error", wrappedError{errors.New("some error")}, false},
+		{"nil", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require.Equal(t, test.expected, IsServerUnavailable(test.err))
+		})
+	}
+}
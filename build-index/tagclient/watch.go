@@ -0,0 +1,114 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/core"
+)
+
+const (
+	_watchTimeout      = 30 * time.Second
+	_watchPollInterval = 30 * time.Second
+)
+
+// errWatchUnsupported is returned when the build-index does not support
+// watching tags.
+var errWatchUnsupported = errors.New("watch not supported")
+
+// Watcher delivers digest changes of a tag. The current digest of the tag is
+// delivered first, followed by each subsequent change. Notifications which
+// are not received before the next change are dropped in favor of the newer
+// digest.
+type Watcher struct {
+	C <-chan core.Digest
+
+	c    chan core.Digest
+	done chan struct{}
+	once sync.Once
+}
+
+// watchFunc long-polls for a digest of a tag other than last, returning last
+// if the tag did not change before the poll timed out.
+type watchFunc func(last core.Digest) (core.Digest, error)
+
+// getFunc returns the current digest of a tag.
+type getFunc func() (core.Digest, error)
+
+// newWatcher starts a Watcher which long-polls via watch, falling back to
+// polling get every pollInterval if watch is unsupported.
+func newWatcher(watch watchFunc, get getFunc, pollInterval time.Duration) *Watcher {
+	c := make(chan core.Digest, 1)
+	w := &Watcher{C: c, c: c, done: make(chan struct{})}
+	go w.run(watch, get, pollInterval)
+	return w
+}
+
+// Stop stops w from polling the build-index.
+func (w *Watcher) Stop() {
+	w.once.Do(func() { close(w.done) })
+}
+
+func (w *Watcher) run(watch watchFunc, get getFunc, pollInterval time.Duration) {
+	var last core.Digest
+	supported := true
+	for {
+		var d core.Digest
+		var err error
+		if supported {
+			d, err = watch(last)
+			if err == errWatchUnsupported {
+				supported = false
+				d, err = get()
+			}
+		} else {
+			d, err = get()
+		}
+		if err == ErrTagNotFound {
+			d, err = last, nil
+		}
+		if err == nil && d != last {
+			last = d
+			w.notify(d)
+		}
+		if supported && err == nil {
+			select {
+			case <-w.done:
+				return
+			default:
+			}
+			continue
+		}
+		select {
+		case <-w.done:
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// notify delivers d, replacing any undelivered notification.
+func (w *Watcher) notify(d core.Digest) {
+	select {
+	case <-w.c:
+	default:
+	}
+	select {
+	case <-w.done:
+	case w.c <- d:
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagclient
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, w *Watcher) core.Digest {
+	select {
+	case d := <-w.C:
+		return d
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for digest")
+	}
+	return core.Digest{}
+}
+
+func TestWatcherDeliversChanges(t *testing.T) {
+	require := require.New(t)
+
+	digests := []core.Digest{core.DigestFixture(), core.DigestFixture()}
+
+	var mu sync.Mutex
+	var lasts []core.Digest
+	watch := func(last core.Digest) (core.Digest, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		lasts = append(lasts, last)
+		if len(lasts) > len(digests) {
+			time.Sleep(time.Millisecond)
+			return last, nil
+		}
+		return digests[len(lasts)-1], nil
+	}
+	get := func() (core.Digest, error) {
+		return core.Digest{}, ErrTagNotFound
+	}
+
+	w := newWatcher(watch, get, time.Millisecond)
+	defer w.Stop()
+
+	require.Equal(digests[0], receive(t, w))
+	require.Equal(digests[1], receive(t, w))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal([]core.Digest{{}, digests[0]}, lasts[:2])
+}
+
+func TestWatcherFallsBackToPolling(t *testing.T) {
+	require := require.New(t)
+
+	d1 := core.DigestFixture()
+	d2 := core.DigestFixture()
+	gets := 0
+
+	watch := func(last core.Digest) (core.Digest, error) {
+		return core.Digest{}, errWatchUnsupported
+	}
+	get := func() (core.Digest, error) {
+		gets++
+		if gets < 3 {
+			return d1, nil
+		}
+		return d2, nil
+	}
+
+	w := newWatcher(watch, get, time.Millisecond)
+	defer w.Stop()
+
+	require.Equal(d1, receive(t, w))
+	require.Equal(d2, receive(t, w))
+}
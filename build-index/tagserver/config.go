@@ -24,6 +24,23 @@ type Config struct {
 	Listener                  listener.Config `yaml:"listener"`
 	DuplicateReplicateStagger time.Duration   `yaml:"duplicate_replicate_stagger"`
 	DuplicatePutStagger       time.Duration   `yaml:"duplicate_put_stagger"`
+
+	// GetManyConcurrency limits the number of tags resolved in parallel by a
+	// single batch request.
+	GetManyConcurrency int `yaml:"get_many_concurrency"`
+
+	// MaxWatchTimeout bounds how long a watch request is held open waiting
+	// for a tag to change.
+	MaxWatchTimeout time.Duration `yaml:"max_watch_timeout"`
+
+	// WatchPollInterval is how often a watch request checks the tag store
+	// for changes.
+	WatchPollInterval time.Duration `yaml:"watch_poll_interval"`
+
+	// ReplicationTTL is how long replication tasks are retried before they
+	// are dropped, e.g. because their tag was deleted. Zero means tasks are
+	// retried until they succeed.
+	ReplicationTTL time.Duration `yaml:"replication_ttl"`
 }
 
 func (c Config) applyDefaults() Config {
@@ -33,5 +50,14 @@ func (c Config) applyDefaults() Config {
 	if c.DuplicatePutStagger == 0 {
 		c.DuplicatePutStagger = 20 * time.Minute
 	}
+	if c.GetManyConcurrency == 0 {
+		c.GetManyConcurrency = 16
+	}
+	if c.MaxWatchTimeout == 0 {
+		c.MaxWatchTimeout = time.Minute
+	}
+	if c.WatchPollInterval == 0 {
+		c.WatchPollInterval = time.Second
+	}
 	return c
 }
@@ -14,6 +14,8 @@
 package tagserver
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -22,6 +24,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/uber/kraken/build-index/tagclient"
@@ -35,6 +38,7 @@ import (
 	"github.com/uber/kraken/lib/persistedretry"
 	"github.com/uber/kraken/lib/persistedretry/tagreplication"
 	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/utils/dockerutil"
 	"github.com/uber/kraken/utils/handler"
 	"github.com/uber/kraken/utils/httputil"
 	"github.com/uber/kraken/utils/listener"
@@ -126,6 +130,10 @@ func (s *Server) Handler() http.Handler {
 	r.Put("/tags/{tag}/digest/{digest}", handler.Wrap(s.putTagHandler))
 	r.Head("/tags/{tag}", handler.Wrap(s.hasTagHandler))
 	r.Get("/tags/{tag}", handler.Wrap(s.getTagHandler))
+	r.Get("/tags/{tag}/resolve", handler.Wrap(s.resolvePlatformHandler))
+	r.Get("/tags/{tag}/watch", handler.Wrap(s.watchTagHandler))
+
+	r.Post("/batch/tags", handler.Wrap(s.getManyTagsHandler))
 
 	r.Get("/repositories/{repo}/tags", handler.Wrap(s.listRepositoryHandler))
 
@@ -143,6 +151,9 @@ func (s *Server) Handler() http.Handler {
 		"/internal/duplicate/tags/{tag}/digest/{digest}",
 		handler.Wrap(s.duplicatePutTagHandler))
 
+	r.Get("/x/tagreplication", persistedretry.DebugHandler(s.tagReplicationManager))
+	r.Post("/x/tagreplication/requeue_dead", persistedretry.RequeueDeadHandler(s.tagReplicationManager))
+
 	r.Mount("/debug", chimiddleware.Profiler())
 
 	return r
@@ -234,6 +245,139 @@ func (s *Server) getTagHandler(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// resolvePlatformHandler returns the digest of the manifest of tag matching the
+// platform query parameter. Tags of single-platform manifests resolve to the
+// tag's own digest.
+func (s *Server) resolvePlatformHandler(w http.ResponseWriter, r *http.Request) error {
+	tag, err := httputil.ParseParam(r, "tag")
+	if err != nil {
+		return err
+	}
+	platform := r.URL.Query().Get("platform")
+	if platform == "" {
+		return handler.Errorf("platform required").Status(http.StatusBadRequest)
+	}
+
+	d, err := s.store.Get(tag)
+	if err != nil {
+		if err == tagstore.ErrTagNotFound {
+			return handler.ErrorStatus(http.StatusNotFound)
+		}
+		return handler.Errorf("storage: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.localOriginClient.DownloadBlob(tag, d, &buf); err != nil {
+		return handler.Errorf("download manifest: %s", err)
+	}
+	manifest, _, err := dockerutil.ParseManifest(&buf)
+	if err != nil {
+		return handler.Errorf("parse manifest: %s", err)
+	}
+	if dockerutil.IsManifestIndex(manifest) {
+		d, err = dockerutil.GetPlatformManifest(manifest, platform)
+		if err == dockerutil.ErrPlatformNotFound {
+			return handler.ErrorStatus(http.StatusNotFound)
+		} else if err != nil {
+			return handler.Errorf("get platform manifest: %s", err).Status(http.StatusBadRequest)
+		}
+	}
+
+	if _, err := io.WriteString(w, d.String()); err != nil {
+		return handler.Errorf("write digest: %s", err)
+	}
+	return nil
+}
+
+// watchTagHandler long-polls until the digest of tag differs from the digest
+// query parameter, and returns the new digest. If the tag does not change
+// within the timeout query parameter, responds with 304.
+func (s *Server) watchTagHandler(w http.ResponseWriter, r *http.Request) error {
+	tag, err := httputil.ParseParam(r, "tag")
+	if err != nil {
+		return err
+	}
+	var last core.Digest
+	if raw := r.URL.Query().Get("digest"); raw != "" {
+		last, err = core.ParseDigest(raw)
+		if err != nil {
+			return handler.Errorf("parse digest: %s", err).Status(http.StatusBadRequest)
+		}
+	}
+	timeout := s.config.MaxWatchTimeout
+	if raw := r.URL.Query().Get("timeout"); raw != "" {
+		t, err := time.ParseDuration(raw)
+		if err != nil {
+			return handler.Errorf("parse timeout: %s", err).Status(http.StatusBadRequest)
+		}
+		if t < timeout {
+			timeout = t
+		}
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		d, err := s.store.Get(tag)
+		if err == nil && d != last {
+			if _, err := io.WriteString(w, d.String()); err != nil {
+				return handler.Errorf("write digest: %s", err)
+			}
+			return nil
+		} else if err != nil && err != tagstore.ErrTagNotFound {
+			return handler.Errorf("storage: %s", err)
+		}
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-deadline.C:
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		case <-time.After(s.config.WatchPollInterval):
+		}
+	}
+}
+
+// getManyTagsHandler resolves all tags in the request body, returning a map of
+// tag to digest. Tags which are not found are omitted from the result.
+func (s *Server) getManyTagsHandler(w http.ResponseWriter, r *http.Request) error {
+	var req tagclient.GetManyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return handler.Errorf("decode body: %s", err).Status(http.StatusBadRequest)
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	result := make(map[string]core.Digest, len(req.Tags))
+	sem := make(chan struct{}, s.config.GetManyConcurrency)
+	var wg sync.WaitGroup
+	for _, tag := range req.Tags {
+		wg.Add(1)
+		go func(tag string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			d, err := s.store.Get(tag)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				result[tag] = d
+			} else if err != tagstore.ErrTagNotFound && firstErr == nil {
+				firstErr = fmt.Errorf("get %s: %s", tag, err)
+			}
+		}(tag)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return handler.Errorf("storage: %s", firstErr)
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
 func (s *Server) hasTagHandler(w http.ResponseWriter, r *http.Request) error {
 	tag, err := httputil.ParseParam(r, "tag")
 	if err != nil {
@@ -367,6 +511,7 @@ func (s *Server) duplicateReplicateTagHandler(w http.ResponseWriter, r *http.Req
 
 	for _, dest := range destinations {
 		task := tagreplication.NewTask(tag, d, req.Dependencies, dest, req.Delay)
+		task.TTL = s.config.ReplicationTTL
 		if err := s.tagReplicationManager.Add(task); err != nil {
 			return handler.Errorf("add replicate task: %s", err)
 		}
@@ -402,7 +547,7 @@ func (s *Server) putTag(tag string, d core.Digest, deps core.DigestList) error {
 	for addr := range neighbors {
 		delay += s.config.DuplicatePutStagger
 		client := s.provider.Provide(addr)
-		if err := client.DuplicatePut(tag, d, delay); err != nil {
+		if err := client.DuplicatePut(context.Background(), tag, d, delay); err != nil {
 			log.Errorf("Error duplicating put task to %s: %s", addr, err)
 		} else {
 			successes++
@@ -422,6 +567,7 @@ func (s *Server) replicateTag(tag string, d core.Digest, deps core.DigestList) e
 
 	for _, dest := range destinations {
 		task := tagreplication.NewTask(tag, d, deps, dest, 0)
+		task.TTL = s.config.ReplicationTTL
 		if err := s.tagReplicationManager.Add(task); err != nil {
 			return handler.Errorf("add replicate task: %s", err)
 		}
@@ -434,7 +580,8 @@ func (s *Server) replicateTag(tag string, d core.Digest, deps core.DigestList) e
 	for addr := range neighbors { // Loops in random order.
 		delay += s.config.DuplicateReplicateStagger
 		client := s.provider.Provide(addr)
-		if err := client.DuplicateReplicate(tag, d, deps, delay); err != nil {
+		err := client.DuplicateReplicate(context.Background(), tag, d, deps, delay)
+		if err != nil {
 			log.Errorf("Error duplicating replicate task to %s: %s", addr, err)
 		} else {
 			successes++
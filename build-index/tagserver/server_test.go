@@ -14,6 +14,8 @@
 package tagserver
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -35,7 +37,9 @@ import (
 	"github.com/uber/kraken/mocks/lib/backend"
 	"github.com/uber/kraken/mocks/lib/persistedretry"
 	"github.com/uber/kraken/mocks/origin/blobclient"
+	"github.com/uber/kraken/utils/dockerutil"
 	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/mockutil"
 	"github.com/uber/kraken/utils/testutil"
 
 	"github.com/golang/mock/gomock"
@@ -168,9 +172,10 @@ func TestPut(t *testing.T) {
 	mocks.store.EXPECT().Put(tag, digest, time.Duration(0)).Return(nil)
 	mocks.provider.EXPECT().Provide(_testNeighbor).Return(neighborClient)
 	neighborClient.EXPECT().DuplicatePut(
+		gomock.Any(),
 		tag, digest, mocks.config.DuplicateReplicateStagger).Return(nil)
 
-	require.NoError(client.Put(tag, digest))
+	require.NoError(client.Put(context.Background(), tag, digest))
 }
 
 func TestPutInvalidParam(t *testing.T) {
@@ -230,7 +235,7 @@ func TestDuplicatePut(t *testing.T) {
 
 	mocks.store.EXPECT().Put(tag, digest, delay).Return(nil)
 
-	require.NoError(client.DuplicatePut(tag, digest, delay))
+	require.NoError(client.DuplicatePut(context.Background(), tag, digest, delay))
 }
 
 func TestDuplicatePutInvalidParam(t *testing.T) {
@@ -285,7 +290,7 @@ func TestGet(t *testing.T) {
 
 	mocks.store.EXPECT().Get(tag).Return(digest, nil)
 
-	result, err := client.Get(tag)
+	result, err := client.Get(context.Background(), tag)
 	require.NoError(err)
 	require.Equal(digest, result)
 }
@@ -305,10 +310,163 @@ func TestGetTagNotFound(t *testing.T) {
 
 	mocks.store.EXPECT().Get(tag).Return(core.Digest{}, tagstore.ErrTagNotFound)
 
-	_, err := client.Get(tag)
+	_, err := client.Get(context.Background(), tag)
 	require.Equal(tagclient.ErrTagNotFound, err)
 }
 
+func TestResolvePlatform(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	client := newClusterClient(addr)
+
+	amd64 := core.DigestFixture()
+	arm64 := core.DigestFixture()
+	index, raw := dockerutil.OCIIndexFixture(amd64, arm64)
+
+	mocks.store.EXPECT().Get("repo:latest").Return(index, nil).Times(2)
+	mocks.originClient.EXPECT().DownloadBlob("repo:latest", index, mockutil.MatchWriter(raw)).Return(nil).Times(2)
+
+	result, err := client.ResolvePlatform(context.Background(), "repo", "latest", "linux/arm64")
+	require.NoError(err)
+	require.Equal(arm64, result)
+
+	_, err = client.ResolvePlatform(context.Background(), "repo", "latest", "windows/amd64")
+	require.Equal(tagclient.ErrTagNotFound, err)
+}
+
+func TestResolvePlatformSingleManifest(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	client := newClusterClient(addr)
+
+	manifest, raw := dockerutil.ManifestFixture(
+		core.DigestFixture(), core.DigestFixture(), core.DigestFixture())
+
+	mocks.store.EXPECT().Get("repo:latest").Return(manifest, nil)
+	mocks.originClient.EXPECT().DownloadBlob("repo:latest", manifest, mockutil.MatchWriter(raw)).Return(nil)
+
+	result, err := client.ResolvePlatform(context.Background(), "repo", "latest", "linux/amd64")
+	require.NoError(err)
+	require.Equal(manifest, result)
+}
+
+func TestWatch(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	mocks.config.WatchPollInterval = time.Millisecond
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	client := newClusterClient(addr)
+
+	d1 := core.DigestFixture()
+	d2 := core.DigestFixture()
+
+	gomock.InOrder(
+		mocks.store.EXPECT().Get("repo:latest").Return(core.Digest{}, tagstore.ErrTagNotFound),
+		mocks.store.EXPECT().Get("repo:latest").Return(d1, nil).Times(3),
+		mocks.store.EXPECT().Get("repo:latest").Return(d2, nil).AnyTimes(),
+	)
+
+	w := client.Watch("repo", "latest")
+	defer w.Stop()
+
+	for _, expected := range []core.Digest{d1, d2} {
+		select {
+		case d := <-w.C:
+			require.Equal(expected, d)
+		case <-time.After(5 * time.Second):
+			require.FailNow("timed out waiting for digest")
+		}
+	}
+}
+
+func TestWatchTimeout(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	mocks.config.WatchPollInterval = time.Millisecond
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	tag := core.TagFixture()
+	d := core.DigestFixture()
+
+	mocks.store.EXPECT().Get(tag).Return(d, nil).MinTimes(1)
+
+	resp, err := httputil.Get(
+		fmt.Sprintf(
+			"http://%s/tags/%s/watch?digest=%s&timeout=50ms",
+			addr, url.PathEscape(tag), url.QueryEscape(d.String())),
+		httputil.SendAcceptedCodes(http.StatusNotModified))
+	require.NoError(err)
+	require.Equal(http.StatusNotModified, resp.StatusCode)
+}
+
+func TestGetMany(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	client := newClusterClient(addr)
+
+	tag1 := core.TagFixture()
+	tag2 := core.TagFixture()
+	missing := core.TagFixture()
+	d1 := core.DigestFixture()
+	d2 := core.DigestFixture()
+
+	mocks.store.EXPECT().Get(tag1).Return(d1, nil)
+	mocks.store.EXPECT().Get(tag2).Return(d2, nil)
+	mocks.store.EXPECT().Get(missing).Return(core.Digest{}, tagstore.ErrTagNotFound)
+
+	result, err := client.GetMany(context.Background(), []string{tag1, tag2, missing})
+	require.NoError(err)
+	require.Equal(map[string]core.Digest{tag1: d1, tag2: d2}, result)
+}
+
+func TestGetManyStorageError(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	client := newClusterClient(addr)
+
+	tag := core.TagFixture()
+
+	mocks.store.EXPECT().Get(tag).Return(core.Digest{}, errors.New("some error"))
+
+	_, err := client.GetMany(context.Background(), []string{tag})
+	require.Error(err)
+}
+
 func TestHas(t *testing.T) {
 	require := require.New(t)
 
@@ -325,7 +483,7 @@ func TestHas(t *testing.T) {
 
 	mocks.backendClient.EXPECT().Stat(tag, tag).Return(core.NewBlobInfo(int64(len(digest.String()))), nil)
 
-	ok, err := client.Has(tag)
+	ok, err := client.Has(context.Background(), tag)
 	require.NoError(err)
 	require.True(ok)
 }
@@ -345,7 +503,7 @@ func TestHasNotFound(t *testing.T) {
 
 	mocks.backendClient.EXPECT().Stat(tag, tag).Return(nil, backenderrors.ErrBlobNotFound)
 
-	ok, err := client.Has(tag)
+	ok, err := client.Has(context.Background(), tag)
 	require.NoError(err)
 	require.False(ok)
 }
@@ -373,7 +531,7 @@ func TestListRepository(t *testing.T) {
 		Names: names,
 	}, nil)
 
-	result, err := client.ListRepository(repo)
+	result, err := client.ListRepository(context.Background(), repo)
 	require.NoError(err)
 	require.Equal(tags, result)
 }
@@ -396,7 +554,7 @@ func TestList(t *testing.T) {
 		Names: names,
 	}, nil)
 
-	result, err := client.List(prefix)
+	result, err := client.List(context.Background(), prefix)
 	require.NoError(err)
 	require.Equal(names, result)
 }
@@ -418,7 +576,7 @@ func TestListEmptyPrefix(t *testing.T) {
 		Names: names,
 	}, nil)
 
-	result, err := client.List("")
+	result, err := client.List(context.Background(), "")
 	require.NoError(err)
 	require.Equal(names, result)
 }
@@ -447,14 +605,16 @@ func TestPutAndReplicate(t *testing.T) {
 		mocks.store.EXPECT().Put(tag, digest, time.Duration(0)).Return(nil),
 		mocks.provider.EXPECT().Provide(_testNeighbor).Return(neighborClient),
 		neighborClient.EXPECT().DuplicatePut(
+			gomock.Any(),
 			tag, digest, mocks.config.DuplicateReplicateStagger).Return(nil),
 		mocks.tagReplicationManager.EXPECT().Add(tagreplication.MatchTask(task)).Return(nil),
 		mocks.provider.EXPECT().Provide(_testNeighbor).Return(replicaClient),
 		replicaClient.EXPECT().DuplicateReplicate(
+			gomock.Any(),
 			tag, digest, deps, mocks.config.DuplicateReplicateStagger).Return(nil),
 	)
 
-	require.NoError(client.PutAndReplicate(tag, digest))
+	require.NoError(client.PutAndReplicate(context.Background(), tag, digest))
 }
 
 func TestReplicate(t *testing.T) {
@@ -480,10 +640,11 @@ func TestReplicate(t *testing.T) {
 		mocks.tagReplicationManager.EXPECT().Add(tagreplication.MatchTask(task)).Return(nil),
 		mocks.provider.EXPECT().Provide(_testNeighbor).Return(replicaClient),
 		replicaClient.EXPECT().DuplicateReplicate(
+			gomock.Any(),
 			tag, digest, deps, mocks.config.DuplicateReplicateStagger).Return(nil),
 	)
 
-	require.NoError(client.Replicate(tag))
+	require.NoError(client.Replicate(context.Background(), tag))
 }
 
 func TestReplicateNotFound(t *testing.T) {
@@ -503,7 +664,7 @@ func TestReplicateNotFound(t *testing.T) {
 		mocks.store.EXPECT().Get(tag).Return(core.Digest{}, tagstore.ErrTagNotFound),
 	)
 
-	err := client.Replicate(tag)
+	err := client.Replicate(context.Background(), tag)
 	require.Error(err)
 	require.True(httputil.IsNotFound(err))
 }
@@ -527,7 +688,7 @@ func TestDuplicateReplicate(t *testing.T) {
 
 	mocks.tagReplicationManager.EXPECT().Add(tagreplication.MatchTask(task)).Return(nil)
 
-	require.NoError(client.DuplicateReplicate(tag, digest, dependencies, delay))
+	require.NoError(client.DuplicateReplicate(context.Background(), tag, digest, dependencies, delay))
 }
 
 func TestDuplicateReplicateInvalidParam(t *testing.T) {
@@ -593,7 +754,7 @@ func TestNoopReplicate(t *testing.T) {
 
 	// No replication tasks added or duplicated because no remotes are configured.
 
-	require.NoError(client.Replicate(tag))
+	require.NoError(client.Replicate(context.Background(), tag))
 }
 
 func TestOrigin(t *testing.T) {
@@ -607,7 +768,7 @@ func TestOrigin(t *testing.T) {
 
 	client := newClusterClient(addr)
 
-	result, err := client.Origin()
+	result, err := client.Origin(context.Background())
 	require.NoError(err)
 	require.Equal(_testOrigin, result)
 }
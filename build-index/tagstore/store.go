@@ -136,7 +136,7 @@ func (s *tagStore) resolveFromDisk(tag string) (core.Digest, error) {
 	if _, err := io.Copy(&b, f); err != nil {
 		return core.Digest{}, fmt.Errorf("copy from fs: %s", err)
 	}
-	d, err := core.ParseSHA256Digest(b.String())
+	d, err := core.ParseDigest(b.String())
 	if err != nil {
 		return core.Digest{}, fmt.Errorf("parse fs digest: %s", err)
 	}
@@ -155,7 +155,7 @@ func (s *tagStore) resolveFromBackend(tag string) (core.Digest, error) {
 		}
 		return core.Digest{}, fmt.Errorf("backend client: %s", err)
 	}
-	d, err := core.ParseSHA256Digest(b.String())
+	d, err := core.ParseDigest(b.String())
 	if err != nil {
 		return core.Digest{}, fmt.Errorf("parse backend digest: %s", err)
 	}
@@ -15,6 +15,7 @@ package core
 
 import (
 	_ "crypto/sha256" // For computing digest.
+	_ "crypto/sha512" // For computing digest.
 	"database/sql/driver"
 	"encoding/hex"
 	"encoding/json"
@@ -92,6 +93,42 @@ func ParseSHA256Digest(raw string) (Digest, error) {
 	}, nil
 }
 
+// ParseDigest parses a raw "<algo>:<hex>" digest of any supported algo. Returns
+// error if the algo is not supported or the hex is not valid for the algo.
+func ParseDigest(raw string) (Digest, error) {
+	if raw == "" {
+		return Digest{}, errors.New("invalid digest: empty")
+	}
+	parts := strings.Split(raw, ":")
+	if len(parts) != 2 {
+		return Digest{}, errors.New("invalid digest: expected '<algo>:<hex>'")
+	}
+	return newDigest(parts[0], parts[1])
+}
+
+// NewDigestFromHex constructs a Digest from a hex string of any supported algo,
+// for contexts where only the hex is known, such as file names. The algo is
+// inferred from the length of hex.
+func NewDigestFromHex(hex string) (Digest, error) {
+	for algo, h := range _algoHashes {
+		if len(hex) == 2*h.Size() {
+			return newDigest(algo, hex)
+		}
+	}
+	return Digest{}, fmt.Errorf("invalid digest hex: unexpected length %d from %q", len(hex), hex)
+}
+
+func newDigest(algo, hex string) (Digest, error) {
+	if err := ValidateDigestHex(algo, hex); err != nil {
+		return Digest{}, fmt.Errorf("invalid %s: %s", algo, err)
+	}
+	return Digest{
+		algo: algo,
+		hex:  hex,
+		raw:  fmt.Sprintf("%s:%s", algo, hex),
+	}, nil
+}
+
 // Value marshals a digest and returns []byte as driver.Value.
 func (d Digest) Value() (driver.Value, error) {
 	b, err := json.Marshal(d)
@@ -112,7 +149,7 @@ func (d *Digest) UnmarshalJSON(str []byte) error {
 	if err := json.Unmarshal(str, &raw); err != nil {
 		return err
 	}
-	digest, err := ParseSHA256Digest(raw)
+	digest, err := ParseDigest(raw)
 	if err != nil {
 		return err
 	}
@@ -144,6 +181,17 @@ func (d Digest) Hex() string {
 	return d.hex
 }
 
+// Digester returns a new Digester for the algo of d, for verifying content
+// against d.
+func (d Digest) Digester() *Digester {
+	digester, err := NewDigesterForAlgo(d.algo)
+	if err != nil {
+		// Digests can only be constructed with supported algos.
+		panic(err)
+	}
+	return digester
+}
+
 // ShardID returns the shard id of the digest.
 func (d Digest) ShardID() string {
 	return d.hex[:4]
@@ -159,3 +207,18 @@ func ValidateSHA256(s string) error {
 	}
 	return nil
 }
+
+// ValidateDigestHex returns error if s is not a valid hex digest for algo.
+func ValidateDigestHex(algo, s string) error {
+	h, ok := _algoHashes[algo]
+	if !ok {
+		return fmt.Errorf("unsupported digest algo: %q", algo)
+	}
+	if len(s) != 2*h.Size() {
+		return fmt.Errorf("expected %d characters, got %d from %q", 2*h.Size(), len(s), s)
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		return fmt.Errorf("hex: %s", err)
+	}
+	return nil
+}
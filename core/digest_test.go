@@ -145,3 +145,64 @@ func TestDigestListValue(t *testing.T) {
 	require.NoError(result.Scan([]byte(expected)))
 	require.Equal(digests, result)
 }
+
+const _sha512Hex = "ee26b0dd4af7e749aa1a8ee3c10ae9923f618980772e473f8819a5d4940e0db27ac185f8a0e1d5f84f88bc887fd67b143732c304cc5fa9ad8e6f57f50028a8ff"
+
+func TestParseDigest(t *testing.T) {
+	tests := []struct {
+		desc string
+		algo string
+		hex  string
+	}{
+		{"sha256", SHA256, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"sha512", SHA512, _sha512Hex},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			raw := test.algo + ":" + test.hex
+			d, err := ParseDigest(raw)
+			require.NoError(err)
+			require.Equal(test.algo, d.Algo())
+			require.Equal(test.hex, d.Hex())
+			require.Equal(raw, d.String())
+
+			fromHex, err := NewDigestFromHex(test.hex)
+			require.NoError(err)
+			require.Equal(d, fromHex)
+		})
+	}
+}
+
+func TestParseDigestErrors(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input string
+	}{
+		{"empty", ""},
+		{"no algo", _sha512Hex},
+		{"unsupported algo", "md5:d8e8fca2dc0f896fd7cb4cb0031ba249"},
+		{"wrong length for algo", "sha512:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := ParseDigest(test.input)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSHA512DigestMarshalJSON(t *testing.T) {
+	require := require.New(t)
+
+	digest, err := ParseDigest("sha512:" + _sha512Hex)
+	require.NoError(err)
+
+	b, err := json.Marshal(digest)
+	require.NoError(err)
+
+	var result Digest
+	require.NoError(json.Unmarshal(b, &result))
+	require.Equal(digest, result)
+}
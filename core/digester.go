@@ -16,30 +16,53 @@ package core
 import (
 	"crypto"
 	"encoding/hex"
+	"fmt"
 	"hash"
 	"io"
 )
 
+// Supported digest algorithms.
 const (
-	// SHA256 is the only algorithm supported.
+	// SHA256 is the default algorithm.
 	SHA256 = "sha256"
+	SHA512 = "sha512"
 )
 
+var _algoHashes = map[string]crypto.Hash{
+	SHA256: crypto.SHA256,
+	SHA512: crypto.SHA512,
+}
+
 // Digester calculates the digest of data stream.
 type Digester struct {
+	algo string
 	hash hash.Hash
 }
 
-// NewDigester instantiates and returns a new Digester object.
+// NewDigester instantiates and returns a new sha256 Digester object.
 func NewDigester() *Digester {
 	return &Digester{
+		algo: SHA256,
 		hash: crypto.SHA256.New(),
 	}
 }
 
+// NewDigesterForAlgo returns a new Digester for algo. Returns error if algo is
+// not supported.
+func NewDigesterForAlgo(algo string) (*Digester, error) {
+	h, ok := _algoHashes[algo]
+	if !ok {
+		return nil, fmt.Errorf("unsupported digest algo: %q", algo)
+	}
+	return &Digester{
+		algo: algo,
+		hash: h.New(),
+	}, nil
+}
+
 // Digest returns the digest of existing data.
 func (d *Digester) Digest() Digest {
-	digest, err := NewSHA256DigestFromHex(hex.EncodeToString(d.hash.Sum(nil)))
+	digest, err := newDigest(d.algo, hex.EncodeToString(d.hash.Sum(nil)))
 	if err != nil {
 		// This should never fail.
 		panic(err)
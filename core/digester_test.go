@@ -79,3 +79,19 @@ func TestTeeReader(t *testing.T) {
 	require.NoError(ValidateSHA256(hexDigest))
 	require.Equal(_expectedHex, hexDigest)
 }
+
+func TestNewDigesterForAlgo(t *testing.T) {
+	require := require.New(t)
+
+	d, err := NewDigesterForAlgo(SHA512)
+	require.NoError(err)
+	digest, err := d.FromBytes([]byte(_testStr))
+	require.NoError(err)
+	require.Equal(SHA512, digest.Algo())
+	require.Equal(
+		"ee26b0dd4af7e749aa1a8ee3c10ae9923f618980772e473f8819a5d4940e0db27ac185f8a0e1d5f84f88bc887fd67b143732c304cc5fa9ad8e6f57f50028a8ff",
+		digest.Hex())
+
+	_, err = NewDigesterForAlgo("md5")
+	require.Error(err)
+}
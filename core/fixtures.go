@@ -115,6 +115,7 @@ func PeerContextFixture() PeerContext {
 		"zone1",
 		"test01-zone1",
 		randutil.IP(),
+		"",
 		randutil.Port(),
 		false)
 	if err != nil {
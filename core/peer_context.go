@@ -13,7 +13,11 @@
 // limitations under the License.
 package core
 
-import "errors"
+import (
+	"errors"
+	"net"
+	"strings"
+)
 
 // PeerContext defines the context a peer runs within, namely the fields which
 // are used to identify each peer.
@@ -26,6 +30,11 @@ type PeerContext struct {
 	IP   string `json:"ip"`
 	Port int    `json:"port"`
 
+	// BindIP is the local ip the peer's Scheduler listens on. Empty means all
+	// interfaces. This allows multi-homed hosts to announce one ip (IP) while
+	// serving peer traffic on a specific interface.
+	BindIP string `json:"-"`
+
 	// PeerID the peer will identify itself as.
 	PeerID PeerID `json:"peer_id"`
 
@@ -39,10 +48,16 @@ type PeerContext struct {
 	Origin bool `json:"origin"`
 }
 
-// NewPeerContext creates a new PeerContext.
+// NewPeerContext creates a new PeerContext. announceIP is the ip the peer
+// announces itself as, and bindIP is the ip its Scheduler listens on (empty
+// for all interfaces).
 func NewPeerContext(
-	f PeerIDFactory, zone, cluster, ip string, port int, origin bool) (PeerContext, error) {
+	f PeerIDFactory,
+	zone, cluster, announceIP, bindIP string,
+	port int,
+	origin bool) (PeerContext, error) {
 
+	ip := NormalizeIP(announceIP)
 	if ip == "" {
 		return PeerContext{}, errors.New("no ip supplied")
 	}
@@ -56,9 +71,21 @@ func NewPeerContext(
 	return PeerContext{
 		IP:      ip,
 		Port:    port,
+		BindIP:  NormalizeIP(bindIP),
 		PeerID:  peerID,
 		Zone:    zone,
 		Cluster: cluster,
 		Origin:  origin,
 	}, nil
 }
+
+// NormalizeIP strips the brackets of bracketed IPv6 literals and returns ip in
+// its canonical form. Values which do not parse as ips, e.g. hostnames, are
+// returned unchanged.
+func NormalizeIP(ip string) string {
+	ip = strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]")
+	if parsed := net.ParseIP(ip); parsed != nil {
+		return parsed.String()
+	}
+	return ip
+}
@@ -40,12 +40,63 @@ func TestNewOriginPeerContext(t *testing.T) {
 	require.True(p.Origin)
 }
 
+func TestNewPeerContextBindIP(t *testing.T) {
+	require := require.New(t)
+
+	announceIP := randutil.IP()
+	bindIP := randutil.IP()
+
+	p, err := NewPeerContext(
+		RandomPeerIDFactory, "zone1", "test01-zone1", announceIP, bindIP, randutil.Port(), false)
+	require.NoError(err)
+	require.Equal(announceIP, p.IP)
+	require.Equal(bindIP, p.BindIP)
+}
+
+func TestNewPeerContextIPv6(t *testing.T) {
+	for _, ip := range []string{"2001:db8::1", "[2001:db8::1]", "2001:0db8:0::1"} {
+		t.Run(ip, func(t *testing.T) {
+			require := require.New(t)
+
+			p, err := NewPeerContext(
+				AddrHashPeerIDFactory, "zone1", "test01-zone1", ip, "[::1]", 8080, false)
+			require.NoError(err)
+			require.Equal("2001:db8::1", p.IP)
+			require.Equal("::1", p.BindIP)
+
+			// Peer ids must not depend on how the ip was formatted.
+			expected, err := AddrHashPeerIDFactory.GeneratePeerID("2001:db8::1", 8080)
+			require.NoError(err)
+			require.Equal(expected, p.PeerID)
+		})
+	}
+}
+
+func TestNormalizeIP(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"10.0.0.1", "10.0.0.1"},
+		{"localhost", "localhost"},
+		{"", ""},
+		{"::1", "::1"},
+		{"[::1]", "::1"},
+		{"2001:DB8:0:0::1", "2001:db8::1"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			require.Equal(t, test.expected, NormalizeIP(test.input))
+		})
+	}
+}
+
 func TestNewOriginPeerContextErrors(t *testing.T) {
 	t.Run("empty ip", func(t *testing.T) {
 		require := require.New(t)
 
 		_, err := NewPeerContext(
-			RandomPeerIDFactory, "zone1", "test01-zone1", "", randutil.Port(), false)
+			RandomPeerIDFactory, "zone1", "test01-zone1", "", "", randutil.Port(), false)
 		require.Error(err)
 	})
 
@@ -53,7 +104,7 @@ func TestNewOriginPeerContextErrors(t *testing.T) {
 		require := require.New(t)
 
 		_, err := NewPeerContext(
-			RandomPeerIDFactory, "zone1", "test01-zone1", randutil.IP(), 0, false)
+			RandomPeerIDFactory, "zone1", "test01-zone1", randutil.IP(), "", 0, false)
 		require.Error(err)
 	})
 
@@ -61,7 +112,7 @@ func TestNewOriginPeerContextErrors(t *testing.T) {
 		require := require.New(t)
 
 		_, err := NewPeerContext(
-			"invalid", "zone1", "test01-zone1", randutil.IP(), randutil.Port(), false)
+			"invalid", "zone1", "test01-zone1", randutil.IP(), "", randutil.Port(), false)
 		require.Error(err)
 	})
 }
@@ -22,6 +22,7 @@ type PeerInfo struct {
 	Port     int    `json:"port"`
 	Origin   bool   `json:"origin"`
 	Complete bool   `json:"complete"`
+	Zone     string `json:"zone,omitempty"`
 }
 
 // NewPeerInfo creates a new PeerInfo.
@@ -43,7 +44,9 @@ func NewPeerInfo(
 
 // PeerInfoFromContext derives PeerInfo from a PeerContext.
 func PeerInfoFromContext(pctx PeerContext, complete bool) *PeerInfo {
-	return NewPeerInfo(pctx.PeerID, pctx.IP, pctx.Port, pctx.Origin, complete)
+	p := NewPeerInfo(pctx.PeerID, pctx.IP, pctx.Port, pctx.Origin, complete)
+	p.Zone = pctx.Zone
+	return p
 }
 
 // PeerInfos groups PeerInfo structs for sorting.
@@ -70,3 +73,18 @@ func SortedByPeerID(peers []*PeerInfo) []*PeerInfo {
 	sort.Sort(PeersByPeerID{PeerInfos(c)})
 	return c
 }
+
+// SortedByZone returns a copy of peers where peers running within zone
+// precede peers in other zones. The relative order of peers is otherwise
+// preserved. If zone is empty, the copy is returned unchanged.
+func SortedByZone(peers []*PeerInfo, zone string) []*PeerInfo {
+	c := make([]*PeerInfo, len(peers))
+	copy(c, peers)
+	if zone == "" {
+		return c
+	}
+	sort.SliceStable(c, func(i, j int) bool {
+		return c[i].Zone == zone && c[j].Zone != zone
+	})
+	return c
+}
@@ -30,3 +30,29 @@ func TestSortedByPeerID(t *testing.T) {
 	require.True(sorted[0].PeerID.LessThan(sorted[1].PeerID))
 	require.True(sorted[1].PeerID.LessThan(sorted[2].PeerID))
 }
+
+func TestSortedByZone(t *testing.T) {
+	require := require.New(t)
+
+	p1 := PeerInfoFixture()
+	p1.Zone = "sjc1"
+	p2 := PeerInfoFixture()
+	p2.Zone = "dca1"
+	p3 := PeerInfoFixture()
+	p4 := PeerInfoFixture()
+	p4.Zone = "dca1"
+
+	peers := []*PeerInfo{p1, p2, p3, p4}
+
+	require.Equal([]*PeerInfo{p2, p4, p1, p3}, SortedByZone(peers, "dca1"))
+	require.Equal(peers, SortedByZone(peers, ""))
+}
+
+func TestPeerInfoFromContextIncludesZone(t *testing.T) {
+	require := require.New(t)
+
+	pctx := PeerContextFixture()
+	pctx.Zone = "sjc1"
+
+	require.Equal("sjc1", PeerInfoFromContext(pctx, false).Zone)
+}
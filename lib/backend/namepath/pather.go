@@ -19,6 +19,8 @@ import (
 	"path"
 	"regexp"
 	"strings"
+
+	"github.com/uber/kraken/core"
 )
 
 // Pather id strings.
@@ -105,18 +107,23 @@ func (p ShardedDockerBlobPather) BasePath() string {
 	return path.Join(p.root, "docker/registry/v2/blobs")
 }
 
-// BlobPath interprets name as a SHA256 digest and returns a registry path
-// which is sharded by the first two bytes.
+// BlobPath interprets name as a digest hex and returns a registry path which
+// is sharded by the first two bytes. Names which are not a valid digest hex are
+// assumed to be SHA256.
 func (p ShardedDockerBlobPather) BlobPath(name string) (string, error) {
 	if len(name) <= 2 {
 		return "", errors.New("name is too short, must be > 2 characters")
 	}
-	return path.Join(p.BasePath(), "sha256", name[:2], name, "data"), nil
+	algo := core.SHA256
+	if d, err := core.NewDigestFromHex(name); err == nil {
+		algo = d.Algo()
+	}
+	return path.Join(p.BasePath(), algo, name[:2], name, "data"), nil
 }
 
 // NameFromBlobPath converts a sharded blob path back into raw hex format.
 func (p ShardedDockerBlobPather) NameFromBlobPath(bp string) (string, error) {
-	re := regexp.MustCompile(p.BasePath() + "/sha256/../(.+)/data")
+	re := regexp.MustCompile(p.BasePath() + "/(?:sha256|sha512)/../(.+)/data")
 	matches := re.FindStringSubmatch(bp)
 	if len(matches) != 2 {
 		return "", errors.New("invalid sharded docker blob path format")
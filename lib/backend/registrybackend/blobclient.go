@@ -50,8 +50,8 @@ func (f *blobClientFactory) Create(
 	return NewBlobClient(config)
 }
 
-const _layerquery = "http://%s/v2/%s/blobs/sha256:%s"
-const _manifestquery = "http://%s/v2/%s/manifests/sha256:%s"
+const _layerquery = "http://%s/v2/%s/blobs/%s"
+const _manifestquery = "http://%s/v2/%s/manifests/%s"
 
 // BlobClient stats and downloads blob from registry.
 type BlobClient struct {
@@ -96,7 +96,7 @@ func (c *BlobClient) Download(namespace, name string, dst io.Writer) error {
 }
 
 func (c *BlobClient) statHelper(namespace, name, query string, opt httputil.SendOption) (*core.BlobInfo, error) {
-	URL := fmt.Sprintf(query, c.config.Address, namespace, name)
+	URL := fmt.Sprintf(query, c.config.Address, namespace, digestFromName(name))
 	resp, err := httputil.Head(
 		URL,
 		opt,
@@ -116,7 +116,7 @@ func (c *BlobClient) statHelper(namespace, name, query string, opt httputil.Send
 }
 
 func (c *BlobClient) downloadHelper(namespace, name, query string, dst io.Writer, opt httputil.SendOption) error {
-	URL := fmt.Sprintf(query, c.config.Address, namespace, name)
+	URL := fmt.Sprintf(query, c.config.Address, namespace, digestFromName(name))
 	resp, err := httputil.Get(
 		URL,
 		opt,
@@ -144,3 +144,12 @@ func (c *BlobClient) Upload(namespace, name string, src io.Reader) error {
 func (c *BlobClient) List(prefix string, opts ...backend.ListOption) (*backend.ListResult, error) {
 	return nil, errors.New("not supported")
 }
+
+// digestFromName converts a blob name into a registry digest reference. Names
+// which are not a valid digest hex are assumed to be SHA256.
+func digestFromName(name string) string {
+	if d, err := core.NewDigestFromHex(name); err == nil {
+		return d.String()
+	}
+	return core.SHA256 + ":" + name
+}
@@ -22,6 +22,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/dockerregistry/transfer"
 	"github.com/uber/kraken/lib/store"
 
@@ -62,7 +63,7 @@ func (b *blobs) stat(ctx context.Context, path string) (storagedriver.FileInfo,
 	if err != nil {
 		return nil, err
 	}
-	bi, err := b.transferer.Stat(repo, digest)
+	bi, err := b.transferer.Stat(ctx, repo, digest)
 	if err != nil {
 		if err == transfer.ErrBlobNotFound {
 			return nil, storagedriver.PathNotFoundError{
@@ -70,6 +71,7 @@ func (b *blobs) stat(ctx context.Context, path string) (storagedriver.FileInfo,
 				Path:       digest.Hex(),
 			}
 		}
+		setErrorStatus(ctx, err)
 		return nil, fmt.Errorf("transferer stat: %s", err)
 	}
 	// Hacking the path, since kraken storage driver is also the consumer of this info.
@@ -110,15 +112,17 @@ func (b *blobs) getCacheReaderHelper(
 		return nil, fmt.Errorf("get layer digest %s: %s", path, err)
 	}
 
-	r, err := b.transferer.Download(repo, digest)
-	if err != nil {
-		if err == transfer.ErrBlobNotFound {
-			return nil, storagedriver.PathNotFoundError{
-				DriverName: "kraken",
-				Path:       digest.Hex(),
-			}
+	if rd, ok := b.transferer.(transfer.RangeDownloader); ok && offset > 0 {
+		r, err := rd.DownloadRange(ctx, repo, digest, offset)
+		if err != nil {
+			return nil, b.downloadError(ctx, digest, err)
 		}
-		return nil, fmt.Errorf("transferer download: %s", err)
+		return r, nil
+	}
+
+	r, err := b.transferer.Download(ctx, repo, digest)
+	if err != nil {
+		return nil, b.downloadError(ctx, digest, err)
 	}
 
 	if _, err := r.Seek(offset, 0); err != nil {
@@ -127,6 +131,17 @@ func (b *blobs) getCacheReaderHelper(
 	return r, nil
 }
 
+func (b *blobs) downloadError(ctx context.Context, digest core.Digest, err error) error {
+	if err == transfer.ErrBlobNotFound {
+		return storagedriver.PathNotFoundError{
+			DriverName: "kraken",
+			Path:       digest.Hex(),
+		}
+	}
+	setErrorStatus(ctx, err)
+	return fmt.Errorf("transferer download: %s", err)
+}
+
 func parseRepo(ctx context.Context) (string, error) {
 	repo, ok := ctx.Value("vars.name").(string)
 	if !ok {
@@ -18,7 +18,6 @@ import (
 	"github.com/uber/kraken/lib/store"
 	"github.com/docker/distribution/configuration"
 	"github.com/docker/distribution/context"
-	"github.com/docker/distribution/registry"
 	"github.com/uber-go/tally"
 )
 
@@ -62,8 +61,21 @@ func (c Config) ReadOnlyParameters(
 	}
 }
 
-// Build builds a new docker registry.
-func (c Config) Build(parameters configuration.Parameters) (*registry.Registry, error) {
+// Build builds a new docker registry. If the transferer in parameters is a
+// transfer.NamespaceAuthorizer, requests for unauthorized namespaces are
+// rejected with 403.
+func (c Config) Build(parameters configuration.Parameters) (*Registry, error) {
+	if a, ok := parameters["transferer"].(transfer.NamespaceAuthorizer); ok {
+		middleware := make(map[string][]configuration.Middleware)
+		for k, v := range c.Docker.Middleware {
+			middleware[k] = v
+		}
+		middleware["registry"] = append([]configuration.Middleware{{
+			Name:    _namespaceMiddleware,
+			Options: configuration.Parameters{"authorizer": a},
+		}}, c.Docker.Middleware["registry"]...)
+		c.Docker.Middleware = middleware
+	}
 	c.Docker.Storage = configuration.Storage{
 		Name: parameters,
 		// Redirect is enabled by default in docker registry.
@@ -72,5 +84,5 @@ func (c Config) Build(parameters configuration.Parameters) (*registry.Registry,
 			"disable": true,
 		},
 	}
-	return registry.NewRegistry(context.Background(), &c.Docker)
+	return newRegistry(context.Background(), &c.Docker)
 }
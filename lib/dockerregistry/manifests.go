@@ -14,6 +14,7 @@
 package dockerregistry
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -46,7 +47,9 @@ func newManifests(transferer transfer.ImageTransferer) *manifests {
 // The caller of storage driver would first call this function to resolve
 // the manifest link (and downloads manifest blob),
 // then call Stat or Reader which would assume the blob is on disk already.
-func (t *manifests) getDigest(path string, subtype PathSubType) ([]byte, error) {
+func (t *manifests) getDigest(
+	ctx context.Context, path string, subtype PathSubType) ([]byte, error) {
+
 	repo, err := GetRepo(path)
 	if err != nil {
 		return nil, fmt.Errorf("get repo: %s", err)
@@ -59,7 +62,7 @@ func (t *manifests) getDigest(path string, subtype PathSubType) ([]byte, error)
 		if err != nil {
 			return nil, fmt.Errorf("get manifest tag: %s", err)
 		}
-		digest, err = t.transferer.GetTag(fmt.Sprintf("%s:%s", repo, tag))
+		digest, err = t.transferer.GetTag(ctx, fmt.Sprintf("%s:%s", repo, tag))
 		if err != nil {
 			if err == transfer.ErrTagNotFound {
 				return nil, storagedriver.PathNotFoundError{
@@ -67,6 +70,7 @@ func (t *manifests) getDigest(path string, subtype PathSubType) ([]byte, error)
 					Path:       digest.String(),
 				}
 			}
+			setErrorStatus(ctx, err)
 			return nil, fmt.Errorf("transferer get tag: %s", err)
 		}
 	case _revisions:
@@ -79,7 +83,11 @@ func (t *manifests) getDigest(path string, subtype PathSubType) ([]byte, error)
 		return nil, &InvalidRequestError{path}
 	}
 
-	blob, err := t.transferer.Download(repo, digest)
+	download := t.transferer.Download
+	if md, ok := t.transferer.(transfer.ManifestDownloader); ok {
+		download = md.DownloadManifest
+	}
+	blob, err := download(ctx, repo, digest)
 	if err != nil {
 		if err == transfer.ErrBlobNotFound {
 			return nil, storagedriver.PathNotFoundError{
@@ -87,6 +95,7 @@ func (t *manifests) getDigest(path string, subtype PathSubType) ([]byte, error)
 				Path:       digest.String(),
 			}
 		}
+		setErrorStatus(ctx, err)
 		return nil, fmt.Errorf("transferer download: %s", err)
 	}
 	defer blob.Close()
@@ -94,7 +103,7 @@ func (t *manifests) getDigest(path string, subtype PathSubType) ([]byte, error)
 	return []byte(digest.String()), nil
 }
 
-func (t *manifests) putContent(path string, subtype PathSubType) error {
+func (t *manifests) putContent(ctx context.Context, path string, subtype PathSubType) error {
 	switch subtype {
 	case _tags:
 		repo, err := GetRepo(path)
@@ -112,7 +121,7 @@ func (t *manifests) putContent(path string, subtype PathSubType) error {
 		if err != nil {
 			return fmt.Errorf("get manifest digest: %s", err)
 		}
-		if err := t.transferer.PutTag(fmt.Sprintf("%s:%s", repo, tag), digest); err != nil {
+		if err := t.transferer.PutTag(ctx, fmt.Sprintf("%s:%s", repo, tag), digest); err != nil {
 			return fmt.Errorf("post tag: %s", err)
 		}
 		return nil
@@ -121,7 +130,7 @@ func (t *manifests) putContent(path string, subtype PathSubType) error {
 	return nil
 }
 
-func (t *manifests) stat(path string) (storagedriver.FileInfo, error) {
+func (t *manifests) stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
 	repo, err := GetRepo(path)
 	if err != nil {
 		return nil, fmt.Errorf("get repo: %s", err)
@@ -130,13 +139,14 @@ func (t *manifests) stat(path string) (storagedriver.FileInfo, error) {
 	if err != nil {
 		return nil, fmt.Errorf("get manifest tag: %s", err)
 	}
-	if _, err := t.transferer.GetTag(fmt.Sprintf("%s:%s", repo, tag)); err != nil {
+	if _, err := t.transferer.GetTag(ctx, fmt.Sprintf("%s:%s", repo, tag)); err != nil {
 		if err == transfer.ErrTagNotFound {
 			return nil, storagedriver.PathNotFoundError{
 				DriverName: "kraken",
 				Path:       path,
 			}
 		}
+		setErrorStatus(ctx, err)
 		return nil, fmt.Errorf("get tag: %s", err)
 	}
 	return storagedriver.FileInfoInternal{
@@ -149,9 +159,9 @@ func (t *manifests) stat(path string) (storagedriver.FileInfo, error) {
 	}, nil
 }
 
-func (t *manifests) list(path string) ([]string, error) {
+func (t *manifests) list(ctx context.Context, path string) ([]string, error) {
 	prefix := path[len(_repositoryRoot):]
-	tags, err := t.transferer.ListTags(prefix)
+	tags, err := t.transferer.ListTags(ctx, prefix)
 	if err != nil {
 		return nil, err
 	}
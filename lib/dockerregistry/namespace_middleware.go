@@ -0,0 +1,70 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dockerregistry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/uber/kraken/lib/dockerregistry/transfer"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	registrymiddleware "github.com/docker/distribution/registry/middleware/registry"
+)
+
+// _namespaceMiddleware is the name of the registry middleware which rejects
+// requests for namespaces the transferer does not authorize.
+const _namespaceMiddleware = "kraken_namespace"
+
+func init() {
+	if err := registrymiddleware.Register(_namespaceMiddleware, newNamespaceAuthorizingRegistry); err != nil {
+		panic(err)
+	}
+}
+
+// namespaceAuthorizingRegistry rejects repositories whose namespace is not
+// authorized with 403 before any blobs or manifests are accessed.
+type namespaceAuthorizingRegistry struct {
+	distribution.Namespace
+	authorizer transfer.NamespaceAuthorizer
+}
+
+func newNamespaceAuthorizingRegistry(
+	ctx context.Context,
+	registry distribution.Namespace,
+	options map[string]interface{}) (distribution.Namespace, error) {
+
+	authorizer, ok := options["authorizer"].(transfer.NamespaceAuthorizer)
+	if !ok {
+		return nil, errors.New("authorizer option required")
+	}
+	return &namespaceAuthorizingRegistry{registry, authorizer}, nil
+}
+
+// Repository returns the repository for name if its namespace is authorized.
+func (r *namespaceAuthorizingRegistry) Repository(
+	ctx context.Context, name reference.Named) (distribution.Repository, error) {
+
+	if err := r.authorizer.AuthorizeNamespace(name.Name()); err != nil {
+		if err == transfer.ErrNamespaceForbidden {
+			return nil, errcode.ErrorCodeDenied.WithMessage(
+				fmt.Sprintf("namespace %s is not served by this registry", name.Name()))
+		}
+		return nil, fmt.Errorf("authorize namespace: %s", err)
+	}
+	return r.Namespace.Repository(ctx, name)
+}
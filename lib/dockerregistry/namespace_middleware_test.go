@@ -0,0 +1,81 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dockerregistry
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/uber/kraken/lib/dockerregistry/transfer"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/stretchr/testify/require"
+)
+
+type namespaceAuthorizerFunc func(namespace string) error
+
+func (f namespaceAuthorizerFunc) AuthorizeNamespace(namespace string) error {
+	return f(namespace)
+}
+
+type fakeNamespace struct {
+	distribution.Namespace
+	requested []string
+}
+
+func (n *fakeNamespace) Repository(
+	ctx context.Context, name reference.Named) (distribution.Repository, error) {
+
+	n.requested = append(n.requested, name.Name())
+	return nil, nil
+}
+
+func TestNamespaceAuthorizingRegistry(t *testing.T) {
+	require := require.New(t)
+
+	authorizer := namespaceAuthorizerFunc(func(namespace string) error {
+		if namespace == "denied/repo" {
+			return transfer.ErrNamespaceForbidden
+		}
+		return nil
+	})
+	underlying := &fakeNamespace{}
+	registry, err := newNamespaceAuthorizingRegistry(
+		context.Background(), underlying, map[string]interface{}{"authorizer": authorizer})
+	require.NoError(err)
+
+	allowed, err := reference.WithName("allowed/repo")
+	require.NoError(err)
+	_, err = registry.Repository(context.Background(), allowed)
+	require.NoError(err)
+
+	denied, err := reference.WithName("denied/repo")
+	require.NoError(err)
+	_, err = registry.Repository(context.Background(), denied)
+	require.Error(err)
+	e, ok := err.(errcode.Error)
+	require.True(ok)
+	require.Equal(http.StatusForbidden, e.ErrorCode().Descriptor().HTTPStatusCode)
+
+	require.Equal([]string{"allowed/repo"}, underlying.requested)
+}
+
+func TestNamespaceAuthorizingRegistryRequiresAuthorizer(t *testing.T) {
+	_, err := newNamespaceAuthorizingRegistry(
+		context.Background(), &fakeNamespace{}, map[string]interface{}{})
+	require.Error(t, err)
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dockerregistry
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/uber/kraken/lib/dockerregistry/transfer"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/utils/syncutil"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/health"
+	"github.com/docker/distribution/registry/handlers"
+	"github.com/docker/distribution/registry/listener"
+	"github.com/sirupsen/logrus"
+)
+
+// Registry serves the docker registry API. Unlike registry.Registry, errors
+// returned by the storage driver may set the status of their response, which
+// docker registry otherwise always serves as 500.
+type Registry struct {
+	config *configuration.Configuration
+	server *http.Server
+}
+
+func newRegistry(ctx context.Context, config *configuration.Configuration) (*Registry, error) {
+	level, err := logrus.ParseLevel(string(config.Log.Level))
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+	logrus.SetLevel(level)
+
+	switch config.Log.Formatter {
+	case "", "text":
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return nil, fmt.Errorf("unsupported log formatter %q", config.Log.Formatter)
+	}
+
+	app := handlers.NewApp(ctx, config)
+	app.RegisterHealthChecks()
+
+	return &Registry{
+		config: config,
+		server: &http.Server{Handler: health.Handler(statusHandler(app))},
+	}, nil
+}
+
+// ListenAndServe is a blocking call which runs r.
+func (r *Registry) ListenAndServe() error {
+	ln, err := listener.NewListener(r.config.HTTP.Net, r.config.HTTP.Addr)
+	if err != nil {
+		return fmt.Errorf("listen: %s", err)
+	}
+	if r.config.HTTP.TLS.LetsEncrypt.CacheFile != "" {
+		return errors.New("let's encrypt is not supported")
+	}
+	if r.config.HTTP.TLS.Certificate != "" {
+		cert, err := tls.LoadX509KeyPair(r.config.HTTP.TLS.Certificate, r.config.HTTP.TLS.Key)
+		if err != nil {
+			return fmt.Errorf("load tls key pair: %s", err)
+		}
+		ln = tls.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{cert}})
+	}
+	return r.server.Serve(ln)
+}
+
+// Shutdown stops accepting requests and waits for in-flight requests to
+// complete, or for ctx to be done. ListenAndServe returns
+// http.ErrServerClosed once Shutdown is called.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	return r.server.Shutdown(ctx)
+}
+
+type statusKey struct{}
+
+// statusOverride is the status error responses to a request are served with,
+// instead of 500.
+type statusOverride struct {
+	mu     sync.Mutex
+	status int
+}
+
+func (o *statusOverride) get() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.status
+}
+
+func (o *statusOverride) set(status int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.status = status
+}
+
+// setErrorStatus serves the 500 response to the request of ctx with the status
+// of err instead, if err has one. Errors returned by storage drivers are always
+// served as 500s by docker registry.
+func setErrorStatus(ctx context.Context, err error) {
+	status := errorStatus(err)
+	if status == 0 {
+		return
+	}
+	if o, ok := ctx.Value(statusKey{}).(*statusOverride); ok {
+		o.set(status)
+	}
+}
+
+// errorStatus returns the status of responses to requests which failed with
+// err, or 0 if err should be served as 500.
+func errorStatus(err error) int {
+	switch {
+	case store.IsInsufficientStorage(err):
+		return http.StatusInsufficientStorage
+	case err == scheduler.ErrMaintenance:
+		return http.StatusServiceUnavailable
+	case err == syncutil.ErrQueueFull, err == syncutil.ErrQueueTimeout:
+		return http.StatusTooManyRequests
+	}
+	switch err.(type) {
+	case transfer.TagsUnavailableError:
+		return http.StatusServiceUnavailable
+	case transfer.BlobTooLargeError:
+		return http.StatusRequestEntityTooLarge
+	}
+	return 0
+}
+
+// statusWriter replaces 500 statuses with the status set by the storage
+// driver, if any.
+type statusWriter struct {
+	http.ResponseWriter
+	override *statusOverride
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if status == http.StatusInternalServerError {
+		if s := w.override.get(); s != 0 {
+			status = s
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// statusHandler allows the storage driver to set the status of error responses
+// served by h via setErrorStatus.
+func statusHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o := &statusOverride{}
+		ctx := context.WithValue(r.Context(), statusKey{}, o)
+		h.ServeHTTP(&statusWriter{w, o}, r.WithContext(ctx))
+	})
+}
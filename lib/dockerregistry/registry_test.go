@@ -0,0 +1,90 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dockerregistry
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/uber/kraken/lib/dockerregistry/transfer"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/utils/syncutil"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusHandler(t *testing.T) {
+	tests := []struct {
+		desc     string
+		err      error
+		status   int
+		expected int
+	}{
+		{
+			"insufficient storage",
+			store.InsufficientStorageError{Dir: "/tmp", Size: 10, Free: 1},
+			http.StatusInternalServerError,
+			http.StatusInsufficientStorage,
+		}, {
+			"maintenance",
+			scheduler.ErrMaintenance,
+			http.StatusInternalServerError,
+			http.StatusServiceUnavailable,
+		}, {
+			"tags unavailable",
+			transfer.TagsUnavailableError{Cause: errors.New("some error")},
+			http.StatusInternalServerError,
+			http.StatusServiceUnavailable,
+		}, {
+			"blob too large",
+			transfer.BlobTooLargeError{Digest: "sha256:abc", Size: 10, Max: 1},
+			http.StatusInternalServerError,
+			http.StatusRequestEntityTooLarge,
+		}, {
+			"download queue full",
+			syncutil.ErrQueueFull,
+			http.StatusInternalServerError,
+			http.StatusTooManyRequests,
+		}, {
+			"download queue timeout",
+			syncutil.ErrQueueTimeout,
+			http.StatusInternalServerError,
+			http.StatusTooManyRequests,
+		}, {
+			"unknown error",
+			errors.New("some error"),
+			http.StatusInternalServerError,
+			http.StatusInternalServerError,
+		}, {
+			"non-500 status",
+			store.InsufficientStorageError{Dir: "/tmp", Size: 10, Free: 1},
+			http.StatusNotFound,
+			http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			h := statusHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				setErrorStatus(r.Context(), test.err)
+				w.WriteHeader(test.status)
+			}))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "/v2/", nil))
+			require.Equal(t, test.expected, w.Code)
+		})
+	}
+}
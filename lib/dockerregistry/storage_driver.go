@@ -21,11 +21,15 @@ import (
 
 	"github.com/uber/kraken/lib/dockerregistry/transfer"
 	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/tracing"
 	"github.com/uber/kraken/utils/log"
 
+	dcontext "github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/factory"
 	"github.com/uber-go/tally"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // The path layout in the storage backend is roughly as follows:
@@ -150,6 +154,9 @@ func (d *KrakenStorageDriver) Name() string {
 // sample path: /docker/registry/v2/repositories/external/ubuntu/_layers/sha256/a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4/link
 func (d *KrakenStorageDriver) GetContent(ctx context.Context, path string) (data []byte, err error) {
 	log.Debugf("(*KrakenStorageDriver).GetContent %s", path)
+	ctx, span := startSpan(ctx, "registry.get_content", path)
+	defer func() { tracing.End(span, err) }()
+
 	pathType, pathSubType, err := ParsePath(path)
 	if err != nil {
 		return nil, err
@@ -157,7 +164,7 @@ func (d *KrakenStorageDriver) GetContent(ctx context.Context, path string) (data
 
 	switch pathType {
 	case _manifests:
-		return d.manifests.getDigest(path, pathSubType)
+		return d.manifests.getDigest(ctx, path, pathSubType)
 	case _uploads:
 		return d.uploads.getContent(path, pathSubType)
 	case _layers:
@@ -171,6 +178,9 @@ func (d *KrakenStorageDriver) GetContent(ctx context.Context, path string) (data
 // Reader returns a reader of path at offset
 func (d *KrakenStorageDriver) Reader(ctx context.Context, path string, offset int64) (reader io.ReadCloser, err error) {
 	log.Debugf("(*KrakenStorageDriver).Reader %s", path)
+	ctx, span := startSpan(ctx, "registry.reader", path)
+	defer func() { tracing.End(span, err) }()
+
 	pathType, pathSubType, err := ParsePath(path)
 	if err != nil {
 		return nil, err
@@ -196,7 +206,7 @@ func (d *KrakenStorageDriver) PutContent(ctx context.Context, path string, conte
 
 	switch pathType {
 	case _manifests:
-		return d.manifests.putContent(path, pathSubType)
+		return d.manifests.putContent(ctx, path, pathSubType)
 	case _uploads:
 		return d.uploads.putContent(path, pathSubType, content)
 	case _layers:
@@ -235,8 +245,11 @@ func (d *KrakenStorageDriver) Writer(ctx context.Context, path string, append bo
 }
 
 // Stat returns fileinfo of path
-func (d *KrakenStorageDriver) Stat(ctx context.Context, path string) (driver.FileInfo, error) {
+func (d *KrakenStorageDriver) Stat(ctx context.Context, path string) (fi driver.FileInfo, err error) {
 	log.Debugf("(*KrakenStorageDriver).Stat %s", path)
+	ctx, span := startSpan(ctx, "registry.stat", path)
+	defer func() { tracing.End(span, err) }()
+
 	pathType, _, err := ParsePath(path)
 	if err != nil {
 		return nil, err
@@ -248,7 +261,7 @@ func (d *KrakenStorageDriver) Stat(ctx context.Context, path string) (driver.Fil
 	case _blobs:
 		return d.blobs.stat(ctx, path)
 	case _manifests:
-		return d.manifests.stat(path)
+		return d.manifests.stat(ctx, path)
 	default:
 		return nil, InvalidRequestError{path}
 	}
@@ -266,7 +279,7 @@ func (d *KrakenStorageDriver) List(ctx context.Context, path string) ([]string,
 	case _uploads:
 		return d.uploads.list(path, pathSubType)
 	case _manifests:
-		return d.manifests.list(path)
+		return d.manifests.list(ctx, path)
 	default:
 		return nil, InvalidRequestError{path}
 	}
@@ -303,6 +316,16 @@ func (d *KrakenStorageDriver) URLFor(ctx context.Context, path string, options m
 	return "", fmt.Errorf("Not implemented")
 }
 
+// startSpan starts a span of a driver operation on path. The docker registry
+// does not pass request contexts to drivers, so the trace is continued from
+// the headers of the registry request which ctx was created for, if any.
+func startSpan(ctx context.Context, name, path string) (context.Context, trace.Span) {
+	if r, err := dcontext.GetRequest(ctx); err == nil {
+		ctx = tracing.Extract(ctx, r.Header)
+	}
+	return tracing.Start(ctx, name, trace.WithAttributes(attribute.String("path", path)))
+}
+
 // Walk is not implemented.
 func (d *KrakenStorageDriver) Walk(ctx context.Context, path string, f driver.WalkFn) error {
 	return errors.New("walk not implemented")
@@ -0,0 +1,131 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package transfer
+
+import (
+	"time"
+
+	"github.com/uber/kraken/lib/healthcheck"
+	"github.com/uber/kraken/utils/httputil"
+)
+
+// ReadOnlyConfig defines ReadOnlyTransferer configuration.
+type ReadOnlyConfig struct {
+	// PrefetchOnManifest starts downloads of all layers referenced by a
+	// manifest as soon as the manifest is downloaded, instead of waiting for
+	// the client to request each layer.
+	PrefetchOnManifest bool `yaml:"prefetch_on_manifest"`
+
+	// AllowedNamespaces restricts transfers to namespaces matching at least
+	// one of these regular expressions. If empty, all namespaces are allowed.
+	AllowedNamespaces []string `yaml:"allowed_namespaces"`
+
+	// DeniedNamespaces rejects transfers of namespaces matching any of these
+	// regular expressions, regardless of AllowedNamespaces.
+	DeniedNamespaces []string `yaml:"denied_namespaces"`
+
+	// OriginFallback configures downloading blobs directly from an origin when
+	// they cannot be downloaded as torrent.
+	OriginFallback OriginFallbackConfig `yaml:"origin_fallback"`
+
+	// BandwidthMetrics emits a counter of blob bytes served, tagged by
+	// namespace and by whether blobs were served from cache, downloaded as
+	// torrent or downloaded via the origin fallback. Disabled by default, since
+	// namespace tags may have high cardinality.
+	BandwidthMetrics bool `yaml:"bandwidth_metrics"`
+
+	// TagRetry retries tag resolutions which fail because the build-index is
+	// unavailable, e.g. 5XX responses or timeouts. Tags which do not exist are
+	// never retried. Disabled by default.
+	TagRetry httputil.ExponentialBackOffConfig `yaml:"tag_retry"`
+
+	// MaxBlobSize rejects manifests which reference blobs larger than this
+	// many bytes, according to the sizes of the manifest descriptors, before
+	// any of the blobs are downloaded. Zero disables the limit.
+	MaxBlobSize int64 `yaml:"max_blob_size"`
+
+	// OptimisticVerification serves blobs downloaded as torrent as soon as all
+	// of their pieces are assembled, and verifies them against their digest in
+	// the background, instead of before they are served. Pieces are still
+	// verified as they are received. Only suitable for trusted swarms, since
+	// corrupt blobs may be served before the mismatch is detected.
+	OptimisticVerification bool `yaml:"optimistic_verification"`
+
+	// SequentialStreaming switches the torrent of a blob to sequential piece
+	// selection while it is streamed, such that earlier bytes are served
+	// sooner, regardless of the scheduler's piece request policy.
+	SequentialStreaming bool `yaml:"sequential_streaming"`
+
+	// ManifestCache caches downloaded manifests in memory by digest, such
+	// that repeated pulls of the same image do not read them from disk or
+	// download them again after cache eviction.
+	ManifestCache ManifestCacheConfig `yaml:"manifest_cache"`
+}
+
+// OriginFallbackConfig defines the origin fallback of ReadOnlyTransferer.
+type OriginFallbackConfig struct {
+	// Addr is the address of the origin (or origin load balancer) which blobs
+	// are downloaded from. If empty, the fallback is disabled.
+	Addr string `yaml:"addr"`
+
+	// Timeout bounds how long a torrent download may take before it is
+	// abandoned for the fallback. If zero, the fallback is only used once the
+	// torrent download fails.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// MinPeers, if set, is the number of peers a torrent download must connect
+	// to within MinPeersTimeout before the agent commits to downloading it as
+	// torrent. Downloads which do not reach MinPeers in time are abandoned for
+	// the fallback, and committed downloads are no longer abandoned after
+	// Timeout, only if they fail.
+	MinPeers int `yaml:"min_peers"`
+
+	// MinPeersTimeout bounds how long a torrent download waits for MinPeers.
+	// Defaults to 30s.
+	MinPeersTimeout time.Duration `yaml:"min_peers_timeout"`
+
+	// Origins are weighted origins which blobs are downloaded from, in
+	// addition to Addr. Each download tries healthy origins in a random order
+	// biased by weight, e.g. to prefer origins in the same zone, and moves on
+	// to the next origin if one fails.
+	Origins []WeightedOrigin `yaml:"origins"`
+
+	// HealthCheck configures passive health checks of fallback origins.
+	// Origins failing with network errors are skipped until FailTimeout
+	// passes, unless all origins are unhealthy.
+	HealthCheck healthcheck.PassiveFilterConfig `yaml:"health_check"`
+}
+
+// WeightedOrigin defines a fallback origin and its relative weight.
+type WeightedOrigin struct {
+	Addr string `yaml:"addr"`
+
+	// Weight is the relative likelihood of the origin being tried first.
+	// Defaults to 1.
+	Weight int `yaml:"weight"`
+}
+
+func (c OriginFallbackConfig) minPeersTimeout() time.Duration {
+	if c.MinPeersTimeout == 0 {
+		return 30 * time.Second
+	}
+	return c.MinPeersTimeout
+}
+
+func (o WeightedOrigin) weight() int {
+	if o.Weight <= 0 {
+		return 1
+	}
+	return o.Weight
+}
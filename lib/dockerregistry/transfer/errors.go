@@ -13,10 +13,45 @@
 // limitations under the License.
 package transfer
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // ErrBlobNotFound is returned when a blob is not found by transferer.
 var ErrBlobNotFound = errors.New("blob not found")
 
 // ErrTagNotFound is returned when a tag is not found by transferer.
 var ErrTagNotFound = errors.New("tag not found")
+
+// ErrNamespaceForbidden is returned when a transfer of a namespace is forbidden
+// by transferer configuration.
+var ErrNamespaceForbidden = errors.New("namespace forbidden")
+
+// TagsUnavailableError is returned when a tag cannot be resolved because the
+// tag service is unavailable, as opposed to the tag not existing.
+type TagsUnavailableError struct {
+	Cause error
+}
+
+func (e TagsUnavailableError) Error() string {
+	return fmt.Sprintf("tags unavailable: %s", e.Cause)
+}
+
+// Unwrap returns the cause of e.
+func (e TagsUnavailableError) Unwrap() error {
+	return e.Cause
+}
+
+// BlobTooLargeError is returned when a manifest references a blob larger than
+// the max blob size of transferer.
+type BlobTooLargeError struct {
+	Digest string
+	Size   int64
+	Max    int64
+}
+
+func (e BlobTooLargeError) Error() string {
+	return fmt.Sprintf(
+		"blob %s of %d bytes exceeds max blob size of %d bytes", e.Digest, e.Size, e.Max)
+}
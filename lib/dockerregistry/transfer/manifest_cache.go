@@ -0,0 +1,123 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package transfer
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/andres-erbsen/clock"
+)
+
+// ManifestCacheConfig defines the in-memory cache of manifests by digest.
+// Since manifests are immutable, TTL can be long.
+type ManifestCacheConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl"`
+
+	// MaxEntries and MaxBytes bound the number and total size of cached
+	// manifests. Least recently used manifests are evicted first.
+	MaxEntries int   `yaml:"max_entries"`
+	MaxBytes   int64 `yaml:"max_bytes"`
+}
+
+func (c ManifestCacheConfig) applyDefaults() ManifestCacheConfig {
+	if c.TTL == 0 {
+		c.TTL = 24 * time.Hour
+	}
+	if c.MaxEntries == 0 {
+		c.MaxEntries = 1000
+	}
+	if c.MaxBytes == 0 {
+		c.MaxBytes = 64 << 20 // 64MB
+	}
+	return c
+}
+
+type manifestCacheEntry struct {
+	d       core.Digest
+	b       []byte
+	expires time.Time
+}
+
+// manifestCache is an LRU cache of manifest content by digest whose entries
+// expire after a TTL.
+type manifestCache struct {
+	config ManifestCacheConfig
+	clk    clock.Clock
+
+	mu      sync.Mutex
+	entries map[core.Digest]*list.Element
+	lru     *list.List
+	size    int64
+}
+
+func newManifestCache(config ManifestCacheConfig, clk clock.Clock) *manifestCache {
+	return &manifestCache{
+		config:  config.applyDefaults(),
+		clk:     clk,
+		entries: make(map[core.Digest]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// get returns the cached content of manifest d, if present and unexpired.
+func (c *manifestCache) get(d core.Digest) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[d]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*manifestCacheEntry)
+	if !c.clk.Now().Before(entry.expires) {
+		c.remove(e)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return entry.b, true
+}
+
+// set caches b as the content of manifest d, evicting least recently used
+// entries until the cache is within its limits. Manifests larger than the
+// max bytes of the cache are not cached.
+func (c *manifestCache) set(d core.Digest, b []byte) {
+	if int64(len(b)) > c.config.MaxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[d]; ok {
+		c.remove(e)
+	}
+	expires := c.clk.Now().Add(c.config.TTL)
+	c.entries[d] = c.lru.PushFront(&manifestCacheEntry{d, b, expires})
+	c.size += int64(len(b))
+	for c.lru.Len() > c.config.MaxEntries || c.size > c.config.MaxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *manifestCache) remove(e *list.Element) {
+	entry := e.Value.(*manifestCacheEntry)
+	c.lru.Remove(e)
+	delete(c.entries, entry.d)
+	c.size -= int64(len(entry.b))
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package transfer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestCacheExpiresEntries(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	c := newManifestCache(ManifestCacheConfig{TTL: time.Hour}, clk)
+
+	blob := core.NewBlobFixture()
+	c.set(blob.Digest, blob.Content)
+
+	result, ok := c.get(blob.Digest)
+	require.True(ok)
+	require.Equal(blob.Content, result)
+
+	clk.Add(time.Hour)
+
+	_, ok = c.get(blob.Digest)
+	require.False(ok)
+}
+
+func TestManifestCacheEvictsLeastRecentlyUsedByEntries(t *testing.T) {
+	require := require.New(t)
+
+	c := newManifestCache(ManifestCacheConfig{MaxEntries: 2}, clock.NewMock())
+
+	a := core.NewBlobFixture()
+	b := core.NewBlobFixture()
+	d := core.NewBlobFixture()
+
+	c.set(a.Digest, a.Content)
+	c.set(b.Digest, b.Content)
+
+	// Touch a so that b is evicted.
+	_, ok := c.get(a.Digest)
+	require.True(ok)
+
+	c.set(d.Digest, d.Content)
+
+	_, ok = c.get(a.Digest)
+	require.True(ok)
+	_, ok = c.get(b.Digest)
+	require.False(ok)
+	_, ok = c.get(d.Digest)
+	require.True(ok)
+}
+
+func TestManifestCacheEvictsLeastRecentlyUsedByBytes(t *testing.T) {
+	require := require.New(t)
+
+	c := newManifestCache(ManifestCacheConfig{MaxBytes: 10}, clock.NewMock())
+
+	a := core.DigestFixture()
+	b := core.DigestFixture()
+
+	c.set(a, []byte("aaaaaa"))
+	c.set(b, []byte("bbbbbb"))
+
+	_, ok := c.get(a)
+	require.False(ok)
+	_, ok = c.get(b)
+	require.True(ok)
+	require.Equal(int64(6), c.size)
+}
+
+func TestManifestCacheSkipsManifestsLargerThanMaxBytes(t *testing.T) {
+	require := require.New(t)
+
+	c := newManifestCache(ManifestCacheConfig{MaxBytes: 4}, clock.NewMock())
+
+	d := core.DigestFixture()
+	c.set(d, []byte("too large"))
+
+	_, ok := c.get(d)
+	require.False(ok)
+	require.Equal(int64(0), c.size)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package transfer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// namespaceFilter decides which namespaces may be transferred. Patterns are
+// regular expressions which must match the entire namespace.
+type namespaceFilter struct {
+	allowed []*regexp.Regexp
+	denied  []*regexp.Regexp
+}
+
+func newNamespaceFilter(allowed, denied []string) (*namespaceFilter, error) {
+	a, err := compileNamespacePatterns(allowed)
+	if err != nil {
+		return nil, fmt.Errorf("allowed namespaces: %s", err)
+	}
+	d, err := compileNamespacePatterns(denied)
+	if err != nil {
+		return nil, fmt.Errorf("denied namespaces: %s", err)
+	}
+	return &namespaceFilter{a, d}, nil
+}
+
+func compileNamespacePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("regexp %q: %s", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// allows returns true if namespace matches no denied pattern and, if any
+// allowed patterns are configured, matches at least one of them.
+func (f *namespaceFilter) allows(namespace string) bool {
+	for _, re := range f.denied {
+		if re.MatchString(namespace) {
+			return false
+		}
+	}
+	if len(f.allowed) == 0 {
+		return true
+	}
+	for _, re := range f.allowed {
+		if re.MatchString(namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagNamespace returns the namespace of a tag formatted as "repo:tag", or of a
+// digest reference formatted as "repo@digest".
+func tagNamespace(tag string) string {
+	if i := strings.Index(tag, "@"); i >= 0 {
+		return tag[:i]
+	}
+	if i := strings.LastIndex(tag, ":"); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package transfer
+
+import (
+	"testing"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceFilter(t *testing.T) {
+	tests := []struct {
+		desc      string
+		allowed   []string
+		denied    []string
+		namespace string
+		expected  bool
+	}{
+		{"no patterns allows all", nil, nil, "foo/bar", true},
+		{"allowed match", []string{"foo/.*"}, nil, "foo/bar", true},
+		{"allowed no match", []string{"foo/.*"}, nil, "baz/bar", false},
+		{"allowed must match entire namespace", []string{"foo/.*"}, nil, "baz/foo/bar", false},
+		{"denied match", nil, []string{"secret/.*"}, "secret/bar", false},
+		{"denied no match", nil, []string{"secret/.*"}, "foo/bar", true},
+		{"denied takes precedence", []string{".*"}, []string{"secret/.*"}, "secret/bar", false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			f, err := newNamespaceFilter(test.allowed, test.denied)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, f.allows(test.namespace))
+		})
+	}
+}
+
+func TestNamespaceFilterInvalidPattern(t *testing.T) {
+	_, err := newNamespaceFilter([]string{"("}, nil)
+	require.Error(t, err)
+}
+
+func TestTagNamespace(t *testing.T) {
+	require.Equal(t, "foo/bar", tagNamespace("foo/bar:latest"))
+	require.Equal(t, "foo/bar", tagNamespace("foo/bar"))
+	require.Equal(t, "foo/bar", tagNamespace("foo/bar@sha256:"+core.DigestFixture().Hex()))
+}
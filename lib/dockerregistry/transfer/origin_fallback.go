@@ -0,0 +1,227 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/uber-go/tally"
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/healthcheck"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/stringset"
+)
+
+// errP2PTimeout is returned when a torrent download is abandoned in favor of
+// the origin fallback.
+var errP2PTimeout = errors.New("p2p download timed out")
+
+// errInsufficientPeers is returned when a torrent download is abandoned in
+// favor of the origin fallback because too few peers connected.
+var errInsufficientPeers = errors.New("p2p download did not reach min peers")
+
+// originFallback downloads blobs directly from an origin when they cannot be
+// downloaded as torrent. Concurrent downloads of the same blob are
+// deduplicated.
+type originFallback struct {
+	origins []fallbackOrigin
+	health  healthcheck.PassiveFilter
+	cads    *store.CADownloadStore
+	stats   tally.Scope
+
+	// intn returns a random int in [0, n), for weighted origin selection.
+	intn func(n int) int
+
+	mu       sync.Mutex
+	inflight map[core.Digest]*fallbackCall
+}
+
+type fallbackOrigin struct {
+	addr   string
+	weight int
+	client blobclient.Client
+}
+
+type fallbackCall struct {
+	done chan struct{}
+	err  error
+}
+
+func newOriginFallback(
+	origins []fallbackOrigin,
+	health healthcheck.PassiveFilter,
+	cads *store.CADownloadStore,
+	stats tally.Scope) *originFallback {
+
+	return &originFallback{
+		origins:  origins,
+		health:   health,
+		cads:     cads,
+		stats:    stats.SubScope("origin_fallback"),
+		intn:     rand.Intn,
+		inflight: make(map[core.Digest]*fallbackCall),
+	}
+}
+
+// download downloads blob d from the origin into the cache. If a download of d
+// is already in progress, waits for it instead.
+func (f *originFallback) download(namespace string, d core.Digest) error {
+	f.mu.Lock()
+	if c, ok := f.inflight[d]; ok {
+		f.mu.Unlock()
+		<-c.done
+		return c.err
+	}
+	c := &fallbackCall{done: make(chan struct{})}
+	f.inflight[d] = c
+	f.mu.Unlock()
+
+	c.err = f.fetch(namespace, d)
+	if c.err != nil {
+		f.stats.Counter("errors").Inc(1)
+	}
+
+	f.mu.Lock()
+	delete(f.inflight, d)
+	f.mu.Unlock()
+	close(c.done)
+
+	return c.err
+}
+
+// fetch downloads blob d from the first origin which succeeds, trying origins
+// in weighted random order. The next origin is only tried if an origin is
+// unreachable or fails with a server error, since all origins would reject
+// the download otherwise.
+func (f *originFallback) fetch(namespace string, d core.Digest) error {
+	if _, err := f.cads.Cache().GetFileStat(d.Hex()); err == nil {
+		// A torrent download finished in the meantime.
+		return nil
+	}
+	f.stats.Counter("downloads").Inc(1)
+
+	var errs []string
+	for _, o := range f.order() {
+		err := f.fetchFrom(o.client, namespace, d)
+		if err == nil {
+			return nil
+		}
+		if err == ErrBlobNotFound {
+			return ErrBlobNotFound
+		}
+		if httputil.IsNetworkError(err) {
+			f.health.Failed(o.addr)
+		}
+		f.stats.Tagged(map[string]string{"origin": o.addr}).Counter("origin_errors").Inc(1)
+		errs = append(errs, fmt.Sprintf("origin %s: %s", o.addr, err))
+		if !shouldFailOver(err) {
+			break
+		}
+	}
+	return errors.New(strings.Join(errs, ", "))
+}
+
+// shouldFailOver returns true if err, returned by an origin, is a transport
+// error or a server error which another origin may not return.
+func shouldFailOver(err error) bool {
+	if httputil.IsNetworkError(err) {
+		return true
+	}
+	se, ok := err.(httputil.StatusError)
+	return ok && se.Status >= 500
+}
+
+// order returns the healthy origins in random order, where origins with
+// higher weights are more likely to come first. If all origins are unhealthy,
+// all origins are returned.
+func (f *originFallback) order() []fallbackOrigin {
+	addrs := make(stringset.Set)
+	for _, o := range f.origins {
+		addrs.Add(o.addr)
+	}
+	healthy := f.health.Run(addrs)
+
+	var candidates []fallbackOrigin
+	var total int
+	for _, o := range f.origins {
+		if len(healthy) == 0 || healthy.Has(o.addr) {
+			candidates = append(candidates, o)
+			total += o.weight
+		}
+	}
+	result := make([]fallbackOrigin, 0, len(candidates))
+	for len(candidates) > 0 {
+		n := f.intn(total)
+		for i, o := range candidates {
+			if n < o.weight {
+				result = append(result, o)
+				total -= o.weight
+				candidates = append(candidates[:i], candidates[i+1:]...)
+				break
+			}
+			n -= o.weight
+		}
+	}
+	return result
+}
+
+func (f *originFallback) fetchFrom(client blobclient.Client, namespace string, d core.Digest) error {
+	info, err := client.Stat(namespace, d)
+	if err != nil {
+		if err == blobclient.ErrBlobNotFound {
+			return ErrBlobNotFound
+		}
+		return err
+	}
+	// The download file may be left over from the failed torrent or a failed
+	// origin, in which case it is overwritten.
+	err = f.cads.CreateDownloadFile(d.Hex(), info.Size)
+	if err != nil && !os.IsExist(err) && !f.cads.InDownloadError(err) {
+		return fmt.Errorf("create download file: %s", err)
+	}
+	if err := f.cads.AddNamespace(d.Hex(), namespace); err != nil {
+		return fmt.Errorf("add namespace: %s", err)
+	}
+	w, err := f.cads.GetDownloadFileReadWriter(d.Hex())
+	if err != nil {
+		return fmt.Errorf("get download file: %s", err)
+	}
+	defer w.Close()
+
+	if err := client.DownloadBlob(namespace, d, w); err != nil {
+		return err
+	}
+	if _, err := w.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek: %s", err)
+	}
+	result, err := d.Digester().FromReader(w)
+	if err != nil {
+		return fmt.Errorf("digest: %s", err)
+	}
+	if result != d {
+		return fmt.Errorf("digest mismatch: got %s", result)
+	}
+	if err := f.cads.MoveDownloadFileToCache(d.Hex()); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("move download file to cache: %s", err)
+	}
+	return nil
+}
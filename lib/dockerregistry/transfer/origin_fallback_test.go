@@ -0,0 +1,480 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package transfer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/healthcheck"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/mocks/origin/blobclient"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/utils/httputil"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+func (m *agentTransfererMocks) newWithFallback(
+	config ReadOnlyConfig, fallback blobclient.Client) *ReadOnlyTransferer {
+
+	t, err := NewReadOnlyTransferer(
+		config, tally.NoopScope, m.cads, m.tags, m.sched, zap.NewNop(), WithOriginFallback(fallback))
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func expectFallbackDownload(fallback *mockblobclient.MockClient, namespace string, blob *core.BlobFixture) {
+	fallback.EXPECT().Stat(namespace, blob.Digest).Return(core.NewBlobInfo(int64(len(blob.Content))), nil)
+	fallback.EXPECT().DownloadBlob(namespace, blob.Digest, gomock.Any()).DoAndReturn(
+		func(namespace string, d core.Digest, dst io.Writer) error {
+			_, err := dst.Write(blob.Content)
+			return err
+		})
+}
+
+func TestReadOnlyTransfererDownloadFallsBackToOrigin(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fallback := mockblobclient.NewMockClient(ctrl)
+
+	transferer := mocks.newWithFallback(ReadOnlyConfig{}, fallback)
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.NewBlobFixture()
+
+	mocks.sched.EXPECT().Download(namespace, blob.Digest).Return(errors.New("some error"))
+	expectFallbackDownload(fallback, namespace, blob)
+
+	result, err := transferer.Download(context.Background(), namespace, blob.Digest)
+	require.NoError(err)
+	b, err := ioutil.ReadAll(result)
+	require.NoError(err)
+	require.Equal(blob.Content, b)
+
+	// Subsequent downloads are served from cache.
+	result, err = transferer.Download(context.Background(), namespace, blob.Digest)
+	require.NoError(err)
+	b, err = ioutil.ReadAll(result)
+	require.NoError(err)
+	require.Equal(blob.Content, b)
+}
+
+func TestReadOnlyTransfererFallbackTimeoutRemovesTorrent(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fallback := mockblobclient.NewMockClient(ctrl)
+
+	transferer := mocks.newWithFallback(ReadOnlyConfig{
+		OriginFallback: OriginFallbackConfig{Timeout: 100 * time.Millisecond},
+	}, fallback)
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.NewBlobFixture()
+
+	removed := make(chan struct{})
+
+	mocks.sched.EXPECT().Download(namespace, blob.Digest).DoAndReturn(
+		func(namespace string, d core.Digest) error {
+			<-removed
+			return errors.New("torrent removed")
+		})
+	mocks.sched.EXPECT().RemoveTorrent(blob.Digest).DoAndReturn(func(d core.Digest) error {
+		close(removed)
+		return nil
+	})
+	expectFallbackDownload(fallback, namespace, blob)
+
+	info, err := transferer.Stat(context.Background(), namespace, blob.Digest)
+	require.NoError(err)
+	require.Equal(int64(len(blob.Content)), info.Size)
+}
+
+func TestReadOnlyTransfererFallbackBlobNotFound(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fallback := mockblobclient.NewMockClient(ctrl)
+
+	transferer := mocks.newWithFallback(ReadOnlyConfig{}, fallback)
+
+	namespace := "docker/repo-bar:latest"
+	d := core.DigestFixture()
+
+	mocks.sched.EXPECT().Download(namespace, d).Return(errors.New("some error"))
+	fallback.EXPECT().Stat(namespace, d).Return(nil, blobclient.ErrBlobNotFound)
+
+	_, err := transferer.Download(context.Background(), namespace, d)
+	require.Equal(ErrBlobNotFound, err)
+}
+
+func TestReadOnlyTransfererFallbackRejectsCorruptBlob(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fallback := mockblobclient.NewMockClient(ctrl)
+
+	transferer := mocks.newWithFallback(ReadOnlyConfig{}, fallback)
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.NewBlobFixture()
+	corrupt := &core.BlobFixture{Digest: blob.Digest, Content: core.NewBlobFixture().Content}
+
+	mocks.sched.EXPECT().Download(namespace, blob.Digest).Return(errors.New("some error"))
+	expectFallbackDownload(fallback, namespace, corrupt)
+
+	_, err := transferer.Download(context.Background(), namespace, blob.Digest)
+	require.Error(err)
+
+	_, err = mocks.cads.Cache().GetFileStat(blob.Digest.Hex())
+	require.Error(err)
+}
+
+func TestReadOnlyTransfererFallbackDeduplicatesConcurrentDownloads(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fallback := mockblobclient.NewMockClient(ctrl)
+
+	transferer := mocks.newWithFallback(ReadOnlyConfig{}, fallback)
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.NewBlobFixture()
+
+	mocks.sched.EXPECT().Download(namespace, blob.Digest).Return(errors.New("some error")).AnyTimes()
+	fallback.EXPECT().Stat(namespace, blob.Digest).Return(core.NewBlobInfo(int64(len(blob.Content))), nil)
+	fallback.EXPECT().DownloadBlob(namespace, blob.Digest, gomock.Any()).DoAndReturn(
+		func(namespace string, d core.Digest, dst io.Writer) error {
+			// Give the other downloads time to join.
+			time.Sleep(100 * time.Millisecond)
+			_, err := dst.Write(blob.Content)
+			return err
+		})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := transferer.Download(context.Background(), namespace, blob.Digest)
+			require.NoError(err)
+			b, err := ioutil.ReadAll(result)
+			require.NoError(err)
+			require.Equal(blob.Content, b)
+		}()
+	}
+	wg.Wait()
+}
+
+type fallbackProviderFixture map[string]blobclient.Client
+
+func (p fallbackProviderFixture) Provide(addr string) blobclient.Client {
+	return p[addr]
+}
+
+func TestReadOnlyTransfererFallbackSkipsUnhealthyOrigins(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	origin1 := mockblobclient.NewMockClient(ctrl)
+	origin2 := mockblobclient.NewMockClient(ctrl)
+
+	config := ReadOnlyConfig{}
+	config.OriginFallback.Origins = []WeightedOrigin{{Addr: "origin1"}, {Addr: "origin2"}}
+	config.OriginFallback.HealthCheck.Fails = 1
+
+	transferer, err := NewReadOnlyTransferer(
+		config, tally.NoopScope, mocks.cads, mocks.tags, mocks.sched, zap.NewNop(),
+		WithOriginFallbackProvider(fallbackProviderFixture{"origin1": origin1, "origin2": origin2}))
+	require.NoError(err)
+	// Always try origins in configured order.
+	transferer.fallback.intn = func(int) int { return 0 }
+
+	namespace := "docker/repo-bar:latest"
+	blob1 := core.NewBlobFixture()
+	blob2 := core.NewBlobFixture()
+
+	mocks.sched.EXPECT().Download(namespace, gomock.Any()).Return(errors.New("some error")).Times(2)
+	origin1.EXPECT().Stat(namespace, blob1.Digest).Return(nil, httputil.NetworkError{})
+	expectFallbackDownload(origin2, namespace, blob1)
+
+	_, err = transferer.Download(context.Background(), namespace, blob1.Digest)
+	require.NoError(err)
+
+	// origin1 is now unhealthy, so it is skipped.
+	expectFallbackDownload(origin2, namespace, blob2)
+
+	_, err = transferer.Download(context.Background(), namespace, blob2.Digest)
+	require.NoError(err)
+}
+
+func TestReadOnlyTransfererFallbackFailsOverAfterPartialDownload(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	origin1 := mockblobclient.NewMockClient(ctrl)
+	origin2 := mockblobclient.NewMockClient(ctrl)
+
+	config := ReadOnlyConfig{}
+	config.OriginFallback.Origins = []WeightedOrigin{{Addr: "origin1"}, {Addr: "origin2"}}
+
+	transferer, err := NewReadOnlyTransferer(
+		config, tally.NoopScope, mocks.cads, mocks.tags, mocks.sched, zap.NewNop(),
+		WithOriginFallbackProvider(fallbackProviderFixture{"origin1": origin1, "origin2": origin2}))
+	require.NoError(err)
+	// Always try origins in configured order.
+	transferer.fallback.intn = func(int) int { return 0 }
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.NewBlobFixture()
+
+	mocks.sched.EXPECT().Download(namespace, blob.Digest).Return(errors.New("some error"))
+	origin1.EXPECT().Stat(namespace, blob.Digest).Return(core.NewBlobInfo(int64(len(blob.Content))), nil)
+	origin1.EXPECT().DownloadBlob(namespace, blob.Digest, gomock.Any()).DoAndReturn(
+		func(namespace string, d core.Digest, dst io.Writer) error {
+			if _, err := dst.Write(blob.Content[:len(blob.Content)/2]); err != nil {
+				return err
+			}
+			return httputil.NetworkError{}
+		})
+	expectFallbackDownload(origin2, namespace, blob)
+
+	result, err := transferer.Download(context.Background(), namespace, blob.Digest)
+	require.NoError(err)
+	b, err := ioutil.ReadAll(result)
+	require.NoError(err)
+	require.Equal(blob.Content, b)
+}
+
+func TestReadOnlyTransfererFallbackFailsOverOnlyOnTransientErrors(t *testing.T) {
+	tests := []struct {
+		desc     string
+		err      error
+		failOver bool
+	}{
+		{"network error", httputil.NetworkError{}, true},
+		{"server error", httputil.StatusError{Status: 503}, true},
+		{"not found", blobclient.ErrBlobNotFound, false},
+		{"client error", httputil.StatusError{Status: 403}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			mocks, cleanup := newReadOnlyTransfererMocks(t)
+			defer cleanup()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			origin1 := mockblobclient.NewMockClient(ctrl)
+			origin2 := mockblobclient.NewMockClient(ctrl)
+
+			config := ReadOnlyConfig{}
+			config.OriginFallback.Origins = []WeightedOrigin{{Addr: "origin1"}, {Addr: "origin2"}}
+
+			transferer, err := NewReadOnlyTransferer(
+				config, tally.NoopScope, mocks.cads, mocks.tags, mocks.sched, zap.NewNop(),
+				WithOriginFallbackProvider(fallbackProviderFixture{"origin1": origin1, "origin2": origin2}))
+			require.NoError(err)
+			// Always try origins in configured order.
+			transferer.fallback.intn = func(int) int { return 0 }
+
+			namespace := "docker/repo-bar:latest"
+			blob := core.NewBlobFixture()
+
+			mocks.sched.EXPECT().Download(namespace, blob.Digest).Return(errors.New("some error"))
+			origin1.EXPECT().Stat(namespace, blob.Digest).Return(nil, test.err)
+			if test.failOver {
+				expectFallbackDownload(origin2, namespace, blob)
+			}
+
+			_, err = transferer.Download(context.Background(), namespace, blob.Digest)
+			if test.failOver {
+				require.NoError(err)
+			} else {
+				require.Error(err)
+			}
+		})
+	}
+}
+
+func TestOriginFallbackOrderPrefersHeavierOrigins(t *testing.T) {
+	require := require.New(t)
+
+	origins := []fallbackOrigin{{addr: "light", weight: 1}, {addr: "heavy", weight: 3}}
+	health := healthcheck.NewPassiveFilter(healthcheck.PassiveFilterConfig{}, clock.NewMock())
+	f := newOriginFallback(origins, health, nil, tally.NoopScope)
+
+	tests := []struct {
+		n        int
+		expected []string
+	}{
+		{0, []string{"light", "heavy"}},
+		{1, []string{"heavy", "light"}},
+		{3, []string{"heavy", "light"}},
+	}
+	for _, test := range tests {
+		f.intn = func(total int) int {
+			if total < 4 {
+				// Only one origin remains.
+				return 0
+			}
+			return test.n
+		}
+		var result []string
+		for _, o := range f.order() {
+			result = append(result, o.addr)
+		}
+		require.Equal(test.expected, result)
+	}
+}
+
+func TestReadOnlyTransfererFallbackMinPeersNotReached(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fallback := mockblobclient.NewMockClient(ctrl)
+
+	transferer := mocks.newWithFallback(ReadOnlyConfig{
+		OriginFallback: OriginFallbackConfig{
+			MinPeers:        2,
+			MinPeersTimeout: 100 * time.Millisecond,
+		},
+	}, fallback)
+	transferer.peerPollInterval = 10 * time.Millisecond
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.NewBlobFixture()
+
+	removed := make(chan struct{})
+
+	mocks.sched.EXPECT().Download(namespace, blob.Digest).DoAndReturn(
+		func(namespace string, d core.Digest) error {
+			<-removed
+			return errors.New("torrent removed")
+		})
+	mocks.sched.EXPECT().TorrentStats().Return([]scheduler.TorrentStats{{
+		Digest: blob.Digest,
+		Peers:  []scheduler.PeerStats{{}},
+	}}, nil).AnyTimes()
+	mocks.sched.EXPECT().RemoveTorrent(blob.Digest).DoAndReturn(func(d core.Digest) error {
+		close(removed)
+		return nil
+	})
+	expectFallbackDownload(fallback, namespace, blob)
+
+	result, err := transferer.Download(context.Background(), namespace, blob.Digest)
+	require.NoError(err)
+	b, err := ioutil.ReadAll(result)
+	require.NoError(err)
+	require.Equal(blob.Content, b)
+}
+
+func TestReadOnlyTransfererFallbackMinPeersReachedCommitsToTorrent(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fallback := mockblobclient.NewMockClient(ctrl)
+
+	transferer := mocks.newWithFallback(ReadOnlyConfig{
+		OriginFallback: OriginFallbackConfig{
+			Timeout:         50 * time.Millisecond,
+			MinPeers:        2,
+			MinPeersTimeout: time.Second,
+		},
+	}, fallback)
+	transferer.peerPollInterval = 10 * time.Millisecond
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.NewBlobFixture()
+
+	// The download outlasts Timeout, but is not abandoned since min peers
+	// were reached first.
+	mocks.sched.EXPECT().Download(namespace, blob.Digest).DoAndReturn(
+		func(namespace string, d core.Digest) error {
+			time.Sleep(200 * time.Millisecond)
+			return store.RunDownload(mocks.cads, d, blob.Content)
+		})
+	mocks.sched.EXPECT().TorrentStats().Return([]scheduler.TorrentStats{{
+		Digest: blob.Digest,
+		Peers:  []scheduler.PeerStats{{}, {}},
+	}}, nil).AnyTimes()
+
+	result, err := transferer.Download(context.Background(), namespace, blob.Digest)
+	require.NoError(err)
+	b, err := ioutil.ReadAll(result)
+	require.NoError(err)
+	require.Equal(blob.Content, b)
+}
@@ -14,48 +14,203 @@
 package transfer
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/andres-erbsen/clock"
+	"github.com/cenkalti/backoff"
+	"github.com/docker/distribution"
+	"github.com/uber-go/tally"
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/healthcheck"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/lib/torrent/scheduler"
-	"github.com/uber-go/tally"
+	"github.com/uber/kraken/lib/torrent/scheduler/dispatch/piecerequest"
+	"github.com/uber/kraken/lib/torrent/storage/agentstorage"
+	"github.com/uber/kraken/lib/tracing"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/utils/dockerutil"
+	"github.com/uber/kraken/utils/log"
+	"github.com/uber/kraken/utils/syncutil"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	_ ImageTransferer     = (*ReadOnlyTransferer)(nil)
+	_ RangeDownloader     = (*ReadOnlyTransferer)(nil)
+	_ ManifestDownloader  = (*ReadOnlyTransferer)(nil)
+	_ NamespaceAuthorizer = (*ReadOnlyTransferer)(nil)
+)
+
+const (
+	_streamPollInterval = 100 * time.Millisecond
+	_peerPollInterval   = 500 * time.Millisecond
+
+	// _sequentialPolicyAttempts bounds how many times the piece request policy
+	// of a streamed torrent is set before the scheduler has registered it.
+	_sequentialPolicyAttempts = 10
 )
 
-var _ ImageTransferer = (*ReadOnlyTransferer)(nil)
+// Sources of served blobs, for bandwidth accounting.
+const (
+	_sourceCache          = "cache"
+	_sourceP2P            = "p2p"
+	_sourceOriginFallback = "origin_fallback"
+)
 
 // ReadOnlyTransferer gets and posts manifest to tracker, and transfers blobs as torrent.
 type ReadOnlyTransferer struct {
-	stats tally.Scope
-	cads  *store.CADownloadStore
-	tags  tagclient.Client
-	sched scheduler.Scheduler
+	config ReadOnlyConfig
+	stats  tally.Scope
+	cads   *store.CADownloadStore
+	tags   tagclient.Client
+	sched  scheduler.Scheduler
+
+	namespaces *namespaceFilter
+
+	// accessLog receives a structured entry for each tag resolution and blob
+	// download.
+	accessLog *zap.Logger
+
+	// fallback is nil if the origin fallback is disabled.
+	fallback         *originFallback
+	fallbackClient   blobclient.Client
+	fallbackProvider blobclient.Provider
+
+	// fetches coalesces concurrent fetches of the same blob.
+	fetches singleflight.Group
+
+	// fetchLimiter limits concurrent fetches of distinct blobs.
+	fetchLimiter *syncutil.Limiter
+
+	// manifests is nil if the manifest cache is disabled.
+	manifests *manifestCache
+
+	streamPollInterval time.Duration
+	peerPollInterval   time.Duration
+}
+
+// ReadOnlyOption allows setting optional ReadOnlyTransferer parameters.
+type ReadOnlyOption func(*ReadOnlyTransferer)
+
+// WithOriginFallback configures a ReadOnlyTransferer to download blobs from
+// client when they cannot be downloaded as torrent.
+func WithOriginFallback(client blobclient.Client) ReadOnlyOption {
+	return func(t *ReadOnlyTransferer) { t.fallbackClient = client }
+}
+
+// WithOriginFallbackProvider configures a ReadOnlyTransferer to download blobs
+// from the weighted origins of OriginFallbackConfig, using clients from p,
+// when they cannot be downloaded as torrent.
+func WithOriginFallbackProvider(p blobclient.Provider) ReadOnlyOption {
+	return func(t *ReadOnlyTransferer) { t.fallbackProvider = p }
+}
+
+// WithDownloadLimiter limits concurrent blob downloads with l, which may be
+// shared with other components downloading blobs.
+func WithDownloadLimiter(l *syncutil.Limiter) ReadOnlyOption {
+	return func(t *ReadOnlyTransferer) { t.fetchLimiter = l }
 }
 
 // NewReadOnlyTransferer creates a new ReadOnlyTransferer.
 func NewReadOnlyTransferer(
+	config ReadOnlyConfig,
 	stats tally.Scope,
 	cads *store.CADownloadStore,
 	tags tagclient.Client,
-	sched scheduler.Scheduler) *ReadOnlyTransferer {
+	sched scheduler.Scheduler,
+	accessLog *zap.Logger,
+	opts ...ReadOnlyOption) (*ReadOnlyTransferer, error) {
 
 	stats = stats.Tagged(map[string]string{
 		"module": "rotransferer",
 	})
 
-	return &ReadOnlyTransferer{stats, cads, tags, sched}
+	namespaces, err := newNamespaceFilter(config.AllowedNamespaces, config.DeniedNamespaces)
+	if err != nil {
+		return nil, fmt.Errorf("namespace filter: %s", err)
+	}
+
+	t := &ReadOnlyTransferer{
+		config:             config,
+		stats:              stats,
+		cads:               cads,
+		tags:               tags,
+		sched:              sched,
+		namespaces:         namespaces,
+		accessLog:          accessLog,
+		streamPollInterval: _streamPollInterval,
+		peerPollInterval:   _peerPollInterval,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	var origins []fallbackOrigin
+	if t.fallbackClient != nil {
+		origins = append(origins, fallbackOrigin{config.OriginFallback.Addr, 1, t.fallbackClient})
+	}
+	if t.fallbackProvider != nil {
+		for _, o := range config.OriginFallback.Origins {
+			origins = append(origins, fallbackOrigin{o.Addr, o.weight(), t.fallbackProvider.Provide(o.Addr)})
+		}
+	}
+	if len(origins) > 0 {
+		health := healthcheck.NewPassiveFilter(config.OriginFallback.HealthCheck, clock.New())
+		t.fallback = newOriginFallback(origins, health, cads, stats)
+	}
+	if t.fetchLimiter == nil {
+		t.fetchLimiter = syncutil.NewLimiter(syncutil.LimiterConfig{})
+	}
+	if config.ManifestCache.Enabled {
+		t.manifests = newManifestCache(config.ManifestCache, clock.New())
+	}
+	return t, nil
+}
+
+// AuthorizeNamespace returns ErrNamespaceForbidden if t is not configured to
+// transfer namespace.
+func (t *ReadOnlyTransferer) AuthorizeNamespace(namespace string) error {
+	if !t.namespaces.allows(namespace) {
+		t.stats.Counter("namespace_forbidden").Inc(1)
+		return ErrNamespaceForbidden
+	}
+	return nil
 }
 
 // Stat returns blob info from local cache, and triggers download if the blob is
-// not available locally.
-func (t *ReadOnlyTransferer) Stat(namespace string, d core.Digest) (*core.BlobInfo, error) {
+// not available locally. If the blob is already being downloaded, its size is
+// returned from the torrent metainfo without waiting for the download.
+func (t *ReadOnlyTransferer) Stat(
+	ctx context.Context, namespace string, d core.Digest) (bi *core.BlobInfo, err error) {
+
+	ctx, span := tracing.Start(ctx, "transferer.stat", blobSpan(namespace, d))
+	defer func() { tracing.End(span, err) }()
+
+	if err := t.AuthorizeNamespace(namespace); err != nil {
+		return nil, err
+	}
 	fi, err := t.cads.Cache().GetFileStat(d.Hex())
+	if t.cads.InDownloadError(err) {
+		if mi, err := agentstorage.GetMetaInfo(t.cads, d); err == nil {
+			return core.NewBlobInfo(mi.Length()), nil
+		}
+	}
 	if os.IsNotExist(err) || t.cads.InDownloadError(err) {
-		if err := t.sched.Download(namespace, d); err != nil {
-			return nil, fmt.Errorf("scheduler: %s", err)
+		if _, err := t.fetchOnce(ctx, namespace, d); err != nil {
+			return nil, err
 		}
 		fi, err = t.cads.Cache().GetFileStat(d.Hex())
 		if err != nil {
@@ -68,19 +223,567 @@ func (t *ReadOnlyTransferer) Stat(namespace string, d core.Digest) (*core.BlobIn
 }
 
 // Download downloads blobs as torrent.
-func (t *ReadOnlyTransferer) Download(namespace string, d core.Digest) (store.FileReader, error) {
+func (t *ReadOnlyTransferer) Download(
+	ctx context.Context, namespace string, d core.Digest) (store.FileReader, error) {
+
+	ctx, span := tracing.Start(ctx, "transferer.download", blobSpan(namespace, d))
+	start := time.Now()
+	f, source, err := t.download(ctx, namespace, d)
+	span.SetAttributes(attribute.String("source", source))
+	tracing.End(span, err)
+	t.logDownload(namespace, d, start, source, f, err)
+	if f != nil {
+		f = t.countServed(namespace, source, f)
+	}
+	return f, err
+}
+
+// logDownload writes an access log entry for a download of blob d which
+// started at start and was served from source.
+func (t *ReadOnlyTransferer) logDownload(
+	namespace string, d core.Digest, start time.Time, source string, f store.FileReader, err error) {
+
+	ce := t.checkAccessLog(err)
+	if ce == nil {
+		return
+	}
+	downloaded := source == _sourceP2P || source == _sourceOriginFallback
+	fields := []zap.Field{
+		zap.String("method", "download"),
+		zap.String("namespace", namespace),
+		zap.String("digest", d.String()),
+		zap.Duration("duration", time.Since(start)),
+		zap.String("outcome", downloadOutcome(downloaded, err)),
+	}
+	if f != nil {
+		fields = append(fields, zap.Int64("bytes", f.Size()))
+	}
+	if downloaded {
+		fields = append(fields, zap.Int("peers", t.numPeers(d)))
+	}
+	ce.Write(append(fields, errorField(err)...)...)
+}
+
+// download returns a reader of blob d, and the source d was served from. The
+// source is empty if d was never looked up.
+func (t *ReadOnlyTransferer) download(
+	ctx context.Context, namespace string, d core.Digest) (store.FileReader, string, error) {
+
+	if err := t.AuthorizeNamespace(namespace); err != nil {
+		return nil, "", err
+	}
 	f, err := t.cads.Cache().GetFileReader(d.Hex())
 	if os.IsNotExist(err) || t.cads.InDownloadError(err) {
-		if err := t.sched.Download(namespace, d); err != nil {
-			return nil, fmt.Errorf("scheduler: %s", err)
+		source, err := t.fetchOnce(ctx, namespace, d)
+		if err != nil {
+			return nil, source, err
 		}
 		f, err = t.cads.Cache().GetFileReader(d.Hex())
 		if err != nil {
-			return nil, fmt.Errorf("cache: %s", err)
+			return nil, source, fmt.Errorf("cache: %s", err)
 		}
+		return f, source, nil
 	} else if err != nil {
+		return nil, _sourceCache, fmt.Errorf("cache: %s", err)
+	}
+	// Blobs served in several namespaces expire by the shortest TTL of them.
+	if err := t.cads.AddNamespace(d.Hex(), namespace); err != nil {
+		log.With("blob", d.Hex(), "namespace", namespace).Errorf("Error adding namespace: %s", err)
+	}
+	return f, _sourceCache, nil
+}
+
+// fetchOnce fetches blob d. Concurrent fetches of d in namespace share a single
+// download, whose result is returned to all of them. The download is traced as
+// part of the first fetch.
+func (t *ReadOnlyTransferer) fetchOnce(
+	ctx context.Context, namespace string, d core.Digest) (string, error) {
+
+	source, err, shared := t.fetches.Do(namespace+":"+d.Hex(), func() (interface{}, error) {
+		if err := t.fetchLimiter.Acquire(); err != nil {
+			t.stats.Counter("download_limit_rejected").Inc(1)
+			return "", err
+		}
+		defer t.fetchLimiter.Release()
+		return t.fetch(ctx, namespace, d)
+	})
+	if shared {
+		t.stats.Counter("coalesced_fetches").Inc(1)
+	}
+	return source.(string), err
+}
+
+// fetch downloads blob d into the cache as torrent, falling back to the origin
+// if the torrent download fails or times out. Returns the source d was
+// downloaded from.
+func (t *ReadOnlyTransferer) fetch(
+	ctx context.Context, namespace string, d core.Digest) (string, error) {
+
+	err := t.schedDownload(ctx, namespace, d)
+	if err == nil {
+		return _sourceP2P, t.verifyDownload(d)
+	}
+	if err == scheduler.ErrTorrentNotFound {
+		return _sourceP2P, ErrBlobNotFound
+	}
+	if err == scheduler.ErrMaintenance {
+		// Falling back would keep pulling new blobs onto a draining agent.
+		return _sourceP2P, err
+	}
+	if store.IsInsufficientStorage(err) {
+		// The origin fallback downloads to the same disk.
+		return _sourceP2P, err
+	}
+	if t.fallback == nil {
+		return _sourceP2P, t.fallBack(ctx, namespace, d, err)
+	}
+	return _sourceOriginFallback, t.fallBack(ctx, namespace, d, err)
+}
+
+// verifyDownload verifies blob d, which was downloaded as torrent, against its
+// digest. If optimistic verification is enabled, d is verified in the
+// background instead. Blobs downloaded via the origin fallback are verified as
+// they are downloaded.
+func (t *ReadOnlyTransferer) verifyDownload(d core.Digest) error {
+	if !t.config.OptimisticVerification {
+		return t.verify(d, "verify_mismatch")
+	}
+	go func() {
+		if err := t.verify(d, "optimistic_verify_mismatch"); err != nil {
+			log.With("blob", d).Errorf("Error verifying blob served before verification: %s", err)
+		}
+	}()
+	return nil
+}
+
+// verify hashes cached blob d and compares it against d. Corrupt blobs are
+// removed from the scheduler and from disk, or quarantined if configured, such
+// that they are downloaded again on the next pull, and counted by the mismatch
+// counter.
+func (t *ReadOnlyTransferer) verify(d core.Digest, mismatch string) error {
+	f, err := t.cads.Cache().GetFileReader(d.Hex())
+	if err != nil {
+		return fmt.Errorf("get cache file: %s", err)
+	}
+	actual, err := d.Digester().FromReader(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("digest: %s", err)
+	}
+	if actual == d {
+		return nil
+	}
+	t.stats.Counter(mismatch).Inc(1)
+	if err := t.cads.QuarantineCacheFile(d.Hex()); err != nil && !os.IsNotExist(err) {
+		log.With("blob", d).Errorf("Error quarantining corrupt blob: %s", err)
+	}
+	if err := t.sched.RemoveTorrent(d); err != nil {
+		log.With("blob", d).Errorf("Error removing corrupt torrent: %s", err)
+	}
+	return fmt.Errorf("digest mismatch: got %s", actual)
+}
+
+// recordBytesServed accounts n bytes of a blob served to a client of
+// namespace from source.
+func (t *ReadOnlyTransferer) recordBytesServed(namespace, source string, n int64) {
+	if n <= 0 {
+		return
+	}
+	t.stats.Tagged(map[string]string{
+		"namespace": namespace,
+		"source":    source,
+	}).Counter("bytes_served").Inc(n)
+}
+
+// countServed wraps f such that the bytes read from it are recorded as served
+// from source once f is closed.
+func (t *ReadOnlyTransferer) countServed(
+	namespace, source string, f store.FileReader) store.FileReader {
+
+	if !t.config.BandwidthMetrics {
+		return f
+	}
+	return &servedFileReader{f, t.newServedCounter(namespace, source)}
+}
+
+// countServedRange is countServed for range readers.
+func (t *ReadOnlyTransferer) countServedRange(
+	namespace, source string, rc io.ReadCloser) io.ReadCloser {
+
+	if !t.config.BandwidthMetrics {
+		return rc
+	}
+	return &servedReadCloser{rc, t.newServedCounter(namespace, source)}
+}
+
+func (t *ReadOnlyTransferer) newServedCounter(namespace, source string) *servedCounter {
+	return &servedCounter{record: func(n int64) {
+		t.recordBytesServed(namespace, source, n)
+	}}
+}
+
+// fallBack downloads blob d from the origin after the torrent download failed
+// with schedErr. Returns schedErr if the origin fallback is disabled.
+func (t *ReadOnlyTransferer) fallBack(
+	ctx context.Context, namespace string, d core.Digest, schedErr error) error {
+
+	if t.fallback == nil {
+		return fmt.Errorf("scheduler: %s", schedErr)
+	}
+	log.With("blob", d).Warnf("Falling back to origin download after scheduler error: %s", schedErr)
+	_, span := tracing.Start(ctx, "origin_fallback.download", blobSpan(namespace, d))
+	err := t.fallback.download(namespace, d)
+	tracing.End(span, err)
+	if err != nil {
+		if err == ErrBlobNotFound {
+			return ErrBlobNotFound
+		}
+		return fmt.Errorf("scheduler: %s; origin fallback: %s", schedErr, err)
+	}
+	return nil
+}
+
+// schedDownload downloads blob d as torrent. If the origin fallback has a
+// timeout, the torrent is removed once the timeout elapses. If the origin
+// fallback has min peers, the torrent is removed if it does not reach min
+// peers in time.
+func (t *ReadOnlyTransferer) schedDownload(
+	ctx context.Context, namespace string, d core.Digest) (err error) {
+
+	_, span := tracing.Start(ctx, "scheduler.download", blobSpan(namespace, d))
+	defer func() { tracing.End(span, err) }()
+
+	config := t.config.OriginFallback
+	if t.fallback == nil || (config.Timeout == 0 && config.MinPeers == 0) {
+		return t.sched.Download(namespace, d)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- t.sched.Download(namespace, d)
+	}()
+
+	var timeoutc <-chan time.Time
+	if config.Timeout > 0 {
+		timer := time.NewTimer(config.Timeout)
+		defer timer.Stop()
+		timeoutc = timer.C
+	}
+	var pollc, minPeersc <-chan time.Time
+	if config.MinPeers > 0 {
+		ticker := time.NewTicker(t.peerPollInterval)
+		defer ticker.Stop()
+		pollc = ticker.C
+		timer := time.NewTimer(config.minPeersTimeout())
+		defer timer.Stop()
+		minPeersc = timer.C
+	}
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case <-pollc:
+			if t.numPeers(d) >= config.MinPeers {
+				// The swarm is healthy enough to commit to the torrent.
+				t.stats.Counter("min_peers_reached").Inc(1)
+				pollc, minPeersc, timeoutc = nil, nil, nil
+			}
+		case <-minPeersc:
+			t.stats.Counter("min_peers_timeouts").Inc(1)
+			return t.abandonTorrent(d, errInsufficientPeers)
+		case <-timeoutc:
+			return t.abandonTorrent(d, errP2PTimeout)
+		}
+	}
+}
+
+// abandonTorrent removes the torrent of d in favor of the origin fallback, and
+// returns reason.
+func (t *ReadOnlyTransferer) abandonTorrent(d core.Digest, reason error) error {
+	if err := t.sched.RemoveTorrent(d); err != nil {
+		log.With("blob", d).Errorf("Error removing abandoned torrent: %s", err)
+	}
+	return reason
+}
+
+// DownloadManifest downloads manifest d as torrent. If MaxBlobSize is set,
+// manifests which reference larger blobs are rejected with BlobTooLargeError.
+// If PrefetchOnManifest is enabled, downloads of all blobs referenced by the
+// manifest are started in the background. If the manifest cache is enabled,
+// manifests are served from memory once downloaded.
+func (t *ReadOnlyTransferer) DownloadManifest(
+	ctx context.Context, namespace string, d core.Digest) (store.FileReader, error) {
+
+	if t.manifests != nil {
+		// Cached manifests may have been downloaded in another namespace.
+		start := time.Now()
+		if err := t.AuthorizeNamespace(namespace); err != nil {
+			t.logDownload(namespace, d, start, "", nil, err)
+			return nil, err
+		}
+		if b, ok := t.manifests.get(d); ok {
+			t.stats.Counter("manifest_cache_hits").Inc(1)
+			if t.config.PrefetchOnManifest {
+				if err := t.prefetchReferences(namespace, d); err != nil {
+					log.With("manifest", d).Errorf("Error prefetching manifest references: %s", err)
+				}
+			}
+			f := store.NewBufferFileReader(b)
+			t.logDownload(namespace, d, start, _sourceCache, f, nil)
+			return t.countServed(namespace, _sourceCache, f), nil
+		}
+		t.stats.Counter("manifest_cache_misses").Inc(1)
+	}
+	f, err := t.Download(ctx, namespace, d)
+	if err != nil {
+		return nil, err
+	}
+	if t.config.MaxBlobSize > 0 {
+		manifest, err := t.readManifest(d)
+		if err == nil {
+			err = t.checkReferenceSizes(manifest)
+		}
+		if err != nil {
+			f.Close()
+			if _, ok := err.(BlobTooLargeError); ok {
+				t.stats.Counter("blob_too_large").Inc(1)
+				log.With("manifest", d).Warnf("Rejecting manifest: %s", err)
+				return nil, err
+			}
+			return nil, fmt.Errorf("check manifest references: %s", err)
+		}
+	}
+	if t.config.PrefetchOnManifest {
+		if err := t.prefetchReferences(namespace, d); err != nil {
+			log.With("manifest", d).Errorf("Error prefetching manifest references: %s", err)
+		}
+	}
+	if t.manifests != nil {
+		if err := t.cacheManifest(d, f); err != nil {
+			log.With("manifest", d).Errorf("Error caching manifest: %s", err)
+		}
+	}
+	return f, nil
+}
+
+// cacheManifest adds the content of manifest d, read from f, to the manifest
+// cache. The content is verified against d first, since with optimistic
+// verification f may not have been verified yet. Leaves f seeked to the
+// beginning of the file.
+func (t *ReadOnlyTransferer) cacheManifest(d core.Digest, f store.FileReader) error {
+	if f.Size() > t.manifests.config.MaxBytes {
+		return nil
+	}
+	b, err := ioutil.ReadAll(f)
+	if _, serr := f.Seek(0, io.SeekStart); serr != nil && err == nil {
+		err = serr
+	}
+	if err != nil {
+		return fmt.Errorf("read: %s", err)
+	}
+	actual, err := d.Digester().FromBytes(b)
+	if err != nil {
+		return fmt.Errorf("digest: %s", err)
+	}
+	if actual != d {
+		return fmt.Errorf("digest mismatch: got %s", actual)
+	}
+	t.manifests.set(d, b)
+	return nil
+}
+
+// prefetchReferences starts downloads of all blobs referenced by manifest d
+// which are not already cached. If d is an image index, the layers of each
+// referenced per-platform manifest are prefetched as well.
+func (t *ReadOnlyTransferer) prefetchReferences(namespace string, d core.Digest) error {
+	manifest, err := t.readManifest(d)
+	if err != nil {
+		return err
+	}
+	// Top-level manifests were already checked, but the per-platform manifests
+	// referenced by an index were not.
+	if err := t.checkReferenceSizes(manifest); err != nil {
+		return err
+	}
+	refs, err := dockerutil.GetManifestReferences(manifest)
+	if err != nil {
+		return fmt.Errorf("get manifest references: %s", err)
+	}
+	index := dockerutil.IsManifestIndex(manifest)
+	for _, ref := range refs {
+		_, err := t.cads.Cache().GetFileStat(ref.Hex())
+		cached := err == nil
+		if cached && !index {
+			continue
+		}
+		go func(ref core.Digest) {
+			if !cached {
+				t.stats.Counter("prefetches").Inc(1)
+				if err := t.sched.Download(namespace, ref); err != nil {
+					t.stats.Counter("prefetch_errors").Inc(1)
+					log.With("blob", ref).Errorf("Error prefetching blob: %s", err)
+					return
+				}
+			}
+			if index {
+				if err := t.prefetchReferences(namespace, ref); err != nil {
+					log.With("manifest", ref).Errorf(
+						"Error prefetching manifest references: %s", err)
+				}
+			}
+		}(ref)
+	}
+	return nil
+}
+
+// readManifest parses manifest d from the manifest cache, if enabled, else
+// from the store cache.
+func (t *ReadOnlyTransferer) readManifest(d core.Digest) (distribution.Manifest, error) {
+	if t.manifests != nil {
+		if b, ok := t.manifests.get(d); ok {
+			manifest, _, err := dockerutil.ParseManifest(bytes.NewReader(b))
+			if err != nil {
+				return nil, fmt.Errorf("parse manifest: %s", err)
+			}
+			return manifest, nil
+		}
+	}
+	f, err := t.cads.Cache().GetFileReader(d.Hex())
+	if err != nil {
 		return nil, fmt.Errorf("cache: %s", err)
 	}
+	defer f.Close()
+	manifest, _, err := dockerutil.ParseManifest(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse manifest: %s", err)
+	}
+	return manifest, nil
+}
+
+// checkReferenceSizes returns BlobTooLargeError if manifest references a blob
+// larger than MaxBlobSize, according to the sizes of its descriptors. The
+// check is skipped if MaxBlobSize is not set.
+func (t *ReadOnlyTransferer) checkReferenceSizes(manifest distribution.Manifest) error {
+	if t.config.MaxBlobSize <= 0 {
+		return nil
+	}
+	for _, desc := range manifest.References() {
+		if desc.Size > t.config.MaxBlobSize {
+			return BlobTooLargeError{
+				Digest: string(desc.Digest),
+				Size:   desc.Size,
+				Max:    t.config.MaxBlobSize,
+			}
+		}
+	}
+	return nil
+}
+
+// DownloadRange returns a reader of blob d starting at offset. Cached blobs are
+// read directly, else the blob is downloaded as torrent and reads block until
+// the pieces which contain them are available.
+func (t *ReadOnlyTransferer) DownloadRange(
+	ctx context.Context, namespace string, d core.Digest, offset int64) (rc io.ReadCloser, err error) {
+
+	ctx, span := tracing.Start(ctx, "transferer.download_range", blobSpan(namespace, d))
+	defer func() { tracing.End(span, err) }()
+
+	if err := t.AuthorizeNamespace(namespace); err != nil {
+		return nil, err
+	}
+	f, err := t.cads.Cache().GetFileReader(d.Hex())
+	if err == nil {
+		return t.seekServed(namespace, _sourceCache, f, offset)
+	} else if !os.IsNotExist(err) && !t.cads.InDownloadError(err) {
+		return nil, fmt.Errorf("cache: %s", err)
+	}
+
+	downloaded := make(chan error, 1)
+	go func() {
+		downloaded <- t.sched.Download(namespace, d)
+	}()
+
+	source := _sourceP2P
+	mi, err := agentstorage.WaitForMetaInfo(t.cads, d, t.streamPollInterval, downloaded)
+	if err != nil {
+		if err == scheduler.ErrTorrentNotFound {
+			return nil, ErrBlobNotFound
+		}
+		if err == scheduler.ErrMaintenance {
+			return nil, err
+		}
+		// The torrent failed before it could be streamed, so the whole blob is
+		// downloaded from the origin instead.
+		if err := t.fallBack(ctx, namespace, d, err); err != nil {
+			return nil, err
+		}
+		source = _sourceOriginFallback
+	}
+	if mi == nil {
+		// Download finished before the torrent was observed.
+		f, err := t.cads.Cache().GetFileReader(d.Hex())
+		if err != nil {
+			return nil, fmt.Errorf("cache: %s", err)
+		}
+		return t.seekServed(namespace, source, f, offset)
+	}
+	if offset > mi.Length() {
+		return nil, fmt.Errorf("offset %d exceeds blob size %d", offset, mi.Length())
+	}
+	if t.config.SequentialStreaming && source == _sourceP2P {
+		t.streamSequentially(d)
+	}
+	t.stats.Counter("range_downloads").Inc(1)
+	return t.countServedRange(namespace, source, agentstorage.NewStreamReader(
+		t.cads, mi, offset, mi.Length()-offset, t.streamPollInterval, downloaded)), nil
+}
+
+// streamSequentially switches the torrent of d to sequential piece selection.
+// The torrent metainfo is written before the scheduler registers the torrent,
+// so ErrTorrentNotFound is retried until the torrent is registered or the
+// blob has finished downloading.
+func (t *ReadOnlyTransferer) streamSequentially(d core.Digest) {
+	var err error
+	for i := 0; i < _sequentialPolicyAttempts; i++ {
+		err = t.sched.SetPieceRequestPolicy(d, piecerequest.SequentialPolicy)
+		if err != scheduler.ErrTorrentNotFound {
+			break
+		}
+		if _, statErr := t.cads.Cache().GetFileStat(d.Hex()); statErr == nil {
+			// The torrent completed in the meantime.
+			return
+		}
+		time.Sleep(t.streamPollInterval)
+	}
+	if err != nil {
+		log.With("blob", d).Warnf("Error setting sequential piece request policy: %s", err)
+		return
+	}
+	t.stats.Counter("sequential_streams").Inc(1)
+}
+
+// blobSpan returns the attributes of spans for blob d.
+func blobSpan(namespace string, d core.Digest) trace.SpanStartOption {
+	return trace.WithAttributes(
+		attribute.String("namespace", namespace),
+		attribute.String("digest", d.String()))
+}
+
+// seekServed seeks f to offset and counts the bytes read from it as served.
+func (t *ReadOnlyTransferer) seekServed(
+	namespace, source string, f store.FileReader, offset int64) (io.ReadCloser, error) {
+
+	f, err := seek(f, offset)
+	if err != nil {
+		return nil, err
+	}
+	return t.countServed(namespace, source, f), nil
+}
+
+func seek(f store.FileReader, offset int64) (store.FileReader, error) {
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("seek: %s", err)
+	}
 	return f, nil
 }
 
@@ -90,25 +793,170 @@ func (t *ReadOnlyTransferer) Upload(namespace string, d core.Digest, blob store.
 }
 
 // GetTag gets manifest digest for tag.
-func (t *ReadOnlyTransferer) GetTag(tag string) (core.Digest, error) {
-	d, err := t.tags.Get(tag)
+func (t *ReadOnlyTransferer) GetTag(ctx context.Context, tag string) (core.Digest, error) {
+	ctx, span := tracing.Start(
+		ctx, "transferer.get_tag", trace.WithAttributes(attribute.String("tag", tag)))
+	start := time.Now()
+	d, err := t.getTag(ctx, tag)
+	tracing.End(span, err)
+	if ce := t.checkAccessLog(err); ce != nil {
+		fields := []zap.Field{
+			zap.String("method", "get_tag"),
+			zap.String("tag", tag),
+			zap.Duration("duration", time.Since(start)),
+		}
+		if err == nil {
+			fields = append(fields, zap.String("digest", d.String()), zap.String("outcome", "success"))
+		} else if err == ErrTagNotFound {
+			fields = append(fields, zap.String("outcome", "not_found"))
+		} else if err == ErrNamespaceForbidden {
+			fields = append(fields, zap.String("outcome", "forbidden"))
+		} else if _, ok := err.(TagsUnavailableError); ok {
+			fields = append(fields, zap.String("outcome", "unavailable"))
+		} else {
+			fields = append(fields, zap.String("outcome", "error"))
+		}
+		ce.Write(append(fields, errorField(err)...)...)
+	}
+	return d, err
+}
+
+func (t *ReadOnlyTransferer) getTag(ctx context.Context, tag string) (core.Digest, error) {
+	if err := t.AuthorizeNamespace(tagNamespace(tag)); err != nil {
+		return core.Digest{}, err
+	}
+	if i := strings.Index(tag, "@"); i >= 0 {
+		return t.getDigestReference(ctx, tag[:i], tag[i+1:])
+	}
+	d, err := t.resolveTag(ctx, tag)
 	if err != nil {
 		if err == tagclient.ErrTagNotFound {
 			t.stats.Counter("tag_not_found").Inc(1)
 			return core.Digest{}, ErrTagNotFound
 		}
+		if _, ok := err.(tagclient.TimeoutError); ok {
+			t.stats.Counter("get_tag_timeout").Inc(1)
+		}
 		t.stats.Counter("get_tag_error").Inc(1)
+		if tagclient.IsServerUnavailable(err) {
+			return core.Digest{}, TagsUnavailableError{err}
+		}
 		return core.Digest{}, fmt.Errorf("client get tag: %s", err)
 	}
 	return d, nil
 }
 
+// resolveTag gets the digest of tag from the build-index. Failures caused by
+// the build-index being unavailable are retried with the configured backoff,
+// while other failures, e.g. the tag not existing, are returned immediately.
+// getDigestReference returns the manifest digest of a digest reference, e.g.
+// of pinned deployments pulling "repo@sha256:...". Digest references are
+// immutable, so they are not resolved through the build-index. Instead, the
+// manifest is downloaded via the scheduler, which validates that it exists on
+// the origins.
+func (t *ReadOnlyTransferer) getDigestReference(
+	ctx context.Context, namespace, ref string) (core.Digest, error) {
+
+	d, err := core.ParseDigest(ref)
+	if err != nil {
+		return core.Digest{}, fmt.Errorf("parse digest reference: %s", err)
+	}
+	t.stats.Counter("digest_references").Inc(1)
+	if _, err := t.Stat(ctx, namespace, d); err != nil {
+		if err == ErrBlobNotFound {
+			t.stats.Counter("tag_not_found").Inc(1)
+			return core.Digest{}, ErrTagNotFound
+		}
+		return core.Digest{}, err
+	}
+	return d, nil
+}
+
+func (t *ReadOnlyTransferer) resolveTag(ctx context.Context, tag string) (core.Digest, error) {
+	b := t.config.TagRetry.Build()
+	for {
+		d, err := t.tags.Get(ctx, tag)
+		if err == nil || !isRetriableTagError(err) {
+			return d, err
+		}
+		wait := b.NextBackOff()
+		if wait == backoff.Stop {
+			return d, err
+		}
+		t.stats.Counter("get_tag_retry").Inc(1)
+		log.With("tag", tag).Warnf("Error getting tag, retrying in %s: %s", wait, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return d, err
+		}
+	}
+}
+
+// isRetriableTagError returns true if err is caused by a transient build-index
+// failure, i.e. a 5XX response, network error or timeout. Errors from an open
+// circuit breaker are not retried, since the breaker already backs off.
+func isRetriableTagError(err error) bool {
+	return tagclient.IsServerUnavailable(err) && err != tagclient.ErrCircuitOpen
+}
+
 // PutTag is not supported.
-func (t *ReadOnlyTransferer) PutTag(tag string, d core.Digest) error {
+func (t *ReadOnlyTransferer) PutTag(ctx context.Context, tag string, d core.Digest) error {
 	return errors.New("not supported")
 }
 
+// checkAccessLog returns a checked entry for logging an access to t, or nil if
+// access logging is disabled at the relevant level. Failed accesses are logged
+// as errors.
+func (t *ReadOnlyTransferer) checkAccessLog(err error) *zapcore.CheckedEntry {
+	level := zap.InfoLevel
+	if err != nil && err != ErrTagNotFound && err != ErrNamespaceForbidden {
+		level = zap.ErrorLevel
+	}
+	return t.accessLog.Check(level, "Registry transfer")
+}
+
+// numPeers returns the number of peers connected for d's torrent.
+func (t *ReadOnlyTransferer) numPeers(d core.Digest) int {
+	stats, err := t.sched.TorrentStats()
+	if err != nil {
+		return 0
+	}
+	for _, s := range stats {
+		if s.Digest == d {
+			return len(s.Peers)
+		}
+	}
+	return 0
+}
+
+func downloadOutcome(downloaded bool, err error) string {
+	if err == ErrNamespaceForbidden {
+		return "forbidden"
+	}
+	if err == syncutil.ErrQueueFull || err == syncutil.ErrQueueTimeout {
+		return "throttled"
+	}
+	if store.IsInsufficientStorage(err) {
+		return "insufficient_storage"
+	}
+	if err != nil {
+		return "error"
+	}
+	if downloaded {
+		return "downloaded"
+	}
+	return "cached"
+}
+
+func errorField(err error) []zap.Field {
+	if err == nil {
+		return nil
+	}
+	return []zap.Field{zap.Error(err)}
+}
+
 // ListTags is not supported.
-func (t *ReadOnlyTransferer) ListTags(prefix string) ([]string, error) {
+func (t *ReadOnlyTransferer) ListTags(ctx context.Context, prefix string) ([]string, error) {
 	return nil, errors.New("not supported")
 }
@@ -15,6 +15,9 @@ package transfer
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
@@ -25,13 +28,23 @@ import (
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/lib/torrent/scheduler/dispatch/piecerequest"
+	"github.com/uber/kraken/lib/torrent/storage/agentstorage"
+	"github.com/uber/kraken/lib/torrent/storage/piecereader"
 	"github.com/uber/kraken/mocks/build-index/tagclient"
 	"github.com/uber/kraken/mocks/lib/torrent/scheduler"
+	"github.com/uber/kraken/utils/dockerutil"
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/syncutil"
 	"github.com/uber/kraken/utils/testutil"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 type agentTransfererMocks struct {
@@ -57,7 +70,21 @@ func newReadOnlyTransfererMocks(t *testing.T) (*agentTransfererMocks, func()) {
 }
 
 func (m *agentTransfererMocks) new() *ReadOnlyTransferer {
-	return NewReadOnlyTransferer(tally.NoopScope, m.cads, m.tags, m.sched)
+	return m.newWithConfig(ReadOnlyConfig{})
+}
+
+func (m *agentTransfererMocks) newWithConfig(config ReadOnlyConfig) *ReadOnlyTransferer {
+	return m.newWithAccessLog(config, zap.NewNop())
+}
+
+func (m *agentTransfererMocks) newWithAccessLog(
+	config ReadOnlyConfig, accessLog *zap.Logger) *ReadOnlyTransferer {
+
+	t, err := NewReadOnlyTransferer(config, tally.NoopScope, m.cads, m.tags, m.sched, accessLog)
+	if err != nil {
+		panic(err)
+	}
+	return t
 }
 
 func TestReadOnlyTransfererDownloadCachesBlob(t *testing.T) {
@@ -79,7 +106,7 @@ func TestReadOnlyTransfererDownloadCachesBlob(t *testing.T) {
 
 	// Downloading multiple times should only call scheduler download once.
 	for i := 0; i < 10; i++ {
-		result, err := transferer.Download(namespace, blob.Digest)
+		result, err := transferer.Download(context.Background(), namespace, blob.Digest)
 		require.NoError(err)
 		b, err := ioutil.ReadAll(result)
 		require.NoError(err)
@@ -87,6 +114,411 @@ func TestReadOnlyTransfererDownloadCachesBlob(t *testing.T) {
 	}
 }
 
+func TestReadOnlyTransfererBandwidthMetrics(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	stats := tally.NewTestScope("", nil)
+
+	transferer, err := NewReadOnlyTransferer(
+		ReadOnlyConfig{BandwidthMetrics: true},
+		stats, mocks.cads, mocks.tags, mocks.sched, zap.NewNop())
+	require.NoError(err)
+
+	namespace := "docker/repo-bar"
+	blob := core.NewBlobFixture()
+
+	mocks.sched.EXPECT().Download(
+		namespace, blob.Digest).DoAndReturn(func(namespace string, d core.Digest) error {
+
+		return store.RunDownload(mocks.cads, d, blob.Content)
+	})
+
+	// The first download is served via p2p, the rest from cache.
+	for i := 0; i < 3; i++ {
+		f, err := transferer.Download(context.Background(), namespace, blob.Digest)
+		require.NoError(err)
+		_, err = ioutil.ReadAll(f)
+		require.NoError(err)
+		f.Close()
+	}
+
+	served := make(map[string]int64)
+	for _, c := range stats.Snapshot().Counters() {
+		if c.Name() == "bytes_served" {
+			require.Equal(namespace, c.Tags()["namespace"])
+			served[c.Tags()["source"]] += c.Value()
+		}
+	}
+	size := int64(len(blob.Content))
+	require.Equal(map[string]int64{_sourceP2P: size, _sourceCache: 2 * size}, served)
+}
+
+func TestReadOnlyTransfererBandwidthMetricsCountsBytesRead(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	stats := tally.NewTestScope("", nil)
+
+	transferer, err := NewReadOnlyTransferer(
+		ReadOnlyConfig{BandwidthMetrics: true},
+		stats, mocks.cads, mocks.tags, mocks.sched, zap.NewNop())
+	require.NoError(err)
+
+	namespace := "docker/repo-bar"
+	blob := core.NewBlobFixture()
+
+	require.NoError(store.RunDownload(mocks.cads, blob.Digest, blob.Content))
+
+	// Unread blobs are not counted.
+	f, err := transferer.Download(context.Background(), namespace, blob.Digest)
+	require.NoError(err)
+	f.Close()
+	require.Equal(int64(0), counterValue(stats, "bytes_served"))
+
+	// Aborted reads only count the bytes read.
+	f, err = transferer.Download(context.Background(), namespace, blob.Digest)
+	require.NoError(err)
+	_, err = io.ReadFull(f, make([]byte, 10))
+	require.NoError(err)
+	f.Close()
+	require.Equal(int64(10), counterValue(stats, "bytes_served"))
+
+	rc, err := transferer.DownloadRange(context.Background(), namespace, blob.Digest, 4)
+	require.NoError(err)
+	b, err := ioutil.ReadAll(rc)
+	require.NoError(err)
+	rc.Close()
+	require.Equal(int64(10+len(b)), counterValue(stats, "bytes_served"))
+}
+
+func counterValue(stats tally.TestScope, name string) int64 {
+	var n int64
+	for _, c := range stats.Snapshot().Counters() {
+		if c.Name() == name {
+			n += c.Value()
+		}
+	}
+	return n
+}
+
+func corruptContent(b []byte) []byte {
+	c := append([]byte(nil), b...)
+	c[0]++
+	return c
+}
+
+func TestReadOnlyTransfererDownloadRejectsCorruptBlob(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	stats := tally.NewTestScope("", nil)
+
+	transferer, err := NewReadOnlyTransferer(
+		ReadOnlyConfig{}, stats, mocks.cads, mocks.tags, mocks.sched, zap.NewNop())
+	require.NoError(err)
+
+	namespace := "docker/repo-bar"
+	blob := core.NewBlobFixture()
+
+	mocks.sched.EXPECT().Download(
+		namespace, blob.Digest).DoAndReturn(func(namespace string, d core.Digest) error {
+
+		return store.RunDownload(mocks.cads, d, corruptContent(blob.Content))
+	})
+	mocks.sched.EXPECT().RemoveTorrent(blob.Digest).Return(nil)
+
+	_, err = transferer.Download(context.Background(), namespace, blob.Digest)
+	require.Error(err)
+	require.Equal(int64(1), counterValue(stats, "verify_mismatch"))
+}
+
+func TestReadOnlyTransfererOptimisticVerification(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	stats := tally.NewTestScope("", nil)
+
+	transferer, err := NewReadOnlyTransferer(
+		ReadOnlyConfig{OptimisticVerification: true},
+		stats, mocks.cads, mocks.tags, mocks.sched, zap.NewNop())
+	require.NoError(err)
+
+	namespace := "docker/repo-bar"
+	blob := core.NewBlobFixture()
+	corrupt := corruptContent(blob.Content)
+
+	mocks.sched.EXPECT().Download(
+		namespace, blob.Digest).DoAndReturn(func(namespace string, d core.Digest) error {
+
+		return store.RunDownload(mocks.cads, d, corrupt)
+	})
+	removed := make(chan struct{})
+	mocks.sched.EXPECT().RemoveTorrent(blob.Digest).DoAndReturn(func(core.Digest) error {
+		close(removed)
+		return nil
+	})
+
+	// The blob is served before the mismatch is detected in the background.
+	f, err := transferer.Download(context.Background(), namespace, blob.Digest)
+	require.NoError(err)
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	require.NoError(err)
+	require.Equal(corrupt, b)
+
+	select {
+	case <-removed:
+	case <-time.After(5 * time.Second):
+		require.FailNow("corrupt torrent not removed")
+	}
+	require.Equal(int64(1), counterValue(stats, "optimistic_verify_mismatch"))
+}
+
+// accessLogFixture returns a logger which writes JSON entries to the returned
+// buffer.
+func accessLogFixture() (*zap.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+		MessageKey:     "message",
+		EncodeDuration: zapcore.SecondsDurationEncoder,
+	})
+	return zap.New(zapcore.NewCore(encoder, zapcore.AddSync(&buf), zap.InfoLevel)), &buf
+}
+
+func decodeAccessLog(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var e map[string]interface{}
+		require.NoError(t, dec.Decode(&e))
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestReadOnlyTransfererDownloadUnknownDigest(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.new()
+
+	namespace := "docker/repo-bar"
+	d := core.DigestFixture()
+
+	mocks.sched.EXPECT().Download(namespace, d).Return(scheduler.ErrTorrentNotFound)
+
+	_, err := transferer.Download(context.Background(), namespace, d)
+	require.Equal(ErrBlobNotFound, err)
+}
+
+func TestReadOnlyTransfererDownloadDoesNotShareFetchesAcrossNamespaces(t *testing.T) {
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.new()
+
+	namespaces := []string{"docker/repo-bar", "docker/repo-baz"}
+	d := core.DigestFixture()
+
+	for _, ns := range namespaces {
+		mocks.sched.EXPECT().Download(ns, d).DoAndReturn(func(string, core.Digest) error {
+			// Give the other fetch time to join.
+			time.Sleep(100 * time.Millisecond)
+			return scheduler.ErrTorrentNotFound
+		})
+	}
+
+	var wg sync.WaitGroup
+	for _, ns := range namespaces {
+		wg.Add(1)
+		go func(ns string) {
+			defer wg.Done()
+			_, err := transferer.Download(context.Background(), ns, d)
+			require.Equal(t, ErrBlobNotFound, err)
+		}(ns)
+	}
+	wg.Wait()
+}
+
+func TestReadOnlyTransfererDownloadInsufficientStorage(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.new()
+
+	namespace := "docker/repo-bar"
+	d := core.DigestFixture()
+
+	mocks.sched.EXPECT().Download(namespace, d).Return(
+		store.InsufficientStorageError{Dir: "/tmp", Size: 10, Free: 1})
+
+	_, err := transferer.Download(context.Background(), namespace, d)
+	require.True(store.IsInsufficientStorage(err))
+	require.Equal("insufficient_storage", downloadOutcome(false, err))
+}
+
+func TestReadOnlyTransfererDownloadLimitExceeded(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	limiter := syncutil.NewLimiter(syncutil.LimiterConfig{MaxConcurrent: 1})
+	transferer, err := NewReadOnlyTransferer(
+		ReadOnlyConfig{}, tally.NoopScope, mocks.cads, mocks.tags, mocks.sched, zap.NewNop(),
+		WithDownloadLimiter(limiter))
+	require.NoError(err)
+
+	namespace := "docker/repo-bar"
+	blob1 := core.NewBlobFixture()
+	blob2 := core.NewBlobFixture()
+
+	release := make(chan struct{})
+	mocks.sched.EXPECT().Download(namespace, blob1.Digest).DoAndReturn(
+		func(namespace string, d core.Digest) error {
+			<-release
+			return store.RunDownload(mocks.cads, d, blob1.Content)
+		})
+
+	errc := make(chan error)
+	go func() {
+		_, err := transferer.Download(context.Background(), namespace, blob1.Digest)
+		errc <- err
+	}()
+	require.NoError(testutil.PollUntilTrue(5*time.Second, func() bool {
+		return limiter.InFlight() == 1
+	}))
+
+	_, err = transferer.Download(context.Background(), namespace, blob2.Digest)
+	require.Equal(syncutil.ErrQueueFull, err)
+
+	close(release)
+	require.NoError(<-errc)
+	require.Equal(0, limiter.InFlight())
+}
+
+func TestReadOnlyTransfererGetTagDigestReference(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.new()
+
+	namespace := "docker/repo-bar"
+	manifest := core.NewBlobFixture()
+
+	// Digest references must not be resolved through the build-index, but
+	// the manifest must still be downloaded.
+	mocks.sched.EXPECT().Download(
+		namespace, manifest.Digest).DoAndReturn(func(namespace string, d core.Digest) error {
+
+		return store.RunDownload(mocks.cads, d, manifest.Content)
+	})
+
+	result, err := transferer.GetTag(context.Background(), namespace+"@"+manifest.Digest.String())
+	require.NoError(err)
+	require.Equal(manifest.Digest, result)
+
+	_, err = transferer.GetTag(context.Background(), namespace+"@sha256:invalid")
+	require.Error(err)
+}
+
+func TestReadOnlyTransfererGetTagDigestReferenceNotFound(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.new()
+
+	namespace := "docker/repo-bar"
+	d := core.DigestFixture()
+
+	mocks.sched.EXPECT().Download(namespace, d).Return(scheduler.ErrTorrentNotFound)
+
+	_, err := transferer.GetTag(context.Background(), namespace+"@"+d.String())
+	require.Equal(ErrTagNotFound, err)
+}
+
+func TestReadOnlyTransfererDownloadAccessLog(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	logger, buf := accessLogFixture()
+	transferer := mocks.newWithAccessLog(ReadOnlyConfig{}, logger)
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.NewBlobFixture()
+
+	mocks.sched.EXPECT().Download(
+		namespace, blob.Digest).DoAndReturn(func(namespace string, d core.Digest) error {
+
+		return store.RunDownload(mocks.cads, d, blob.Content)
+	})
+	mocks.sched.EXPECT().TorrentStats().Return([]scheduler.TorrentStats{{
+		Digest: blob.Digest,
+		Peers:  []scheduler.PeerStats{{}, {}},
+	}}, nil)
+
+	for i := 0; i < 2; i++ {
+		result, err := transferer.Download(context.Background(), namespace, blob.Digest)
+		require.NoError(err)
+		result.Close()
+	}
+
+	entries := decodeAccessLog(t, buf)
+	require.Len(entries, 2)
+
+	require.Equal("download", entries[0]["method"])
+	require.Equal(namespace, entries[0]["namespace"])
+	require.Equal(blob.Digest.String(), entries[0]["digest"])
+	require.Equal("downloaded", entries[0]["outcome"])
+	require.Equal(float64(len(blob.Content)), entries[0]["bytes"])
+	require.Equal(float64(2), entries[0]["peers"])
+
+	require.Equal("cached", entries[1]["outcome"])
+	require.NotContains(entries[1], "peers")
+}
+
+func TestReadOnlyTransfererGetTagAccessLog(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	logger, buf := accessLogFixture()
+	transferer := mocks.newWithAccessLog(ReadOnlyConfig{}, logger)
+
+	tag := core.TagFixture()
+
+	mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(core.Digest{}, tagclient.ErrTagNotFound)
+
+	_, err := transferer.GetTag(context.Background(), tag)
+	require.Equal(ErrTagNotFound, err)
+
+	entries := decodeAccessLog(t, buf)
+	require.Len(entries, 1)
+	require.Equal("get_tag", entries[0]["method"])
+	require.Equal(tag, entries[0]["tag"])
+	require.Equal("not_found", entries[0]["outcome"])
+}
+
 func TestReadOnlyTransfererStat(t *testing.T) {
 	require := require.New(t)
 
@@ -106,7 +538,7 @@ func TestReadOnlyTransfererStat(t *testing.T) {
 
 	// Stat-ing multiple times should only call scheduler download once.
 	for i := 0; i < 10; i++ {
-		bi, err := transferer.Stat(namespace, blob.Digest)
+		bi, err := transferer.Stat(context.Background(), namespace, blob.Digest)
 		require.NoError(err)
 		require.Equal(blob.Info(), bi)
 	}
@@ -123,9 +555,9 @@ func TestReadOnlyTransfererGetTag(t *testing.T) {
 	tag := "docker/some-tag"
 	manifest := core.DigestFixture()
 
-	mocks.tags.EXPECT().Get(tag).Return(manifest, nil)
+	mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(manifest, nil)
 
-	d, err := transferer.GetTag(tag)
+	d, err := transferer.GetTag(context.Background(), tag)
 	require.NoError(err)
 	require.Equal(manifest, d)
 }
@@ -140,13 +572,112 @@ func TestReadOnlyTransfererGetTagNotFound(t *testing.T) {
 
 	tag := "docker/some-tag"
 
-	mocks.tags.EXPECT().Get(tag).Return(core.Digest{}, tagclient.ErrTagNotFound)
+	mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(core.Digest{}, tagclient.ErrTagNotFound)
 
-	_, err := transferer.GetTag(tag)
+	_, err := transferer.GetTag(context.Background(), tag)
 	require.Error(err)
 	require.Equal(ErrTagNotFound, err)
 }
 
+func TestReadOnlyTransfererGetTagUnavailable(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.new()
+
+	tag := "docker/some-tag"
+
+	mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(core.Digest{}, tagclient.ErrCircuitOpen)
+
+	_, err := transferer.GetTag(context.Background(), tag)
+	require.IsType(TagsUnavailableError{}, err)
+}
+
+func tagRetryConfigFixture() ReadOnlyConfig {
+	return ReadOnlyConfig{
+		TagRetry: httputil.ExponentialBackOffConfig{
+			Enabled:         true,
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+			MaxRetries:      2,
+		},
+	}
+}
+
+func TestReadOnlyTransfererGetTagRetriesUnavailable(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.newWithConfig(tagRetryConfigFixture())
+
+	tag := "docker/some-tag"
+	manifest := core.DigestFixture()
+
+	unavailable := &tagclient.Error{
+		Kind: tagclient.ErrServerUnavailable, Cause: errors.New("503")}
+
+	gomock.InOrder(
+		mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(core.Digest{}, unavailable),
+		mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(
+			core.Digest{}, tagclient.TimeoutError{Method: "GET", URL: "/tags"}),
+		mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(manifest, nil),
+	)
+
+	d, err := transferer.GetTag(context.Background(), tag)
+	require.NoError(err)
+	require.Equal(manifest, d)
+}
+
+func TestReadOnlyTransfererGetTagRetriesExhausted(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.newWithConfig(tagRetryConfigFixture())
+
+	tag := "docker/some-tag"
+
+	unavailable := &tagclient.Error{
+		Kind: tagclient.ErrServerUnavailable, Cause: errors.New("503")}
+
+	mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(core.Digest{}, unavailable).Times(3)
+
+	_, err := transferer.GetTag(context.Background(), tag)
+	require.IsType(TagsUnavailableError{}, err)
+}
+
+func TestReadOnlyTransfererGetTagDoesNotRetryNonRetriableErrors(t *testing.T) {
+	tests := []struct {
+		desc string
+		err  error
+	}{
+		{"not found", tagclient.ErrTagNotFound},
+		{"circuit open", tagclient.ErrCircuitOpen},
+		{"unauthorized", &tagclient.Error{
+			Kind: tagclient.ErrUnauthorized, Cause: errors.New("403")}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			mocks, cleanup := newReadOnlyTransfererMocks(t)
+			defer cleanup()
+
+			transferer := mocks.newWithConfig(tagRetryConfigFixture())
+
+			tag := "docker/some-tag"
+
+			mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(core.Digest{}, test.err)
+
+			_, err := transferer.GetTag(context.Background(), tag)
+			require.Error(t, err)
+		})
+	}
+}
+
 // TODO(codyg): This is a particularly ugly test that is a symptom of the lack
 // of abstraction surrounding scheduler / file store operations.
 func TestReadOnlyTransfererMultipleDownloadsOfSameBlob(t *testing.T) {
@@ -177,17 +708,17 @@ func TestReadOnlyTransfererMultipleDownloadsOfSameBlob(t *testing.T) {
 			return err
 		}
 		return nil
-	}).Times(10)
+	})
 
 	// Multiple clients trying to download the same file which is already in
-	// the download state should queue up until the file has been committed to
-	// the cache.
+	// the download state should share a single scheduler download, and queue
+	// up until the file has been committed to the cache.
 	var wg sync.WaitGroup
 	for i := 0; i < 10; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			result, err := transferer.Download(namespace, blob.Digest)
+			result, err := transferer.Download(context.Background(), namespace, blob.Digest)
 			require.NoError(err)
 			b, err := ioutil.ReadAll(result)
 			require.NoError(err)
@@ -201,3 +732,432 @@ func TestReadOnlyTransfererMultipleDownloadsOfSameBlob(t *testing.T) {
 
 	wg.Wait()
 }
+
+// startTorrent creates a torrent for blob in cads, returning a function which
+// writes its pieces one at a time.
+func startTorrent(t *testing.T, cads *store.CADownloadStore, blob *core.BlobFixture) func() error {
+	mi := blob.MetaInfo
+	require.NoError(t, cads.CreateDownloadFile(mi.Digest().Hex(), mi.Length()))
+	_, err := cads.Download().SetMetadata(mi.Digest().Hex(), metadata.NewTorrentMeta(mi))
+	require.NoError(t, err)
+	tor, err := agentstorage.NewTorrent(cads, mi)
+	require.NoError(t, err)
+
+	return func() error {
+		for i := 0; i < tor.NumPieces(); i++ {
+			time.Sleep(10 * time.Millisecond)
+			start := int64(i) * mi.PieceLength()
+			src := piecereader.NewBuffer(blob.Content[start : start+tor.PieceLength(i)])
+			if err := tor.WritePiece(src, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestReadOnlyTransfererDownloadRangeCached(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.new()
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.NewBlobFixture()
+
+	require.NoError(store.RunDownload(mocks.cads, blob.Digest, blob.Content))
+
+	r, err := transferer.DownloadRange(context.Background(), namespace, blob.Digest, 10)
+	require.NoError(err)
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	require.Equal(blob.Content[10:], b)
+}
+
+func TestReadOnlyTransfererDownloadRangeStreamsPieces(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.new()
+	transferer.streamPollInterval = time.Millisecond
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.SizedBlobFixture(16, 4)
+
+	mocks.sched.EXPECT().Download(
+		namespace, blob.Digest).DoAndReturn(func(namespace string, d core.Digest) error {
+
+		return startTorrent(t, mocks.cads, blob)()
+	})
+
+	r, err := transferer.DownloadRange(context.Background(), namespace, blob.Digest, 6)
+	require.NoError(err)
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	require.Equal(blob.Content[6:], b)
+}
+
+func TestReadOnlyTransfererDownloadRangeSequentialStreaming(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.newWithConfig(ReadOnlyConfig{SequentialStreaming: true})
+	transferer.streamPollInterval = time.Millisecond
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.SizedBlobFixture(16, 4)
+
+	mocks.sched.EXPECT().Download(
+		namespace, blob.Digest).DoAndReturn(func(namespace string, d core.Digest) error {
+
+		return startTorrent(t, mocks.cads, blob)()
+	})
+	mocks.sched.EXPECT().SetPieceRequestPolicy(blob.Digest, piecerequest.SequentialPolicy).Return(nil)
+
+	r, err := transferer.DownloadRange(context.Background(), namespace, blob.Digest, 0)
+	require.NoError(err)
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	require.Equal(blob.Content, b)
+}
+
+func TestReadOnlyTransfererDownloadRangeSequentialStreamingRetriesUnregisteredTorrent(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.newWithConfig(ReadOnlyConfig{SequentialStreaming: true})
+	transferer.streamPollInterval = time.Millisecond
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.SizedBlobFixture(16, 4)
+
+	mocks.sched.EXPECT().Download(
+		namespace, blob.Digest).DoAndReturn(func(namespace string, d core.Digest) error {
+
+		return startTorrent(t, mocks.cads, blob)()
+	})
+	gomock.InOrder(
+		mocks.sched.EXPECT().SetPieceRequestPolicy(
+			blob.Digest, piecerequest.SequentialPolicy).Return(scheduler.ErrTorrentNotFound),
+		mocks.sched.EXPECT().SetPieceRequestPolicy(
+			blob.Digest, piecerequest.SequentialPolicy).Return(nil),
+	)
+
+	r, err := transferer.DownloadRange(context.Background(), namespace, blob.Digest, 0)
+	require.NoError(err)
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	require.Equal(blob.Content, b)
+}
+
+func TestReadOnlyTransfererDownloadRangeError(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.new()
+	transferer.streamPollInterval = time.Millisecond
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.NewBlobFixture()
+
+	mocks.sched.EXPECT().Download(namespace, blob.Digest).Return(errors.New("some error"))
+
+	_, err := transferer.DownloadRange(context.Background(), namespace, blob.Digest, 6)
+	require.Error(err)
+}
+
+func TestReadOnlyTransfererStatDuringDownload(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.new()
+
+	namespace := "docker/repo-bar:latest"
+	blob := core.SizedBlobFixture(16, 4)
+
+	startTorrent(t, mocks.cads, blob)
+
+	// Stat should not wait for the in-progress download.
+	bi, err := transferer.Stat(context.Background(), namespace, blob.Digest)
+	require.NoError(err)
+	require.Equal(blob.Info(), bi)
+}
+
+func TestReadOnlyTransfererDownloadManifestPrefetchesReferences(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.newWithConfig(ReadOnlyConfig{PrefetchOnManifest: true})
+
+	namespace := "docker/repo-bar:latest"
+	config := core.DigestFixture()
+	layer1 := core.DigestFixture()
+	layer2 := core.DigestFixture()
+	manifest, raw := dockerutil.ManifestFixture(config, layer1, layer2)
+
+	mocks.sched.EXPECT().Download(
+		namespace, manifest).DoAndReturn(func(namespace string, d core.Digest) error {
+
+		return store.RunDownload(mocks.cads, d, raw)
+	})
+
+	var wg sync.WaitGroup
+	for _, d := range []core.Digest{config, layer1, layer2} {
+		wg.Add(1)
+		mocks.sched.EXPECT().Download(namespace, d).DoAndReturn(
+			func(namespace string, d core.Digest) error {
+				wg.Done()
+				return nil
+			})
+	}
+
+	f, err := transferer.DownloadManifest(context.Background(), namespace, manifest)
+	require.NoError(err)
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	require.NoError(err)
+	require.Equal(raw, b)
+
+	wg.Wait()
+}
+
+func TestReadOnlyTransfererDownloadManifestPrefetchDisabled(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.new()
+
+	namespace := "docker/repo-bar:latest"
+	manifest, raw := dockerutil.ManifestFixture(
+		core.DigestFixture(), core.DigestFixture(), core.DigestFixture())
+
+	mocks.sched.EXPECT().Download(
+		namespace, manifest).DoAndReturn(func(namespace string, d core.Digest) error {
+
+		return store.RunDownload(mocks.cads, d, raw)
+	})
+
+	f, err := transferer.DownloadManifest(context.Background(), namespace, manifest)
+	require.NoError(err)
+	f.Close()
+
+	// Give any unexpected prefetch a chance to hit the scheduler mock.
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestReadOnlyTransfererDownloadManifestCache(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.newWithConfig(ReadOnlyConfig{
+		ManifestCache: ManifestCacheConfig{Enabled: true},
+	})
+
+	namespace := "docker/repo-bar:latest"
+	manifest, raw := dockerutil.ManifestFixture(
+		core.DigestFixture(), core.DigestFixture(), core.DigestFixture())
+
+	mocks.sched.EXPECT().Download(
+		namespace, manifest).DoAndReturn(func(namespace string, d core.Digest) error {
+
+		return store.RunDownload(mocks.cads, d, raw)
+	})
+
+	f, err := transferer.DownloadManifest(context.Background(), namespace, manifest)
+	require.NoError(err)
+	b, err := ioutil.ReadAll(f)
+	require.NoError(err)
+	require.Equal(raw, b)
+	f.Close()
+
+	// Served from memory even once evicted from disk, without downloading
+	// the manifest again.
+	require.NoError(mocks.cads.Cache().DeleteFile(manifest.Hex()))
+
+	f, err = transferer.DownloadManifest(context.Background(), namespace, manifest)
+	require.NoError(err)
+	defer f.Close()
+	b, err = ioutil.ReadAll(f)
+	require.NoError(err)
+	require.Equal(raw, b)
+}
+
+func TestReadOnlyTransfererDownloadManifestCacheForbiddenNamespace(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.newWithConfig(ReadOnlyConfig{
+		AllowedNamespaces: []string{"allowed/.*"},
+		ManifestCache:     ManifestCacheConfig{Enabled: true},
+	})
+
+	namespace := "allowed/repo:latest"
+	manifest, raw := dockerutil.ManifestFixture(
+		core.DigestFixture(), core.DigestFixture(), core.DigestFixture())
+
+	mocks.sched.EXPECT().Download(
+		namespace, manifest).DoAndReturn(func(namespace string, d core.Digest) error {
+
+		return store.RunDownload(mocks.cads, d, raw)
+	})
+
+	f, err := transferer.DownloadManifest(context.Background(), namespace, manifest)
+	require.NoError(err)
+	f.Close()
+
+	// Cached manifests are not served to forbidden namespaces.
+	_, err = transferer.DownloadManifest(context.Background(), "denied/repo", manifest)
+	require.Equal(ErrNamespaceForbidden, err)
+}
+
+func TestReadOnlyTransfererDownloadManifestMaxBlobSize(t *testing.T) {
+	tests := []struct {
+		desc        string
+		maxBlobSize int64
+		tooLarge    bool
+	}{
+		{"disabled", 0, false},
+		{"within limit", 3000000, false},
+		{"exceeds limit", 2000000, true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			mocks, cleanup := newReadOnlyTransfererMocks(t)
+			defer cleanup()
+
+			transferer := mocks.newWithConfig(ReadOnlyConfig{MaxBlobSize: test.maxBlobSize})
+
+			namespace := "docker/repo-bar:latest"
+			layer2 := core.DigestFixture()
+			manifest, raw := dockerutil.ManifestFixture(
+				core.DigestFixture(), core.DigestFixture(), layer2)
+
+			mocks.sched.EXPECT().Download(
+				namespace, manifest).DoAndReturn(func(namespace string, d core.Digest) error {
+
+				return store.RunDownload(mocks.cads, d, raw)
+			})
+
+			f, err := transferer.DownloadManifest(context.Background(), namespace, manifest)
+			if !test.tooLarge {
+				require.NoError(err)
+				f.Close()
+				return
+			}
+			require.Equal(BlobTooLargeError{
+				Digest: layer2.String(),
+				Size:   2345077,
+				Max:    test.maxBlobSize,
+			}, err)
+		})
+	}
+}
+
+func TestReadOnlyTransfererDownloadManifestPrefetchesIndexManifests(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.newWithConfig(ReadOnlyConfig{PrefetchOnManifest: true})
+
+	namespace := "docker/repo-bar:latest"
+	config1, layer1 := core.DigestFixture(), core.DigestFixture()
+	config2, layer2 := core.DigestFixture(), core.DigestFixture()
+	amd64, amd64Raw := dockerutil.OCIManifestFixture(config1, layer1)
+	arm64, arm64Raw := dockerutil.OCIManifestFixture(config2, layer2)
+	index, indexRaw := dockerutil.OCIIndexFixture(amd64, arm64)
+
+	manifests := map[core.Digest][]byte{
+		index: indexRaw,
+		amd64: amd64Raw,
+		arm64: arm64Raw,
+	}
+	for d, raw := range manifests {
+		raw := raw
+		mocks.sched.EXPECT().Download(
+			namespace, d).DoAndReturn(func(namespace string, d core.Digest) error {
+
+			return store.RunDownload(mocks.cads, d, raw)
+		})
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range []core.Digest{config1, layer1, config2, layer2} {
+		wg.Add(1)
+		mocks.sched.EXPECT().Download(namespace, d).DoAndReturn(
+			func(namespace string, d core.Digest) error {
+				wg.Done()
+				return nil
+			})
+	}
+
+	f, err := transferer.DownloadManifest(context.Background(), namespace, index)
+	require.NoError(err)
+	f.Close()
+
+	wg.Wait()
+}
+
+func TestReadOnlyTransfererForbiddenNamespace(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.newWithConfig(ReadOnlyConfig{
+		AllowedNamespaces: []string{"allowed/.*"},
+	})
+
+	blob := core.NewBlobFixture()
+
+	_, err := transferer.Stat(context.Background(), "denied/repo", blob.Digest)
+	require.Equal(ErrNamespaceForbidden, err)
+
+	_, err = transferer.Download(context.Background(), "denied/repo", blob.Digest)
+	require.Equal(ErrNamespaceForbidden, err)
+
+	_, err = transferer.DownloadRange(context.Background(), "denied/repo", blob.Digest, 0)
+	require.Equal(ErrNamespaceForbidden, err)
+
+	_, err = transferer.GetTag(context.Background(), "denied/repo:latest")
+	require.Equal(ErrNamespaceForbidden, err)
+
+	require.NoError(transferer.AuthorizeNamespace("allowed/repo"))
+}
+
+func TestNewReadOnlyTransfererInvalidNamespacePattern(t *testing.T) {
+	mocks, cleanup := newReadOnlyTransfererMocks(t)
+	defer cleanup()
+
+	_, err := NewReadOnlyTransferer(
+		ReadOnlyConfig{DeniedNamespaces: []string{"("}},
+		tally.NoopScope, mocks.cads, mocks.tags, mocks.sched, zap.NewNop())
+	require.Error(t, err)
+}
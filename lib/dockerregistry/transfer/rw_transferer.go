@@ -14,6 +14,7 @@
 package transfer
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -51,7 +52,9 @@ func NewReadWriteTransferer(
 }
 
 // Stat returns blob info from origin cluster or local cache.
-func (t *ReadWriteTransferer) Stat(namespace string, d core.Digest) (*core.BlobInfo, error) {
+func (t *ReadWriteTransferer) Stat(
+	ctx context.Context, namespace string, d core.Digest) (*core.BlobInfo, error) {
+
 	fi, err := t.cas.GetCacheFileStat(d.Hex())
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -80,7 +83,9 @@ func (t *ReadWriteTransferer) originStat(namespace string, d core.Digest) (*core
 
 // Download downloads the blob of name into the file store and returns a reader
 // to the newly downloaded file.
-func (t *ReadWriteTransferer) Download(namespace string, d core.Digest) (store.FileReader, error) {
+func (t *ReadWriteTransferer) Download(
+	ctx context.Context, namespace string, d core.Digest) (store.FileReader, error) {
+
 	blob, err := t.cas.GetCacheFileReader(d.Hex())
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -125,20 +130,23 @@ func (t *ReadWriteTransferer) Upload(
 }
 
 // GetTag returns the manifest digest for tag.
-func (t *ReadWriteTransferer) GetTag(tag string) (core.Digest, error) {
-	d, err := t.tags.Get(tag)
+func (t *ReadWriteTransferer) GetTag(ctx context.Context, tag string) (core.Digest, error) {
+	d, err := t.tags.Get(ctx, tag)
 	if err != nil {
 		if err == tagclient.ErrTagNotFound {
 			return core.Digest{}, ErrTagNotFound
 		}
+		if tagclient.IsServerUnavailable(err) {
+			return core.Digest{}, TagsUnavailableError{err}
+		}
 		return core.Digest{}, fmt.Errorf("client get tag: %s", err)
 	}
 	return d, nil
 }
 
 // PutTag uploads d as the manifest digest for tag.
-func (t *ReadWriteTransferer) PutTag(tag string, d core.Digest) error {
-	if err := t.tags.PutAndReplicate(tag, d); err != nil {
+func (t *ReadWriteTransferer) PutTag(ctx context.Context, tag string, d core.Digest) error {
+	if err := t.tags.PutAndReplicate(ctx, tag, d); err != nil {
 		t.stats.Counter("put_tag_error").Inc(1)
 		return fmt.Errorf("put and replicate tag: %s", err)
 	}
@@ -146,6 +154,6 @@ func (t *ReadWriteTransferer) PutTag(tag string, d core.Digest) error {
 }
 
 // ListTags lists all tags with prefix.
-func (t *ReadWriteTransferer) ListTags(prefix string) ([]string, error) {
-	return t.tags.List(prefix)
+func (t *ReadWriteTransferer) ListTags(ctx context.Context, prefix string) ([]string, error) {
+	return t.tags.List(ctx, prefix)
 }
@@ -15,6 +15,7 @@ package transfer
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io/ioutil"
 	"testing"
@@ -75,7 +76,7 @@ func TestReadWriteTransfererDownloadCachesBlob(t *testing.T) {
 
 	// Downloading multiple times should only call blob download once.
 	for i := 0; i < 10; i++ {
-		result, err := transferer.Download(namespace, blob.Digest)
+		result, err := transferer.Download(context.Background(), namespace, blob.Digest)
 		require.NoError(err)
 		b, err := ioutil.ReadAll(result)
 		require.NoError(err)
@@ -94,9 +95,9 @@ func TestReadWriteTransfererGetTag(t *testing.T) {
 	tag := "docker/some-tag"
 	manifest := core.DigestFixture()
 
-	mocks.tags.EXPECT().Get(tag).Return(manifest, nil)
+	mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(manifest, nil)
 
-	d, err := transferer.GetTag(tag)
+	d, err := transferer.GetTag(context.Background(), tag)
 	require.NoError(err)
 	require.Equal(manifest, d)
 }
@@ -111,9 +112,9 @@ func TestReadWriteTransfererGetTagNotFound(t *testing.T) {
 
 	tag := "docker/some-tag"
 
-	mocks.tags.EXPECT().Get(tag).Return(core.Digest{}, tagclient.ErrTagNotFound)
+	mocks.tags.EXPECT().Get(gomock.Any(), tag).Return(core.Digest{}, tagclient.ErrTagNotFound)
 
-	_, err := transferer.GetTag(tag)
+	_, err := transferer.GetTag(context.Background(), tag)
 	require.Error(err)
 	require.Equal(ErrTagNotFound, err)
 }
@@ -136,9 +137,9 @@ func TestReadWriteTransfererPutTag(t *testing.T) {
 
 	tag := "docker/some-tag"
 
-	mocks.tags.EXPECT().PutAndReplicate(tag, manifestDigest).Return(nil)
+	mocks.tags.EXPECT().PutAndReplicate(gomock.Any(), tag, manifestDigest).Return(nil)
 
-	require.NoError(transferer.PutTag(tag, manifestDigest))
+	require.NoError(transferer.PutTag(context.Background(), tag, manifestDigest))
 }
 
 func TestReadWriteTransfererStatLocalBlob(t *testing.T) {
@@ -154,7 +155,7 @@ func TestReadWriteTransfererStatLocalBlob(t *testing.T) {
 
 	require.NoError(mocks.cas.CreateCacheFile(blob.Digest.Hex(), bytes.NewReader(blob.Content)))
 
-	bi, err := transferer.Stat(namespace, blob.Digest)
+	bi, err := transferer.Stat(context.Background(), namespace, blob.Digest)
 	require.NoError(err)
 	require.Equal(blob.Info(), bi)
 }
@@ -172,7 +173,7 @@ func TestReadWriteTransfererStatRemoteBlob(t *testing.T) {
 
 	mocks.originCluster.EXPECT().Stat(namespace, blob.Digest).Return(blob.Info(), nil)
 
-	bi, err := transferer.Stat(namespace, blob.Digest)
+	bi, err := transferer.Stat(context.Background(), namespace, blob.Digest)
 	require.NoError(err)
 	require.Equal(blob.Info(), bi)
 }
@@ -190,6 +191,6 @@ func TestReadWriteTransfererStatNotFoundOnAnyOriginError(t *testing.T) {
 
 	mocks.originCluster.EXPECT().Stat(namespace, blob.Digest).Return(nil, errors.New("any error"))
 
-	_, err := transferer.Stat(namespace, blob.Digest)
+	_, err := transferer.Stat(context.Background(), namespace, blob.Digest)
 	require.Equal(ErrBlobNotFound, err)
 }
@@ -0,0 +1,78 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package transfer
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/uber/kraken/lib/store"
+)
+
+// servedCounter counts the bytes read from a blob reader and records them once
+// the reader is closed.
+type servedCounter struct {
+	n      int64
+	record func(n int64)
+	once   sync.Once
+}
+
+func (c *servedCounter) add(n int) {
+	atomic.AddInt64(&c.n, int64(n))
+}
+
+func (c *servedCounter) flush() {
+	c.once.Do(func() { c.record(atomic.LoadInt64(&c.n)) })
+}
+
+// servedFileReader wraps a store.FileReader and counts the bytes read from it.
+type servedFileReader struct {
+	store.FileReader
+	c *servedCounter
+}
+
+func (r *servedFileReader) Read(p []byte) (int, error) {
+	n, err := r.FileReader.Read(p)
+	r.c.add(n)
+	return n, err
+}
+
+func (r *servedFileReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.FileReader.ReadAt(p, off)
+	r.c.add(n)
+	return n, err
+}
+
+func (r *servedFileReader) Close() error {
+	r.c.flush()
+	return r.FileReader.Close()
+}
+
+// servedReadCloser wraps an io.ReadCloser and counts the bytes read from it.
+type servedReadCloser struct {
+	io.ReadCloser
+	c *servedCounter
+}
+
+func (r *servedReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.c.add(n)
+	return n, err
+}
+
+func (r *servedReadCloser) Close() error {
+	r.c.flush()
+	return r.ReadCloser.Close()
+}
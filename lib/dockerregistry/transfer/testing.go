@@ -14,6 +14,7 @@
 package transfer
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"path"
@@ -45,7 +46,7 @@ func NewTestTransferer(cas *store.CAStore) ImageTransferer {
 }
 
 // Stat returns blob info from local cache.
-func (t *testTransferer) Stat(namespace string, d core.Digest) (*core.BlobInfo, error) {
+func (t *testTransferer) Stat(ctx context.Context, namespace string, d core.Digest) (*core.BlobInfo, error) {
 	fi, err := t.cas.GetCacheFileStat(d.Hex())
 	if err != nil {
 		return nil, fmt.Errorf("stat cache file: %s", err)
@@ -53,7 +54,7 @@ func (t *testTransferer) Stat(namespace string, d core.Digest) (*core.BlobInfo,
 	return core.NewBlobInfo(fi.Size()), nil
 }
 
-func (t *testTransferer) Download(namespace string, d core.Digest) (store.FileReader, error) {
+func (t *testTransferer) Download(ctx context.Context, namespace string, d core.Digest) (store.FileReader, error) {
 	return t.cas.GetCacheFileReader(d.Hex())
 }
 
@@ -61,7 +62,7 @@ func (t *testTransferer) Upload(namespace string, d core.Digest, blob store.File
 	return t.cas.CreateCacheFile(d.Hex(), blob)
 }
 
-func (t *testTransferer) GetTag(tag string) (core.Digest, error) {
+func (t *testTransferer) GetTag(ctx context.Context, tag string) (core.Digest, error) {
 	p, err := t.tagPather.BlobPath(tag)
 	if err != nil {
 		return core.Digest{}, err
@@ -73,7 +74,7 @@ func (t *testTransferer) GetTag(tag string) (core.Digest, error) {
 	return d, nil
 }
 
-func (t *testTransferer) PutTag(tag string, d core.Digest) error {
+func (t *testTransferer) PutTag(ctx context.Context, tag string, d core.Digest) error {
 	p, err := t.tagPather.BlobPath(tag)
 	if err != nil {
 		return err
@@ -82,7 +83,7 @@ func (t *testTransferer) PutTag(tag string, d core.Digest) error {
 	return nil
 }
 
-func (t *testTransferer) ListTags(prefix string) ([]string, error) {
+func (t *testTransferer) ListTags(ctx context.Context, prefix string) ([]string, error) {
 	prefix = path.Join(t.tagPather.BasePath(), prefix)
 	var tags []string
 	for path := range t.tags {
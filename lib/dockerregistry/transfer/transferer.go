@@ -14,17 +14,41 @@
 package transfer
 
 import (
+	"context"
+	"io"
+
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/store"
 )
 
 // ImageTransferer defines an interface that transfers images
 type ImageTransferer interface {
-	Stat(namespace string, d core.Digest) (*core.BlobInfo, error)
-	Download(namespace string, d core.Digest) (store.FileReader, error)
+	// Stat and Download record spans as children of any trace in ctx.
+	Stat(ctx context.Context, namespace string, d core.Digest) (*core.BlobInfo, error)
+	Download(ctx context.Context, namespace string, d core.Digest) (store.FileReader, error)
 	Upload(namespace string, d core.Digest, blob store.FileReader) error
 
-	GetTag(tag string) (core.Digest, error)
-	PutTag(tag string, d core.Digest) error
-	ListTags(prefix string) ([]string, error)
+	// Tag operations are aborted once ctx is done, e.g. because the registry
+	// client disconnected.
+	GetTag(ctx context.Context, tag string) (core.Digest, error)
+	PutTag(ctx context.Context, tag string, d core.Digest) error
+	ListTags(ctx context.Context, prefix string) ([]string, error)
+}
+
+// RangeDownloader is an optional ImageTransferer extension for serving a blob
+// starting at an offset before the whole blob has been downloaded.
+type RangeDownloader interface {
+	DownloadRange(ctx context.Context, namespace string, d core.Digest, offset int64) (io.ReadCloser, error)
+}
+
+// ManifestDownloader is an optional ImageTransferer extension for downloading
+// manifests, which allows the transferer to act on the layers they reference.
+type ManifestDownloader interface {
+	DownloadManifest(ctx context.Context, namespace string, d core.Digest) (store.FileReader, error)
+}
+
+// NamespaceAuthorizer is an optional ImageTransferer extension for rejecting
+// transfers of namespaces before any blobs or tags are requested.
+type NamespaceAuthorizer interface {
+	AuthorizeNamespace(namespace string) error
 }
@@ -36,14 +36,23 @@ type Config struct {
 	// Statically configured addresses. Must be in 'host:port' format.
 	Static []string `yaml:"static"`
 
-	// TTL defines how long resolved host lists are cached for.
+	// TTL defines how long resolved host lists are cached for. Once expired,
+	// DNS records are re-resolved on the next lookup of the list, so host
+	// changes behind the record are picked up within TTL.
 	TTL time.Duration `yaml:"ttl"`
+
+	// DNSTimeout bounds how long a single DNS resolution may take. On timeout,
+	// the latest successful snapshot continues to be used.
+	DNSTimeout time.Duration `yaml:"dns_timeout"`
 }
 
 func (c *Config) applyDefaults() {
 	if c.TTL == 0 {
 		c.TTL = 5 * time.Second
 	}
+	if c.DNSTimeout == 0 {
+		c.DNSTimeout = 5 * time.Second
+	}
 }
 
 // getResolver parses the configuration for which resolver to use.
@@ -72,7 +81,7 @@ func (c *Config) getResolver() (resolver, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid dns port: %s", err)
 	}
-	return &dnsResolver{dns, port}, nil
+	return &dnsResolver{dns, port, c.DNSTimeout}, nil
 }
 
 // resolver resolves parsed configuration into a list of addresses.
@@ -93,13 +102,17 @@ func (r *staticResolver) String() string {
 }
 
 type dnsResolver struct {
-	dns  string
-	port int
+	dns     string
+	port    int
+	timeout time.Duration
 }
 
 func (r *dnsResolver) resolve() (stringset.Set, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
 	var nr net.Resolver
-	names, err := nr.LookupHost(context.Background(), r.dns)
+	names, err := nr.LookupHost(ctx, r.dns)
 	if err != nil {
 		return nil, fmt.Errorf("resolve dns: %s", err)
 	}
@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -93,8 +94,16 @@ func (l *list) takeSnapshot() error {
 		return err
 	}
 	l.mu.Lock()
+	prev := l.snapshot
 	l.snapshot = snapshot
 	l.mu.Unlock()
+
+	if prev != nil && !stringset.Equal(prev, snapshot) {
+		log.With(
+			"source", l.resolver,
+			"added", snapshot.Sub(prev).ToSlice(),
+			"removed", prev.Sub(snapshot).ToSlice()).Info("Hostlist membership changed")
+	}
 	return nil
 }
 
@@ -128,7 +137,7 @@ func (l *nonLocalList) Resolve() stringset.Set {
 func getLocalNames() (stringset.Set, error) {
 	result := make(stringset.Set)
 
-	// Add all local non-loopback ips.
+	// Add all local non-loopback ips, both IPv4 and IPv6.
 	ifaces, err := net.Interfaces()
 	if err != nil {
 		return nil, fmt.Errorf("interfaces: %s", err)
@@ -139,8 +148,14 @@ func getLocalNames() (stringset.Set, error) {
 			return nil, fmt.Errorf("addrs of %v: %s", i, err)
 		}
 		for _, addr := range addrs {
-			ip := net.ParseIP(addr.String()).To4()
-			if ip == nil {
+			var ip net.IP
+			switch v := addr.(type) {
+			case *net.IPNet:
+				ip = v.IP
+			case *net.IPAddr:
+				ip = v.IP
+			}
+			if ip == nil || ip.IsLoopback() {
 				continue
 			}
 			result.Add(ip.String())
@@ -160,14 +175,12 @@ func getLocalNames() (stringset.Set, error) {
 func attachPortIfMissing(names stringset.Set, port int) (stringset.Set, error) {
 	result := make(stringset.Set)
 	for name := range names {
-		parts := strings.Split(name, ":")
-		switch len(parts) {
-		case 1:
-			// Name is in 'host' format -- attach port.
-			name = fmt.Sprintf("%s:%d", parts[0], port)
-		case 2:
+		if _, _, err := net.SplitHostPort(name); err == nil {
 			// No-op, name is already in "ip:port" format.
-		default:
+		} else if !strings.Contains(name, ":") || net.ParseIP(name) != nil {
+			// Name is in 'host' format, possibly an IPv6 literal -- attach port.
+			name = net.JoinHostPort(name, strconv.Itoa(port))
+		} else {
 			return nil, fmt.Errorf("invalid name format: %s, expected 'host' or 'ip:port'", name)
 		}
 		result.Add(name)
@@ -14,13 +14,26 @@
 package hostlist
 
 import (
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/uber/kraken/utils/dedup"
 	"github.com/uber/kraken/utils/stringset"
 
+	"github.com/andres-erbsen/clock"
 	"github.com/stretchr/testify/require"
 )
 
+type fakeResolver struct {
+	addrs stringset.Set
+	err   error
+}
+
+func (r *fakeResolver) resolve() (stringset.Set, error) {
+	return r.addrs, r.err
+}
+
 func TestListResolve(t *testing.T) {
 	require := require.New(t)
 
@@ -32,12 +45,45 @@ func TestListResolve(t *testing.T) {
 	require.ElementsMatch(addrs, l.Resolve().ToSlice())
 }
 
+func TestListRefreshesAfterTTL(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	ttl := 5 * time.Second
+
+	r := &fakeResolver{addrs: stringset.New("a:80", "b:80")}
+	l := &list{resolver: r}
+	l.snapshotTrap = dedup.NewIntervalTrap(ttl, clk, &snapshotTask{l})
+	require.NoError(l.takeSnapshot())
+
+	r.addrs = stringset.New("b:80", "c:80")
+
+	// Snapshot is cached until the TTL expires.
+	require.Equal(stringset.New("a:80", "b:80"), l.Resolve())
+
+	clk.Add(ttl + time.Second)
+	require.Equal(stringset.New("b:80", "c:80"), l.Resolve())
+
+	// Resolution errors fall back to the latest successful snapshot.
+	r.err = errors.New("some error")
+	r.addrs = nil
+
+	clk.Add(ttl + time.Second)
+	require.Equal(stringset.New("b:80", "c:80"), l.Resolve())
+}
+
 func TestAttachPortIfMissing(t *testing.T) {
 	addrs, err := attachPortIfMissing(stringset.New("x", "y:5", "z"), 7)
 	require.NoError(t, err)
 	require.Equal(t, stringset.New("x:7", "y:5", "z:7"), addrs)
 }
 
+func TestAttachPortIfMissingIPv6(t *testing.T) {
+	addrs, err := attachPortIfMissing(stringset.New("2001:db8::1", "[2001:db8::2]:5"), 7)
+	require.NoError(t, err)
+	require.Equal(t, stringset.New("[2001:db8::1]:7", "[2001:db8::2]:5"), addrs)
+}
+
 func TestAttachPortIfMissingError(t *testing.T) {
 	_, err := attachPortIfMissing(stringset.New("a:b:c"), 7)
 	require.Error(t, err)
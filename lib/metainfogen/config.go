@@ -15,6 +15,7 @@ package metainfogen
 
 import (
 	"errors"
+	"fmt"
 	"sort"
 
 	"github.com/c2h5oh/datasize"
@@ -57,6 +58,9 @@ func newPieceLengthConfig(
 	}
 	var ranges []rangeConfig
 	for fileSize, pieceLength := range pieceLengthByFileSize {
+		if pieceLength == 0 {
+			return nil, fmt.Errorf("invalid piece length for file size %s: must be non-zero", fileSize)
+		}
 		ranges = append(ranges, rangeConfig{
 			fileSize:    int64(fileSize),
 			pieceLength: int64(pieceLength),
@@ -36,3 +36,22 @@ func TestPieceLengthConfig(t *testing.T) {
 	require.Equal(int64(8*datasize.MB), plConfig.get(int64(4*datasize.GB)))
 	require.Equal(int64(8*datasize.MB), plConfig.get(int64(8*datasize.GB)))
 }
+
+func TestPieceLengthConfigErrors(t *testing.T) {
+	tests := []struct {
+		desc         string
+		pieceLengths map[datasize.ByteSize]datasize.ByteSize
+	}{
+		{"empty", nil},
+		{"zero piece length", map[datasize.ByteSize]datasize.ByteSize{
+			0:               datasize.MB,
+			2 * datasize.GB: 0,
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := newPieceLengthConfig(test.pieceLengths)
+			require.Error(t, err)
+		})
+	}
+}
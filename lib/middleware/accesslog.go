@@ -0,0 +1,81 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pressly/chi"
+	"go.uber.org/zap"
+)
+
+// accessLogParams are the path variables included in access log entries when
+// present in the matched route.
+var accessLogParams = []string{"namespace", "digest", "tag", "infohash"}
+
+type recordBytesWriter struct {
+	recordStatusWriter
+	bytes int64
+}
+
+func (w *recordBytesWriter) Write(b []byte) (int, error) {
+	n, err := w.recordStatusWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// AccessLogger logs a structured entry for each request to logger. Requests
+// which fail with 5XX statuses are logged as errors, and all others as info,
+// such that the level of logger controls which requests are logged.
+func AccessLogger(logger *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recordw := &recordBytesWriter{recordStatusWriter{w, false, http.StatusOK}, 0}
+			next.ServeHTTP(recordw, r)
+
+			level := zap.InfoLevel
+			if recordw.code >= 500 {
+				level = zap.ErrorLevel
+			}
+			ce := logger.Check(level, "HTTP request")
+			if ce == nil {
+				return
+			}
+			outcome := "success"
+			if recordw.code >= 400 {
+				outcome = "failure"
+			}
+			fields := []zap.Field{
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("remote_addr", r.RemoteAddr),
+				zap.Int("status", recordw.code),
+				zap.Int64("bytes", recordw.bytes),
+				zap.Duration("duration", time.Since(start)),
+				zap.String("outcome", outcome),
+			}
+			if ctx := chi.RouteContext(r.Context()); ctx != nil {
+				fields = append(fields, zap.String("endpoint", ctx.RoutePattern()))
+				for _, p := range accessLogParams {
+					if v := chi.URLParam(r, p); v != "" {
+						fields = append(fields, zap.String(p, v))
+					}
+				}
+			}
+			ce.Write(fields...)
+		})
+	}
+}
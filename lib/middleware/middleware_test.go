@@ -14,6 +14,8 @@
 package middleware
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -26,6 +28,8 @@ import (
 	"github.com/pressly/chi"
 	"github.com/stretchr/testify/require"
 	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestScopeByEndpoint(t *testing.T) {
@@ -155,3 +159,84 @@ func TestStatusCounter(t *testing.T) {
 		})
 	}
 }
+
+func TestAccessLogger(t *testing.T) {
+	tests := []struct {
+		desc            string
+		handler         func(http.ResponseWriter, *http.Request)
+		level           zapcore.Level
+		expectedEntries int
+		expectedStatus  float64
+		expectedOutcome string
+	}{
+		{
+			"success",
+			func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "OK") },
+			zap.InfoLevel,
+			1,
+			200,
+			"success",
+		}, {
+			"server error",
+			func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(500) },
+			zap.InfoLevel,
+			1,
+			500,
+			"failure",
+		}, {
+			"success below level",
+			func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "OK") },
+			zap.ErrorLevel,
+			0,
+			0,
+			"",
+		}, {
+			"server error at level",
+			func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(500) },
+			zap.ErrorLevel,
+			1,
+			500,
+			"failure",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			var buf bytes.Buffer
+			encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+				MessageKey:     "message",
+				EncodeDuration: zapcore.SecondsDurationEncoder,
+			})
+			logger := zap.New(zapcore.NewCore(encoder, zapcore.AddSync(&buf), test.level))
+
+			r := chi.NewRouter()
+			r.Use(AccessLogger(logger))
+			r.Get("/namespace/{namespace}/blobs/{digest}", test.handler)
+
+			addr, stop := testutil.StartServer(r)
+			defer stop()
+
+			httputil.Get(fmt.Sprintf("http://%s/namespace/foo/blobs/abc", addr))
+
+			var entries []map[string]interface{}
+			dec := json.NewDecoder(&buf)
+			for dec.More() {
+				var e map[string]interface{}
+				require.NoError(dec.Decode(&e))
+				entries = append(entries, e)
+			}
+			require.Len(entries, test.expectedEntries)
+			if test.expectedEntries == 0 {
+				return
+			}
+			e := entries[0]
+			require.Equal("GET", e["method"])
+			require.Equal("/namespace/{namespace}/blobs/{digest}", e["endpoint"])
+			require.Equal("foo", e["namespace"])
+			require.Equal("abc", e["digest"])
+			require.Equal(test.expectedStatus, e["status"])
+			require.Equal(test.expectedOutcome, e["outcome"])
+		})
+	}
+}
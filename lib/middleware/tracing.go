@@ -0,0 +1,52 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/uber/kraken/lib/tracing"
+
+	"github.com/pressly/chi"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer starts a server span for each request, which continues the trace
+// carried by the request headers, if any. Spans are named after the method and
+// route pattern of the request, e.g. "GET /blobs/{digest}".
+func Tracer() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := tracing.Extract(r.Context(), r.Header)
+			ctx, span := tracing.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer))
+			defer span.End()
+
+			recordw := &recordStatusWriter{w, false, http.StatusOK}
+			next.ServeHTTP(recordw, r.WithContext(ctx))
+
+			if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+				span.SetName(r.Method + " " + rctx.RoutePattern())
+			}
+			span.SetAttributes(
+				attribute.String("http.method", r.Method),
+				attribute.String("http.target", r.URL.Path),
+				attribute.Int("http.status_code", recordw.code))
+			if recordw.code >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(recordw.code))
+			}
+		})
+	}
+}
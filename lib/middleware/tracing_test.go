@@ -0,0 +1,64 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/pressly/chi"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracerContinuesIncomingTrace(t *testing.T) {
+	require := require.New(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}()
+
+	r := chi.NewRouter()
+	r.Use(Tracer())
+	r.Get("/foo/{foo}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	addr, stop := testutil.StartServer(r)
+	defer stop()
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	_, err := httputil.Get(
+		fmt.Sprintf("http://%s/foo/x", addr),
+		httputil.SendHeaders(map[string]string{
+			"traceparent": fmt.Sprintf("00-%s-00f067aa0ba902b7-01", traceID),
+		}))
+	require.True(httputil.IsNotFound(err))
+
+	spans := recorder.Ended()
+	require.Len(spans, 1)
+	require.Equal("GET /foo/{foo}", spans[0].Name())
+	require.Equal(traceID, spans[0].SpanContext().TraceID().String())
+	require.True(spans[0].Parent().IsRemote())
+}
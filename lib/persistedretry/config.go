@@ -23,20 +23,47 @@ type Config struct {
 	NumIncomingWorkers int `yaml:"num_incoming_workers"`
 	NumRetryWorkers    int `yaml:"num_retry_workers"`
 
+	// Total number of workers executing tasks in parallel. If set, overrides
+	// NumIncomingWorkers and NumRetryWorkers, reserving a third of the workers
+	// for retries. Both pools have at least one worker.
+	NumWorkers int `yaml:"num_workers"`
+
 	// Max rate of task execution across all workers.
 	MaxTaskThroughput time.Duration `yaml:"max_task_throughput"`
 
 	// Interval at which failed tasks should be retried.
 	RetryInterval time.Duration `yaml:"retry_interval"`
 
+	// Failed tasks are marked as dead after MaxRetries failures, and are no
+	// longer retried. Zero means tasks are retried forever.
+	MaxRetries int `yaml:"max_retries"`
+
 	// Interval at which retries should be polled from storage.
 	PollRetriesInterval time.Duration `yaml:"poll_retries_interval"`
 
+	// Completed tasks are removed from storage in batches, flushed at
+	// CompletionBatchInterval or once CompletionBatchSize tasks are buffered.
+	CompletionBatchInterval time.Duration `yaml:"completion_batch_interval"`
+	CompletionBatchSize     int           `yaml:"completion_batch_size"`
+
+	// Interval at which the number of tasks in each state should be emitted.
+	EmitCountsInterval time.Duration `yaml:"emit_counts_interval"`
+
 	// Flags that zero-value channel sizes should not have defaults applied.
 	Testing bool
 }
 
 func (c Config) applyDefaults() Config {
+	if c.NumWorkers > 0 {
+		c.NumRetryWorkers = c.NumWorkers / 3
+		if c.NumRetryWorkers == 0 {
+			c.NumRetryWorkers = 1
+		}
+		c.NumIncomingWorkers = c.NumWorkers - c.NumRetryWorkers
+		if c.NumIncomingWorkers == 0 {
+			c.NumIncomingWorkers = 1
+		}
+	}
 	if c.NumIncomingWorkers == 0 {
 		c.NumIncomingWorkers = 4
 	}
@@ -52,6 +79,15 @@ func (c Config) applyDefaults() Config {
 	if c.RetryInterval == 0 {
 		c.RetryInterval = 30 * time.Second
 	}
+	if c.CompletionBatchInterval == 0 {
+		c.CompletionBatchInterval = 50 * time.Millisecond
+	}
+	if c.CompletionBatchSize == 0 {
+		c.CompletionBatchSize = 100
+	}
+	if c.EmitCountsInterval == 0 {
+		c.EmitCountsInterval = 30 * time.Second
+	}
 	if !c.Testing {
 		if c.IncomingBuffer == 0 {
 			c.IncomingBuffer = 1000
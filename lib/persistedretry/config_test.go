@@ -0,0 +1,43 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package persistedretry
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigNumWorkersOverridesWorkerPools(t *testing.T) {
+	tests := []struct {
+		numWorkers       int
+		expectedIncoming int
+		expectedRetry    int
+	}{
+		{0, 4, 2},
+		{1, 1, 1},
+		{2, 1, 1},
+		{16, 11, 5},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d workers", test.numWorkers), func(t *testing.T) {
+			require := require.New(t)
+
+			c := Config{NumWorkers: test.numWorkers}.applyDefaults()
+			require.Equal(test.expectedIncoming, c.NumIncomingWorkers)
+			require.Equal(test.expectedRetry, c.NumRetryWorkers)
+		})
+	}
+}
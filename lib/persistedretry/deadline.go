@@ -0,0 +1,93 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package persistedretry
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andres-erbsen/clock"
+
+	"github.com/uber/kraken/utils/log"
+)
+
+// DeadlineTask is a Task which should no longer be retried after its deadline.
+type DeadlineTask interface {
+	Task
+
+	// GetDeadline returns when the task expires. A zero time means the task
+	// never expires.
+	GetDeadline() time.Time
+}
+
+// Expired returns true if t is a DeadlineTask whose deadline has passed.
+func Expired(t Task, now time.Time) bool {
+	dt, ok := t.(DeadlineTask)
+	if !ok {
+		return false
+	}
+	deadline := dt.GetDeadline()
+	return !deadline.IsZero() && now.After(deadline)
+}
+
+// DeadlineExecutor wraps an Executor such that expired DeadlineTasks are
+// dropped from the Store instead of executed. Tasks which do not implement
+// DeadlineTask are always executed.
+type DeadlineExecutor struct {
+	executor Executor
+	store    Store
+	clk      clock.Clock
+}
+
+// NewDeadlineExecutor creates a new DeadlineExecutor.
+func NewDeadlineExecutor(executor Executor, store Store, clk clock.Clock) *DeadlineExecutor {
+	return &DeadlineExecutor{executor, store, clk}
+}
+
+// Exec executes t with the underlying Executor, unless t has expired, in which
+// case t is removed from the store and nil is returned so it is not retried.
+func (e *DeadlineExecutor) Exec(t Task) error {
+	if Expired(t, e.clk.Now()) {
+		if err := e.store.Remove(t); err != nil && err != ErrTaskNotFound {
+			return fmt.Errorf("remove expired task: %s", err)
+		}
+		log.With("task", t).Info("Dropped expired task")
+		return nil
+	}
+	return e.executor.Exec(t)
+}
+
+// Name returns the name of the underlying Executor.
+func (e *DeadlineExecutor) Name() string {
+	return e.executor.Name()
+}
+
+// GetExpired returns all pending and failed tasks in s which have expired.
+func GetExpired(s Store, now time.Time) ([]Task, error) {
+	pending, err := s.GetPending()
+	if err != nil {
+		return nil, fmt.Errorf("get pending: %s", err)
+	}
+	failed, err := s.GetFailed()
+	if err != nil {
+		return nil, fmt.Errorf("get failed: %s", err)
+	}
+	var expired []Task
+	for _, t := range append(pending, failed...) {
+		if Expired(t, now) {
+			expired = append(expired, t)
+		}
+	}
+	return expired, nil
+}
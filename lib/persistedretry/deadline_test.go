@@ -0,0 +1,146 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package persistedretry_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/mocks/lib/persistedretry"
+)
+
+type deadlineTask struct {
+	*mockpersistedretry.MockTask
+	deadline time.Time
+}
+
+func (t deadlineTask) GetDeadline() time.Time {
+	return t.deadline
+}
+
+type deadlineMocks struct {
+	ctrl     *gomock.Controller
+	store    *mockpersistedretry.MockStore
+	executor *mockpersistedretry.MockExecutor
+	clk      *clock.Mock
+}
+
+func newDeadlineMocks(t *testing.T) (*deadlineMocks, func()) {
+	ctrl := gomock.NewController(t)
+	return &deadlineMocks{
+		ctrl:     ctrl,
+		store:    mockpersistedretry.NewMockStore(ctrl),
+		executor: mockpersistedretry.NewMockExecutor(ctrl),
+		clk:      clock.NewMock(),
+	}, ctrl.Finish
+}
+
+func (m *deadlineMocks) new() *DeadlineExecutor {
+	return NewDeadlineExecutor(m.executor, m.store, m.clk)
+}
+
+func (m *deadlineMocks) task(deadline time.Time) deadlineTask {
+	return deadlineTask{mockpersistedretry.NewMockTask(m.ctrl), deadline}
+}
+
+func TestDeadlineExecutorExecutesUnexpiredTasks(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newDeadlineMocks(t)
+	defer cleanup()
+
+	e := mocks.new()
+
+	task := mocks.task(mocks.clk.Now().Add(time.Minute))
+	noDeadline := mocks.task(time.Time{})
+	plain := mockpersistedretry.NewMockTask(mocks.ctrl)
+	execErr := errors.New("some error")
+
+	mocks.executor.EXPECT().Exec(task).Return(execErr)
+	mocks.executor.EXPECT().Exec(noDeadline).Return(nil)
+	mocks.executor.EXPECT().Exec(plain).Return(nil)
+
+	require.Equal(execErr, e.Exec(task))
+	require.NoError(e.Exec(noDeadline))
+	require.NoError(e.Exec(plain))
+}
+
+func TestDeadlineExecutorDropsExpiredTasks(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newDeadlineMocks(t)
+	defer cleanup()
+
+	e := mocks.new()
+
+	task := mocks.task(mocks.clk.Now().Add(time.Minute))
+	mocks.clk.Add(2 * time.Minute)
+
+	mocks.store.EXPECT().Remove(task).Return(nil)
+
+	require.NoError(e.Exec(task))
+}
+
+func TestDeadlineExecutorRemoveError(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newDeadlineMocks(t)
+	defer cleanup()
+
+	e := mocks.new()
+
+	task := mocks.task(mocks.clk.Now().Add(-time.Minute))
+
+	mocks.store.EXPECT().Remove(task).Return(errors.New("some error"))
+
+	require.Error(e.Exec(task))
+}
+
+func TestDeadlineExecutorName(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newDeadlineMocks(t)
+	defer cleanup()
+
+	mocks.executor.EXPECT().Name().Return("mock executor")
+
+	require.Equal("mock executor", mocks.new().Name())
+}
+
+func TestGetExpired(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newDeadlineMocks(t)
+	defer cleanup()
+
+	now := mocks.clk.Now()
+
+	expiredPending := mocks.task(now.Add(-time.Minute))
+	expiredFailed := mocks.task(now.Add(-time.Second))
+	unexpired := mocks.task(now.Add(time.Minute))
+	plain := mockpersistedretry.NewMockTask(mocks.ctrl)
+
+	mocks.store.EXPECT().GetPending().Return([]Task{expiredPending, unexpired}, nil)
+	mocks.store.EXPECT().GetFailed().Return([]Task{expiredFailed, plain}, nil)
+
+	result, err := GetExpired(mocks.store, now)
+	require.NoError(err)
+	require.Equal([]Task{expiredPending, expiredFailed}, result)
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package persistedretry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/uber/kraken/utils/handler"
+)
+
+// debugResponse is the body returned by DebugHandler.
+type debugResponse struct {
+	Counts map[TaskState]int    `json:"counts"`
+	Tasks  map[TaskState][]Task `json:"tasks"`
+}
+
+// DebugHandler returns a handler which lists the tasks of m and their counts
+// as JSON, so operators can inspect the retry backlog. An optional "state"
+// query parameter limits the listed tasks to a single state.
+func DebugHandler(m Manager) http.HandlerFunc {
+	return handler.Wrap(func(w http.ResponseWriter, r *http.Request) error {
+		states := TaskStates
+		if s := r.URL.Query().Get("state"); s != "" {
+			if !validTaskState(TaskState(s)) {
+				return handler.Errorf("invalid state: %q", s).Status(http.StatusBadRequest)
+			}
+			states = []TaskState{TaskState(s)}
+		}
+		counts, err := m.Counts()
+		if err != nil {
+			return handler.Errorf("counts: %s", err)
+		}
+		resp := debugResponse{
+			Counts: counts,
+			Tasks:  make(map[TaskState][]Task),
+		}
+		for _, state := range states {
+			tasks, err := m.List(state)
+			if err != nil {
+				return handler.Errorf("list %s: %s", state, err)
+			}
+			resp.Tasks[state] = tasks
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(&resp); err != nil {
+			return fmt.Errorf("json encode: %s", err)
+		}
+		return nil
+	})
+}
+
+// requeueDeadResponse is the body returned by RequeueDeadHandler.
+type requeueDeadResponse struct {
+	Requeued int `json:"requeued"`
+}
+
+// RequeueDeadHandler returns a handler which moves all dead tasks of m back
+// into the retry queue. Dead tasks can be listed via DebugHandler with
+// "state=dead".
+func RequeueDeadHandler(m Manager) http.HandlerFunc {
+	return handler.Wrap(func(w http.ResponseWriter, r *http.Request) error {
+		n, err := m.RequeueDead()
+		if err != nil {
+			return handler.Errorf("requeue dead: %s", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(&requeueDeadResponse{n}); err != nil {
+			return fmt.Errorf("json encode: %s", err)
+		}
+		return nil
+	})
+}
+
+func validTaskState(state TaskState) bool {
+	for _, s := range TaskStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package persistedretry_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/mocks/lib/persistedretry"
+)
+
+type debugBody struct {
+	Counts map[TaskState]int                `json:"counts"`
+	Tasks  map[TaskState][]*json.RawMessage `json:"tasks"`
+}
+
+func TestDebugHandler(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mockpersistedretry.NewMockManager(ctrl)
+
+	pending := []Task{mockpersistedretry.NewMockTask(ctrl), mockpersistedretry.NewMockTask(ctrl)}
+	failed := []Task{mockpersistedretry.NewMockTask(ctrl)}
+	counts := map[TaskState]int{StatePending: 2, StateFailed: 1, StateInProgress: 0, StateDead: 0}
+
+	m.EXPECT().Counts().Return(counts, nil)
+	m.EXPECT().List(StatePending).Return(pending, nil)
+	m.EXPECT().List(StateFailed).Return(failed, nil)
+	m.EXPECT().List(StateInProgress).Return(nil, nil)
+	m.EXPECT().List(StateDead).Return(nil, nil)
+
+	w := httptest.NewRecorder()
+	DebugHandler(m)(w, httptest.NewRequest("GET", "/", nil))
+	require.Equal(http.StatusOK, w.Code)
+
+	var body debugBody
+	require.NoError(json.NewDecoder(w.Body).Decode(&body))
+	require.Equal(counts, body.Counts)
+	require.Len(body.Tasks[StatePending], 2)
+	require.Len(body.Tasks[StateFailed], 1)
+	require.Len(body.Tasks[StateInProgress], 0)
+}
+
+func TestDebugHandlerFiltersState(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mockpersistedretry.NewMockManager(ctrl)
+
+	failed := []Task{mockpersistedretry.NewMockTask(ctrl)}
+
+	m.EXPECT().Counts().Return(map[TaskState]int{StateFailed: 1}, nil)
+	m.EXPECT().List(StateFailed).Return(failed, nil)
+
+	w := httptest.NewRecorder()
+	DebugHandler(m)(w, httptest.NewRequest("GET", "/?state=failed", nil))
+	require.Equal(http.StatusOK, w.Code)
+
+	var body debugBody
+	require.NoError(json.NewDecoder(w.Body).Decode(&body))
+	require.Len(body.Tasks, 1)
+	require.Len(body.Tasks[StateFailed], 1)
+}
+
+func TestDebugHandlerInvalidState(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mockpersistedretry.NewMockManager(ctrl)
+
+	w := httptest.NewRecorder()
+	DebugHandler(m)(w, httptest.NewRequest("GET", "/?state=bogus", nil))
+	require.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestRequeueDeadHandler(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mockpersistedretry.NewMockManager(ctrl)
+
+	m.EXPECT().RequeueDead().Return(3, nil)
+
+	w := httptest.NewRecorder()
+	RequeueDeadHandler(m)(w, httptest.NewRequest("POST", "/", nil))
+	require.Equal(http.StatusOK, w.Code)
+
+	var body struct {
+		Requeued int `json:"requeued"`
+	}
+	require.NoError(json.NewDecoder(w.Body).Decode(&body))
+	require.Equal(3, body.Requeued)
+}
@@ -20,3 +20,19 @@ var (
 	ErrTaskExists   = errors.New("task already exists in store")
 	ErrTaskNotFound = errors.New("task not found")
 )
+
+// Failure reasons recorded for tasks which are marked as failed without being
+// executed.
+var (
+	errInterrupted = errors.New("task was pending when manager started")
+	errQueueFull   = errors.New("task queue full")
+)
+
+// ErrorString returns the message of err, or an empty string if err is nil.
+// Suitable for recording the last error of a task.
+func ErrorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -19,6 +19,11 @@ import "time"
 type Task interface {
 	GetLastAttempt() time.Time
 	GetFailures() int
+
+	// GetLastError returns the error message of the most recent failure of
+	// the task, or an empty string if the task has not failed.
+	GetLastError() string
+
 	Ready() bool
 
 	// Tags returns tags describing the context of the task, which can be
@@ -26,6 +31,13 @@ type Task interface {
 	Tags() map[string]string
 }
 
+// Keyed is a Task which defines a key uniquely identifying it within its Store.
+// The Manager never executes two tasks with the same key concurrently.
+type Keyed interface {
+	Task
+	GetKey() string
+}
+
 // Store provides persisted storage for tasks.
 type Store interface {
 	// AddPending adds a new task as pending in the store. Implementations should
@@ -39,8 +51,17 @@ type Store interface {
 	// MarkPending marks an existing task as pending.
 	MarkPending(Task) error
 
-	// MarkFailed marks an existing task as failed.
-	MarkFailed(Task) error
+	// MarkFailed marks an existing task as failed, recording err as the reason
+	// of the failure and incrementing the failure count of the task.
+	MarkFailed(Task, error) error
+
+	// MarkDead marks an existing task as dead. Dead tasks are not returned
+	// by GetPending or GetFailed.
+	MarkDead(Task) error
+
+	// Requeue marks an existing dead task as pending, resetting its failure
+	// count so it is retried up to the maximum number of retries again.
+	Requeue(Task) error
 
 	// GetPending returns all pending Tasks.
 	GetPending() ([]Task, error)
@@ -48,6 +69,9 @@ type Store interface {
 	// GetFailed returns all failed Tasks.
 	GetFailed() ([]Task, error)
 
+	// GetDead returns all dead Tasks.
+	GetDead() ([]Task, error)
+
 	// Remove removes a task from the store.
 	Remove(Task) error
 
@@ -55,6 +79,26 @@ type Store interface {
 	Find(query interface{}) ([]Task, error)
 }
 
+// BatchRemover is an optional interface for Stores which can remove many tasks
+// in a single transaction.
+type BatchRemover interface {
+	RemoveBatch([]Task) error
+}
+
+// RemoveBatch removes tasks from s. If s implements BatchRemover, tasks are
+// removed in a single batch, else they are removed one at a time.
+func RemoveBatch(s Store, tasks []Task) error {
+	if b, ok := s.(BatchRemover); ok {
+		return b.RemoveBatch(tasks)
+	}
+	for _, t := range tasks {
+		if err := s.Remove(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Executor executes tasks.
 type Executor interface {
 	Exec(Task) error
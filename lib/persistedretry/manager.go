@@ -34,15 +34,101 @@ type Manager interface {
 	SyncExec(Task) error
 	Close()
 	Find(query interface{}) ([]Task, error)
+	List(state TaskState) ([]Task, error)
+	Counts() (map[TaskState]int, error)
+	RequeueDead() (int, error)
 }
 
+// TaskState describes the state of a task within a Manager.
+type TaskState string
+
+// Task states.
+const (
+	// StatePending tasks are queued or being executed.
+	StatePending TaskState = "pending"
+
+	// StateFailed tasks are waiting to be retried.
+	StateFailed TaskState = "failed"
+
+	// StateInProgress tasks are currently being executed. This is a subset of
+	// StatePending.
+	StateInProgress TaskState = "in_progress"
+
+	// StateDead tasks have exceeded the maximum number of retries and will not
+	// be executed again unless requeued.
+	StateDead TaskState = "dead"
+)
+
+// TaskStates lists all task states.
+var TaskStates = []TaskState{StatePending, StateFailed, StateInProgress, StateDead}
+
+// Priority lanes of a queue, ordered from highest to lowest priority.
+const (
+	_highLane = iota
+	_defaultLane
+	_lowLane
+	_numLanes
+)
+
+func lane(t Task) int {
+	p := GetPriority(t)
+	switch {
+	case p > PriorityDefault:
+		return _highLane
+	case p < PriorityDefault:
+		return _lowLane
+	default:
+		return _defaultLane
+	}
+}
+
+// queue buffers tasks in separate lanes per priority, such that workers always
+// drain higher priority lanes first. Each lane buffers up to size tasks.
 type queue struct {
-	tasks   chan Task
+	lanes   [_numLanes]chan Task
 	counter tally.Counter
 }
 
 func newQueue(size int, counter tally.Counter) *queue {
-	return &queue{make(chan Task, size), counter}
+	q := &queue{counter: counter}
+	for i := range q.lanes {
+		q.lanes[i] = make(chan Task, size)
+	}
+	return q
+}
+
+// offer adds t to q without blocking. Returns false if t's lane is full.
+func (q *queue) offer(t Task) bool {
+	select {
+	case q.lanes[lane(t)] <- t:
+		q.counter.Inc(1)
+		return true
+	default:
+		return false
+	}
+}
+
+// next blocks until a task is available, returning the highest priority task.
+// Returns false if done is closed first.
+func (q *queue) next(done <-chan struct{}) (Task, bool) {
+	for _, l := range q.lanes {
+		select {
+		case t := <-l:
+			q.counter.Inc(-1)
+			return t, true
+		default:
+		}
+	}
+	var t Task
+	select {
+	case <-done:
+		return nil, false
+	case t = <-q.lanes[_highLane]:
+	case t = <-q.lanes[_defaultLane]:
+	case t = <-q.lanes[_lowLane]:
+	}
+	q.counter.Inc(-1)
+	return t, true
 }
 
 type manager struct {
@@ -56,6 +142,18 @@ type manager struct {
 	incoming *queue
 	retries  *queue
 
+	// Tasks currently being executed, keyed by worker id, the keys of
+	// in-progress Keyed tasks, and duplicates of in-progress Keyed tasks which
+	// are executed once their twin is released.
+	inProgressMu   sync.Mutex
+	inProgress     map[int]Task
+	inProgressKeys map[string]bool
+	deferred       map[string]Task
+
+	// Successfully executed tasks waiting to be removed from store.
+	completedMu sync.Mutex
+	completed   []Task
+
 	closeOnce sync.Once
 	done      chan struct{}
 	closed    atomic.Bool
@@ -71,13 +169,16 @@ func NewManager(
 	})
 	config = config.applyDefaults()
 	m := &manager{
-		config:   config,
-		stats:    stats,
-		store:    store,
-		executor: executor,
-		incoming: newQueue(config.IncomingBuffer, stats.Counter("incoming")),
-		retries:  newQueue(config.RetryBuffer, stats.Counter("retries")),
-		done:     make(chan struct{}),
+		config:         config,
+		stats:          stats,
+		store:          store,
+		executor:       executor,
+		incoming:       newQueue(config.IncomingBuffer, stats.Counter("incoming")),
+		retries:        newQueue(config.RetryBuffer, stats.Counter("retries")),
+		inProgress:     make(map[int]Task),
+		inProgressKeys: make(map[string]bool),
+		deferred:       make(map[string]Task),
+		done:           make(chan struct{}),
 	}
 	if err := m.markPendingTasksAsFailed(); err != nil {
 		return nil, fmt.Errorf("mark pending tasks as failed: %s", err)
@@ -94,7 +195,7 @@ func (m *manager) markPendingTasksAsFailed() error {
 		return fmt.Errorf("get pending tasks: %s", err)
 	}
 	for _, t := range tasks {
-		if err := m.store.MarkFailed(t); err != nil {
+		if err := m.store.MarkFailed(t, errInterrupted); err != nil {
 			return fmt.Errorf("mark task as failed: %s", err)
 		}
 	}
@@ -110,13 +211,16 @@ func (m *manager) start() error {
 	totalWorkers := m.config.NumIncomingWorkers + m.config.NumRetryWorkers
 	limit := m.config.MaxTaskThroughput * time.Duration(totalWorkers)
 
+	var id int
 	for i := 0; i < m.config.NumIncomingWorkers; i++ {
 		m.wg.Add(1)
-		go m.worker(m.incoming, limit)
+		go m.worker(id, m.incoming, limit)
+		id++
 	}
 	for i := 0; i < m.config.NumRetryWorkers; i++ {
 		m.wg.Add(1)
-		go m.worker(m.retries, limit)
+		go m.worker(id, m.retries, limit)
+		id++
 	}
 
 	m.wg.Add(1)
@@ -130,6 +234,13 @@ func (m *manager) Add(t Task) error {
 	if m.closed.Load() {
 		return ErrManagerClosed
 	}
+	if k, ok := t.(Keyed); ok {
+		// A completed instance of t still in store would reject t as a
+		// duplicate, and then remove t once flushed.
+		if err := m.removeCompleted(k.GetKey()); err != nil {
+			return fmt.Errorf("remove completed: %s", err)
+		}
+	}
 	ready := t.Ready()
 	var err error
 	if ready {
@@ -164,6 +275,7 @@ func (m *manager) Close() {
 		m.closed.Store(true)
 		close(m.done)
 		m.wg.Wait()
+		m.flushCompleted()
 	})
 }
 
@@ -171,14 +283,107 @@ func (m *manager) Find(query interface{}) ([]Task, error) {
 	return m.store.Find(query)
 }
 
-func (m *manager) enqueue(t Task, q *queue) error {
-	select {
-	case q.tasks <- t:
-		q.counter.Inc(1)
+// List returns all tasks in state.
+func (m *manager) List(state TaskState) ([]Task, error) {
+	switch state {
+	case StatePending:
+		return m.store.GetPending()
+	case StateFailed:
+		return m.store.GetFailed()
+	case StateInProgress:
+		return m.listInProgress(), nil
+	case StateDead:
+		return m.store.GetDead()
 	default:
+		return nil, fmt.Errorf("unknown task state: %q", state)
+	}
+}
+
+// Counts returns the number of tasks in each state.
+func (m *manager) Counts() (map[TaskState]int, error) {
+	counts := make(map[TaskState]int)
+	for _, state := range TaskStates {
+		tasks, err := m.List(state)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %s", state, err)
+		}
+		counts[state] = len(tasks)
+	}
+	return counts, nil
+}
+
+// RequeueDead moves all dead tasks back into the retry queue. Returns the
+// number of requeued tasks.
+func (m *manager) RequeueDead() (int, error) {
+	if m.closed.Load() {
+		return 0, ErrManagerClosed
+	}
+	tasks, err := m.store.GetDead()
+	if err != nil {
+		return 0, fmt.Errorf("get dead tasks: %s", err)
+	}
+	for i, t := range tasks {
+		if err := m.store.Requeue(t); err != nil {
+			return i, fmt.Errorf("requeue: %s", err)
+		}
+		if err := m.enqueue(t, m.retries); err != nil {
+			return i, fmt.Errorf("enqueue: %s", err)
+		}
+	}
+	return len(tasks), nil
+}
+
+func (m *manager) listInProgress() []Task {
+	m.inProgressMu.Lock()
+	defer m.inProgressMu.Unlock()
+
+	tasks := make([]Task, 0, len(m.inProgress))
+	for _, t := range m.inProgress {
+		tasks = append(tasks, t)
+	}
+	return tasks
+}
+
+// claim marks t as in progress on worker id. Returns false if another worker
+// is already executing a task with the same key as t, in which case t is
+// deferred until that task is released.
+func (m *manager) claim(id int, t Task) bool {
+	m.inProgressMu.Lock()
+	defer m.inProgressMu.Unlock()
+
+	if k, ok := t.(Keyed); ok {
+		if m.inProgressKeys[k.GetKey()] {
+			m.deferred[k.GetKey()] = t
+			return false
+		}
+		m.inProgressKeys[k.GetKey()] = true
+	}
+	m.inProgress[id] = t
+	return true
+}
+
+// release clears the in progress task of worker id. Returns the task deferred
+// while it was in progress, if any.
+func (m *manager) release(id int) (Task, bool) {
+	m.inProgressMu.Lock()
+	defer m.inProgressMu.Unlock()
+
+	var deferred Task
+	var ok bool
+	if k, keyed := m.inProgress[id].(Keyed); keyed {
+		delete(m.inProgressKeys, k.GetKey())
+		deferred, ok = m.deferred[k.GetKey()]
+		delete(m.deferred, k.GetKey())
+	}
+	delete(m.inProgress, id)
+	return deferred, ok
+}
+
+func (m *manager) enqueue(t Task, q *queue) error {
+	if !q.offer(t) {
 		// If task queue is full, fallback task to failure state so it can be
 		// picked up by a retry round.
-		if err := m.store.MarkFailed(t); err != nil {
+		if err := m.store.MarkFailed(t, errQueueFull); err != nil {
 			return fmt.Errorf("mark task as failed: %s", err)
 		}
 	}
@@ -195,21 +400,30 @@ func (m *manager) retry(t Task) error {
 	return nil
 }
 
-func (m *manager) worker(q *queue, limit time.Duration) {
+func (m *manager) worker(id int, q *queue, limit time.Duration) {
 	defer m.wg.Done()
 
 	for {
-		select {
-		case <-m.done:
+		t, ok := q.next(m.done)
+		if !ok {
 			return
-		case t := <-q.tasks:
-			q.counter.Inc(-1)
-			if err := m.exec(t); err != nil {
-				m.stats.Counter("exec_failures").Inc(1)
-				log.With("task", t).Errorf("Failed to exec task: %s", err)
+		}
+		if !m.claim(id, t) {
+			m.stats.Counter("duplicate_tasks").Inc(1)
+			continue
+		}
+		if err := m.exec(t); err != nil {
+			m.stats.Counter("exec_failures").Inc(1)
+			log.With("task", t).Errorf("Failed to exec task: %s", err)
+		}
+		if d, ok := m.release(id); ok {
+			// The duplicate is still pending in store, so it is executed again
+			// without counting a failure.
+			if err := m.enqueue(d, m.retries); err != nil {
+				log.With("task", d).Errorf("Error enqueuing deferred task: %s", err)
 			}
-			time.Sleep(limit)
 		}
+		time.Sleep(limit)
 	}
 }
 
@@ -217,14 +431,108 @@ func (m *manager) tickerLoop() {
 	defer m.wg.Done()
 
 	pollRetriesTicker := time.NewTicker(m.config.PollRetriesInterval)
+	defer pollRetriesTicker.Stop()
+	flushCompletedTicker := time.NewTicker(m.config.CompletionBatchInterval)
+	defer flushCompletedTicker.Stop()
+	emitCountsTicker := time.NewTicker(m.config.EmitCountsInterval)
+	defer emitCountsTicker.Stop()
 	for {
 		select {
 		case <-m.done:
 			return
 		case <-pollRetriesTicker.C:
 			m.pollRetries()
+		case <-flushCompletedTicker.C:
+			m.flushCompleted()
+		case <-emitCountsTicker.C:
+			m.emitCounts()
+		}
+	}
+}
+
+// emitCounts emits the number of tasks in each state, such that alerts can
+// detect when execution falls behind.
+func (m *manager) emitCounts() {
+	counts, err := m.Counts()
+	if err != nil {
+		m.stats.Counter("counts_failure").Inc(1)
+		log.Errorf("Error counting tasks: %s", err)
+		return
+	}
+	for state, n := range counts {
+		m.stats.Gauge(string(state) + "_tasks").Update(float64(n))
+	}
+}
+
+// complete buffers t for removal from store. The buffer is flushed early once
+// it reaches the configured batch size.
+func (m *manager) complete(t Task) {
+	m.completedMu.Lock()
+	m.completed = append(m.completed, t)
+	full := len(m.completed) >= m.config.CompletionBatchSize
+	m.completedMu.Unlock()
+
+	if full {
+		m.flushCompleted()
+	}
+}
+
+// removeCompleted removes buffered completed tasks with key from store ahead of
+// the next flush.
+func (m *manager) removeCompleted(key string) error {
+	var removed []Task
+	m.completedMu.Lock()
+	remaining := m.completed[:0]
+	for _, t := range m.completed {
+		if k, ok := t.(Keyed); ok && k.GetKey() == key {
+			removed = append(removed, t)
+		} else {
+			remaining = append(remaining, t)
+		}
+	}
+	m.completed = remaining
+	m.completedMu.Unlock()
+
+	for _, t := range removed {
+		if err := m.store.Remove(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushCompleted removes all buffered completed tasks from store. If the batch
+// cannot be removed, tasks are removed individually, and tasks which still
+// fail are buffered again for the next flush, such that they are not
+// re-executed on restart.
+func (m *manager) flushCompleted() {
+	m.completedMu.Lock()
+	tasks := m.completed
+	m.completed = nil
+	m.completedMu.Unlock()
+
+	if len(tasks) == 0 {
+		return
+	}
+	err := RemoveBatch(m.store, tasks)
+	if err == nil {
+		return
+	}
+	log.Errorf("Error removing %d completed tasks in batch: %s", len(tasks), err)
+	var failed []Task
+	for _, t := range tasks {
+		if err := m.store.Remove(t); err != nil {
+			log.With("task", t).Errorf("Error removing completed task: %s", err)
+			failed = append(failed, t)
 		}
 	}
+	if len(failed) == 0 {
+		return
+	}
+	m.stats.Counter("remove_failures").Inc(int64(len(failed)))
+	m.completedMu.Lock()
+	m.completed = append(m.completed, failed...)
+	m.completedMu.Unlock()
 }
 
 func (m *manager) pollRetries() {
@@ -234,7 +542,12 @@ func (m *manager) pollRetries() {
 		log.Errorf("Error getting failed tasks: %s", err)
 		return
 	}
+	sortByPriority(tasks)
 	for _, t := range tasks {
+		if m.exceededMaxRetries(t) {
+			m.markDead(t)
+			continue
+		}
 		if t.Ready() && time.Since(t.GetLastAttempt()) > m.config.RetryInterval {
 			if err := m.retry(t); err != nil {
 				log.With("task", t).Errorf("Error adding retry task: %s", err)
@@ -244,18 +557,39 @@ func (m *manager) pollRetries() {
 }
 
 func (m *manager) exec(t Task) error {
-	if err := m.executor.Exec(t); err != nil {
-		if err := m.store.MarkFailed(t); err != nil {
-			return fmt.Errorf("mark task as failed: %s", err)
+	stats := m.stats.Tagged(t.Tags())
+	start := time.Now()
+	err := m.executor.Exec(t)
+	stats.Timer("exec_latency").Record(time.Since(start))
+	if err != nil {
+		if markErr := m.store.MarkFailed(t, err); markErr != nil {
+			return fmt.Errorf("mark task as failed: %s", markErr)
 		}
 		log.With(
 			"task", t,
 			"failures", t.GetFailures()).Errorf("Task failed: %s", err)
-		m.stats.Tagged(t.Tags()).Counter("task_failures").Inc(1)
+		stats.Counter("task_failures").Inc(1)
+		if m.exceededMaxRetries(t) {
+			m.markDead(t)
+		}
 		return nil
 	}
-	if err := m.store.Remove(t); err != nil {
-		return fmt.Errorf("remove task: %s", err)
-	}
+	stats.Counter("task_successes").Inc(1)
+	m.complete(t)
 	return nil
 }
+
+func (m *manager) exceededMaxRetries(t Task) bool {
+	return m.config.MaxRetries > 0 && t.GetFailures() >= m.config.MaxRetries
+}
+
+// markDead moves t into the dead-letter state, such that it is no longer
+// polled for retries.
+func (m *manager) markDead(t Task) {
+	if err := m.store.MarkDead(t); err != nil {
+		log.With("task", t).Errorf("Error marking task as dead: %s", err)
+		return
+	}
+	log.With("task", t, "failures", t.GetFailures()).Warn("Task exceeded max retries, marked as dead")
+	m.stats.Tagged(t.Tags()).Counter("task_deaths").Inc(1)
+}
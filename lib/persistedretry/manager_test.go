@@ -16,6 +16,7 @@ package persistedretry_test
 import (
 	"errors"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 
@@ -64,7 +65,9 @@ func (m *managerMocks) new() (Manager, error) {
 }
 
 func (m *managerMocks) task() *mockpersistedretry.MockTask {
-	return mockpersistedretry.NewMockTask(m.ctrl)
+	t := mockpersistedretry.NewMockTask(m.ctrl)
+	t.EXPECT().Tags().Return(nil).AnyTimes()
+	return t
 }
 
 func TestNewManagerMarksAllPendingTasksAsFailed(t *testing.T) {
@@ -79,8 +82,8 @@ func TestNewManagerMarksAllPendingTasksAsFailed(t *testing.T) {
 
 	gomock.InOrder(
 		mocks.store.EXPECT().GetPending().Return(tasks, nil),
-		mocks.store.EXPECT().MarkFailed(tasks[0]).Return(nil),
-		mocks.store.EXPECT().MarkFailed(tasks[1]).Return(nil),
+		mocks.store.EXPECT().MarkFailed(tasks[0], gomock.Any()).Return(nil),
+		mocks.store.EXPECT().MarkFailed(tasks[1], gomock.Any()).Return(nil),
 	)
 
 	m, err := mocks.new()
@@ -142,6 +145,7 @@ func TestManagerAddTaskFail(t *testing.T) {
 	defer cleanup()
 
 	task := mocks.task()
+	execErr := errors.New("task failed")
 
 	mocks.store.EXPECT().GetFailed().Return(nil, nil).AnyTimes()
 
@@ -149,10 +153,9 @@ func TestManagerAddTaskFail(t *testing.T) {
 		mocks.store.EXPECT().GetPending().Return(nil, nil),
 		task.EXPECT().Ready().Return(true),
 		mocks.store.EXPECT().AddPending(task).Return(nil),
-		mocks.executor.EXPECT().Exec(task).Return(errors.New("task failed")),
-		mocks.store.EXPECT().MarkFailed(task).Return(nil),
+		mocks.executor.EXPECT().Exec(task).Return(execErr),
+		mocks.store.EXPECT().MarkFailed(task, execErr).Return(nil),
 		task.EXPECT().GetFailures().Return(1),
-		task.EXPECT().Tags().Return(nil),
 	)
 
 	m, err := mocks.new()
@@ -193,7 +196,7 @@ func TestManagerAddTaskFallbackWhenWorkersBusy(t *testing.T) {
 	gomock.InOrder(
 		task2.EXPECT().Ready().Return(true),
 		mocks.store.EXPECT().AddPending(task2).Return(nil),
-		mocks.store.EXPECT().MarkFailed(task2).Return(nil),
+		mocks.store.EXPECT().MarkFailed(task2, gomock.Any()).Return(nil),
 	)
 
 	m, err := mocks.new()
@@ -379,3 +382,550 @@ func TestManagerExec(t *testing.T) {
 
 	require.NoError(m.SyncExec(task))
 }
+
+func TestManagerListAndCounts(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	mocks.config.PollRetriesInterval = time.Hour
+
+	running := mocks.task()
+	pending := []Task{running, mocks.task()}
+	failed := []Task{mocks.task()}
+	dead := []Task{mocks.task()}
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+
+	gomock.InOrder(
+		mocks.store.EXPECT().GetPending().Return(nil, nil),
+		running.EXPECT().Ready().Return(true),
+		mocks.store.EXPECT().AddPending(running).Return(nil),
+		mocks.executor.EXPECT().Exec(running).DoAndReturn(func(Task) error {
+			close(started)
+			<-unblock
+			return nil
+		}),
+		mocks.store.EXPECT().Remove(running).Return(nil),
+	)
+
+	m, err := mocks.new()
+	require.NoError(err)
+	defer m.Close()
+
+	waitForWorkers()
+
+	require.NoError(m.Add(running))
+	<-started
+
+	mocks.store.EXPECT().GetPending().Return(pending, nil).Times(2)
+	mocks.store.EXPECT().GetFailed().Return(failed, nil).Times(2)
+	mocks.store.EXPECT().GetDead().Return(dead, nil).Times(2)
+
+	result, err := m.List(StatePending)
+	require.NoError(err)
+	require.Equal(pending, result)
+
+	result, err = m.List(StateFailed)
+	require.NoError(err)
+	require.Equal(failed, result)
+
+	result, err = m.List(StateInProgress)
+	require.NoError(err)
+	require.Equal([]Task{running}, result)
+
+	result, err = m.List(StateDead)
+	require.NoError(err)
+	require.Equal(dead, result)
+
+	counts, err := m.Counts()
+	require.NoError(err)
+	require.Equal(map[TaskState]int{
+		StatePending:    2,
+		StateFailed:     1,
+		StateInProgress: 1,
+		StateDead:       1,
+	}, counts)
+
+	_, err = m.List("unknown")
+	require.Error(err)
+
+	close(unblock)
+	time.Sleep(50 * time.Millisecond)
+
+	result, err = m.List(StateInProgress)
+	require.NoError(err)
+	require.Empty(result)
+}
+
+type priorityTask struct {
+	*mockpersistedretry.MockTask
+	priority int
+}
+
+func (t priorityTask) GetPriority() int {
+	return t.priority
+}
+
+func TestManagerExecutesHigherPriorityTasksFirst(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	mocks.config.IncomingBuffer = 3
+
+	blocker := mocks.task()
+	low := priorityTask{mocks.task(), PriorityLow}
+	normal := mocks.task()
+	high := priorityTask{mocks.task(), PriorityHigh}
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+
+	mocks.store.EXPECT().GetFailed().Return(nil, nil).AnyTimes()
+	mocks.store.EXPECT().GetPending().Return(nil, nil)
+
+	for _, task := range []Task{blocker, low, normal, high} {
+		mocks.store.EXPECT().AddPending(task).Return(nil)
+		mocks.store.EXPECT().Remove(task).Return(nil)
+	}
+	blocker.EXPECT().Ready().Return(true)
+	low.EXPECT().Ready().Return(true)
+	normal.EXPECT().Ready().Return(true)
+	high.EXPECT().Ready().Return(true)
+
+	gomock.InOrder(
+		mocks.executor.EXPECT().Exec(blocker).DoAndReturn(func(Task) error {
+			close(started)
+			<-unblock
+			return nil
+		}),
+		mocks.executor.EXPECT().Exec(high).Return(nil),
+		mocks.executor.EXPECT().Exec(normal).Return(nil),
+		mocks.executor.EXPECT().Exec(low).Return(nil),
+	)
+
+	m, err := mocks.new()
+	require.NoError(err)
+	defer m.Close()
+
+	waitForWorkers()
+
+	require.NoError(m.Add(blocker))
+	<-started
+
+	require.NoError(m.Add(low))
+	require.NoError(m.Add(normal))
+	require.NoError(m.Add(high))
+
+	close(unblock)
+
+	time.Sleep(100 * time.Millisecond)
+}
+
+func TestManagerBatchesCompletedTasks(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	tasks := []Task{mocks.task(), mocks.task(), mocks.task()}
+
+	mocks.config.CompletionBatchInterval = time.Hour
+	mocks.config.CompletionBatchSize = len(tasks)
+	mocks.config.IncomingBuffer = len(tasks)
+
+	store := &batchStore{MockStore: mocks.store}
+
+	mocks.store.EXPECT().GetFailed().Return(nil, nil).AnyTimes()
+	mocks.store.EXPECT().GetPending().Return(nil, nil)
+	for _, task := range tasks {
+		task.(*mockpersistedretry.MockTask).EXPECT().Ready().Return(true)
+		mocks.store.EXPECT().AddPending(task).Return(nil)
+		mocks.executor.EXPECT().Exec(task).Return(nil)
+	}
+
+	mocks.executor.EXPECT().Name().Return("mock executor")
+	m, err := NewManager(mocks.config, tally.NoopScope, store, mocks.executor)
+	require.NoError(err)
+	defer m.Close()
+
+	waitForWorkers()
+
+	for _, task := range tasks {
+		require.NoError(m.Add(task))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	batches := store.getBatches()
+	require.Len(batches, 1)
+	require.ElementsMatch(tasks, batches[0])
+}
+
+func TestManagerRemovesCompletedTasksIndividuallyWhenBatchFails(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	tasks := []Task{mocks.task(), mocks.task()}
+
+	mocks.config.CompletionBatchInterval = time.Hour
+	mocks.config.CompletionBatchSize = len(tasks)
+	mocks.config.IncomingBuffer = len(tasks)
+
+	store := &batchStore{MockStore: mocks.store, err: errors.New("some error")}
+
+	mocks.store.EXPECT().GetFailed().Return(nil, nil).AnyTimes()
+	mocks.store.EXPECT().GetPending().Return(nil, nil)
+	for _, task := range tasks {
+		task.(*mockpersistedretry.MockTask).EXPECT().Ready().Return(true)
+		mocks.store.EXPECT().AddPending(task).Return(nil)
+		mocks.executor.EXPECT().Exec(task).Return(nil)
+	}
+	mocks.store.EXPECT().Remove(tasks[0]).Return(nil)
+	gomock.InOrder(
+		mocks.store.EXPECT().Remove(tasks[1]).Return(errors.New("some error")),
+		// The failed task is removed by the next flush.
+		mocks.store.EXPECT().Remove(tasks[1]).Return(nil),
+	)
+
+	mocks.executor.EXPECT().Name().Return("mock executor")
+	m, err := NewManager(mocks.config, tally.NoopScope, store, mocks.executor)
+	require.NoError(err)
+
+	waitForWorkers()
+
+	for _, task := range tasks {
+		require.NoError(m.Add(task))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	m.Close()
+
+	batches := store.getBatches()
+	require.Len(batches, 2)
+	require.ElementsMatch(tasks, batches[0])
+	require.Equal([]Task{tasks[1]}, batches[1])
+}
+
+func TestManagerFlushesCompletedTasksOnClose(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	mocks.config.CompletionBatchInterval = time.Hour
+
+	task := mocks.task()
+
+	mocks.store.EXPECT().GetFailed().Return(nil, nil).AnyTimes()
+
+	gomock.InOrder(
+		mocks.store.EXPECT().GetPending().Return(nil, nil),
+		task.EXPECT().Ready().Return(true),
+		mocks.store.EXPECT().AddPending(task).Return(nil),
+		mocks.executor.EXPECT().Exec(task).Return(nil),
+		mocks.store.EXPECT().Remove(task).Return(nil),
+	)
+
+	m, err := mocks.new()
+	require.NoError(err)
+
+	waitForWorkers()
+
+	require.NoError(m.Add(task))
+
+	time.Sleep(50 * time.Millisecond)
+
+	m.Close()
+}
+
+func TestManagerAddRemovesCompletedInstanceBeforeFlush(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	mocks.config.CompletionBatchInterval = time.Hour
+	mocks.config.IncomingBuffer = 1
+
+	completed := keyedTask{mocks.task(), "foo"}
+	readded := keyedTask{mocks.task(), "foo"}
+
+	store := &batchStore{MockStore: mocks.store}
+
+	mocks.store.EXPECT().GetPending().Return(nil, nil)
+	mocks.store.EXPECT().GetFailed().Return(nil, nil).AnyTimes()
+
+	completed.EXPECT().Ready().Return(true)
+	readded.EXPECT().Ready().Return(false)
+	gomock.InOrder(
+		mocks.store.EXPECT().AddPending(completed).Return(nil),
+		mocks.executor.EXPECT().Exec(completed).Return(nil),
+		mocks.store.EXPECT().Remove(completed).Return(nil),
+		mocks.store.EXPECT().AddFailed(readded).Return(nil),
+	)
+
+	mocks.executor.EXPECT().Name().Return("mock executor")
+	m, err := NewManager(mocks.config, tally.NoopScope, store, mocks.executor)
+	require.NoError(err)
+
+	waitForWorkers()
+
+	require.NoError(m.Add(completed))
+
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(m.Add(readded))
+
+	// The completed instance is no longer flushed, which would remove the
+	// re-added instance.
+	m.Close()
+	require.Empty(store.getBatches())
+}
+
+// batchStore wraps a MockStore with BatchRemover support. Batches are
+// recorded, and fail with err if set.
+type batchStore struct {
+	*mockpersistedretry.MockStore
+
+	mu      sync.Mutex
+	batches [][]Task
+	err     error
+}
+
+func (s *batchStore) RemoveBatch(tasks []Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, tasks)
+	return s.err
+}
+
+func (s *batchStore) getBatches() [][]Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batches
+}
+
+func TestManagerEmitsMetrics(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	mocks.config.EmitCountsInterval = 5 * time.Millisecond
+
+	stats := tally.NewTestScope("", nil)
+
+	succeeded := mocks.task()
+	failed := mocks.task()
+
+	mocks.store.EXPECT().GetPending().Return(nil, nil).AnyTimes()
+	mocks.store.EXPECT().GetFailed().Return([]Task{failed}, nil).AnyTimes()
+	mocks.store.EXPECT().GetDead().Return(nil, nil).AnyTimes()
+
+	failed.EXPECT().Ready().Return(false).AnyTimes()
+	mocks.store.EXPECT().AddFailed(failed).Return(nil)
+
+	succeeded.EXPECT().Ready().Return(true)
+	mocks.store.EXPECT().AddPending(succeeded).Return(nil)
+	mocks.executor.EXPECT().Exec(succeeded).Return(nil)
+	mocks.store.EXPECT().Remove(succeeded).Return(nil)
+
+	mocks.executor.EXPECT().Name().Return("mock executor")
+	m, err := NewManager(mocks.config, stats, mocks.store, mocks.executor)
+	require.NoError(err)
+
+	waitForWorkers()
+
+	require.NoError(m.Add(failed))
+	require.NoError(m.Add(succeeded))
+
+	time.Sleep(50 * time.Millisecond)
+
+	m.Close()
+
+	snapshot := stats.Snapshot()
+
+	counters := make(map[string]int64)
+	for _, c := range snapshot.Counters() {
+		counters[c.Name()] += c.Value()
+	}
+	require.Equal(int64(1), counters["task_successes"])
+
+	var latencies int
+	for _, timer := range snapshot.Timers() {
+		if timer.Name() == "exec_latency" {
+			latencies += len(timer.Values())
+		}
+	}
+	require.Equal(1, latencies)
+
+	gauges := make(map[string]float64)
+	for _, g := range snapshot.Gauges() {
+		gauges[g.Name()] = g.Value()
+	}
+	require.Equal(float64(0), gauges["pending_tasks"])
+	require.Equal(float64(1), gauges["failed_tasks"])
+	require.Equal(float64(0), gauges["in_progress_tasks"])
+	require.Equal(float64(0), gauges["dead_tasks"])
+}
+
+func TestManagerMarksTaskDeadAfterMaxRetries(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	mocks.config.MaxRetries = 3
+
+	task := mocks.task()
+	task.EXPECT().GetFailures().Return(3).AnyTimes()
+
+	mocks.store.EXPECT().GetFailed().Return(nil, nil).AnyTimes()
+
+	gomock.InOrder(
+		mocks.store.EXPECT().GetPending().Return(nil, nil),
+		task.EXPECT().Ready().Return(true),
+		mocks.store.EXPECT().AddPending(task).Return(nil),
+		mocks.executor.EXPECT().Exec(task).Return(errors.New("task failed")),
+		mocks.store.EXPECT().MarkFailed(task, gomock.Any()).Return(nil),
+		mocks.store.EXPECT().MarkDead(task).Return(nil),
+	)
+
+	m, err := mocks.new()
+	require.NoError(err)
+	defer m.Close()
+
+	waitForWorkers()
+
+	require.NoError(m.Add(task))
+
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestManagerPollRetriesMarksExhaustedTasksDead(t *testing.T) {
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	mocks.config.MaxRetries = 3
+
+	task := mocks.task()
+	task.EXPECT().GetFailures().Return(5).AnyTimes()
+
+	mocks.store.EXPECT().GetPending().Return(nil, nil)
+	first := mocks.store.EXPECT().GetFailed().Return([]Task{task}, nil)
+	mocks.store.EXPECT().GetFailed().Return(nil, nil).After(first).AnyTimes()
+	mocks.store.EXPECT().MarkDead(task).Return(nil)
+
+	m, err := mocks.new()
+	require.NoError(t, err)
+	defer m.Close()
+
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestManagerRequeueDead(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	mocks.config.PollRetriesInterval = time.Hour
+
+	task := mocks.task()
+
+	gomock.InOrder(
+		mocks.store.EXPECT().GetPending().Return(nil, nil),
+		mocks.store.EXPECT().GetDead().Return([]Task{task}, nil),
+		mocks.store.EXPECT().Requeue(task).Return(nil),
+		mocks.executor.EXPECT().Exec(task).Return(nil),
+		mocks.store.EXPECT().Remove(task).Return(nil),
+	)
+
+	m, err := mocks.new()
+	require.NoError(err)
+	defer m.Close()
+
+	waitForWorkers()
+
+	n, err := m.RequeueDead()
+	require.NoError(err)
+	require.Equal(1, n)
+
+	time.Sleep(50 * time.Millisecond)
+}
+
+type keyedTask struct {
+	*mockpersistedretry.MockTask
+	key string
+}
+
+func (t keyedTask) GetKey() string {
+	return t.key
+}
+
+func TestManagerDoesNotExecuteSameKeyConcurrently(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	mocks.config.NumIncomingWorkers = 2
+	mocks.config.IncomingBuffer = 2
+	mocks.config.RetryBuffer = 1
+
+	task1 := keyedTask{mocks.task(), "foo"}
+	task2 := keyedTask{mocks.task(), "foo"}
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+
+	mocks.store.EXPECT().GetPending().Return(nil, nil)
+	mocks.store.EXPECT().GetFailed().Return(nil, nil).AnyTimes()
+
+	task1.EXPECT().Ready().Return(true)
+	mocks.store.EXPECT().AddPending(task1).Return(nil)
+	mocks.executor.EXPECT().Exec(task1).DoAndReturn(func(Task) error {
+		close(started)
+		<-unblock
+		return nil
+	})
+	mocks.store.EXPECT().Remove(task1).Return(nil)
+
+	task2.EXPECT().Ready().Return(true)
+	mocks.store.EXPECT().AddPending(task2).Return(nil)
+	// The duplicate is executed once task1 is released, without being marked
+	// as failed.
+	mocks.executor.EXPECT().Exec(task2).DoAndReturn(func(Task) error {
+		select {
+		case <-unblock:
+		default:
+			t.Error("duplicate executed concurrently")
+		}
+		return nil
+	})
+	mocks.store.EXPECT().Remove(task2).Return(nil)
+
+	m, err := mocks.new()
+	require.NoError(err)
+	defer m.Close()
+
+	waitForWorkers()
+
+	require.NoError(m.Add(task1))
+	<-started
+	require.NoError(m.Add(task2))
+
+	time.Sleep(50 * time.Millisecond)
+
+	close(unblock)
+
+	time.Sleep(50 * time.Millisecond)
+}
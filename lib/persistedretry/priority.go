@@ -0,0 +1,47 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package persistedretry
+
+import "sort"
+
+// Task priorities. Any int is a valid priority, these are provided as
+// reference points.
+const (
+	PriorityLow     = 0
+	PriorityDefault = 50
+	PriorityHigh    = 100
+)
+
+// Prioritizable is a Task which defines its execution priority. Tasks with
+// higher priority are executed first. Tasks which do not implement
+// Prioritizable are executed with PriorityDefault.
+type Prioritizable interface {
+	Task
+	GetPriority() int
+}
+
+// GetPriority returns the priority of t.
+func GetPriority(t Task) int {
+	if p, ok := t.(Prioritizable); ok {
+		return p.GetPriority()
+	}
+	return PriorityDefault
+}
+
+// sortByPriority stably sorts tasks from highest to lowest priority.
+func sortByPriority(tasks []Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return GetPriority(tasks[i]) > GetPriority(tasks[j])
+	})
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagpush
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uber/kraken/build-index/tagclient"
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/origin/blobclient"
+
+	"github.com/uber-go/tally"
+)
+
+// Executor executes tag push tasks.
+type Executor struct {
+	stats         tally.Scope
+	cads          *store.CADownloadStore
+	originCluster blobclient.ClusterClient
+	tags          tagclient.Client
+	pins          *Pins
+}
+
+// NewExecutor creates a new Executor.
+func NewExecutor(
+	stats tally.Scope,
+	cads *store.CADownloadStore,
+	originCluster blobclient.ClusterClient,
+	tags tagclient.Client,
+	pins *Pins) *Executor {
+
+	stats = stats.Tagged(map[string]string{
+		"module": "tagpushexecutor",
+	})
+
+	return &Executor{stats, cads, originCluster, tags, pins}
+}
+
+// Name returns the executor name.
+func (e *Executor) Name() string {
+	return "tagpush"
+}
+
+// Exec uploads a tag's dependencies from the local cache to the origin
+// cluster, then puts the tag to the build-index, which replicates it to any
+// remotes. Dependencies are pinned in the cache until the push succeeds, or
+// longer if other pending pushes reference them.
+func (e *Executor) Exec(r persistedretry.Task) error {
+	t := r.(*Task)
+	start := time.Now()
+
+	for _, d := range t.Dependencies {
+		if err := e.upload(t.Namespace, d); err != nil {
+			return fmt.Errorf("upload %s: %s", d, err)
+		}
+	}
+	if err := e.tags.PutAndReplicate(context.Background(), t.Tag, t.Digest); err != nil {
+		return fmt.Errorf("put and replicate tag: %s", err)
+	}
+	e.pins.Release(t.Dependencies)
+
+	e.stats.Timer("push").Record(time.Since(start))
+	e.stats.Timer("lifetime").Record(time.Since(t.CreatedAt))
+
+	return nil
+}
+
+// upload uploads blob d to the origin cluster, unless the origin already has it.
+func (e *Executor) upload(namespace string, d core.Digest) error {
+	if _, err := e.originCluster.Stat(namespace, d); err == nil {
+		return nil
+	} else if err != blobclient.ErrBlobNotFound {
+		return fmt.Errorf("stat: %s", err)
+	}
+	f, err := e.cads.Cache().GetFileReader(d.Hex())
+	if err != nil {
+		return fmt.Errorf("get cache file: %s", err)
+	}
+	defer f.Close()
+
+	if err := e.originCluster.UploadBlob(namespace, d, f); err != nil {
+		return fmt.Errorf("upload blob: %s", err)
+	}
+	e.stats.Counter("blobs_uploaded").Inc(1)
+	return nil
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagpush
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/mocks/build-index/tagclient"
+	"github.com/uber/kraken/mocks/origin/blobclient"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/utils/mockutil"
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+type executorMocks struct {
+	ctrl          *gomock.Controller
+	cads          *store.CADownloadStore
+	pins          *Pins
+	originCluster *mockblobclient.MockClusterClient
+	tags          *mocktagclient.MockClient
+}
+
+func newExecutorMocks(t *testing.T) (*executorMocks, func()) {
+	var cleanup testutil.Cleanup
+	defer cleanup.Recover()
+
+	ctrl := gomock.NewController(t)
+	cleanup.Add(ctrl.Finish)
+
+	cads, c := store.CADownloadStoreFixture()
+	cleanup.Add(c)
+
+	return &executorMocks{
+		ctrl:          ctrl,
+		cads:          cads,
+		pins:          NewPins(cads, clock.New()),
+		originCluster: mockblobclient.NewMockClusterClient(ctrl),
+		tags:          mocktagclient.NewMockClient(ctrl),
+	}, cleanup.Run
+}
+
+func (m *executorMocks) new() *Executor {
+	return NewExecutor(tally.NoopScope, m.cads, m.originCluster, m.tags, m.pins)
+}
+
+func (m *executorMocks) setupBlob(t *testing.T, blob *core.BlobFixture) {
+	t.Helper()
+	require.NoError(t, store.RunDownload(m.cads, blob.Digest, blob.Content))
+	require.NoError(t, m.pins.PinUpload(blob.Digest))
+}
+
+func TestExec(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newExecutorMocks(t)
+	defer cleanup()
+
+	manifest := core.NewBlobFixture()
+	layer := core.NewBlobFixture()
+
+	mocks.setupBlob(t, manifest)
+	mocks.setupBlob(t, layer)
+
+	task := NewTask(
+		core.TagFixture(), core.NamespaceFixture(), manifest.Digest,
+		core.DigestList{layer.Digest, manifest.Digest}, 0)
+	require.NoError(mocks.pins.Acquire(task.Dependencies))
+
+	gomock.InOrder(
+		mocks.originCluster.EXPECT().Stat(task.Namespace, layer.Digest).Return(
+			core.NewBlobInfo(layer.Length()), nil),
+		mocks.originCluster.EXPECT().Stat(task.Namespace, manifest.Digest).Return(
+			nil, blobclient.ErrBlobNotFound),
+		mocks.originCluster.EXPECT().UploadBlob(
+			task.Namespace, manifest.Digest, mockutil.MatchReader(manifest.Content)).Return(nil),
+		mocks.tags.EXPECT().PutAndReplicate(gomock.Any(), task.Tag, manifest.Digest).Return(nil),
+	)
+
+	require.NoError(mocks.new().Exec(task))
+
+	// Pushed blobs are no longer pinned.
+	var pm metadata.Persist
+	for _, d := range task.Dependencies {
+		require.NoError(mocks.cads.Cache().GetMetadata(d.Hex(), &pm))
+		require.False(pm.Value)
+	}
+}
+
+func TestExecUploadFailure(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newExecutorMocks(t)
+	defer cleanup()
+
+	blob := core.NewBlobFixture()
+
+	mocks.setupBlob(t, blob)
+
+	task := NewTask(
+		core.TagFixture(), core.NamespaceFixture(), blob.Digest, core.DigestList{blob.Digest}, 0)
+	require.NoError(mocks.pins.Acquire(task.Dependencies))
+
+	mocks.originCluster.EXPECT().Stat(task.Namespace, blob.Digest).Return(nil, blobclient.ErrBlobNotFound)
+	mocks.originCluster.EXPECT().UploadBlob(
+		task.Namespace, blob.Digest, mockutil.MatchReader(blob.Content)).Return(errors.New("some error"))
+
+	require.Error(mocks.new().Exec(task))
+
+	// Blob is still pinned until the task succeeds.
+	var pm metadata.Persist
+	require.NoError(mocks.cads.Cache().GetMetadata(blob.Digest.Hex(), &pm))
+	require.True(pm.Value)
+}
+
+func TestExecPutTagFailure(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newExecutorMocks(t)
+	defer cleanup()
+
+	blob := core.NewBlobFixture()
+
+	mocks.setupBlob(t, blob)
+
+	task := NewTask(
+		core.TagFixture(), core.NamespaceFixture(), blob.Digest, core.DigestList{blob.Digest}, 0)
+
+	mocks.originCluster.EXPECT().Stat(task.Namespace, blob.Digest).Return(core.NewBlobInfo(blob.Length()), nil)
+	mocks.tags.EXPECT().PutAndReplicate(
+		gomock.Any(), task.Tag, blob.Digest).Return(errors.New("some error"))
+
+	require.Error(mocks.new().Exec(task))
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagpush
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+)
+
+// Pins pins blobs pushed to an agent in the cache until they have been pushed
+// to the origins. Pins are reference counted by pending tag pushes, such that
+// blobs shared by several pushes stay pinned until the last push completes.
+// Blobs which are uploaded but never referenced by a tag push are unpinned
+// once their upload TTL expires.
+type Pins struct {
+	mu   sync.Mutex
+	cads *store.CADownloadStore
+	clk  clock.Clock
+}
+
+// NewPins creates a new Pins.
+func NewPins(cads *store.CADownloadStore, clk clock.Clock) *Pins {
+	return &Pins{cads: cads, clk: clk}
+}
+
+// PinUpload pins uploaded blob d, without referencing it from a tag push.
+func (p *Pins) PinUpload(d core.Digest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.cads.Cache().GetOrSetMetadata(d.Hex(), metadata.NewPushRefs(0)); err != nil {
+		return fmt.Errorf("get or set push refs: %s", err)
+	}
+	if _, err := p.cads.Cache().SetMetadata(d.Hex(), metadata.NewPersist(true)); err != nil {
+		return fmt.Errorf("set persist: %s", err)
+	}
+	return nil
+}
+
+// Acquire references blobs ds from a tag push, pinning them until Release.
+func (p *Pins) Acquire(ds core.DigestList) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, d := range ds {
+		if err := p.add(d, 1, false); err != nil {
+			return fmt.Errorf("pin %s: %s", d, err)
+		}
+	}
+	return nil
+}
+
+// Release drops the references to blobs ds of a completed tag push. Blobs are
+// unpinned once no pending tag push references them.
+func (p *Pins) Release(ds core.DigestList) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, d := range ds {
+		if err := p.add(d, -1, true); err != nil {
+			log.With("blob", d).Errorf("Error unpinning pushed blob: %s", err)
+		}
+	}
+}
+
+// Cancel drops the references to blobs ds of a tag push which was never
+// started. Blobs which are no longer referenced stay pinned as uploads.
+func (p *Pins) Cancel(ds core.DigestList) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, d := range ds {
+		if err := p.add(d, -1, false); err != nil {
+			log.With("blob", d).Errorf("Error dropping push reference: %s", err)
+		}
+	}
+}
+
+func (p *Pins) add(d core.Digest, delta int, unpinUnreferenced bool) error {
+	var refs metadata.PushRefs
+	if err := p.cads.Cache().GetMetadata(d.Hex(), &refs); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("get push refs: %s", err)
+	}
+	refs.Count += delta
+	if refs.Count <= 0 {
+		if unpinUnreferenced {
+			return p.unpin(d)
+		}
+		refs.Count = 0
+	}
+	if _, err := p.cads.Cache().SetMetadata(d.Hex(), &refs); err != nil {
+		return fmt.Errorf("set push refs: %s", err)
+	}
+	if _, err := p.cads.Cache().SetMetadata(d.Hex(), metadata.NewPersist(true)); err != nil {
+		return fmt.Errorf("set persist: %s", err)
+	}
+	return nil
+}
+
+func (p *Pins) unpin(d core.Digest) error {
+	err := p.cads.Cache().DeleteMetadata(d.Hex(), &metadata.PushRefs{})
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete push refs: %s", err)
+	}
+	if _, err := p.cads.Cache().SetMetadata(d.Hex(), metadata.NewPersist(false)); err != nil {
+		return fmt.Errorf("set persist: %s", err)
+	}
+	return nil
+}
+
+// ExpireUploads unpins blobs which were uploaded more than ttl ago and are
+// not referenced by any tag push. Returns the number of blobs unpinned.
+func (p *Pins) ExpireUploads(ttl time.Duration) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names, err := p.cads.ListCacheFileNames()
+	if err != nil {
+		return 0, fmt.Errorf("list cache files: %s", err)
+	}
+	var n int
+	for _, name := range names {
+		d, err := core.NewDigestFromHex(name)
+		if err != nil {
+			continue
+		}
+		var refs metadata.PushRefs
+		if err := p.cads.Cache().GetMetadata(name, &refs); err != nil || refs.Count > 0 {
+			continue
+		}
+		info, err := p.cads.Cache().GetFileStat(name)
+		if err != nil || p.clk.Now().Sub(info.ModTime()) < ttl {
+			continue
+		}
+		if err := p.unpin(d); err != nil {
+			return n, fmt.Errorf("unpin %s: %s", name, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// ExpireUploadsEvery runs ExpireUploads every interval until stop is closed.
+func (p *Pins) ExpireUploadsEvery(ttl, interval time.Duration, stop <-chan struct{}) {
+	ticker := p.clk.Ticker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if n, err := p.ExpireUploads(ttl); err != nil {
+				log.Errorf("Error expiring uploaded blobs: %s", err)
+			} else if n > 0 {
+				log.Infof("Unpinned %d uploaded blobs which were never tagged", n)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
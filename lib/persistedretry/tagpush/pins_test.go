@@ -0,0 +1,118 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagpush
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func pinned(t *testing.T, cads *store.CADownloadStore, d core.Digest) bool {
+	var pm metadata.Persist
+	require.NoError(t, cads.Cache().GetMetadata(d.Hex(), &pm))
+	return pm.Value
+}
+
+func TestPinsSharedBlobStaysPinnedUntilLastRelease(t *testing.T) {
+	require := require.New(t)
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	pins := NewPins(cads, clock.New())
+
+	shared := core.NewBlobFixture()
+	require.NoError(store.RunDownload(cads, shared.Digest, shared.Content))
+	require.NoError(pins.PinUpload(shared.Digest))
+
+	deps := core.DigestList{shared.Digest}
+	require.NoError(pins.Acquire(deps))
+	require.NoError(pins.Acquire(deps))
+
+	pins.Release(deps)
+	require.True(pinned(t, cads, shared.Digest))
+
+	pins.Release(deps)
+	require.False(pinned(t, cads, shared.Digest))
+
+	var refs metadata.PushRefs
+	err := cads.Cache().GetMetadata(shared.Digest.Hex(), &refs)
+	require.True(os.IsNotExist(err))
+}
+
+func TestPinsCancelKeepsUploadPin(t *testing.T) {
+	require := require.New(t)
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	pins := NewPins(cads, clock.New())
+
+	blob := core.NewBlobFixture()
+	require.NoError(store.RunDownload(cads, blob.Digest, blob.Content))
+	require.NoError(pins.PinUpload(blob.Digest))
+
+	deps := core.DigestList{blob.Digest}
+	require.NoError(pins.Acquire(deps))
+	pins.Cancel(deps)
+
+	require.True(pinned(t, cads, blob.Digest))
+}
+
+func TestPinsExpireUploads(t *testing.T) {
+	require := require.New(t)
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+	pins := NewPins(cads, clk)
+
+	untagged := core.NewBlobFixture()
+	tagged := core.NewBlobFixture()
+	manual := core.NewBlobFixture()
+	for _, blob := range []*core.BlobFixture{untagged, tagged, manual} {
+		require.NoError(store.RunDownload(cads, blob.Digest, blob.Content))
+	}
+	require.NoError(pins.PinUpload(untagged.Digest))
+	require.NoError(pins.PinUpload(tagged.Digest))
+	require.NoError(pins.Acquire(core.DigestList{tagged.Digest}))
+
+	// Pins which were not made by pushes are never expired.
+	_, err := cads.Cache().SetMetadata(manual.Digest.Hex(), metadata.NewPersist(true))
+	require.NoError(err)
+
+	n, err := pins.ExpireUploads(time.Hour)
+	require.NoError(err)
+	require.Equal(0, n)
+
+	clk.Add(2 * time.Hour)
+
+	n, err = pins.ExpireUploads(time.Hour)
+	require.NoError(err)
+	require.Equal(1, n)
+
+	require.False(pinned(t, cads, untagged.Digest))
+	require.True(pinned(t, cads, tagged.Digest))
+	require.True(pinned(t, cads, manual.Digest))
+}
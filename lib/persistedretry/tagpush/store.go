@@ -0,0 +1,222 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagpush
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/uber/kraken/lib/persistedretry"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Store stores tags to be pushed asynchronously.
+type Store struct {
+	db *sqlx.DB
+}
+
+// NewStore creates a new Store.
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db}
+}
+
+// GetPending returns all pending tasks.
+func (s *Store) GetPending() ([]persistedretry.Task, error) {
+	return s.selectStatus("pending")
+}
+
+// GetFailed returns all failed tasks.
+func (s *Store) GetFailed() ([]persistedretry.Task, error) {
+	return s.selectStatus("failed")
+}
+
+// GetDead returns all dead tasks.
+func (s *Store) GetDead() ([]persistedretry.Task, error) {
+	return s.selectStatus("dead")
+}
+
+// AddPending adds r as pending.
+func (s *Store) AddPending(r persistedretry.Task) error {
+	return s.addWithStatus(r, "pending")
+}
+
+// AddFailed adds r as failed.
+func (s *Store) AddFailed(r persistedretry.Task) error {
+	return s.addWithStatus(r, "failed")
+}
+
+// MarkPending marks r as pending.
+func (s *Store) MarkPending(r persistedretry.Task) error {
+	return s.markStatus(r, "pending")
+}
+
+// MarkFailed marks r as failed, recording err as the last error of r.
+func (s *Store) MarkFailed(r persistedretry.Task, err error) error {
+	t := r.(*Task)
+	u := *t
+	u.LastError = persistedretry.ErrorString(err)
+	res, err := s.db.NamedExec(`
+		UPDATE push_tag_task
+		SET last_attempt = CURRENT_TIMESTAMP,
+			failures = failures + 1,
+			last_error = :last_error,
+			status = "failed"
+		WHERE tag=:tag
+	`, &u)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		panic("driver does not support RowsAffected")
+	} else if n == 0 {
+		return persistedretry.ErrTaskNotFound
+	}
+	t.Failures++
+	t.LastAttempt = time.Now()
+	t.LastError = u.LastError
+	return nil
+}
+
+// MarkDead marks r as dead.
+func (s *Store) MarkDead(r persistedretry.Task) error {
+	return s.markStatus(r, "dead")
+}
+
+// Requeue marks dead r as pending and resets its failure count.
+func (s *Store) Requeue(r persistedretry.Task) error {
+	t := r.(*Task)
+	res, err := s.db.NamedExec(`
+		UPDATE push_tag_task
+		SET failures = 0,
+			last_error = "",
+			status = "pending"
+		WHERE tag=:tag
+	`, t)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		panic("driver does not support RowsAffected")
+	} else if n == 0 {
+		return persistedretry.ErrTaskNotFound
+	}
+	t.Failures = 0
+	t.LastError = ""
+	return nil
+}
+
+// Remove removes r.
+func (s *Store) Remove(r persistedretry.Task) error {
+	_, err := s.db.NamedExec(`
+		DELETE FROM push_tag_task
+		WHERE tag=:tag
+	`, r.(*Task))
+	return err
+}
+
+// RemoveBatch removes tasks in a single transaction.
+func (s *Store) RemoveBatch(tasks []persistedretry.Task) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin: %s", err)
+	}
+	for _, r := range tasks {
+		if _, err := tx.NamedExec(`
+			DELETE FROM push_tag_task
+			WHERE tag=:tag
+		`, r.(*Task)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Find is not supported.
+func (s *Store) Find(query interface{}) ([]persistedretry.Task, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *Store) markStatus(r persistedretry.Task, status string) error {
+	res, err := s.db.NamedExec(fmt.Sprintf(`
+		UPDATE push_tag_task
+		SET status = %q
+		WHERE tag=:tag
+	`, status), r.(*Task))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		panic("driver does not support RowsAffected")
+	} else if n == 0 {
+		return persistedretry.ErrTaskNotFound
+	}
+	return nil
+}
+
+func (s *Store) addWithStatus(r persistedretry.Task, status string) error {
+	query := fmt.Sprintf(`
+		INSERT INTO push_tag_task (
+			tag,
+			namespace,
+			digest,
+			dependencies,
+			last_attempt,
+			failures,
+			delay,
+			priority,
+			last_error,
+			status
+		) VALUES (
+			:tag,
+			:namespace,
+			:digest,
+			:dependencies,
+			:last_attempt,
+			:failures,
+			:delay,
+			:priority,
+			:last_error,
+			%q
+		)
+	`, status)
+	_, err := s.db.NamedExec(query, r.(*Task))
+	if se, ok := err.(sqlite3.Error); ok {
+		if se.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			return persistedretry.ErrTaskExists
+		}
+	}
+	return err
+}
+
+func (s *Store) selectStatus(status string) ([]persistedretry.Task, error) {
+	var tasks []*Task
+	err := s.db.Select(&tasks, `
+		SELECT tag, namespace, digest, dependencies, created_at, last_attempt, failures, last_error, delay, priority
+		FROM push_tag_task
+		WHERE status=?
+		ORDER BY priority DESC, rowid
+	`, status)
+	if err != nil {
+		return nil, err
+	}
+	var result []persistedretry.Task
+	for _, t := range tasks {
+		result = append(result, t)
+	}
+	return result, nil
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagpush_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/kraken/lib/persistedretry"
+	. "github.com/uber/kraken/lib/persistedretry/tagpush"
+	"github.com/uber/kraken/localdb"
+)
+
+func newTestStore() (*Store, func()) {
+	db, cleanup := localdb.Fixture()
+	return NewStore(db), cleanup
+}
+
+func checkTasks(t *testing.T, expected []*Task, result []persistedretry.Task) {
+	t.Helper()
+
+	require.Equal(t, len(expected), len(result))
+
+	for i := range expected {
+		expectedCopy := *expected[i]
+		resultCopy := *(result[i].(*Task))
+
+		require.InDelta(t, expectedCopy.CreatedAt.Unix(), resultCopy.CreatedAt.Unix(), 1)
+		expectedCopy.CreatedAt = time.Time{}
+		resultCopy.CreatedAt = time.Time{}
+
+		require.InDelta(t, expectedCopy.LastAttempt.Unix(), resultCopy.LastAttempt.Unix(), 1)
+		expectedCopy.LastAttempt = time.Time{}
+		resultCopy.LastAttempt = time.Time{}
+
+		require.Equal(t, expectedCopy, resultCopy)
+	}
+}
+
+func checkPending(t *testing.T, store *Store, expected ...*Task) {
+	t.Helper()
+
+	result, err := store.GetPending()
+	require.NoError(t, err)
+	checkTasks(t, expected, result)
+}
+
+func checkFailed(t *testing.T, store *Store, expected ...*Task) {
+	t.Helper()
+
+	result, err := store.GetFailed()
+	require.NoError(t, err)
+	checkTasks(t, expected, result)
+}
+
+func TestAddPending(t *testing.T) {
+	require := require.New(t)
+
+	store, cleanup := newTestStore()
+	defer cleanup()
+
+	task := TaskFixture()
+
+	require.NoError(store.AddPending(task))
+	checkPending(t, store, task)
+
+	require.Equal(persistedretry.ErrTaskExists, store.AddPending(task))
+}
+
+func TestAddFailed(t *testing.T) {
+	require := require.New(t)
+
+	store, cleanup := newTestStore()
+	defer cleanup()
+
+	task := TaskFixture()
+
+	require.NoError(store.AddFailed(task))
+	checkFailed(t, store, task)
+
+	require.Equal(persistedretry.ErrTaskExists, store.AddFailed(task))
+}
+
+func TestStateTransitions(t *testing.T) {
+	require := require.New(t)
+
+	store, cleanup := newTestStore()
+	defer cleanup()
+
+	task := TaskFixture()
+
+	require.NoError(store.AddPending(task))
+	checkPending(t, store, task)
+	checkFailed(t, store)
+
+	require.NoError(store.MarkFailed(task, errors.New("some error")))
+	require.Equal("some error", task.LastError)
+	checkPending(t, store)
+	checkFailed(t, store, task)
+
+	require.NoError(store.MarkPending(task))
+	checkPending(t, store, task)
+	checkFailed(t, store)
+
+	require.NoError(store.MarkDead(task))
+	checkPending(t, store)
+
+	dead, err := store.GetDead()
+	require.NoError(err)
+	checkTasks(t, []*Task{task}, dead)
+}
+
+func TestMarkTaskNotFound(t *testing.T) {
+	require := require.New(t)
+
+	store, cleanup := newTestStore()
+	defer cleanup()
+
+	task := TaskFixture()
+
+	require.Equal(persistedretry.ErrTaskNotFound, store.MarkPending(task))
+	require.Equal(persistedretry.ErrTaskNotFound, store.MarkFailed(task, errors.New("some error")))
+	require.Equal(persistedretry.ErrTaskNotFound, store.MarkDead(task))
+}
+
+func TestRemoveBatch(t *testing.T) {
+	require := require.New(t)
+
+	store, cleanup := newTestStore()
+	defer cleanup()
+
+	task1 := TaskFixture()
+	task2 := TaskFixture()
+	task3 := TaskFixture()
+
+	for _, task := range []*Task{task1, task2, task3} {
+		require.NoError(store.AddPending(task))
+	}
+	require.NoError(store.RemoveBatch([]persistedretry.Task{task1, task3}))
+	checkPending(t, store, task2)
+
+	require.NoError(store.Remove(task2))
+	checkPending(t, store)
+}
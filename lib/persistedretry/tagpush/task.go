@@ -0,0 +1,95 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagpush
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/persistedretry"
+)
+
+// Task contains information to push a tag built on an agent, and its
+// dependencies, to the local origin cluster and build-index.
+type Task struct {
+	Tag          string          `db:"tag"`
+	Namespace    string          `db:"namespace"`
+	Digest       core.Digest     `db:"digest"`
+	Dependencies core.DigestList `db:"dependencies"`
+	CreatedAt    time.Time       `db:"created_at"`
+	LastAttempt  time.Time       `db:"last_attempt"`
+	Failures     int             `db:"failures"`
+	Delay        time.Duration   `db:"delay"`
+	Priority     int             `db:"priority"`
+	LastError    string          `db:"last_error"`
+}
+
+// NewTask creates a new Task.
+func NewTask(
+	tag string,
+	namespace string,
+	d core.Digest,
+	dependencies core.DigestList,
+	delay time.Duration) *Task {
+
+	return &Task{
+		Tag:          tag,
+		Namespace:    namespace,
+		Digest:       d,
+		Dependencies: dependencies,
+		CreatedAt:    time.Now(),
+		Delay:        delay,
+		Priority:     persistedretry.PriorityDefault,
+	}
+}
+
+func (t *Task) String() string {
+	return fmt.Sprintf("tagpush.Task(tag=%s, digest=%s)", t.Tag, t.Digest)
+}
+
+// GetLastAttempt returns when t was last attempted.
+func (t *Task) GetLastAttempt() time.Time {
+	return t.LastAttempt
+}
+
+// GetFailures returns the number of times t has failed.
+func (t *Task) GetFailures() int {
+	return t.Failures
+}
+
+// GetLastError returns the error message of the last failure of t.
+func (t *Task) GetLastError() string {
+	return t.LastError
+}
+
+// GetKey returns the key which uniquely identifies t.
+func (t *Task) GetKey() string {
+	return t.Tag
+}
+
+// GetPriority returns the execution priority of t.
+func (t *Task) GetPriority() int {
+	return t.Priority
+}
+
+// Ready returns whether t is ready to run.
+func (t *Task) Ready() bool {
+	return time.Since(t.CreatedAt) >= t.Delay
+}
+
+// Tags is unused.
+func (t *Task) Tags() map[string]string {
+	return nil
+}
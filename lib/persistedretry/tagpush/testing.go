@@ -0,0 +1,47 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tagpush
+
+import (
+	"reflect"
+	"time"
+)
+
+// TaskMatcher is a gomock Matcher which matches two tasks.
+type TaskMatcher struct {
+	task Task
+}
+
+// MatchTask returns a new TaskMatcher
+func MatchTask(task *Task) *TaskMatcher {
+	return &TaskMatcher{*task}
+}
+
+// Matches compares two tasks. It ignores checking for time.
+func (m *TaskMatcher) Matches(x interface{}) bool {
+	expected := m.task
+	result := *(x.(*Task))
+
+	expected.CreatedAt = time.Time{}
+	result.CreatedAt = time.Time{}
+	expected.LastAttempt = time.Time{}
+	result.LastAttempt = time.Time{}
+
+	return reflect.DeepEqual(expected, result)
+}
+
+// String returns the name of the matcher.
+func (m *TaskMatcher) String() string {
+	return "TaskMatcher"
+}
@@ -14,6 +14,7 @@
 package tagreplication
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -56,13 +57,13 @@ func (e *Executor) Exec(r persistedretry.Task) error {
 	start := time.Now()
 	remoteTagClient := e.tagClientProvider.Provide(t.Destination)
 
-	if ok, err := remoteTagClient.Has(t.Tag); err == nil && ok {
+	if ok, err := remoteTagClient.Has(context.Background(), t.Tag); err == nil && ok {
 		// Remote index already has the tag, therefore dependencies have already
 		// been replicated, and the remote has also replicated the tag. No-op.
 		return nil
 	}
 
-	remoteOrigin, err := remoteTagClient.Origin()
+	remoteOrigin, err := remoteTagClient.Origin(context.Background())
 	if err != nil {
 		return fmt.Errorf("lookup remote origin cluster: %s", err)
 	}
@@ -75,7 +76,7 @@ func (e *Executor) Exec(r persistedretry.Task) error {
 	// Put tag and triggers replication on the remote client.
 	// Replication will call Exec n^2 times but some will return early
 	// if remote has the tag already.
-	if err := remoteTagClient.PutAndReplicate(t.Tag, t.Digest); err != nil {
+	if err := remoteTagClient.PutAndReplicate(context.Background(), t.Tag, t.Digest); err != nil {
 		return fmt.Errorf("put and replicate tag: %s", err)
 	}
 
@@ -63,15 +63,15 @@ func TestExecutor(t *testing.T) {
 
 	gomock.InOrder(
 		mocks.tagClientProvider.EXPECT().Provide(task.Destination).Return(tagClient),
-		tagClient.EXPECT().Has(task.Tag).Return(false, nil),
-		tagClient.EXPECT().Origin().Return(_testRemoteOrigin, nil),
+		tagClient.EXPECT().Has(gomock.Any(), task.Tag).Return(false, nil),
+		tagClient.EXPECT().Origin(gomock.Any()).Return(_testRemoteOrigin, nil),
 		mocks.originCluster.EXPECT().ReplicateToRemote(
 			task.Tag, task.Dependencies[0], _testRemoteOrigin).Return(nil),
 		mocks.originCluster.EXPECT().ReplicateToRemote(
 			task.Tag, task.Dependencies[1], _testRemoteOrigin).Return(nil),
 		mocks.originCluster.EXPECT().ReplicateToRemote(
 			task.Tag, task.Dependencies[2], _testRemoteOrigin).Return(nil),
-		tagClient.EXPECT().PutAndReplicate(task.Tag, task.Digest).Return(nil),
+		tagClient.EXPECT().PutAndReplicate(gomock.Any(), task.Tag, task.Digest).Return(nil),
 	)
 
 	require.NoError(executor.Exec(task))
@@ -89,7 +89,7 @@ func TestExecutorNoopsWhenTagAlreadyReplicated(t *testing.T) {
 
 	gomock.InOrder(
 		mocks.tagClientProvider.EXPECT().Provide(task.Destination).Return(tagClient),
-		tagClient.EXPECT().Has(task.Tag).Return(true, nil),
+		tagClient.EXPECT().Has(gomock.Any(), task.Tag).Return(true, nil),
 	)
 
 	require.NoError(executor.Exec(task))
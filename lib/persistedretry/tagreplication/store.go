@@ -48,6 +48,11 @@ func (s *Store) GetFailed() ([]persistedretry.Task, error) {
 	return s.selectStatus("failed")
 }
 
+// GetDead returns all dead tasks.
+func (s *Store) GetDead() ([]persistedretry.Task, error) {
+	return s.selectStatus("dead")
+}
+
 // AddPending adds r as pending.
 func (s *Store) AddPending(r persistedretry.Task) error {
 	return s.addWithStatus(r, "pending")
@@ -76,16 +81,19 @@ func (s *Store) MarkPending(r persistedretry.Task) error {
 	return nil
 }
 
-// MarkFailed marks r as failed.
-func (s *Store) MarkFailed(r persistedretry.Task) error {
+// MarkFailed marks r as failed, recording err as the last error of r.
+func (s *Store) MarkFailed(r persistedretry.Task, err error) error {
 	t := r.(*Task)
+	u := *t
+	u.LastError = persistedretry.ErrorString(err)
 	res, err := s.db.NamedExec(`
 		UPDATE replicate_tag_task
 		SET last_attempt = CURRENT_TIMESTAMP,
 			failures = failures + 1,
+			last_error = :last_error,
 			status = "failed"
 		WHERE tag=:tag AND destination=:destination
-	`, t)
+	`, &u)
 	if err != nil {
 		return err
 	}
@@ -96,6 +104,48 @@ func (s *Store) MarkFailed(r persistedretry.Task) error {
 	}
 	t.Failures++
 	t.LastAttempt = time.Now()
+	t.LastError = u.LastError
+	return nil
+}
+
+// MarkDead marks r as dead.
+func (s *Store) MarkDead(r persistedretry.Task) error {
+	res, err := s.db.NamedExec(`
+		UPDATE replicate_tag_task
+		SET status = "dead"
+		WHERE tag=:tag AND destination=:destination
+	`, r.(*Task))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		panic("driver does not support RowsAffected")
+	} else if n == 0 {
+		return persistedretry.ErrTaskNotFound
+	}
+	return nil
+}
+
+// Requeue marks dead r as pending and resets its failure count.
+func (s *Store) Requeue(r persistedretry.Task) error {
+	t := r.(*Task)
+	res, err := s.db.NamedExec(`
+		UPDATE replicate_tag_task
+		SET failures = 0,
+			last_error = "",
+			status = "pending"
+		WHERE tag=:tag AND destination=:destination
+	`, t)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		panic("driver does not support RowsAffected")
+	} else if n == 0 {
+		return persistedretry.ErrTaskNotFound
+	}
+	t.Failures = 0
+	t.LastError = ""
 	return nil
 }
 
@@ -104,6 +154,23 @@ func (s *Store) Remove(r persistedretry.Task) error {
 	return s.delete(r)
 }
 
+// RemoveBatch removes tasks in a single transaction.
+func (s *Store) RemoveBatch(tasks []persistedretry.Task) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin: %s", err)
+	}
+	for _, r := range tasks {
+		if _, err := tx.NamedExec(`
+			DELETE FROM replicate_tag_task
+			WHERE tag=:tag AND destination=:destination`, r.(*Task)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 // Find is not supported.
 func (s *Store) Find(query interface{}) ([]persistedretry.Task, error) {
 	return nil, errors.New("not supported")
@@ -119,6 +186,9 @@ func (s *Store) addWithStatus(r persistedretry.Task, status string) error {
 			last_attempt,
 			failures,
 			delay,
+			priority,
+			last_error,
+			ttl,
 			status
 		) VALUES (
 			:tag,
@@ -128,6 +198,9 @@ func (s *Store) addWithStatus(r persistedretry.Task, status string) error {
 			:last_attempt,
 			:failures,
 			:delay,
+			:priority,
+			:last_error,
+			:ttl,
 			%q
 		)
 	`, status)
@@ -143,9 +216,10 @@ func (s *Store) addWithStatus(r persistedretry.Task, status string) error {
 func (s *Store) selectStatus(status string) ([]persistedretry.Task, error) {
 	var tasks []*Task
 	err := s.db.Select(&tasks, `
-		SELECT tag, digest, dependencies, destination, created_at, last_attempt, failures, delay
+		SELECT tag, digest, dependencies, destination, created_at, last_attempt, failures, last_error, delay, priority, ttl
 		FROM replicate_tag_task
-		WHERE status=?`, status)
+		WHERE status=?
+		ORDER BY priority DESC, rowid`, status)
 	if err != nil {
 		return nil, err
 	}
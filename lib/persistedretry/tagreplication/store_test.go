@@ -14,6 +14,7 @@
 package tagreplication_test
 
 import (
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -222,7 +223,7 @@ func TestStateTransitions(t *testing.T) {
 	checkPending(t, store, task)
 	checkFailed(t, store)
 
-	require.NoError(store.MarkFailed(task))
+	require.NoError(store.MarkFailed(task, errors.New("some error")))
 	checkPending(t, store)
 	checkFailed(t, store, task)
 
@@ -242,7 +243,8 @@ func TestMarkTaskNotFound(t *testing.T) {
 	task := TaskFixture()
 
 	require.Equal(persistedretry.ErrTaskNotFound, store.MarkPending(task))
-	require.Equal(persistedretry.ErrTaskNotFound, store.MarkFailed(task))
+	require.Equal(persistedretry.ErrTaskNotFound, store.MarkFailed(task, errors.New("some error")))
+	require.Equal(persistedretry.ErrTaskNotFound, store.MarkDead(task))
 }
 
 func TestRemove(t *testing.T) {
@@ -288,3 +290,143 @@ func TestDelay(t *testing.T) {
 	require.False(pending[0].Ready())
 	require.True(pending[1].Ready())
 }
+
+func TestTTL(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newStoreMocks(t)
+	defer cleanup()
+
+	store := mocks.new()
+
+	task1 := TaskFixture()
+	task1.TTL = time.Minute
+
+	task2 := TaskFixture()
+	task2.TTL = 0
+
+	require.NoError(store.AddPending(task1))
+	require.NoError(store.AddPending(task2))
+
+	pending, err := store.GetPending()
+	require.NoError(err)
+	checkTasks(t, []*Task{task1, task2}, pending)
+
+	now := time.Now()
+	require.False(persistedretry.Expired(pending[0], now))
+	require.True(persistedretry.Expired(pending[0], now.Add(2*time.Minute)))
+	require.False(persistedretry.Expired(pending[1], now.Add(time.Hour)))
+}
+
+func TestGetPendingOrdersByPriority(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newStoreMocks(t)
+	defer cleanup()
+
+	store := mocks.new()
+
+	low := TaskFixture()
+	low.Priority = persistedretry.PriorityLow
+	normal := TaskFixture()
+	high := TaskFixture()
+	high.Priority = persistedretry.PriorityHigh
+
+	for _, task := range []*Task{low, normal, high} {
+		require.NoError(store.AddPending(task))
+	}
+
+	checkPending(t, store, high, normal, low)
+}
+
+func TestRemoveBatch(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newStoreMocks(t)
+	defer cleanup()
+
+	store := mocks.new()
+
+	tasks := []*Task{TaskFixture(), TaskFixture(), TaskFixture()}
+	for _, task := range tasks {
+		require.NoError(store.AddPending(task))
+	}
+
+	require.NoError(store.RemoveBatch([]persistedretry.Task{tasks[0], tasks[2]}))
+
+	checkPending(t, store, tasks[1])
+}
+
+func TestMarkFailedRecordsLastError(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newStoreMocks(t)
+	defer cleanup()
+
+	store := mocks.new()
+
+	task := TaskFixture()
+
+	require.NoError(store.AddPending(task))
+
+	require.NoError(store.MarkFailed(task, errors.New("first error")))
+	require.NoError(store.MarkPending(task))
+	require.NoError(store.MarkFailed(task, errors.New("second error")))
+
+	require.Equal(2, task.GetFailures())
+	require.Equal("second error", task.GetLastError())
+
+	checkFailed(t, store, task)
+}
+
+func TestMarkDead(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newStoreMocks(t)
+	defer cleanup()
+
+	store := mocks.new()
+
+	task := TaskFixture()
+
+	require.NoError(store.AddPending(task))
+	require.NoError(store.MarkDead(task))
+	checkPending(t, store)
+	checkFailed(t, store)
+
+	dead, err := store.GetDead()
+	require.NoError(err)
+	checkTasks(t, []*Task{task}, dead)
+
+	require.NoError(store.MarkPending(task))
+	checkPending(t, store, task)
+
+	dead, err = store.GetDead()
+	require.NoError(err)
+	require.Empty(dead)
+}
+
+func TestRequeueResetsFailures(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newStoreMocks(t)
+	defer cleanup()
+
+	store := mocks.new()
+
+	task := TaskFixture()
+
+	require.NoError(store.AddPending(task))
+	require.NoError(store.MarkFailed(task, errors.New("some error")))
+	require.NoError(store.MarkDead(task))
+
+	require.NoError(store.Requeue(task))
+	require.Equal(0, task.GetFailures())
+	require.Equal("", task.GetLastError())
+
+	checkPending(t, store, task)
+
+	dead, err := store.GetDead()
+	require.NoError(err)
+	require.Empty(dead)
+}
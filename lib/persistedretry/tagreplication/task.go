@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/persistedretry"
 )
 
 // Task contains information to replicate a tag and its dependencies to a
@@ -31,6 +32,12 @@ type Task struct {
 	LastAttempt  time.Time       `db:"last_attempt"`
 	Failures     int             `db:"failures"`
 	Delay        time.Duration   `db:"delay"`
+	Priority     int             `db:"priority"`
+	LastError    string          `db:"last_error"`
+
+	// TTL is how long after creation t is dropped instead of retried. Zero
+	// means t never expires.
+	TTL time.Duration `db:"ttl"`
 }
 
 // NewTask creates a new Task.
@@ -48,6 +55,7 @@ func NewTask(
 		Destination:  destination,
 		CreatedAt:    time.Now(),
 		Delay:        delay,
+		Priority:     persistedretry.PriorityDefault,
 	}
 }
 
@@ -65,6 +73,29 @@ func (t *Task) GetFailures() int {
 	return t.Failures
 }
 
+// GetLastError returns the error message of the last failure of t.
+func (t *Task) GetLastError() string {
+	return t.LastError
+}
+
+// GetKey returns the key which uniquely identifies t.
+func (t *Task) GetKey() string {
+	return t.Tag + ":" + t.Destination
+}
+
+// GetPriority returns the execution priority of t.
+func (t *Task) GetPriority() int {
+	return t.Priority
+}
+
+// GetDeadline returns when t expires, or the zero time if t never expires.
+func (t *Task) GetDeadline() time.Time {
+	if t.TTL == 0 {
+		return time.Time{}
+	}
+	return t.CreatedAt.Add(t.TTL)
+}
+
 // Ready returns whether t is ready to run.
 func (t *Task) Ready() bool {
 	return time.Since(t.CreatedAt) >= t.Delay
@@ -44,6 +44,11 @@ func (s *Store) GetFailed() ([]persistedretry.Task, error) {
 	return s.selectStatus("failed")
 }
 
+// GetDead returns all dead tasks.
+func (s *Store) GetDead() ([]persistedretry.Task, error) {
+	return s.selectStatus("dead")
+}
+
 // AddPending adds r as pending.
 func (s *Store) AddPending(r persistedretry.Task) error {
 	return s.addWithStatus(r, "pending")
@@ -72,16 +77,19 @@ func (s *Store) MarkPending(r persistedretry.Task) error {
 	return nil
 }
 
-// MarkFailed marks r as failed.
-func (s *Store) MarkFailed(r persistedretry.Task) error {
+// MarkFailed marks r as failed, recording err as the last error of r.
+func (s *Store) MarkFailed(r persistedretry.Task, err error) error {
 	t := r.(*Task)
+	u := *t
+	u.LastError = persistedretry.ErrorString(err)
 	res, err := s.db.NamedExec(`
 		UPDATE writeback_task
 		SET last_attempt = CURRENT_TIMESTAMP,
 			failures = failures + 1,
+			last_error = :last_error,
 			status = "failed"
 		WHERE namespace=:namespace AND name=:name
-	`, t)
+	`, &u)
 	if err != nil {
 		return err
 	}
@@ -92,6 +100,48 @@ func (s *Store) MarkFailed(r persistedretry.Task) error {
 	}
 	t.Failures++
 	t.LastAttempt = time.Now()
+	t.LastError = u.LastError
+	return nil
+}
+
+// MarkDead marks r as dead.
+func (s *Store) MarkDead(r persistedretry.Task) error {
+	res, err := s.db.NamedExec(`
+		UPDATE writeback_task
+		SET status = "dead"
+		WHERE namespace=:namespace AND name=:name
+	`, r.(*Task))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		panic("driver does not support RowsAffected")
+	} else if n == 0 {
+		return persistedretry.ErrTaskNotFound
+	}
+	return nil
+}
+
+// Requeue marks dead r as pending and resets its failure count.
+func (s *Store) Requeue(r persistedretry.Task) error {
+	t := r.(*Task)
+	res, err := s.db.NamedExec(`
+		UPDATE writeback_task
+		SET failures = 0,
+			last_error = "",
+			status = "pending"
+		WHERE namespace=:namespace AND name=:name
+	`, t)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		panic("driver does not support RowsAffected")
+	} else if n == 0 {
+		return persistedretry.ErrTaskNotFound
+	}
+	t.Failures = 0
+	t.LastError = ""
 	return nil
 }
 
@@ -104,6 +154,24 @@ func (s *Store) Remove(r persistedretry.Task) error {
 	return err
 }
 
+// RemoveBatch removes tasks in a single transaction.
+func (s *Store) RemoveBatch(tasks []persistedretry.Task) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin: %s", err)
+	}
+	for _, r := range tasks {
+		if _, err := tx.NamedExec(`
+			DELETE FROM writeback_task
+			WHERE namespace=:namespace AND name=:name
+		`, r.(*Task)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 // Find finds tasks matching query.
 func (s *Store) Find(query interface{}) ([]persistedretry.Task, error) {
 	var tasks []*Task
@@ -111,7 +179,7 @@ func (s *Store) Find(query interface{}) ([]persistedretry.Task, error) {
 	switch q := query.(type) {
 	case *NameQuery:
 		err = s.db.Select(&tasks, `
-			SELECT namespace, name, created_at, last_attempt, failures, delay
+			SELECT namespace, name, created_at, last_attempt, failures, last_error, delay, priority
 			FROM writeback_task
 			WHERE name=?
 		`, q.name)
@@ -132,6 +200,8 @@ func (s *Store) addWithStatus(r persistedretry.Task, status string) error {
 			last_attempt,
 			failures,
 			delay,
+			priority,
+			last_error,
 			status
 		) VALUES (
 			:namespace,
@@ -139,6 +209,8 @@ func (s *Store) addWithStatus(r persistedretry.Task, status string) error {
 			:last_attempt,
 			:failures,
 			:delay,
+			:priority,
+			:last_error,
 			%q
 		)
 	`, status)
@@ -154,9 +226,10 @@ func (s *Store) addWithStatus(r persistedretry.Task, status string) error {
 func (s *Store) selectStatus(status string) ([]persistedretry.Task, error) {
 	var tasks []*Task
 	err := s.db.Select(&tasks, `
-		SELECT namespace, name, created_at, last_attempt, failures, delay
+		SELECT namespace, name, created_at, last_attempt, failures, last_error, delay, priority
 		FROM writeback_task
 		WHERE status=?
+		ORDER BY priority DESC, rowid
 	`, status)
 	if err != nil {
 		return nil, err
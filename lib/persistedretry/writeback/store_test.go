@@ -14,6 +14,7 @@
 package writeback
 
 import (
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -160,7 +161,7 @@ func TestStateTransitions(t *testing.T) {
 	checkPending(t, store, task)
 	checkFailed(t, store)
 
-	require.NoError(store.MarkFailed(task))
+	require.NoError(store.MarkFailed(task, errors.New("some error")))
 	checkPending(t, store)
 	checkFailed(t, store, task)
 
@@ -180,7 +181,8 @@ func TestMarkTaskNotFound(t *testing.T) {
 	task := TaskFixture()
 
 	require.Equal(persistedretry.ErrTaskNotFound, store.MarkPending(task))
-	require.Equal(persistedretry.ErrTaskNotFound, store.MarkFailed(task))
+	require.Equal(persistedretry.ErrTaskNotFound, store.MarkFailed(task, errors.New("some error")))
+	require.Equal(persistedretry.ErrTaskNotFound, store.MarkDead(task))
 }
 
 func TestRemove(t *testing.T) {
@@ -260,3 +262,116 @@ func TestFindEmpty(t *testing.T) {
 	require.NoError(err)
 	require.Empty(result)
 }
+
+func TestGetPendingOrdersByPriority(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store := NewStore(db)
+
+	low := TaskFixture()
+	low.Priority = persistedretry.PriorityLow
+	normal := TaskFixture()
+	high := TaskFixture()
+	high.Priority = persistedretry.PriorityHigh
+
+	for _, task := range []*Task{low, normal, high} {
+		require.NoError(store.AddPending(task))
+	}
+
+	checkPending(t, store, high, normal, low)
+}
+
+func TestRemoveBatch(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store := NewStore(db)
+
+	tasks := []*Task{TaskFixture(), TaskFixture(), TaskFixture()}
+	for _, task := range tasks {
+		require.NoError(store.AddPending(task))
+	}
+
+	require.NoError(store.RemoveBatch([]persistedretry.Task{tasks[0], tasks[2]}))
+
+	checkPending(t, store, tasks[1])
+}
+
+func TestMarkFailedRecordsLastError(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store := NewStore(db)
+
+	task := TaskFixture()
+
+	require.NoError(store.AddPending(task))
+
+	require.NoError(store.MarkFailed(task, errors.New("first error")))
+	require.NoError(store.MarkPending(task))
+	require.NoError(store.MarkFailed(task, errors.New("second error")))
+
+	require.Equal(2, task.GetFailures())
+	require.Equal("second error", task.GetLastError())
+
+	checkFailed(t, store, task)
+}
+
+func TestMarkDead(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store := NewStore(db)
+
+	task := TaskFixture()
+
+	require.NoError(store.AddPending(task))
+	require.NoError(store.MarkDead(task))
+	checkPending(t, store)
+	checkFailed(t, store)
+
+	dead, err := store.GetDead()
+	require.NoError(err)
+	checkTasks(t, []*Task{task}, dead)
+
+	require.NoError(store.MarkPending(task))
+	checkPending(t, store, task)
+
+	dead, err = store.GetDead()
+	require.NoError(err)
+	require.Empty(dead)
+}
+
+func TestRequeueResetsFailures(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store := NewStore(db)
+
+	task := TaskFixture()
+
+	require.NoError(store.AddPending(task))
+	require.NoError(store.MarkFailed(task, errors.New("some error")))
+	require.NoError(store.MarkDead(task))
+
+	require.NoError(store.Requeue(task))
+	require.Equal(0, task.GetFailures())
+	require.Equal("", task.GetLastError())
+
+	checkPending(t, store, task)
+
+	dead, err := store.GetDead()
+	require.NoError(err)
+	require.Empty(dead)
+}
@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/persistedretry"
 )
 
 // Task contains information to write back a blob to remote storage.
@@ -28,6 +29,8 @@ type Task struct {
 	LastAttempt time.Time     `db:"last_attempt"`
 	Failures    int           `db:"failures"`
 	Delay       time.Duration `db:"delay"`
+	Priority    int           `db:"priority"`
+	LastError   string        `db:"last_error"`
 
 	// Deprecated. Use name instead.
 	Digest core.Digest `db:"digest"`
@@ -40,6 +43,7 @@ func NewTask(namespace, name string, delay time.Duration) *Task {
 		Name:      name,
 		CreatedAt: time.Now(),
 		Delay:     delay,
+		Priority:  persistedretry.PriorityDefault,
 	}
 }
 
@@ -57,6 +61,21 @@ func (t *Task) GetFailures() int {
 	return t.Failures
 }
 
+// GetLastError returns the error message of the last failure of t.
+func (t *Task) GetLastError() string {
+	return t.LastError
+}
+
+// GetKey returns the key which uniquely identifies t.
+func (t *Task) GetKey() string {
+	return t.Namespace + ":" + t.Name
+}
+
+// GetPriority returns the execution priority of t.
+func (t *Task) GetPriority() int {
+	return t.Priority
+}
+
 // Ready returns whether t is ready to run.
 func (t *Task) Ready() bool {
 	return time.Since(t.CreatedAt) >= t.Delay
@@ -0,0 +1,92 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/uber/kraken/utils/configutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+// AWSSecretsManagerConfig defines a secret stored in AWS Secrets Manager.
+// Credentials are resolved from the default credential chain, e.g. the
+// instance role.
+type AWSSecretsManagerConfig struct {
+	Region string `yaml:"region"`
+
+	// SecretID is the name or ARN of the secret, whose string value contains
+	// the YAML secrets.
+	SecretID string `yaml:"secret_id"`
+}
+
+// SecretsManager defines the operations we use in the secrets manager api.
+// Useful for mocking.
+type SecretsManager interface {
+	GetSecretValue(
+		input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+type awsSecretsManagerProvider struct {
+	config AWSSecretsManagerConfig
+	api    SecretsManager
+}
+
+// AWSSecretsManagerOption allows setting optional AWS Secrets Manager
+// provider parameters.
+type AWSSecretsManagerOption func(*awsSecretsManagerProvider)
+
+// WithSecretsManager configures the provider with a custom SecretsManager
+// implementation.
+func WithSecretsManager(api SecretsManager) AWSSecretsManagerOption {
+	return func(p *awsSecretsManagerProvider) { p.api = api }
+}
+
+// NewAWSSecretsManagerProvider creates a Provider which loads secrets from
+// AWS Secrets Manager.
+func NewAWSSecretsManagerProvider(
+	config AWSSecretsManagerConfig, opts ...AWSSecretsManagerOption) (Provider, error) {
+
+	if config.Region == "" {
+		return nil, errors.New("invalid config: region required")
+	}
+	if config.SecretID == "" {
+		return nil, errors.New("invalid config: secret_id required")
+	}
+	p := &awsSecretsManagerProvider{config: config}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.api == nil {
+		p.api = secretsmanager.New(session.New(), aws.NewConfig().WithRegion(config.Region))
+	}
+	return p, nil
+}
+
+func (p *awsSecretsManagerProvider) Load(config interface{}) error {
+	out, err := p.api.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(p.config.SecretID),
+	})
+	if err != nil {
+		return fmt.Errorf("get secret value: %s", err)
+	}
+	if out.SecretString == nil {
+		return errors.New("secret has no string value")
+	}
+	return configutil.LoadData([]byte(*out.SecretString), config)
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secrets loads secret configuration values, e.g. backend credentials,
+// from a secrets provider at startup instead of the main configuration file.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/uber/kraken/utils/configutil"
+)
+
+// Provider names.
+const (
+	FileProvider              = "file"
+	VaultProvider             = "vault"
+	AWSSecretsManagerProvider = "aws_secrets_manager"
+)
+
+// Provider populates configuration with secret values. The secrets are
+// structured the same as the configuration they are loaded into, i.e. like a
+// secrets file.
+type Provider interface {
+	Load(config interface{}) error
+}
+
+// Config defines which provider secrets are loaded from.
+type Config struct {
+	// Provider is one of "file", "vault" or "aws_secrets_manager". If empty,
+	// no secrets are loaded.
+	Provider string `yaml:"provider"`
+
+	File              FileConfig              `yaml:"file"`
+	Vault             VaultConfig             `yaml:"vault"`
+	AWSSecretsManager AWSSecretsManagerConfig `yaml:"aws_secrets_manager"`
+}
+
+// Build creates the configured Provider. Returns nil if no provider is
+// configured.
+func (c Config) Build() (Provider, error) {
+	switch c.Provider {
+	case "":
+		return nil, nil
+	case FileProvider:
+		return NewFileProvider(c.File)
+	case VaultProvider:
+		return NewVaultProvider(c.Vault)
+	case AWSSecretsManagerProvider:
+		return NewAWSSecretsManagerProvider(c.AWSSecretsManager)
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q", c.Provider)
+	}
+}
+
+// FileConfig defines a YAML secrets file.
+type FileConfig struct {
+	Path string `yaml:"path"`
+}
+
+type fileProvider struct {
+	config FileConfig
+}
+
+// NewFileProvider creates a Provider which loads secrets from a YAML file.
+// Like any configuration file, the secrets file may extend other files.
+func NewFileProvider(config FileConfig) (Provider, error) {
+	if config.Path == "" {
+		return nil, errors.New("invalid config: path required")
+	}
+	return &fileProvider{config}, nil
+}
+
+func (p *fileProvider) Load(config interface{}) error {
+	return configutil.Load(p.config.Path, config)
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package secrets
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/uber/kraken/mocks/lib/secrets"
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/golang/mock/gomock"
+	"github.com/pressly/chi"
+	"github.com/stretchr/testify/require"
+)
+
+const _secrets = `
+auth:
+  username: foo
+  password: bar
+`
+
+type testConfig struct {
+	Addr string `yaml:"addr"`
+	Auth struct {
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+	} `yaml:"auth"`
+}
+
+func TestConfigBuildNoProvider(t *testing.T) {
+	require := require.New(t)
+
+	p, err := Config{}.Build()
+	require.NoError(err)
+	require.Nil(p)
+}
+
+func TestConfigBuildUnknownProvider(t *testing.T) {
+	_, err := Config{Provider: "foo"}.Build()
+	require.Error(t, err)
+}
+
+func TestFileProviderLoad(t *testing.T) {
+	require := require.New(t)
+
+	path, cleanup := testutil.TempFile([]byte(_secrets))
+	defer cleanup()
+
+	p, err := Config{Provider: FileProvider, File: FileConfig{Path: path}}.Build()
+	require.NoError(err)
+
+	config := testConfig{Addr: "localhost:80"}
+	require.NoError(p.Load(&config))
+	require.Equal("localhost:80", config.Addr)
+	require.Equal("foo", config.Auth.Username)
+	require.Equal("bar", config.Auth.Password)
+}
+
+func TestVaultProviderLoad(t *testing.T) {
+	tests := []struct {
+		version int
+		body    string
+	}{
+		{1, `{"data": {"secrets": %q}}`},
+		{2, `{"data": {"data": {"secrets": %q}, "metadata": {"version": 1}}}`},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("kv_version=%d", test.version), func(t *testing.T) {
+			require := require.New(t)
+
+			r := chi.NewRouter()
+			r.Get("/v1/secret/kraken", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Vault-Token") != "token" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				fmt.Fprintf(w, test.body, _secrets)
+			})
+			addr, stop := testutil.StartServer(r)
+			defer stop()
+
+			tokenFile, cleanup := testutil.TempFile([]byte("token\n"))
+			defer cleanup()
+
+			p, err := NewVaultProvider(VaultConfig{
+				Addr:      "http://" + addr,
+				Path:      "secret/kraken",
+				KVVersion: test.version,
+				TokenFile: tokenFile,
+			})
+			require.NoError(err)
+
+			var config testConfig
+			require.NoError(p.Load(&config))
+			require.Equal("foo", config.Auth.Username)
+			require.Equal("bar", config.Auth.Password)
+		})
+	}
+}
+
+func TestVaultProviderLoadMissingKey(t *testing.T) {
+	require := require.New(t)
+
+	r := chi.NewRouter()
+	r.Get("/v1/secret/kraken", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": {"data": {"other": "x"}}}`)
+	})
+	addr, stop := testutil.StartServer(r)
+	defer stop()
+
+	tokenFile, cleanup := testutil.TempFile([]byte("token"))
+	defer cleanup()
+
+	p, err := NewVaultProvider(VaultConfig{
+		Addr:      "http://" + addr,
+		Path:      "secret/kraken",
+		TokenFile: tokenFile,
+	})
+	require.NoError(err)
+
+	var config testConfig
+	require.Error(p.Load(&config))
+}
+
+func TestNewVaultProviderInvalidConfig(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config VaultConfig
+	}{
+		{"no addr", VaultConfig{Path: "secret/kraken"}},
+		{"no path", VaultConfig{Addr: "http://vault:8200"}},
+		{"bad kv version", VaultConfig{Addr: "http://vault:8200", Path: "secret/kraken", KVVersion: 3}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := NewVaultProvider(test.config)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestAWSSecretsManagerProviderLoad(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := mocksecrets.NewMockSecretsManager(ctrl)
+
+	p, err := NewAWSSecretsManagerProvider(
+		AWSSecretsManagerConfig{Region: "us-west-1", SecretID: "kraken"},
+		WithSecretsManager(api))
+	require.NoError(err)
+
+	api.EXPECT().GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String("kraken"),
+	}).Return(&secretsmanager.GetSecretValueOutput{
+		SecretString: aws.String(_secrets),
+	}, nil)
+
+	var config testConfig
+	require.NoError(p.Load(&config))
+	require.Equal("foo", config.Auth.Username)
+	require.Equal("bar", config.Auth.Password)
+}
+
+func TestAWSSecretsManagerProviderLoadNoStringValue(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := mocksecrets.NewMockSecretsManager(ctrl)
+
+	p, err := NewAWSSecretsManagerProvider(
+		AWSSecretsManagerConfig{Region: "us-west-1", SecretID: "kraken"},
+		WithSecretsManager(api))
+	require.NoError(err)
+
+	api.EXPECT().GetSecretValue(gomock.Any()).Return(&secretsmanager.GetSecretValueOutput{}, nil)
+
+	var config testConfig
+	require.Error(p.Load(&config))
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/uber/kraken/utils/configutil"
+	"github.com/uber/kraken/utils/httputil"
+)
+
+// VaultConfig defines a secret stored in a Vault KV secrets engine.
+type VaultConfig struct {
+	// Addr is the address of the Vault server, e.g. https://vault:8200.
+	Addr string `yaml:"addr"`
+
+	// Path is the API path of the secret, e.g. secret/data/kraken/agent for
+	// the KV version 2 engine mounted at secret/.
+	Path string `yaml:"path"`
+
+	// Key is the key of the secret whose value contains the YAML secrets.
+	Key string `yaml:"key"`
+
+	// KVVersion is the version of the KV secrets engine, 1 or 2.
+	KVVersion int `yaml:"kv_version"`
+
+	// TokenFile is the path to a file containing the Vault token, e.g. written
+	// by a Vault agent. Defaults to the VAULT_TOKEN environment variable.
+	TokenFile string `yaml:"token_file"`
+
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (c VaultConfig) applyDefaults() VaultConfig {
+	if c.Key == "" {
+		c.Key = "secrets"
+	}
+	if c.KVVersion == 0 {
+		c.KVVersion = 2
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 10 * time.Second
+	}
+	return c
+}
+
+type vaultProvider struct {
+	config VaultConfig
+}
+
+// NewVaultProvider creates a Provider which loads secrets from Vault.
+func NewVaultProvider(config VaultConfig) (Provider, error) {
+	config = config.applyDefaults()
+	if config.Addr == "" {
+		return nil, errors.New("invalid config: addr required")
+	}
+	if config.Path == "" {
+		return nil, errors.New("invalid config: path required")
+	}
+	if config.KVVersion != 1 && config.KVVersion != 2 {
+		return nil, fmt.Errorf("invalid config: unsupported kv_version %d", config.KVVersion)
+	}
+	return &vaultProvider{config}, nil
+}
+
+func (p *vaultProvider) Load(config interface{}) error {
+	token, err := p.token()
+	if err != nil {
+		return fmt.Errorf("vault token: %s", err)
+	}
+	u := fmt.Sprintf(
+		"%s/v1/%s", strings.TrimSuffix(p.config.Addr, "/"), strings.TrimPrefix(p.config.Path, "/"))
+	resp, err := httputil.Get(
+		u,
+		httputil.SendHeaders(map[string]string{"X-Vault-Token": token}),
+		httputil.SendTimeout(p.config.Timeout),
+		httputil.DisableHTTPFallback())
+	if err != nil {
+		return fmt.Errorf("get secret: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var secret struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return fmt.Errorf("decode secret: %s", err)
+	}
+	data := secret.Data
+	if p.config.KVVersion == 2 {
+		// Version 2 nests the secret data alongside its metadata.
+		var versioned struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(data, &versioned); err != nil {
+			return fmt.Errorf("decode versioned secret: %s", err)
+		}
+		data = versioned.Data
+	}
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("decode secret data: %s", err)
+	}
+	v, ok := values[p.config.Key]
+	if !ok {
+		return fmt.Errorf("secret has no key %q", p.config.Key)
+	}
+	return configutil.LoadData([]byte(v), config)
+}
+
+func (p *vaultProvider) token() (string, error) {
+	if p.config.TokenFile == "" {
+		token := os.Getenv("VAULT_TOKEN")
+		if token == "" {
+			return "", errors.New("VAULT_TOKEN is not set")
+		}
+		return token, nil
+	}
+	b, err := ioutil.ReadFile(p.config.TokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
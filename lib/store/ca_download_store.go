@@ -15,12 +15,17 @@ package store
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sync"
 
+	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/store/base"
 	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/utils/log"
 	"github.com/andres-erbsen/clock"
 	"github.com/uber-go/tally"
+	"go.uber.org/atomic"
 )
 
 // CADownloadStore allows simultaneously downloading and uploading
@@ -30,10 +35,23 @@ type CADownloadStore struct {
 	downloadState base.FileState
 	cacheState    base.FileState
 	cleanup       *cleanupManager
+	pins          *pins
+	evictor       *evictor
+	expirer       *expirer
+	namespaceMu   sync.Mutex
+	verifyOnRead  VerifyOnReadConfig
+	quarantine    *quarantine
+	reads         *atomic.Uint64
+	stats         tally.Scope
+
+	// freeSpace returns the free space on the disk of the download directory.
+	freeSpace func() (int64, error)
 }
 
 // NewCADownloadStore creates a new CADownloadStore.
 func NewCADownloadStore(config CADownloadStoreConfig, stats tally.Scope) (*CADownloadStore, error) {
+	config = config.applyDefaults()
+
 	stats = stats.Tagged(map[string]string{
 		"module": "cadownloadstore",
 	})
@@ -47,6 +65,17 @@ func NewCADownloadStore(config CADownloadStoreConfig, stats tally.Scope) (*CADow
 	backend := base.NewCASFileStore(clock.New())
 	downloadState := base.NewFileState(config.DownloadDir)
 	cacheState := base.NewFileState(config.CacheDir)
+	pins := newPins()
+
+	expirer, err := newExpirer(
+		config.CacheExpiry,
+		clock.New(),
+		stats,
+		backend.NewFileOp().AcceptState(cacheState),
+		pins)
+	if err != nil {
+		return nil, fmt.Errorf("new expirer: %s", err)
+	}
 
 	cleanup, err := newCleanupManager(clock.New(), stats)
 	if err != nil {
@@ -61,22 +90,108 @@ func NewCADownloadStore(config CADownloadStoreConfig, stats tally.Scope) (*CADow
 		config.CacheCleanup,
 		backend.NewFileOp().AcceptState(cacheState))
 
+	downloadFreeSpace := func() (int64, error) { return freeSpace(config.DownloadDir) }
+	if free, err := downloadFreeSpace(); err != nil {
+		log.Warnf("Error checking free space: %s", err)
+	} else if free < config.CacheEviction.MinFreeSpace {
+		log.Warnf(
+			"Low disk space in %s: %d bytes free, below min free space of %d bytes",
+			config.DownloadDir, free, config.CacheEviction.MinFreeSpace)
+	}
+
+	quarantine, err := newQuarantine(config.Quarantine, clock.New(), stats)
+	if err != nil {
+		return nil, fmt.Errorf("new quarantine: %s", err)
+	}
+
+	evictor := newEvictor(
+		config.CacheEviction,
+		clock.New(),
+		stats,
+		backend.NewFileOp().AcceptState(cacheState),
+		pins,
+		func() (int64, error) { return freeSpace(config.CacheDir) })
+	evictor.start()
+	expirer.start()
+
 	return &CADownloadStore{
 		backend:       backend,
 		downloadState: downloadState,
 		cacheState:    cacheState,
 		cleanup:       cleanup,
+		pins:          pins,
+		evictor:       evictor,
+		expirer:       expirer,
+		verifyOnRead:  config.VerifyOnRead,
+		quarantine:    quarantine,
+		reads:         atomic.NewUint64(0),
+		stats:         stats,
+		freeSpace:     downloadFreeSpace,
 	}, nil
 }
 
 // Close terminates all goroutines started by s.
 func (s *CADownloadStore) Close() {
 	s.cleanup.stop()
+	s.evictor.stop()
+	s.expirer.stop()
+}
+
+// Pin protects name from cache eviction until a matching Unpin call. Pins are
+// reference counted.
+func (s *CADownloadStore) Pin(name string) {
+	s.pins.pin(name)
+}
+
+// Unpin releases a pin on name acquired via Pin.
+func (s *CADownloadStore) Unpin(name string) {
+	s.pins.unpin(name)
+}
+
+// CheckReadiness verifies that the download and cache directories are
+// accessible.
+func (s *CADownloadStore) CheckReadiness() error {
+	for _, state := range []base.FileState{s.downloadState, s.cacheState} {
+		if _, err := os.Stat(state.GetDirectory()); err != nil {
+			return fmt.Errorf("stat %s: %s", state.GetDirectory(), err)
+		}
+	}
+	return nil
 }
 
 // CreateDownloadFile creates an empty download file initialized with length.
+// Returns an error satisfying IsInsufficientStorage if the download directory
+// does not have enough free space for the file.
 func (s *CADownloadStore) CreateDownloadFile(name string, length int64) error {
-	return s.backend.NewFileOp().CreateFile(name, s.downloadState, length)
+	if err := s.checkFreeSpace(name, length); err != nil {
+		return err
+	}
+	err := s.backend.NewFileOp().CreateFile(name, s.downloadState, length)
+	if err != nil && IsInsufficientStorage(err) {
+		s.stats.Counter("insufficient_storage").Inc(1)
+	}
+	return err
+}
+
+// checkFreeSpace returns InsufficientStorageError if a download file of length
+// bytes does not fit on the disk. Files which already exist are not checked,
+// since creating them is a no-op. Failures to check free space are logged and
+// otherwise ignored, such that the file creation itself surfaces disk errors.
+func (s *CADownloadStore) checkFreeSpace(name string, length int64) error {
+	free, err := s.freeSpace()
+	if err != nil {
+		log.Warnf("Error checking free space: %s", err)
+		return nil
+	}
+	s.stats.Gauge("free_bytes").Update(float64(free))
+	if free >= length {
+		return nil
+	}
+	if _, err := s.Any().GetFileStat(name); err == nil {
+		return nil
+	}
+	s.stats.Counter("insufficient_storage").Inc(1)
+	return InsufficientStorageError{s.downloadState.GetDirectory(), length, free}
 }
 
 // GetDownloadFileReadWriter returns a FileReadWriter for name.
@@ -89,6 +204,17 @@ func (s *CADownloadStore) MoveDownloadFileToCache(name string) error {
 	return s.backend.NewFileOp().AcceptState(s.downloadState).MoveFile(name, s.cacheState)
 }
 
+// ListDownloadFileNames returns the names of all files in the download
+// directory.
+func (s *CADownloadStore) ListDownloadFileNames() ([]string, error) {
+	return s.backend.NewFileOp().AcceptState(s.downloadState).ListNames()
+}
+
+// ListCacheFileNames returns the names of all files in the cache directory.
+func (s *CADownloadStore) ListCacheFileNames() ([]string, error) {
+	return s.backend.NewFileOp().AcceptState(s.cacheState).ListNames()
+}
+
 // GetCacheFileReader gets a cache file reader. Implemented for compatibility with
 // other stores.
 func (s *CADownloadStore) GetCacheFileReader(name string) (FileReader, error) {
@@ -101,6 +227,60 @@ func (s *CADownloadStore) GetCacheFileStat(name string) (os.FileInfo, error) {
 	return s.Cache().GetFileStat(name)
 }
 
+// VerifyCacheFile hashes the content of cache file name and compares it
+// against name, regardless of whether verify on read is enabled. On mismatch,
+// the file is evicted from the cache and a not exist error is returned.
+func (s *CADownloadStore) VerifyCacheFile(name string) error {
+	f, err := s.states().cache().GetFileReader(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.verify(name, f)
+}
+
+// QuarantineCacheFile removes cache file name, which failed verification. The
+// file is moved aside if quarantine is enabled, else deleted.
+func (s *CADownloadStore) QuarantineCacheFile(name string) error {
+	return s.quarantine.remove(s.states().cache().op, name)
+}
+
+func (s *CADownloadStore) sampleVerify() bool {
+	if !s.verifyOnRead.Enabled {
+		return false
+	}
+	return s.reads.Inc()%uint64(s.verifyOnRead.SampleRate) == 0
+}
+
+// verify hashes the content of cache file f and compares it against name. On
+// mismatch, the file is evicted from the cache, or quarantined if configured,
+// and a not exist error is returned, such that callers re-download the file as
+// if it were never cached. Leaves f seeked to the beginning of the file.
+func (s *CADownloadStore) verify(name string, f FileReader) error {
+	expected, err := core.NewDigestFromHex(name)
+	if err != nil {
+		// Not a content-addressed name, nothing to verify against.
+		return nil
+	}
+	actual, err := expected.Digester().FromReader(f)
+	if err != nil {
+		return fmt.Errorf("compute digest: %s", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek: %s", err)
+	}
+	s.stats.Counter("verify_on_read").Inc(1)
+	if actual == expected {
+		return nil
+	}
+	s.stats.Counter("verify_on_read_mismatch").Inc(1)
+	log.With("name", name, "actual", actual).Error("Cache file failed verification, evicting")
+	if err := s.QuarantineCacheFile(name); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("evict corrupt file: %s", err)
+	}
+	return &os.PathError{Op: "verify", Path: name, Err: os.ErrNotExist}
+}
+
 // InCacheError returns true for errors originating from file store operations
 // which do not accept files in cache state.
 func (s *CADownloadStore) InCacheError(err error) bool {
@@ -115,12 +295,32 @@ func (s *CADownloadStore) InDownloadError(err error) bool {
 	return ok && fse.State == s.downloadState
 }
 
+// AddNamespace records that name was downloaded or served in namespace, such
+// that name expires by the shortest TTL of all namespaces it was used in.
+func (s *CADownloadStore) AddNamespace(name, namespace string) error {
+	s.namespaceMu.Lock()
+	defer s.namespaceMu.Unlock()
+
+	var ns metadata.Namespace
+	if err := s.Any().GetMetadata(name, &ns); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("get namespace: %s", err)
+	}
+	if !ns.Add(namespace) {
+		return nil
+	}
+	if _, err := s.Any().SetMetadata(name, &ns); err != nil {
+		return fmt.Errorf("set namespace: %s", err)
+	}
+	return nil
+}
+
 // CADownloadStoreScope scopes what states an operation may be accepted within.
 // Should only be used for read / write operations which are acceptable in any
 // state.
 type CADownloadStoreScope struct {
-	store *CADownloadStore
-	op    base.FileOp
+	store  *CADownloadStore
+	op     base.FileOp
+	verify bool
 }
 
 func (s *CADownloadStore) states() *CADownloadStoreScope {
@@ -145,9 +345,12 @@ func (s *CADownloadStore) Download() *CADownloadStoreScope {
 	return s.states().download()
 }
 
-// Cache scopes the store to files in the cache state.
+// Cache scopes the store to files in the cache state. If verify on read is
+// enabled, file readers returned from this scope are sampled for verification.
 func (s *CADownloadStore) Cache() *CADownloadStoreScope {
-	return s.states().cache()
+	a := s.states().cache()
+	a.verify = true
+	return a
 }
 
 // Any scopes the store to files in any state.
@@ -155,9 +358,23 @@ func (s *CADownloadStore) Any() *CADownloadStoreScope {
 	return s.states().download().cache()
 }
 
-// GetFileReader returns a reader for name.
+// GetFileReader returns a reader for name. name is pinned against eviction
+// until the reader is closed.
 func (a *CADownloadStoreScope) GetFileReader(name string) (FileReader, error) {
-	return a.op.GetFileReader(name)
+	a.store.Pin(name)
+	f, err := a.op.GetFileReader(name)
+	if err != nil {
+		a.store.Unpin(name)
+		return nil, err
+	}
+	f = &pinnedFileReader{FileReader: f, unpin: func() { a.store.Unpin(name) }}
+	if a.verify && a.store.sampleVerify() {
+		if err := a.store.verify(name, f); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
 }
 
 // GetFileStat returns file info for name.
@@ -189,6 +406,11 @@ func (a *CADownloadStoreScope) SetMetadataAt(
 	return a.op.SetFileMetadataAt(name, md, b, offset)
 }
 
+// DeleteMetadata deletes the metadata content of md for name.
+func (a *CADownloadStoreScope) DeleteMetadata(name string, md metadata.Metadata) error {
+	return a.op.DeleteFileMetadata(name, md)
+}
+
 // GetOrSetMetadata returns the metadata content of md for name, or
 // initializes the metadata content to b if not set.
 func (a *CADownloadStoreScope) GetOrSetMetadata(name string, md metadata.Metadata) error {
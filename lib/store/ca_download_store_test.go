@@ -14,13 +14,18 @@
 package store
 
 import (
+	"errors"
+	"io/ioutil"
 	"os"
 	"sync"
+	"syscall"
 	"testing"
 
 	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store/metadata"
 
 	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
 )
 
 func TestCADownloadStoreDownloadAndDeleteFiles(t *testing.T) {
@@ -49,3 +54,131 @@ func TestCADownloadStoreDownloadAndDeleteFiles(t *testing.T) {
 		require.True(os.IsNotExist(err))
 	}
 }
+
+func TestCADownloadStoreAddNamespace(t *testing.T) {
+	require := require.New(t)
+
+	s, cleanup := CADownloadStoreFixture()
+	defer cleanup()
+
+	name := core.DigestFixture().Hex()
+	require.NoError(s.CreateDownloadFile(name, 1))
+
+	var wg sync.WaitGroup
+	for _, ns := range []string{"a", "b", "c", "a"} {
+		wg.Add(1)
+		go func(ns string) {
+			defer wg.Done()
+			require.NoError(s.AddNamespace(name, ns))
+		}(ns)
+	}
+	wg.Wait()
+
+	require.NoError(s.MoveDownloadFileToCache(name))
+
+	var result metadata.Namespace
+	require.NoError(s.Cache().GetMetadata(name, &result))
+	require.ElementsMatch([]string{"a", "b", "c"}, result.Values)
+}
+
+func TestCADownloadStoreVerifyOnRead(t *testing.T) {
+	require := require.New(t)
+
+	config, cleanup := CADownloadStoreConfigFixture()
+	defer cleanup()
+
+	config.VerifyOnRead = VerifyOnReadConfig{Enabled: true, SampleRate: 1}
+
+	s, err := NewCADownloadStore(config, tally.NoopScope)
+	require.NoError(err)
+	defer s.Close()
+
+	blob := core.NewBlobFixture()
+	require.NoError(RunDownload(s, blob.Digest, blob.Content))
+
+	f, err := s.Cache().GetFileReader(blob.Digest.Hex())
+	require.NoError(err)
+	defer f.Close()
+	result, err := ioutil.ReadAll(f)
+	require.NoError(err)
+	require.Equal(blob.Content, result)
+
+	// Cache a file whose content does not match its name.
+	corrupt := core.DigestFixture()
+	require.NoError(RunDownload(s, corrupt, []byte("some corrupt content")))
+
+	_, err = s.GetCacheFileReader(corrupt.Hex())
+	require.True(os.IsNotExist(err))
+
+	_, err = s.Cache().GetFileStat(corrupt.Hex())
+	require.True(os.IsNotExist(err))
+}
+
+func TestCADownloadStoreVerifyOnReadSamples(t *testing.T) {
+	require := require.New(t)
+
+	config, cleanup := CADownloadStoreConfigFixture()
+	defer cleanup()
+
+	config.VerifyOnRead = VerifyOnReadConfig{Enabled: true, SampleRate: 3}
+
+	s, err := NewCADownloadStore(config, tally.NoopScope)
+	require.NoError(err)
+	defer s.Close()
+
+	corrupt := core.DigestFixture()
+	require.NoError(RunDownload(s, corrupt, []byte("some corrupt content")))
+
+	// Only the third read is verified.
+	for i := 0; i < 2; i++ {
+		f, err := s.Cache().GetFileReader(corrupt.Hex())
+		require.NoError(err)
+		f.Close()
+	}
+	_, err = s.Cache().GetFileReader(corrupt.Hex())
+	require.True(os.IsNotExist(err))
+}
+
+func TestCADownloadStoreCreateDownloadFileInsufficientStorage(t *testing.T) {
+	require := require.New(t)
+
+	s, cleanup := CADownloadStoreFixture()
+	defer cleanup()
+
+	s.freeSpace = func() (int64, error) { return 10, nil }
+
+	name := core.DigestFixture().Hex()
+
+	err := s.CreateDownloadFile(name, 11)
+	require.Error(err)
+	require.True(IsInsufficientStorage(err))
+	_, err = s.Any().GetFileStat(name)
+	require.True(os.IsNotExist(err))
+
+	require.NoError(s.CreateDownloadFile(name, 10))
+
+	// Existing files are not checked against free space.
+	s.freeSpace = func() (int64, error) { return 0, nil }
+	err = s.CreateDownloadFile(name, 10)
+	require.True(s.InDownloadError(err))
+}
+
+func TestCADownloadStoreCreateDownloadFileFreeSpaceError(t *testing.T) {
+	require := require.New(t)
+
+	s, cleanup := CADownloadStoreFixture()
+	defer cleanup()
+
+	s.freeSpace = func() (int64, error) { return 0, errors.New("some error") }
+
+	require.NoError(s.CreateDownloadFile(core.DigestFixture().Hex(), 10))
+}
+
+func TestIsInsufficientStorage(t *testing.T) {
+	require := require.New(t)
+
+	require.True(IsInsufficientStorage(InsufficientStorageError{"/tmp", 10, 1}))
+	require.True(IsInsufficientStorage(
+		&os.PathError{Op: "truncate", Path: "/tmp/f", Err: syscall.ENOSPC}))
+	require.False(IsInsufficientStorage(errors.New("some error")))
+}
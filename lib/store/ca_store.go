@@ -116,13 +116,13 @@ func (s *CAStore) WriteCacheFile(name string, write func(w FileReadWriter) error
 	if _, err := w.Seek(0, 0); err != nil {
 		return fmt.Errorf("seek: %s", err)
 	}
-	actual, err := core.NewDigester().FromReader(w)
+	expected, err := core.NewDigestFromHex(name)
 	if err != nil {
-		return fmt.Errorf("compute digest: %s", err)
+		return fmt.Errorf("new digest from file name: %s", err)
 	}
-	expected, err := core.NewSHA256DigestFromHex(name)
+	actual, err := expected.Digester().FromReader(w)
 	if err != nil {
-		return fmt.Errorf("new digest from file name: %s", err)
+		return fmt.Errorf("compute digest: %s", err)
 	}
 	if actual != expected {
 		return fmt.Errorf("failed to verify data: digests do not match")
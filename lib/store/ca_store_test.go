@@ -177,3 +177,26 @@ func TestCAStoreCreateCacheFile(t *testing.T) {
 	b2, err := ioutil.ReadAll(r2)
 	require.Equal(s1, string(b2))
 }
+
+func TestCAStoreCreateCacheFileSHA512(t *testing.T) {
+	require := require.New(t)
+
+	s, cleanup := CAStoreFixture()
+	defer cleanup()
+
+	content := "buffer"
+	digester, err := core.NewDigesterForAlgo(core.SHA512)
+	require.NoError(err)
+	d, err := digester.FromBytes([]byte(content))
+	require.NoError(err)
+
+	require.NoError(s.CreateCacheFile(d.Hex(), strings.NewReader(content)))
+	r, err := s.GetCacheFileReader(d.Hex())
+	require.NoError(err)
+	b, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	require.Equal(content, string(b))
+
+	// Content must match the sha512 digest.
+	require.Error(s.CreateCacheFile(d.Hex(), strings.NewReader("other content")))
+}
@@ -53,4 +53,34 @@ type CADownloadStoreConfig struct {
 	CacheDir        string        `yaml:"cache_dir"`
 	DownloadCleanup CleanupConfig `yaml:"download_cleanup"`
 	CacheCleanup    CleanupConfig `yaml:"cache_cleanup"`
+
+	// CacheEviction evicts least recently used cache files once the cache
+	// exceeds a max size.
+	CacheEviction EvictionConfig `yaml:"cache_eviction"`
+
+	// CacheExpiry deletes cache files a fixed duration after they were
+	// downloaded, by namespace, even if they were recently accessed.
+	CacheExpiry ExpiryConfig `yaml:"cache_expiry"`
+
+	VerifyOnRead VerifyOnReadConfig `yaml:"verify_on_read"`
+
+	// Quarantine moves cache files which fail verification aside instead of
+	// deleting them.
+	Quarantine QuarantineConfig `yaml:"quarantine"`
+}
+
+func (c CADownloadStoreConfig) applyDefaults() CADownloadStoreConfig {
+	if c.VerifyOnRead.SampleRate == 0 {
+		c.VerifyOnRead.SampleRate = 100
+	}
+	return c
+}
+
+// VerifyOnReadConfig defines sampled verification of cache file content
+// against its digest when the file is read.
+type VerifyOnReadConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// One in every SampleRate reads is verified.
+	SampleRate int `yaml:"sample_rate"`
 }
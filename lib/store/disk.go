@@ -0,0 +1,59 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// InsufficientStorageError occurs when a file of Size bytes cannot be created
+// in Dir, which only has Free bytes available.
+type InsufficientStorageError struct {
+	Dir  string
+	Size int64
+	Free int64
+}
+
+func (e InsufficientStorageError) Error() string {
+	return fmt.Sprintf(
+		"insufficient storage in %s: %d bytes required, %d bytes free", e.Dir, e.Size, e.Free)
+}
+
+// IsInsufficientStorage returns true if err is caused by the store disk not
+// having enough free space, including writes which failed with ENOSPC.
+func IsInsufficientStorage(err error) bool {
+	switch e := err.(type) {
+	case InsufficientStorageError:
+		return true
+	case *os.PathError:
+		return e.Err == syscall.ENOSPC
+	case *os.LinkError:
+		return e.Err == syscall.ENOSPC
+	case *os.SyscallError:
+		return e.Err == syscall.ENOSPC
+	}
+	return err == syscall.ENOSPC
+}
+
+// freeSpace returns the number of bytes available to unprivileged users on the
+// filesystem containing dir.
+func freeSpace(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, fmt.Errorf("statfs %s: %s", dir, err)
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/lib/store/base"
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
+)
+
+// EvictionConfig defines configuration for evicting least recently used files
+// once their total size exceeds a cap, or free space on their disk drops below
+// a threshold.
+type EvictionConfig struct {
+	// MaxSize is the max total size of files in bytes. If 0, disables eviction
+	// by size.
+	MaxSize int64 `yaml:"max_size"`
+
+	// MinFreeSpace is the min free space in bytes on the disk containing the
+	// files. If 0, disables eviction by free space.
+	MinFreeSpace int64 `yaml:"min_free_space"`
+
+	Interval time.Duration `yaml:"interval"` // How often eviction runs.
+}
+
+func (c EvictionConfig) applyDefaults() EvictionConfig {
+	if c.Interval == 0 {
+		c.Interval = time.Minute
+	}
+	return c
+}
+
+// pins tracks files which must not be evicted.
+type pins struct {
+	sync.Mutex
+	counts map[string]int
+}
+
+func newPins() *pins {
+	return &pins{counts: make(map[string]int)}
+}
+
+func (p *pins) pin(name string) {
+	p.Lock()
+	defer p.Unlock()
+	p.counts[name]++
+}
+
+func (p *pins) unpin(name string) {
+	p.Lock()
+	defer p.Unlock()
+	if p.counts[name] <= 1 {
+		delete(p.counts, name)
+	} else {
+		p.counts[name]--
+	}
+}
+
+// pinnedFileReader unpins its file when closed.
+type pinnedFileReader struct {
+	FileReader
+	once  sync.Once
+	unpin func()
+}
+
+func (r *pinnedFileReader) Close() error {
+	r.once.Do(r.unpin)
+	return r.FileReader.Close()
+}
+
+// evictor periodically deletes the least recently accessed files of op once
+// their total size exceeds the configured max size, or free space on their
+// disk drops below the configured min free space. Pinned files are never
+// evicted.
+type evictor struct {
+	config    EvictionConfig
+	clk       clock.Clock
+	stats     tally.Scope
+	op        base.FileOp
+	pins      *pins
+	freeSpace func() (int64, error)
+	stopOnce  sync.Once
+	stopc     chan struct{}
+}
+
+func newEvictor(
+	config EvictionConfig,
+	clk clock.Clock,
+	stats tally.Scope,
+	op base.FileOp,
+	pins *pins,
+	freeSpace func() (int64, error)) *evictor {
+
+	return &evictor{
+		config:    config.applyDefaults(),
+		clk:       clk,
+		stats:     stats.Tagged(map[string]string{"module": "storeeviction"}),
+		op:        op,
+		pins:      pins,
+		freeSpace: freeSpace,
+		stopc:     make(chan struct{}),
+	}
+}
+
+func (e *evictor) start() {
+	if e.config.MaxSize == 0 && e.config.MinFreeSpace == 0 {
+		return
+	}
+	ticker := e.clk.Ticker(e.config.Interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := e.evict(); err != nil {
+					log.Errorf("Error evicting from %s: %s", e.op, err)
+				}
+			case <-e.stopc:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (e *evictor) stop() {
+	e.stopOnce.Do(func() { close(e.stopc) })
+}
+
+type evictionCandidate struct {
+	name string
+	size int64
+	lat  time.Time
+}
+
+// evict deletes the least recently accessed files until the total size of op
+// is within the max size, and free space is above the min free space.
+func (e *evictor) evict() error {
+	var free int64
+	if e.config.MinFreeSpace > 0 {
+		var err error
+		free, err = e.freeSpace()
+		if err != nil {
+			return fmt.Errorf("free space: %s", err)
+		}
+	}
+	names, err := e.op.ListNames()
+	if err != nil {
+		return fmt.Errorf("list names: %s", err)
+	}
+	var usage int64
+	var candidates []evictionCandidate
+	for _, name := range names {
+		info, err := e.op.GetFileStat(name)
+		if err != nil {
+			continue
+		}
+		usage += info.Size()
+		lat := metadata.NewLastAccessTime(info.ModTime())
+		if err := e.op.GetFileMetadata(name, lat); err != nil && !os.IsNotExist(err) {
+			log.With("name", name).Errorf("Error getting file lat: %s", err)
+		}
+		candidates = append(candidates, evictionCandidate{name, info.Size(), lat.Time})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lat.Before(candidates[j].lat)
+	})
+	for _, c := range candidates {
+		if !e.exceeded(usage, free) {
+			break
+		}
+		if e.delete(c.name) {
+			usage -= c.size
+			free += c.size
+			e.stats.Counter("evictions").Inc(1)
+		}
+	}
+	e.stats.Gauge("usage").Update(float64(usage))
+	if e.config.MinFreeSpace > 0 {
+		e.stats.Gauge("free_bytes").Update(float64(free))
+		if free < e.config.MinFreeSpace {
+			log.Warnf(
+				"Free space %d bytes below min %d bytes after evicting from %s",
+				free, e.config.MinFreeSpace, e.op)
+		}
+	}
+	return nil
+}
+
+// exceeded returns true if usage exceeds the max size, or free is below the
+// min free space.
+func (e *evictor) exceeded(usage, free int64) bool {
+	if e.config.MaxSize > 0 && usage > e.config.MaxSize {
+		return true
+	}
+	return e.config.MinFreeSpace > 0 && free < e.config.MinFreeSpace
+}
+
+func (e *evictor) delete(name string) bool {
+	return deleteUnpinned(e.op, e.pins, name)
+}
+
+// deleteUnpinned deletes name from op unless it is pinned or persisted. Pins
+// are locked while deleting, so name cannot be pinned mid-deletion.
+func deleteUnpinned(op base.FileOp, pins *pins, name string) bool {
+	pins.Lock()
+	defer pins.Unlock()
+
+	if pins.counts[name] > 0 {
+		return false
+	}
+	if err := op.DeleteFile(name); err != nil {
+		if err != base.ErrFilePersisted && !os.IsNotExist(err) {
+			log.With("name", name).Errorf("Error deleting file: %s", err)
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func TestEvictorEvictsLeastRecentlyUsedFiles(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+
+	state, op, cleanup := fileOpFixture(clk)
+	defer cleanup()
+
+	var names []string
+	for i := 0; i < 4; i++ {
+		name := core.DigestFixture().Hex()
+		require.NoError(op.CreateFile(name, state, 10))
+		names = append(names, name)
+		clk.Add(time.Hour)
+	}
+
+	e := newEvictor(EvictionConfig{MaxSize: 25}, clk, tally.NoopScope, op, newPins(), nil)
+	require.NoError(e.evict())
+
+	for _, name := range names[:2] {
+		_, err := op.GetFileStat(name)
+		require.True(os.IsNotExist(err))
+	}
+	for _, name := range names[2:] {
+		_, err := op.GetFileStat(name)
+		require.NoError(err)
+	}
+}
+
+func TestEvictorSkipsPinnedFiles(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+
+	state, op, cleanup := fileOpFixture(clk)
+	defer cleanup()
+
+	oldest := core.DigestFixture().Hex()
+	require.NoError(op.CreateFile(oldest, state, 10))
+	clk.Add(time.Hour)
+
+	newest := core.DigestFixture().Hex()
+	require.NoError(op.CreateFile(newest, state, 10))
+
+	pins := newPins()
+	pins.pin(oldest)
+
+	e := newEvictor(EvictionConfig{MaxSize: 15}, clk, tally.NoopScope, op, pins, nil)
+	require.NoError(e.evict())
+
+	_, err := op.GetFileStat(oldest)
+	require.NoError(err)
+	_, err = op.GetFileStat(newest)
+	require.True(os.IsNotExist(err))
+
+	pins.unpin(oldest)
+	require.NoError(e.evict())
+
+	_, err = op.GetFileStat(oldest)
+	require.NoError(err)
+}
+
+func TestEvictorEvictsUntilMinFreeSpace(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+
+	state, op, cleanup := fileOpFixture(clk)
+	defer cleanup()
+
+	var names []string
+	for i := 0; i < 4; i++ {
+		name := core.DigestFixture().Hex()
+		require.NoError(op.CreateFile(name, state, 10))
+		names = append(names, name)
+		clk.Add(time.Hour)
+	}
+
+	freeSpace := func() (int64, error) { return 5, nil }
+
+	e := newEvictor(EvictionConfig{MinFreeSpace: 20}, clk, tally.NoopScope, op, newPins(), freeSpace)
+	require.NoError(e.evict())
+
+	for _, name := range names[:2] {
+		_, err := op.GetFileStat(name)
+		require.True(os.IsNotExist(err))
+	}
+	for _, name := range names[2:] {
+		_, err := op.GetFileStat(name)
+		require.NoError(err)
+	}
+}
+
+func TestEvictorFreeSpaceError(t *testing.T) {
+	clk := clock.NewMock()
+
+	_, op, cleanup := fileOpFixture(clk)
+	defer cleanup()
+
+	freeSpace := func() (int64, error) { return 0, errors.New("some error") }
+
+	e := newEvictor(EvictionConfig{MinFreeSpace: 20}, clk, tally.NoopScope, op, newPins(), freeSpace)
+	require.Error(t, e.evict())
+}
+
+func TestCADownloadStoreReaderPinsFile(t *testing.T) {
+	require := require.New(t)
+
+	s, cleanup := CADownloadStoreFixture()
+	defer cleanup()
+
+	blob := core.NewBlobFixture()
+	require.NoError(RunDownload(s, blob.Digest, blob.Content))
+
+	f, err := s.Cache().GetFileReader(blob.Digest.Hex())
+	require.NoError(err)
+	require.Equal(1, s.pins.counts[blob.Digest.Hex()])
+
+	require.NoError(f.Close())
+	require.Equal(0, s.pins.counts[blob.Digest.Hex()])
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/lib/store/base"
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
+)
+
+// ExpiryConfig defines expiry of files a fixed duration after they were
+// downloaded, regardless of how recently they were accessed.
+type ExpiryConfig struct {
+	// Rules set the TTL of files by the namespaces they were downloaded or
+	// served in. For each namespace, the first rule whose namespace matches
+	// applies, and files used in several namespaces expire by the shortest TTL
+	// among them.
+	Rules []ExpiryRule `yaml:"rules"`
+
+	// DefaultTTL applies to files which match no rule, including files whose
+	// namespace is unknown. If 0, such files never expire.
+	DefaultTTL time.Duration `yaml:"default_ttl"`
+
+	Interval time.Duration `yaml:"interval"` // How often expired files are deleted.
+}
+
+// ExpiryRule sets the TTL of files downloaded in namespaces matching Namespace,
+// a regular expression.
+type ExpiryRule struct {
+	Namespace string        `yaml:"namespace"`
+	TTL       time.Duration `yaml:"ttl"`
+}
+
+func (c ExpiryConfig) applyDefaults() ExpiryConfig {
+	if c.Interval == 0 {
+		c.Interval = time.Minute
+	}
+	return c
+}
+
+type expiryRule struct {
+	namespace *regexp.Regexp
+	ttl       time.Duration
+}
+
+// expirer periodically deletes files of op which have outlived the TTL of
+// their namespace. Pinned files are deleted once unpinned.
+type expirer struct {
+	config   ExpiryConfig
+	rules    []expiryRule
+	clk      clock.Clock
+	stats    tally.Scope
+	op       base.FileOp
+	pins     *pins
+	stopOnce sync.Once
+	stopc    chan struct{}
+}
+
+func newExpirer(
+	config ExpiryConfig,
+	clk clock.Clock,
+	stats tally.Scope,
+	op base.FileOp,
+	pins *pins) (*expirer, error) {
+
+	config = config.applyDefaults()
+
+	var rules []expiryRule
+	for _, r := range config.Rules {
+		re, err := regexp.Compile(r.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace regexp %q: %s", r.Namespace, err)
+		}
+		rules = append(rules, expiryRule{re, r.TTL})
+	}
+	return &expirer{
+		config: config,
+		rules:  rules,
+		clk:    clk,
+		stats:  stats.Tagged(map[string]string{"module": "storeexpiry"}),
+		op:     op,
+		pins:   pins,
+		stopc:  make(chan struct{}),
+	}, nil
+}
+
+func (e *expirer) start() {
+	if len(e.rules) == 0 && e.config.DefaultTTL == 0 {
+		return
+	}
+	ticker := e.clk.Ticker(e.config.Interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := e.expire(); err != nil {
+					log.Errorf("Error expiring files of %s: %s", e.op, err)
+				}
+			case <-e.stopc:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (e *expirer) stop() {
+	e.stopOnce.Do(func() { close(e.stopc) })
+}
+
+// ttl returns the TTL of files used in namespaces, i.e. the shortest non-zero
+// TTL of any namespace.
+func (e *expirer) ttl(namespaces []string) time.Duration {
+	if len(namespaces) == 0 {
+		return e.config.DefaultTTL
+	}
+	var min time.Duration
+	for _, ns := range namespaces {
+		ttl := e.namespaceTTL(ns)
+		if ttl > 0 && (min == 0 || ttl < min) {
+			min = ttl
+		}
+	}
+	return min
+}
+
+func (e *expirer) namespaceTTL(namespace string) time.Duration {
+	for _, r := range e.rules {
+		if r.namespace.MatchString(namespace) {
+			return r.ttl
+		}
+	}
+	return e.config.DefaultTTL
+}
+
+// expire deletes all files which have outlived their TTL. The age of a file
+// is measured from its last modification, i.e. when its download completed.
+func (e *expirer) expire() error {
+	names, err := e.op.ListNames()
+	if err != nil {
+		return fmt.Errorf("list names: %s", err)
+	}
+	now := e.clk.Now()
+	for _, name := range names {
+		info, err := e.op.GetFileStat(name)
+		if err != nil {
+			continue
+		}
+		var ns metadata.Namespace
+		if err := e.op.GetFileMetadata(name, &ns); err != nil && !os.IsNotExist(err) {
+			log.With("name", name).Errorf("Error getting file namespace: %s", err)
+			continue
+		}
+		ttl := e.ttl(ns.Values)
+		if ttl == 0 || now.Sub(info.ModTime()) < ttl {
+			continue
+		}
+		if deleteUnpinned(e.op, e.pins, name) {
+			log.With("name", name, "namespaces", ns.Values).Info("Deleted expired file")
+			e.stats.Counter("expirations").Inc(1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store/metadata"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func TestExpirerDeletesExpiredFilesByNamespace(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+
+	state, op, cleanup := fileOpFixture(clk)
+	defer cleanup()
+
+	newFile := func(namespace string) string {
+		name := core.DigestFixture().Hex()
+		require.NoError(op.CreateFile(name, state, 10))
+		if namespace != "" {
+			_, err := op.SetFileMetadata(name, metadata.NewNamespace(namespace))
+			require.NoError(err)
+		}
+		return name
+	}
+	secure := newFile("security/base")
+	other := newFile("team/app")
+	unknown := newFile("")
+
+	e, err := newExpirer(ExpiryConfig{
+		Rules: []ExpiryRule{{Namespace: "^security/.*", TTL: time.Hour}},
+	}, clk, tally.NoopScope, op, newPins())
+	require.NoError(err)
+
+	require.NoError(e.expire())
+	for _, name := range []string{secure, other, unknown} {
+		_, err := op.GetFileStat(name)
+		require.NoError(err)
+	}
+
+	// Files expire regardless of access.
+	clk.Add(2 * time.Hour)
+	_, err = op.SetFileMetadata(secure, metadata.NewLastAccessTime(clk.Now()))
+	require.NoError(err)
+
+	require.NoError(e.expire())
+	_, err = op.GetFileStat(secure)
+	require.True(os.IsNotExist(err))
+	for _, name := range []string{other, unknown} {
+		_, err := op.GetFileStat(name)
+		require.NoError(err)
+	}
+}
+
+func TestExpirerUsesShortestNamespaceTTL(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+
+	state, op, cleanup := fileOpFixture(clk)
+	defer cleanup()
+
+	name := core.DigestFixture().Hex()
+	require.NoError(op.CreateFile(name, state, 10))
+	_, err := op.SetFileMetadata(name, metadata.NewNamespace("team/app", "security/base"))
+	require.NoError(err)
+
+	e, err := newExpirer(ExpiryConfig{
+		Rules: []ExpiryRule{
+			{Namespace: "^security/.*", TTL: time.Hour},
+			{Namespace: "^team/.*", TTL: 24 * time.Hour},
+		},
+	}, clk, tally.NoopScope, op, newPins())
+	require.NoError(err)
+
+	clk.Add(2 * time.Hour)
+	require.NoError(e.expire())
+	_, err = op.GetFileStat(name)
+	require.True(os.IsNotExist(err))
+}
+
+func TestExpirerDefaultTTL(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+
+	state, op, cleanup := fileOpFixture(clk)
+	defer cleanup()
+
+	name := core.DigestFixture().Hex()
+	require.NoError(op.CreateFile(name, state, 10))
+
+	e, err := newExpirer(ExpiryConfig{DefaultTTL: time.Hour}, clk, tally.NoopScope, op, newPins())
+	require.NoError(err)
+
+	clk.Add(2 * time.Hour)
+	require.NoError(e.expire())
+	_, err = op.GetFileStat(name)
+	require.True(os.IsNotExist(err))
+}
+
+func TestExpirerSkipsPinnedFiles(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+
+	state, op, cleanup := fileOpFixture(clk)
+	defer cleanup()
+
+	name := core.DigestFixture().Hex()
+	require.NoError(op.CreateFile(name, state, 10))
+
+	pins := newPins()
+	pins.pin(name)
+
+	e, err := newExpirer(ExpiryConfig{DefaultTTL: time.Hour}, clk, tally.NoopScope, op, pins)
+	require.NoError(err)
+
+	clk.Add(2 * time.Hour)
+	require.NoError(e.expire())
+	_, err = op.GetFileStat(name)
+	require.NoError(err)
+
+	pins.unpin(name)
+	require.NoError(e.expire())
+	_, err = op.GetFileStat(name)
+	require.True(os.IsNotExist(err))
+}
+
+func TestNewExpirerInvalidNamespace(t *testing.T) {
+	_, err := newExpirer(ExpiryConfig{
+		Rules: []ExpiryRule{{Namespace: "(", TTL: time.Hour}},
+	}, clock.NewMock(), tally.NoopScope, nil, newPins())
+	require.Error(t, err)
+}
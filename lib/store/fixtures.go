@@ -62,18 +62,29 @@ func CAStoreFixture() (*CAStore, func()) {
 	return s, cleanup.Run
 }
 
-// CADownloadStoreFixture returns a CADownloadStore for testing purposes.
-func CADownloadStoreFixture() (*CADownloadStore, func()) {
+// CADownloadStoreConfigFixture returns config for CADownloadStore for testing
+// purposes.
+func CADownloadStoreConfigFixture() (CADownloadStoreConfig, func()) {
 	cleanup := &testutil.Cleanup{}
 	defer cleanup.Recover()
 
 	download := tempdir(cleanup, "download")
 	cache := tempdir(cleanup, "cache")
 
-	config := CADownloadStoreConfig{
+	return CADownloadStoreConfig{
 		DownloadDir: download,
 		CacheDir:    cache,
-	}
+	}, cleanup.Run
+}
+
+// CADownloadStoreFixture returns a CADownloadStore for testing purposes.
+func CADownloadStoreFixture() (*CADownloadStore, func()) {
+	var cleanup testutil.Cleanup
+	defer cleanup.Recover()
+
+	config, c := CADownloadStoreConfigFixture()
+	cleanup.Add(c)
+
 	s, err := NewCADownloadStore(config, tally.NoopScope)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,86 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store/metadata"
+)
+
+// FsckReport summarizes the result of CADownloadStore.Fsck.
+type FsckReport struct {
+	// Verified is the number of cache files whose content was verified.
+	Verified int
+
+	// Corrupt are the names of cache files removed because their content did
+	// not match their digest.
+	Corrupt []string
+
+	// Orphaned are the names of files removed because they can neither be
+	// served nor resumed, i.e. files not named by a digest, or downloads
+	// without readable torrent metainfo.
+	Orphaned []string
+}
+
+// Fsck verifies the content of every cache file against its digest, and
+// removes corrupt and orphaned files. Fsck is intended to run while s is not
+// used by a running agent.
+func (s *CADownloadStore) Fsck() (FsckReport, error) {
+	var report FsckReport
+
+	names, err := s.ListCacheFileNames()
+	if err != nil {
+		return report, fmt.Errorf("list cache files: %s", err)
+	}
+	for _, name := range names {
+		if _, err := core.NewDigestFromHex(name); err != nil {
+			if err := s.Cache().DeleteFile(name); err != nil && !os.IsNotExist(err) {
+				return report, fmt.Errorf("delete cache file %s: %s", name, err)
+			}
+			report.Orphaned = append(report.Orphaned, name)
+			continue
+		}
+		if err := s.VerifyCacheFile(name); err != nil {
+			if os.IsNotExist(err) {
+				// Corrupt files are evicted by verification.
+				report.Corrupt = append(report.Corrupt, name)
+				continue
+			}
+			return report, fmt.Errorf("verify cache file %s: %s", name, err)
+		}
+		report.Verified++
+	}
+
+	names, err = s.ListDownloadFileNames()
+	if err != nil {
+		return report, fmt.Errorf("list download files: %s", err)
+	}
+	for _, name := range names {
+		if _, err := core.NewDigestFromHex(name); err == nil {
+			// Downloads cannot be resumed without readable metainfo.
+			var tm metadata.TorrentMeta
+			if err := s.Download().GetMetadata(name, &tm); err == nil {
+				continue
+			}
+		}
+		if err := s.Download().DeleteFile(name); err != nil && !os.IsNotExist(err) {
+			return report, fmt.Errorf("delete download file %s: %s", name, err)
+		}
+		report.Orphaned = append(report.Orphaned, name)
+	}
+	return report, nil
+}
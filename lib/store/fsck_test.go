@@ -0,0 +1,74 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"os"
+	"testing"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCADownloadStoreFsck(t *testing.T) {
+	require := require.New(t)
+
+	s, cleanup := CADownloadStoreFixture()
+	defer cleanup()
+
+	good := core.NewBlobFixture()
+	require.NoError(RunDownload(s, good.Digest, good.Content))
+
+	corrupt := core.DigestFixture()
+	require.NoError(RunDownload(s, corrupt, []byte("some corrupt content")))
+
+	// Download with metainfo, which can be resumed.
+	resumable := core.NewBlobFixture()
+	require.NoError(s.CreateDownloadFile(resumable.Digest.Hex(), resumable.Length()))
+	_, err := s.Download().SetMetadata(
+		resumable.Digest.Hex(), metadata.NewTorrentMeta(resumable.MetaInfo))
+	require.NoError(err)
+
+	// Download without metainfo, which cannot be resumed.
+	orphan := core.DigestFixture()
+	require.NoError(s.CreateDownloadFile(orphan.Hex(), 1))
+
+	report, err := s.Fsck()
+	require.NoError(err)
+	require.Equal(1, report.Verified)
+	require.Equal([]string{corrupt.Hex()}, report.Corrupt)
+	require.Equal([]string{orphan.Hex()}, report.Orphaned)
+
+	_, err = s.Cache().GetFileStat(good.Digest.Hex())
+	require.NoError(err)
+	_, err = s.Cache().GetFileStat(corrupt.Hex())
+	require.True(os.IsNotExist(err))
+	_, err = s.Download().GetFileStat(resumable.Digest.Hex())
+	require.NoError(err)
+	_, err = s.Download().GetFileStat(orphan.Hex())
+	require.True(os.IsNotExist(err))
+}
+
+func TestCADownloadStoreFsckEmpty(t *testing.T) {
+	require := require.New(t)
+
+	s, cleanup := CADownloadStoreFixture()
+	defer cleanup()
+
+	report, err := s.Fsck()
+	require.NoError(err)
+	require.Equal(FsckReport{}, report)
+}
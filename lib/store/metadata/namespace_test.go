@@ -0,0 +1,41 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceMetadataSerialization(t *testing.T) {
+	require := require.New(t)
+
+	n := NewNamespace("security/base-image", "uber/foo")
+	b, err := n.Serialize()
+	require.NoError(err)
+
+	var result Namespace
+	require.NoError(result.Deserialize(b))
+	require.Equal(n.Values, result.Values)
+}
+
+func TestNamespaceMetadataAdd(t *testing.T) {
+	require := require.New(t)
+
+	n := NewNamespace("security/base-image")
+	require.True(n.Add("uber/foo"))
+	require.False(n.Add("security/base-image"))
+	require.Equal([]string{"security/base-image", "uber/foo"}, n.Values)
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package metadata
+
+import (
+	"regexp"
+	"strconv"
+)
+
+const _pushRefsSuffix = "_push_refs"
+
+func init() {
+	Register(regexp.MustCompile(_pushRefsSuffix), &pushRefsFactory{})
+}
+
+type pushRefsFactory struct{}
+
+func (f pushRefsFactory) Create(suffix string) Metadata {
+	return &PushRefs{}
+}
+
+// PushRefs marks a blob pushed to an agent, and counts the pending tag pushes
+// which reference it. A blob with zero refs has been uploaded but not yet
+// tagged.
+type PushRefs struct {
+	Count int
+}
+
+// NewPushRefs creates a new PushRefs.
+func NewPushRefs(count int) *PushRefs {
+	return &PushRefs{count}
+}
+
+// GetSuffix returns a static suffix.
+func (m *PushRefs) GetSuffix() string {
+	return _pushRefsSuffix
+}
+
+// Movable is true.
+func (m *PushRefs) Movable() bool {
+	return true
+}
+
+// Serialize converts m to bytes.
+func (m *PushRefs) Serialize() ([]byte, error) {
+	return []byte(strconv.Itoa(m.Count)), nil
+}
+
+// Deserialize loads b into m.
+func (m *PushRefs) Deserialize(b []byte) error {
+	v, err := strconv.Atoi(string(b))
+	if err != nil {
+		return err
+	}
+	m.Count = v
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/uber/kraken/lib/store/base"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
+)
+
+// QuarantineConfig defines quarantining of cache files which fail
+// verification. Quarantined files are moved aside instead of deleted, such that
+// they can be inspected to find the source of corruption.
+type QuarantineConfig struct {
+	// Enabled moves corrupt files into Dir. Corrupt files are deleted if not
+	// set.
+	Enabled bool `yaml:"enabled"`
+
+	// Dir is the directory corrupt files are moved to. Should be on the same
+	// disk as the cache directory, else files are copied. Required if enabled.
+	Dir string `yaml:"dir"`
+
+	// MaxSize is the max total size of quarantined files in bytes. The oldest
+	// files are deleted once exceeded, and files larger than MaxSize are never
+	// quarantined.
+	MaxSize int64 `yaml:"max_size"`
+}
+
+func (c QuarantineConfig) applyDefaults() QuarantineConfig {
+	if c.MaxSize == 0 {
+		c.MaxSize = 1 << 30 // 1GB
+	}
+	return c
+}
+
+// quarantine holds cache files which failed verification.
+type quarantine struct {
+	config QuarantineConfig
+	clk    clock.Clock
+	stats  tally.Scope
+
+	// mu serializes enforcement of the size cap.
+	mu sync.Mutex
+}
+
+func newQuarantine(config QuarantineConfig, clk clock.Clock, stats tally.Scope) (*quarantine, error) {
+	config = config.applyDefaults()
+	if config.Enabled {
+		if config.Dir == "" {
+			return nil, errors.New("dir is required")
+		}
+		if err := os.MkdirAll(config.Dir, 0775); err != nil {
+			return nil, fmt.Errorf("mkdir: %s", err)
+		}
+	}
+	return &quarantine{config: config, clk: clk, stats: stats}, nil
+}
+
+// remove removes file name of op, quarantining it if enabled.
+func (q *quarantine) remove(op base.FileOp, name string) error {
+	if q.config.Enabled {
+		if err := q.add(op, name); err != nil {
+			log.With("name", name).Errorf("Error quarantining corrupt file, deleting: %s", err)
+		}
+	}
+	return op.DeleteFile(name)
+}
+
+// add copies file name of op into the quarantine directory, evicting the
+// oldest quarantined files if the size cap is exceeded.
+func (q *quarantine) add(op base.FileOp, name string) error {
+	info, err := op.GetFileStat(name)
+	if err != nil {
+		return fmt.Errorf("stat: %s", err)
+	}
+	if info.Size() > q.config.MaxSize {
+		return fmt.Errorf("size %d exceeds max size %d", info.Size(), q.config.MaxSize)
+	}
+	src, err := op.GetFilePath(name)
+	if err != nil {
+		return fmt.Errorf("get path: %s", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Files are suffixed with the time of quarantine, such that repeated
+	// corruption of the same blob is kept.
+	now := q.clk.Now()
+	dst := filepath.Join(q.config.Dir, fmt.Sprintf("%s.%d", name, now.UnixNano()))
+	if err := os.Link(src, dst); err != nil {
+		if err := copyFile(src, dst); err != nil {
+			return fmt.Errorf("copy: %s", err)
+		}
+	}
+	// Hard links keep the modification time of the source, which is used to
+	// order files for eviction.
+	if err := os.Chtimes(dst, now, now); err != nil {
+		return fmt.Errorf("chtimes: %s", err)
+	}
+	q.stats.Counter("quarantined").Inc(1)
+	log.With("name", name, "path", dst).Warn("Quarantined corrupt file")
+
+	return q.enforceMaxSize()
+}
+
+// enforceMaxSize deletes the oldest quarantined files until the total size of
+// the quarantine directory is within the max size.
+func (q *quarantine) enforceMaxSize() error {
+	infos, err := ioutil.ReadDir(q.config.Dir)
+	if err != nil {
+		return fmt.Errorf("read dir: %s", err)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	var total int64
+	for _, info := range infos {
+		total += info.Size()
+	}
+	for _, info := range infos {
+		if total <= q.config.MaxSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(q.config.Dir, info.Name())); err != nil {
+			return fmt.Errorf("remove: %s", err)
+		}
+		total -= info.Size()
+		q.stats.Counter("quarantine_evictions").Inc(1)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func readQuarantine(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+
+	infos, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	files := make(map[string][]byte)
+	for _, info := range infos {
+		b, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+		require.NoError(t, err)
+		files[info.Name()] = b
+	}
+	return files
+}
+
+func TestCADownloadStoreQuarantinesCorruptFiles(t *testing.T) {
+	require := require.New(t)
+
+	var cleanup testutil.Cleanup
+	defer cleanup.Run()
+
+	config, c := CADownloadStoreConfigFixture()
+	cleanup.Add(c)
+
+	config.Quarantine = QuarantineConfig{Enabled: true, Dir: tempdir(&cleanup, "quarantine")}
+
+	s, err := NewCADownloadStore(config, tally.NoopScope)
+	require.NoError(err)
+	defer s.Close()
+
+	corrupt := core.DigestFixture()
+	content := []byte("some corrupt content")
+	require.NoError(RunDownload(s, corrupt, content))
+
+	require.True(os.IsNotExist(s.VerifyCacheFile(corrupt.Hex())))
+
+	_, err = s.Cache().GetFileStat(corrupt.Hex())
+	require.True(os.IsNotExist(err))
+
+	files := readQuarantine(t, config.Quarantine.Dir)
+	require.Len(files, 1)
+	for name, b := range files {
+		require.True(strings.HasPrefix(name, corrupt.Hex()+"."))
+		require.Equal(content, b)
+	}
+}
+
+func TestCADownloadStoreDeletesCorruptFilesWhenQuarantineDisabled(t *testing.T) {
+	require := require.New(t)
+
+	s, cleanup := CADownloadStoreFixture()
+	defer cleanup()
+
+	corrupt := core.DigestFixture()
+	require.NoError(RunDownload(s, corrupt, []byte("some corrupt content")))
+
+	require.True(os.IsNotExist(s.VerifyCacheFile(corrupt.Hex())))
+
+	_, err := s.Cache().GetFileStat(corrupt.Hex())
+	require.True(os.IsNotExist(err))
+}
+
+func TestNewQuarantineRequiresDir(t *testing.T) {
+	_, err := newQuarantine(QuarantineConfig{Enabled: true}, clock.New(), tally.NoopScope)
+	require.Error(t, err)
+}
+
+func TestQuarantineMaxSize(t *testing.T) {
+	require := require.New(t)
+
+	var cleanup testutil.Cleanup
+	defer cleanup.Run()
+
+	config, c := CADownloadStoreConfigFixture()
+	cleanup.Add(c)
+
+	config.Quarantine = QuarantineConfig{
+		Enabled: true,
+		Dir:     tempdir(&cleanup, "quarantine"),
+		MaxSize: 10,
+	}
+
+	s, err := NewCADownloadStore(config, tally.NoopScope)
+	require.NoError(err)
+	defer s.Close()
+
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+	s.quarantine.clk = clk
+
+	quarantine := func(content string) core.Digest {
+		d := core.DigestFixture()
+		require.NoError(RunDownload(s, d, []byte(content)))
+		require.True(os.IsNotExist(s.VerifyCacheFile(d.Hex())))
+		clk.Add(time.Second)
+		return d
+	}
+
+	d1 := quarantine("aaaa")
+	d2 := quarantine("bbbb")
+	require.Len(readQuarantine(t, config.Quarantine.Dir), 2)
+
+	// Exceeds the max size, so the oldest file is evicted.
+	d3 := quarantine("cccc")
+	files := readQuarantine(t, config.Quarantine.Dir)
+	require.Len(files, 2)
+	for name := range files {
+		require.False(strings.HasPrefix(name, d1.Hex()))
+		require.True(strings.HasPrefix(name, d2.Hex()) || strings.HasPrefix(name, d3.Hex()))
+	}
+
+	// Files larger than the max size are deleted without being quarantined.
+	d4 := quarantine("some content larger than max size")
+	_, err = s.Cache().GetFileStat(d4.Hex())
+	require.True(os.IsNotExist(err))
+	require.Len(readQuarantine(t, config.Quarantine.Dir), 2)
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store/metadata"
+)
+
+// errTransferCorrupt occurs when copied content does not match its digest.
+var errTransferCorrupt = errors.New("content does not match digest")
+
+// TransferReport summarizes the result of CADownloadStore.TransferTo.
+type TransferReport struct {
+	// Copied are the names of cache files copied to the destination store.
+	Copied []string
+
+	// Skipped are the names of cache files which the destination store
+	// already had, e.g. from an interrupted transfer.
+	Skipped []string
+
+	// Corrupt are the names of cache files not copied because their content
+	// did not match their digest.
+	Corrupt []string
+}
+
+// TransferTo copies every cache file of s, along with its metadata, e.g. pins
+// and last access times, and its modification time into the cache of dst.
+// Copied content is verified against its digest before it becomes visible in
+// dst. Files already cached in dst are skipped, such that an interrupted
+// transfer can be resumed by running it again. Neither store may be used by a
+// running agent during the transfer, and s should be opened without eviction
+// and expiry, which could delete files mid-transfer.
+func (s *CADownloadStore) TransferTo(dst *CADownloadStore) (TransferReport, error) {
+	var report TransferReport
+
+	names, err := s.ListCacheFileNames()
+	if err != nil {
+		return report, fmt.Errorf("list cache files: %s", err)
+	}
+	for _, name := range names {
+		d, err := core.NewDigestFromHex(name)
+		if err != nil {
+			// Not content-addressed, cannot be verified.
+			continue
+		}
+		if _, err := dst.Cache().GetFileStat(name); err == nil {
+			report.Skipped = append(report.Skipped, name)
+			continue
+		}
+		if err := s.transferFile(dst, d); err != nil {
+			if err == errTransferCorrupt {
+				report.Corrupt = append(report.Corrupt, name)
+				continue
+			}
+			return report, fmt.Errorf("transfer %s: %s", name, err)
+		}
+		report.Copied = append(report.Copied, name)
+	}
+	return report, nil
+}
+
+// transferFile copies cache file d of s into a download file of dst, and moves
+// it into the cache of dst once its content and metadata are written.
+func (s *CADownloadStore) transferFile(dst *CADownloadStore, d core.Digest) error {
+	name := d.Hex()
+
+	// Metadata is read before content, such that reading the content does not
+	// bump the last access time being preserved. Ranging only yields empty
+	// metadata of each type, which must be loaded separately.
+	op := s.states().cache().op
+	var mds []metadata.Metadata
+	err := op.RangeFileMetadata(name, func(md metadata.Metadata) error {
+		mds = append(mds, md)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("range metadata: %s", err)
+	}
+	for _, md := range mds {
+		if err := op.GetFileMetadata(name, md); err != nil {
+			return fmt.Errorf("get metadata: %s", err)
+		}
+	}
+
+	// TTLs are enforced against the modification time of the content.
+	info, err := op.GetFileStat(name)
+	if err != nil {
+		return fmt.Errorf("stat cache file: %s", err)
+	}
+
+	f, err := s.states().cache().GetFileReader(name)
+	if err != nil {
+		return fmt.Errorf("get cache file reader: %s", err)
+	}
+	defer f.Close()
+
+	// Partial download files are left behind by interrupted transfers.
+	if err := dst.Download().DeleteFile(name); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete partial download file: %s", err)
+	}
+	if err := dst.CreateDownloadFile(name, f.Size()); err != nil {
+		return fmt.Errorf("create download file: %s", err)
+	}
+	w, err := dst.GetDownloadFileReadWriter(name)
+	if err != nil {
+		return fmt.Errorf("get download file writer: %s", err)
+	}
+	defer w.Close()
+
+	digester := d.Digester()
+	if _, err := io.Copy(w, digester.Tee(f)); err != nil {
+		return fmt.Errorf("copy: %s", err)
+	}
+	if digester.Digest() != d {
+		if err := dst.Download().DeleteFile(name); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("delete corrupt download file: %s", err)
+		}
+		return errTransferCorrupt
+	}
+	for _, md := range mds {
+		if _, err := dst.Download().SetMetadata(name, md); err != nil {
+			return fmt.Errorf("set metadata: %s", err)
+		}
+	}
+	path, err := dst.Download().op.GetFilePath(name)
+	if err != nil {
+		return fmt.Errorf("get download file path: %s", err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("chtimes: %s", err)
+	}
+	if err := dst.MoveDownloadFileToCache(name); err != nil {
+		return fmt.Errorf("move download file to cache: %s", err)
+	}
+	return nil
+}
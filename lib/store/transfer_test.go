@@ -0,0 +1,126 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCADownloadStoreTransferTo(t *testing.T) {
+	require := require.New(t)
+
+	src, cleanup := CADownloadStoreFixture()
+	defer cleanup()
+
+	dst, cleanup := CADownloadStoreFixture()
+	defer cleanup()
+
+	blob := core.NewBlobFixture()
+	require.NoError(RunDownload(src, blob.Digest, blob.Content))
+	_, err := src.Cache().SetMetadata(blob.Digest.Hex(), metadata.NewPersist(true))
+	require.NoError(err)
+	lat := time.Unix(1000, 0)
+	_, err = src.Cache().SetMetadata(blob.Digest.Hex(), metadata.NewLastAccessTime(lat))
+	require.NoError(err)
+	path, err := src.Cache().op.GetFilePath(blob.Digest.Hex())
+	require.NoError(err)
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(os.Chtimes(path, mtime, mtime))
+
+	corrupt := core.DigestFixture()
+	require.NoError(RunDownload(src, corrupt, []byte("some corrupt content")))
+
+	report, err := src.TransferTo(dst)
+	require.NoError(err)
+	require.Equal([]string{blob.Digest.Hex()}, report.Copied)
+	require.Empty(report.Skipped)
+	require.Equal([]string{corrupt.Hex()}, report.Corrupt)
+
+	f, err := dst.GetCacheFileReader(blob.Digest.Hex())
+	require.NoError(err)
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	require.NoError(err)
+	require.Equal(blob.Content, content)
+
+	var persist metadata.Persist
+	require.NoError(dst.Cache().GetMetadata(blob.Digest.Hex(), &persist))
+	require.True(persist.Value)
+
+	var actual metadata.LastAccessTime
+	require.NoError(dst.Cache().GetMetadata(blob.Digest.Hex(), &actual))
+	require.Equal(lat.Unix(), actual.Time.Unix())
+
+	info, err := dst.Cache().GetFileStat(blob.Digest.Hex())
+	require.NoError(err)
+	require.True(mtime.Equal(info.ModTime()))
+
+	_, err = dst.Cache().GetFileStat(corrupt.Hex())
+	require.True(os.IsNotExist(err))
+	_, err = dst.Download().GetFileStat(corrupt.Hex())
+	require.True(os.IsNotExist(err))
+}
+
+func TestCADownloadStoreTransferToResumes(t *testing.T) {
+	require := require.New(t)
+
+	src, cleanup := CADownloadStoreFixture()
+	defer cleanup()
+
+	dst, cleanup := CADownloadStoreFixture()
+	defer cleanup()
+
+	done := core.NewBlobFixture()
+	require.NoError(RunDownload(src, done.Digest, done.Content))
+	require.NoError(RunDownload(dst, done.Digest, done.Content))
+
+	// Partial copy left behind by an interrupted transfer.
+	partial := core.NewBlobFixture()
+	require.NoError(RunDownload(src, partial.Digest, partial.Content))
+	require.NoError(dst.CreateDownloadFile(partial.Digest.Hex(), 1))
+
+	report, err := src.TransferTo(dst)
+	require.NoError(err)
+	require.Equal([]string{partial.Digest.Hex()}, report.Copied)
+	require.Equal([]string{done.Digest.Hex()}, report.Skipped)
+
+	f, err := dst.GetCacheFileReader(partial.Digest.Hex())
+	require.NoError(err)
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	require.NoError(err)
+	require.Equal(partial.Content, content)
+}
+
+func TestCADownloadStoreTransferToEmpty(t *testing.T) {
+	require := require.New(t)
+
+	src, cleanup := CADownloadStoreFixture()
+	defer cleanup()
+
+	dst, cleanup := CADownloadStoreFixture()
+	defer cleanup()
+
+	report, err := src.TransferTo(dst)
+	require.NoError(err)
+	require.Equal(TransferReport{}, report)
+}
@@ -13,8 +13,35 @@
 // limitations under the License.
 package networkevent
 
+import "fmt"
+
 // Config defines network event configuration.
 type Config struct {
 	LogPath string `yaml:"log_path"`
 	Enabled bool   `yaml:"enabled"`
+
+	// DisabledEvents lists events which are never recorded, e.g. high-volume
+	// piece events.
+	DisabledEvents []Name `yaml:"disabled_events"`
+
+	// SampleRates records only 1 in N events of each listed event name.
+	// Events which are not listed are always recorded.
+	SampleRates map[Name]int `yaml:"sample_rates"`
+}
+
+func (c Config) validate() error {
+	for _, name := range c.DisabledEvents {
+		if !_names[name] {
+			return fmt.Errorf("unknown disabled event %q", name)
+		}
+	}
+	for name, rate := range c.SampleRates {
+		if !_names[name] {
+			return fmt.Errorf("unknown sampled event %q", name)
+		}
+		if rate < 1 {
+			return fmt.Errorf("sample rate of %s must be positive, got %d", name, rate)
+		}
+	}
+	return nil
 }
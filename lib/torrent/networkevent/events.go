@@ -36,8 +36,21 @@ const (
 	ReceivePiece     Name = "receive_piece"
 	TorrentComplete  Name = "torrent_complete"
 	TorrentCancelled Name = "torrent_cancelled"
+	Announce         Name = "announce"
 )
 
+var _names = map[Name]bool{
+	AddTorrent:       true,
+	AddActiveConn:    true,
+	DropActiveConn:   true,
+	BlacklistConn:    true,
+	RequestPiece:     true,
+	ReceivePiece:     true,
+	TorrentComplete:  true,
+	TorrentCancelled: true,
+	Announce:         true,
+}
+
 // Event consolidates all possible event fields.
 type Event struct {
 	Name    Name      `json:"event"`
@@ -51,6 +64,7 @@ type Event struct {
 	Bitfield     []bool `json:"bitfield,omitempty"`
 	DurationMS   int64  `json:"duration_ms,omitempty"`
 	ConnCapacity int    `json:"conn_capacity,omitempty"`
+	NumPeers     int    `json:"num_peers,omitempty"`
 }
 
 func baseEvent(name Name, h core.InfoHash, self core.PeerID) *Event {
@@ -131,3 +145,10 @@ func TorrentCompleteEvent(h core.InfoHash, self core.PeerID) *Event {
 func TorrentCancelledEvent(h core.InfoHash, self core.PeerID) *Event {
 	return baseEvent(TorrentCancelled, h, self)
 }
+
+// AnnounceEvent returns an event for an announce response listing numPeers peers.
+func AnnounceEvent(h core.InfoHash, self core.PeerID, numPeers int) *Event {
+	e := baseEvent(Announce, h, self)
+	e.NumPeers = numPeers
+	return e
+}
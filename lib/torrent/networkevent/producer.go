@@ -18,6 +18,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync/atomic"
 
 	"github.com/uber/kraken/utils/log"
 )
@@ -29,13 +30,17 @@ type Producer interface {
 }
 
 type producer struct {
-	file *os.File
+	file    *os.File
+	sampler *sampler
 }
 
 // NewProducer creates a new Producer.
 func NewProducer(config Config) (Producer, error) {
 	var f *os.File
 	if config.Enabled {
+		if err := config.validate(); err != nil {
+			return nil, fmt.Errorf("invalid config: %s", err)
+		}
 		if config.LogPath == "" {
 			return nil, errors.New("no log path supplied")
 		}
@@ -57,12 +62,12 @@ func NewProducer(config Config) (Producer, error) {
 	} else {
 		log.Warn("Kafka network events disabled")
 	}
-	return &producer{f}, nil
+	return &producer{f, newSampler(config)}, nil
 }
 
 // Produce emits a network event.
 func (p *producer) Produce(e *Event) {
-	if p.file == nil {
+	if p.file == nil || !p.sampler.sample(e.Name) {
 		return
 	}
 	b, err := json.Marshal(e)
@@ -84,3 +89,39 @@ func (p *producer) Close() error {
 	}
 	return p.file.Close()
 }
+
+// sampler decides which events are recorded.
+type sampler struct {
+	disabled map[Name]bool
+	rates    map[Name]uint64
+	counts   map[Name]*uint64
+}
+
+func newSampler(config Config) *sampler {
+	s := &sampler{
+		disabled: make(map[Name]bool),
+		rates:    make(map[Name]uint64),
+		counts:   make(map[Name]*uint64),
+	}
+	for _, name := range config.DisabledEvents {
+		s.disabled[name] = true
+	}
+	for name, rate := range config.SampleRates {
+		s.rates[name] = uint64(rate)
+		s.counts[name] = new(uint64)
+	}
+	return s
+}
+
+// sample returns true if an event of name should be recorded. Sampled events
+// are recorded deterministically, starting with the first.
+func (s *sampler) sample(name Name) bool {
+	if s.disabled[name] {
+		return false
+	}
+	rate, ok := s.rates[name]
+	if !ok || rate <= 1 {
+		return true
+	}
+	return (atomic.AddUint64(s.counts[name], 1)-1)%rate == 0
+}
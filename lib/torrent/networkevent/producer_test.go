@@ -93,3 +93,76 @@ func TestDisabledProducerNoops(t *testing.T) {
 
 	p.Produce(ReceivePieceEvent(h, peer1, peer2, 1))
 }
+
+func readEvents(t *testing.T, path string) []*Event {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var events []*Event
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		e := new(Event)
+		require.NoError(t, json.Unmarshal(s.Bytes(), e))
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestProducerSamplesAndDisablesEvents(t *testing.T) {
+	require := require.New(t)
+
+	h := core.InfoHashFixture()
+	peer1 := core.PeerIDFixture()
+	peer2 := core.PeerIDFixture()
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	config := Config{
+		Enabled:        true,
+		LogPath:        filepath.Join(dir, "netevents"),
+		DisabledEvents: []Name{RequestPiece},
+		SampleRates:    map[Name]int{ReceivePiece: 3},
+	}
+
+	p, err := NewProducer(config)
+	require.NoError(err)
+	for i := 0; i < 6; i++ {
+		p.Produce(RequestPieceEvent(h, peer1, peer2, i))
+		p.Produce(ReceivePieceEvent(h, peer1, peer2, i))
+	}
+	p.Produce(AddActiveConnEvent(h, peer1, peer2))
+	require.NoError(p.Close())
+
+	expected := []*Event{
+		ReceivePieceEvent(h, peer1, peer2, 0),
+		ReceivePieceEvent(h, peer1, peer2, 3),
+		AddActiveConnEvent(h, peer1, peer2),
+	}
+	require.Equal(StripTimestamps(expected), StripTimestamps(readEvents(t, config.LogPath)))
+}
+
+func TestNewProducerInvalidConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		desc   string
+		config Config
+	}{
+		{"unknown disabled event", Config{DisabledEvents: []Name{"foo"}}},
+		{"unknown sampled event", Config{SampleRates: map[Name]int{"foo": 2}}},
+		{"zero sample rate", Config{SampleRates: map[Name]int{ReceivePiece: 0}}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			test.config.Enabled = true
+			test.config.LogPath = filepath.Join(dir, "netevents")
+			_, err := NewProducer(test.config)
+			require.Error(t, err)
+		})
+	}
+}
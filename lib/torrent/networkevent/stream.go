@@ -0,0 +1,95 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package networkevent
+
+import "sync"
+
+// _subscriberBuffer is the number of events buffered for each subscriber
+// before further events are dropped.
+const _subscriberBuffer = 256
+
+// Stream is a Producer which publishes events to live subscribers, in addition
+// to the Producer it wraps.
+type Stream struct {
+	producer Producer
+
+	mu   sync.Mutex
+	subs map[*subscription]struct{}
+}
+
+type subscription struct {
+	torrent string
+	events  chan *Event
+	dropped int
+}
+
+// NewStream creates a new Stream which forwards all events to p.
+func NewStream(p Producer) *Stream {
+	return &Stream{
+		producer: p,
+		subs:     make(map[*subscription]struct{}),
+	}
+}
+
+// Produce emits e to the wrapped producer and all matching subscribers.
+// Subscribers which fall behind miss events instead of blocking e.
+func (s *Stream) Produce(e *Event) {
+	s.producer.Produce(e)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sub := range s.subs {
+		if sub.torrent != "" && sub.torrent != e.Torrent {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// Subscribe returns a channel which receives events of torrent, or all events
+// if torrent is empty. The returned function ends the subscription and closes
+// the channel, returning the number of events dropped because the channel was
+// full.
+func (s *Stream) Subscribe(torrent string) (<-chan *Event, func() int) {
+	sub := &subscription{
+		torrent: torrent,
+		events:  make(chan *Event, _subscriberBuffer),
+	}
+
+	s.mu.Lock()
+	s.subs[sub] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return sub.events, func() int {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		once.Do(func() {
+			delete(s.subs, sub)
+			close(sub.events)
+		})
+		return sub.dropped
+	}
+}
+
+// Close closes the wrapped producer.
+func (s *Stream) Close() error {
+	return s.producer.Close()
+}
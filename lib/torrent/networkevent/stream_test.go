@@ -0,0 +1,83 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package networkevent
+
+import (
+	"testing"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamForwardsToProducer(t *testing.T) {
+	p := NewTestProducer()
+	s := NewStream(p)
+
+	e := TorrentCompleteEvent(core.InfoHashFixture(), core.PeerIDFixture())
+	s.Produce(e)
+
+	require.Equal(t, []*Event{e}, p.Events())
+}
+
+func TestStreamSubscribeFiltersByTorrent(t *testing.T) {
+	require := require.New(t)
+
+	s := NewStream(NewTestProducer())
+
+	h1 := core.InfoHashFixture()
+	h2 := core.InfoHashFixture()
+	self := core.PeerIDFixture()
+
+	events, unsubscribe := s.Subscribe(h1.String())
+	all, unsubscribeAll := s.Subscribe("")
+
+	e1 := TorrentCompleteEvent(h1, self)
+	e2 := TorrentCompleteEvent(h2, self)
+	s.Produce(e1)
+	s.Produce(e2)
+
+	require.Equal(0, unsubscribe())
+	require.Equal(0, unsubscribeAll())
+
+	var result []*Event
+	for e := range events {
+		result = append(result, e)
+	}
+	require.Equal([]*Event{e1}, result)
+
+	result = nil
+	for e := range all {
+		result = append(result, e)
+	}
+	require.Equal([]*Event{e1, e2}, result)
+}
+
+func TestStreamDropsEventsForSlowSubscribers(t *testing.T) {
+	require := require.New(t)
+
+	s := NewStream(NewTestProducer())
+
+	_, unsubscribe := s.Subscribe("")
+
+	h := core.InfoHashFixture()
+	self := core.PeerIDFixture()
+	for i := 0; i < _subscriberBuffer+10; i++ {
+		s.Produce(ReceivePieceEvent(h, self, self, i))
+	}
+	require.Equal(10, unsubscribe())
+
+	// Unsubscribing is idempotent.
+	require.Equal(10, unsubscribe())
+}
@@ -14,6 +14,7 @@
 package announcer
 
 import (
+	"math/rand"
 	"time"
 
 	"github.com/uber/kraken/core"
@@ -28,6 +29,13 @@ import (
 type Config struct {
 	DefaultInterval time.Duration `yaml:"default_interval"`
 	MaxInterval     time.Duration `yaml:"max_interval"`
+
+	// Jitter randomizes every announce interval by up to the given fraction in
+	// either direction, e.g. 0.1 spreads a 5s interval across [4.5s, 5.5s].
+	// When set, the first announce is also delayed by a random duration within
+	// the default interval, such that agents which start at the same time do
+	// not announce in lockstep. Zero disables jitter.
+	Jitter float64 `yaml:"jitter"`
 }
 
 func (c Config) applyDefaults() Config {
@@ -37,6 +45,12 @@ func (c Config) applyDefaults() Config {
 	if c.MaxInterval == 0 {
 		c.MaxInterval = time.Minute
 	}
+	if c.Jitter < 0 {
+		c.Jitter = 0
+	}
+	if c.Jitter > 1 {
+		c.Jitter = 1
+	}
 	return c
 }
 
@@ -48,12 +62,13 @@ type Events interface {
 // Announcer is a thin wrapper around an announceclient.Client which handles
 // changes to the announce interval.
 type Announcer struct {
-	config   Config
-	client   announceclient.Client
-	events   Events
-	interval *atomic.Int64
-	timer    *clock.Timer
-	logger   *zap.SugaredLogger
+	config          Config
+	client          announceclient.Client
+	events          Events
+	defaultInterval *atomic.Int64
+	interval        *atomic.Int64
+	timer           *clock.Timer
+	logger          *zap.SugaredLogger
 }
 
 // New creates a new Announcer.
@@ -64,18 +79,22 @@ func New(
 	clk clock.Clock,
 	logger *zap.SugaredLogger) *Announcer {
 	config = config.applyDefaults()
+	first := config.DefaultInterval
+	if config.Jitter > 0 {
+		first = time.Duration(rand.Int63n(int64(config.DefaultInterval)) + 1)
+	}
 	return &Announcer{
-		config:   config,
-		client:   client,
-		events:   events,
-		interval: atomic.NewInt64(int64(config.DefaultInterval)),
-		timer:    clk.Timer(config.DefaultInterval),
-		logger:   logger,
+		config:          config,
+		client:          client,
+		events:          events,
+		defaultInterval: atomic.NewInt64(int64(config.DefaultInterval)),
+		interval:        atomic.NewInt64(int64(config.DefaultInterval)),
+		timer:           clk.Timer(first),
+		logger:          logger,
 	}
 }
 
 // Default creates a default Announcer.
-// TODO(evelynl94): make announce interval configurable.
 func Default(
 	client announceclient.Client,
 	events Events,
@@ -95,13 +114,13 @@ func (a *Announcer) Announce(
 	}
 	if interval == 0 {
 		// Protect against unset intervals.
-		interval = a.config.DefaultInterval
+		interval = time.Duration(a.defaultInterval.Load())
 	}
 	if interval > a.config.MaxInterval {
 		// Since the timer is only reset on ticks, a wildly high interval can lock
 		// down future updates to interval. The max interval protects against a
 		// mistake in the central authority which will become impossible to correct.
-		interval = a.config.DefaultInterval
+		interval = time.Duration(a.defaultInterval.Load())
 	}
 	if a.interval.Swap(int64(interval)) != int64(interval) {
 		// Note: updated interval will take effect after next tick.
@@ -110,6 +129,14 @@ func (a *Announcer) Announce(
 	return peers, nil
 }
 
+// SetDefaultInterval changes the default announce interval, which is used
+// until the next announce returns an interval from the tracker. Like intervals
+// updated by Announce, the change takes effect after the next tick.
+func (a *Announcer) SetDefaultInterval(interval time.Duration) {
+	a.defaultInterval.Store(int64(interval))
+	a.interval.Store(int64(interval))
+}
+
 // Ticker emits AnnounceTick events at the current announce interval, which may be
 // updated by Announce. Ticker exits when done is closed.
 func (a *Announcer) Ticker(done <-chan struct{}) {
@@ -117,9 +144,18 @@ func (a *Announcer) Ticker(done <-chan struct{}) {
 		select {
 		case <-a.timer.C:
 			a.events.AnnounceTick()
-			a.timer.Reset(time.Duration(a.interval.Load()))
+			a.timer.Reset(a.jitter(time.Duration(a.interval.Load())))
 		case <-done:
 			return
 		}
 	}
 }
+
+// jitter randomizes d by up to the configured jitter fraction.
+func (a *Announcer) jitter(d time.Duration) time.Duration {
+	if a.config.Jitter == 0 {
+		return d
+	}
+	delta := (2*rand.Float64() - 1) * a.config.Jitter * float64(d)
+	return d + time.Duration(delta)
+}
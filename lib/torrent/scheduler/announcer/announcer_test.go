@@ -115,6 +115,44 @@ func TestAnnouncerAnnounceUpdatesInterval(t *testing.T) {
 	mocks.events.expectTick(t)
 }
 
+func TestAnnouncerSetDefaultInterval(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newAnnouncerMocks(t)
+	defer cleanup()
+
+	config := Config{DefaultInterval: 5 * time.Second}
+
+	announcer := mocks.newAnnouncer(config)
+
+	go announcer.Ticker(nil)
+
+	interval := 10 * time.Second
+	announcer.SetDefaultInterval(interval)
+
+	mocks.clk.Add(config.DefaultInterval)
+	mocks.events.expectTick(t)
+
+	// Timer should have been reset to new default interval now.
+
+	mocks.clk.Add(config.DefaultInterval)
+	mocks.events.expectNoTick(t)
+
+	mocks.clk.Add(interval - config.DefaultInterval)
+	mocks.events.expectTick(t)
+
+	// Unset tracker intervals fall back to the new default.
+
+	d := core.DigestFixture()
+	hash := core.InfoHashFixture()
+
+	mocks.client.EXPECT().Announce(d, hash, false, announceclient.V1).Return(nil, time.Duration(0), nil)
+
+	_, err := announcer.Announce(d, hash, false)
+	require.NoError(err)
+	require.Equal(int64(interval), announcer.interval.Load())
+}
+
 func TestAnnouncerAnnounceErr(t *testing.T) {
 	require := require.New(t)
 
@@ -134,3 +172,45 @@ func TestAnnouncerAnnounceErr(t *testing.T) {
 	_, aErr := announcer.Announce(d, hash, false)
 	require.Equal(err, aErr)
 }
+
+func TestAnnouncerJitter(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newAnnouncerMocks(t)
+	defer cleanup()
+
+	announcer := mocks.newAnnouncer(Config{Jitter: 0.2})
+
+	d := 10 * time.Second
+	for i := 0; i < 100; i++ {
+		j := announcer.jitter(d)
+		require.True(j >= 8*time.Second, "%s below jitter bounds", j)
+		require.True(j <= 12*time.Second, "%s above jitter bounds", j)
+	}
+}
+
+func TestAnnouncerNoJitter(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newAnnouncerMocks(t)
+	defer cleanup()
+
+	announcer := mocks.newAnnouncer(Config{})
+
+	require.Equal(10*time.Second, announcer.jitter(10*time.Second))
+}
+
+func TestAnnouncerJitterRandomizesFirstAnnounce(t *testing.T) {
+	mocks, cleanup := newAnnouncerMocks(t)
+	defer cleanup()
+
+	config := Config{DefaultInterval: 5 * time.Second, Jitter: 0.1}
+
+	announcer := mocks.newAnnouncer(config)
+
+	go announcer.Ticker(nil)
+
+	// The first announce fires somewhere within the default interval.
+	mocks.clk.Add(config.DefaultInterval)
+	mocks.events.expectTick(t)
+}
@@ -16,9 +16,13 @@ package scheduler
 import (
 	"time"
 
+	"github.com/uber/kraken/lib/torrent/scheduler/announcer"
 	"github.com/uber/kraken/lib/torrent/scheduler/conn"
 	"github.com/uber/kraken/lib/torrent/scheduler/connstate"
 	"github.com/uber/kraken/lib/torrent/scheduler/dispatch"
+	"github.com/uber/kraken/lib/torrent/scheduler/peerselector"
+	"github.com/uber/kraken/lib/torrent/scheduler/reputation"
+	"github.com/uber/kraken/tracker/announceclient"
 	"github.com/uber/kraken/utils/log"
 )
 
@@ -29,6 +33,16 @@ type Config struct {
 	// read from before being cancelled.
 	SeederTTI time.Duration `yaml:"seeder_tti"`
 
+	// SeederTTL is the max duration a torrent will be seeded after completing,
+	// regardless of whether it is being read from. Zero disables the limit.
+	// Removing a seeding torrent does not remove the blob from disk.
+	SeederTTL time.Duration `yaml:"seeder_ttl"`
+
+	// SeederMaxUploadRatio is the ratio of bytes uploaded to torrent length
+	// after which a completed torrent stops being seeded. Zero disables the
+	// limit.
+	SeederMaxUploadRatio float64 `yaml:"seeder_max_upload_ratio"`
+
 	// LeecherTTI is the duration a leeching torrent will exist without being
 	// written to before being cancelled.
 	LeecherTTI time.Duration `yaml:"leecher_tti"`
@@ -48,6 +62,11 @@ type Config struct {
 	// the Scheduler.
 	EmitStatsInterval time.Duration `yaml:"emit_stats_interval"`
 
+	// UploadMetrics emits a counter of piece bytes uploaded to peers, tagged by
+	// the namespace and info hash of each torrent. Disabled by default, since
+	// info hash tags have high cardinality.
+	UploadMetrics bool `yaml:"upload_metrics"`
+
 	// DisablePreemption disables resource preemption. Should only be used for
 	// testing purposes.
 	DisablePreemption bool `yaml:"disable_preemption"`
@@ -56,10 +75,31 @@ type Config struct {
 
 	ConnState connstate.Config `yaml:"connstate"`
 
+	Reputation reputation.Config `yaml:"reputation"`
+
 	Conn conn.Config `yaml:"conn"`
 
 	Dispatch dispatch.Config `yaml:"dispatch"`
 
+	AnnounceClient announceclient.Config `yaml:"announce_client"`
+
+	Announcer announcer.Config `yaml:"announcer"`
+
+	// PeerSelector is the strategy used to select which announced peers to
+	// connect to. See the peerselector package for available strategies.
+	PeerSelector string `yaml:"peer_selector"`
+
+	// Neighbors are the addresses of peers, e.g. agents on the same rack,
+	// which trackers are asked to include in every peer handout, and which
+	// are connected to before any other peers. Addresses are IPs, optionally
+	// with a port.
+	Neighbors []string `yaml:"neighbors"`
+
+	// PeerPortRange is the range of ports which agents select a free peer
+	// port from on startup if no peer port is given. The selected port is
+	// announced to the tracker.
+	PeerPortRange PortRange `yaml:"peer_port_range"`
+
 	TorrentLog log.Config `yaml:"torrentlog"`
 	Log        log.Config `yaml:"log"`
 }
@@ -86,5 +126,8 @@ func (c Config) applyDefaults() Config {
 	if c.ProbeTimeout == 0 {
 		c.ProbeTimeout = 3 * time.Second
 	}
+	if c.PeerSelector == "" {
+		c.PeerSelector = peerselector.DefaultStrategy
+	}
 	return c
 }
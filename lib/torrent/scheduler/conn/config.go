@@ -27,6 +27,15 @@ type Config struct {
 	// during handshake.
 	HandshakeTimeout time.Duration `yaml:"handshake_timeout"`
 
+	// HandshakeRetries is the number of times a failed outgoing handshake is
+	// retried before the peer is blacklisted for the torrent. The conn remains
+	// pending while it is retried.
+	HandshakeRetries int `yaml:"handshake_retries"`
+
+	// HandshakeRetryInterval is the delay before retrying a failed outgoing
+	// handshake.
+	HandshakeRetryInterval time.Duration `yaml:"handshake_retry_interval"`
+
 	// SenderBufferSize is the size of the sender channel for a connection.
 	// Prevents writers to the connection from being blocked if there are many
 	// writers trying to send messages at the same time.
@@ -44,6 +53,9 @@ func (c Config) applyDefaults() Config {
 	if c.HandshakeTimeout == 0 {
 		c.HandshakeTimeout = 5 * time.Second
 	}
+	if c.HandshakeRetryInterval == 0 {
+		c.HandshakeRetryInterval = time.Second
+	}
 	if c.SenderBufferSize == 0 {
 		c.SenderBufferSize = 10000
 	}
@@ -58,6 +58,10 @@ type Conn struct {
 	lastGoodPieceReceived time.Time
 	lastPieceSent         time.Time
 
+	// Pieces the remote peer no longer needs, which are dropped if their
+	// payloads have not yet been written to the socket.
+	canceledPieces map[int]bool
+
 	nc            net.Conn
 	config        Config
 	clk           clock.Clock
@@ -73,9 +77,10 @@ type Conn struct {
 	receiver chan *Message
 
 	// The following fields orchestrate the closing of the connection:
-	closed *atomic.Bool
-	done   chan struct{}  // Signals to readLoop / writeLoop to exit.
-	wg     sync.WaitGroup // Waits for readLoop / writeLoop to exit.
+	closed         *atomic.Bool
+	closedByRemote *atomic.Bool
+	done           chan struct{}  // Signals to readLoop / writeLoop to exit.
+	wg             sync.WaitGroup // Waits for readLoop / writeLoop to exit.
 
 	logger *zap.SugaredLogger
 }
@@ -116,7 +121,9 @@ func newConn(
 		sender:         make(chan *Message, config.SenderBufferSize),
 		receiver:       make(chan *Message, config.ReceiverBufferSize),
 		closed:         atomic.NewBool(false),
+		closedByRemote: atomic.NewBool(false),
 		done:           make(chan struct{}),
+		canceledPieces: make(map[int]bool),
 		logger:         logger,
 	}
 
@@ -145,6 +152,11 @@ func (c *Conn) InfoHash() core.InfoHash {
 	return c.infoHash
 }
 
+// RemoteAddr returns the network address of the remote peer.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.nc.RemoteAddr()
+}
+
 // CreatedAt returns the time at which the Conn was created.
 func (c *Conn) CreatedAt() time.Time {
 	return c.createdAt
@@ -189,6 +201,12 @@ func (c *Conn) Close() {
 	}()
 }
 
+// ClosedByRemote returns true if c was closed because the remote peer closed
+// the underlying connection, rather than by the local peer.
+func (c *Conn) ClosedByRemote() bool {
+	return c.closedByRemote.Load()
+}
+
 // IsClosed returns true if the c is closed.
 func (c *Conn) IsClosed() bool {
 	return c.closed.Load()
@@ -244,8 +262,14 @@ func (c *Conn) readLoop() {
 			msg, err := c.readMessage()
 			if err != nil {
 				c.log().Infof("Error reading message from socket, exiting read loop: %s", err)
+				if !c.closed.Load() {
+					c.closedByRemote.Store(true)
+				}
 				return
 			}
+			if msg.Message.Type == p2p.Message_CANCEL_PIECE {
+				c.cancelPiece(int(msg.Message.CancelPiece.Index))
+			}
 			c.receiver <- msg
 		}
 	}
@@ -267,7 +291,35 @@ func (c *Conn) sendPiecePayload(pr storage.PieceReader) error {
 	return nil
 }
 
+// cancelPiece marks piece i as no longer needed by the remote peer.
+func (c *Conn) cancelPiece(i int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.canceledPieces[i] = true
+}
+
+// consumeCanceledPiece returns true if piece i was canceled by the remote peer,
+// clearing the cancellation.
+func (c *Conn) consumeCanceledPiece(i int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	canceled := c.canceledPieces[i]
+	delete(c.canceledPieces, i)
+	return canceled
+}
+
 func (c *Conn) sendMessage(msg *Message) error {
+	if msg.Message.Type == p2p.Message_PIECE_PAYLOAD &&
+		c.consumeCanceledPiece(int(msg.Message.PiecePayload.Index)) {
+
+		// Remote peer already received the piece from someone else, so don't
+		// waste bandwidth sending it.
+		msg.Payload.Close()
+		c.stats.Counter("canceled_piece_payloads").Inc(1)
+		return nil
+	}
 	if err := sendMessage(c.nc, msg.Message); err != nil {
 		return fmt.Errorf("send message: %s", err)
 	}
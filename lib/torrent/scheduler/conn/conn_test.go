@@ -17,6 +17,10 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/uber/kraken/gen/go/proto/p2p"
+	"github.com/uber/kraken/lib/torrent/storage"
+	"github.com/uber/kraken/lib/torrent/storage/piecereader"
+
 	"github.com/stretchr/testify/require"
 )
 
@@ -40,3 +44,24 @@ func TestConnClose(t *testing.T) {
 
 	require.True(c.IsClosed())
 }
+
+func TestConnDropsCanceledPiecePayloads(t *testing.T) {
+	require := require.New(t)
+
+	info := storage.TorrentInfoFixture(2, 1)
+	local, remote, cleanup := PipeFixture(Config{}, info)
+	defer cleanup()
+
+	require.NoError(remote.Send(NewCancelPieceMessage(0)))
+
+	msg := <-local.Receiver()
+	require.Equal(p2p.Message_CANCEL_PIECE, msg.Message.Type)
+
+	require.NoError(local.Send(NewPiecePayloadMessage(0, piecereader.NewBuffer([]byte{1}))))
+	require.NoError(local.Send(NewPiecePayloadMessage(1, piecereader.NewBuffer([]byte{2}))))
+
+	// Only the payload which was not canceled is sent.
+	msg = <-remote.Receiver()
+	require.Equal(p2p.Message_PIECE_PAYLOAD, msg.Message.Type)
+	require.Equal(int32(1), msg.Message.PiecePayload.Index)
+}
@@ -17,6 +17,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/gen/go/proto/p2p"
@@ -106,7 +107,7 @@ func handshakeFromP2PMessage(m *p2p.Message) (*handshake, error) {
 	if err != nil {
 		return nil, fmt.Errorf("info hash: %s", err)
 	}
-	d, err := core.NewSHA256DigestFromHex(m.Bitfield.Name)
+	d, err := core.NewDigestFromHex(m.Bitfield.Name)
 	if err != nil {
 		return nil, fmt.Errorf("name: %s", err)
 	}
@@ -227,6 +228,7 @@ func NewHandshaker(
 func (h *Handshaker) Accept(nc net.Conn) (*PendingConn, error) {
 	hs, err := h.readHandshake(nc)
 	if err != nil {
+		h.handshakeFailed("incoming")
 		return nil, fmt.Errorf("read handshake: %s", err)
 	}
 	return &PendingConn{hs, nc}, nil
@@ -242,6 +244,7 @@ func (h *Handshaker) Establish(
 	// Namespace is one-directional: it is only supplied by the connection opener
 	// and is not reciprocated by the connection acceptor.
 	if err := h.sendHandshake(pc.nc, info, remoteBitfields, ""); err != nil {
+		h.handshakeFailed("incoming")
 		return nil, fmt.Errorf("send handshake: %s", err)
 	}
 	c, err := h.newConn(pc.nc, pc.handshake.peerID, info, true)
@@ -253,7 +256,10 @@ func (h *Handshaker) Establish(
 
 // Initialize returns a fully established Conn for the given torrent to the
 // given peer / address. Also returns the bitfield of the remote peer and
-// its connections for the torrent.
+// its connections for the torrent. Handshakes which fail due to transient
+// network errors, i.e. dial errors and timeouts, are retried up to the
+// configured number of handshake retries. Other failures, e.g. the remote peer
+// rejecting the conn or handshaking for a different torrent, are not retried.
 func (h *Handshaker) Initialize(
 	peerID core.PeerID,
 	addr string,
@@ -261,9 +267,32 @@ func (h *Handshaker) Initialize(
 	remoteBitfields RemoteBitfields,
 	namespace string) (*HandshakeResult, error) {
 
+	for i := 0; ; i++ {
+		r, err := h.initialize(peerID, addr, info, remoteBitfields, namespace)
+		if err == nil {
+			return r, nil
+		}
+		h.handshakeFailed("outgoing")
+		if _, ok := err.(transientHandshakeError); !ok || i >= h.config.HandshakeRetries {
+			return nil, err
+		}
+		h.stats.Counter("handshake_retries").Inc(1)
+		// NOTE: We do not use the clock interface here, for consistency with
+		// handshake deadlines which use the system clock.
+		time.Sleep(h.config.HandshakeRetryInterval)
+	}
+}
+
+func (h *Handshaker) initialize(
+	peerID core.PeerID,
+	addr string,
+	info *storage.TorrentInfo,
+	remoteBitfields RemoteBitfields,
+	namespace string) (*HandshakeResult, error) {
+
 	nc, err := net.DialTimeout("tcp", addr, h.config.HandshakeTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("dial: %s", err)
+		return nil, transientHandshakeError{fmt.Errorf("dial: %s", err)}
 	}
 	r, err := h.fullHandshake(nc, peerID, info, remoteBitfields, namespace)
 	if err != nil {
@@ -273,6 +302,62 @@ func (h *Handshaker) Initialize(
 	return r, nil
 }
 
+// Bandwidth returns the bandwidth limiter shared by all connections created
+// by h.
+func (h *Handshaker) Bandwidth() *bandwidth.Limiter {
+	return h.bandwidth
+}
+
+// transientHandshakeError wraps handshake failures caused by transient network
+// errors, which are worth retrying.
+type transientHandshakeError struct {
+	err error
+}
+
+func (e transientHandshakeError) Error() string {
+	return e.err.Error()
+}
+
+// timeoutConn records whether any read or write on the wrapped conn timed out.
+type timeoutConn struct {
+	net.Conn
+	timedOut bool
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.record(err)
+	return n, err
+}
+
+func (c *timeoutConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.record(err)
+	return n, err
+}
+
+func (c *timeoutConn) record(err error) {
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		c.timedOut = true
+	}
+}
+
+// wrap marks err as transient if c timed out.
+func (c *timeoutConn) wrap(err error) error {
+	if c.timedOut {
+		return transientHandshakeError{err}
+	}
+	return err
+}
+
+// handshakeFailed records a failed handshake in direction, i.e. incoming or
+// outgoing.
+func (h *Handshaker) handshakeFailed(direction string) {
+	h.stats.Tagged(map[string]string{
+		"direction": direction,
+	}).Counter("handshake_failures").Inc(1)
+}
+
 func (h *Handshaker) sendHandshake(
 	nc net.Conn,
 	info *storage.TorrentInfo,
@@ -313,16 +398,20 @@ func (h *Handshaker) fullHandshake(
 	remoteBitfields RemoteBitfields,
 	namespace string) (*HandshakeResult, error) {
 
-	if err := h.sendHandshake(nc, info, remoteBitfields, namespace); err != nil {
-		return nil, fmt.Errorf("send handshake: %s", err)
+	tc := &timeoutConn{Conn: nc}
+	if err := h.sendHandshake(tc, info, remoteBitfields, namespace); err != nil {
+		return nil, tc.wrap(fmt.Errorf("send handshake: %s", err))
 	}
-	hs, err := h.readHandshake(nc)
+	hs, err := h.readHandshake(tc)
 	if err != nil {
-		return nil, fmt.Errorf("read handshake: %s", err)
+		return nil, tc.wrap(fmt.Errorf("read handshake: %s", err))
 	}
 	if hs.peerID != peerID {
 		return nil, errors.New("unexpected peer id")
 	}
+	if hs.infoHash != info.InfoHash() {
+		return nil, errors.New("unexpected info hash")
+	}
 	c, err := h.newConn(nc, peerID, info, false)
 	if err != nil {
 		return nil, fmt.Errorf("new conn: %s", err)
@@ -19,9 +19,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/andres-erbsen/clock"
 	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
 
 	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/torrent/networkevent"
 	"github.com/uber/kraken/lib/torrent/storage"
 	"github.com/uber/kraken/utils/bitsetutil"
 )
@@ -87,3 +91,176 @@ func TestHandshakerSetsConnFieldsProperly(t *testing.T) {
 
 	wg.Wait()
 }
+
+func handshakerWithStatsFixture(config Config) (*Handshaker, tally.TestScope) {
+	stats := tally.NewTestScope("", nil)
+	h, err := NewHandshaker(
+		config,
+		stats,
+		clock.New(),
+		networkevent.NewTestProducer(),
+		core.PeerIDFixture(),
+		noopEvents{},
+		zap.NewNop().Sugar())
+	if err != nil {
+		panic(err)
+	}
+	return h, stats
+}
+
+func counterValue(stats tally.TestScope, name string) int64 {
+	var v int64
+	for _, c := range stats.Snapshot().Counters() {
+		if c.Name() == name {
+			v += c.Value()
+		}
+	}
+	return v
+}
+
+func TestHandshakerInitializeRetriesFailedHandshake(t *testing.T) {
+	require := require.New(t)
+
+	config := ConfigFixture()
+	config.HandshakeTimeout = 100 * time.Millisecond
+	config.HandshakeRetries = 2
+	config.HandshakeRetryInterval = 10 * time.Millisecond
+
+	h1 := HandshakerFixture(config)
+	l1, err := net.Listen("tcp", "localhost:0")
+	require.NoError(err)
+	defer l1.Close()
+
+	h2, stats := handshakerWithStatsFixture(config)
+
+	info := storage.TorrentInfoFixture(4, 1)
+
+	go func() {
+		// The first handshake times out.
+		nc, err := l1.Accept()
+		if err != nil {
+			return
+		}
+		defer nc.Close()
+
+		nc, err = l1.Accept()
+		if err != nil {
+			return
+		}
+		pc, err := h1.Accept(nc)
+		if err != nil {
+			return
+		}
+		h1.Establish(pc, info, make(RemoteBitfields))
+	}()
+
+	r, err := h2.Initialize(
+		h1.peerID, l1.Addr().String(), info, make(RemoteBitfields), core.TagFixture())
+	require.NoError(err)
+	require.Equal(h1.peerID, r.Conn.PeerID())
+
+	require.Equal(int64(1), counterValue(stats, "handshake_failures"))
+	require.Equal(int64(1), counterValue(stats, "handshake_retries"))
+}
+
+func TestHandshakerInitializeRetriesExhausted(t *testing.T) {
+	require := require.New(t)
+
+	config := ConfigFixture()
+	config.HandshakeTimeout = 100 * time.Millisecond
+	config.HandshakeRetries = 2
+	config.HandshakeRetryInterval = 10 * time.Millisecond
+
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(err)
+	defer l.Close()
+
+	// Handshakes always time out.
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer nc.Close()
+		}
+	}()
+
+	h, stats := handshakerWithStatsFixture(config)
+
+	_, err = h.Initialize(
+		core.PeerIDFixture(), l.Addr().String(), storage.TorrentInfoFixture(4, 1),
+		make(RemoteBitfields), core.TagFixture())
+	require.Error(err)
+
+	require.Equal(int64(3), counterValue(stats, "handshake_failures"))
+	require.Equal(int64(2), counterValue(stats, "handshake_retries"))
+}
+
+func TestHandshakerInitializeDoesNotRetryRejectedHandshake(t *testing.T) {
+	require := require.New(t)
+
+	config := ConfigFixture()
+	config.HandshakeRetries = 2
+	config.HandshakeRetryInterval = 10 * time.Millisecond
+
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(err)
+	defer l.Close()
+
+	// The remote peer closes the conn, e.g. because it blacklisted us.
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			nc.Close()
+		}
+	}()
+
+	h, stats := handshakerWithStatsFixture(config)
+
+	_, err = h.Initialize(
+		core.PeerIDFixture(), l.Addr().String(), storage.TorrentInfoFixture(4, 1),
+		make(RemoteBitfields), core.TagFixture())
+	require.Error(err)
+
+	require.Equal(int64(1), counterValue(stats, "handshake_failures"))
+	require.Equal(int64(0), counterValue(stats, "handshake_retries"))
+}
+
+func TestHandshakerInitializeRejectsUnexpectedInfoHash(t *testing.T) {
+	require := require.New(t)
+
+	config := ConfigFixture()
+	config.HandshakeRetries = 2
+
+	h1 := HandshakerFixture(config)
+	l1, err := net.Listen("tcp", "localhost:0")
+	require.NoError(err)
+	defer l1.Close()
+
+	h2, stats := handshakerWithStatsFixture(config)
+
+	go func() {
+		nc, err := l1.Accept()
+		if err != nil {
+			return
+		}
+		pc, err := h1.Accept(nc)
+		if err != nil {
+			return
+		}
+		// Reply with a different torrent.
+		h1.Establish(pc, storage.TorrentInfoFixture(4, 1), make(RemoteBitfields))
+	}()
+
+	_, err = h2.Initialize(
+		h1.peerID, l1.Addr().String(), storage.TorrentInfoFixture(4, 1),
+		make(RemoteBitfields), core.TagFixture())
+	require.Error(err)
+
+	require.Equal(int64(1), counterValue(stats, "handshake_failures"))
+	require.Equal(int64(0), counterValue(stats, "handshake_retries"))
+}
@@ -87,6 +87,18 @@ func NewAnnouncePieceMessage(index int) *Message {
 	}
 }
 
+// NewCancelPieceMessage returns a Message for canceling a piece request.
+func NewCancelPieceMessage(index int) *Message {
+	return &Message{
+		Message: &p2p.Message{
+			Type: p2p.Message_CANCEL_PIECE,
+			CancelPiece: &p2p.CancelPieceMessage{
+				Index: int32(index),
+			},
+		},
+	}
+}
+
 // NewCompleteMessage returns a Message for a completed torrent.
 func NewCompleteMessage() *Message {
 	return &Message{
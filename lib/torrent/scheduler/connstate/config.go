@@ -22,6 +22,13 @@ type Config struct {
 	// Scheduler will maintain at once for each torrent.
 	MaxOpenConnectionsPerTorrent int `yaml:"max_open_conn"`
 
+	// MaxOpenConnections is the maximum number of connections which a
+	// Scheduler will maintain at once across all torrents. While enabled, each
+	// torrent is additionally limited to a fair share of MaxOpenConnections,
+	// such that a single torrent cannot starve others of connections. Zero
+	// disables the limit.
+	MaxOpenConnections int `yaml:"max_open_conn_global"`
+
 	// MaxMutualConnections is the maximum number of mutual connections a peer
 	// can have and still connect with us.
 	MaxMutualConnections int `yaml:"max_mutual_conn"`
@@ -27,6 +27,7 @@ import (
 // State errors.
 var (
 	ErrTorrentAtCapacity       = errors.New("torrent is at capacity")
+	ErrGlobalAtCapacity        = errors.New("scheduler is at global connection capacity")
 	ErrConnAlreadyPending      = errors.New("conn is already pending")
 	ErrConnAlreadyActive       = errors.New("conn is already active")
 	ErrConnClosed              = errors.New("conn is closed")
@@ -88,6 +89,10 @@ type State struct {
 	// All pending or active conns. These count towards conn capacity.
 	conns map[core.InfoHash]map[core.PeerID]entry
 
+	// Number of pending and active conns across all torrents.
+	numPending int
+	numActive  int
+
 	// All blacklisted conns. These do not count towards conn capacity.
 	blacklist map[connKey]*blacklistEntry
 }
@@ -138,7 +143,12 @@ func (s *State) Saturated(h core.InfoHash) bool {
 			active++
 		}
 	}
-	return active == s.config.MaxOpenConnectionsPerTorrent
+	return active >= s.limit(h)
+}
+
+// NumConns returns the number of pending and active conns across all torrents.
+func (s *State) NumConns() (pending, active int) {
+	return s.numPending, s.numActive
 }
 
 // Blacklist blacklists peerID/h for the configured BlacklistDuration.
@@ -180,7 +190,11 @@ func (s *State) ClearBlacklist(h core.InfoHash) {
 // AddPending sets the connection for peerID/h as pending and reserves capacity
 // for it.
 func (s *State) AddPending(peerID core.PeerID, h core.InfoHash, neighbors []core.PeerID) error {
-	if len(s.conns[h]) == s.config.MaxOpenConnectionsPerTorrent {
+	if s.config.MaxOpenConnections > 0 &&
+		s.numPending+s.numActive >= s.config.MaxOpenConnections {
+		return ErrGlobalAtCapacity
+	}
+	if len(s.conns[h]) >= s.limit(h) {
 		return ErrTorrentAtCapacity
 	}
 	switch s.get(h, peerID).status {
@@ -292,6 +306,8 @@ func (s *State) put(h core.InfoHash, peerID core.PeerID, e entry) {
 		peers = make(map[core.PeerID]entry)
 		s.conns[h] = peers
 	}
+	s.count(peers[peerID].status, -1)
+	s.count(e.status, 1)
 	peers[peerID] = e
 }
 
@@ -300,14 +316,47 @@ func (s *State) delete(h core.InfoHash, peerID core.PeerID) {
 	if !ok {
 		return
 	}
+	s.count(peers[peerID].status, -1)
 	delete(peers, peerID)
 	if len(peers) == 0 {
 		delete(s.conns, h)
 	}
 }
 
+// count adjusts the global conn counts of status by delta.
+func (s *State) count(st status, delta int) {
+	switch st {
+	case _pending:
+		s.numPending += delta
+	case _active:
+		s.numActive += delta
+	}
+}
+
+// limit returns the max number of conns h may have. If a global limit is
+// configured, the global limit is shared fairly between h and all other torrents
+// which currently have conns.
+func (s *State) limit(h core.InfoHash) int {
+	limit := s.config.MaxOpenConnectionsPerTorrent
+	if s.config.MaxOpenConnections == 0 {
+		return limit
+	}
+	n := len(s.conns)
+	if _, ok := s.conns[h]; !ok {
+		n++
+	}
+	share := s.config.MaxOpenConnections / n
+	if share < 1 {
+		share = 1
+	}
+	if share < limit {
+		limit = share
+	}
+	return limit
+}
+
 func (s *State) capacity(h core.InfoHash) int {
-	return s.config.MaxOpenConnectionsPerTorrent - len(s.conns[h])
+	return s.limit(h) - len(s.conns[h])
 }
 
 func (s *State) log(args ...interface{}) *zap.SugaredLogger {
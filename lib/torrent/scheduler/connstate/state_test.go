@@ -302,3 +302,70 @@ func TestMaxMutualConns(t *testing.T) {
 	require.Equal(s.AddPending(core.PeerIDFixture(), h, neighbors[:mutualConnLimit+1]), ErrTooManyMutualConns)
 	require.NoError(s.AddPending(core.PeerIDFixture(), h, neighbors[:mutualConnLimit]))
 }
+
+func TestStateGlobalConnLimit(t *testing.T) {
+	require := require.New(t)
+
+	s := testState(Config{
+		MaxOpenConnectionsPerTorrent: 10,
+		MaxOpenConnections:           4,
+	}, clock.New())
+
+	h1 := core.InfoHashFixture()
+	h2 := core.InfoHashFixture()
+	h3 := core.InfoHashFixture()
+
+	// A single torrent may use the entire global limit.
+	for i := 0; i < 4; i++ {
+		require.NoError(s.AddPending(core.PeerIDFixture(), h1, nil))
+	}
+	require.Equal(ErrGlobalAtCapacity, s.AddPending(core.PeerIDFixture(), h2, nil))
+
+	pending, active := s.NumConns()
+	require.Equal(4, pending)
+	require.Equal(0, active)
+
+	// Once capacity frees up, h1 is held to its fair share of 2 conns while
+	// h2 may still open conns.
+	for _, p := range peersOf(s, h1)[:3] {
+		s.DeletePending(p, h1)
+	}
+	require.NoError(s.AddPending(core.PeerIDFixture(), h2, nil))
+	require.NoError(s.AddPending(core.PeerIDFixture(), h1, nil))
+	require.Equal(ErrTorrentAtCapacity, s.AddPending(core.PeerIDFixture(), h1, nil))
+	require.NoError(s.AddPending(core.PeerIDFixture(), h2, nil))
+	require.Equal(ErrGlobalAtCapacity, s.AddPending(core.PeerIDFixture(), h3, nil))
+}
+
+func TestStateNumConns(t *testing.T) {
+	require := require.New(t)
+
+	s := testState(Config{}, clock.New())
+
+	info := storage.TorrentInfoFixture(1, 1)
+
+	c, _, cleanup := conn.PipeFixture(conn.Config{}, info)
+	defer cleanup()
+
+	require.NoError(s.AddPending(c.PeerID(), info.InfoHash(), nil))
+	require.NoError(s.AddPending(core.PeerIDFixture(), info.InfoHash(), nil))
+	require.NoError(s.MovePendingToActive(c))
+
+	pending, active := s.NumConns()
+	require.Equal(1, pending)
+	require.Equal(1, active)
+
+	s.DeleteActive(c)
+
+	pending, active = s.NumConns()
+	require.Equal(1, pending)
+	require.Equal(0, active)
+}
+
+func peersOf(s *State, h core.InfoHash) []core.PeerID {
+	var peers []core.PeerID
+	for p := range s.conns[h] {
+		peers = append(peers, p)
+	}
+	return peers
+}
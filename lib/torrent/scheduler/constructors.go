@@ -39,14 +39,35 @@ func NewAgentScheduler(
 	cads *store.CADownloadStore,
 	netevents networkevent.Producer,
 	trackers hashring.PassiveRing,
+	remoteTrackers []hashring.PassiveRing,
 	tls *tls.Config) (ReloadableScheduler, error) {
 
+	archive := agentstorage.NewTorrentArchive(stats, cads, metainfoclient.New(trackers, tls))
+
+	// Verify downloads left over from a previous run so they can be resumed.
+	if err := archive.RestoreDownloads(); err != nil {
+		return nil, fmt.Errorf("restore downloads: %s", err)
+	}
+
+	// Trackers are asked for neighbors, which are otherwise only connected to
+	// if they happen to be in the random peer handout.
+	ac := config.AnnounceClient
+	ac.Neighbors = config.Neighbors
+
+	// Metainfo is only downloaded from the local tracker cluster, whereas peers
+	// are shared with remote tracker clusters.
+	var remotes []announceclient.Client
+	for _, ring := range remoteTrackers {
+		remotes = append(remotes, announceclient.New(ac, pctx, ring, tls))
+	}
+
 	s, err := newScheduler(
 		config,
-		agentstorage.NewTorrentArchive(stats, cads, metainfoclient.New(trackers, tls)),
+		archive,
 		stats,
 		pctx,
-		announceclient.New(pctx, trackers, tls),
+		announceclient.NewMulti(
+			announceclient.New(ac, pctx, trackers, tls), remotes...),
 		netevents)
 	if err != nil {
 		return nil, fmt.Errorf("new scheduler: %s", err)
@@ -0,0 +1,193 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dispatch
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/andres-erbsen/clock"
+)
+
+// Choking algorithms.
+const (
+	// RateChokingAlgorithm unchokes the peers which uploaded the most to us
+	// since the last rechoke, or once the torrent is complete, the peers we
+	// uploaded the most to. Favors throughput.
+	RateChokingAlgorithm = "rate"
+
+	// RoundRobinChokingAlgorithm unchokes the peers which have waited the
+	// longest since they were last unchoked. Favors fairness.
+	RoundRobinChokingAlgorithm = "round_robin"
+)
+
+// chokeCandidate is a peer which is interested in the pieces of a torrent.
+type chokeCandidate struct {
+	peerID core.PeerID
+
+	// Total bytes exchanged with the peer, which rate-based choking ranks
+	// peers by the growth of between rechokes.
+	bytes int64
+}
+
+// choker limits the number of peers which may request pieces from a torrent
+// at the same time to a fixed number of upload slots. Slots are reassigned
+// by the choking algorithm on every rechoke. When more peers are interested
+// than there are slots, one slot is reserved for an optimistic unchoke: a
+// random choked peer which keeps its slot for a full optimistic interval,
+// allowing new peers to prove themselves under rate-based choking.
+type choker struct {
+	slots              int
+	algorithm          string
+	optimisticInterval time.Duration
+	clk                clock.Clock
+
+	mu           sync.Mutex
+	unchoked     map[core.PeerID]bool
+	optimistic   core.PeerID
+	optimisticAt time.Time
+	lastUnchoked map[core.PeerID]time.Time
+	lastBytes    map[core.PeerID]int64
+}
+
+func newChoker(
+	slots int, algorithm string, optimisticInterval time.Duration, clk clock.Clock) (*choker, error) {
+
+	switch algorithm {
+	case RateChokingAlgorithm, RoundRobinChokingAlgorithm:
+	default:
+		return nil, fmt.Errorf("unknown choking algorithm: %s", algorithm)
+	}
+	return &choker{
+		slots:              slots,
+		algorithm:          algorithm,
+		optimisticInterval: optimisticInterval,
+		clk:                clk,
+		unchoked:           make(map[core.PeerID]bool),
+		lastUnchoked:       make(map[core.PeerID]time.Time),
+		lastBytes:          make(map[core.PeerID]int64),
+	}, nil
+}
+
+// allow returns whether peerID may request pieces. Peers are unchoked
+// immediately while there are free slots, instead of waiting for the next
+// rechoke. Returns whether peerID was newly unchoked.
+func (c *choker) allow(peerID core.PeerID) (allowed bool, unchoked bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.unchoked[peerID] {
+		return true, false
+	}
+	if len(c.unchoked) < c.slots {
+		c.unchoked[peerID] = true
+		c.lastUnchoked[peerID] = c.clk.Now()
+		return true, true
+	}
+	return false, false
+}
+
+// rechoke reassigns upload slots between candidates. Peers which are not
+// candidates are always choked. Returns the number of peers which were
+// choked and unchoked.
+func (c *choker) rechoke(candidates []chokeCandidate) (choked, unchoked int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clk.Now()
+
+	rates := make(map[core.PeerID]int64, len(candidates))
+	lastBytes := make(map[core.PeerID]int64, len(candidates))
+	for _, cand := range candidates {
+		rates[cand.peerID] = cand.bytes - c.lastBytes[cand.peerID]
+		lastBytes[cand.peerID] = cand.bytes
+	}
+	c.lastBytes = lastBytes
+
+	next := make(map[core.PeerID]bool)
+	if len(candidates) <= c.slots {
+		for _, cand := range candidates {
+			next[cand.peerID] = true
+		}
+		c.optimistic = core.PeerID{}
+	} else {
+		regular := c.slots
+		if regular > 1 {
+			regular--
+		}
+		ranked := make([]chokeCandidate, len(candidates))
+		copy(ranked, candidates)
+		sort.SliceStable(ranked, func(i, j int) bool {
+			a, b := ranked[i].peerID, ranked[j].peerID
+			if c.algorithm == RoundRobinChokingAlgorithm {
+				return c.lastUnchoked[a].Before(c.lastUnchoked[b])
+			}
+			return rates[a] > rates[b]
+		})
+		for _, cand := range ranked[:regular] {
+			next[cand.peerID] = true
+		}
+		if regular < c.slots {
+			c.unchokeOptimistically(ranked[regular:], now)
+			next[c.optimistic] = true
+		}
+	}
+
+	for peerID := range c.unchoked {
+		if !next[peerID] {
+			choked++
+		}
+	}
+	for peerID := range next {
+		if !c.unchoked[peerID] {
+			unchoked++
+		}
+		c.lastUnchoked[peerID] = now
+	}
+	c.unchoked = next
+	return choked, unchoked
+}
+
+// unchokeOptimistically keeps the current optimistic unchoke if it is still
+// choked otherwise and its interval has not elapsed, else picks a random peer
+// out of choked.
+func (c *choker) unchokeOptimistically(choked []chokeCandidate, now time.Time) {
+	if now.Sub(c.optimisticAt) < c.optimisticInterval {
+		for _, cand := range choked {
+			if cand.peerID == c.optimistic {
+				return
+			}
+		}
+	}
+	c.optimistic = choked[rand.Intn(len(choked))].peerID
+	c.optimisticAt = now
+}
+
+// removePeer frees the slot held by peerID, if any.
+func (c *choker) removePeer(peerID core.PeerID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.unchoked, peerID)
+	delete(c.lastUnchoked, peerID)
+	delete(c.lastBytes, peerID)
+	if c.optimistic == peerID {
+		c.optimistic = core.PeerID{}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dispatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChokerUnknownAlgorithm(t *testing.T) {
+	_, err := newChoker(2, "foo", time.Second, clock.NewMock())
+	require.Error(t, err)
+}
+
+func TestChokerAllowFillsFreeSlots(t *testing.T) {
+	require := require.New(t)
+
+	c, err := newChoker(2, RateChokingAlgorithm, time.Second, clock.NewMock())
+	require.NoError(err)
+
+	p1, p2, p3 := core.PeerIDFixture(), core.PeerIDFixture(), core.PeerIDFixture()
+
+	allowed, unchoked := c.allow(p1)
+	require.True(allowed)
+	require.True(unchoked)
+
+	allowed, unchoked = c.allow(p1)
+	require.True(allowed)
+	require.False(unchoked)
+
+	allowed, _ = c.allow(p2)
+	require.True(allowed)
+
+	allowed, _ = c.allow(p3)
+	require.False(allowed)
+
+	// Removing a peer frees its slot.
+	c.removePeer(p1)
+	allowed, _ = c.allow(p3)
+	require.True(allowed)
+}
+
+func TestChokerRateUnchokesFastestPeers(t *testing.T) {
+	require := require.New(t)
+
+	c, err := newChoker(3, RateChokingAlgorithm, time.Minute, clock.NewMock())
+	require.NoError(err)
+
+	slow, medium, fast, fastest :=
+		core.PeerIDFixture(), core.PeerIDFixture(), core.PeerIDFixture(), core.PeerIDFixture()
+
+	_, unchoked := c.rechoke([]chokeCandidate{{slow, 10}, {medium, 20}, {fast, 30}, {fastest, 40}})
+	require.Equal(3, unchoked)
+	require.True(c.unchoked[fastest])
+	require.True(c.unchoked[fast])
+
+	// Rates are measured since the last rechoke, so slow is now the fastest.
+	c.rechoke([]chokeCandidate{{slow, 100}, {medium, 21}, {fast, 31}, {fastest, 41}})
+	require.True(c.unchoked[slow])
+	require.Len(c.unchoked, 3)
+}
+
+func TestChokerRoundRobinRotatesSlots(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+
+	c, err := newChoker(1, RoundRobinChokingAlgorithm, time.Minute, clk)
+	require.NoError(err)
+
+	p1, p2, p3 := core.PeerIDFixture(), core.PeerIDFixture(), core.PeerIDFixture()
+	candidates := []chokeCandidate{{p1, 0}, {p2, 0}, {p3, 0}}
+
+	seen := make(map[core.PeerID]bool)
+	for i := 0; i < 3; i++ {
+		clk.Add(time.Second)
+		choked, unchoked := c.rechoke(candidates)
+		require.Equal(1, unchoked)
+		if i > 0 {
+			require.Equal(1, choked)
+		}
+		require.Len(c.unchoked, 1)
+		for peerID := range c.unchoked {
+			seen[peerID] = true
+		}
+	}
+	require.Len(seen, 3)
+}
+
+func TestChokerOptimisticUnchokeKeptForInterval(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+
+	c, err := newChoker(2, RateChokingAlgorithm, time.Minute, clk)
+	require.NoError(err)
+
+	fast := core.PeerIDFixture()
+	candidates := []chokeCandidate{{fast, 100}}
+	for i := 0; i < 5; i++ {
+		candidates = append(candidates, chokeCandidate{core.PeerIDFixture(), 0})
+	}
+
+	c.rechoke(candidates)
+	require.True(c.unchoked[fast])
+	optimistic := c.optimistic
+	require.True(c.unchoked[optimistic])
+	require.NotEqual(fast, optimistic)
+
+	clk.Add(30 * time.Second)
+	c.rechoke(candidates)
+	require.Equal(optimistic, c.optimistic)
+}
+
+func TestChokerUnchokesAllWhenSlotsSuffice(t *testing.T) {
+	require := require.New(t)
+
+	c, err := newChoker(2, RateChokingAlgorithm, time.Minute, clock.NewMock())
+	require.NoError(err)
+
+	p1, p2 := core.PeerIDFixture(), core.PeerIDFixture()
+
+	choked, unchoked := c.rechoke([]chokeCandidate{{p1, 0}, {p2, 0}})
+	require.Equal(0, choked)
+	require.Equal(2, unchoked)
+
+	// Peers which are no longer interested are choked.
+	choked, unchoked = c.rechoke([]chokeCandidate{{p1, 0}})
+	require.Equal(1, choked)
+	require.Equal(0, unchoked)
+}
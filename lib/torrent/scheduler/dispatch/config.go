@@ -33,8 +33,24 @@ type Config struct {
 	// timeouts based on the piece size (in megabytes).
 	PieceRequestTimeoutPerMb time.Duration `yaml:"piece_request_timeout_per_mb"`
 
+	// PieceRequestTimeout is the timeout of all piece requests, after which the
+	// piece is re-requested from another peer. Overrides PieceRequestMinTimeout
+	// and PieceRequestTimeoutPerMb if set.
+	PieceRequestTimeout time.Duration `yaml:"piece_request_timeout"`
+
+	// MaxPieceRerequests bounds the number of times a piece is re-requested
+	// from other peers after requests for it time out or fail. Pieces which
+	// exhaust their re-requests are only requested again once announced by a
+	// peer. Unlimited if not set.
+	MaxPieceRerequests int `yaml:"max_piece_rerequests"`
+
+	// BlacklistSlowPeers closes connections to peers which time out piece
+	// requests, which blacklists them, so that a single slow peer does not
+	// keep stalling pieces.
+	BlacklistSlowPeers bool `yaml:"blacklist_slow_peers"`
+
 	// PieceRequestPolicy is the policy that is used to decide which pieces to request
-	// from a peer.
+	// from a peer: "default" (random), "rarest_first" or "sequential".
 	PieceRequestPolicy string `yaml:"piece_request_policy"`
 
 	// PipelineLimit limits the total number of requests can be sent to a peer
@@ -46,7 +62,38 @@ type Config struct {
 	// requests to multiple peers.
 	EndgameThreshold int `yaml:"endgame_threshold"`
 
+	// EndgameMaxRequestsPerPiece bounds the number of peers a single piece may be
+	// requested from at the same time during endgame. Once a piece is received,
+	// redundant requests to other peers are canceled.
+	EndgameMaxRequestsPerPiece int `yaml:"endgame_max_requests_per_piece"`
+
 	DisableEndgame bool `yaml:"disable_endgame"`
+
+	// MaxBadPieces is the number of pieces failing verification against the
+	// torrent metainfo which a peer may send before its connection is closed,
+	// and the peer is blacklisted.
+	MaxBadPieces int `yaml:"max_bad_pieces"`
+
+	// SuperSeeding hides the pieces of completed torrents from new peers and
+	// reveals them one at a time, until every piece has been uploaded at least
+	// once. Intended for origins, which are often the only initial seeder.
+	SuperSeeding bool `yaml:"super_seeding"`
+
+	// UploadSlots limits the number of peers which may request pieces of a
+	// torrent at the same time. Piece requests from the remaining, choked,
+	// peers are rejected until they are unchoked. Unlimited if not set.
+	UploadSlots int `yaml:"upload_slots"`
+
+	// ChokingAlgorithm decides which peers are given upload slots, either
+	// "rate" or "round_robin". Defaults to "rate".
+	ChokingAlgorithm string `yaml:"choking_algorithm"`
+
+	// RechokeInterval is how often upload slots are reassigned.
+	RechokeInterval time.Duration `yaml:"rechoke_interval"`
+
+	// OptimisticUnchokeInterval is how long a random choked peer keeps the
+	// upload slot reserved for optimistic unchokes.
+	OptimisticUnchokeInterval time.Duration `yaml:"optimistic_unchoke_interval"`
 }
 
 func (c Config) applyDefaults() Config {
@@ -65,10 +112,28 @@ func (c Config) applyDefaults() Config {
 	if c.EndgameThreshold == 0 {
 		c.EndgameThreshold = c.PipelineLimit
 	}
+	if c.EndgameMaxRequestsPerPiece == 0 {
+		c.EndgameMaxRequestsPerPiece = 4
+	}
+	if c.MaxBadPieces == 0 {
+		c.MaxBadPieces = 3
+	}
+	if c.ChokingAlgorithm == "" {
+		c.ChokingAlgorithm = RateChokingAlgorithm
+	}
+	if c.RechokeInterval == 0 {
+		c.RechokeInterval = 10 * time.Second
+	}
+	if c.OptimisticUnchokeInterval == 0 {
+		c.OptimisticUnchokeInterval = 30 * time.Second
+	}
 	return c
 }
 
 func (c Config) calcPieceRequestTimeout(maxPieceLength int64) time.Duration {
+	if c.PieceRequestTimeout > 0 {
+		return c.PieceRequestTimeout
+	}
 	n := float64(c.PieceRequestTimeoutPerMb) * float64(maxPieceLength) / float64(memsize.MB)
 	d := time.Duration(math.Ceil(n))
 	return timeutil.MaxDuration(d, c.PieceRequestMinTimeout)
@@ -24,6 +24,7 @@ import (
 	"github.com/uber/kraken/lib/torrent/networkevent"
 	"github.com/uber/kraken/lib/torrent/scheduler/conn"
 	"github.com/uber/kraken/lib/torrent/scheduler/dispatch/piecerequest"
+	"github.com/uber/kraken/lib/torrent/scheduler/reputation"
 	"github.com/uber/kraken/lib/torrent/scheduler/torrentlog"
 	"github.com/uber/kraken/lib/torrent/storage"
 	"github.com/uber/kraken/utils/syncutil"
@@ -31,6 +32,7 @@ import (
 	"github.com/andres-erbsen/clock"
 	"github.com/uber-go/tally"
 	"github.com/willf/bitset"
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
 	"golang.org/x/sync/syncmap"
 )
@@ -40,12 +42,14 @@ var (
 	errPieceOutOfBounds        = errors.New("piece index out of bounds")
 	errChunkNotSupported       = errors.New("reading / writing chunk of piece not supported")
 	errRepeatedBitfieldMessage = errors.New("received repeated bitfield message")
+	errPeerChoked              = errors.New("peer is choked")
 )
 
 // Events defines Dispatcher events.
 type Events interface {
 	DispatcherComplete(*Dispatcher)
 	PeerRemoved(core.PeerID, core.InfoHash)
+	PeerMisbehaved(core.PeerID, core.InfoHash, reputation.Offense)
 }
 
 // Messages defines a subset of conn.Conn methods which Dispatcher requires to
@@ -75,6 +79,11 @@ type Dispatcher struct {
 	pendingPiecesDoneOnce sync.Once
 	pendingPiecesDone     chan struct{}
 	completeOnce          sync.Once
+	completedAt           *atomic.Int64 // Unix nanoseconds, zero until complete.
+	superseeder           *superseeder  // Nil if super-seeding is disabled.
+	choker                *choker       // Nil if upload slots are unlimited.
+	tearDownOnce          sync.Once
+	tornDown              chan struct{}
 	events                Events
 	logger                *zap.SugaredLogger
 	torrentlog            *torrentlog.Logger
@@ -100,6 +109,11 @@ func New(
 	// Exits when d.pendingPiecesDone is closed.
 	go d.watchPendingPieceRequests()
 
+	if d.choker != nil {
+		// Exits when d.tornDown is closed.
+		go d.watchChoking()
+	}
+
 	if t.Complete() {
 		d.complete()
 	}
@@ -127,11 +141,29 @@ func newDispatcher(
 
 	pieceRequestTimeout := config.calcPieceRequestTimeout(t.MaxPieceLength())
 	pieceRequestManager, err := piecerequest.NewManager(
-		clk, pieceRequestTimeout, config.PieceRequestPolicy, config.PipelineLimit)
+		clk,
+		pieceRequestTimeout,
+		config.PieceRequestPolicy,
+		config.PipelineLimit,
+		config.EndgameMaxRequestsPerPiece)
 	if err != nil {
 		return nil, fmt.Errorf("piece request manager: %s", err)
 	}
 
+	var ss *superseeder
+	if config.SuperSeeding {
+		ss = newSuperseeder(t.NumPieces())
+	}
+
+	var ch *choker
+	if config.UploadSlots > 0 {
+		ch, err = newChoker(
+			config.UploadSlots, config.ChokingAlgorithm, config.OptimisticUnchokeInterval, clk)
+		if err != nil {
+			return nil, fmt.Errorf("choker: %s", err)
+		}
+	}
+
 	return &Dispatcher{
 		config:              config,
 		stats:               stats,
@@ -144,6 +176,10 @@ func newDispatcher(
 		pieceRequestTimeout: pieceRequestTimeout,
 		pieceRequestManager: pieceRequestManager,
 		pendingPiecesDone:   make(chan struct{}),
+		completedAt:         atomic.NewInt64(0),
+		superseeder:         ss,
+		choker:              ch,
+		tornDown:            make(chan struct{}),
 		events:              events,
 		logger:              logger,
 		torrentlog:          tlog,
@@ -180,6 +216,28 @@ func (d *Dispatcher) CreatedAt() time.Time {
 	return d.createdAt
 }
 
+// CompletedAt returns when d's torrent completed, or the zero time if d's
+// torrent is not complete. Torrents which were already complete when d was
+// created are considered completed at creation.
+func (d *Dispatcher) CompletedAt() time.Time {
+	t := d.completedAt.Load()
+	if t == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, t)
+}
+
+// BytesSent returns the total piece payload bytes d has sent to peers,
+// including peers which are no longer connected.
+func (d *Dispatcher) BytesSent() int64 {
+	var n int64
+	d.peerStats.Range(func(k, v interface{}) bool {
+		n += v.(*peerStats).getBytesSent()
+		return true
+	})
+	return n
+}
+
 // LastGoodPieceReceived returns when d last received a valid and needed piece
 // from peerID.
 func (d *Dispatcher) LastGoodPieceReceived(peerID core.PeerID) time.Time {
@@ -230,6 +288,66 @@ func (d *Dispatcher) RemoteBitfields() conn.RemoteBitfields {
 	return remoteBitfields
 }
 
+// PeerStats summarizes the contribution of a peer connected to a Dispatcher.
+type PeerStats struct {
+	PeerID         core.PeerID
+	PiecesReceived int
+	PiecesSent     int
+	BytesReceived  int64
+	BytesSent      int64
+}
+
+// Stats summarizes the progress of a Dispatcher's torrent.
+type Stats struct {
+	PiecesComplete int
+	NumPieces      int
+
+	// Totals include peers which are no longer connected.
+	BytesReceived int64
+	BytesSent     int64
+
+	// Only includes connected peers.
+	Peers []PeerStats
+}
+
+// Stats returns a snapshot of d's torrent progress and peer contributions.
+func (d *Dispatcher) Stats() Stats {
+	stats := Stats{
+		PiecesComplete: int(d.torrent.Bitfield().Count()),
+		NumPieces:      d.torrent.NumPieces(),
+		Peers:          []PeerStats{},
+	}
+	d.peerStats.Range(func(k, v interface{}) bool {
+		stats.BytesReceived += v.(*peerStats).getBytesReceived()
+		return true
+	})
+	stats.BytesSent = d.BytesSent()
+	d.peers.Range(func(k, v interface{}) bool {
+		p := v.(*peer)
+		stats.Peers = append(stats.Peers, PeerStats{
+			PeerID:         p.id,
+			PiecesReceived: p.pstats.getGoodPiecesReceived(),
+			PiecesSent:     p.pstats.getPiecesSent(),
+			BytesReceived:  p.pstats.getBytesReceived(),
+			BytesSent:      p.pstats.getBytesSent(),
+		})
+		return true
+	})
+	return stats
+}
+
+// PendingPieceRequests returns the number of in-flight piece requests, and the
+// number of peers they were sent to.
+func (d *Dispatcher) PendingPieceRequests() (requests, peers int) {
+	return d.pieceRequestManager.NumPending()
+}
+
+// SetPieceRequestPolicy changes the policy used to select pieces to request
+// from peers, e.g. to sequential while the blob is being streamed.
+func (d *Dispatcher) SetPieceRequestPolicy(policy string) error {
+	return d.pieceRequestManager.SetPolicy(policy)
+}
+
 // AddPeer registers a new peer with the Dispatcher.
 func (d *Dispatcher) AddPeer(
 	peerID core.PeerID, b *bitset.BitSet, messages Messages) error {
@@ -238,6 +356,7 @@ func (d *Dispatcher) AddPeer(
 	if err != nil {
 		return err
 	}
+	d.maybeSuperseed(p)
 	go d.maybeRequestMorePieces(p)
 	go d.feed(p)
 	return nil
@@ -267,6 +386,12 @@ func (d *Dispatcher) addPeer(
 func (d *Dispatcher) removePeer(p *peer) error {
 	d.peers.Delete(p.id)
 	d.pieceRequestManager.ClearPeer(p.id)
+	if d.superseeder != nil {
+		d.superseeder.removePeer(p.id)
+	}
+	if d.choker != nil {
+		d.choker.removePeer(p.id)
+	}
 
 	for _, i := range p.bitfield.GetAllSet() {
 		d.numPeersByPiece.Decrement(int(i))
@@ -279,6 +404,7 @@ func (d *Dispatcher) TearDown() {
 	d.pendingPiecesDoneOnce.Do(func() {
 		close(d.pendingPiecesDone)
 	})
+	d.tearDownOnce.Do(func() { close(d.tornDown) })
 
 	d.peers.Range(func(k, v interface{}) bool {
 		p := v.(*peer)
@@ -310,7 +436,10 @@ func (d *Dispatcher) String() string {
 }
 
 func (d *Dispatcher) complete() {
-	d.completeOnce.Do(func() { go d.events.DispatcherComplete(d) })
+	d.completeOnce.Do(func() {
+		d.completedAt.Store(d.clk.Now().UnixNano())
+		go d.events.DispatcherComplete(d)
+	})
 	d.pendingPiecesDoneOnce.Do(func() { close(d.pendingPiecesDone) })
 
 	d.peers.Range(func(k, v interface{}) bool {
@@ -336,10 +465,11 @@ func (d *Dispatcher) complete() {
 		requested := pstats.getPieceRequestsSent()
 		piecesRequestedTotal += requested
 		summary := torrentlog.SeederSummary{
-			PeerID:         peerID,
-			RequestsSent:   requested,
-			GoodPiecesReceived: pstats.getGoodPiecesReceived(),
+			PeerID:                  peerID,
+			RequestsSent:            requested,
+			GoodPiecesReceived:      pstats.getGoodPiecesReceived(),
 			DuplicatePiecesReceived: pstats.getDuplicatePiecesReceived(),
+			BadPiecesReceived:       pstats.getBadPiecesReceived(),
 		}
 		summaries = append(summaries, summary)
 		return true
@@ -384,8 +514,8 @@ func (d *Dispatcher) maybeSendPieceRequests(p *peer, candidates *bitset.BitSet)
 		}
 		d.netevents.Produce(
 			networkevent.RequestPieceEvent(d.torrent.InfoHash(), d.localPeerID, p.id, i))
-			p.pstats.incrementPieceRequestsSent()
-		}
+		p.pstats.incrementPieceRequestsSent()
+	}
 	return true, nil
 }
 
@@ -396,8 +526,16 @@ func (d *Dispatcher) resendFailedPieceRequests() {
 		d.stats.Counter("piece_request_failures").Inc(int64(len(failedRequests)))
 	}
 
-	var sent int
+	var sent, exhausted int
 	for _, r := range failedRequests {
+		if r.Status == piecerequest.StatusExpired && d.config.BlacklistSlowPeers {
+			d.closeSlowPeer(r.PeerID, r.Piece)
+		}
+		max := d.config.MaxPieceRerequests
+		if max > 0 && d.pieceRequestManager.Rerequests(r.Piece) >= max {
+			exhausted++
+			continue
+		}
 		d.peers.Range(func(k, v interface{}) bool {
 			p := v.(*peer)
 			if (r.Status == piecerequest.StatusExpired || r.Status == piecerequest.StatusInvalid) &&
@@ -410,7 +548,9 @@ func (d *Dispatcher) resendFailedPieceRequests() {
 			candidates := p.bitfield.Intersection(b.Complement())
 			if candidates.Test(uint(r.Piece)) {
 				nb := bitset.New(b.Len()).Set(uint(r.Piece))
-				if sent, err := d.maybeSendPieceRequests(p, nb); sent && err == nil {
+				if ok, err := d.maybeSendPieceRequests(p, nb); ok && err == nil {
+					d.pieceRequestManager.MarkRerequested(r.Piece)
+					sent++
 					return false
 				}
 			}
@@ -418,12 +558,31 @@ func (d *Dispatcher) resendFailedPieceRequests() {
 		})
 	}
 
-	unsent := len(failedRequests) - sent
+	d.stats.Counter("piece_rerequests").Inc(int64(sent))
+	if exhausted > 0 {
+		d.log().Infof("Not resending %d failed piece requests which exhausted their re-requests", exhausted)
+		d.stats.Counter("piece_rerequests_exhausted").Inc(int64(exhausted))
+	}
+
+	unsent := len(failedRequests) - sent - exhausted
 	if unsent > 0 {
 		d.log().Infof("Nowhere to resend %d / %d failed piece requests", unsent, len(failedRequests))
 	}
 }
 
+// closeSlowPeer closes the connection to peerID, which timed out a request for
+// piece i. Closing the connection blacklists the peer, and clears its requests.
+func (d *Dispatcher) closeSlowPeer(peerID core.PeerID, i int) {
+	v, ok := d.peers.Load(peerID)
+	if !ok {
+		return
+	}
+	p := v.(*peer)
+	d.stats.Counter("slow_peer_bans").Inc(1)
+	d.log("peer", p, "piece", i).Warn("Closing connection to peer which timed out piece request")
+	p.messages.Close()
+}
+
 func (d *Dispatcher) watchPendingPieceRequests() {
 	for {
 		select {
@@ -435,6 +594,40 @@ func (d *Dispatcher) watchPendingPieceRequests() {
 	}
 }
 
+func (d *Dispatcher) watchChoking() {
+	for {
+		select {
+		case <-d.clk.After(d.config.RechokeInterval):
+			d.rechoke()
+		case <-d.tornDown:
+			return
+		}
+	}
+}
+
+// rechoke reassigns upload slots between peers which still need pieces. While
+// downloading, peers are ranked by how much they uploaded to us, and once
+// complete, by how much we uploaded to them.
+func (d *Dispatcher) rechoke() {
+	complete := d.Complete()
+	var candidates []chokeCandidate
+	d.peers.Range(func(k, v interface{}) bool {
+		p := v.(*peer)
+		if p.bitfield.Complete() {
+			return true
+		}
+		bytes := p.pstats.getBytesReceived()
+		if complete {
+			bytes = p.pstats.getBytesSent()
+		}
+		candidates = append(candidates, chokeCandidate{p.id, bytes})
+		return true
+	})
+	choked, unchoked := d.choker.rechoke(candidates)
+	d.stats.Counter("chokes").Inc(int64(choked))
+	d.stats.Counter("unchokes").Inc(int64(unchoked))
+}
+
 // feed reads off of peer and handles incoming messages. When peer's messages close,
 // the feed goroutine removes peer from the Dispatcher and exits.
 func (d *Dispatcher) feed(p *peer) {
@@ -472,8 +665,17 @@ func (d *Dispatcher) dispatch(p *peer, msg *conn.Message) error {
 func (d *Dispatcher) handleError(p *peer, msg *p2p.ErrorMessage) {
 	switch msg.Code {
 	case p2p.ErrorMessage_PIECE_REQUEST_FAILED:
-		d.log().Errorf("Piece request failed: %s", msg.Error)
 		d.pieceRequestManager.MarkInvalid(p.id, int(msg.Index))
+		if msg.Error == errPeerChoked.Error() {
+			// Choking is expected behavior, not a failure to serve the piece.
+			d.log("peer", p).Debug("Piece request rejected by choking peer")
+			return
+		}
+		d.log().Errorf("Piece request failed: %s", msg.Error)
+		if p.bitfield.Has(uint(msg.Index)) {
+			// The peer failed to serve a piece it announced it has.
+			go d.events.PeerMisbehaved(p.id, d.torrent.InfoHash(), reputation.UnavailablePiece)
+		}
 	}
 }
 
@@ -497,6 +699,17 @@ func (d *Dispatcher) handlePieceRequest(p *peer, msg *p2p.PieceRequestMessage) {
 	p.pstats.incrementPieceRequestsReceived()
 
 	i := int(msg.Index)
+	if d.choker != nil {
+		allowed, unchoked := d.choker.allow(p.id)
+		if !allowed {
+			d.stats.Counter("choked_piece_requests").Inc(1)
+			p.messages.Send(conn.NewErrorMessage(i, p2p.ErrorMessage_PIECE_REQUEST_FAILED, errPeerChoked))
+			return
+		}
+		if unchoked {
+			d.stats.Counter("unchokes").Inc(1)
+		}
+	}
 	if !d.isFullPiece(i, int(msg.Offset), int(msg.Length)) {
 		d.log("peer", p, "piece", i).Error("Rejecting piece request: chunk not supported")
 		p.messages.Send(conn.NewErrorMessage(i, p2p.ErrorMessage_PIECE_REQUEST_FAILED, errChunkNotSupported))
@@ -516,9 +729,51 @@ func (d *Dispatcher) handlePieceRequest(p *peer, msg *p2p.PieceRequestMessage) {
 
 	p.touchLastPieceSent()
 	p.pstats.incrementPiecesSent()
+	p.pstats.addBytesSent(int64(msg.Length))
 
 	// Assume that the peer successfully received the piece.
 	p.bitfield.Set(uint(i), true)
+
+	if d.superseeder != nil && d.superseeder.markSent(i) {
+		// Every piece has been uploaded at least once, so there is no longer
+		// any benefit to hiding pieces from peers.
+		d.peers.Range(func(k, v interface{}) bool {
+			d.maybeSuperseed(v.(*peer))
+			return true
+		})
+	} else {
+		d.maybeSuperseed(p)
+	}
+}
+
+// maybeSuperseed announces the next pieces p should download from a complete
+// super-seeding Dispatcher. Peers connected to such a Dispatcher are handshaked
+// with an empty bitfield, and only learn of pieces via these announcements.
+func (d *Dispatcher) maybeSuperseed(p *peer) {
+	if d.superseeder == nil || !d.Complete() {
+		return
+	}
+	for _, i := range d.superseeder.offer(p.id, p.bitfield.Copy()) {
+		if err := p.messages.Send(conn.NewAnnouncePieceMessage(i)); err != nil {
+			return
+		}
+	}
+}
+
+// rejectBadPiece handles piece i from p which failed verification against the
+// torrent metainfo. Once p exceeds the configured bad piece limit, its
+// connection is closed, which blacklists p.
+func (d *Dispatcher) rejectBadPiece(p *peer, i int) {
+	d.stats.Counter("bad_pieces").Inc(1)
+	d.pieceRequestManager.MarkInvalid(p.id, i)
+	go d.events.PeerMisbehaved(p.id, d.torrent.InfoHash(), reputation.BadPiece)
+	n := p.pstats.incrementBadPiecesReceived()
+	d.log("peer", p, "piece", i, "bad_pieces", n).Error("Rejecting piece payload: invalid piece sum")
+	if n >= d.config.MaxBadPieces {
+		d.stats.Counter("bad_piece_bans").Inc(1)
+		d.log("peer", p).Warn("Closing connection to peer which sent too many bad pieces")
+		p.messages.Close()
+	}
 }
 
 func (d *Dispatcher) handlePiecePayload(
@@ -534,11 +789,13 @@ func (d *Dispatcher) handlePiecePayload(
 	}
 
 	if err := d.torrent.WritePiece(payload, i); err != nil {
-		if err != storage.ErrPieceComplete {
+		if err == storage.ErrPieceComplete {
+			p.pstats.incrementDuplicatePiecesReceived()
+		} else if err == storage.ErrInvalidPieceSum {
+			d.rejectBadPiece(p, i)
+		} else {
 			d.log("peer", p, "piece", i).Errorf("Error writing piece payload: %s", err)
 			d.pieceRequestManager.MarkInvalid(p.id, i)
-		} else {
-			p.pstats.incrementDuplicatePiecesReceived()
 		}
 		return
 	}
@@ -547,12 +804,13 @@ func (d *Dispatcher) handlePiecePayload(
 		networkevent.ReceivePieceEvent(d.torrent.InfoHash(), d.localPeerID, p.id, i))
 
 	p.pstats.incrementGoodPiecesReceived()
+	p.pstats.addBytesReceived(int64(msg.Length))
 	p.touchLastGoodPieceReceived()
 	if d.torrent.Complete() {
 		d.complete()
 	}
 
-	d.pieceRequestManager.Clear(i)
+	d.cancelPendingPieceRequests(p, i)
 
 	d.maybeRequestMorePieces(p)
 
@@ -568,10 +826,23 @@ func (d *Dispatcher) handlePiecePayload(
 	})
 }
 
+// cancelPendingPieceRequests clears bookkeeping for piece i, which was just
+// received from p, and cancels any redundant requests for i sent to other peers
+// (e.g. during endgame).
+func (d *Dispatcher) cancelPendingPieceRequests(p *peer, i int) {
+	for _, peerID := range d.pieceRequestManager.PendingPeers(p.id, i) {
+		if v, ok := d.peers.Load(peerID); ok {
+			v.(*peer).messages.Send(conn.NewCancelPieceMessage(i))
+		}
+	}
+	d.pieceRequestManager.Clear(i)
+}
+
 func (d *Dispatcher) handleCancelPiece(p *peer, msg *p2p.CancelPieceMessage) {
-	// No-op: cancelling not supported because all received messages are synchronized,
-	// therefore if we receive a cancel it is already too late -- we've already read
-	// the piece.
+	// No-op: all received messages are synchronized, therefore if we receive a
+	// cancel it is already too late -- we've already read the piece request.
+	// Instead, Conn drops canceled piece payloads which have not been written
+	// to the socket yet.
 }
 
 func (d *Dispatcher) handleBitfield(p *peer, msg *p2p.BitfieldMessage) {
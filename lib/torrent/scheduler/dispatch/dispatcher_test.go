@@ -23,6 +23,7 @@ import (
 	"github.com/uber/kraken/gen/go/proto/p2p"
 	"github.com/uber/kraken/lib/torrent/networkevent"
 	"github.com/uber/kraken/lib/torrent/scheduler/conn"
+	"github.com/uber/kraken/lib/torrent/scheduler/reputation"
 	"github.com/uber/kraken/lib/torrent/scheduler/torrentlog"
 	"github.com/uber/kraken/lib/torrent/storage"
 	"github.com/uber/kraken/lib/torrent/storage/agentstorage"
@@ -85,6 +86,16 @@ func announcedPieces(messages Messages) []int {
 	return ps
 }
 
+func canceledPieces(messages Messages) []int {
+	var ps []int
+	for _, msg := range messages.(*mockMessages).sent {
+		if msg.Message.Type == p2p.Message_CANCEL_PIECE {
+			ps = append(ps, int(msg.Message.CancelPiece.Index))
+		}
+	}
+	return ps
+}
+
 func hasComplete(messages Messages) bool {
 	for _, m := range messages.(*mockMessages).sent {
 		if m.Message.Type == p2p.Message_COMPLETE {
@@ -104,6 +115,8 @@ func (e noopEvents) DispatcherComplete(*Dispatcher) {}
 
 func (e noopEvents) PeerRemoved(core.PeerID, core.InfoHash) {}
 
+func (e noopEvents) PeerMisbehaved(core.PeerID, core.InfoHash, reputation.Offense) {}
+
 func testDispatcher(config Config, clk clock.Clock, t storage.Torrent) *Dispatcher {
 	d, err := newDispatcher(
 		config,
@@ -231,6 +244,76 @@ func TestDispatcherResendFailedPieceRequests(t *testing.T) {
 	}, numRequestsPerPiece(p3.messages))
 }
 
+func TestDispatcherResendFailedPieceRequestsMaxRerequests(t *testing.T) {
+	require := require.New(t)
+
+	config := Config{
+		DisableEndgame:     true,
+		MaxPieceRerequests: 1,
+	}
+	clk := clock.NewMock()
+
+	torrent, cleanup := agentstorage.TorrentFixture(core.SizedBlobFixture(1, 1).MetaInfo)
+	defer cleanup()
+
+	d := testDispatcher(config, clk, torrent)
+
+	var peers []*peer
+	for i := 0; i < 3; i++ {
+		p, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(true), newMockMessages())
+		require.NoError(err)
+		peers = append(peers, p)
+	}
+	d.maybeRequestMorePieces(peers[0])
+
+	numRequests := func() int {
+		var n int
+		for _, p := range peers {
+			n += numRequestsPerPiece(p.messages)[0]
+		}
+		return n
+	}
+
+	clk.Add(d.pieceRequestTimeout + 1)
+	d.resendFailedPieceRequests()
+	require.Equal(2, numRequests())
+	require.Equal(1, d.pieceRequestManager.Rerequests(0))
+
+	// Piece 0 exhausted its re-requests, so it is not re-requested again.
+	clk.Add(d.pieceRequestTimeout + 1)
+	d.resendFailedPieceRequests()
+	require.Equal(2, numRequests())
+}
+
+func TestDispatcherResendFailedPieceRequestsBlacklistSlowPeers(t *testing.T) {
+	require := require.New(t)
+
+	config := Config{
+		DisableEndgame:     true,
+		BlacklistSlowPeers: true,
+	}
+	clk := clock.NewMock()
+
+	torrent, cleanup := agentstorage.TorrentFixture(core.SizedBlobFixture(1, 1).MetaInfo)
+	defer cleanup()
+
+	d := testDispatcher(config, clk, torrent)
+
+	p1, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(true), newMockMessages())
+	require.NoError(err)
+	d.maybeRequestMorePieces(p1)
+
+	p2, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(true), newMockMessages())
+	require.NoError(err)
+
+	clk.Add(d.pieceRequestTimeout + 1)
+	d.resendFailedPieceRequests()
+
+	require.True(closed(p1.messages))
+	require.False(closed(p2.messages))
+	require.Equal(map[int]int{0: 1}, numRequestsPerPiece(p2.messages))
+}
+
 func TestDispatcherSendErrorsMarksPieceRequestsUnsent(t *testing.T) {
 	require := require.New(t)
 
@@ -288,6 +371,14 @@ func TestDispatcherCalcPieceRequestTimeout(t *testing.T) {
 	}
 }
 
+func TestDispatcherCalcPieceRequestTimeoutOverride(t *testing.T) {
+	config := Config{
+		PieceRequestTimeout:    time.Second,
+		PieceRequestMinTimeout: 5 * time.Second,
+	}
+	require.Equal(t, time.Second, config.calcPieceRequestTimeout(int64(8*memsize.MB)))
+}
+
 func TestDispatcherEndgame(t *testing.T) {
 	require := require.New(t)
 
@@ -316,6 +407,69 @@ func TestDispatcherEndgame(t *testing.T) {
 	require.Equal(map[int]int{0: 1}, numRequestsPerPiece(p2.messages))
 }
 
+func TestDispatcherEndgameMaxRequestsPerPiece(t *testing.T) {
+	require := require.New(t)
+
+	config := Config{
+		PipelineLimit:              1,
+		EndgameThreshold:           1,
+		EndgameMaxRequestsPerPiece: 2,
+	}
+	clk := clock.NewMock()
+
+	torrent, cleanup := agentstorage.TorrentFixture(core.SizedBlobFixture(1, 1).MetaInfo)
+	defer cleanup()
+
+	d := testDispatcher(config, clk, torrent)
+
+	var peers []*peer
+	for i := 0; i < 3; i++ {
+		p, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(true), newMockMessages())
+		require.NoError(err)
+		d.maybeRequestMorePieces(p)
+		peers = append(peers, p)
+	}
+
+	require.Equal(map[int]int{0: 1}, numRequestsPerPiece(peers[0].messages))
+	require.Equal(map[int]int{0: 1}, numRequestsPerPiece(peers[1].messages))
+
+	// Piece 0 has already been requested from the max number of peers.
+	require.Empty(numRequestsPerPiece(peers[2].messages))
+}
+
+func TestDispatcherEndgameCancelsRedundantPieceRequests(t *testing.T) {
+	require := require.New(t)
+
+	config := Config{
+		PipelineLimit:    1,
+		EndgameThreshold: 2,
+	}
+	clk := clock.NewMock()
+
+	blob := core.SizedBlobFixture(2, 1)
+
+	torrent, cleanup := agentstorage.TorrentFixture(blob.MetaInfo)
+	defer cleanup()
+
+	d := testDispatcher(config, clk, torrent)
+
+	p1, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(true, false), newMockMessages())
+	require.NoError(err)
+	d.maybeRequestMorePieces(p1)
+
+	p2, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(true, false), newMockMessages())
+	require.NoError(err)
+	d.maybeRequestMorePieces(p2)
+
+	msg := conn.NewPiecePayloadMessage(0, piecereader.NewBuffer(blob.Content[0:1]))
+	require.NoError(d.dispatch(p1, msg))
+
+	// The redundant request to p2 is canceled.
+	require.Empty(canceledPieces(p1.messages))
+	require.Equal([]int{0}, canceledPieces(p2.messages))
+	require.Empty(d.pieceRequestManager.PendingPieces(p2.id))
+}
+
 func TestDispatcherHandlePiecePayloadAnnouncesPiece(t *testing.T) {
 	require := require.New(t)
 
@@ -343,6 +497,37 @@ func TestDispatcherHandlePiecePayloadAnnouncesPiece(t *testing.T) {
 	require.Equal([]int{0}, announcedPieces(p2.messages))
 }
 
+func TestDispatcherClosesPeerAfterTooManyBadPieces(t *testing.T) {
+	require := require.New(t)
+
+	blob := core.SizedBlobFixture(4, 1)
+
+	torrent, cleanup := agentstorage.TorrentFixture(blob.MetaInfo)
+	defer cleanup()
+
+	d := testDispatcher(Config{MaxBadPieces: 2}, clock.NewMock(), torrent)
+
+	p, err := d.addPeer(
+		core.PeerIDFixture(), bitsetutil.FromBools(true, true, true, true), newMockMessages())
+	require.NoError(err)
+
+	badPiece := func(i int) *conn.Message {
+		return conn.NewPiecePayloadMessage(i, piecereader.NewBuffer([]byte{blob.Content[i] + 1}))
+	}
+
+	require.NoError(d.dispatch(p, badPiece(0)))
+	require.False(closed(p.messages))
+	require.False(torrent.HasPiece(0))
+
+	// Good pieces do not reset the bad piece count.
+	require.NoError(d.dispatch(p, conn.NewPiecePayloadMessage(1, piecereader.NewBuffer(blob.Content[1:2]))))
+	require.True(torrent.HasPiece(1))
+
+	require.NoError(d.dispatch(p, badPiece(2)))
+	require.True(closed(p.messages))
+	require.Equal(2, p.pstats.getBadPiecesReceived())
+}
+
 func TestDispatcherHandlePiecePayloadSendsCompleteMessage(t *testing.T) {
 	require := require.New(t)
 
@@ -472,3 +657,186 @@ func TestDispatcherPeerPieceCounts(t *testing.T) {
 	require.Equal(1, d.numPeersByPiece.Get(1))
 	require.Equal(2, d.numPeersByPiece.Get(2))
 }
+
+func TestDispatcherStats(t *testing.T) {
+	require := require.New(t)
+
+	blob := core.SizedBlobFixture(2, 1)
+
+	torrent, cleanup := agentstorage.TorrentFixture(blob.MetaInfo)
+	defer cleanup()
+
+	d := testDispatcher(Config{}, clock.NewMock(), torrent)
+
+	p1, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(true, true), newMockMessages())
+	require.NoError(err)
+
+	p2, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(false, false), newMockMessages())
+	require.NoError(err)
+
+	msg := conn.NewPiecePayloadMessage(0, piecereader.NewBuffer(blob.Content[0:1]))
+	require.NoError(d.dispatch(p1, msg))
+
+	stats := d.Stats()
+	require.Equal(1, stats.PiecesComplete)
+	require.Equal(2, stats.NumPieces)
+	require.Equal(int64(1), stats.BytesReceived)
+	require.Equal(int64(0), stats.BytesSent)
+	require.ElementsMatch([]PeerStats{{
+		PeerID:         p1.id,
+		PiecesReceived: 1,
+		BytesReceived:  1,
+	}, {
+		PeerID: p2.id,
+	}}, stats.Peers)
+
+	// Totals persist after the peer is removed.
+	require.NoError(d.removePeer(p1))
+	stats = d.Stats()
+	require.Equal(int64(1), stats.BytesReceived)
+	require.Equal([]PeerStats{{PeerID: p2.id}}, stats.Peers)
+}
+
+func TestDispatcherCompletedAt(t *testing.T) {
+	require := require.New(t)
+
+	blob := core.SizedBlobFixture(1, 1)
+
+	torrent, cleanup := agentstorage.TorrentFixture(blob.MetaInfo)
+	defer cleanup()
+
+	clk := clock.NewMock()
+	clk.Add(time.Hour)
+
+	d := testDispatcher(Config{}, clk, torrent)
+	require.True(d.CompletedAt().IsZero())
+
+	p, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(true), newMockMessages())
+	require.NoError(err)
+
+	clk.Add(time.Minute)
+
+	msg := conn.NewPiecePayloadMessage(0, piecereader.NewBuffer(blob.Content[0:1]))
+	require.NoError(d.dispatch(p, msg))
+
+	require.Equal(clk.Now().UnixNano(), d.CompletedAt().UnixNano())
+}
+
+func TestDispatcherSuperSeeding(t *testing.T) {
+	require := require.New(t)
+
+	blob := core.SizedBlobFixture(3, 1)
+
+	torrent, cleanup := agentstorage.TorrentFixture(blob.MetaInfo)
+	defer cleanup()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(torrent.WritePiece(piecereader.NewBuffer(blob.Content[i:i+1]), i))
+	}
+
+	d := testDispatcher(Config{SuperSeeding: true}, clock.NewMock(), torrent)
+
+	p1, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(false, false, false), newMockMessages())
+	require.NoError(err)
+	d.maybeSuperseed(p1)
+
+	p2, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(false, false, false), newMockMessages())
+	require.NoError(err)
+	d.maybeSuperseed(p2)
+
+	// Each peer is offered a different piece.
+	require.Equal([]int{0}, announcedPieces(p1.messages))
+	require.Equal([]int{1}, announcedPieces(p2.messages))
+
+	// Uploading a piece offers the next piece which has not been uploaded yet.
+	require.NoError(d.dispatch(p1, conn.NewPieceRequestMessage(0, 1)))
+	require.Equal([]int{0, 2}, announcedPieces(p1.messages))
+
+	require.NoError(d.dispatch(p2, conn.NewPieceRequestMessage(1, 1)))
+	require.Equal([]int{1, 2}, announcedPieces(p2.messages))
+
+	// Once every piece has been uploaded, all remaining pieces are revealed.
+	require.NoError(d.dispatch(p1, conn.NewPieceRequestMessage(2, 1)))
+	require.Equal([]int{0, 2, 1}, announcedPieces(p1.messages))
+	require.Equal([]int{1, 2, 0}, announcedPieces(p2.messages))
+}
+
+func TestDispatcherSuperSeedingDisabled(t *testing.T) {
+	require := require.New(t)
+
+	blob := core.SizedBlobFixture(2, 1)
+
+	torrent, cleanup := agentstorage.TorrentFixture(blob.MetaInfo)
+	defer cleanup()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(torrent.WritePiece(piecereader.NewBuffer(blob.Content[i:i+1]), i))
+	}
+
+	d := testDispatcher(Config{}, clock.NewMock(), torrent)
+
+	p, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(false, false), newMockMessages())
+	require.NoError(err)
+	d.maybeSuperseed(p)
+
+	require.NoError(d.dispatch(p, conn.NewPieceRequestMessage(0, 1)))
+	require.Empty(announcedPieces(p.messages))
+}
+
+func TestDispatcherRejectsPieceRequestsFromChokedPeers(t *testing.T) {
+	require := require.New(t)
+
+	blob := core.SizedBlobFixture(2, 1)
+
+	torrent, cleanup := agentstorage.TorrentFixture(blob.MetaInfo)
+	defer cleanup()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(torrent.WritePiece(piecereader.NewBuffer(blob.Content[i:i+1]), i))
+	}
+
+	d := testDispatcher(Config{UploadSlots: 1}, clock.NewMock(), torrent)
+
+	p1, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(false, false), newMockMessages())
+	require.NoError(err)
+
+	p2, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(false, false), newMockMessages())
+	require.NoError(err)
+
+	require.NoError(d.dispatch(p1, conn.NewPieceRequestMessage(0, 1)))
+	require.NoError(d.dispatch(p2, conn.NewPieceRequestMessage(0, 1)))
+
+	sent := func(p *peer) *conn.Message {
+		msgs := p.messages.(*mockMessages).sent
+		require.Len(msgs, 1)
+		return msgs[0]
+	}
+	require.Equal(p2p.Message_PIECE_PAYLOAD, sent(p1).Message.Type)
+	require.Equal(p2p.Message_ERROR, sent(p2).Message.Type)
+	require.Equal(errPeerChoked.Error(), sent(p2).Message.Error.Error)
+
+	// Once p1 disconnects, p2 takes over its slot.
+	require.NoError(d.removePeer(p1))
+	require.NoError(d.dispatch(p2, conn.NewPieceRequestMessage(1, 1)))
+	msgs := p2.messages.(*mockMessages).sent
+	require.Equal(p2p.Message_PIECE_PAYLOAD, msgs[len(msgs)-1].Message.Type)
+}
+
+func TestDispatcherInvalidChokingAlgorithm(t *testing.T) {
+	blob := core.SizedBlobFixture(2, 1)
+
+	torrent, cleanup := agentstorage.TorrentFixture(blob.MetaInfo)
+	defer cleanup()
+
+	_, err := newDispatcher(
+		Config{UploadSlots: 1, ChokingAlgorithm: "foo"},
+		tally.NoopScope,
+		clock.NewMock(),
+		networkevent.NewTestProducer(),
+		noopEvents{},
+		core.PeerIDFixture(),
+		torrent,
+		zap.NewNop().Sugar(),
+		torrentlog.NewNopLogger())
+	require.Error(t, err)
+}
@@ -100,6 +100,11 @@ type peerStats struct {
 	goodPiecesReceived int
 	// Pieces we received from the peer that we already had.
 	duplicatePiecesReceived int
+	// Pieces we received from the peer that failed verification.
+	badPiecesReceived int
+
+	bytesSent     int64 // Payload bytes we sent to the peer.
+	bytesReceived int64 // Payload bytes of good pieces received from the peer.
 }
 
 func (s *peerStats) getPieceRequestsSent() int {
@@ -171,3 +176,48 @@ func (s *peerStats) incrementDuplicatePiecesReceived() {
 
 	s.duplicatePiecesReceived++
 }
+
+func (s *peerStats) getBadPiecesReceived() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.badPiecesReceived
+}
+
+// incrementBadPiecesReceived returns the number of bad pieces received after
+// the increment.
+func (s *peerStats) incrementBadPiecesReceived() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.badPiecesReceived++
+	return s.badPiecesReceived
+}
+
+func (s *peerStats) getBytesSent() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.bytesSent
+}
+
+func (s *peerStats) addBytesSent(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bytesSent += n
+}
+
+func (s *peerStats) getBytesReceived() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.bytesReceived
+}
+
+func (s *peerStats) addBytesReceived(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bytesReceived += n
+}
@@ -61,11 +61,18 @@ type Manager struct {
 	requests       map[int][]*Request
 	requestsByPeer map[core.PeerID]map[int]*Request
 
+	// Number of times each piece has been re-requested after a failed request.
+	rerequests map[int]int
+
 	clock   clock.Clock
 	timeout time.Duration
 
 	policy        pieceSelectionPolicy
 	pipelineLimit int
+
+	// Limits the number of pending requests per piece when duplicates are
+	// allowed. Zero means no limit.
+	maxDuplicateRequests int
 }
 
 // NewManager creates a new Manager.
@@ -73,31 +80,58 @@ func NewManager(
 	clk clock.Clock,
 	timeout time.Duration,
 	policy string,
-	pipelineLimit int) (*Manager, error) {
+	pipelineLimit int,
+	maxDuplicateRequests int) (*Manager, error) {
 
 	m := &Manager{
-		requests:       make(map[int][]*Request),
-		requestsByPeer: make(map[core.PeerID]map[int]*Request),
-		clock:          clk,
-		timeout:        timeout,
-		pipelineLimit:  pipelineLimit,
+		requests:             make(map[int][]*Request),
+		requestsByPeer:       make(map[core.PeerID]map[int]*Request),
+		rerequests:           make(map[int]int),
+		clock:                clk,
+		timeout:              timeout,
+		pipelineLimit:        pipelineLimit,
+		maxDuplicateRequests: maxDuplicateRequests,
 	}
 
+	p, err := newPolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	m.policy = p
+	return m, nil
+}
+
+func newPolicy(policy string) (pieceSelectionPolicy, error) {
 	switch policy {
 	case DefaultPolicy:
-		m.policy = newDefaultPolicy()
+		return newDefaultPolicy(), nil
 	case RarestFirstPolicy:
-		m.policy = newRarestFirstPolicy()
+		return newRarestFirstPolicy(), nil
+	case SequentialPolicy:
+		return newSequentialPolicy(), nil
 	default:
 		return nil, fmt.Errorf("invalid piece selection policy: %s", policy)
 	}
-	return m, nil
+}
+
+// SetPolicy changes the piece selection policy of subsequent reservations.
+// Pending requests are unaffected.
+func (m *Manager) SetPolicy(policy string) error {
+	p, err := newPolicy(policy)
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	m.policy = p
+	return nil
 }
 
 // ReservePieces selects the next piece(s) to be requested from given peer.
 // It selects peers on a rarity-first basis using numPeersByPiece.
 // If allowDuplicates is set, may return pieces which have already been
-// reserved under other peers.
+// reserved under other peers, up to the configured max duplicate requests.
 func (m *Manager) ReservePieces(
 	peerID core.PeerID,
 	candidates *bitset.BitSet,
@@ -153,6 +187,7 @@ func (m *Manager) Clear(i int) {
 	defer m.Unlock()
 
 	delete(m.requests, i)
+	delete(m.rerequests, i)
 
 	for peerID, pm := range m.requestsByPeer {
 		delete(pm, i)
@@ -162,6 +197,39 @@ func (m *Manager) Clear(i int) {
 	}
 }
 
+// MarkRerequested records a re-request of piece i after a failed request, and
+// returns the number of times i has been re-requested.
+func (m *Manager) MarkRerequested(i int) int {
+	m.Lock()
+	defer m.Unlock()
+
+	m.rerequests[i]++
+	return m.rerequests[i]
+}
+
+// Rerequests returns the number of times piece i has been re-requested.
+func (m *Manager) Rerequests(i int) int {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.rerequests[i]
+}
+
+// PendingPeers returns the peers, excluding peerID, which have pending requests
+// for piece i, regardless of whether the requests have expired.
+func (m *Manager) PendingPeers(peerID core.PeerID, i int) []core.PeerID {
+	m.RLock()
+	defer m.RUnlock()
+
+	var peers []core.PeerID
+	for _, r := range m.requests[i] {
+		if r.Status == StatusPending && r.PeerID != peerID {
+			peers = append(peers, r.PeerID)
+		}
+	}
+	return peers
+}
+
 // PendingPieces returns the pieces for all pending requests to peerID in sorted
 // order. Intended primarily for testing purposes.
 func (m *Manager) PendingPieces(peerID core.PeerID) []int {
@@ -221,7 +289,29 @@ func (m *Manager) GetFailedRequests() []Request {
 	return failed
 }
 
+// NumPending returns the number of pending requests, and the number of peers
+// with at least one pending request.
+func (m *Manager) NumPending() (requests, peers int) {
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, pm := range m.requestsByPeer {
+		var n int
+		for _, r := range pm {
+			if r.Status == StatusPending && !m.expired(r) {
+				n++
+			}
+		}
+		if n > 0 {
+			requests += n
+			peers++
+		}
+	}
+	return requests, peers
+}
+
 func (m *Manager) validRequest(peerID core.PeerID, i int, allowDuplicates bool) bool {
+	var pending int
 	for _, r := range m.requests[i] {
 		if r.Status == StatusPending && !m.expired(r) {
 			if r.PeerID == peerID {
@@ -230,8 +320,12 @@ func (m *Manager) validRequest(peerID core.PeerID, i int, allowDuplicates bool)
 			if !allowDuplicates {
 				return false
 			}
+			pending++
 		}
 	}
+	if m.maxDuplicateRequests > 0 && pending >= m.maxDuplicateRequests {
+		return false
+	}
 	return true
 }
 
@@ -31,7 +31,7 @@ func newManager(
 	policy string,
 	pipelineLimit int) *Manager {
 
-	m, err := NewManager(clk, timeout, policy, pipelineLimit)
+	m, err := NewManager(clk, timeout, policy, pipelineLimit, 0)
 	if err != nil {
 		panic(err)
 	}
@@ -62,6 +62,46 @@ func TestManagerPipelineLimit(t *testing.T) {
 	require.Len(m.PendingPieces(peerID), 3)
 }
 
+func TestManagerNumPending(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	timeout := 5 * time.Second
+
+	m := newManager(clk, timeout, DefaultPolicy, 3)
+
+	peer1 := core.PeerIDFixture()
+	peer2 := core.PeerIDFixture()
+	peer3 := core.PeerIDFixture()
+
+	requests, peers := m.NumPending()
+	require.Equal(0, requests)
+	require.Equal(0, peers)
+
+	_, err := m.ReservePieces(peer1, bitsetutil.FromBools(true, true, true, true),
+		countsFromInts(0, 0, 0, 0), false)
+	require.NoError(err)
+
+	_, err = m.ReservePieces(peer2, bitsetutil.FromBools(true, true, true, true),
+		countsFromInts(0, 0, 0, 0), false)
+	require.NoError(err)
+
+	// Peer 3 has nothing left to request.
+	_, err = m.ReservePieces(peer3, bitsetutil.FromBools(false, false, false, false),
+		countsFromInts(0, 0, 0, 0), false)
+	require.NoError(err)
+
+	requests, peers = m.NumPending()
+	require.Equal(4, requests)
+	require.Equal(2, peers)
+
+	clk.Add(timeout + 1)
+
+	requests, peers = m.NumPending()
+	require.Equal(0, requests)
+	require.Equal(0, peers)
+}
+
 func TestManagerReserveExpiredRequest(t *testing.T) {
 	require := require.New(t)
 
@@ -222,6 +262,21 @@ func TestManagerClear(t *testing.T) {
 	require.Empty(m.PendingPieces(peerID))
 }
 
+func TestManagerRerequests(t *testing.T) {
+	require := require.New(t)
+
+	m := newManager(clock.NewMock(), 5*time.Second, DefaultPolicy, 1)
+
+	require.Equal(0, m.Rerequests(0))
+	require.Equal(1, m.MarkRerequested(0))
+	require.Equal(2, m.MarkRerequested(0))
+	require.Equal(0, m.Rerequests(1))
+
+	// Clearing a piece resets its re-requests.
+	m.Clear(0)
+	require.Equal(0, m.Rerequests(0))
+}
+
 func TestManagerClearPeer(t *testing.T) {
 	require := require.New(t)
 
@@ -277,6 +332,33 @@ func TestManagerReservePiecesAllowDuplicate(t *testing.T) {
 	require.Equal([]int{0}, pieces)
 }
 
+func TestManagerReservePiecesMaxDuplicateRequests(t *testing.T) {
+	require := require.New(t)
+
+	m, err := NewManager(clock.NewMock(), 5*time.Second, DefaultPolicy, 2, 2)
+	require.NoError(err)
+
+	p1 := core.PeerIDFixture()
+	p2 := core.PeerIDFixture()
+	p3 := core.PeerIDFixture()
+
+	for _, p := range []core.PeerID{p1, p2} {
+		pieces, err := m.ReservePieces(p, bitsetutil.FromBools(true),
+			countsFromInts(0), true)
+		require.NoError(err)
+		require.Equal([]int{0}, pieces)
+	}
+
+	// Should not exceed max duplicate requests.
+	pieces, err := m.ReservePieces(p3, bitsetutil.FromBools(true),
+		countsFromInts(0), true)
+	require.NoError(err)
+	require.Empty(pieces)
+
+	require.ElementsMatch([]core.PeerID{p2}, m.PendingPeers(p1, 0))
+	require.ElementsMatch([]core.PeerID{p1, p2}, m.PendingPeers(p3, 0))
+}
+
 func TestManagerClearWhenAllowedDuplicates(t *testing.T) {
 	require := require.New(t)
 
@@ -406,3 +488,36 @@ func TestRarestFirstPolicy(t *testing.T) {
 	require.NoError(err)
 	require.Empty(pieces)
 }
+
+func TestSequentialPolicy(t *testing.T) {
+	require := require.New(t)
+
+	m := newManager(clock.NewMock(), 5*time.Second, SequentialPolicy, 2)
+
+	p1 := core.PeerIDFixture()
+	p2 := core.PeerIDFixture()
+
+	pieces, err := m.ReservePieces(p1, bitsetutil.FromBools(false, true, true, true),
+		countsFromInts(2, 3, 1, 0), false)
+	require.NoError(err)
+	require.Equal([]int{1, 2}, pieces)
+
+	pieces, err = m.ReservePieces(p2, bitsetutil.FromBools(true, true, true, true),
+		countsFromInts(2, 3, 1, 0), false)
+	require.NoError(err)
+	require.Equal([]int{0, 3}, pieces)
+}
+
+func TestManagerSetPolicy(t *testing.T) {
+	require := require.New(t)
+
+	m := newManager(clock.NewMock(), 5*time.Second, RarestFirstPolicy, 2)
+
+	require.Error(m.SetPolicy("invalid"))
+	require.NoError(m.SetPolicy(SequentialPolicy))
+
+	pieces, err := m.ReservePieces(core.PeerIDFixture(), bitsetutil.FromBools(true, true, true, true),
+		countsFromInts(3, 2, 1, 0), false)
+	require.NoError(err)
+	require.Equal([]int{0, 1}, pieces)
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package piecerequest
+
+import (
+	"github.com/uber/kraken/utils/syncutil"
+
+	"github.com/willf/bitset"
+)
+
+// SequentialPolicy selects pieces in order, such that the beginning of a blob
+// completes first. Suited for streaming blobs while they download, at the
+// cost of swarm health.
+const SequentialPolicy = "sequential"
+
+type sequentialPolicy struct{}
+
+func newSequentialPolicy() *sequentialPolicy {
+	return &sequentialPolicy{}
+}
+
+func (p *sequentialPolicy) selectPieces(
+	limit int,
+	valid func(int) bool,
+	candidates *bitset.BitSet,
+	numPeersByPiece syncutil.Counters) ([]int, error) {
+
+	pieces := make([]int, 0, limit)
+	for i, e := candidates.NextSet(0); e && len(pieces) < limit; i, e = candidates.NextSet(i + 1) {
+		if valid(int(i)) {
+			pieces = append(pieces, int(i))
+		}
+	}
+	return pieces, nil
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dispatch
+
+import (
+	"sync"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/willf/bitset"
+)
+
+// superseeder decides which pieces a complete torrent reveals to each peer
+// when super-seeding. Until every piece has been uploaded at least once, each
+// peer is offered a single piece at a time, preferring pieces which have been
+// offered to the fewest peers. This maximizes the diversity of pieces in the
+// swarm while a single seeder holds the only copy. Once every piece has been
+// uploaded, all remaining pieces are revealed to each peer. Pieces are never
+// announced to the same peer twice.
+type superseeder struct {
+	mu        sync.Mutex
+	offered   []int
+	sent      []int
+	unsent    int
+	announced map[core.PeerID]*bitset.BitSet
+}
+
+func newSuperseeder(numPieces int) *superseeder {
+	return &superseeder{
+		offered:   make([]int, numPieces),
+		sent:      make([]int, numPieces),
+		unsent:    numPieces,
+		announced: make(map[core.PeerID]*bitset.BitSet),
+	}
+}
+
+// offer returns the pieces which should be announced to peerID, given the
+// pieces peerID already has.
+func (s *superseeder) offer(peerID core.PeerID, has *bitset.BitSet) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	announced, ok := s.announced[peerID]
+	if !ok {
+		announced = bitset.New(uint(len(s.sent)))
+		s.announced[peerID] = announced
+	}
+
+	if s.unsent == 0 {
+		var pieces []int
+		for i := range s.sent {
+			if !has.Test(uint(i)) && !announced.Test(uint(i)) {
+				announced.Set(uint(i))
+				pieces = append(pieces, i)
+			}
+		}
+		return pieces
+	}
+
+	best := -1
+	for i := range s.offered {
+		if has.Test(uint(i)) || announced.Test(uint(i)) {
+			continue
+		}
+		if best == -1 || s.less(i, best) {
+			best = i
+		}
+	}
+	if best == -1 {
+		return nil
+	}
+	s.offered[best]++
+	announced.Set(uint(best))
+	return []int{best}
+}
+
+// less returns true if piece i should be offered before piece j. Pieces which
+// have never been uploaded are preferred, then pieces offered to fewer peers.
+func (s *superseeder) less(i, j int) bool {
+	if (s.sent[i] == 0) != (s.sent[j] == 0) {
+		return s.sent[i] == 0
+	}
+	return s.offered[i] < s.offered[j]
+}
+
+// markSent records that piece i was uploaded. Returns true if i was the last
+// piece to be uploaded for the first time, i.e. all pieces should now be
+// revealed.
+func (s *superseeder) markSent(i int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent[i]++
+	if s.sent[i] == 1 {
+		s.unsent--
+		return s.unsent == 0
+	}
+	return false
+}
+
+// removePeer clears any state held for peerID.
+func (s *superseeder) removePeer(peerID core.PeerID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.announced, peerID)
+}
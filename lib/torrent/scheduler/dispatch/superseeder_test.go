@@ -0,0 +1,101 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dispatch
+
+import (
+	"testing"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/utils/bitsetutil"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuperseederOffersDistinctPieces(t *testing.T) {
+	require := require.New(t)
+
+	s := newSuperseeder(3)
+
+	none := bitsetutil.FromBools(false, false, false)
+
+	var offered []int
+	for i := 0; i < 3; i++ {
+		pieces := s.offer(core.PeerIDFixture(), none)
+		require.Len(pieces, 1)
+		offered = append(offered, pieces[0])
+	}
+	require.ElementsMatch([]int{0, 1, 2}, offered)
+}
+
+func TestSuperseederSkipsPiecesPeerHas(t *testing.T) {
+	require := require.New(t)
+
+	s := newSuperseeder(3)
+
+	require.Equal([]int{2}, s.offer(core.PeerIDFixture(), bitsetutil.FromBools(true, true, false)))
+	require.Empty(s.offer(core.PeerIDFixture(), bitsetutil.FromBools(true, true, true)))
+}
+
+func TestSuperseederPrefersUnsentPieces(t *testing.T) {
+	require := require.New(t)
+
+	s := newSuperseeder(2)
+
+	none := bitsetutil.FromBools(false, false)
+
+	require.Equal([]int{0}, s.offer(core.PeerIDFixture(), none))
+	require.False(s.markSent(0))
+
+	// Piece 1 has been offered to more peers, but has never been sent.
+	require.Equal([]int{1}, s.offer(core.PeerIDFixture(), none))
+	require.Equal([]int{1}, s.offer(core.PeerIDFixture(), none))
+}
+
+func TestSuperseederRevealsAllPiecesOnceSent(t *testing.T) {
+	require := require.New(t)
+
+	s := newSuperseeder(3)
+
+	require.False(s.markSent(0))
+	require.False(s.markSent(0))
+	require.False(s.markSent(1))
+	require.True(s.markSent(2))
+
+	peerID := core.PeerIDFixture()
+
+	require.Equal([]int{0, 2}, s.offer(peerID, bitsetutil.FromBools(false, true, false)))
+
+	// Pieces are only revealed once per peer.
+	require.Empty(s.offer(peerID, bitsetutil.FromBools(false, true, false)))
+
+	s.removePeer(peerID)
+	require.Equal([]int{0, 2}, s.offer(peerID, bitsetutil.FromBools(false, true, false)))
+}
+
+func TestSuperseederRevealSkipsAnnouncedPieces(t *testing.T) {
+	require := require.New(t)
+
+	s := newSuperseeder(3)
+
+	peerID := core.PeerIDFixture()
+	none := bitsetutil.FromBools(false, false, false)
+
+	require.Equal([]int{0}, s.offer(peerID, none))
+	require.False(s.markSent(0))
+	require.Equal([]int{1}, s.offer(peerID, none))
+	require.False(s.markSent(1))
+	require.True(s.markSent(2))
+
+	require.Equal([]int{2}, s.offer(peerID, none))
+}
@@ -21,6 +21,8 @@ import (
 	"github.com/uber/kraken/lib/torrent/scheduler/conn"
 	"github.com/uber/kraken/lib/torrent/scheduler/connstate"
 	"github.com/uber/kraken/lib/torrent/scheduler/dispatch"
+	"github.com/uber/kraken/lib/torrent/scheduler/peerselector"
+	"github.com/uber/kraken/lib/torrent/scheduler/reputation"
 	"github.com/uber/kraken/lib/torrent/storage"
 	"github.com/uber/kraken/utils/memsize"
 	"github.com/uber/kraken/utils/timeutil"
@@ -116,6 +118,10 @@ func (l *liftedEventLoop) PeerRemoved(peerID core.PeerID, h core.InfoHash) {
 	l.send(peerRemovedEvent{peerID, h})
 }
 
+func (l *liftedEventLoop) PeerMisbehaved(peerID core.PeerID, h core.InfoHash, o reputation.Offense) {
+	l.send(peerMisbehavedEvent{peerID, h, o})
+}
+
 func (l *liftedEventLoop) AnnounceTick() {
 	l.send(announceTickEvent{})
 }
@@ -125,12 +131,29 @@ type connClosedEvent struct {
 	c *conn.Conn
 }
 
-// apply ejects the conn from the scheduler's active connections.
+// apply ejects the conn from the scheduler's active connections. Peers which
+// close connections before the local torrent is complete are penalized.
 func (e connClosedEvent) apply(s *state) {
 	s.conns.DeleteActive(e.c)
 	if err := s.conns.Blacklist(e.c.PeerID(), e.c.InfoHash()); err != nil {
 		s.log("conn", e.c).Infof("Cannot blacklist active conn: %s", err)
 	}
+	if ctrl, ok := s.torrentControls[e.c.InfoHash()]; ok {
+		if e.c.ClosedByRemote() && !ctrl.dispatcher.Complete() {
+			s.penalize(e.c.PeerID(), e.c.InfoHash(), reputation.Disconnect)
+		}
+	}
+}
+
+// peerMisbehavedEvent occurs when a dispatcher detects an offense by a peer.
+type peerMisbehavedEvent struct {
+	peerID   core.PeerID
+	infoHash core.InfoHash
+	offense  reputation.Offense
+}
+
+func (e peerMisbehavedEvent) apply(s *state) {
+	s.penalize(e.peerID, e.infoHash, e.offense)
 }
 
 // incomingHandshakeEvent when a handshake was received from a new connection.
@@ -149,7 +172,12 @@ func (e incomingHandshakeEvent) apply(s *state) {
 		peerNeighbors[i] = peerID
 		i++
 	}
-	if err := s.conns.AddPending(e.pc.PeerID(), e.pc.InfoHash(), peerNeighbors); err != nil {
+	err := s.conns.AddPending(e.pc.PeerID(), e.pc.InfoHash(), peerNeighbors)
+	if err == nil && s.reputation.Banned(e.pc.PeerID()) {
+		s.conns.DeletePending(e.pc.PeerID(), e.pc.InfoHash())
+		err = errPeerBanned
+	}
+	if err != nil {
 		s.log("peer", e.pc.PeerID(), "hash", e.pc.InfoHash()).Infof(
 			"Rejecting incoming handshake: %s", err)
 		s.sched.torrentlog.IncomingConnectionReject(e.pc.Digest(), e.pc.InfoHash(), e.pc.PeerID(), err)
@@ -246,6 +274,11 @@ func (e announceTickEvent) apply(s *state) {
 			s.log("hash", h).Error("Pulled unknown torrent off announce queue")
 			continue
 		}
+		if ctrl.dispatcher.Complete() && s.sched.InMaintenance() {
+			s.log("hash", h).Debug("Skipping announce for seeded torrent in maintenance")
+			skipped = append(skipped, h)
+			continue
+		}
 		go s.sched.announce(
 			ctrl.dispatcher.Digest(), ctrl.dispatcher.InfoHash(), ctrl.dispatcher.Complete())
 		break
@@ -266,7 +299,8 @@ type announceResultEvent struct {
 
 // apply selects new peers returned via an announce response to open connections to
 // if there is capacity. These connections are added to the scheduler's pending
-// connections and handshaked asynchronously.
+// connections and handshaked asynchronously. Which peers are connected to, and in
+// which order, is decided by the scheduler's peer selector.
 //
 // Also marks the dispatcher as ready to announce again.
 func (e announceResultEvent) apply(s *state) {
@@ -276,26 +310,40 @@ func (e announceResultEvent) apply(s *state) {
 		return
 	}
 	s.announceQueue.Ready(e.infoHash)
+	s.sched.netevents.Produce(
+		networkevent.AnnounceEvent(e.infoHash, s.sched.pctx.PeerID, len(e.peers)))
 	if ctrl.dispatcher.Complete() {
 		// Torrent is already complete, don't open any new connections.
 		return
 	}
+	var candidates []*core.PeerInfo
 	for _, p := range e.peers {
 		if p.PeerID == s.sched.pctx.PeerID {
 			// Tracker may return our own peer.
 			continue
 		}
-		if s.conns.Blacklisted(p.PeerID, e.infoHash) {
+		if s.conns.Blacklisted(p.PeerID, e.infoHash) || s.reputation.Banned(p.PeerID) {
 			continue
 		}
+		candidates = append(candidates, p)
+	}
+	info := ctrl.dispatcher.Stat()
+	selected := s.sched.peerSelector.Select(candidates, peerselector.TorrentState{
+		InfoHash:          e.infoHash,
+		Digest:            ctrl.dispatcher.Digest(),
+		Namespace:         ctrl.namespace,
+		LocalPeer:         s.sched.pctx,
+		PercentDownloaded: info.PercentDownloaded(),
+	})
+	for _, p := range selected {
 		if err := s.conns.AddPending(p.PeerID, e.infoHash, nil); err != nil {
-			if err == connstate.ErrTorrentAtCapacity {
+			if err == connstate.ErrTorrentAtCapacity || err == connstate.ErrGlobalAtCapacity {
 				break
 			}
 			continue
 		}
 		go s.sched.initializeOutgoingHandshake(
-			p, ctrl.dispatcher.Stat(), ctrl.dispatcher.RemoteBitfields(), ctrl.namespace)
+			p, info, ctrl.dispatcher.RemoteBitfields(), ctrl.namespace)
 	}
 }
 
@@ -322,6 +370,10 @@ type newTorrentEvent struct {
 func (e newTorrentEvent) apply(s *state) {
 	ctrl, ok := s.torrentControls[e.torrent.InfoHash()]
 	if !ok {
+		if s.sched.InMaintenance() {
+			e.errc <- ErrMaintenance
+			return
+		}
 		var err error
 		ctrl, err = s.addTorrent(e.namespace, e.torrent, true)
 		if err != nil {
@@ -373,6 +425,10 @@ func (e dispatcherCompleteEvent) apply(s *state) {
 	s.log("hash", infoHash).Info("Torrent complete")
 	s.sched.netevents.Produce(networkevent.TorrentCompleteEvent(infoHash, s.sched.pctx.PeerID))
 
+	if s.sched.InMaintenance() {
+		return
+	}
+
 	// Immediately announce completed torrents.
 	go s.sched.announce(ctrl.dispatcher.Digest(), ctrl.dispatcher.InfoHash(), true)
 }
@@ -405,11 +461,13 @@ func (e preemptionTickEvent) apply(s *state) {
 			ctrl.dispatcher.LastPieceSent(c.PeerID()))
 		if s.sched.clock.Now().Sub(lastProgress) > s.sched.config.ConnTTI {
 			s.log("conn", c).Info("Closing idle conn")
+			s.sched.stats.Counter("idle_conns_closed").Inc(1)
 			c.Close()
 			continue
 		}
 		if s.sched.clock.Now().Sub(c.CreatedAt()) > s.sched.config.ConnTTL {
 			s.log("conn", c).Info("Closing expired conn")
+			s.sched.stats.Counter("expired_conns_closed").Inc(1)
 			c.Close()
 			continue
 		}
@@ -432,6 +490,14 @@ func (e preemptionTickEvent) apply(s *state) {
 
 		if idleSeeder || idleLeecher {
 			s.log("hash", h, "inprogress", !ctrl.dispatcher.Complete()).Info("Removing idle torrent")
+			s.sched.stats.Counter("idle_torrents_removed").Inc(1)
+			s.removeTorrent(h, ErrTorrentTimeout)
+			continue
+		}
+
+		if ctrl.dispatcher.Complete() && s.seedLimitReached(ctrl.dispatcher) {
+			s.log("hash", h).Info("Removing torrent which reached seed limits")
+			s.sched.stats.Counter("seed_limit_torrents_removed").Inc(1)
 			s.removeTorrent(h, ErrTorrentTimeout)
 		}
 	}
@@ -442,6 +508,30 @@ type emitStatsEvent struct{}
 
 func (e emitStatsEvent) apply(s *state) {
 	s.sched.stats.Gauge("torrents").Update(float64(len(s.torrentControls)))
+
+	pending, active := s.conns.NumConns()
+	s.sched.stats.Gauge("pending_conns").Update(float64(pending))
+	s.sched.stats.Gauge("active_conns").Update(float64(active))
+
+	s.sched.stats.Gauge("banned_peers").Update(float64(len(s.reputation.BannedSnapshot())))
+
+	for h, ctrl := range s.torrentControls {
+		s.reportUploads(h, ctrl)
+	}
+
+	// Average pipeline depth of peers with in-flight piece requests, for tuning
+	// the dispatch pipeline limit.
+	var requests, peers int
+	for _, ctrl := range s.torrentControls {
+		r, p := ctrl.dispatcher.PendingPieceRequests()
+		requests += r
+		peers += p
+	}
+	s.sched.stats.Gauge("inflight_piece_requests").Update(float64(requests))
+	if peers > 0 {
+		s.sched.stats.Gauge("avg_inflight_piece_requests_per_peer").Update(
+			float64(requests) / float64(peers))
+	}
 }
 
 type blacklistSnapshotEvent struct {
@@ -452,6 +542,60 @@ func (e blacklistSnapshotEvent) apply(s *state) {
 	e.result <- s.conns.BlacklistSnapshot()
 }
 
+type bannedPeersSnapshotEvent struct {
+	result chan []reputation.BannedPeer
+}
+
+func (e bannedPeersSnapshotEvent) apply(s *state) {
+	e.result <- s.reputation.BannedSnapshot()
+}
+
+// numActiveTorrentsEvent occurs when the number of active torrents is requested
+// via scheduler API.
+type numActiveTorrentsEvent struct {
+	result chan int
+}
+
+func (e numActiveTorrentsEvent) apply(s *state) {
+	e.result <- len(s.torrentControls)
+}
+
+// torrentStatsEvent occurs when torrent stats are requested via scheduler API.
+// If infoHash is nil, stats for all torrents are returned.
+type torrentStatsEvent struct {
+	infoHash *core.InfoHash
+	result   chan []TorrentStats
+}
+
+func (e torrentStatsEvent) apply(s *state) {
+	stats := []TorrentStats{}
+	for h, ctrl := range s.torrentControls {
+		if e.infoHash != nil && *e.infoHash != h {
+			continue
+		}
+		stats = append(stats, s.torrentStats(h, ctrl))
+	}
+	e.result <- stats
+}
+
+// setPieceRequestPolicyEvent occurs when the piece request policy of a torrent
+// is changed via scheduler API.
+type setPieceRequestPolicyEvent struct {
+	digest core.Digest
+	policy string
+	errc   chan error
+}
+
+func (e setPieceRequestPolicyEvent) apply(s *state) {
+	for _, ctrl := range s.torrentControls {
+		if ctrl.dispatcher.Digest() == e.digest {
+			e.errc <- ctrl.dispatcher.SetPieceRequestPolicy(e.policy)
+			return
+		}
+	}
+	e.errc <- ErrTorrentNotFound
+}
+
 // removeTorrentEvent occurs when a torrent is manually removed via scheduler API.
 type removeTorrentEvent struct {
 	digest core.Digest
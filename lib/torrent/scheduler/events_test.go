@@ -26,6 +26,7 @@ import (
 	"github.com/uber/kraken/lib/torrent/scheduler/announcequeue"
 	"github.com/uber/kraken/lib/torrent/scheduler/conn"
 	"github.com/uber/kraken/lib/torrent/scheduler/connstate"
+	"github.com/uber/kraken/lib/torrent/scheduler/reputation"
 	"github.com/uber/kraken/lib/torrent/storage"
 	"github.com/uber/kraken/lib/torrent/storage/agentstorage"
 	mockannounceclient "github.com/uber/kraken/mocks/tracker/announceclient"
@@ -229,3 +230,44 @@ func TestAnnounceTickEventSkipsFullTorrents(t *testing.T) {
 		infoHash: full.dispatcher.InfoHash(),
 	})
 }
+
+func TestPeerMisbehavedEventBansPeer(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newStateMocks(t)
+	defer cleanup()
+
+	state := mocks.newState(Config{
+		Reputation: reputation.Config{
+			BadPiecePenalty: 10,
+			BanThreshold:    20,
+		},
+	})
+
+	ctrl, err := state.addTorrent(_testNamespace, mocks.newTorrent(), true)
+	require.NoError(err)
+
+	info := ctrl.dispatcher.Stat()
+
+	_, c, cleanup := conn.PipeFixture(conn.Config{}, info)
+	defer cleanup()
+
+	require.NoError(state.conns.AddPending(c.PeerID(), c.InfoHash(), nil))
+	require.NoError(state.addOutgoingConn(c, info.Bitfield(), info))
+
+	peerMisbehavedEvent{c.PeerID(), c.InfoHash(), reputation.BadPiece}.apply(state)
+	require.False(c.IsClosed())
+
+	peerMisbehavedEvent{c.PeerID(), c.InfoHash(), reputation.BadPiece}.apply(state)
+	require.True(c.IsClosed())
+	require.True(state.reputation.Banned(c.PeerID()))
+	require.Equal(1, state.torrentStats(c.InfoHash(), ctrl).BannedPeers)
+
+	// Banned peers are not connected to when announced.
+	state.conns.DeleteActive(c)
+	peer := core.PeerInfoFixture()
+	peer.PeerID = c.PeerID()
+	announceResultEvent{c.InfoHash(), []*core.PeerInfo{peer}}.apply(state)
+	pending, _ := state.conns.NumConns()
+	require.Equal(0, pending)
+}
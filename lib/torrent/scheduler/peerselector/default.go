@@ -0,0 +1,32 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package peerselector
+
+import "github.com/uber/kraken/core"
+
+// DefaultStrategy selects all candidates, preferring peers within the local
+// zone. The tracker's ordering is otherwise preserved.
+const DefaultStrategy = "default"
+
+type defaultSelector struct{}
+
+func newDefaultSelector() *defaultSelector {
+	return &defaultSelector{}
+}
+
+func (s *defaultSelector) Select(
+	candidates []*core.PeerInfo, state TorrentState) []*core.PeerInfo {
+
+	return core.SortedByZone(candidates, state.LocalPeer.Zone)
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package peerselector
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/utils/stringset"
+)
+
+// neighborSelector prefers explicitly configured neighbor peers, e.g. agents
+// on the same rack, over all other candidates. Neighbors and the remaining
+// candidates are each ordered by the underlying Selector.
+type neighborSelector struct {
+	selector Selector
+
+	// addrs contains neighbors configured with a port, and ips contains
+	// neighbors configured without one.
+	addrs stringset.Set
+	ips   stringset.Set
+}
+
+// WithNeighbors wraps s such that candidates matching neighbors are selected
+// before all others. Neighbors are IP addresses, optionally with a port, e.g.
+// "10.0.0.2" or "10.0.0.2:16001". Neighbors are still only selected if
+// returned as candidates by the tracker, which agents ask to include them.
+// Returns s if neighbors is empty.
+func WithNeighbors(s Selector, neighbors []string) (Selector, error) {
+	if len(neighbors) == 0 {
+		return s, nil
+	}
+	ns := &neighborSelector{
+		selector: s,
+		addrs:    stringset.New(),
+		ips:      stringset.New(),
+	}
+	for _, n := range neighbors {
+		if ip := net.ParseIP(n); ip != nil {
+			ns.ips.Add(ip.String())
+			continue
+		}
+		host, port, err := net.SplitHostPort(n)
+		if err != nil {
+			return nil, fmt.Errorf("invalid neighbor %q: %s", n, err)
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid neighbor %q: host must be an ip", n)
+		}
+		if _, err := strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("invalid neighbor %q: invalid port", n)
+		}
+		ns.addrs.Add(net.JoinHostPort(ip.String(), port))
+	}
+	return ns, nil
+}
+
+func (s *neighborSelector) Select(
+	candidates []*core.PeerInfo, state TorrentState) []*core.PeerInfo {
+
+	var neighbors, others []*core.PeerInfo
+	for _, p := range candidates {
+		if s.isNeighbor(p) {
+			neighbors = append(neighbors, p)
+		} else {
+			others = append(others, p)
+		}
+	}
+	var result []*core.PeerInfo
+	if len(neighbors) > 0 {
+		result = append(result, s.selector.Select(neighbors, state)...)
+	}
+	if len(others) > 0 {
+		result = append(result, s.selector.Select(others, state)...)
+	}
+	return result
+}
+
+func (s *neighborSelector) isNeighbor(p *core.PeerInfo) bool {
+	ip := net.ParseIP(p.IP)
+	if ip == nil {
+		return false
+	}
+	return s.ips.Has(ip.String()) ||
+		s.addrs.Has(net.JoinHostPort(ip.String(), strconv.Itoa(p.Port)))
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package peerselector
+
+import (
+	"math/rand"
+
+	"github.com/uber/kraken/core"
+)
+
+// RandomStrategy selects all candidates in random order, ignoring zones.
+const RandomStrategy = "random"
+
+type randomSelector struct{}
+
+func newRandomSelector() *randomSelector {
+	return &randomSelector{}
+}
+
+func (s *randomSelector) Select(
+	candidates []*core.PeerInfo, state TorrentState) []*core.PeerInfo {
+
+	result := make([]*core.PeerInfo, len(candidates))
+	for i, j := range rand.Perm(len(candidates)) {
+		result[i] = candidates[j]
+	}
+	return result
+}
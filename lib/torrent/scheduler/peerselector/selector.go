@@ -0,0 +1,83 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package peerselector
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/uber/kraken/core"
+)
+
+// TorrentState describes the torrent which peers are being selected for.
+type TorrentState struct {
+	InfoHash  core.InfoHash
+	Digest    core.Digest
+	Namespace string
+
+	// LocalPeer is the context of the local peer, e.g. for zone-aware
+	// selection.
+	LocalPeer core.PeerContext
+
+	// PercentDownloaded is the download progress of the torrent.
+	PercentDownloaded int
+}
+
+// Selector selects which peers returned by an announce to open connections to.
+// Given candidate peers, Select returns the subset of candidates worth
+// connecting to, ordered by preference. Connections are opened in order until
+// the torrent runs out of connection capacity. Candidates never include the
+// local peer nor blacklisted peers.
+//
+// Select is called from the scheduler event loop and must not block.
+type Selector interface {
+	Select(candidates []*core.PeerInfo, state TorrentState) []*core.PeerInfo
+}
+
+// Factory creates a Selector.
+type Factory func() Selector
+
+var (
+	_factoriesMu sync.RWMutex
+	_factories   = map[string]Factory{
+		DefaultStrategy: func() Selector { return newDefaultSelector() },
+		RandomStrategy:  func() Selector { return newRandomSelector() },
+	}
+)
+
+// Register makes a custom Selector available by name, such that it can be
+// configured as the scheduler's peer selection strategy. Register panics if
+// name is already registered. Must be called before the scheduler is created,
+// typically from an init function.
+func Register(name string, f Factory) {
+	_factoriesMu.Lock()
+	defer _factoriesMu.Unlock()
+
+	if _, ok := _factories[name]; ok {
+		panic(fmt.Sprintf("peer selection strategy %q is already registered", name))
+	}
+	_factories[name] = f
+}
+
+// New creates the Selector registered under strategy.
+func New(strategy string) (Selector, error) {
+	_factoriesMu.RLock()
+	defer _factoriesMu.RUnlock()
+
+	f, ok := _factories[strategy]
+	if !ok {
+		return nil, fmt.Errorf("invalid peer selection strategy: %s", strategy)
+	}
+	return f(), nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package peerselector
+
+import (
+	"testing"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/stretchr/testify/require"
+)
+
+func peersFixture(zones ...string) []*core.PeerInfo {
+	var peers []*core.PeerInfo
+	for _, z := range zones {
+		p := core.PeerInfoFixture()
+		p.Zone = z
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+func TestDefaultSelectorPrefersLocalZone(t *testing.T) {
+	require := require.New(t)
+
+	s, err := New(DefaultStrategy)
+	require.NoError(err)
+
+	peers := peersFixture("zone2", "zone1", "zone2", "zone1")
+
+	result := s.Select(peers, TorrentState{LocalPeer: core.PeerContext{Zone: "zone1"}})
+	require.Equal([]*core.PeerInfo{peers[1], peers[3], peers[0], peers[2]}, result)
+}
+
+func TestRandomSelectorSelectsAllCandidates(t *testing.T) {
+	require := require.New(t)
+
+	s, err := New(RandomStrategy)
+	require.NoError(err)
+
+	peers := peersFixture("zone1", "zone2", "zone3", "zone4", "zone5")
+
+	result := s.Select(peers, TorrentState{})
+	require.ElementsMatch(peers, result)
+}
+
+type firstSelector struct{}
+
+func (firstSelector) Select(candidates []*core.PeerInfo, state TorrentState) []*core.PeerInfo {
+	return candidates[:1]
+}
+
+func TestRegister(t *testing.T) {
+	require := require.New(t)
+
+	Register("first", func() Selector { return firstSelector{} })
+
+	s, err := New("first")
+	require.NoError(err)
+
+	peers := peersFixture("zone1", "zone2")
+	require.Equal(peers[:1], s.Select(peers, TorrentState{}))
+
+	require.Panics(func() {
+		Register("first", func() Selector { return firstSelector{} })
+	})
+}
+
+func TestNewInvalidStrategy(t *testing.T) {
+	_, err := New("invalid")
+	require.Error(t, err)
+}
+
+func TestWithNeighborsPrefersNeighbors(t *testing.T) {
+	require := require.New(t)
+
+	s, err := New(DefaultStrategy)
+	require.NoError(err)
+
+	peers := peersFixture("zone2", "zone1", "zone2", "zone1")
+	peers[0].IP, peers[0].Port = "10.0.0.1", 16001
+	peers[2].IP, peers[2].Port = "10.0.0.2", 16001
+	peers[3].IP, peers[3].Port = "10.0.0.2", 16002
+
+	s, err = WithNeighbors(s, []string{"10.0.0.1", "10.0.0.2:16002"})
+	require.NoError(err)
+
+	result := s.Select(peers, TorrentState{LocalPeer: core.PeerContext{Zone: "zone1"}})
+	require.Equal([]*core.PeerInfo{peers[3], peers[0], peers[1], peers[2]}, result)
+}
+
+func TestWithNeighborsEmpty(t *testing.T) {
+	require := require.New(t)
+
+	s, err := New(DefaultStrategy)
+	require.NoError(err)
+
+	result, err := WithNeighbors(s, nil)
+	require.NoError(err)
+	require.Equal(s, result)
+}
+
+func TestWithNeighborsInvalid(t *testing.T) {
+	for _, n := range []string{"kraken-agent", "kraken-agent:16001", "10.0.0.1:port"} {
+		t.Run(n, func(t *testing.T) {
+			s, err := New(DefaultStrategy)
+			require.NoError(t, err)
+
+			_, err = WithNeighbors(s, []string{n})
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// PortRange is an inclusive range of ports which the scheduler may listen on
+// for peer connections.
+type PortRange struct {
+	Min int `yaml:"min"`
+	Max int `yaml:"max"`
+}
+
+// IsSet returns true if r is configured.
+func (r PortRange) IsSet() bool {
+	return r.Min != 0 || r.Max != 0
+}
+
+func (r PortRange) validate() error {
+	if r.Min < 1 || r.Max > 65535 {
+		return fmt.Errorf("ports must be within 1-65535, got %d-%d", r.Min, r.Max)
+	}
+	if r.Min > r.Max {
+		return fmt.Errorf("min %d is greater than max %d", r.Min, r.Max)
+	}
+	return nil
+}
+
+// SelectPeerPort returns the lowest port within r, other than the exclude
+// ports, which is free to listen on at bindIP. Ports which other servers of
+// the process have yet to listen on must be excluded. The port is released
+// before returning, such that the scheduler can listen on it, so another
+// process may still claim it in the meantime.
+func SelectPeerPort(bindIP string, r PortRange, exclude ...int) (int, error) {
+	if !r.IsSet() {
+		return 0, errors.New("no port range configured")
+	}
+	if err := r.validate(); err != nil {
+		return 0, fmt.Errorf("invalid port range: %s", err)
+	}
+	excluded := make(map[int]bool)
+	for _, port := range exclude {
+		excluded[port] = true
+	}
+	for port := r.Min; port <= r.Max; port++ {
+		if excluded[port] {
+			continue
+		}
+		l, err := net.Listen("tcp", net.JoinHostPort(bindIP, strconv.Itoa(port)))
+		if err != nil {
+			continue
+		}
+		l.Close()
+		return port, nil
+	}
+	return 0, fmt.Errorf("no free port within %d-%d", r.Min, r.Max)
+}
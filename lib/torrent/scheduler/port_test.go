@@ -0,0 +1,89 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package scheduler
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func listenAny(t *testing.T) (net.Listener, int) {
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	return l, l.Addr().(*net.TCPAddr).Port
+}
+
+func TestSelectPeerPortSkipsPortsInUse(t *testing.T) {
+	require := require.New(t)
+
+	l, port := listenAny(t)
+	defer l.Close()
+
+	// The next port may also be in use on a busy host, in which case the
+	// range is exhausted.
+	result, err := SelectPeerPort("localhost", PortRange{port, port + 1})
+	if err == nil {
+		require.Equal(port+1, result)
+	}
+
+	_, err = SelectPeerPort("localhost", PortRange{port, port})
+	require.Error(err)
+}
+
+func TestSelectPeerPortReleasesPort(t *testing.T) {
+	require := require.New(t)
+
+	l, port := listenAny(t)
+	require.NoError(l.Close())
+
+	result, err := SelectPeerPort("localhost", PortRange{port, port})
+	require.NoError(err)
+	require.Equal(port, result)
+
+	// The scheduler must be able to listen on the selected port.
+	l, err = net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(result)))
+	require.NoError(err)
+	l.Close()
+}
+
+func TestSelectPeerPortSkipsExcludedPorts(t *testing.T) {
+	require := require.New(t)
+
+	l, port := listenAny(t)
+	require.NoError(l.Close())
+
+	_, err := SelectPeerPort("localhost", PortRange{port, port}, port)
+	require.Error(err)
+}
+
+func TestSelectPeerPortInvalidRange(t *testing.T) {
+	tests := []struct {
+		desc string
+		r    PortRange
+	}{
+		{"unset", PortRange{}},
+		{"min greater than max", PortRange{9000, 8000}},
+		{"max too large", PortRange{8000, 70000}},
+		{"min too small", PortRange{0, 8000}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := SelectPeerPort("localhost", test.r)
+			require.Error(t, err)
+		})
+	}
+}
@@ -53,12 +53,14 @@ func (rs *reloadableScheduler) reload(config Config) error {
 
 	s := rs.scheduler
 	s.Stop()
+	maintenance := s.InMaintenance()
 
 	n, err := newScheduler(
 		config, s.torrentArchive, s.stats, s.pctx, s.announceClient, s.netevents)
 	if err != nil {
 		return fmt.Errorf("create new scheduler: %s", err)
 	}
+	n.SetMaintenance(maintenance)
 	rs.scheduler = n
 
 	if err := rs.scheduler.start(rs.aq()); err != nil {
@@ -0,0 +1,68 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package reputation
+
+import "time"
+
+// Config defines Tracker configuration.
+type Config struct {
+
+	// DisableBans disables the banning of peers. Offenses are still counted.
+	// Should only be used for testing purposes.
+	DisableBans bool `yaml:"disable_bans"`
+
+	// BadPiecePenalty is the score penalty for sending a piece which fails
+	// verification against the torrent metainfo.
+	BadPiecePenalty int `yaml:"bad_piece_penalty"`
+
+	// DisconnectPenalty is the score penalty for closing a connection before
+	// the local torrent is complete.
+	DisconnectPenalty int `yaml:"disconnect_penalty"`
+
+	// UnavailablePiecePenalty is the score penalty for failing a request for a
+	// piece the peer announced it has.
+	UnavailablePiecePenalty int `yaml:"unavailable_piece_penalty"`
+
+	// BanThreshold is the score at which a peer is banned.
+	BanThreshold int `yaml:"ban_threshold"`
+
+	// BanDuration is the duration a peer remains banned.
+	BanDuration time.Duration `yaml:"ban_duration"`
+
+	// PenaltyTTL is the duration after which a peer's score is reset if it
+	// commits no further offenses.
+	PenaltyTTL time.Duration `yaml:"penalty_ttl"`
+}
+
+func (c Config) applyDefaults() Config {
+	if c.BadPiecePenalty == 0 {
+		c.BadPiecePenalty = 10
+	}
+	if c.DisconnectPenalty == 0 {
+		c.DisconnectPenalty = 2
+	}
+	if c.UnavailablePiecePenalty == 0 {
+		c.UnavailablePiecePenalty = 5
+	}
+	if c.BanThreshold == 0 {
+		c.BanThreshold = 30
+	}
+	if c.BanDuration == 0 {
+		c.BanDuration = 10 * time.Minute
+	}
+	if c.PenaltyTTL == 0 {
+		c.PenaltyTTL = 10 * time.Minute
+	}
+	return c
+}
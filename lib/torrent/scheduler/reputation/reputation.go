@@ -0,0 +1,170 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package reputation
+
+import (
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
+)
+
+// Offense is a type of peer misbehavior.
+type Offense int
+
+const (
+	// BadPiece is sending a piece which fails verification.
+	BadPiece Offense = iota
+
+	// Disconnect is closing a connection before the local torrent is complete.
+	Disconnect
+
+	// UnavailablePiece is failing a request for an announced piece.
+	UnavailablePiece
+)
+
+func (o Offense) String() string {
+	switch o {
+	case BadPiece:
+		return "bad_piece"
+	case Disconnect:
+		return "disconnect"
+	case UnavailablePiece:
+		return "unavailable_piece"
+	default:
+		return "unknown"
+	}
+}
+
+type entry struct {
+	score       int
+	lastOffense time.Time
+
+	// Set when the peer is banned.
+	bannedUntil time.Time
+	infoHash    core.InfoHash
+}
+
+// BannedPeer represents a peer which has been banned.
+type BannedPeer struct {
+	PeerID core.PeerID `json:"peer_id"`
+
+	// InfoHash is the torrent of the offense which triggered the ban.
+	InfoHash  core.InfoHash `json:"info_hash"`
+	Remaining time.Duration `json:"remaining"`
+}
+
+// Tracker maintains a reputation score for each peer, which increases with
+// every offense the peer commits. Peers whose score reaches the configured
+// threshold are banned from all torrents for the configured duration, after
+// which their score starts over.
+//
+// Note, Tracker is NOT thread-safe. Synchronization must be provided by the
+// client.
+type Tracker struct {
+	config Config
+	clk    clock.Clock
+	stats  tally.Scope
+	peers  map[core.PeerID]*entry
+}
+
+// New creates a new Tracker.
+func New(config Config, clk clock.Clock, stats tally.Scope) *Tracker {
+	config = config.applyDefaults()
+
+	return &Tracker{
+		config: config,
+		clk:    clk,
+		stats:  stats.SubScope("reputation"),
+		peers:  make(map[core.PeerID]*entry),
+	}
+}
+
+func (t *Tracker) penalty(o Offense) int {
+	switch o {
+	case BadPiece:
+		return t.config.BadPiecePenalty
+	case Disconnect:
+		return t.config.DisconnectPenalty
+	case UnavailablePiece:
+		return t.config.UnavailablePiecePenalty
+	default:
+		return 0
+	}
+}
+
+// Penalize records offense o by peerID on torrent h. Returns true if the
+// offense caused peerID to be banned.
+func (t *Tracker) Penalize(peerID core.PeerID, h core.InfoHash, o Offense) bool {
+	t.stats.Tagged(map[string]string{"offense": o.String()}).Counter("offenses").Inc(1)
+
+	now := t.clk.Now()
+	e, ok := t.peers[peerID]
+	if !ok {
+		e = &entry{}
+		t.peers[peerID] = e
+	}
+	if e.bannedUntil.After(now) {
+		return false
+	}
+	if now.Sub(e.lastOffense) > t.config.PenaltyTTL {
+		e.score = 0
+	}
+	e.score += t.penalty(o)
+	e.lastOffense = now
+	if t.config.DisableBans || e.score < t.config.BanThreshold {
+		return false
+	}
+	e.score = 0
+	e.bannedUntil = now.Add(t.config.BanDuration)
+	e.infoHash = h
+	t.stats.Counter("bans").Inc(1)
+	return true
+}
+
+// Banned returns true if peerID is banned.
+func (t *Tracker) Banned(peerID core.PeerID) bool {
+	e, ok := t.peers[peerID]
+	return ok && e.bannedUntil.After(t.clk.Now())
+}
+
+// Score returns the current score of peerID.
+func (t *Tracker) Score(peerID core.PeerID) int {
+	e, ok := t.peers[peerID]
+	if !ok || t.clk.Now().Sub(e.lastOffense) > t.config.PenaltyTTL {
+		return 0
+	}
+	return e.score
+}
+
+// BannedSnapshot returns a snapshot of all banned peers. Expired entries are
+// cleaned up in the process.
+func (t *Tracker) BannedSnapshot() []BannedPeer {
+	now := t.clk.Now()
+	var banned []BannedPeer
+	for peerID, e := range t.peers {
+		if e.bannedUntil.After(now) {
+			banned = append(banned, BannedPeer{
+				PeerID:    peerID,
+				InfoHash:  e.infoHash,
+				Remaining: e.bannedUntil.Sub(now),
+			})
+		} else if now.Sub(e.lastOffense) > t.config.PenaltyTTL {
+			delete(t.peers, peerID)
+		}
+	}
+	return banned
+}
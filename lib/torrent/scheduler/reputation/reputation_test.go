@@ -0,0 +1,98 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package reputation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func TestTrackerBansPeerAtThreshold(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	tracker := New(Config{
+		BadPiecePenalty:         10,
+		UnavailablePiecePenalty: 5,
+		BanThreshold:            20,
+		BanDuration:             time.Minute,
+	}, clk, tally.NoopScope)
+
+	peerID := core.PeerIDFixture()
+	h := core.InfoHashFixture()
+
+	require.False(tracker.Penalize(peerID, h, BadPiece))
+	require.False(tracker.Penalize(peerID, h, UnavailablePiece))
+	require.Equal(15, tracker.Score(peerID))
+	require.False(tracker.Banned(peerID))
+
+	require.True(tracker.Penalize(peerID, h, UnavailablePiece))
+	require.True(tracker.Banned(peerID))
+
+	banned := tracker.BannedSnapshot()
+	require.Len(banned, 1)
+	require.Equal(peerID, banned[0].PeerID)
+	require.Equal(h, banned[0].InfoHash)
+	require.Equal(time.Minute, banned[0].Remaining)
+
+	// Offenses while banned are ignored.
+	require.False(tracker.Penalize(peerID, h, BadPiece))
+
+	clk.Add(time.Minute + 1)
+	require.False(tracker.Banned(peerID))
+	require.Equal(0, tracker.Score(peerID))
+	require.Empty(tracker.BannedSnapshot())
+}
+
+func TestTrackerResetsScoreAfterPenaltyTTL(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	tracker := New(Config{
+		BadPiecePenalty: 10,
+		BanThreshold:    20,
+		PenaltyTTL:      time.Minute,
+	}, clk, tally.NoopScope)
+
+	peerID := core.PeerIDFixture()
+	h := core.InfoHashFixture()
+
+	require.False(tracker.Penalize(peerID, h, BadPiece))
+
+	clk.Add(time.Minute + 1)
+	require.Equal(0, tracker.Score(peerID))
+
+	require.False(tracker.Penalize(peerID, h, BadPiece))
+	require.False(tracker.Banned(peerID))
+}
+
+func TestTrackerDisableBansNeverBans(t *testing.T) {
+	require := require.New(t)
+
+	tracker := New(Config{DisableBans: true, BanThreshold: 1}, clock.NewMock(), tally.NoopScope)
+
+	peerID := core.PeerIDFixture()
+	h := core.InfoHashFixture()
+
+	for i := 0; i < 10; i++ {
+		require.False(tracker.Penalize(peerID, h, BadPiece))
+	}
+	require.False(tracker.Banned(peerID))
+}
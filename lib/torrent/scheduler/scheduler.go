@@ -17,19 +17,25 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/andres-erbsen/clock"
 	"github.com/uber-go/tally"
+	"github.com/willf/bitset"
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
 
 	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/lib/torrent/networkevent"
 	"github.com/uber/kraken/lib/torrent/scheduler/announcequeue"
 	"github.com/uber/kraken/lib/torrent/scheduler/announcer"
 	"github.com/uber/kraken/lib/torrent/scheduler/conn"
 	"github.com/uber/kraken/lib/torrent/scheduler/connstate"
+	"github.com/uber/kraken/lib/torrent/scheduler/peerselector"
+	"github.com/uber/kraken/lib/torrent/scheduler/reputation"
 	"github.com/uber/kraken/lib/torrent/scheduler/torrentlog"
 	"github.com/uber/kraken/lib/torrent/storage"
 	"github.com/uber/kraken/tracker/announceclient"
@@ -43,15 +49,30 @@ var (
 	ErrTorrentTimeout    = errors.New("torrent timed out")
 	ErrTorrentRemoved    = errors.New("torrent manually removed")
 	ErrSendEventTimedOut = errors.New("event loop send timed out")
+	ErrMaintenance       = errors.New("scheduler is in maintenance mode")
 )
 
+var errPeerBanned = errors.New("peer is banned")
+
 // Scheduler defines operations for scheduler.
 type Scheduler interface {
 	Stop()
 	Download(namespace string, d core.Digest) error
+	Seed(d core.Digest) error
 	BlacklistSnapshot() ([]connstate.BlacklistedConn, error)
+	BannedPeers() ([]reputation.BannedPeer, error)
 	RemoveTorrent(d core.Digest) error
 	Probe() error
+	CheckReadiness() error
+	NumActiveTorrents() (int, error)
+	TorrentStats() ([]TorrentStats, error)
+	TorrentStatsByInfoHash(h core.InfoHash) (TorrentStats, error)
+	BandwidthLimits() (egressBitsPerSec, ingressBitsPerSec uint64)
+	SetBandwidthLimits(egressBitsPerSec, ingressBitsPerSec uint64) error
+	SetAnnounceInterval(interval time.Duration)
+	SetMaintenance(enabled bool)
+	InMaintenance() bool
+	SetPieceRequestPolicy(d core.Digest, policy string) error
 }
 
 // scheduler manages global state for the peer. This includes:
@@ -82,12 +103,18 @@ type scheduler struct {
 
 	announcer *announcer.Announcer
 
+	peerSelector peerselector.Selector
+
 	netevents networkevent.Producer
 
 	torrentlog *torrentlog.Logger
 
 	logger *zap.SugaredLogger
 
+	// maintenance is set while the agent is being drained: no new torrents
+	// are added and completed torrents are not announced.
+	maintenance *atomic.Bool
+
 	// The following fields orchestrate the stopping of the scheduler.
 	stopOnce sync.Once      // Ensures the stop sequence is executed only once.
 	done     chan struct{}  // Signals all goroutines to exit.
@@ -161,6 +188,15 @@ func newScheduler(
 		return nil, fmt.Errorf("torrentlog: %s", err)
 	}
 
+	peerSelector, err := peerselector.New(config.PeerSelector)
+	if err != nil {
+		return nil, fmt.Errorf("peer selector: %s", err)
+	}
+	peerSelector, err = peerselector.WithNeighbors(peerSelector, config.Neighbors)
+	if err != nil {
+		return nil, fmt.Errorf("peer selector: %s", err)
+	}
+
 	s := &scheduler{
 		pctx:           pctx,
 		config:         config,
@@ -172,10 +208,12 @@ func newScheduler(
 		preemptionTick: preemptionTick,
 		emitStatsTick:  overrides.clock.Tick(config.EmitStatsInterval),
 		announceClient: announceClient,
-		announcer:      announcer.Default(announceClient, eventLoop, overrides.clock, slogger),
+		announcer:      announcer.New(config.Announcer, announceClient, eventLoop, overrides.clock, slogger),
+		peerSelector:   peerSelector,
 		netevents:      netevents,
 		torrentlog:     tlog,
 		logger:         slogger,
+		maintenance:    atomic.NewBool(false),
 		done:           done,
 	}
 
@@ -198,7 +236,7 @@ func (s *scheduler) start(aq announcequeue.Queue) error {
 		"Scheduler starting as peer %s on addr %s:%d",
 		s.pctx.PeerID, s.pctx.IP, s.pctx.Port)
 
-	l, err := net.Listen("tcp", fmt.Sprintf(":%d", s.pctx.Port))
+	l, err := net.Listen("tcp", net.JoinHostPort(s.pctx.BindIP, strconv.Itoa(s.pctx.Port)))
 	if err != nil {
 		return err
 	}
@@ -232,11 +270,21 @@ func (s *scheduler) Stop() {
 }
 
 func (s *scheduler) doDownload(namespace string, d core.Digest) (size int64, err error) {
+	if s.InMaintenance() {
+		// Avoids creating torrents which will never be downloaded. Torrents
+		// already in progress are joined by newTorrentEvent instead.
+		if _, err := s.torrentArchive.Stat(namespace, d); err != nil {
+			return 0, ErrMaintenance
+		}
+	}
 	t, err := s.torrentArchive.CreateTorrent(namespace, d)
 	if err != nil {
 		if err == storage.ErrNotFound {
 			return 0, ErrTorrentNotFound
 		}
+		if store.IsInsufficientStorage(err) {
+			return 0, err
+		}
 		return 0, fmt.Errorf("create torrent: %s", err)
 	}
 
@@ -264,8 +312,13 @@ func (s *scheduler) Download(namespace string, d core.Digest) error {
 			errTag = "scheduler_stopped"
 		case ErrTorrentRemoved:
 			errTag = "removed"
+		case ErrMaintenance:
+			errTag = "maintenance"
 		default:
 			errTag = "unknown"
+			if store.IsInsufficientStorage(err) {
+				errTag = "insufficient_storage"
+			}
 		}
 		s.stats.Tagged(map[string]string{
 			"error": errTag,
@@ -279,6 +332,25 @@ func (s *scheduler) Download(namespace string, d core.Digest) error {
 	return err
 }
 
+// Seed starts seeding blob d, which must already be fully downloaded. The
+// torrent is announced with the next announce tick, and is removed once idle
+// like any other seeded torrent.
+func (s *scheduler) Seed(d core.Digest) error {
+	t, err := s.torrentArchive.GetTorrent("", d)
+	if err != nil {
+		return fmt.Errorf("get torrent: %s", err)
+	}
+	if !t.Complete() {
+		return errors.New("torrent is not complete")
+	}
+	// Buffer size of 1 so sends do not block.
+	errc := make(chan error, 1)
+	if !s.eventLoop.send(newTorrentEvent{"", t, errc}) {
+		return ErrSchedulerStopped
+	}
+	return <-errc
+}
+
 // BlacklistSnapshot returns a snapshot of the current connection blacklist.
 func (s *scheduler) BlacklistSnapshot() ([]connstate.BlacklistedConn, error) {
 	result := make(chan []connstate.BlacklistedConn)
@@ -288,6 +360,98 @@ func (s *scheduler) BlacklistSnapshot() ([]connstate.BlacklistedConn, error) {
 	return <-result, nil
 }
 
+// BannedPeers returns a snapshot of all peers currently banned for
+// misbehavior.
+func (s *scheduler) BannedPeers() ([]reputation.BannedPeer, error) {
+	result := make(chan []reputation.BannedPeer)
+	if !s.eventLoop.send(bannedPeersSnapshotEvent{result}) {
+		return nil, ErrSchedulerStopped
+	}
+	return <-result, nil
+}
+
+// NumActiveTorrents returns the number of torrents currently being leeched or
+// seeded.
+func (s *scheduler) NumActiveTorrents() (int, error) {
+	result := make(chan int)
+	if !s.eventLoop.send(numActiveTorrentsEvent{result}) {
+		return 0, ErrSchedulerStopped
+	}
+	return <-result, nil
+}
+
+// TorrentStats returns the progress of all torrents currently being leeched or
+// seeded.
+func (s *scheduler) TorrentStats() ([]TorrentStats, error) {
+	result := make(chan []TorrentStats)
+	if !s.eventLoop.send(torrentStatsEvent{nil, result}) {
+		return nil, ErrSchedulerStopped
+	}
+	return <-result, nil
+}
+
+// TorrentStatsByInfoHash returns the progress of the torrent for h. Returns
+// ErrTorrentNotFound if h is not being leeched or seeded.
+func (s *scheduler) TorrentStatsByInfoHash(h core.InfoHash) (TorrentStats, error) {
+	result := make(chan []TorrentStats)
+	if !s.eventLoop.send(torrentStatsEvent{&h, result}) {
+		return TorrentStats{}, ErrSchedulerStopped
+	}
+	stats := <-result
+	if len(stats) == 0 {
+		return TorrentStats{}, ErrTorrentNotFound
+	}
+	return stats[0], nil
+}
+
+// BandwidthLimits returns the egress and ingress bandwidth currently enforced
+// across all peer connections.
+func (s *scheduler) BandwidthLimits() (egressBitsPerSec, ingressBitsPerSec uint64) {
+	return s.handshaker.Bandwidth().Limits()
+}
+
+// SetBandwidthLimits changes the egress and ingress bandwidth enforced across
+// all peer connections without restarting the scheduler. Limits set at runtime
+// are reset to the configured limits on Reload.
+func (s *scheduler) SetBandwidthLimits(egressBitsPerSec, ingressBitsPerSec uint64) error {
+	return s.handshaker.Bandwidth().SetLimits(egressBitsPerSec, ingressBitsPerSec)
+}
+
+// SetAnnounceInterval changes the default announce interval without
+// restarting the scheduler. Intervals returned by the tracker still take
+// precedence. Reset to the configured interval on Reload.
+func (s *scheduler) SetAnnounceInterval(interval time.Duration) {
+	s.announcer.SetDefaultInterval(interval)
+}
+
+// SetMaintenance enables or disables maintenance mode. While in maintenance,
+// downloads of torrents which are not already in progress fail with
+// ErrMaintenance, and completed torrents are no longer announced, such that
+// peers stop discovering the agent as a seeder. In-progress downloads and
+// existing connections are unaffected.
+func (s *scheduler) SetMaintenance(enabled bool) {
+	if s.maintenance.Swap(enabled) != enabled {
+		s.log().Infof("Maintenance mode set to %t", enabled)
+	}
+}
+
+// InMaintenance returns whether the scheduler is in maintenance mode.
+func (s *scheduler) InMaintenance() bool {
+	return s.maintenance.Load()
+}
+
+// SetPieceRequestPolicy changes the piece request policy of the torrent for d,
+// e.g. to sequential while the blob is being streamed. Returns
+// ErrTorrentNotFound if d is not being leeched or seeded.
+func (s *scheduler) SetPieceRequestPolicy(d core.Digest, policy string) error {
+	// Buffer size of 1 so sends do not block.
+	errc := make(chan error, 1)
+	if !s.eventLoop.send(setPieceRequestPolicyEvent{d, policy, errc}) {
+		return ErrSchedulerStopped
+	}
+	return <-errc
+}
+
 // RemoveTorrent forcibly stops leeching / seeding torrent for d and removes
 // the torrent from disk.
 func (s *scheduler) RemoveTorrent(d core.Digest) error {
@@ -304,6 +468,12 @@ func (s *scheduler) Probe() error {
 	return s.eventLoop.sendTimeout(probeEvent{}, s.config.ProbeTimeout)
 }
 
+// CheckReadiness verifies that the scheduler is able to reach at least one
+// healthy tracker.
+func (s *scheduler) CheckReadiness() error {
+	return s.announceClient.CheckReadiness()
+}
+
 func (s *scheduler) runEventLoop(aq announcequeue.Queue) {
 	defer s.wg.Done()
 
@@ -384,7 +554,7 @@ func (s *scheduler) establishIncomingHandshake(pc *conn.PendingConn, rb conn.Rem
 		s.failIncomingHandshake(pc, fmt.Errorf("torrent stat: %s", err))
 		return
 	}
-	c, err := s.handshaker.Establish(pc, info, rb)
+	c, err := s.handshaker.Establish(pc, s.handshakeInfo(info), rb)
 	if err != nil {
 		s.failIncomingHandshake(pc, fmt.Errorf("establish handshake: %s", err))
 		return
@@ -398,8 +568,8 @@ func (s *scheduler) establishIncomingHandshake(pc *conn.PendingConn, rb conn.Rem
 func (s *scheduler) initializeOutgoingHandshake(
 	p *core.PeerInfo, info *storage.TorrentInfo, rb conn.RemoteBitfields, namespace string) {
 
-	addr := fmt.Sprintf("%s:%d", p.IP, p.Port)
-	result, err := s.handshaker.Initialize(p.PeerID, addr, info, rb, namespace)
+	addr := net.JoinHostPort(p.IP, strconv.Itoa(p.Port))
+	result, err := s.handshaker.Initialize(p.PeerID, addr, s.handshakeInfo(info), rb, namespace)
 	if err != nil {
 		s.log(
 			"peer", p.PeerID,
@@ -413,6 +583,16 @@ func (s *scheduler) initializeOutgoingHandshake(
 	s.eventLoop.send(outgoingConnEvent{result.Conn, result.Bitfield, info})
 }
 
+// handshakeInfo returns the torrent info to advertise to remote peers during
+// handshakes. Complete torrents hide their pieces when super-seeding, which
+// are instead announced individually after the conn is established.
+func (s *scheduler) handshakeInfo(info *storage.TorrentInfo) *storage.TorrentInfo {
+	if s.config.Dispatch.SuperSeeding && info.Bitfield().All() {
+		return info.WithBitfield(bitset.New(info.Bitfield().Len()))
+	}
+	return info
+}
+
 func (s *scheduler) log(args ...interface{}) *zap.SugaredLogger {
 	return s.logger.With(args...)
 }
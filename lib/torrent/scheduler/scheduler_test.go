@@ -14,6 +14,7 @@
 package scheduler
 
 import (
+	"net"
 	"os"
 	"sync"
 	"testing"
@@ -24,9 +25,12 @@ import (
 	"github.com/uber/kraken/lib/hostlist"
 	"github.com/uber/kraken/lib/torrent/networkevent"
 	"github.com/uber/kraken/lib/torrent/scheduler/announcequeue"
+	"github.com/uber/kraken/lib/torrent/scheduler/dispatch/piecerequest"
+	"github.com/uber/kraken/lib/torrent/scheduler/peerselector"
 	"github.com/uber/kraken/lib/torrent/storage/piecereader"
 	"github.com/uber/kraken/tracker/announceclient"
 	"github.com/uber/kraken/utils/bitsetutil"
+	"github.com/uber/kraken/utils/testutil"
 
 	"github.com/andres-erbsen/clock"
 	"github.com/stretchr/testify/require"
@@ -56,6 +60,65 @@ func TestDownloadTorrentWithSeederAndLeecher(t *testing.T) {
 	leecher.checkTorrent(t, namespace, blob)
 }
 
+func TestDownloadTorrentWithRandomPeerSelector(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	config := configFixture()
+	config.PeerSelector = peerselector.RandomStrategy
+
+	seeder := mocks.newPeer(config)
+	leecher := mocks.newPeer(config)
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil).Times(2)
+
+	seeder.writeTorrent(namespace, blob)
+	require.NoError(seeder.scheduler.Download(namespace, blob.Digest))
+
+	require.NoError(leecher.scheduler.Download(namespace, blob.Digest))
+	leecher.checkTorrent(t, namespace, blob)
+}
+
+func TestDownloadTorrentWithIPv6Peers(t *testing.T) {
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %s", err)
+	}
+	l.Close()
+
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	config := configFixture()
+
+	// Mixed IPv4 / IPv6 peers must interoperate.
+	seeder := mocks.newPeerWithIP(config, "::1")
+	leecher6 := mocks.newPeerWithIP(config, "::1")
+	leecher4 := mocks.newPeerWithIP(config, "127.0.0.1")
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil).Times(3)
+
+	seeder.writeTorrent(namespace, blob)
+	require.NoError(seeder.scheduler.Download(namespace, blob.Digest))
+
+	for _, leecher := range []*testPeer{leecher6, leecher4} {
+		require.NoError(leecher.scheduler.Download(namespace, blob.Digest))
+		leecher.checkTorrent(t, namespace, blob)
+	}
+}
+
 func TestDownloadManyTorrentsWithSeederAndLeecher(t *testing.T) {
 	require := require.New(t)
 
@@ -213,11 +276,128 @@ func TestSeederTTI(t *testing.T) {
 	require.False(hasConn(seeder.scheduler, leecher.pctx.PeerID, blob.MetaInfo.InfoHash()))
 	require.False(hasConn(leecher.scheduler, seeder.pctx.PeerID, blob.MetaInfo.InfoHash()))
 
+	// Either side may close the idle conn first.
+	require.True(seeder.counter("idle_conns_closed")+leecher.counter("idle_conns_closed") > 0)
+	require.Equal(int64(1), seeder.counter("idle_torrents_removed"))
+	require.Equal(int64(1), leecher.counter("idle_torrents_removed"))
+
 	// Idle seeder should keep around the torrent file so it can still serve content.
 	_, err := seeder.torrentArchive.Stat(namespace, blob.Digest)
 	require.NoError(err)
 }
 
+func TestSeederTTL(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	config := configFixture()
+	config.SeederTTI = time.Hour
+	config.SeederTTL = time.Minute
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil).Times(2)
+
+	clk := clock.NewMock()
+
+	seeder := mocks.newPeer(config, withClock(clk))
+	seeder.writeTorrent(namespace, blob)
+	require.NoError(seeder.scheduler.Download(namespace, blob.Digest))
+
+	leecher := mocks.newPeer(config, withClock(clk))
+
+	require.NoError(leecher.scheduler.Download(namespace, blob.Digest))
+	leecher.checkTorrent(t, namespace, blob)
+
+	// Seeding torrents are removed once they reach the TTL, despite SeederTTI
+	// not having elapsed.
+	clk.Add(config.SeederTTL)
+
+	waitForTorrentRemoved(t, seeder.scheduler, blob.MetaInfo.InfoHash())
+	waitForTorrentRemoved(t, leecher.scheduler, blob.MetaInfo.InfoHash())
+
+	require.Equal(int64(1), seeder.counter("seed_limit_torrents_removed"))
+	require.Equal(int64(0), seeder.counter("idle_torrents_removed"))
+
+	// Blob remains on disk.
+	_, err := leecher.torrentArchive.Stat(namespace, blob.Digest)
+	require.NoError(err)
+}
+
+func TestUploadMetrics(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	config := configFixture()
+	config.UploadMetrics = true
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil).Times(2)
+
+	seeder := mocks.newPeer(config)
+	seeder.writeTorrent(namespace, blob)
+	require.NoError(seeder.scheduler.Download(namespace, blob.Digest))
+
+	leecher := mocks.newPeer(config)
+	require.NoError(leecher.scheduler.Download(namespace, blob.Digest))
+
+	require.NoError(testutil.PollUntilTrue(5*time.Second, func() bool {
+		seeder.scheduler.eventLoop.send(emitStatsEvent{})
+		return seeder.counter("torrent_bytes_uploaded") == blob.MetaInfo.Length()
+	}))
+	require.Equal(blob.MetaInfo.Length(), seeder.counter("bytes_uploaded"))
+	require.Equal(int64(0), leecher.counter("bytes_uploaded"))
+}
+
+func TestSeederMaxUploadRatio(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	config := configFixture()
+	config.SeederTTI = time.Hour
+	config.SeederMaxUploadRatio = 1
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil).Times(2)
+
+	clk := clock.NewMock()
+
+	seeder := mocks.newPeer(config, withClock(clk))
+	seeder.writeTorrent(namespace, blob)
+	require.NoError(seeder.scheduler.Download(namespace, blob.Digest))
+
+	leecher := mocks.newPeer(config, withClock(clk))
+
+	require.NoError(leecher.scheduler.Download(namespace, blob.Digest))
+	leecher.checkTorrent(t, namespace, blob)
+
+	// Seeder uploaded the entire blob, while the leecher uploaded nothing.
+	require.NoError(testutil.PollUntilTrue(5*time.Second, func() bool {
+		clk.Add(config.PreemptionInterval)
+		result := make(chan bool)
+		seeder.scheduler.eventLoop.send(hasTorrentEvent{blob.MetaInfo.InfoHash(), result})
+		return !<-result
+	}))
+
+	stats, err := leecher.scheduler.TorrentStatsByInfoHash(blob.MetaInfo.InfoHash())
+	require.NoError(err)
+	require.True(stats.Complete)
+}
+
 func TestLeecherTTI(t *testing.T) {
 	t.Skip()
 
@@ -355,13 +535,27 @@ func TestNetworkEvents(t *testing.T) {
 		networkevent.BlacklistConnEvent(h, lid, sid, config.ConnState.BlacklistDuration),
 	}
 
+	// The number of announces depends on timing, so they are checked separately.
+	names := []networkevent.Name{
+		networkevent.AddTorrent,
+		networkevent.AddActiveConn,
+		networkevent.DropActiveConn,
+		networkevent.BlacklistConn,
+		networkevent.RequestPiece,
+		networkevent.ReceivePiece,
+		networkevent.TorrentComplete,
+		networkevent.TorrentCancelled,
+	}
+
 	require.Equal(
 		networkevent.StripTimestamps(seederExpected),
-		networkevent.StripTimestamps(seeder.testProducer.Events()))
+		networkevent.StripTimestamps(networkevent.Filter(seeder.testProducer.Events(), names...)))
 
 	require.Equal(
 		networkevent.StripTimestamps(leecherExpected),
-		networkevent.StripTimestamps(leecher.testProducer.Events()))
+		networkevent.StripTimestamps(networkevent.Filter(leecher.testProducer.Events(), names...)))
+
+	require.NotEmpty(networkevent.Filter(leecher.testProducer.Events(), networkevent.Announce))
 }
 
 func TestPullInactiveTorrent(t *testing.T) {
@@ -385,7 +579,7 @@ func TestPullInactiveTorrent(t *testing.T) {
 
 	// Force announce the scheduler for this torrent to simulate a peer which
 	// is registered in tracker but does not have the torrent in memory.
-	ac := announceclient.New(seeder.pctx, hashring.NoopPassiveRing(hostlist.Fixture(mocks.trackerAddr)), nil)
+	ac := announceclient.New(announceclient.Config{}, seeder.pctx, hashring.NoopPassiveRing(hostlist.Fixture(mocks.trackerAddr)), nil)
 	ac.Announce(blob.Digest, blob.MetaInfo.InfoHash(), false, announceclient.V1)
 
 	leecher := mocks.newPeer(config)
@@ -458,6 +652,191 @@ func TestSchedulerRemoveTorrent(t *testing.T) {
 	require.True(os.IsNotExist(err))
 }
 
+func TestSchedulerSetPieceRequestPolicy(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	w := newEventWatcher()
+
+	p := mocks.newPeer(configFixture(), withEventLoop(w))
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	require.Equal(
+		ErrTorrentNotFound,
+		p.scheduler.SetPieceRequestPolicy(blob.Digest, piecerequest.SequentialPolicy))
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil)
+
+	errc := make(chan error)
+	go func() { errc <- p.scheduler.Download(namespace, blob.Digest) }()
+
+	w.waitFor(t, newTorrentEvent{})
+
+	require.NoError(p.scheduler.SetPieceRequestPolicy(blob.Digest, piecerequest.SequentialPolicy))
+	require.Error(p.scheduler.SetPieceRequestPolicy(blob.Digest, "invalid"))
+
+	require.NoError(p.scheduler.RemoveTorrent(blob.Digest))
+	require.Equal(ErrTorrentRemoved, <-errc)
+}
+
+func TestSchedulerMaintenance(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	config := configFixture()
+	namespace := core.TagFixture()
+
+	w := newEventWatcher()
+
+	seeder := mocks.newPeer(config)
+	leecher := mocks.newPeer(config, withEventLoop(w))
+
+	blob := core.NewBlobFixture()
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil).Times(2)
+
+	errc := make(chan error)
+	go func() { errc <- leecher.scheduler.Download(namespace, blob.Digest) }()
+
+	w.waitFor(t, newTorrentEvent{})
+
+	leecher.scheduler.SetMaintenance(true)
+	require.True(leecher.scheduler.InMaintenance())
+
+	// In-progress downloads are completed.
+	seeder.writeTorrent(namespace, blob)
+	require.NoError(seeder.scheduler.Download(namespace, blob.Digest))
+
+	require.NoError(<-errc)
+	leecher.checkTorrent(t, namespace, blob)
+
+	// New downloads are rejected.
+	require.Equal(ErrMaintenance, leecher.scheduler.Download(namespace, core.DigestFixture()))
+
+	leecher.scheduler.SetMaintenance(false)
+	require.False(leecher.scheduler.InMaintenance())
+}
+
+func TestSchedulerSeedCachedBlob(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	config := configFixture()
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil).Times(2)
+
+	seeder := mocks.newPeer(config)
+	seeder.writeTorrent(namespace, blob)
+	require.NoError(seeder.scheduler.Seed(blob.Digest))
+
+	leecher := mocks.newPeer(config)
+	require.NoError(leecher.scheduler.Download(namespace, blob.Digest))
+	leecher.checkTorrent(t, namespace, blob)
+}
+
+func TestSchedulerSeedMissingBlobErrors(t *testing.T) {
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	p := mocks.newPeer(configFixture())
+
+	require.Error(t, p.scheduler.Seed(core.DigestFixture()))
+}
+
+func TestSchedulerNumActiveTorrents(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	w := newEventWatcher()
+
+	p := mocks.newPeer(configFixture(), withEventLoop(w))
+
+	n, err := p.scheduler.NumActiveTorrents()
+	require.NoError(err)
+	require.Equal(0, n)
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil)
+
+	errc := make(chan error)
+	go func() { errc <- p.scheduler.Download(namespace, blob.Digest) }()
+
+	w.waitFor(t, newTorrentEvent{})
+
+	n, err = p.scheduler.NumActiveTorrents()
+	require.NoError(err)
+	require.Equal(1, n)
+
+	require.NoError(p.scheduler.RemoveTorrent(blob.Digest))
+	require.Equal(ErrTorrentRemoved, <-errc)
+
+	p.scheduler.Stop()
+
+	_, err = p.scheduler.NumActiveTorrents()
+	require.Equal(ErrSchedulerStopped, err)
+}
+
+func TestSchedulerTorrentStats(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	config := configFixture()
+
+	seeder := mocks.newPeer(config)
+	leecher := mocks.newPeer(config)
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil).Times(2)
+
+	seeder.writeTorrent(namespace, blob)
+	require.NoError(seeder.scheduler.Download(namespace, blob.Digest))
+
+	require.NoError(leecher.scheduler.Download(namespace, blob.Digest))
+
+	h := blob.MetaInfo.InfoHash()
+
+	stats, err := leecher.scheduler.TorrentStatsByInfoHash(h)
+	require.NoError(err)
+	require.Equal(h, stats.InfoHash)
+	require.Equal(blob.Digest, stats.Digest)
+	require.Equal(namespace, stats.Namespace)
+	require.True(stats.Complete)
+	require.Equal(blob.MetaInfo.NumPieces(), stats.PiecesComplete)
+	require.Equal(blob.MetaInfo.NumPieces(), stats.PiecesTotal)
+
+	all, err := leecher.scheduler.TorrentStats()
+	require.NoError(err)
+	require.Len(all, 1)
+	require.Equal(h, all[0].InfoHash)
+
+	_, err = leecher.scheduler.TorrentStatsByInfoHash(core.InfoHashFixture())
+	require.Equal(ErrTorrentNotFound, err)
+}
+
 func TestSchedulerProbe(t *testing.T) {
 	require := require.New(t)
 
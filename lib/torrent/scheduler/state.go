@@ -23,6 +23,7 @@ import (
 	"github.com/uber/kraken/lib/torrent/scheduler/conn"
 	"github.com/uber/kraken/lib/torrent/scheduler/connstate"
 	"github.com/uber/kraken/lib/torrent/scheduler/dispatch"
+	"github.com/uber/kraken/lib/torrent/scheduler/reputation"
 	"github.com/uber/kraken/lib/torrent/storage"
 	"go.uber.org/zap"
 
@@ -35,6 +36,10 @@ type torrentControl struct {
 	dispatcher   *dispatch.Dispatcher
 	errors       []chan error
 	localRequest bool
+
+	// bytesSentReported is the number of bytes sent by dispatcher which have
+	// been emitted as metrics.
+	bytesSentReported int64
 }
 
 // state is a superset of scheduler, which includes protected state which can
@@ -51,6 +56,7 @@ type state struct {
 	// Protected state.
 	torrentControls map[core.InfoHash]*torrentControl
 	conns           *connstate.State
+	reputation      *reputation.Tracker
 	announceQueue   announcequeue.Queue
 }
 
@@ -60,6 +66,7 @@ func newState(s *scheduler, aq announcequeue.Queue) *state {
 		torrentControls: make(map[core.InfoHash]*torrentControl),
 		conns: connstate.New(
 			s.config.ConnState, s.clock, s.pctx.PeerID, s.netevents, s.logger),
+		reputation:    reputation.New(s.config.Reputation, s.clock, s.stats),
 		announceQueue: aq,
 	}
 }
@@ -94,6 +101,10 @@ func (s *state) addTorrent(
 		t.Bitfield(),
 		s.sched.config.ConnState.MaxOpenConnectionsPerTorrent))
 	s.torrentControls[t.InfoHash()] = ctrl
+	if p, ok := s.sched.torrentArchive.(storage.Pinner); ok {
+		// Torrents must not be evicted while they are being seeded.
+		p.Pin(t.Digest())
+	}
 	return ctrl, nil
 }
 
@@ -113,9 +124,31 @@ func (s *state) removeTorrent(h core.InfoHash, err error) {
 		s.sched.netevents.Produce(networkevent.TorrentCancelledEvent(h, s.sched.pctx.PeerID))
 		s.sched.torrentArchive.DeleteTorrent(ctrl.dispatcher.Digest())
 	}
+	if p, ok := s.sched.torrentArchive.(storage.Pinner); ok {
+		p.Unpin(ctrl.dispatcher.Digest())
+	}
+	s.reportUploads(h, ctrl)
 	delete(s.torrentControls, h)
 }
 
+// reportUploads emits the bytes uploaded to peers for torrent h since they
+// were last reported.
+func (s *state) reportUploads(h core.InfoHash, ctrl *torrentControl) {
+	sent := ctrl.dispatcher.BytesSent()
+	n := sent - ctrl.bytesSentReported
+	if n <= 0 {
+		return
+	}
+	ctrl.bytesSentReported = sent
+	s.sched.stats.Counter("bytes_uploaded").Inc(n)
+	if s.sched.config.UploadMetrics {
+		s.sched.stats.Tagged(map[string]string{
+			"namespace": ctrl.namespace,
+			"infohash":  h.Hex(),
+		}).Counter("torrent_bytes_uploaded").Inc(n)
+	}
+}
+
 // addOutgoingConn adds a conn, initialized by us, to state. The conn must already
 // be in a pending state, and the torrent control must already be initialized.
 func (s *state) addOutgoingConn(c *conn.Conn, b *bitset.BitSet, info *storage.TorrentInfo) error {
@@ -160,6 +193,37 @@ func (s *state) addIncomingConn(
 	return nil
 }
 
+// seedLimitReached returns true if the completed torrent of d has been seeded
+// for longer than the configured seeder TTL, or has uploaded more than the
+// configured max upload ratio.
+func (s *state) seedLimitReached(d *dispatch.Dispatcher) bool {
+	if ttl := s.sched.config.SeederTTL; ttl > 0 {
+		if s.sched.clock.Now().Sub(d.CompletedAt()) >= ttl {
+			return true
+		}
+	}
+	if ratio := s.sched.config.SeederMaxUploadRatio; ratio > 0 && d.Length() > 0 {
+		if float64(d.BytesSent())/float64(d.Length()) >= ratio {
+			return true
+		}
+	}
+	return false
+}
+
+// penalize records offense o by peerID on torrent h. If peerID is banned as a
+// result, all connections to peerID are closed.
+func (s *state) penalize(peerID core.PeerID, h core.InfoHash, o reputation.Offense) {
+	if !s.reputation.Penalize(peerID, h, o) {
+		return
+	}
+	s.log("peer", peerID, "hash", h, "offense", o).Warn("Banning peer")
+	for _, c := range s.conns.ActiveConns() {
+		if c.PeerID() == peerID {
+			c.Close()
+		}
+	}
+}
+
 func (s *state) log(args ...interface{}) *zap.SugaredLogger {
 	return s.sched.log(args...)
 }
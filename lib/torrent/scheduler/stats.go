@@ -0,0 +1,108 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package scheduler
+
+import (
+	"net"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/torrent/scheduler/dispatch"
+)
+
+// PeerStats summarizes a connected peer's contribution to a torrent.
+type PeerStats struct {
+	PeerID         core.PeerID `json:"peer_id"`
+	IP             string      `json:"ip"`
+	PiecesReceived int         `json:"pieces_received"`
+	PiecesSent     int         `json:"pieces_sent"`
+	BytesReceived  int64       `json:"bytes_received"`
+	BytesSent      int64       `json:"bytes_sent"`
+}
+
+// TorrentStats summarizes the progress of a torrent the scheduler is leeching
+// or seeding. Rates are averaged over the lifetime of the torrent.
+type TorrentStats struct {
+	InfoHash            core.InfoHash `json:"info_hash"`
+	Digest              core.Digest   `json:"digest"`
+	Namespace           string        `json:"namespace"`
+	Complete            bool          `json:"complete"`
+	PiecesComplete      int           `json:"pieces_complete"`
+	PiecesTotal         int           `json:"pieces_total"`
+	DownloadBytesPerSec float64       `json:"download_bytes_per_sec"`
+	UploadBytesPerSec   float64       `json:"upload_bytes_per_sec"`
+	Peers               []PeerStats   `json:"peers"`
+
+	// BannedPeers is the number of peers banned for an offense on the torrent.
+	BannedPeers int `json:"banned_peers"`
+}
+
+// torrentStats builds TorrentStats for ctrl. Must be called from the event loop.
+func (s *state) torrentStats(h core.InfoHash, ctrl *torrentControl) TorrentStats {
+	ips := make(map[core.PeerID]string)
+	for _, c := range s.conns.ActiveConns() {
+		if c.InfoHash() != h {
+			continue
+		}
+		ip, _, err := net.SplitHostPort(c.RemoteAddr().String())
+		if err != nil {
+			ip = c.RemoteAddr().String()
+		}
+		ips[c.PeerID()] = ip
+	}
+
+	d := ctrl.dispatcher
+	ds := d.Stats()
+
+	var downloadRate, uploadRate float64
+	if elapsed := s.sched.clock.Now().Sub(d.CreatedAt()).Seconds(); elapsed > 0 {
+		downloadRate = float64(ds.BytesReceived) / elapsed
+		uploadRate = float64(ds.BytesSent) / elapsed
+	}
+
+	peers := make([]PeerStats, 0, len(ds.Peers))
+	for _, p := range ds.Peers {
+		peers = append(peers, newPeerStats(p, ips[p.PeerID]))
+	}
+
+	var banned int
+	for _, b := range s.reputation.BannedSnapshot() {
+		if b.InfoHash == h {
+			banned++
+		}
+	}
+
+	return TorrentStats{
+		InfoHash:            h,
+		Digest:              d.Digest(),
+		Namespace:           ctrl.namespace,
+		Complete:            d.Complete(),
+		PiecesComplete:      ds.PiecesComplete,
+		PiecesTotal:         ds.NumPieces,
+		DownloadBytesPerSec: downloadRate,
+		UploadBytesPerSec:   uploadRate,
+		Peers:               peers,
+		BannedPeers:         banned,
+	}
+}
+
+func newPeerStats(p dispatch.PeerStats, ip string) PeerStats {
+	return PeerStats{
+		PeerID:         p.PeerID,
+		IP:             ip,
+		PiecesReceived: p.PiecesReceived,
+		PiecesSent:     p.PiecesSent,
+		BytesReceived:  p.BytesReceived,
+		BytesSent:      p.BytesSent,
+	}
+}
@@ -116,6 +116,11 @@ type testPeer struct {
 }
 
 func (m *testMocks) newPeer(config Config, options ...option) *testPeer {
+	return m.newPeerWithIP(config, "localhost", options...)
+}
+
+// newPeerWithIP creates a peer which announces itself as ip.
+func (m *testMocks) newPeerWithIP(config Config, ip string, options ...option) *testPeer {
 	var cleanup testutil.Cleanup
 	m.cleanup.Add(cleanup.Run)
 
@@ -129,10 +134,10 @@ func (m *testMocks) newPeer(config Config, options ...option) *testPeer {
 	pctx := core.PeerContext{
 		PeerID: core.PeerIDFixture(),
 		Zone:   "zone1",
-		IP:     "localhost",
+		IP:     ip,
 		Port:   findFreePort(),
 	}
-	ac := announceclient.New(pctx, hashring.NoopPassiveRing(hostlist.Fixture(m.trackerAddr)), nil)
+	ac := announceclient.New(announceclient.Config{}, pctx, hashring.NoopPassiveRing(hostlist.Fixture(m.trackerAddr)), nil)
 	tp := networkevent.NewTestProducer()
 
 	s, err := newScheduler(config, ta, stats, pctx, ac, tp, options...)
@@ -266,6 +271,17 @@ func hasConn(s *scheduler, peerID core.PeerID, infoHash core.InfoHash) bool {
 	return <-result
 }
 
+// counter returns the value of the counter called name in p's stats.
+func (p *testPeer) counter(name string) int64 {
+	var v int64
+	for _, c := range p.stats.Snapshot().Counters() {
+		if c.Name() == name {
+			v += c.Value()
+		}
+	}
+	return v
+}
+
 type hasTorrentEvent struct {
 	infoHash core.InfoHash
 	result   chan bool
@@ -0,0 +1,113 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentstorage
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/utils/log"
+)
+
+// RestoreDownloads verifies the persisted state of all in-progress downloads,
+// such that torrents re-opened after a restart resume from where they left off.
+//
+// Piece statuses are persisted as pieces are written, however a crash may leave
+// piece data and piece metadata out of sync. Every piece marked complete is
+// therefore re-hashed and reset to empty if it does not match its metainfo
+// piece sum. Downloads with missing or malformed metadata are discarded.
+func (a *TorrentArchive) RestoreDownloads() error {
+	names, err := a.cads.ListDownloadFileNames()
+	if err != nil {
+		return fmt.Errorf("list download files: %s", err)
+	}
+	for _, name := range names {
+		if err := a.restoreDownload(name); err != nil {
+			log.With("name", name).Warnf("Discarding download, unable to restore: %s", err)
+			a.stats.Counter("discarded_downloads").Inc(1)
+			if err := a.cads.Download().DeleteFile(name); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("delete %s: %s", name, err)
+			}
+			continue
+		}
+		a.stats.Counter("restored_downloads").Inc(1)
+	}
+	return nil
+}
+
+func (a *TorrentArchive) restoreDownload(name string) error {
+	var tm metadata.TorrentMeta
+	if err := a.cads.Download().GetMetadata(name, &tm); err != nil {
+		return fmt.Errorf("get metainfo: %s", err)
+	}
+	mi := tm.MetaInfo
+	if mi.Digest().Hex() != name {
+		return fmt.Errorf("metainfo digest %s does not match file", mi.Digest().Hex())
+	}
+
+	var psm pieceStatusMetadata
+	if err := a.cads.Download().GetMetadata(name, &psm); os.IsNotExist(err) {
+		// No pieces have been written yet.
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("get piece metadata: %s", err)
+	}
+	if len(psm.pieces) != mi.NumPieces() {
+		return fmt.Errorf(
+			"piece metadata has %d pieces, expected %d", len(psm.pieces), mi.NumPieces())
+	}
+
+	f, err := a.cads.Download().GetFileReader(name)
+	if err != nil {
+		return fmt.Errorf("get download reader: %s", err)
+	}
+	defer f.Close()
+
+	var discarded int
+	for pi, p := range psm.pieces {
+		if p.status != _complete {
+			continue
+		}
+		ok, err := verifyPiece(f, mi, pi)
+		if err != nil {
+			return fmt.Errorf("verify piece %d: %s", pi, err)
+		}
+		if ok {
+			continue
+		}
+		if _, err := a.cads.Download().SetMetadataAt(
+			name, &pieceStatusMetadata{}, []byte{byte(_empty)}, int64(pi)); err != nil {
+			return fmt.Errorf("reset piece %d metadata: %s", pi, err)
+		}
+		discarded++
+	}
+	if discarded > 0 {
+		log.With("name", name).Warnf("Discarded %d corrupt pieces", discarded)
+		a.stats.Counter("discarded_pieces").Inc(int64(discarded))
+	}
+	return nil
+}
+
+// verifyPiece returns whether the data of piece pi in f matches its piece sum.
+func verifyPiece(f io.ReaderAt, mi *core.MetaInfo, pi int) (bool, error) {
+	h := core.PieceHash()
+	r := io.NewSectionReader(f, mi.PieceLength()*int64(pi), mi.GetPieceLength(pi))
+	if _, err := io.Copy(h, r); err != nil {
+		return false, err
+	}
+	return h.Sum32() == mi.GetPieceSum(pi), nil
+}
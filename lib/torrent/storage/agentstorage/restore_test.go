@@ -0,0 +1,134 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentstorage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/torrent/storage/piecereader"
+	"github.com/uber/kraken/utils/bitsetutil"
+)
+
+func TestRestoreDownloadsKeepsValidPieces(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newArchiveMocks(t)
+	defer cleanup()
+
+	archive := mocks.new()
+
+	namespace := core.TagFixture()
+	blob := core.SizedBlobFixture(4, 1)
+	mi := blob.MetaInfo
+
+	mocks.metaInfoClient.EXPECT().Download(namespace, mi.Digest()).Return(mi, nil)
+
+	tor, err := archive.CreateTorrent(namespace, mi.Digest())
+	require.NoError(err)
+	require.NoError(tor.WritePiece(piecereader.NewBuffer(blob.Content[0:1]), 0))
+	require.NoError(tor.WritePiece(piecereader.NewBuffer(blob.Content[2:3]), 2))
+
+	// Simulate a restart.
+	archive = mocks.new()
+	require.NoError(archive.RestoreDownloads())
+
+	info, err := archive.Stat(namespace, mi.Digest())
+	require.NoError(err)
+	require.Equal(bitsetutil.FromBools(true, false, true, false), info.Bitfield())
+}
+
+func TestRestoreDownloadsDiscardsCorruptPieces(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newArchiveMocks(t)
+	defer cleanup()
+
+	archive := mocks.new()
+
+	namespace := core.TagFixture()
+	blob := core.SizedBlobFixture(4, 1)
+	mi := blob.MetaInfo
+
+	mocks.metaInfoClient.EXPECT().Download(namespace, mi.Digest()).Return(mi, nil)
+
+	tor, err := archive.CreateTorrent(namespace, mi.Digest())
+	require.NoError(err)
+	require.NoError(tor.WritePiece(piecereader.NewBuffer(blob.Content[0:1]), 0))
+	require.NoError(tor.WritePiece(piecereader.NewBuffer(blob.Content[2:3]), 2))
+
+	// Corrupt piece 2 on disk.
+	f, err := mocks.cads.GetDownloadFileReadWriter(mi.Digest().Hex())
+	require.NoError(err)
+	_, err = f.WriteAt([]byte{blob.Content[2] + 1}, 2)
+	require.NoError(err)
+	require.NoError(f.Close())
+
+	archive = mocks.new()
+	require.NoError(archive.RestoreDownloads())
+
+	info, err := archive.Stat(namespace, mi.Digest())
+	require.NoError(err)
+	require.Equal(bitsetutil.FromBools(true, false, false, false), info.Bitfield())
+
+	// The discarded piece can be written again.
+	tor, err = archive.GetTorrent(namespace, mi.Digest())
+	require.NoError(err)
+	require.NoError(tor.WritePiece(piecereader.NewBuffer(blob.Content[2:3]), 2))
+}
+
+func TestRestoreDownloadsDiscardsDownloadsWithoutMetaInfo(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newArchiveMocks(t)
+	defer cleanup()
+
+	d := core.DigestFixture()
+	require.NoError(mocks.cads.CreateDownloadFile(d.Hex(), 4))
+
+	archive := mocks.new()
+	require.NoError(archive.RestoreDownloads())
+
+	_, err := mocks.cads.Download().GetFileStat(d.Hex())
+	require.True(os.IsNotExist(err))
+}
+
+func TestRestoreDownloadsIgnoresCachedFiles(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newArchiveMocks(t)
+	defer cleanup()
+
+	archive := mocks.new()
+
+	namespace := core.TagFixture()
+	blob := core.SizedBlobFixture(2, 1)
+	mi := blob.MetaInfo
+
+	mocks.metaInfoClient.EXPECT().Download(namespace, mi.Digest()).Return(mi, nil)
+
+	tor, err := archive.CreateTorrent(namespace, mi.Digest())
+	require.NoError(err)
+	require.NoError(tor.WritePiece(piecereader.NewBuffer(blob.Content[0:1]), 0))
+	require.NoError(tor.WritePiece(piecereader.NewBuffer(blob.Content[1:2]), 1))
+	require.True(tor.Complete())
+
+	require.NoError(archive.RestoreDownloads())
+
+	_, err = mocks.cads.Cache().GetFileStat(mi.Digest().Hex())
+	require.NoError(err)
+}
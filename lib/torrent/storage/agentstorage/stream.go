@@ -0,0 +1,176 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentstorage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
+)
+
+// GetMetaInfo returns the metainfo of the blob d in cads, which is available
+// once the torrent of d has been created.
+func GetMetaInfo(cads *store.CADownloadStore, d core.Digest) (*core.MetaInfo, error) {
+	var tm metadata.TorrentMeta
+	if err := cads.Any().GetMetadata(d.Hex(), &tm); err != nil {
+		return nil, err
+	}
+	return tm.MetaInfo, nil
+}
+
+// WaitForMetaInfo polls cads every pollInterval until the torrent of d has
+// been created. If the download finishes first, returns nil metainfo along
+// with the result of the download.
+func WaitForMetaInfo(
+	cads *store.CADownloadStore,
+	d core.Digest,
+	pollInterval time.Duration,
+	downloaded <-chan error) (*core.MetaInfo, error) {
+
+	for {
+		mi, err := GetMetaInfo(cads, d)
+		if err == nil {
+			return mi, nil
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("get metainfo: %s", err)
+		}
+		select {
+		case err := <-downloaded:
+			return nil, err
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// StreamReader reads a byte range of a blob while the blob is being
+// downloaded into a CADownloadStore. Reads of pieces which are not yet
+// downloaded block until the piece completes or the download fails, in which
+// case the download error is returned.
+type StreamReader struct {
+	cads         *store.CADownloadStore
+	mi           *core.MetaInfo
+	offset       int64
+	end          int64
+	pollInterval time.Duration
+	downloaded   <-chan error
+	done         bool
+	f            store.FileReader
+}
+
+// NewStreamReader creates a new StreamReader which reads length bytes of the
+// blob described by mi, starting at offset. The result of the download must be
+// sent on downloaded once the download finishes.
+func NewStreamReader(
+	cads *store.CADownloadStore,
+	mi *core.MetaInfo,
+	offset int64,
+	length int64,
+	pollInterval time.Duration,
+	downloaded <-chan error) *StreamReader {
+
+	return &StreamReader{
+		cads:         cads,
+		mi:           mi,
+		offset:       offset,
+		end:          offset + length,
+		pollInterval: pollInterval,
+		downloaded:   downloaded,
+	}
+}
+
+// Read reads up to the end of the current piece, blocking until the piece is
+// downloaded.
+func (r *StreamReader) Read(p []byte) (int, error) {
+	if r.offset >= r.end {
+		return 0, io.EOF
+	}
+	pi := int(r.offset / r.mi.PieceLength())
+	if err := r.waitForPiece(pi); err != nil {
+		return 0, err
+	}
+	if r.f == nil {
+		f, err := r.cads.Any().GetFileReader(r.mi.Digest().Hex())
+		if err != nil {
+			return 0, fmt.Errorf("get file reader: %s", err)
+		}
+		r.f = f
+	}
+	pieceEnd := int64(pi+1) * r.mi.PieceLength()
+	if pieceEnd > r.end {
+		pieceEnd = r.end
+	}
+	if int64(len(p)) > pieceEnd-r.offset {
+		p = p[:pieceEnd-r.offset]
+	}
+	n, err := r.f.ReadAt(p, r.offset)
+	r.offset += int64(n)
+	if err != nil && err != io.EOF {
+		return n, err
+	}
+	return n, nil
+}
+
+// Close closes the underlying file.
+func (r *StreamReader) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	return r.f.Close()
+}
+
+func (r *StreamReader) waitForPiece(pi int) error {
+	for {
+		complete, err := r.pieceComplete(pi)
+		if err != nil {
+			return err
+		}
+		if complete {
+			return nil
+		}
+		if r.done {
+			return errors.New("download finished without completing piece")
+		}
+		select {
+		case err := <-r.downloaded:
+			if err != nil {
+				return err
+			}
+			r.done = true
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+func (r *StreamReader) pieceComplete(pi int) (bool, error) {
+	var md pieceStatusMetadata
+	err := r.cads.Download().GetMetadata(r.mi.Digest().Hex(), &md)
+	if r.cads.InCacheError(err) {
+		// File has been committed to the cache, so all pieces are complete.
+		return true, nil
+	} else if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("get piece metadata: %s", err)
+	}
+	if pi >= len(md.pieces) {
+		return false, nil
+	}
+	return md.pieces[pi].status == _complete, nil
+}
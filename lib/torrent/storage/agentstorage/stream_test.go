@@ -0,0 +1,108 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentstorage
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/torrent/storage/piecereader"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamReaderBlocksUntilPiecesComplete(t *testing.T) {
+	require := require.New(t)
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(4, 1)
+	mi := blob.MetaInfo
+
+	prepareStore(cads, mi)
+
+	tor, err := NewTorrent(cads, mi)
+	require.NoError(err)
+
+	downloaded := make(chan error, 1)
+	go func() {
+		for i := 0; i < tor.NumPieces(); i++ {
+			time.Sleep(10 * time.Millisecond)
+			if err := tor.WritePiece(piecereader.NewBuffer(blob.Content[i:i+1]), i); err != nil {
+				downloaded <- err
+				return
+			}
+		}
+		downloaded <- nil
+	}()
+
+	r := NewStreamReader(cads, mi, 0, mi.Length(), time.Millisecond, downloaded)
+	defer r.Close()
+
+	result, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	require.Equal(blob.Content, result)
+}
+
+func TestStreamReaderRange(t *testing.T) {
+	require := require.New(t)
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(7, 2)
+	mi := blob.MetaInfo
+
+	prepareStore(cads, mi)
+
+	tor, err := NewTorrent(cads, mi)
+	require.NoError(err)
+
+	// Only the pieces covering bytes [3, 6) are written.
+	require.NoError(tor.WritePiece(piecereader.NewBuffer(blob.Content[2:4]), 1))
+	require.NoError(tor.WritePiece(piecereader.NewBuffer(blob.Content[4:6]), 2))
+
+	r := NewStreamReader(cads, mi, 3, 3, time.Millisecond, make(chan error))
+	defer r.Close()
+
+	result, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	require.Equal(blob.Content[3:6], result)
+}
+
+func TestStreamReaderReturnsDownloadError(t *testing.T) {
+	require := require.New(t)
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	mi := core.SizedBlobFixture(4, 1).MetaInfo
+
+	prepareStore(cads, mi)
+
+	downloadErr := errors.New("some error")
+	downloaded := make(chan error, 1)
+	downloaded <- downloadErr
+
+	r := NewStreamReader(cads, mi, 0, mi.Length(), time.Millisecond, downloaded)
+	defer r.Close()
+
+	_, err := r.Read(make([]byte, 4))
+	require.Equal(downloadErr, err)
+}
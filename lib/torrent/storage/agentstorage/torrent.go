@@ -189,7 +189,7 @@ func (t *Torrent) writePiece(src storage.PieceReader, pi int) error {
 		return fmt.Errorf("copy: %s", err)
 	}
 	if h.Sum32() != t.metaInfo.GetPieceSum(pi) {
-		return errors.New("invalid piece sum")
+		return storage.ErrInvalidPieceSum
 	}
 
 	if err := t.markPieceComplete(pi); err != nil {
@@ -231,6 +231,9 @@ func (t *Torrent) WritePiece(src storage.PieceReader, pi int) error {
 	if err := t.writePiece(src, pi); err != nil {
 		// Allow other threads to write this piece since we mysteriously failed.
 		piece.markEmpty()
+		if err == storage.ErrInvalidPieceSum {
+			return err
+		}
 		return fmt.Errorf("write piece: %s", err)
 	}
 
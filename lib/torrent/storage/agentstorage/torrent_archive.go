@@ -92,6 +92,9 @@ func (a *TorrentArchive) CreateTorrent(namespace string, d core.Digest) (storage
 		createErr := a.cads.CreateDownloadFile(mi.Digest().Hex(), mi.Length())
 		if createErr != nil &&
 			!(a.cads.InDownloadError(createErr) || a.cads.InCacheError(createErr)) {
+			if store.IsInsufficientStorage(createErr) {
+				return nil, createErr
+			}
 			return nil, fmt.Errorf("create download file: %s", createErr)
 		}
 		tm.MetaInfo = mi
@@ -101,6 +104,10 @@ func (a *TorrentArchive) CreateTorrent(namespace string, d core.Digest) (storage
 	} else if err != nil {
 		return nil, fmt.Errorf("get metainfo: %s", err)
 	}
+	// Recorded for expiring the blob by namespace once cached.
+	if err := a.cads.AddNamespace(d.Hex(), namespace); err != nil {
+		return nil, fmt.Errorf("add namespace: %s", err)
+	}
 	t, err := NewTorrent(a.cads, tm.MetaInfo)
 	if err != nil {
 		return nil, fmt.Errorf("initialize torrent: %s", err)
@@ -128,3 +135,13 @@ func (a *TorrentArchive) DeleteTorrent(d core.Digest) error {
 	}
 	return nil
 }
+
+// Pin protects the torrent of d from cache eviction.
+func (a *TorrentArchive) Pin(d core.Digest) {
+	a.cads.Pin(d.Hex())
+}
+
+// Unpin releases a pin on the torrent of d.
+func (a *TorrentArchive) Unpin(d core.Digest) {
+	a.cads.Unpin(d.Hex())
+}
@@ -118,6 +118,29 @@ func TestTorrentWriteComplete(t *testing.T) {
 	require.Equal(storage.ErrPieceComplete, tor.WritePiece(piecereader.NewBuffer(blob.Content[:1]), 0))
 }
 
+func TestTorrentWritePieceInvalidPieceSum(t *testing.T) {
+	require := require.New(t)
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(1, 1)
+
+	prepareStore(cads, blob.MetaInfo)
+
+	tor, err := NewTorrent(cads, blob.MetaInfo)
+	require.NoError(err)
+
+	corrupt := []byte{blob.Content[0] + 1}
+
+	require.Equal(storage.ErrInvalidPieceSum, tor.WritePiece(piecereader.NewBuffer(corrupt), 0))
+	require.False(tor.Complete())
+
+	// The piece can be written again once the corrupt write is rejected.
+	require.NoError(tor.WritePiece(piecereader.NewBuffer(blob.Content), 0))
+	require.True(tor.Complete())
+}
+
 func TestTorrentWriteMultiplePieceConcurrent(t *testing.T) {
 	require := require.New(t)
 
@@ -29,6 +29,10 @@ var ErrNotFound = errors.New("torrent not found")
 // complete.
 var ErrPieceComplete = errors.New("piece is already complete")
 
+// ErrInvalidPieceSum occurs when Torrent cannot write a piece because its data
+// does not match the piece sum in the torrent metainfo.
+var ErrInvalidPieceSum = errors.New("invalid piece sum")
+
 // PieceReader defines operations for lazy piece reading.
 type PieceReader interface {
 	io.ReadCloser
@@ -63,3 +67,10 @@ type TorrentArchive interface {
 	GetTorrent(namespace string, d core.Digest) (Torrent, error)
 	DeleteTorrent(d core.Digest) error
 }
+
+// Pinner is an optional interface for TorrentArchives which may evict torrents
+// from disk. Pinned torrents are never evicted.
+type Pinner interface {
+	Pin(d core.Digest)
+	Unpin(d core.Digest)
+}
@@ -58,6 +58,11 @@ func (i *TorrentInfo) PercentDownloaded() int {
 	return i.percentDownloaded
 }
 
+// WithBitfield returns a copy of i with the given piece status bitfield.
+func (i *TorrentInfo) WithBitfield(bitfield *bitset.BitSet) *TorrentInfo {
+	return NewTorrentInfo(i.metainfo, bitfield)
+}
+
 // Bitfield returns the piece status bitfield of the torrent. Note, this is a
 // snapshot and may be stale information.
 func (i *TorrentInfo) Bitfield() *bitset.BitSet {
@@ -0,0 +1,132 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing records OpenTelemetry spans of requests as they pass through
+// Kraken components. Tracing is disabled by default, in which case spans are
+// no-ops.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/uber/kraken/utils/log"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const _tracerName = "github.com/uber/kraken"
+
+// Config defines tracing configuration.
+type Config struct {
+	// Enabled exports spans to an OTLP collector.
+	Enabled bool `yaml:"enabled"`
+
+	// Endpoint is the host:port of the OTLP/HTTP collector spans are exported
+	// to, e.g. an OpenTelemetry collector running on the same host.
+	Endpoint string `yaml:"endpoint"`
+
+	// Insecure exports spans over HTTP instead of HTTPS.
+	Insecure bool `yaml:"insecure"`
+
+	// SampleRatio is the fraction of traces which are sampled, unless the
+	// incoming request already carries a sampling decision.
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+func (c Config) applyDefaults() Config {
+	if c.Endpoint == "" {
+		c.Endpoint = "localhost:4318"
+	}
+	if c.SampleRatio == 0 {
+		c.SampleRatio = 1
+	}
+	return c
+}
+
+// Init installs a global tracer provider which exports spans of service, and
+// propagates trace context via W3C Trace Context headers. The returned function
+// flushes any buffered spans, and must be called before exiting. If tracing is
+// disabled, Init is a no-op.
+func Init(config Config, service string) (func(), error) {
+	if !config.Enabled {
+		return func() {}, nil
+	}
+	config = config.applyDefaults()
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint)}
+	if config.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("new exporter: %s", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL, semconv.ServiceNameKey.String(service))),
+		sdktrace.WithSampler(sdktrace.ParentBased(
+			sdktrace.TraceIDRatioBased(config.SampleRatio))))
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			log.Errorf("Error shutting down tracer provider: %s", err)
+		}
+	}, nil
+}
+
+// Start starts a span called name, which is a child of the span in ctx if
+// any. The span must be ended by the caller, e.g. via End.
+func Start(
+	ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+
+	return otel.Tracer(_tracerName).Start(ctx, name, opts...)
+}
+
+// End ends span, marking it as failed if err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Extract returns ctx with the remote trace context carried by header, if
+// any, such that spans started from the returned context continue the trace
+// of the caller.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Inject sets headers in header which carry the trace context of ctx to the
+// recipient of a request.
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInitDisabled(t *testing.T) {
+	require := require.New(t)
+
+	shutdown, err := Init(Config{}, "kraken-test")
+	require.NoError(err)
+	shutdown()
+}
+
+func TestEndRecordsError(t *testing.T) {
+	require := require.New(t)
+
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	_, span := Start(context.Background(), "ok")
+	End(span, nil)
+	_, span = Start(context.Background(), "failed")
+	End(span, errors.New("some error"))
+
+	spans := sr.Ended()
+	require.Len(spans, 2)
+	require.Equal(codes.Unset, spans[0].Status().Code)
+	require.Equal(codes.Error, spans[1].Status().Code)
+}
+
+func TestInjectExtract(t *testing.T) {
+	require := require.New(t)
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	ctx, span := Start(context.Background(), "client")
+	defer span.End()
+
+	header := make(http.Header)
+	Inject(ctx, header)
+	require.NotEmpty(header.Get("traceparent"))
+
+	_, child := Start(Extract(context.Background(), header), "server")
+	defer child.End()
+	require.Equal(span.SpanContext().TraceID(), child.SpanContext().TraceID())
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package upstream
+
+import (
+	"time"
+
+	"github.com/cenkalti/backoff"
+
+	"github.com/uber/kraken/utils/log"
+)
+
+// StartupRetryConfig defines how building an upstream is retried at startup,
+// e.g. when DNS or the upstream itself is not yet available. Retries are
+// disabled by default.
+type StartupRetryConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	InitialInterval time.Duration `yaml:"initial_interval"`
+	MaxInterval     time.Duration `yaml:"max_interval"`
+
+	// MaxDuration is the total time spent retrying before giving up.
+	MaxDuration time.Duration `yaml:"max_duration"`
+}
+
+func (c *StartupRetryConfig) applyDefaults() {
+	if c.InitialInterval == 0 {
+		c.InitialInterval = time.Second
+	}
+	if c.MaxInterval == 0 {
+		c.MaxInterval = 30 * time.Second
+	}
+	if c.MaxDuration == 0 {
+		c.MaxDuration = 5 * time.Minute
+	}
+}
+
+// Retry runs build until it succeeds. If c is enabled, failures are retried
+// with exponential backoff until c.MaxDuration elapses, otherwise build only
+// runs once. Returns the last error build returned.
+func (c StartupRetryConfig) Retry(name string, build func() error) error {
+	if !c.Enabled {
+		return build()
+	}
+	c.applyDefaults()
+	b := &backoff.ExponentialBackOff{
+		InitialInterval:     c.InitialInterval,
+		RandomizationFactor: backoff.DefaultRandomizationFactor,
+		Multiplier:          backoff.DefaultMultiplier,
+		MaxInterval:         c.MaxInterval,
+		MaxElapsedTime:      c.MaxDuration,
+		Clock:               backoff.SystemClock,
+	}
+	return backoff.RetryNotify(build, b, func(err error, d time.Duration) {
+		log.With("upstream", name).Warnf("Build failed, retrying in %s: %s", d, err)
+	})
+}
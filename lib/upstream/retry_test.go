@@ -0,0 +1,74 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package upstream
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartupRetryDisabledRunsOnce(t *testing.T) {
+	require := require.New(t)
+
+	var calls int
+	err := StartupRetryConfig{}.Retry("test", func() error {
+		calls++
+		return errors.New("some error")
+	})
+	require.Error(err)
+	require.Equal(1, calls)
+}
+
+func TestStartupRetryEventuallySucceeds(t *testing.T) {
+	require := require.New(t)
+
+	config := StartupRetryConfig{
+		Enabled:         true,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     5 * time.Millisecond,
+		MaxDuration:     time.Second,
+	}
+	var calls int
+	err := config.Retry("test", func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("some error")
+		}
+		return nil
+	})
+	require.NoError(err)
+	require.Equal(3, calls)
+}
+
+func TestStartupRetryGivesUpAfterMaxDuration(t *testing.T) {
+	require := require.New(t)
+
+	config := StartupRetryConfig{
+		Enabled:         true,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     5 * time.Millisecond,
+		MaxDuration:     50 * time.Millisecond,
+	}
+	buildErr := errors.New("some error")
+	var calls int
+	err := config.Retry("test", func() error {
+		calls++
+		return buildErr
+	})
+	require.Equal(buildErr, err)
+	require.True(calls > 1)
+}
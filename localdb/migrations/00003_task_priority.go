@@ -0,0 +1,75 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00003, down00003)
+}
+
+func up00003(tx *sql.Tx) error {
+	for _, table := range []string{"replicate_tag_task", "writeback_task"} {
+		if _, err := tx.Exec(
+			`ALTER TABLE ` + table + ` ADD COLUMN priority integer NOT NULL DEFAULT 50;`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func down00003(tx *sql.Tx) error {
+	// SQLite does not support dropping columns, so tables are rebuilt without
+	// the priority column.
+	_, err := tx.Exec(`
+		CREATE TABLE replicate_tag_task_backup (
+			tag          text      NOT NULL,
+			digest       blob      NOT NULL,
+			dependencies blob      NOT NULL,
+			destination  text      NOT NULL,
+			created_at   timestamp DEFAULT CURRENT_TIMESTAMP,
+			last_attempt timestamp NOT NULL,
+			status       text      NOT NULL,
+			failures     integer   NOT NULL,
+			delay        integer   NOT NULL,
+			PRIMARY KEY(tag, destination)
+		);
+		INSERT INTO replicate_tag_task_backup
+			SELECT tag, digest, dependencies, destination, created_at, last_attempt, status, failures, delay
+			FROM replicate_tag_task;
+		DROP TABLE replicate_tag_task;
+		ALTER TABLE replicate_tag_task_backup RENAME TO replicate_tag_task;
+
+		CREATE TABLE writeback_task_backup (
+			namespace    text      NOT NULL,
+			name         text      NOT NULL,
+			created_at   timestamp DEFAULT CURRENT_TIMESTAMP,
+			last_attempt timestamp NOT NULL,
+			status       text      NOT NULL,
+			failures     integer   NOT NULL,
+			delay        integer   NOT NULL,
+			PRIMARY KEY(namespace, name)
+		);
+		INSERT INTO writeback_task_backup
+			SELECT namespace, name, created_at, last_attempt, status, failures, delay
+			FROM writeback_task;
+		DROP TABLE writeback_task;
+		ALTER TABLE writeback_task_backup RENAME TO writeback_task;
+	`)
+	return err
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00005, down00005)
+}
+
+// Indexing status keeps polling pending and failed tasks cheap as dead tasks
+// accumulate.
+func up00005(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE INDEX replicate_tag_task_status ON replicate_tag_task(status);
+		CREATE INDEX writeback_task_status ON writeback_task(status);
+	`)
+	return err
+}
+
+func down00005(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		DROP INDEX replicate_tag_task_status;
+		DROP INDEX writeback_task_status;
+	`)
+	return err
+}
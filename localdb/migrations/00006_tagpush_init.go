@@ -0,0 +1,53 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(up00006, down00006)
+}
+
+func up00006(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS push_tag_task (
+			tag          text      NOT NULL,
+			namespace    text      NOT NULL,
+			digest       blob      NOT NULL,
+			dependencies blob      NOT NULL,
+			created_at   timestamp DEFAULT CURRENT_TIMESTAMP,
+			last_attempt timestamp NOT NULL,
+			status       text      NOT NULL,
+			failures     integer   NOT NULL,
+			delay        integer   NOT NULL,
+			priority     integer   NOT NULL DEFAULT 50,
+			last_error   text      NOT NULL DEFAULT "",
+			PRIMARY KEY(tag)
+		);
+		CREATE INDEX push_tag_task_status ON push_tag_task(status);
+	`)
+	return err
+}
+
+func down00006(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		DROP INDEX push_tag_task_status;
+		DROP TABLE push_tag_task;
+	`)
+	return err
+}
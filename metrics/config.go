@@ -18,6 +18,11 @@ type Config struct {
 	Backend string       `yaml:"backend"`
 	Statsd  StatsdConfig `yaml:"statsd"`
 	M3      M3Config     `yaml:"m3"`
+
+	// Tags are static tags added to every metric, e.g. hardware class or
+	// availability zone. Ignored by backends which do not support tags, such
+	// as statsd.
+	Tags map[string]string `yaml:"tags"`
 }
 
 // StatsdConfig defines statsd configuration.
@@ -29,6 +29,7 @@ func init() {
 	register("statsd", newStatsdScope)
 	register("disabled", newDisabledScope)
 	register("m3", newM3Scope)
+	register("prometheus", newPrometheusScope)
 }
 
 var _scopeFactories = make(map[string]scopeFactory)
@@ -43,7 +44,7 @@ func register(name string, f scopeFactory) {
 }
 
 // New creates a new metrics Scope from config. If no backend is configured, metrics
-// are disabled.
+// are disabled. All scopes derived from the returned Scope include config.Tags.
 func New(config Config, cluster string) (tally.Scope, io.Closer, error) {
 	if config.Backend == "" {
 		config.Backend = "disabled"
@@ -52,7 +53,14 @@ func New(config Config, cluster string) (tally.Scope, io.Closer, error) {
 	if !ok || f == nil {
 		return nil, nil, fmt.Errorf("metrics backend %q not registered", config.Backend)
 	}
-	return f(config, cluster)
+	s, c, err := f(config, cluster)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(config.Tags) > 0 {
+		s = s.Tagged(config.Tags)
+	}
+	return s, c, nil
 }
 
 // EmitVersion periodically emits the current GIT_DESCRIBE as a metric.
@@ -0,0 +1,78 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package metrics
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func TestNewAddsTags(t *testing.T) {
+	require := require.New(t)
+
+	root := tally.NewTestScope("", nil)
+	_scopeFactories["test"] = func(Config, string) (tally.Scope, io.Closer, error) {
+		return root, ioutil.NopCloser(nil), nil
+	}
+	defer delete(_scopeFactories, "test")
+
+	stats, _, err := New(Config{
+		Backend: "test",
+		Tags:    map[string]string{"instance_type": "m5.large"},
+	}, "test-cluster")
+	require.NoError(err)
+
+	stats.Tagged(map[string]string{"module": "foo"}).Counter("count").Inc(1)
+
+	counters := root.Snapshot().Counters()
+	require.Len(counters, 1)
+	for _, c := range counters {
+		require.Equal(map[string]string{
+			"instance_type": "m5.large",
+			"module":        "foo",
+		}, c.Tags())
+	}
+}
+
+func TestNewUnregisteredBackend(t *testing.T) {
+	_, _, err := New(Config{Backend: "unknown"}, "test-cluster")
+	require.Error(t, err)
+}
+
+func TestNewPrometheus(t *testing.T) {
+	require := require.New(t)
+
+	stats, closer, err := New(Config{Backend: "prometheus"}, "test-cluster")
+	require.NoError(err)
+
+	stats.Tagged(map[string]string{"module": "foo"}).Counter("prometheus_test_count").Inc(1)
+
+	// Metrics are only registered when reported, which closing flushes.
+	require.NoError(closer.Close())
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(err)
+	var found bool
+	for _, f := range families {
+		if f.GetName() == "prometheus_test_count" {
+			found = true
+		}
+	}
+	require.True(found)
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package metrics
+
+import (
+	"io"
+	"time"
+
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/uber-go/tally"
+	promreporter "github.com/uber-go/tally/prometheus"
+)
+
+// newPrometheusScope creates a scope whose metrics are registered with the
+// default Prometheus registry, to be scraped from a /metrics endpoint rather
+// than pushed to a collector.
+func newPrometheusScope(config Config, cluster string) (tally.Scope, io.Closer, error) {
+	r := promreporter.NewReporter(promreporter.Options{
+		OnRegisterError: func(err error) {
+			log.Warnf("Error registering prometheus metric: %s", err)
+		},
+	})
+	var tags map[string]string
+	if cluster != "" {
+		tags = map[string]string{"cluster": cluster}
+	}
+	s, c := tally.NewRootScope(tally.ScopeOptions{
+		Tags:           tags,
+		CachedReporter: r,
+		Separator:      promreporter.DefaultSeparator,
+	}, time.Second)
+	return s, c, nil
+}
@@ -5,7 +5,9 @@
 package mocktagclient
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
+	tagclient "github.com/uber/kraken/build-index/tagclient"
 	core "github.com/uber/kraken/core"
 	reflect "reflect"
 	time "time"
@@ -35,146 +37,205 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 }
 
 // DuplicatePut mocks base method
-func (m *MockClient) DuplicatePut(arg0 string, arg1 core.Digest, arg2 time.Duration) error {
+func (m *MockClient) DuplicatePut(arg0 context.Context, arg1 string, arg2 core.Digest, arg3 time.Duration) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DuplicatePut", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "DuplicatePut", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DuplicatePut indicates an expected call of DuplicatePut
-func (mr *MockClientMockRecorder) DuplicatePut(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) DuplicatePut(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DuplicatePut", reflect.TypeOf((*MockClient)(nil).DuplicatePut), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DuplicatePut", reflect.TypeOf((*MockClient)(nil).DuplicatePut), arg0, arg1, arg2, arg3)
 }
 
 // DuplicateReplicate mocks base method
-func (m *MockClient) DuplicateReplicate(arg0 string, arg1 core.Digest, arg2 core.DigestList, arg3 time.Duration) error {
+func (m *MockClient) DuplicateReplicate(arg0 context.Context, arg1 string, arg2 core.Digest, arg3 core.DigestList, arg4 time.Duration) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DuplicateReplicate", arg0, arg1, arg2, arg3)
+	ret := m.ctrl.Call(m, "DuplicateReplicate", arg0, arg1, arg2, arg3, arg4)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DuplicateReplicate indicates an expected call of DuplicateReplicate
-func (mr *MockClientMockRecorder) DuplicateReplicate(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) DuplicateReplicate(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DuplicateReplicate", reflect.TypeOf((*MockClient)(nil).DuplicateReplicate), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DuplicateReplicate", reflect.TypeOf((*MockClient)(nil).DuplicateReplicate), arg0, arg1, arg2, arg3, arg4)
 }
 
 // Get mocks base method
-func (m *MockClient) Get(arg0 string) (core.Digest, error) {
+func (m *MockClient) Get(arg0 context.Context, arg1 string) (core.Digest, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Get", arg0)
+	ret := m.ctrl.Call(m, "Get", arg0, arg1)
 	ret0, _ := ret[0].(core.Digest)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Get indicates an expected call of Get
-func (mr *MockClientMockRecorder) Get(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockClient)(nil).Get), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockClient)(nil).Get), arg0, arg1)
+}
+
+// GetMany mocks base method
+func (m *MockClient) GetMany(arg0 context.Context, arg1 []string) (map[string]core.Digest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMany", arg0, arg1)
+	ret0, _ := ret[0].(map[string]core.Digest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMany indicates an expected call of GetMany
+func (mr *MockClientMockRecorder) GetMany(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMany", reflect.TypeOf((*MockClient)(nil).GetMany), arg0, arg1)
+}
+
+// GetUncached mocks base method
+func (m *MockClient) GetUncached(arg0 context.Context, arg1 string) (core.Digest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUncached", arg0, arg1)
+	ret0, _ := ret[0].(core.Digest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUncached indicates an expected call of GetUncached
+func (mr *MockClientMockRecorder) GetUncached(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUncached", reflect.TypeOf((*MockClient)(nil).GetUncached), arg0, arg1)
 }
 
 // Has mocks base method
-func (m *MockClient) Has(arg0 string) (bool, error) {
+func (m *MockClient) Has(arg0 context.Context, arg1 string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Has", arg0)
+	ret := m.ctrl.Call(m, "Has", arg0, arg1)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Has indicates an expected call of Has
-func (mr *MockClientMockRecorder) Has(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) Has(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Has", reflect.TypeOf((*MockClient)(nil).Has), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Has", reflect.TypeOf((*MockClient)(nil).Has), arg0, arg1)
 }
 
 // List mocks base method
-func (m *MockClient) List(arg0 string) ([]string, error) {
+func (m *MockClient) List(arg0 context.Context, arg1 string) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "List", arg0)
+	ret := m.ctrl.Call(m, "List", arg0, arg1)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // List indicates an expected call of List
-func (mr *MockClientMockRecorder) List(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) List(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockClient)(nil).List), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockClient)(nil).List), arg0, arg1)
 }
 
 // ListRepository mocks base method
-func (m *MockClient) ListRepository(arg0 string) ([]string, error) {
+func (m *MockClient) ListRepository(arg0 context.Context, arg1 string) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListRepository", arg0)
+	ret := m.ctrl.Call(m, "ListRepository", arg0, arg1)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListRepository indicates an expected call of ListRepository
-func (mr *MockClientMockRecorder) ListRepository(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) ListRepository(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRepository", reflect.TypeOf((*MockClient)(nil).ListRepository), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRepository", reflect.TypeOf((*MockClient)(nil).ListRepository), arg0, arg1)
 }
 
 // Origin mocks base method
-func (m *MockClient) Origin() (string, error) {
+func (m *MockClient) Origin(arg0 context.Context) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Origin")
+	ret := m.ctrl.Call(m, "Origin", arg0)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Origin indicates an expected call of Origin
-func (mr *MockClientMockRecorder) Origin() *gomock.Call {
+func (mr *MockClientMockRecorder) Origin(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Origin", reflect.TypeOf((*MockClient)(nil).Origin))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Origin", reflect.TypeOf((*MockClient)(nil).Origin), arg0)
 }
 
 // Put mocks base method
-func (m *MockClient) Put(arg0 string, arg1 core.Digest) error {
+func (m *MockClient) Put(arg0 context.Context, arg1 string, arg2 core.Digest) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Put", arg0, arg1)
+	ret := m.ctrl.Call(m, "Put", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Put indicates an expected call of Put
-func (mr *MockClientMockRecorder) Put(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) Put(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Put", reflect.TypeOf((*MockClient)(nil).Put), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Put", reflect.TypeOf((*MockClient)(nil).Put), arg0, arg1, arg2)
 }
 
 // PutAndReplicate mocks base method
-func (m *MockClient) PutAndReplicate(arg0 string, arg1 core.Digest) error {
+func (m *MockClient) PutAndReplicate(arg0 context.Context, arg1 string, arg2 core.Digest) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PutAndReplicate", arg0, arg1)
+	ret := m.ctrl.Call(m, "PutAndReplicate", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // PutAndReplicate indicates an expected call of PutAndReplicate
-func (mr *MockClientMockRecorder) PutAndReplicate(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) PutAndReplicate(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutAndReplicate", reflect.TypeOf((*MockClient)(nil).PutAndReplicate), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutAndReplicate", reflect.TypeOf((*MockClient)(nil).PutAndReplicate), arg0, arg1, arg2)
 }
 
 // Replicate mocks base method
-func (m *MockClient) Replicate(arg0 string) error {
+func (m *MockClient) Replicate(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Replicate", arg0)
+	ret := m.ctrl.Call(m, "Replicate", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Replicate indicates an expected call of Replicate
-func (mr *MockClientMockRecorder) Replicate(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) Replicate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replicate", reflect.TypeOf((*MockClient)(nil).Replicate), arg0, arg1)
+}
+
+// ResolvePlatform mocks base method
+func (m *MockClient) ResolvePlatform(arg0 context.Context, arg1 string, arg2 string, arg3 string) (core.Digest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolvePlatform", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(core.Digest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResolvePlatform indicates an expected call of ResolvePlatform
+func (mr *MockClientMockRecorder) ResolvePlatform(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolvePlatform", reflect.TypeOf((*MockClient)(nil).ResolvePlatform), arg0, arg1, arg2, arg3)
+}
+
+// Watch mocks base method
+func (m *MockClient) Watch(arg0 string, arg1 string) *tagclient.Watcher {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Watch", arg0, arg1)
+	ret0, _ := ret[0].(*tagclient.Watcher)
+	return ret0
+}
+
+// Watch indicates an expected call of Watch
+func (mr *MockClientMockRecorder) Watch(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replicate", reflect.TypeOf((*MockClient)(nil).Replicate), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockClient)(nil).Watch), arg0, arg1)
 }
@@ -5,6 +5,7 @@
 package mocktransfer
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	core "github.com/uber/kraken/core"
 	base "github.com/uber/kraken/lib/store/base"
@@ -35,77 +36,77 @@ func (m *MockImageTransferer) EXPECT() *MockImageTransfererMockRecorder {
 }
 
 // Download mocks base method
-func (m *MockImageTransferer) Download(arg0 string, arg1 core.Digest) (base.FileReader, error) {
+func (m *MockImageTransferer) Download(arg0 context.Context, arg1 string, arg2 core.Digest) (base.FileReader, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Download", arg0, arg1)
+	ret := m.ctrl.Call(m, "Download", arg0, arg1, arg2)
 	ret0, _ := ret[0].(base.FileReader)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Download indicates an expected call of Download
-func (mr *MockImageTransfererMockRecorder) Download(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockImageTransfererMockRecorder) Download(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockImageTransferer)(nil).Download), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockImageTransferer)(nil).Download), arg0, arg1, arg2)
 }
 
 // GetTag mocks base method
-func (m *MockImageTransferer) GetTag(arg0 string) (core.Digest, error) {
+func (m *MockImageTransferer) GetTag(arg0 context.Context, arg1 string) (core.Digest, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTag", arg0)
+	ret := m.ctrl.Call(m, "GetTag", arg0, arg1)
 	ret0, _ := ret[0].(core.Digest)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetTag indicates an expected call of GetTag
-func (mr *MockImageTransfererMockRecorder) GetTag(arg0 interface{}) *gomock.Call {
+func (mr *MockImageTransfererMockRecorder) GetTag(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTag", reflect.TypeOf((*MockImageTransferer)(nil).GetTag), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTag", reflect.TypeOf((*MockImageTransferer)(nil).GetTag), arg0, arg1)
 }
 
 // ListTags mocks base method
-func (m *MockImageTransferer) ListTags(arg0 string) ([]string, error) {
+func (m *MockImageTransferer) ListTags(arg0 context.Context, arg1 string) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListTags", arg0)
+	ret := m.ctrl.Call(m, "ListTags", arg0, arg1)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListTags indicates an expected call of ListTags
-func (mr *MockImageTransfererMockRecorder) ListTags(arg0 interface{}) *gomock.Call {
+func (mr *MockImageTransfererMockRecorder) ListTags(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTags", reflect.TypeOf((*MockImageTransferer)(nil).ListTags), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTags", reflect.TypeOf((*MockImageTransferer)(nil).ListTags), arg0, arg1)
 }
 
 // PutTag mocks base method
-func (m *MockImageTransferer) PutTag(arg0 string, arg1 core.Digest) error {
+func (m *MockImageTransferer) PutTag(arg0 context.Context, arg1 string, arg2 core.Digest) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PutTag", arg0, arg1)
+	ret := m.ctrl.Call(m, "PutTag", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // PutTag indicates an expected call of PutTag
-func (mr *MockImageTransfererMockRecorder) PutTag(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockImageTransfererMockRecorder) PutTag(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutTag", reflect.TypeOf((*MockImageTransferer)(nil).PutTag), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutTag", reflect.TypeOf((*MockImageTransferer)(nil).PutTag), arg0, arg1, arg2)
 }
 
 // Stat mocks base method
-func (m *MockImageTransferer) Stat(arg0 string, arg1 core.Digest) (*core.BlobInfo, error) {
+func (m *MockImageTransferer) Stat(arg0 context.Context, arg1 string, arg2 core.Digest) (*core.BlobInfo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Stat", arg0, arg1)
+	ret := m.ctrl.Call(m, "Stat", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*core.BlobInfo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Stat indicates an expected call of Stat
-func (mr *MockImageTransfererMockRecorder) Stat(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockImageTransfererMockRecorder) Stat(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stat", reflect.TypeOf((*MockImageTransferer)(nil).Stat), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stat", reflect.TypeOf((*MockImageTransferer)(nil).Stat), arg0, arg1, arg2)
 }
 
 // Upload mocks base method
@@ -59,6 +59,21 @@ func (mr *MockManagerMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockManager)(nil).Close))
 }
 
+// Counts mocks base method
+func (m *MockManager) Counts() (map[persistedretry.TaskState]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Counts")
+	ret0, _ := ret[0].(map[persistedretry.TaskState]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Counts indicates an expected call of Counts
+func (mr *MockManagerMockRecorder) Counts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Counts", reflect.TypeOf((*MockManager)(nil).Counts))
+}
+
 // Find mocks base method
 func (m *MockManager) Find(arg0 interface{}) ([]persistedretry.Task, error) {
 	m.ctrl.T.Helper()
@@ -74,6 +89,36 @@ func (mr *MockManagerMockRecorder) Find(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockManager)(nil).Find), arg0)
 }
 
+// List mocks base method
+func (m *MockManager) List(arg0 persistedretry.TaskState) ([]persistedretry.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0)
+	ret0, _ := ret[0].([]persistedretry.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List
+func (mr *MockManagerMockRecorder) List(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockManager)(nil).List), arg0)
+}
+
+// RequeueDead mocks base method
+func (m *MockManager) RequeueDead() (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequeueDead")
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequeueDead indicates an expected call of RequeueDead
+func (mr *MockManagerMockRecorder) RequeueDead() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequeueDead", reflect.TypeOf((*MockManager)(nil).RequeueDead))
+}
+
 // SyncExec mocks base method
 func (m *MockManager) SyncExec(arg0 persistedretry.Task) error {
 	m.ctrl.T.Helper()
@@ -76,6 +76,21 @@ func (mr *MockStoreMockRecorder) Find(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockStore)(nil).Find), arg0)
 }
 
+// GetDead mocks base method
+func (m *MockStore) GetDead() ([]persistedretry.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDead")
+	ret0, _ := ret[0].([]persistedretry.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDead indicates an expected call of GetDead
+func (mr *MockStoreMockRecorder) GetDead() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDead", reflect.TypeOf((*MockStore)(nil).GetDead))
+}
+
 // GetFailed mocks base method
 func (m *MockStore) GetFailed() ([]persistedretry.Task, error) {
 	m.ctrl.T.Helper()
@@ -106,18 +121,32 @@ func (mr *MockStoreMockRecorder) GetPending() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPending", reflect.TypeOf((*MockStore)(nil).GetPending))
 }
 
+// MarkDead mocks base method
+func (m *MockStore) MarkDead(arg0 persistedretry.Task) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkDead", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkDead indicates an expected call of MarkDead
+func (mr *MockStoreMockRecorder) MarkDead(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkDead", reflect.TypeOf((*MockStore)(nil).MarkDead), arg0)
+}
+
 // MarkFailed mocks base method
-func (m *MockStore) MarkFailed(arg0 persistedretry.Task) error {
+func (m *MockStore) MarkFailed(arg0 persistedretry.Task, arg1 error) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MarkFailed", arg0)
+	ret := m.ctrl.Call(m, "MarkFailed", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // MarkFailed indicates an expected call of MarkFailed
-func (mr *MockStoreMockRecorder) MarkFailed(arg0 interface{}) *gomock.Call {
+func (mr *MockStoreMockRecorder) MarkFailed(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkFailed", reflect.TypeOf((*MockStore)(nil).MarkFailed), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkFailed", reflect.TypeOf((*MockStore)(nil).MarkFailed), arg0, arg1)
 }
 
 // MarkPending mocks base method
@@ -147,3 +176,17 @@ func (mr *MockStoreMockRecorder) Remove(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockStore)(nil).Remove), arg0)
 }
+
+// Requeue mocks base method
+func (m *MockStore) Requeue(arg0 persistedretry.Task) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Requeue", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Requeue indicates an expected call of Requeue
+func (mr *MockStoreMockRecorder) Requeue(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Requeue", reflect.TypeOf((*MockStore)(nil).Requeue), arg0)
+}
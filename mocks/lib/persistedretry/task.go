@@ -61,6 +61,20 @@ func (mr *MockTaskMockRecorder) GetLastAttempt() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastAttempt", reflect.TypeOf((*MockTask)(nil).GetLastAttempt))
 }
 
+// GetLastError mocks base method
+func (m *MockTask) GetLastError() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastError")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetLastError indicates an expected call of GetLastError
+func (mr *MockTaskMockRecorder) GetLastError() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastError", reflect.TypeOf((*MockTask)(nil).GetLastError))
+}
+
 // Ready mocks base method
 func (m *MockTask) Ready() bool {
 	m.ctrl.T.Helper()
@@ -9,7 +9,9 @@ import (
 	core "github.com/uber/kraken/core"
 	scheduler "github.com/uber/kraken/lib/torrent/scheduler"
 	connstate "github.com/uber/kraken/lib/torrent/scheduler/connstate"
+	reputation "github.com/uber/kraken/lib/torrent/scheduler/reputation"
 	reflect "reflect"
+	time "time"
 )
 
 // MockReloadableScheduler is a mock of ReloadableScheduler interface
@@ -35,6 +37,36 @@ func (m *MockReloadableScheduler) EXPECT() *MockReloadableSchedulerMockRecorder
 	return m.recorder
 }
 
+// BandwidthLimits mocks base method
+func (m *MockReloadableScheduler) BandwidthLimits() (uint64, uint64) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BandwidthLimits")
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(uint64)
+	return ret0, ret1
+}
+
+// BandwidthLimits indicates an expected call of BandwidthLimits
+func (mr *MockReloadableSchedulerMockRecorder) BandwidthLimits() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BandwidthLimits", reflect.TypeOf((*MockReloadableScheduler)(nil).BandwidthLimits))
+}
+
+// BannedPeers mocks base method
+func (m *MockReloadableScheduler) BannedPeers() ([]reputation.BannedPeer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BannedPeers")
+	ret0, _ := ret[0].([]reputation.BannedPeer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BannedPeers indicates an expected call of BannedPeers
+func (mr *MockReloadableSchedulerMockRecorder) BannedPeers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BannedPeers", reflect.TypeOf((*MockReloadableScheduler)(nil).BannedPeers))
+}
+
 // BlacklistSnapshot mocks base method
 func (m *MockReloadableScheduler) BlacklistSnapshot() ([]connstate.BlacklistedConn, error) {
 	m.ctrl.T.Helper()
@@ -50,6 +82,20 @@ func (mr *MockReloadableSchedulerMockRecorder) BlacklistSnapshot() *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlacklistSnapshot", reflect.TypeOf((*MockReloadableScheduler)(nil).BlacklistSnapshot))
 }
 
+// CheckReadiness mocks base method
+func (m *MockReloadableScheduler) CheckReadiness() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckReadiness")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckReadiness indicates an expected call of CheckReadiness
+func (mr *MockReloadableSchedulerMockRecorder) CheckReadiness() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckReadiness", reflect.TypeOf((*MockReloadableScheduler)(nil).CheckReadiness))
+}
+
 // Download mocks base method
 func (m *MockReloadableScheduler) Download(arg0 string, arg1 core.Digest) error {
 	m.ctrl.T.Helper()
@@ -64,6 +110,35 @@ func (mr *MockReloadableSchedulerMockRecorder) Download(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockReloadableScheduler)(nil).Download), arg0, arg1)
 }
 
+// InMaintenance mocks base method
+func (m *MockReloadableScheduler) InMaintenance() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InMaintenance")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// InMaintenance indicates an expected call of InMaintenance
+func (mr *MockReloadableSchedulerMockRecorder) InMaintenance() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InMaintenance", reflect.TypeOf((*MockReloadableScheduler)(nil).InMaintenance))
+}
+
+// NumActiveTorrents mocks base method
+func (m *MockReloadableScheduler) NumActiveTorrents() (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NumActiveTorrents")
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NumActiveTorrents indicates an expected call of NumActiveTorrents
+func (mr *MockReloadableSchedulerMockRecorder) NumActiveTorrents() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NumActiveTorrents", reflect.TypeOf((*MockReloadableScheduler)(nil).NumActiveTorrents))
+}
+
 // Probe mocks base method
 func (m *MockReloadableScheduler) Probe() error {
 	m.ctrl.T.Helper()
@@ -104,6 +179,72 @@ func (mr *MockReloadableSchedulerMockRecorder) RemoveTorrent(arg0 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveTorrent", reflect.TypeOf((*MockReloadableScheduler)(nil).RemoveTorrent), arg0)
 }
 
+// Seed mocks base method
+func (m *MockReloadableScheduler) Seed(arg0 core.Digest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Seed", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Seed indicates an expected call of Seed
+func (mr *MockReloadableSchedulerMockRecorder) Seed(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Seed", reflect.TypeOf((*MockReloadableScheduler)(nil).Seed), arg0)
+}
+
+// SetAnnounceInterval mocks base method
+func (m *MockReloadableScheduler) SetAnnounceInterval(arg0 time.Duration) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetAnnounceInterval", arg0)
+}
+
+// SetAnnounceInterval indicates an expected call of SetAnnounceInterval
+func (mr *MockReloadableSchedulerMockRecorder) SetAnnounceInterval(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAnnounceInterval", reflect.TypeOf((*MockReloadableScheduler)(nil).SetAnnounceInterval), arg0)
+}
+
+// SetBandwidthLimits mocks base method
+func (m *MockReloadableScheduler) SetBandwidthLimits(arg0 uint64, arg1 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBandwidthLimits", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBandwidthLimits indicates an expected call of SetBandwidthLimits
+func (mr *MockReloadableSchedulerMockRecorder) SetBandwidthLimits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBandwidthLimits", reflect.TypeOf((*MockReloadableScheduler)(nil).SetBandwidthLimits), arg0, arg1)
+}
+
+// SetMaintenance mocks base method
+func (m *MockReloadableScheduler) SetMaintenance(arg0 bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetMaintenance", arg0)
+}
+
+// SetMaintenance indicates an expected call of SetMaintenance
+func (mr *MockReloadableSchedulerMockRecorder) SetMaintenance(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMaintenance", reflect.TypeOf((*MockReloadableScheduler)(nil).SetMaintenance), arg0)
+}
+
+// SetPieceRequestPolicy mocks base method
+func (m *MockReloadableScheduler) SetPieceRequestPolicy(arg0 core.Digest, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPieceRequestPolicy", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPieceRequestPolicy indicates an expected call of SetPieceRequestPolicy
+func (mr *MockReloadableSchedulerMockRecorder) SetPieceRequestPolicy(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPieceRequestPolicy", reflect.TypeOf((*MockReloadableScheduler)(nil).SetPieceRequestPolicy), arg0, arg1)
+}
+
 // Stop mocks base method
 func (m *MockReloadableScheduler) Stop() {
 	m.ctrl.T.Helper()
@@ -115,3 +256,33 @@ func (mr *MockReloadableSchedulerMockRecorder) Stop() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockReloadableScheduler)(nil).Stop))
 }
+
+// TorrentStats mocks base method
+func (m *MockReloadableScheduler) TorrentStats() ([]scheduler.TorrentStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TorrentStats")
+	ret0, _ := ret[0].([]scheduler.TorrentStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TorrentStats indicates an expected call of TorrentStats
+func (mr *MockReloadableSchedulerMockRecorder) TorrentStats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TorrentStats", reflect.TypeOf((*MockReloadableScheduler)(nil).TorrentStats))
+}
+
+// TorrentStatsByInfoHash mocks base method
+func (m *MockReloadableScheduler) TorrentStatsByInfoHash(arg0 core.InfoHash) (scheduler.TorrentStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TorrentStatsByInfoHash", arg0)
+	ret0, _ := ret[0].(scheduler.TorrentStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TorrentStatsByInfoHash indicates an expected call of TorrentStatsByInfoHash
+func (mr *MockReloadableSchedulerMockRecorder) TorrentStatsByInfoHash(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TorrentStatsByInfoHash", reflect.TypeOf((*MockReloadableScheduler)(nil).TorrentStatsByInfoHash), arg0)
+}
@@ -7,8 +7,11 @@ package mockscheduler
 import (
 	gomock "github.com/golang/mock/gomock"
 	core "github.com/uber/kraken/core"
+	scheduler "github.com/uber/kraken/lib/torrent/scheduler"
 	connstate "github.com/uber/kraken/lib/torrent/scheduler/connstate"
+	reputation "github.com/uber/kraken/lib/torrent/scheduler/reputation"
 	reflect "reflect"
+	time "time"
 )
 
 // MockScheduler is a mock of Scheduler interface
@@ -34,6 +37,36 @@ func (m *MockScheduler) EXPECT() *MockSchedulerMockRecorder {
 	return m.recorder
 }
 
+// BandwidthLimits mocks base method
+func (m *MockScheduler) BandwidthLimits() (uint64, uint64) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BandwidthLimits")
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(uint64)
+	return ret0, ret1
+}
+
+// BandwidthLimits indicates an expected call of BandwidthLimits
+func (mr *MockSchedulerMockRecorder) BandwidthLimits() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BandwidthLimits", reflect.TypeOf((*MockScheduler)(nil).BandwidthLimits))
+}
+
+// BannedPeers mocks base method
+func (m *MockScheduler) BannedPeers() ([]reputation.BannedPeer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BannedPeers")
+	ret0, _ := ret[0].([]reputation.BannedPeer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BannedPeers indicates an expected call of BannedPeers
+func (mr *MockSchedulerMockRecorder) BannedPeers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BannedPeers", reflect.TypeOf((*MockScheduler)(nil).BannedPeers))
+}
+
 // BlacklistSnapshot mocks base method
 func (m *MockScheduler) BlacklistSnapshot() ([]connstate.BlacklistedConn, error) {
 	m.ctrl.T.Helper()
@@ -49,6 +82,20 @@ func (mr *MockSchedulerMockRecorder) BlacklistSnapshot() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlacklistSnapshot", reflect.TypeOf((*MockScheduler)(nil).BlacklistSnapshot))
 }
 
+// CheckReadiness mocks base method
+func (m *MockScheduler) CheckReadiness() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckReadiness")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckReadiness indicates an expected call of CheckReadiness
+func (mr *MockSchedulerMockRecorder) CheckReadiness() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckReadiness", reflect.TypeOf((*MockScheduler)(nil).CheckReadiness))
+}
+
 // Download mocks base method
 func (m *MockScheduler) Download(arg0 string, arg1 core.Digest) error {
 	m.ctrl.T.Helper()
@@ -63,6 +110,35 @@ func (mr *MockSchedulerMockRecorder) Download(arg0, arg1 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockScheduler)(nil).Download), arg0, arg1)
 }
 
+// InMaintenance mocks base method
+func (m *MockScheduler) InMaintenance() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InMaintenance")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// InMaintenance indicates an expected call of InMaintenance
+func (mr *MockSchedulerMockRecorder) InMaintenance() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InMaintenance", reflect.TypeOf((*MockScheduler)(nil).InMaintenance))
+}
+
+// NumActiveTorrents mocks base method
+func (m *MockScheduler) NumActiveTorrents() (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NumActiveTorrents")
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NumActiveTorrents indicates an expected call of NumActiveTorrents
+func (mr *MockSchedulerMockRecorder) NumActiveTorrents() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NumActiveTorrents", reflect.TypeOf((*MockScheduler)(nil).NumActiveTorrents))
+}
+
 // Probe mocks base method
 func (m *MockScheduler) Probe() error {
 	m.ctrl.T.Helper()
@@ -91,6 +167,72 @@ func (mr *MockSchedulerMockRecorder) RemoveTorrent(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveTorrent", reflect.TypeOf((*MockScheduler)(nil).RemoveTorrent), arg0)
 }
 
+// Seed mocks base method
+func (m *MockScheduler) Seed(arg0 core.Digest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Seed", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Seed indicates an expected call of Seed
+func (mr *MockSchedulerMockRecorder) Seed(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Seed", reflect.TypeOf((*MockScheduler)(nil).Seed), arg0)
+}
+
+// SetAnnounceInterval mocks base method
+func (m *MockScheduler) SetAnnounceInterval(arg0 time.Duration) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetAnnounceInterval", arg0)
+}
+
+// SetAnnounceInterval indicates an expected call of SetAnnounceInterval
+func (mr *MockSchedulerMockRecorder) SetAnnounceInterval(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAnnounceInterval", reflect.TypeOf((*MockScheduler)(nil).SetAnnounceInterval), arg0)
+}
+
+// SetBandwidthLimits mocks base method
+func (m *MockScheduler) SetBandwidthLimits(arg0 uint64, arg1 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBandwidthLimits", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBandwidthLimits indicates an expected call of SetBandwidthLimits
+func (mr *MockSchedulerMockRecorder) SetBandwidthLimits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBandwidthLimits", reflect.TypeOf((*MockScheduler)(nil).SetBandwidthLimits), arg0, arg1)
+}
+
+// SetMaintenance mocks base method
+func (m *MockScheduler) SetMaintenance(arg0 bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetMaintenance", arg0)
+}
+
+// SetMaintenance indicates an expected call of SetMaintenance
+func (mr *MockSchedulerMockRecorder) SetMaintenance(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMaintenance", reflect.TypeOf((*MockScheduler)(nil).SetMaintenance), arg0)
+}
+
+// SetPieceRequestPolicy mocks base method
+func (m *MockScheduler) SetPieceRequestPolicy(arg0 core.Digest, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPieceRequestPolicy", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPieceRequestPolicy indicates an expected call of SetPieceRequestPolicy
+func (mr *MockSchedulerMockRecorder) SetPieceRequestPolicy(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPieceRequestPolicy", reflect.TypeOf((*MockScheduler)(nil).SetPieceRequestPolicy), arg0, arg1)
+}
+
 // Stop mocks base method
 func (m *MockScheduler) Stop() {
 	m.ctrl.T.Helper()
@@ -102,3 +244,33 @@ func (mr *MockSchedulerMockRecorder) Stop() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockScheduler)(nil).Stop))
 }
+
+// TorrentStats mocks base method
+func (m *MockScheduler) TorrentStats() ([]scheduler.TorrentStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TorrentStats")
+	ret0, _ := ret[0].([]scheduler.TorrentStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TorrentStats indicates an expected call of TorrentStats
+func (mr *MockSchedulerMockRecorder) TorrentStats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TorrentStats", reflect.TypeOf((*MockScheduler)(nil).TorrentStats))
+}
+
+// TorrentStatsByInfoHash mocks base method
+func (m *MockScheduler) TorrentStatsByInfoHash(arg0 core.InfoHash) (scheduler.TorrentStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TorrentStatsByInfoHash", arg0)
+	ret0, _ := ret[0].(scheduler.TorrentStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TorrentStatsByInfoHash indicates an expected call of TorrentStatsByInfoHash
+func (mr *MockSchedulerMockRecorder) TorrentStatsByInfoHash(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TorrentStatsByInfoHash", reflect.TypeOf((*MockScheduler)(nil).TorrentStatsByInfoHash), arg0)
+}
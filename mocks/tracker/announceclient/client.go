@@ -49,3 +49,17 @@ func (mr *MockClientMockRecorder) Announce(arg0, arg1, arg2, arg3 interface{}) *
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Announce", reflect.TypeOf((*MockClient)(nil).Announce), arg0, arg1, arg2, arg3)
 }
+
+// CheckReadiness mocks base method
+func (m *MockClient) CheckReadiness() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckReadiness")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckReadiness indicates an expected call of CheckReadiness
+func (mr *MockClientMockRecorder) CheckReadiness() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckReadiness", reflect.TypeOf((*MockClient)(nil).CheckReadiness))
+}
@@ -48,6 +48,21 @@ func (mr *MockStoreMockRecorder) GetPeers(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPeers", reflect.TypeOf((*MockStore)(nil).GetPeers), arg0, arg1)
 }
 
+// GetPeersByIP mocks base method
+func (m *MockStore) GetPeersByIP(arg0 core.InfoHash, arg1 []string) ([]*core.PeerInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPeersByIP", arg0, arg1)
+	ret0, _ := ret[0].([]*core.PeerInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPeersByIP indicates an expected call of GetPeersByIP
+func (mr *MockStoreMockRecorder) GetPeersByIP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPeersByIP", reflect.TypeOf((*MockStore)(nil).GetPeersByIP), arg0, arg1)
+}
+
 // UpdatePeer mocks base method
 func (m *MockStore) UpdatePeer(arg0 core.InfoHash, arg1 *core.PeerInfo) error {
 	m.ctrl.T.Helper()
@@ -33,8 +33,16 @@ server {
   access_log {{.log_dir}}/nginx-access.v2.log;
   error_log {{.log_dir}}/nginx-error.v2.log;
 
-  gzip on;
-  gzip_types text/plain test/csv application/json;
+  gzip {{.gzip}};
+  gzip_types {{.gzip_types}};
+
+  location = /v2/_catalog {
+    proxy_pass {{.agent_server_scheme}}://{{.agent_server}};
+  }
+
+  location ~ ^/v2/.+/tags/list$ {
+    proxy_pass {{.agent_server_scheme}}://{{.agent_server}};
+  }
 
   location / {
     proxy_pass http://registry-backend;
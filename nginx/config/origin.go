@@ -25,8 +25,8 @@ server {
   access_log {{.log_dir}}/nginx-access.log;
   error_log {{.log_dir}}/nginx-error.log;
 
-  gzip on;
-  gzip_types text/plain test/csv application/json;
+  gzip {{.gzip}};
+  gzip_types {{.gzip_types}};
 
   location / {
     proxy_pass http://{{.server}};
@@ -34,8 +34,8 @@ server {
   access_log {{$.log_dir}}/nginx-access.log json;
   error_log {{$.log_dir}}/nginx-error.log;
 
-  gzip on;
-  gzip_types text/plain test/csv application/json;
+  gzip {{$.gzip}};
+  gzip_types {{$.gzip_types}};
 
   # Committing large blobs might take a while.
   proxy_read_timeout 3m;
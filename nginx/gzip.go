@@ -0,0 +1,64 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package nginx
+
+import "strings"
+
+// _defaultGzipTypes are the MIME types compressed by default: JSON, such as
+// tag and metainfo responses, and docker / OCI manifests. text/html is always
+// compressed by nginx and must not be listed.
+var _defaultGzipTypes = []string{
+	"text/plain",
+	"text/csv",
+	"application/json",
+	"application/vnd.docker.distribution.manifest.v1+prettyjws",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// GzipConfig defines compression of responses served by nginx. Blob content
+// is never compressed, since docker layers are already gzip compressed and
+// compressing them again only wastes CPU.
+type GzipConfig struct {
+	// Disabled disables compression of all responses.
+	Disabled bool `yaml:"disabled"`
+
+	// Types are MIME types compressed in addition to the defaults. Blob
+	// content types are ignored.
+	Types []string `yaml:"types"`
+}
+
+// isBlobType returns true if responses of MIME type t contain blob content.
+func isBlobType(t string) bool {
+	return t == "*" ||
+		t == "application/octet-stream" ||
+		strings.Contains(t, "gzip") ||
+		strings.Contains(t, "tar")
+}
+
+// params returns the "gzip" and "gzip_types" template params.
+func (c GzipConfig) params() (string, string) {
+	if c.Disabled {
+		return "off", strings.Join(_defaultGzipTypes, " ")
+	}
+	types := append([]string{}, _defaultGzipTypes...)
+	for _, t := range c.Types {
+		if !isBlobType(t) {
+			types = append(types, t)
+		}
+	}
+	return "on", strings.Join(types, " ")
+}
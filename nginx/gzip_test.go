@@ -0,0 +1,66 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package nginx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/uber/kraken/nginx/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzipConfigParams(t *testing.T) {
+	require := require.New(t)
+
+	gzip, types := GzipConfig{}.params()
+	require.Equal("on", gzip)
+	require.Contains(types, "application/vnd.docker.distribution.manifest.v2+json")
+	require.NotContains(types, "application/octet-stream")
+
+	gzip, _ = GzipConfig{Disabled: true}.params()
+	require.Equal("off", gzip)
+}
+
+func TestGzipConfigParamsIgnoresBlobTypes(t *testing.T) {
+	require := require.New(t)
+
+	_, types := GzipConfig{Types: []string{
+		"application/xml",
+		"application/octet-stream",
+		"application/vnd.docker.image.rootfs.diff.tar.gzip",
+		"*",
+	}}.params()
+
+	fields := strings.Fields(types)
+	require.Contains(fields, "application/xml")
+	require.NotContains(fields, "application/octet-stream")
+	require.NotContains(fields, "application/vnd.docker.image.rootfs.diff.tar.gzip")
+	require.NotContains(fields, "*")
+}
+
+func TestProxyTemplateGzipPerPort(t *testing.T) {
+	require := require.New(t)
+
+	gzip, types := GzipConfig{}.params()
+	b, err := populateTemplate(config.ProxyTemplate, map[string]interface{}{
+		"ports":      []int{5000, 5001},
+		"gzip":       gzip,
+		"gzip_types": types,
+	})
+	require.NoError(err)
+	require.Equal(2, strings.Count(string(b), "gzip on;"))
+	require.Equal(2, strings.Count(string(b), "gzip_types "+types+";"))
+}
@@ -48,17 +48,22 @@ type Config struct {
 	CacheDir string `yaml:"cache_dir"`
 	LogDir   string `yaml:"log_dir"`
 
+	// Gzip configures compression of responses. Enabled by default for JSON
+	// and manifests only.
+	Gzip GzipConfig `yaml:"gzip"`
+
 	tls httputil.TLSConfig
 }
 
 func (c *Config) inject(params map[string]interface{}) error {
-	for _, s := range []string{"cache_dir", "log_dir"} {
+	for _, s := range []string{"cache_dir", "log_dir", "gzip", "gzip_types"} {
 		if _, ok := params[s]; ok {
 			return fmt.Errorf("invalid params: %s is reserved", s)
 		}
 	}
 	params["cache_dir"] = c.CacheDir
 	params["log_dir"] = c.LogDir
+	params["gzip"], params["gzip_types"] = c.Gzip.params()
 	return nil
 }
 
@@ -163,6 +163,9 @@ func (s *Server) Handler() http.Handler {
 		"/internal/duplicate/namespace/{namespace}/blobs/{digest}/uploads/{uid}",
 		handler.Wrap(s.duplicateCommitClusterUploadHandler))
 
+	r.Get("/x/writeback", persistedretry.DebugHandler(s.writeBackManager))
+	r.Post("/x/writeback/requeue_dead", persistedretry.RequeueDeadHandler(s.writeBackManager))
+
 	r.Mount("/", http.DefaultServeMux) // Serves /debug/pprof endpoints.
 
 	return r
@@ -717,7 +720,7 @@ func (s *Server) forceCleanupHandler(w http.ResponseWriter, r *http.Request) err
 }
 
 func (s *Server) maybeDelete(name string, ttl time.Duration) (deleted bool, err error) {
-	d, err := core.NewSHA256DigestFromHex(name)
+	d, err := core.NewDigestFromHex(name)
 	if err != nil {
 		return false, fmt.Errorf("parse digest: %s", err)
 	}
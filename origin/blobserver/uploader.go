@@ -72,7 +72,7 @@ func (u *uploader) patch(
 }
 
 func (u *uploader) verify(d core.Digest, uid string) error {
-	digester := core.NewDigester()
+	digester := d.Digester()
 	f, err := u.cas.GetUploadFileReader(uid)
 	if err != nil {
 		if os.IsNotExist(err) {
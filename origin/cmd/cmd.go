@@ -17,8 +17,10 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/backend"
@@ -137,7 +139,7 @@ func Run(flags *Flags) {
 	}
 
 	pctx, err := core.NewPeerContext(
-		config.PeerIDFactory, flags.Zone, flags.KrakenCluster, flags.PeerIP, flags.PeerPort, true)
+		config.PeerIDFactory, flags.Zone, flags.KrakenCluster, flags.PeerIP, "", flags.PeerPort, true)
 	if err != nil {
 		log.Fatalf("Failed to create peer context: %s", err)
 	}
@@ -206,7 +208,7 @@ func Run(flags *Flags) {
 		if err != nil {
 			log.Fatalf("Error getting local ip: %s", err)
 		}
-		addr = fmt.Sprintf("%s:%d", ip, flags.BlobServerPort)
+		addr = net.JoinHostPort(ip, strconv.Itoa(flags.BlobServerPort))
 		if !hashRing.Contains(addr) {
 			log.Fatalf(
 				"Neither %s nor %s (port %d) found in hash ring",
@@ -70,7 +70,7 @@ func (ph *PreheatHandler) process(repo, digest string) error {
 		return err
 	}
 	for _, desc := range manifest.References() {
-		d, err := core.ParseSHA256Digest(string(desc.Digest))
+		d, err := core.ParseDigest(string(desc.Digest))
 		if err != nil {
 			log.With("repo", repo, "digest", string(desc.Digest)).Errorf("parse digest: %s", err)
 			continue
@@ -87,7 +87,7 @@ func (ph *PreheatHandler) process(repo, digest string) error {
 }
 
 func (ph *PreheatHandler) fetchManifest(repo, digest string) (distribution.Manifest, error) {
-	d, err := core.ParseSHA256Digest(digest)
+	d, err := core.ParseDigest(digest)
 	if err != nil {
 		return nil, fmt.Errorf("Error parse digest: %s ", err)
 	}
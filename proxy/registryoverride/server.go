@@ -55,8 +55,11 @@ type catalogResponse struct {
 }
 
 func (s *Server) catalogHandler(w http.ResponseWriter, r *http.Request) error {
-	tags, err := s.tagClient.List("")
+	tags, err := s.tagClient.List(r.Context(), "")
 	if err != nil {
+		if tagclient.IsServerUnavailable(err) {
+			return handler.Errorf("list: %s", err).Status(http.StatusServiceUnavailable)
+		}
 		return handler.Errorf("list: %s", err)
 	}
 	repos := stringset.New()
@@ -15,11 +15,17 @@ package announceclient
 
 import (
 	"bytes"
+	"compress/gzip"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/uber/kraken/core"
@@ -36,6 +42,10 @@ type Request struct {
 	Digest   *core.Digest   `json:"digest"` // Optional (for now).
 	InfoHash core.InfoHash  `json:"info_hash"`
 	Peer     *core.PeerInfo `json:"peer"`
+
+	// Neighbors are addresses of peers, i.e. IPs optionally with a port, which
+	// the tracker includes in the handout if they announced the torrent.
+	Neighbors []string `json:"neighbors,omitempty"`
 }
 
 // GetDigest is a backwards compatible accessor of the request digest.
@@ -43,7 +53,7 @@ func (r *Request) GetDigest() (core.Digest, error) {
 	if r.Digest != nil {
 		return *r.Digest, nil
 	}
-	d, err := core.NewSHA256DigestFromHex(r.Name)
+	d, err := core.NewDigestFromHex(r.Name)
 	if err != nil {
 		return core.Digest{}, err
 	}
@@ -63,17 +73,131 @@ type Client interface {
 		h core.InfoHash,
 		complete bool,
 		version int) ([]*core.PeerInfo, time.Duration, error)
+	CheckReadiness() error
 }
 
 type client struct {
-	pctx core.PeerContext
-	ring hashring.PassiveRing
-	tls  *tls.Config
+	pctx      core.PeerContext
+	ring      hashring.PassiveRing
+	tls       *tls.Config
+	transport *http.Transport
+
+	compression        bool
+	neighbors          []string
+	healthCheckTimeout time.Duration
+
+	// Addresses of trackers which advertised gzip support, mapped to whether
+	// they actually accept gzip request bodies.
+	gzipTrackers sync.Map
 }
 
 // New creates a new client.
-func New(pctx core.PeerContext, ring hashring.PassiveRing, tls *tls.Config) Client {
-	return &client{pctx, ring, tls}
+func New(
+	config Config, pctx core.PeerContext, ring hashring.PassiveRing, tls *tls.Config) Client {
+
+	config = config.applyDefaults()
+
+	// A single transport is shared across all requests so keep-alive
+	// connections to trackers are reused between announces.
+	transport := &http.Transport{
+		Proxy: httputil.Proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:     tls,
+		MaxIdleConnsPerHost: config.MaxIdleConnsPerHost,
+		IdleConnTimeout:     config.IdleConnTimeout,
+	}
+	return &client{
+		pctx:               pctx,
+		ring:               ring,
+		tls:                tls,
+		transport:          transport,
+		compression:        config.Compression,
+		neighbors:          config.Neighbors,
+		healthCheckTimeout: config.HealthCheckTimeout,
+	}
+}
+
+// sendTransport returns the option for sending requests over c's shared
+// transport.
+func (c *client) sendTransport() httputil.SendOption {
+	if c.tls != nil {
+		return httputil.SendTLSTransport(c.transport)
+	}
+	return httputil.SendTransport(c.transport)
+}
+
+// closeBody drains and closes body, which is required for the underlying
+// connection to be reused.
+func closeBody(body io.ReadCloser) {
+	io.Copy(ioutil.Discard, body)
+	body.Close()
+}
+
+// acceptsGzip returns whether header, from a tracker response, advertises
+// support for gzip request bodies.
+func acceptsGzip(header http.Header) bool {
+	for _, v := range header["Accept-Encoding"] {
+		for _, enc := range strings.Split(v, ",") {
+			if strings.TrimSpace(enc) == "gzip" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// send sends an announce request with body to the tracker at addr, gzipping
+// body if the tracker is known to accept it. If the tracker rejects a gzipped
+// body with 415, e.g. because it was downgraded or a proxy in front of it does
+// not decode gzip, the request is resent uncompressed and gzip is no longer
+// used for the tracker. Other errors are returned as is, since the tracker may
+// already have processed the announce.
+func (c *client) send(method, url, addr string, body []byte) (*http.Response, error) {
+	if c.compression {
+		if v, ok := c.gzipTrackers.Load(addr); ok && v.(bool) {
+			gz, err := gzipBody(body)
+			if err != nil {
+				return nil, fmt.Errorf("gzip request: %s", err)
+			}
+			resp, err := httputil.Send(
+				method,
+				url,
+				httputil.SendBody(bytes.NewReader(gz)),
+				httputil.SendHeaders(map[string]string{"Content-Encoding": "gzip"}),
+				httputil.SendTimeout(10*time.Second),
+				c.sendTransport())
+			if !httputil.IsStatus(err, http.StatusUnsupportedMediaType) {
+				return resp, err
+			}
+			c.gzipTrackers.Store(addr, false)
+		}
+	}
+	resp, err := httputil.Send(
+		method,
+		url,
+		httputil.SendBody(bytes.NewReader(body)),
+		httputil.SendTimeout(10*time.Second),
+		c.sendTransport())
+	if err == nil && c.compression && acceptsGzip(resp.Header) {
+		// Trackers which rejected gzip bodies stay uncompressed.
+		c.gzipTrackers.LoadOrStore(addr, true)
+	}
+	return resp, err
 }
 
 // Announce versionss.
@@ -99,10 +223,11 @@ func (c *client) Announce(
 	version int) (peers []*core.PeerInfo, interval time.Duration, err error) {
 
 	body, err := json.Marshal(&Request{
-		Name:     d.Hex(), // For backwards compatability. TODO(codyg): Remove.
-		Digest:   &d,
-		InfoHash: h,
-		Peer:     core.PeerInfoFromContext(c.pctx, complete),
+		Name:      d.Hex(), // For backwards compatability. TODO(codyg): Remove.
+		Digest:    &d,
+		InfoHash:  h,
+		Peer:      core.PeerInfoFromContext(c.pctx, complete),
+		Neighbors: c.neighbors,
 	})
 	if err != nil {
 		return nil, 0, fmt.Errorf("marshal request: %s", err)
@@ -110,12 +235,7 @@ func (c *client) Announce(
 	var httpResp *http.Response
 	for _, addr := range c.ring.Locations(d) {
 		method, url := getEndpoint(version, addr, h)
-		httpResp, err = httputil.Send(
-			method,
-			url,
-			httputil.SendBody(bytes.NewReader(body)),
-			httputil.SendTimeout(10*time.Second),
-			httputil.SendTLS(c.tls))
+		httpResp, err = c.send(method, url, addr, body)
 		if err != nil {
 			if httputil.IsNetworkError(err) {
 				c.ring.Failed(addr)
@@ -123,7 +243,7 @@ func (c *client) Announce(
 			}
 			return nil, 0, err
 		}
-		defer httpResp.Body.Close()
+		defer closeBody(httpResp.Body)
 		var resp Response
 		if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
 			return nil, 0, fmt.Errorf("decode response: %s", err)
@@ -133,6 +253,34 @@ func (c *client) Announce(
 	return nil, 0, err
 }
 
+// readinessCheckDigest is an arbitrary fixed digest used to pick which trackers
+// to health check, so readiness checks are spread the same way as announces.
+var readinessCheckDigest = core.NewDigester().Digest()
+
+// CheckReadiness returns nil if at least one tracker responds to health checks.
+func (c *client) CheckReadiness() error {
+	var err error
+	for _, addr := range c.ring.Locations(readinessCheckDigest) {
+		var resp *http.Response
+		resp, err = httputil.Get(
+			fmt.Sprintf("http://%s/health", addr),
+			httputil.SendTimeout(c.healthCheckTimeout),
+			c.sendTransport())
+		if err != nil {
+			if httputil.IsNetworkError(err) {
+				c.ring.Failed(addr)
+			}
+			continue
+		}
+		closeBody(resp.Body)
+		return nil
+	}
+	if err == nil {
+		err = errors.New("no trackers available")
+	}
+	return fmt.Errorf("tracker health check: %s", err)
+}
+
 // DisabledClient rejects all announces. Suitable for origin peers which should
 // not be announcing.
 type DisabledClient struct{}
@@ -148,3 +296,9 @@ func (c DisabledClient) Announce(
 
 	return nil, 0, ErrDisabled
 }
+
+// CheckReadiness always returns nil, since disabled clients do not depend on
+// any trackers.
+func (c DisabledClient) CheckReadiness() error {
+	return nil
+}
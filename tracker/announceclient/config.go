@@ -0,0 +1,57 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package announceclient
+
+import "time"
+
+// Config defines the configuration of the announce client's HTTP transport.
+// Connections to trackers are kept alive and reused across announces.
+type Config struct {
+	// MaxIdleConnsPerHost is the max number of idle keep-alive connections
+	// kept open to each tracker.
+	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host"`
+
+	// IdleConnTimeout is the duration an idle keep-alive connection remains
+	// open before being closed.
+	IdleConnTimeout time.Duration `yaml:"idle_conn_timeout"`
+
+	// HealthCheckTimeout is the timeout of tracker health checks made by
+	// readiness checks.
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout"`
+
+	// Compression gzips announce request bodies sent to trackers which
+	// advertise support for gzip request bodies in the Accept-Encoding header
+	// of their responses. Trackers which do not, e.g. older versions, are sent
+	// uncompressed requests. The first announce to each tracker is always
+	// uncompressed, and trackers which reject a gzipped request with 415 are
+	// sent uncompressed requests from then on.
+	Compression bool `yaml:"compression"`
+
+	// Neighbors are addresses of peers which trackers are asked to include in
+	// every peer handout. Set from the neighbors of the scheduler.
+	Neighbors []string `yaml:"-"`
+}
+
+func (c Config) applyDefaults() Config {
+	if c.MaxIdleConnsPerHost == 0 {
+		c.MaxIdleConnsPerHost = 16
+	}
+	if c.IdleConnTimeout == 0 {
+		c.IdleConnTimeout = 90 * time.Second
+	}
+	if c.HealthCheckTimeout == 0 {
+		c.HealthCheckTimeout = 5 * time.Second
+	}
+	return c
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package announceclient
+
+import (
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/utils/log"
+)
+
+// multiClient announces to a primary tracker cluster, and opportunistically to
+// secondary tracker clusters, e.g. in other regions.
+type multiClient struct {
+	primary     Client
+	secondaries []Client
+}
+
+// NewMulti creates a Client which announces to primary and all secondaries.
+// Peers returned by primary are ordered before peers returned by secondaries,
+// and the announce interval is always set by primary. Announces never wait for
+// secondaries: only peers of secondaries which responded before primary are
+// returned, and slower secondaries finish in the background. Failed announces
+// to secondaries are ignored, whereas failed announces to primary fail the
+// announce.
+func NewMulti(primary Client, secondaries ...Client) Client {
+	if len(secondaries) == 0 {
+		return primary
+	}
+	return &multiClient{primary, secondaries}
+}
+
+// Announce announces to all tracker clusters concurrently, returning the union
+// of the peers of primary and of the secondaries which responded before it.
+func (c *multiClient) Announce(
+	d core.Digest,
+	h core.InfoHash,
+	complete bool,
+	version int) ([]*core.PeerInfo, time.Duration, error) {
+
+	// Buffered such that secondaries which respond after primary never block.
+	responses := make(chan secondaryResponse, len(c.secondaries))
+	for i, s := range c.secondaries {
+		go func(i int, s Client) {
+			peers, _, err := s.Announce(d, h, complete, version)
+			if err != nil {
+				log.With("hash", h).Warnf("Error announcing to secondary tracker cluster: %s", err)
+			}
+			responses <- secondaryResponse{i, peers}
+		}(i, s)
+	}
+	peers, interval, err := c.primary.Announce(d, h, complete, version)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results := make([][]*core.PeerInfo, len(c.secondaries))
+	for done := false; !done; {
+		select {
+		case r := <-responses:
+			results[r.i] = r.peers
+		default:
+			done = true
+		}
+	}
+
+	seen := make(map[core.PeerID]bool)
+	for _, p := range peers {
+		seen[p.PeerID] = true
+	}
+	for _, r := range results {
+		for _, p := range r {
+			if !seen[p.PeerID] {
+				seen[p.PeerID] = true
+				peers = append(peers, p)
+			}
+		}
+	}
+	return peers, interval, nil
+}
+
+// secondaryResponse holds the peers returned by the i-th secondary.
+type secondaryResponse struct {
+	i     int
+	peers []*core.PeerInfo
+}
+
+// CheckReadiness only checks the primary tracker cluster, since secondaries
+// are not required to download.
+func (c *multiClient) CheckReadiness() error {
+	return c.primary.CheckReadiness()
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package announceclient
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/kraken/core"
+	mockannounceclient "github.com/uber/kraken/mocks/tracker/announceclient"
+)
+
+// respondAfterSecondaries returns a primary response which is delayed until
+// all secondaries registered in wg have responded.
+func respondAfterSecondaries(
+	wg *sync.WaitGroup, peers []*core.PeerInfo, interval time.Duration) func(
+	core.Digest, core.InfoHash, bool, int) ([]*core.PeerInfo, time.Duration, error) {
+
+	return func(core.Digest, core.InfoHash, bool, int) ([]*core.PeerInfo, time.Duration, error) {
+		wg.Wait()
+		// Allows secondary responses to be received before primary returns.
+		time.Sleep(50 * time.Millisecond)
+		return peers, interval, nil
+	}
+}
+
+// respondSecondary returns a secondary response which marks wg done.
+func respondSecondary(
+	wg *sync.WaitGroup, peers []*core.PeerInfo, err error) func(
+	core.Digest, core.InfoHash, bool, int) ([]*core.PeerInfo, time.Duration, error) {
+
+	wg.Add(1)
+	return func(core.Digest, core.InfoHash, bool, int) ([]*core.PeerInfo, time.Duration, error) {
+		defer wg.Done()
+		return peers, time.Second, err
+	}
+}
+
+func TestMultiClientAnnounceMergesPeers(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	primary := mockannounceclient.NewMockClient(ctrl)
+	secondary1 := mockannounceclient.NewMockClient(ctrl)
+	secondary2 := mockannounceclient.NewMockClient(ctrl)
+
+	c := NewMulti(primary, secondary1, secondary2)
+
+	d := core.DigestFixture()
+	h := core.InfoHashFixture()
+
+	p1 := core.PeerInfoFixture()
+	p2 := core.PeerInfoFixture()
+	p3 := core.PeerInfoFixture()
+
+	var wg sync.WaitGroup
+	secondary1.EXPECT().Announce(d, h, false, V2).DoAndReturn(
+		respondSecondary(&wg, []*core.PeerInfo{p2, p1}, nil))
+	secondary2.EXPECT().Announce(d, h, false, V2).DoAndReturn(
+		respondSecondary(&wg, []*core.PeerInfo{p3}, nil))
+	primary.EXPECT().Announce(d, h, false, V2).DoAndReturn(
+		respondAfterSecondaries(&wg, []*core.PeerInfo{p1}, 5*time.Second))
+
+	peers, interval, err := c.Announce(d, h, false, V2)
+	require.NoError(err)
+	require.Equal([]*core.PeerInfo{p1, p2, p3}, peers)
+	require.Equal(5*time.Second, interval)
+}
+
+func TestMultiClientAnnounceIgnoresSecondaryErrors(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	primary := mockannounceclient.NewMockClient(ctrl)
+	secondary := mockannounceclient.NewMockClient(ctrl)
+
+	c := NewMulti(primary, secondary)
+
+	d := core.DigestFixture()
+	h := core.InfoHashFixture()
+	p := core.PeerInfoFixture()
+
+	var wg sync.WaitGroup
+	secondary.EXPECT().Announce(d, h, true, V2).DoAndReturn(
+		respondSecondary(&wg, nil, errors.New("some error")))
+	primary.EXPECT().Announce(d, h, true, V2).DoAndReturn(
+		respondAfterSecondaries(&wg, []*core.PeerInfo{p}, time.Second))
+
+	peers, _, err := c.Announce(d, h, true, V2)
+	require.NoError(err)
+	require.Equal([]*core.PeerInfo{p}, peers)
+}
+
+func TestMultiClientAnnouncePrimaryError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	primary := mockannounceclient.NewMockClient(ctrl)
+	secondary := mockannounceclient.NewMockClient(ctrl)
+
+	c := NewMulti(primary, secondary)
+
+	d := core.DigestFixture()
+	h := core.InfoHashFixture()
+
+	var wg sync.WaitGroup
+	secondary.EXPECT().Announce(d, h, false, V2).DoAndReturn(
+		respondSecondary(&wg, []*core.PeerInfo{core.PeerInfoFixture()}, nil))
+	primary.EXPECT().Announce(d, h, false, V2).DoAndReturn(
+		func(core.Digest, core.InfoHash, bool, int) ([]*core.PeerInfo, time.Duration, error) {
+			wg.Wait()
+			return nil, 0, errors.New("some error")
+		})
+
+	_, _, err := c.Announce(d, h, false, V2)
+	require.Error(t, err)
+}
+
+func TestMultiClientAnnounceDoesNotWaitForSecondaries(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	primary := mockannounceclient.NewMockClient(ctrl)
+	secondary := mockannounceclient.NewMockClient(ctrl)
+
+	c := NewMulti(primary, secondary)
+
+	d := core.DigestFixture()
+	h := core.InfoHashFixture()
+	p := core.PeerInfoFixture()
+
+	unblock := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	secondary.EXPECT().Announce(d, h, false, V2).DoAndReturn(
+		func(core.Digest, core.InfoHash, bool, int) ([]*core.PeerInfo, time.Duration, error) {
+			defer wg.Done()
+			<-unblock
+			return []*core.PeerInfo{core.PeerInfoFixture()}, time.Second, nil
+		})
+	primary.EXPECT().Announce(d, h, false, V2).Return([]*core.PeerInfo{p}, time.Second, nil)
+
+	peers, _, err := c.Announce(d, h, false, V2)
+	require.NoError(err)
+	require.Equal([]*core.PeerInfo{p}, peers)
+
+	// The secondary finishes in the background.
+	close(unblock)
+	wg.Wait()
+}
+
+func TestMultiClientCheckReadinessOnlyChecksPrimary(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	primary := mockannounceclient.NewMockClient(ctrl)
+	secondary := mockannounceclient.NewMockClient(ctrl)
+
+	c := NewMulti(primary, secondary)
+
+	primary.EXPECT().CheckReadiness().Return(nil)
+
+	require.NoError(t, c.CheckReadiness())
+}
+
+func TestNewMultiWithoutSecondariesReturnsPrimary(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	primary := mockannounceclient.NewMockClient(ctrl)
+
+	require.Equal(t, primary, NewMulti(primary))
+}
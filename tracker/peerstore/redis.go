@@ -36,30 +36,65 @@ func serializePeer(p *core.PeerInfo) string {
 	if p.Complete {
 		completeBit = 1
 	}
-	return fmt.Sprintf("%s:%s:%d:%d", p.PeerID.String(), p.IP, p.Port, completeBit)
+	return fmt.Sprintf(
+		"%s:%s:%d:%d:%s", p.PeerID.String(), serializeIP(p.IP), p.Port, completeBit, p.Zone)
+}
+
+func serializeIP(ip string) string {
+	if strings.Contains(ip, ":") {
+		// Bracket IPv6 literals to keep the encoding colon separated.
+		return "[" + ip + "]"
+	}
+	return ip
+}
+
+// ipPattern returns a SSCAN pattern matching serialized peers with ip.
+func ipPattern(ip string) string {
+	r := strings.NewReplacer(`[`, `\[`, `]`, `\]`, `*`, `\*`, `?`, `\?`)
+	return "*:" + r.Replace(serializeIP(ip)) + ":*"
 }
 
 type peerIdentity struct {
 	peerID core.PeerID
 	ip     string
 	port   int
+	zone   string
 }
 
 func deserializePeer(s string) (id peerIdentity, complete bool, err error) {
+	// IPv6 literals are bracketed, see serializePeer. Cut them out before
+	// splitting on colons.
+	var ip6 string
+	if i := strings.Index(s, ":["); i >= 0 {
+		j := strings.Index(s, "]")
+		if j < i {
+			return id, false, errors.New("invalid peer encoding: unterminated ipv6 address")
+		}
+		ip6 = s[i+2 : j]
+		s = s[:i+1] + s[j+1:]
+	}
 	parts := strings.Split(s, ":")
-	if len(parts) != 4 {
-		return id, false, fmt.Errorf("invalid peer encoding: expected 'pid:ip:port:complete'")
+	// Peers written before zones were tracked omit the trailing zone.
+	if len(parts) != 4 && len(parts) != 5 {
+		return id, false, fmt.Errorf("invalid peer encoding: expected 'pid:ip:port:complete:zone'")
 	}
 	peerID, err := core.NewPeerID(parts[0])
 	if err != nil {
 		return id, false, fmt.Errorf("parse peer id: %s", err)
 	}
 	ip := parts[1]
+	if ip6 != "" {
+		ip = ip6
+	}
 	port, err := strconv.Atoi(parts[2])
 	if err != nil {
 		return id, false, fmt.Errorf("parse port: %s", err)
 	}
-	id = peerIdentity{peerID, ip, port}
+	var zone string
+	if len(parts) == 5 {
+		zone = parts[4]
+	}
+	id = peerIdentity{peerID, ip, port, zone}
 	complete = parts[3] == "1"
 	return id, complete, nil
 }
@@ -177,20 +212,60 @@ func (s *RedisStore) GetPeers(h core.InfoHash, n int) ([]*core.PeerInfo, error)
 		} else if err != nil {
 			return nil, err
 		}
-		for _, s := range result {
-			id, complete, err := deserializePeer(s)
-			if err != nil {
-				log.Errorf("Error deserializing peer %q: %s", s, err)
-				continue
+		addPeers(selected, result)
+	}
+	return selectedPeers(selected), nil
+}
+
+// GetPeersByIP returns all PeerInfos associated with h whose IP is in ips.
+// Every window is scanned for each ip, so ips should be few.
+func (s *RedisStore) GetPeersByIP(h core.InfoHash, ips []string) ([]*core.PeerInfo, error) {
+	c := s.pool.Get()
+	defer c.Close()
+
+	selected := make(map[peerIdentity]bool)
+	for _, w := range s.peerSetWindows() {
+		k := peerSetKey(h, w)
+		for _, ip := range ips {
+			cursor := 0
+			for {
+				values, err := redis.Values(c.Do("SSCAN", k, cursor, "MATCH", ipPattern(ip)))
+				if err != nil {
+					return nil, fmt.Errorf("SSCAN: %s", err)
+				}
+				var result []string
+				if _, err := redis.Scan(values, &cursor, &result); err != nil {
+					return nil, fmt.Errorf("scan SSCAN reply: %s", err)
+				}
+				addPeers(selected, result)
+				if cursor == 0 {
+					break
+				}
 			}
-			selected[id] = selected[id] || complete
 		}
 	}
+	return selectedPeers(selected), nil
+}
+
+// addPeers deserializes result into selected, collapsing complete bits of
+// peers which are already selected.
+func addPeers(selected map[peerIdentity]bool, result []string) {
+	for _, s := range result {
+		id, complete, err := deserializePeer(s)
+		if err != nil {
+			log.Errorf("Error deserializing peer %q: %s", s, err)
+			continue
+		}
+		selected[id] = selected[id] || complete
+	}
+}
 
+func selectedPeers(selected map[peerIdentity]bool) []*core.PeerInfo {
 	var peers []*core.PeerInfo
 	for id, complete := range selected {
 		p := core.NewPeerInfo(id.peerID, id.ip, id.port, false, complete)
+		p.Zone = id.zone
 		peers = append(peers, p)
 	}
-	return peers, nil
+	return peers
 }
@@ -14,6 +14,7 @@
 package peerstore
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -56,6 +57,82 @@ func TestRedisStoreGetPeersPopulatesPeerInfoFields(t *testing.T) {
 	require.Equal(peers, []*core.PeerInfo{p})
 }
 
+func TestRedisStoreGetPeersPopulatesZone(t *testing.T) {
+	require := require.New(t)
+
+	config := redisConfigFixture()
+
+	s, err := NewRedisStore(config, clock.New())
+	require.NoError(err)
+
+	h := core.InfoHashFixture()
+
+	p := core.PeerInfoFixture()
+	p.Zone = "sjc1"
+
+	require.NoError(s.UpdatePeer(h, p))
+
+	peers, err := s.GetPeers(h, 1)
+	require.NoError(err)
+	require.Equal(peers, []*core.PeerInfo{p})
+}
+
+func TestDeserializePeerWithoutZone(t *testing.T) {
+	require := require.New(t)
+
+	p := core.PeerInfoFixture()
+
+	id, complete, err := deserializePeer(
+		fmt.Sprintf("%s:%s:%d:1", p.PeerID.String(), p.IP, p.Port))
+	require.NoError(err)
+	require.True(complete)
+	require.Equal(peerIdentity{p.PeerID, p.IP, p.Port, ""}, id)
+}
+
+func TestSerializePeerIPv6(t *testing.T) {
+	for _, ip := range []string{"2001:db8::1", "::1", "10.0.0.1"} {
+		t.Run(ip, func(t *testing.T) {
+			require := require.New(t)
+
+			p := core.PeerInfoFixture()
+			p.IP = ip
+			p.Zone = "sjc1"
+
+			id, complete, err := deserializePeer(serializePeer(p))
+			require.NoError(err)
+			require.Equal(p.Complete, complete)
+			require.Equal(peerIdentity{p.PeerID, ip, p.Port, "sjc1"}, id)
+		})
+	}
+}
+
+func TestRedisStoreGetPeersByIP(t *testing.T) {
+	require := require.New(t)
+
+	config := redisConfigFixture()
+
+	s, err := NewRedisStore(config, clock.New())
+	require.NoError(err)
+
+	h := core.InfoHashFixture()
+
+	var peers []*core.PeerInfo
+	for _, ip := range []string{"10.0.0.1", "10.0.0.12", "2001:db8::1"} {
+		p := core.PeerInfoFixture()
+		p.IP = ip
+		require.NoError(s.UpdatePeer(h, p))
+		peers = append(peers, p)
+	}
+
+	result, err := s.GetPeersByIP(h, []string{"10.0.0.1", "2001:db8::1"})
+	require.NoError(err)
+	require.ElementsMatch([]*core.PeerInfo{peers[0], peers[2]}, result)
+
+	result, err = s.GetPeersByIP(h, []string{"10.0.0.2"})
+	require.NoError(err)
+	require.Empty(result)
+}
+
 func TestRedisStoreGetPeersFromMultipleWindows(t *testing.T) {
 	require := require.New(t)
 
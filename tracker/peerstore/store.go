@@ -23,6 +23,9 @@ type Store interface {
 	// GetPeers returns at most n random peers announcing for h.
 	GetPeers(h core.InfoHash, n int) ([]*core.PeerInfo, error)
 
+	// GetPeersByIP returns all peers announcing for h whose IP is in ips.
+	GetPeersByIP(h core.InfoHash, ips []string) ([]*core.PeerInfo, error)
+
 	// UpdatePeer updates peer fields.
 	UpdatePeer(h core.InfoHash, peer *core.PeerInfo) error
 }
@@ -66,3 +66,20 @@ func (s *testStore) GetPeers(h core.InfoHash, n int) ([]*core.PeerInfo, error) {
 	}
 	return copies, nil
 }
+
+func (s *testStore) GetPeersByIP(h core.InfoHash, ips []string) ([]*core.PeerInfo, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var result []*core.PeerInfo
+	for _, p := range s.torrents[h] {
+		for _, ip := range ips {
+			if p.IP == ip {
+				c := p
+				result = append(result, &c)
+				break
+			}
+		}
+	}
+	return result, nil
+}
@@ -14,9 +14,14 @@
 package trackerserver
 
 import (
+	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
 
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/tracker/announceclient"
@@ -26,16 +31,44 @@ import (
 	"github.com/uber/kraken/utils/log"
 )
 
-func (s *Server) announceHandlerV1(w http.ResponseWriter, r *http.Request) error {
+// decodeAnnounceRequest decodes the announce request in the body of r, which
+// may be gzipped. Clients are told gzipped bodies are supported via the
+// Accept-Encoding response header.
+func decodeAnnounceRequest(w http.ResponseWriter, r *http.Request) (*announceclient.Request, error) {
+	w.Header().Set("Accept-Encoding", "gzip")
+
+	var body io.Reader
+	switch enc := r.Header.Get("Content-Encoding"); enc {
+	case "", "identity":
+		body = r.Body
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, handler.Errorf("gzip request: %s", err).Status(http.StatusBadRequest)
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		return nil, handler.Errorf(
+			"unsupported content encoding: %s", enc).Status(http.StatusUnsupportedMediaType)
+	}
 	req := new(announceclient.Request)
-	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-		return handler.Errorf("json decode request: %s", err)
+	if err := json.NewDecoder(body).Decode(req); err != nil {
+		return nil, handler.Errorf("json decode request: %s", err)
+	}
+	return req, nil
+}
+
+func (s *Server) announceHandlerV1(w http.ResponseWriter, r *http.Request) error {
+	req, err := decodeAnnounceRequest(w, r)
+	if err != nil {
+		return err
 	}
 	d, err := req.GetDigest()
 	if err != nil {
 		return handler.Errorf("get request digest: %s", err)
 	}
-	resp, err := s.announce(d, req.InfoHash, req.Peer)
+	resp, err := s.announce(d, req.InfoHash, req.Peer, req.Neighbors)
 	if err != nil {
 		return err
 	}
@@ -54,15 +87,15 @@ func (s *Server) announceHandlerV2(w http.ResponseWriter, r *http.Request) error
 	if err != nil {
 		return fmt.Errorf("parse infohash: %s", err)
 	}
-	req := new(announceclient.Request)
-	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-		return handler.Errorf("json decode request: %s", err)
+	req, err := decodeAnnounceRequest(w, r)
+	if err != nil {
+		return err
 	}
 	d, err := req.GetDigest()
 	if err != nil {
 		return handler.Errorf("get request digest: %s", err)
 	}
-	resp, err := s.announce(d, h, req.Peer)
+	resp, err := s.announce(d, h, req.Peer, req.Neighbors)
 	if err != nil {
 		return err
 	}
@@ -73,14 +106,17 @@ func (s *Server) announceHandlerV2(w http.ResponseWriter, r *http.Request) error
 }
 
 func (s *Server) announce(
-	d core.Digest, h core.InfoHash, peer *core.PeerInfo) (*announceclient.Response, error) {
+	d core.Digest,
+	h core.InfoHash,
+	peer *core.PeerInfo,
+	neighbors []string) (*announceclient.Response, error) {
 
 	if err := s.peerStore.UpdatePeer(h, peer); err != nil {
 		log.With(
 			"hash", h,
 			"peer_id", peer.PeerID).Errorf("Error updating peer: %s", err)
 	}
-	peers, err := s.getPeerHandout(d, h, peer)
+	peers, err := s.getPeerHandout(d, h, peer, neighbors)
 	if err != nil {
 		return nil, err
 	}
@@ -91,7 +127,10 @@ func (s *Server) announce(
 }
 
 func (s *Server) getPeerHandout(
-	d core.Digest, h core.InfoHash, peer *core.PeerInfo) ([]*core.PeerInfo, error) {
+	d core.Digest,
+	h core.InfoHash,
+	peer *core.PeerInfo,
+	neighbors []string) ([]*core.PeerInfo, error) {
 
 	if peer.Complete {
 		// If the peer is announcing as complete, don't return a peer handout since
@@ -99,10 +138,15 @@ func (s *Server) getPeerHandout(
 		return nil, nil
 	}
 	var errs []error
-	peers, err := s.peerStore.GetPeers(h, s.config.PeerHandoutLimit)
+	peers, err := s.getNeighbors(h, peer, neighbors)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("neighbors: %s", err))
+	}
+	random, err := s.peerStore.GetPeers(h, s.config.PeerHandoutLimit)
 	if err != nil {
 		errs = append(errs, fmt.Errorf("peer store: %s", err))
 	}
+	peers = mergePeers(peers, random)
 	origins, err := s.originStore.GetOrigins(d)
 	if err != nil {
 		errs = append(errs, fmt.Errorf("origin store: %s", err))
@@ -113,3 +157,78 @@ func (s *Server) getPeerHandout(
 	}
 	return s.policy.SortPeers(peer, peers), nil
 }
+
+// getNeighbors returns the peers announcing for h which match neighbors, in
+// addition to the random handout. Neighbors are IPs, optionally with a port.
+func (s *Server) getNeighbors(
+	h core.InfoHash, peer *core.PeerInfo, neighbors []string) ([]*core.PeerInfo, error) {
+
+	if len(neighbors) == 0 {
+		return nil, nil
+	}
+	// Maps neighbor IPs to their ports, or to 0 if any port matches.
+	ports := make(map[string]map[int]bool)
+	for _, n := range neighbors {
+		ip, port, err := parseNeighbor(n)
+		if err != nil {
+			return nil, fmt.Errorf("invalid neighbor %q: %s", n, err)
+		}
+		if ports[ip] == nil {
+			ports[ip] = make(map[int]bool)
+		}
+		ports[ip][port] = true
+	}
+	ips := make([]string, 0, len(ports))
+	for ip := range ports {
+		ips = append(ips, ip)
+	}
+	candidates, err := s.peerStore.GetPeersByIP(h, ips)
+	if err != nil {
+		return nil, err
+	}
+	var result []*core.PeerInfo
+	for _, p := range candidates {
+		if p.PeerID == peer.PeerID {
+			continue
+		}
+		if ps := ports[p.IP]; ps[0] || ps[p.Port] {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+// parseNeighbor parses n, an IP optionally with a port. Port is 0 if absent.
+func parseNeighbor(n string) (ip string, port int, err error) {
+	if parsed := net.ParseIP(n); parsed != nil {
+		return parsed.String(), 0, nil
+	}
+	host, p, err := net.SplitHostPort(n)
+	if err != nil {
+		return "", 0, err
+	}
+	parsed := net.ParseIP(host)
+	if parsed == nil {
+		return "", 0, errors.New("host must be an ip")
+	}
+	port, err = strconv.Atoi(p)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port: %s", err)
+	}
+	return parsed.String(), port, nil
+}
+
+// mergePeers appends the peers of b missing from a to a.
+func mergePeers(a, b []*core.PeerInfo) []*core.PeerInfo {
+	seen := make(map[core.PeerID]bool)
+	for _, p := range a {
+		seen[p.PeerID] = true
+	}
+	for _, p := range b {
+		if !seen[p.PeerID] {
+			seen[p.PeerID] = true
+			a = append(a, p)
+		}
+	}
+	return a
+}
@@ -14,8 +14,10 @@
 package trackerserver
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
@@ -23,6 +25,7 @@ import (
 	"github.com/uber/kraken/lib/hashring"
 	"github.com/uber/kraken/lib/hostlist"
 	"github.com/uber/kraken/tracker/announceclient"
+	"github.com/uber/kraken/utils/httputil"
 	"github.com/uber/kraken/utils/testutil"
 
 	"github.com/golang/mock/gomock"
@@ -30,7 +33,7 @@ import (
 )
 
 func newAnnounceClient(pctx core.PeerContext, addr string) announceclient.Client {
-	return announceclient.New(pctx, hashring.NoopPassiveRing(hostlist.Fixture(addr)), nil)
+	return announceclient.New(announceclient.Config{}, pctx, hashring.NoopPassiveRing(hostlist.Fixture(addr)), nil)
 }
 
 func TestAnnounceSinglePeerResponse(t *testing.T) {
@@ -68,6 +71,48 @@ func TestAnnounceSinglePeerResponse(t *testing.T) {
 	}
 }
 
+func TestAnnounceIncludesNeighbors(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t, Config{})
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	blob := core.NewBlobFixture()
+	pctx := core.PeerContextFixture()
+
+	neighbor := core.PeerInfoFixture()
+	neighbor.IP = "10.0.0.2"
+	neighbor.Port = 16001
+	otherPort := core.PeerInfoFixture()
+	otherPort.IP = "10.0.0.2"
+	otherPort.Port = 16002
+	random := core.PeerInfoFixture()
+
+	client := announceclient.New(
+		announceclient.Config{Neighbors: []string{"10.0.0.2:16001", "10.0.0.3"}},
+		pctx, hashring.NoopPassiveRing(hostlist.Fixture(addr)), nil)
+
+	mocks.originStore.EXPECT().GetOrigins(blob.Digest).Return(nil, nil)
+	mocks.peerStore.EXPECT().GetPeersByIP(
+		blob.MetaInfo.InfoHash(), gomock.Any()).DoAndReturn(
+		func(h core.InfoHash, ips []string) ([]*core.PeerInfo, error) {
+			require.ElementsMatch([]string{"10.0.0.2", "10.0.0.3"}, ips)
+			return []*core.PeerInfo{neighbor, otherPort}, nil
+		})
+	mocks.peerStore.EXPECT().GetPeers(
+		blob.MetaInfo.InfoHash(), gomock.Any()).Return([]*core.PeerInfo{random, neighbor}, nil)
+	mocks.peerStore.EXPECT().UpdatePeer(
+		blob.MetaInfo.InfoHash(), core.PeerInfoFromContext(pctx, false)).Return(nil)
+
+	result, _, err := client.Announce(
+		blob.Digest, blob.MetaInfo.InfoHash(), false, announceclient.V2)
+	require.NoError(err)
+	require.ElementsMatch([]*core.PeerInfo{neighbor, random}, result)
+}
+
 func TestAnnounceUnavailablePeerStoreCanStillProvideOrigins(t *testing.T) {
 	require := require.New(t)
 
@@ -168,3 +213,226 @@ func TestAnnounceRequestGetDigestBackwardsCompatibility(t *testing.T) {
 		})
 	}
 }
+
+func TestAnnounceClientCheckReadiness(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t, Config{})
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	client := newAnnounceClient(core.PeerContextFixture(), addr)
+
+	require.NoError(client.CheckReadiness())
+
+	stop()
+
+	require.Error(client.CheckReadiness())
+}
+
+// encodingRecorder records the Content-Encoding of requests to h. If
+// acceptGzip is false, h is treated as a tracker without gzip support.
+type encodingRecorder struct {
+	h          http.Handler
+	acceptGzip bool
+	encodings  []string
+}
+
+func (e *encodingRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.encodings = append(e.encodings, r.Header.Get("Content-Encoding"))
+	if !e.acceptGzip {
+		w = noAcceptEncodingWriter{w}
+	}
+	e.h.ServeHTTP(w, r)
+}
+
+type noAcceptEncodingWriter struct {
+	http.ResponseWriter
+}
+
+func (w noAcceptEncodingWriter) WriteHeader(code int) {
+	w.Header().Del("Accept-Encoding")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w noAcceptEncodingWriter) Write(b []byte) (int, error) {
+	w.Header().Del("Accept-Encoding")
+	return w.ResponseWriter.Write(b)
+}
+
+func TestAnnounceCompression(t *testing.T) {
+	for _, acceptGzip := range []bool{true, false} {
+		t.Run(fmt.Sprintf("accept_gzip=%t", acceptGzip), func(t *testing.T) {
+			require := require.New(t)
+
+			mocks, cleanup := newServerMocks(t, Config{})
+			defer cleanup()
+
+			recorder := &encodingRecorder{h: mocks.handler(), acceptGzip: acceptGzip}
+			addr, stop := testutil.StartServer(recorder)
+			defer stop()
+
+			blob := core.NewBlobFixture()
+			pctx := core.PeerContextFixture()
+			peers := []*core.PeerInfo{core.PeerInfoFixture()}
+
+			client := announceclient.New(
+				announceclient.Config{Compression: true},
+				pctx, hashring.NoopPassiveRing(hostlist.Fixture(addr)), nil)
+
+			mocks.originStore.EXPECT().GetOrigins(blob.Digest).Return(nil, nil).Times(2)
+			mocks.peerStore.EXPECT().GetPeers(
+				blob.MetaInfo.InfoHash(), gomock.Any()).Return(peers, nil).Times(2)
+			mocks.peerStore.EXPECT().UpdatePeer(
+				blob.MetaInfo.InfoHash(), core.PeerInfoFromContext(pctx, false)).Return(nil).Times(2)
+
+			for i := 0; i < 2; i++ {
+				result, _, err := client.Announce(
+					blob.Digest, blob.MetaInfo.InfoHash(), false, announceclient.V2)
+				require.NoError(err)
+				require.Equal(peers, result)
+			}
+
+			// The first announce is never compressed, since the tracker has not
+			// advertised gzip support yet.
+			expected := []string{"", ""}
+			if acceptGzip {
+				expected = []string{"", "gzip"}
+			}
+			require.Equal(expected, recorder.encodings)
+		})
+	}
+}
+
+// gzipRejecter fails gzipped requests with status, like trackers which do not
+// decode gzip bodies, while still advertising gzip support.
+type gzipRejecter struct {
+	encodingRecorder
+	status int
+}
+
+func (g *gzipRejecter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		g.encodings = append(g.encodings, "gzip")
+		w.Header().Set("Accept-Encoding", "gzip")
+		w.WriteHeader(g.status)
+		return
+	}
+	g.encodingRecorder.ServeHTTP(w, r)
+}
+
+func TestAnnounceCompressionFallsBackWhenGzipRejected(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t, Config{})
+	defer cleanup()
+
+	rejecter := &gzipRejecter{
+		encodingRecorder: encodingRecorder{h: mocks.handler(), acceptGzip: true},
+		status:           http.StatusUnsupportedMediaType,
+	}
+	addr, stop := testutil.StartServer(rejecter)
+	defer stop()
+
+	blob := core.NewBlobFixture()
+	pctx := core.PeerContextFixture()
+	peers := []*core.PeerInfo{core.PeerInfoFixture()}
+
+	client := announceclient.New(
+		announceclient.Config{Compression: true},
+		pctx, hashring.NoopPassiveRing(hostlist.Fixture(addr)), nil)
+
+	mocks.originStore.EXPECT().GetOrigins(blob.Digest).Return(nil, nil).Times(3)
+	mocks.peerStore.EXPECT().GetPeers(
+		blob.MetaInfo.InfoHash(), gomock.Any()).Return(peers, nil).Times(3)
+	mocks.peerStore.EXPECT().UpdatePeer(
+		blob.MetaInfo.InfoHash(), core.PeerInfoFromContext(pctx, false)).Return(nil).Times(3)
+
+	for i := 0; i < 3; i++ {
+		result, _, err := client.Announce(
+			blob.Digest, blob.MetaInfo.InfoHash(), false, announceclient.V2)
+		require.NoError(err)
+		require.Equal(peers, result)
+	}
+
+	// The rejected gzip request is resent uncompressed, and gzip is not
+	// retried although the tracker keeps advertising it.
+	require.Equal([]string{"", "gzip", "", ""}, rejecter.encodings)
+}
+
+// flakyTracker fails the first gzipped request with 500, like a tracker with a
+// transient error.
+type flakyTracker struct {
+	encodingRecorder
+	failed bool
+}
+
+func (f *flakyTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Encoding") == "gzip" && !f.failed {
+		f.failed = true
+		f.encodings = append(f.encodings, "gzip")
+		w.Header().Set("Accept-Encoding", "gzip")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	f.encodingRecorder.ServeHTTP(w, r)
+}
+
+func TestAnnounceCompressionNotDisabledByTransientError(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t, Config{})
+	defer cleanup()
+
+	tracker := &flakyTracker{
+		encodingRecorder: encodingRecorder{h: mocks.handler(), acceptGzip: true},
+	}
+	addr, stop := testutil.StartServer(tracker)
+	defer stop()
+
+	blob := core.NewBlobFixture()
+	pctx := core.PeerContextFixture()
+	peers := []*core.PeerInfo{core.PeerInfoFixture()}
+
+	client := announceclient.New(
+		announceclient.Config{Compression: true},
+		pctx, hashring.NoopPassiveRing(hostlist.Fixture(addr)), nil)
+
+	mocks.originStore.EXPECT().GetOrigins(blob.Digest).Return(nil, nil).Times(2)
+	mocks.peerStore.EXPECT().GetPeers(
+		blob.MetaInfo.InfoHash(), gomock.Any()).Return(peers, nil).Times(2)
+	mocks.peerStore.EXPECT().UpdatePeer(
+		blob.MetaInfo.InfoHash(), core.PeerInfoFromContext(pctx, false)).Return(nil).Times(2)
+
+	announce := func() error {
+		_, _, err := client.Announce(
+			blob.Digest, blob.MetaInfo.InfoHash(), false, announceclient.V2)
+		return err
+	}
+	require.NoError(announce())
+	require.True(httputil.IsStatus(announce(), http.StatusInternalServerError))
+	require.NoError(announce())
+
+	// The failed gzip request is not resent uncompressed, and gzip is used
+	// again once the tracker recovers.
+	require.Equal([]string{"", "gzip", "gzip"}, tracker.encodings)
+}
+
+func TestAnnounceUnsupportedContentEncoding(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t, Config{})
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	h := core.InfoHashFixture()
+	_, err := httputil.Post(
+		fmt.Sprintf("http://%s/announce/%s", addr, h),
+		httputil.SendBody(bytes.NewReader([]byte("{}"))),
+		httputil.SendHeaders(map[string]string{"Content-Encoding": "br"}))
+	require.True(httputil.IsStatus(err, http.StatusUnsupportedMediaType))
+}
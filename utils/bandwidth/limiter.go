@@ -16,6 +16,7 @@ package bandwidth
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/uber/kraken/utils/log"
@@ -47,10 +48,14 @@ func (c Config) applyDefaults() Config {
 
 // Limiter limits egress and ingress bandwidth via token-bucket rate limiter.
 type Limiter struct {
-	config  Config
+	config Config
+	logger *zap.SugaredLogger
+
+	// Protects egress, ingress, and the configured bits per sec, which may be
+	// changed at runtime via SetLimits.
+	mu      sync.RWMutex
 	egress  *rate.Limiter
 	ingress *rate.Limiter
-	logger  *zap.SugaredLogger
 }
 
 // Option allows setting optional parameters in Limiter.
@@ -85,16 +90,56 @@ func NewLimiter(config Config, opts ...Option) (*Limiter, error) {
 		return nil, errors.New("invalid config: ingress_bits_per_sec must be non-zero")
 	}
 
-	l.logger.Infof("Setting egress bandwidth to %s/sec", memsize.BitFormat(config.EgressBitsPerSec))
-	l.logger.Infof("Setting ingress bandwidth to %s/sec", memsize.BitFormat(config.IngressBitsPerSec))
+	l.setLimits(config.EgressBitsPerSec, config.IngressBitsPerSec)
+
+	return l, nil
+}
 
-	etps := config.EgressBitsPerSec / config.TokenSize
-	itps := config.IngressBitsPerSec / config.TokenSize
+// setLimits replaces the egress and ingress token buckets. Callers must
+// hold l.mu, or have exclusive access to l.
+func (l *Limiter) setLimits(egressBitsPerSec, ingressBitsPerSec uint64) {
+	l.logger.Infof("Setting egress bandwidth to %s/sec", memsize.BitFormat(egressBitsPerSec))
+	l.logger.Infof("Setting ingress bandwidth to %s/sec", memsize.BitFormat(ingressBitsPerSec))
+
+	l.config.EgressBitsPerSec = egressBitsPerSec
+	l.config.IngressBitsPerSec = ingressBitsPerSec
+
+	etps := egressBitsPerSec / l.config.TokenSize
+	itps := ingressBitsPerSec / l.config.TokenSize
 
 	l.egress = rate.NewLimiter(rate.Limit(etps), int(etps))
 	l.ingress = rate.NewLimiter(rate.Limit(itps), int(itps))
+}
 
-	return l, nil
+// SetLimits replaces the configured egress and ingress bandwidth at runtime.
+// Reservations in progress are unaffected, but all subsequent reservations
+// use the new limits. Returns error if bandwidth limits are disabled.
+func (l *Limiter) SetLimits(egressBitsPerSec, ingressBitsPerSec uint64) error {
+	if !l.config.Enable {
+		return errors.New("bandwidth limits disabled")
+	}
+	if egressBitsPerSec < l.config.TokenSize {
+		return fmt.Errorf(
+			"egress bandwidth must be at least %s/sec", memsize.BitFormat(l.config.TokenSize))
+	}
+	if ingressBitsPerSec < l.config.TokenSize {
+		return fmt.Errorf(
+			"ingress bandwidth must be at least %s/sec", memsize.BitFormat(l.config.TokenSize))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.setLimits(egressBitsPerSec, ingressBitsPerSec)
+	return nil
+}
+
+// Limits returns the currently configured egress and ingress bits per sec.
+func (l *Limiter) Limits() (egressBitsPerSec, ingressBitsPerSec uint64) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.config.EgressBitsPerSec, l.config.IngressBitsPerSec
 }
 
 func (l *Limiter) reserve(rl *rate.Limiter, nbytes int64) error {
@@ -119,23 +164,34 @@ func (l *Limiter) reserve(rl *rate.Limiter, nbytes int64) error {
 // ReserveEgress blocks until egress bandwidth for nbytes is available.
 // Returns error if nbytes is larger than the maximum egress bandwidth.
 func (l *Limiter) ReserveEgress(nbytes int64) error {
-	return l.reserve(l.egress, nbytes)
+	l.mu.RLock()
+	rl := l.egress
+	l.mu.RUnlock()
+
+	return l.reserve(rl, nbytes)
 }
 
 // ReserveIngress blocks until ingress bandwidth for nbytes is available.
 // Returns error if nbytes is larger than the maximum ingress bandwidth.
 func (l *Limiter) ReserveIngress(nbytes int64) error {
-	return l.reserve(l.ingress, nbytes)
+	l.mu.RLock()
+	rl := l.ingress
+	l.mu.RUnlock()
+
+	return l.reserve(rl, nbytes)
 }
 
-// Adjust divides the originally configured egress and ingress bps by denominator.
-// Note, because the original configuration is always used, multiple Adjust calls
+// Adjust divides the currently configured egress and ingress bps by denominator.
+// Note, because the configured limits are always used, multiple Adjust calls
 // have no affect on each other.
 func (l *Limiter) Adjust(denominator int) error {
 	if denominator <= 0 {
 		return errors.New("denominator must be greater than 0")
 	}
 
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
 	ebps := max(l.config.EgressBitsPerSec/l.config.TokenSize/uint64(denominator), 1)
 	ibps := max(l.config.IngressBitsPerSec/l.config.TokenSize/uint64(denominator), 1)
 
@@ -147,11 +203,17 @@ func (l *Limiter) Adjust(denominator int) error {
 
 // EgressLimit returns the current egress limit.
 func (l *Limiter) EgressLimit() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
 	return int64(l.egress.Limit())
 }
 
 // IngressLimit returns the current ingress limit.
 func (l *Limiter) IngressLimit() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
 	return int64(l.ingress.Limit())
 }
 
@@ -248,3 +248,49 @@ func TestLimiterAdjust(t *testing.T) {
 		require.Equal(c.ingress, l.IngressLimit())
 	}
 }
+
+func TestLimiterSetLimits(t *testing.T) {
+	require := require.New(t)
+
+	l, err := NewLimiter(Config{
+		EgressBitsPerSec:  50,
+		IngressBitsPerSec: 10,
+		TokenSize:         1,
+		Enable:            true,
+	})
+	require.NoError(err)
+
+	require.NoError(l.SetLimits(20, 40))
+
+	egress, ingress := l.Limits()
+	require.Equal(uint64(20), egress)
+	require.Equal(uint64(40), ingress)
+	require.Equal(int64(20), l.EgressLimit())
+	require.Equal(int64(40), l.IngressLimit())
+
+	// Adjust is relative to the new limits.
+	require.NoError(l.Adjust(2))
+	require.Equal(int64(10), l.EgressLimit())
+	require.Equal(int64(20), l.IngressLimit())
+
+	// Reservations larger than the old limits now succeed.
+	require.NoError(l.ReserveIngress(3))
+}
+
+func TestLimiterSetLimitsErrors(t *testing.T) {
+	require := require.New(t)
+
+	l, err := NewLimiter(Config{
+		EgressBitsPerSec:  50,
+		IngressBitsPerSec: 10,
+		TokenSize:         8,
+		Enable:            true,
+	})
+	require.NoError(err)
+	require.Error(l.SetLimits(0, 80))
+	require.Error(l.SetLimits(80, 4))
+
+	disabled, err := NewLimiter(Config{Enable: false})
+	require.NoError(err)
+	require.Error(disabled.SetLimits(80, 80))
+}
@@ -110,6 +110,15 @@ func Load(filename string, config interface{}) error {
 	return loadFiles(config, filenames)
 }
 
+// LoadData unmarshals YAML data, e.g. fetched from a secrets store, over
+// config and validates the result. Extends directives are not followed.
+func LoadData(data []byte, config interface{}) error {
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("unmarshal: %s", err)
+	}
+	return validate(config)
+}
+
 type getExtend func(filename string) (extends string, err error)
 
 // resolveExtends returns the list of config paths that the original config `filename`
@@ -159,6 +168,15 @@ func readExtend(configFile string) (string, error) {
 
 // loadFiles loads a list of files, deep-merging values.
 func loadFiles(config interface{}, fnames []string) error {
+	if err := unmarshalFiles(config, fnames); err != nil {
+		return err
+	}
+	// Validate on the merged config at the end.
+	return validate(config)
+}
+
+// unmarshalFiles unmarshals a list of files into config without validation.
+func unmarshalFiles(config interface{}, fnames []string) error {
 	for _, fname := range fnames {
 		data, err := ioutil.ReadFile(fname)
 		if err != nil {
@@ -169,8 +187,10 @@ func loadFiles(config interface{}, fnames []string) error {
 			return fmt.Errorf("unmarshal %s: %s", fname, err)
 		}
 	}
+	return nil
+}
 
-	// Validate on the merged config at the end.
+func validate(config interface{}) error {
 	if err := validator.Validate(config); err != nil {
 		return ValidationError{
 			errorMap: err.(validator.ErrorMap),
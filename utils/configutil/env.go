@@ -0,0 +1,167 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package configutil
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/uber/kraken/utils/log"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is the prefix of environment variables which LoadWithEnv overlays
+// onto configuration.
+const EnvPrefix = "KRAKEN_"
+
+// LoadWithEnv behaves like Load, but overlays values from environment variables
+// prefixed with EnvPrefix after all files are loaded and before validation.
+//
+// Variable names are mapped onto nested fields by joining field names with
+// underscores, where each field name is either its yaml key or its Go name,
+// case-insensitive and with underscores optional. For example, both
+// KRAKEN_SCHEDULER_CONN_TTI and KRAKEN_SCHEDULER_CONNTTI set the conn_tti key
+// nested under scheduler. Values are parsed as YAML, so durations, lists and
+// maps use the same syntax as in configuration files.
+func LoadWithEnv(filename string, config interface{}) error {
+	filenames, err := resolveExtends(filename, readExtend)
+	if err != nil {
+		return err
+	}
+	if err := unmarshalFiles(config, filenames); err != nil {
+		return err
+	}
+	if err := applyEnv(config, EnvPrefix, os.Environ()); err != nil {
+		return fmt.Errorf("apply env: %s", err)
+	}
+	return validate(config)
+}
+
+// applyEnv overlays all variables in environ which start with prefix onto config.
+// Variables which do not map onto a field are logged and ignored, since the
+// prefix may be shared with variables meant for other components.
+func applyEnv(config interface{}, prefix string, environ []string) error {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to a struct, got %T", config)
+	}
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, prefix) {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name, value := parts[0], parts[1]
+		path := strings.Split(strings.TrimPrefix(name, prefix), "_")
+		ok, err := setPath(v.Elem(), path, value)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		if !ok {
+			log.Warnf("Ignoring environment variable %s: no matching config field", name)
+		}
+	}
+	return nil
+}
+
+// setPath sets the field of struct v identified by path to value. Because field
+// names may themselves contain underscores, every prefix of path is tried as
+// the name of the outermost field. Returns false if no field matches.
+func setPath(v reflect.Value, path []string, value string) (bool, error) {
+	for i := 1; i <= len(path); i++ {
+		name := strings.Join(path[:i], "")
+		rest := path[i:]
+		for _, f := range matchFields(v, name) {
+			if len(rest) == 0 {
+				return true, setValue(f, value)
+			}
+			if f.Kind() == reflect.Ptr && f.Type().Elem().Kind() == reflect.Struct {
+				if f.IsNil() {
+					f.Set(reflect.New(f.Type().Elem()))
+				}
+				f = f.Elem()
+			}
+			if f.Kind() != reflect.Struct {
+				continue
+			}
+			if ok, err := setPath(f, rest, value); ok || err != nil {
+				return ok, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// matchFields returns the settable fields of struct v whose yaml key or Go name
+// normalizes to name. Fields of inlined structs are included.
+func matchFields(v reflect.Value, name string) []reflect.Value {
+	var result []reflect.Value
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		f := v.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+		key, inline := yamlKey(sf)
+		if key == "-" {
+			continue
+		}
+		if inline && f.Kind() == reflect.Struct {
+			result = append(result, matchFields(f, name)...)
+			continue
+		}
+		if normalizeEnvName(key) == name || normalizeEnvName(sf.Name) == name {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// yamlKey returns the yaml key of sf, following the same defaults as yaml.v2.
+func yamlKey(sf reflect.StructField) (key string, inline bool) {
+	tag := strings.Split(sf.Tag.Get("yaml"), ",")
+	for _, opt := range tag[1:] {
+		if opt == "inline" {
+			inline = true
+		}
+	}
+	if tag[0] == "" {
+		return strings.ToLower(sf.Name), inline
+	}
+	return tag[0], inline
+}
+
+func normalizeEnvName(s string) string {
+	return strings.ToUpper(strings.Replace(s, "_", "", -1))
+}
+
+func setValue(f reflect.Value, value string) error {
+	if f.Kind() == reflect.String {
+		// Avoid YAML interpreting strings such as "true" or "1".
+		f.SetString(value)
+		return nil
+	}
+	p := reflect.New(f.Type())
+	if err := yaml.Unmarshal([]byte(value), p.Interface()); err != nil {
+		return fmt.Errorf("unmarshal %q: %s", value, err)
+	}
+	f.Set(p.Elem())
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package configutil
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type envConfig struct {
+	Scheduler envSchedulerConfig `yaml:"scheduler"`
+	Peers     []string           `yaml:"peers"`
+	Name      string             `yaml:"name"`
+	Pointer   *envSchedulerConfig
+}
+
+type envSchedulerConfig struct {
+	ConnTTI     time.Duration `yaml:"conn_tti"`
+	ConnTimeout time.Duration `yaml:"conn_timeout"`
+	MaxConns    int           `yaml:"max_conns"`
+	Disabled    bool          `yaml:"disabled"`
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		desc     string
+		env      string
+		expected envConfig
+	}{
+		{
+			"joined yaml key",
+			"KRAKEN_SCHEDULER_CONNTIMEOUT=10s",
+			envConfig{Scheduler: envSchedulerConfig{ConnTimeout: 10 * time.Second}},
+		}, {
+			"underscored yaml key",
+			"KRAKEN_SCHEDULER_CONN_TTI=1m",
+			envConfig{Scheduler: envSchedulerConfig{ConnTTI: time.Minute}},
+		}, {
+			"int",
+			"KRAKEN_SCHEDULER_MAX_CONNS=5",
+			envConfig{Scheduler: envSchedulerConfig{MaxConns: 5}},
+		}, {
+			"bool",
+			"KRAKEN_SCHEDULER_DISABLED=true",
+			envConfig{Scheduler: envSchedulerConfig{Disabled: true}},
+		}, {
+			"list",
+			"KRAKEN_PEERS=[a, b]",
+			envConfig{Peers: []string{"a", "b"}},
+		}, {
+			"string is not parsed as yaml",
+			"KRAKEN_NAME=true",
+			envConfig{Name: "true"},
+		}, {
+			"nil pointer is allocated",
+			"KRAKEN_POINTER_MAXCONNS=3",
+			envConfig{Pointer: &envSchedulerConfig{MaxConns: 3}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			var config envConfig
+			require.NoError(applyEnv(&config, EnvPrefix, []string{test.env, "OTHER_NAME=foo"}))
+			require.Equal(test.expected, config)
+		})
+	}
+}
+
+func TestApplyEnvErrors(t *testing.T) {
+	tests := []struct {
+		desc string
+		env  string
+	}{
+		{"invalid value", "KRAKEN_SCHEDULER_MAX_CONNS=abc"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var config envConfig
+			require.Error(t, applyEnv(&config, EnvPrefix, []string{test.env}))
+		})
+	}
+}
+
+func TestApplyEnvIgnoresUnknownVariables(t *testing.T) {
+	tests := []struct {
+		desc string
+		env  string
+	}{
+		{"unknown field", "KRAKEN_SCHEDULER_UNKNOWN=1"},
+		{"path into non-struct", "KRAKEN_NAME_FOO=1"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			var config envConfig
+			require.NoError(applyEnv(
+				&config, EnvPrefix, []string{test.env, "KRAKEN_SCHEDULER_MAX_CONNS=5"}))
+			require.Equal(envConfig{Scheduler: envSchedulerConfig{MaxConns: 5}}, config)
+		})
+	}
+}
+
+func TestLoadWithEnvOverridesFile(t *testing.T) {
+	require := require.New(t)
+
+	fname := writeFile(t, goodConfig)
+	defer os.Remove(fname)
+
+	os.Setenv("KRAKEN_LISTEN_ADDRESS", "localhost:9000")
+	os.Setenv("KRAKEN_X_Y_Z_K1", "v2")
+	defer os.Unsetenv("KRAKEN_LISTEN_ADDRESS")
+	defer os.Unsetenv("KRAKEN_X_Y_Z_K1")
+
+	var cfg configuration
+	require.NoError(LoadWithEnv(fname, &cfg))
+	require.Equal("localhost:9000", cfg.ListenAddress)
+	require.Equal("v2", cfg.X.Y.Z.K1)
+	require.Equal(1024, cfg.BufferSpace)
+}
+
+func TestLoadWithEnvValidatesMergedConfig(t *testing.T) {
+	require := require.New(t)
+
+	fname := writeFile(t, goodConfig)
+	defer os.Remove(fname)
+
+	os.Setenv("KRAKEN_BUFFER_SPACE", "1")
+	defer os.Unsetenv("KRAKEN_BUFFER_SPACE")
+
+	var cfg configuration
+	err := LoadWithEnv(fname, &cfg)
+	require.Error(err)
+	_, ok := err.(ValidationError)
+	require.True(ok)
+}
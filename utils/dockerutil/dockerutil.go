@@ -14,16 +14,33 @@
 package dockerutil
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 
 	"github.com/uber/kraken/core"
+
 	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	_ "github.com/docker/distribution/manifest/ocischema" // Registers OCI manifest unmarshaling.
 	"github.com/docker/distribution/manifest/schema2"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
+// ErrPlatformNotFound is returned when a manifest index has no manifest for
+// the requested platform.
+var ErrPlatformNotFound = errors.New("platform not found")
+
+var _supportedMediaTypes = map[string]bool{
+	schema2.MediaTypeManifest:          true,
+	manifestlist.MediaTypeManifestList: true,
+	v1.MediaTypeImageManifest:          true,
+	v1.MediaTypeImageIndex:             true,
+}
+
 // ParseManifestV2 returns a parsed v2 manifest and its digest
 func ParseManifestV2(r io.Reader) (distribution.Manifest, core.Digest, error) {
 	b, err := ioutil.ReadAll(r)
@@ -42,18 +59,72 @@ func ParseManifestV2(r io.Reader) (distribution.Manifest, core.Digest, error) {
 	if version != 2 {
 		return nil, core.Digest{}, fmt.Errorf("unsupported manifest version: %d", version)
 	}
-	d, err := core.ParseSHA256Digest(string(desc.Digest))
+	d, err := core.ParseDigest(string(desc.Digest))
 	if err != nil {
 		return nil, core.Digest{}, fmt.Errorf("parse digest: %s", err)
 	}
 	return manifest, d, nil
 }
 
+// ParseManifest returns a parsed manifest and its digest. Docker schema2
+// manifests and manifest lists, and OCI image manifests and indexes are
+// supported.
+func ParseManifest(r io.Reader) (distribution.Manifest, core.Digest, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, core.Digest{}, fmt.Errorf("read: %s", err)
+	}
+	mediaType, err := manifestMediaType(b)
+	if err != nil {
+		return nil, core.Digest{}, err
+	}
+	manifest, _, err := distribution.UnmarshalManifest(mediaType, b)
+	if err != nil {
+		return nil, core.Digest{}, fmt.Errorf("unmarshal manifest: %s", err)
+	}
+	d, err := core.NewDigester().FromBytes(b)
+	if err != nil {
+		return nil, core.Digest{}, fmt.Errorf("digest: %s", err)
+	}
+	return manifest, d, nil
+}
+
+// manifestMediaType returns the media type of raw manifest b. The media type
+// is optional in OCI manifests, in which case it is inferred from the content.
+func manifestMediaType(b []byte) (string, error) {
+	var v struct {
+		MediaType string          `json:"mediaType"`
+		Manifests json.RawMessage `json:"manifests"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return "", fmt.Errorf("unmarshal media type: %s", err)
+	}
+	mediaType := v.MediaType
+	if mediaType == "" {
+		if v.Manifests != nil {
+			mediaType = v1.MediaTypeImageIndex
+		} else {
+			mediaType = v1.MediaTypeImageManifest
+		}
+	}
+	if !_supportedMediaTypes[mediaType] {
+		return "", fmt.Errorf("unsupported manifest media type: %s", mediaType)
+	}
+	return mediaType, nil
+}
+
+// IsManifestIndex returns true if manifest is a manifest list or OCI image
+// index, which reference per-platform manifests rather than layers.
+func IsManifestIndex(manifest distribution.Manifest) bool {
+	_, ok := manifest.(*manifestlist.DeserializedManifestList)
+	return ok
+}
+
 // GetManifestReferences returns a list of references by a V2 manifest
 func GetManifestReferences(manifest distribution.Manifest) ([]core.Digest, error) {
 	var refs []core.Digest
 	for _, desc := range manifest.References() {
-		d, err := core.ParseSHA256Digest(string(desc.Digest))
+		d, err := core.ParseDigest(string(desc.Digest))
 		if err != nil {
 			return nil, fmt.Errorf("parse digest: %s", err)
 		}
@@ -61,3 +132,31 @@ func GetManifestReferences(manifest distribution.Manifest) ([]core.Digest, error
 	}
 	return refs, nil
 }
+
+// GetPlatformManifest returns the digest of the manifest referenced by index
+// manifest which matches platform, formatted as "os/arch" or
+// "os/arch/variant". If no variant is given, any variant matches.
+func GetPlatformManifest(manifest distribution.Manifest, platform string) (core.Digest, error) {
+	list, ok := manifest.(*manifestlist.DeserializedManifestList)
+	if !ok {
+		return core.Digest{}, errors.New("expected manifest list or image index")
+	}
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return core.Digest{}, fmt.Errorf("invalid platform %q: expected os/arch[/variant]", platform)
+	}
+	for _, desc := range list.Manifests {
+		if desc.Platform.OS != parts[0] || desc.Platform.Architecture != parts[1] {
+			continue
+		}
+		if len(parts) == 3 && desc.Platform.Variant != parts[2] {
+			continue
+		}
+		d, err := core.ParseDigest(string(desc.Digest))
+		if err != nil {
+			return core.Digest{}, fmt.Errorf("parse digest: %s", err)
+		}
+		return d, nil
+	}
+	return core.Digest{}, ErrPlatformNotFound
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dockerutil
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseManifest(t *testing.T) {
+	config := core.DigestFixture()
+	layer1 := core.DigestFixture()
+	layer2 := core.DigestFixture()
+
+	schema2Digest, schema2Raw := ManifestFixture(config, layer1, layer2)
+	ociDigest, ociRaw := OCIManifestFixture(config, layer1)
+	indexDigest, indexRaw := OCIIndexFixture(schema2Digest, ociDigest)
+
+	tests := []struct {
+		desc    string
+		digest  core.Digest
+		raw     []byte
+		refs    []core.Digest
+		isIndex bool
+	}{
+		{"docker schema2", schema2Digest, schema2Raw, []core.Digest{config, layer1, layer2}, false},
+		{"oci manifest", ociDigest, ociRaw, []core.Digest{config, layer1}, false},
+		{"oci index", indexDigest, indexRaw, []core.Digest{schema2Digest, ociDigest}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			manifest, d, err := ParseManifest(bytes.NewReader(test.raw))
+			require.NoError(err)
+			require.Equal(test.digest, d)
+			require.Equal(test.isIndex, IsManifestIndex(manifest))
+
+			refs, err := GetManifestReferences(manifest)
+			require.NoError(err)
+			require.Equal(test.refs, refs)
+		})
+	}
+}
+
+func TestParseManifestInfersOCIMediaType(t *testing.T) {
+	require := require.New(t)
+
+	config := core.DigestFixture()
+	layer := core.DigestFixture()
+	_, raw := OCIManifestFixture(config, layer)
+	raw = bytes.Replace(raw, []byte(`"mediaType": "application/vnd.oci.image.manifest.v1+json",`), nil, 1)
+
+	manifest, _, err := ParseManifest(bytes.NewReader(raw))
+	require.NoError(err)
+	refs, err := GetManifestReferences(manifest)
+	require.NoError(err)
+	require.Equal([]core.Digest{config, layer}, refs)
+}
+
+func TestParseManifestUnsupportedMediaType(t *testing.T) {
+	require := require.New(t)
+
+	raw := []byte(`{"schemaVersion": 1, "mediaType": "application/vnd.docker.distribution.manifest.v1+json"}`)
+
+	_, _, err := ParseManifest(bytes.NewReader(raw))
+	require.Error(err)
+}
+
+func TestGetPlatformManifest(t *testing.T) {
+	amd64 := core.DigestFixture()
+	arm64 := core.DigestFixture()
+	_, raw := OCIIndexFixture(amd64, arm64)
+
+	tests := []struct {
+		platform string
+		expected core.Digest
+		err      error
+	}{
+		{"linux/amd64", amd64, nil},
+		{"linux/arm64", arm64, nil},
+		{"linux/arm64/v8", core.Digest{}, ErrPlatformNotFound},
+		{"windows/amd64", core.Digest{}, ErrPlatformNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.platform, func(t *testing.T) {
+			require := require.New(t)
+
+			manifest, _, err := ParseManifest(bytes.NewReader(raw))
+			require.NoError(err)
+
+			d, err := GetPlatformManifest(manifest, test.platform)
+			require.Equal(test.err, err)
+			require.Equal(test.expected, d)
+		})
+	}
+}
+
+func TestGetPlatformManifestInvalidPlatform(t *testing.T) {
+	require := require.New(t)
+
+	_, raw := OCIIndexFixture(core.DigestFixture(), core.DigestFixture())
+	manifest, _, err := ParseManifest(bytes.NewReader(raw))
+	require.NoError(err)
+
+	_, err = GetPlatformManifest(manifest, "linux")
+	require.Error(err)
+}
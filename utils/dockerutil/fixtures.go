@@ -50,3 +50,60 @@ func ManifestFixture(config core.Digest, layer1 core.Digest, layer2 core.Digest)
 
 	return d, raw
 }
+
+// OCIManifestFixture creates an OCI image manifest blob for testing purposes.
+func OCIManifestFixture(config core.Digest, layer core.Digest) (core.Digest, []byte) {
+	raw := []byte(fmt.Sprintf(`{
+	   "schemaVersion": 2,
+	   "mediaType": "application/vnd.oci.image.manifest.v1+json",
+	   "config": {
+		  "mediaType": "application/vnd.oci.image.config.v1+json",
+		  "size": 2940,
+		  "digest": "%s"
+	   },
+	   "layers": [
+		  {
+			 "mediaType": "application/vnd.oci.image.layer.v1.tar+gzip",
+			 "size": 1902063,
+			 "digest": "%s"
+		  }
+	   ]
+	}`, config, layer))
+
+	d, err := core.NewDigester().FromBytes(raw)
+	if err != nil {
+		panic(err)
+	}
+
+	return d, raw
+}
+
+// OCIIndexFixture creates an OCI image index blob referencing per-platform
+// manifests for testing purposes.
+func OCIIndexFixture(amd64 core.Digest, arm64 core.Digest) (core.Digest, []byte) {
+	raw := []byte(fmt.Sprintf(`{
+	   "schemaVersion": 2,
+	   "mediaType": "application/vnd.oci.image.index.v1+json",
+	   "manifests": [
+		  {
+			 "mediaType": "application/vnd.oci.image.manifest.v1+json",
+			 "size": 7143,
+			 "digest": "%s",
+			 "platform": {"architecture": "amd64", "os": "linux"}
+		  },
+		  {
+			 "mediaType": "application/vnd.oci.image.manifest.v1+json",
+			 "size": 7682,
+			 "digest": "%s",
+			 "platform": {"architecture": "arm64", "os": "linux"}
+		  }
+	   ]
+	}`, amd64, arm64))
+
+	d, err := core.NewDigester().FromBytes(raw)
+	if err != nil {
+		panic(err)
+	}
+
+	return d, raw
+}
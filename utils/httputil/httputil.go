@@ -26,6 +26,8 @@ import (
 
 	"github.com/cenkalti/backoff"
 	"github.com/pressly/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/utils/handler"
@@ -230,7 +232,7 @@ func SendTLS(config *tls.Config) SendOption {
 		if config == nil {
 			return
 		}
-		o.transport = &http.Transport{TLSClientConfig: config}
+		o.transport = &http.Transport{TLSClientConfig: config, Proxy: Proxy}
 		o.url.Scheme = "https"
 	}
 }
@@ -265,7 +267,7 @@ func Send(method, rawurl string, options ...SendOption) (*http.Response, error)
 		acceptedCodes:        map[int]bool{http.StatusOK: true},
 		headers:              map[string]string{},
 		retry:                retryOptions{backoff: &backoff.StopBackOff{}},
-		transport:            nil, // Use HTTP default.
+		transport:            defaultTransport(), // HTTP default, unless a proxy is set.
 		ctx:                  context.Background(),
 		url:                  u,
 		httpFallbackDisabled: false,
@@ -408,7 +410,7 @@ func ParseDigest(r *http.Request, name string) (core.Digest, error) {
 		return core.Digest{}, err
 	}
 
-	d, err := core.ParseSHA256Digest(raw)
+	d, err := core.ParseDigest(raw)
 	if err != nil {
 		return core.Digest{}, handler.Errorf("parse digest: %s", err).Status(http.StatusBadRequest)
 	}
@@ -427,6 +429,9 @@ func newRequest(method string, opts *sendOptions) (*http.Request, error) {
 	for key, val := range opts.headers {
 		req.Header.Set(key, val)
 	}
+	// Propagates the trace of ctx to the recipient. A no-op unless tracing is
+	// enabled.
+	otel.GetTextMapPropagator().Inject(opts.ctx, propagation.HeaderCarrier(req.Header))
 	return req, nil
 }
 
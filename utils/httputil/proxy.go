@@ -0,0 +1,122 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package httputil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyConfig defines an HTTP or SOCKS5 proxy which outgoing requests are sent
+// through, e.g. an egress proxy.
+type ProxyConfig struct {
+	// URL is the address of the proxy, e.g. http://proxy:3128 or
+	// socks5://proxy:1080. If empty, the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables are honored instead.
+	URL string `yaml:"url"`
+
+	// Username and Password authenticate with the proxy, if set. Override any
+	// credentials in URL.
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	// NoProxy lists hosts, domains, IPs and CIDRs which are dialed directly,
+	// in NO_PROXY format. Requests to localhost are never proxied.
+	NoProxy []string `yaml:"no_proxy"`
+}
+
+var (
+	_proxyMu sync.RWMutex
+
+	// Proxy returned by Proxy. If nil, requests are not proxied.
+	_proxy func(*http.Request) (*url.URL, error)
+
+	// Transport for requests sent without an explicit transport once a proxy
+	// is configured. If nil, the HTTP default transport is used.
+	_defaultTransport http.RoundTripper
+)
+
+// SetProxy configures the proxy of all requests sent via Send, and of
+// transports which use Proxy. Requests are not proxied unless SetProxy is
+// called. Should be called on startup, before any requests are sent.
+func SetProxy(config ProxyConfig) error {
+	if config.URL == "" {
+		_proxyMu.Lock()
+		defer _proxyMu.Unlock()
+
+		_proxy = http.ProxyFromEnvironment
+		return nil
+	}
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return fmt.Errorf("parse proxy url: %s", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported proxy scheme: %q", u.Scheme)
+	}
+	if config.Username != "" {
+		u.User = url.UserPassword(config.Username, config.Password)
+	}
+	pc := &httpproxy.Config{
+		HTTPProxy:  u.String(),
+		HTTPSProxy: u.String(),
+		NoProxy:    strings.Join(config.NoProxy, ","),
+	}
+	proxyFunc := pc.ProxyFunc()
+
+	_proxyMu.Lock()
+	defer _proxyMu.Unlock()
+
+	_proxy = func(req *http.Request) (*url.URL, error) { return proxyFunc(req.URL) }
+	_defaultTransport = &http.Transport{
+		Proxy: Proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return nil
+}
+
+// Proxy returns the URL of the proxy which req should be sent through, or nil
+// if req should not be proxied. Suitable for http.Transport.Proxy.
+func Proxy(req *http.Request) (*url.URL, error) {
+	_proxyMu.RLock()
+	defer _proxyMu.RUnlock()
+
+	if _proxy == nil {
+		return nil, nil
+	}
+	return _proxy(req)
+}
+
+func defaultTransport() http.RoundTripper {
+	_proxyMu.RLock()
+	defer _proxyMu.RUnlock()
+
+	return _defaultTransport
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package httputil
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func resetProxy() {
+	_proxyMu.Lock()
+	defer _proxyMu.Unlock()
+
+	_proxy = nil
+	_defaultTransport = nil
+}
+
+func TestProxyDisabledByDefault(t *testing.T) {
+	require := require.New(t)
+
+	resetProxy()
+	t.Setenv("HTTPS_PROXY", "http://proxy:3128")
+
+	req, err := http.NewRequest("GET", "https://origin:9003/health", nil)
+	require.NoError(err)
+
+	u, err := Proxy(req)
+	require.NoError(err)
+	require.Nil(u)
+}
+
+func TestSendThroughProxy(t *testing.T) {
+	require := require.New(t)
+
+	defer resetProxy()
+
+	var host, auth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.URL.Host
+		auth = r.Header.Get("Proxy-Authorization")
+	}))
+	defer proxy.Close()
+
+	require.NoError(SetProxy(ProxyConfig{
+		URL:      proxy.URL,
+		Username: "user",
+		Password: "pass",
+	}))
+
+	_, err := Get("http://origin.example:8080/health")
+	require.NoError(err)
+	require.Equal("origin.example:8080", host)
+	require.Equal(
+		"Basic "+base64.StdEncoding.EncodeToString([]byte("user:pass")), auth)
+}
+
+func TestProxyNoProxyHosts(t *testing.T) {
+	require := require.New(t)
+
+	defer resetProxy()
+
+	require.NoError(SetProxy(ProxyConfig{
+		URL:     "socks5://proxy:1080",
+		NoProxy: []string{".internal", "10.0.0.0/8"},
+	}))
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"http://origin.example/blobs", "socks5://proxy:1080"},
+		{"https://origin.example/blobs", "socks5://proxy:1080"},
+		{"http://origin.internal/blobs", ""},
+		{"http://10.1.2.3/blobs", ""},
+		{"http://localhost/blobs", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			req, err := http.NewRequest("GET", test.url, nil)
+			require.NoError(err)
+			u, err := Proxy(req)
+			require.NoError(err)
+			if test.expected == "" {
+				require.Nil(u)
+			} else {
+				require.Equal(test.expected, u.String())
+			}
+		})
+	}
+}
+
+func TestSetProxyInvalidConfig(t *testing.T) {
+	defer resetProxy()
+
+	require.Error(t, SetProxy(ProxyConfig{URL: "ftp://proxy:21"}))
+	require.Error(t, SetProxy(ProxyConfig{URL: "http://%zz"}))
+}
+
+func TestSetProxyEmptyURLUsesEnvironment(t *testing.T) {
+	defer resetProxy()
+
+	require.NoError(t, SetProxy(ProxyConfig{}))
+	require.Nil(t, defaultTransport())
+}
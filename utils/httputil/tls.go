@@ -29,6 +29,9 @@ import (
 // ErrEmptyCommonName is returned when common name is not provided for key generation.
 var ErrEmptyCommonName = errors.New("empty common name")
 
+// ErrIncompleteKeyPair is returned when only one of a cert and key is configured.
+var ErrIncompleteKeyPair = errors.New("cert and key must be configured together")
+
 // TLSConfig defines TLS configuration.
 type TLSConfig struct {
 	Name   string   `yaml:"name"`
@@ -54,7 +57,8 @@ type Secret struct {
 	Path string `yaml:"path"`
 }
 
-// BuildClient builts tls.Config for http client.
+// BuildClient builts tls.Config for http client. If a client cert and key are
+// configured, the client presents them to servers which require mutual TLS.
 func (c *TLSConfig) BuildClient() (*tls.Config, error) {
 	if c.Client.Disabled {
 		log.Infof("Client TLS is disabled")
@@ -63,6 +67,10 @@ func (c *TLSConfig) BuildClient() (*tls.Config, error) {
 	if c.tls != nil {
 		return c.tls, nil
 	}
+	if (c.Client.Cert.Path == "") != (c.Client.Key.Path == "") {
+		// Silently dropping the cert would fail mutual TLS at request time.
+		return nil, fmt.Errorf("client: %s", ErrIncompleteKeyPair)
+	}
 
 	var caPool *x509.CertPool
 	var certs []tls.Certificate
@@ -98,6 +106,43 @@ func (c *TLSConfig) BuildClient() (*tls.Config, error) {
 	return c.tls, nil
 }
 
+// BuildServer builds tls.Config for http servers from the server cert and
+// key. Client certs are optional, but when presented must be signed by one of
+// the configured CAs, such that handlers may trust the verified chains of a
+// request. Unlike BuildClient, system CAs are not trusted for client certs.
+func (c *TLSConfig) BuildServer() (*tls.Config, error) {
+	if c.Server.Cert.Path == "" || c.Server.Key.Path == "" {
+		return nil, fmt.Errorf("server: %s", ErrIncompleteKeyPair)
+	}
+	certPEM, err := parseCert(c.Server.Cert.Path)
+	if err != nil {
+		return nil, fmt.Errorf("parse server cert: %s", err)
+	}
+	keyPEM, err := parseKey(c.Server.Key.Path, c.Server.Passphrase.Path)
+	if err != nil {
+		return nil, fmt.Errorf("parse server key: %s", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("load server x509 key pair: %s", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if len(c.CAs) > 0 {
+		pems, err := concatSecrets(c.CAs)
+		if err != nil {
+			return nil, fmt.Errorf("concat secrets: %s", err)
+		}
+		if ok := clientCAs.AppendCertsFromPEM(pems); !ok {
+			return nil, fmt.Errorf("cannot append cert")
+		}
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	}, nil
+}
+
 // WriteCABundle writes a list of CA to a writer.
 func (c *TLSConfig) WriteCABundle(w io.Writer) error {
 	pems, err := concatSecrets(c.CAs)
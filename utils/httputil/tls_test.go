@@ -22,6 +22,7 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"net/http"
 	"testing"
@@ -215,6 +216,28 @@ func TestTLSClientBadAuth(t *testing.T) {
 	require.True(IsNetworkError(err))
 }
 
+func TestTLSClientIncompleteKeyPair(t *testing.T) {
+	c, cleanup := genCerts(t)
+	defer cleanup()
+
+	tests := []struct {
+		desc string
+		pair X509Pair
+	}{
+		{"cert without key", X509Pair{Cert: c.Client.Cert}},
+		{"key without cert", X509Pair{Key: c.Client.Key}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			config := &TLSConfig{Name: c.Name, CAs: c.CAs, Client: test.pair}
+			_, err := config.BuildClient()
+			require.Error(err)
+		})
+	}
+}
+
 func TestTLSClientFallback(t *testing.T) {
 	require := require.New(t)
 	c := &TLSConfig{}
@@ -244,3 +267,73 @@ func TestTLSClientFallbackError(t *testing.T) {
 	_, err = Get("https://some-non-existent-addr/", SendTLS(tls))
 	require.Error(err)
 }
+
+func TestTLSServer(t *testing.T) {
+	require := require.New(t)
+
+	var cleanup testutil.Cleanup
+	defer cleanup.Run()
+
+	tempFile := func(b []byte) string {
+		path, c := testutil.TempFile(b)
+		cleanup.Add(c)
+		return path
+	}
+
+	// Server cert, which is also the root CA for client certs.
+	sCertPEM, sKeyPEM, sSecretBytes := genKeyPair(t, nil, nil, nil)
+	sCA := Secret{tempFile(sCertPEM)}
+
+	config := &TLSConfig{CAs: []Secret{sCA}}
+	config.Server.Cert = sCA
+	config.Server.Key.Path = tempFile(sKeyPEM)
+	config.Server.Passphrase.Path = tempFile(sSecretBytes)
+
+	serverTLS, err := config.BuildServer()
+	require.NoError(err)
+
+	l, err := tls.Listen("tcp", "localhost:0", serverTLS)
+	require.NoError(err)
+	cleanup.Add(func() { l.Close() })
+	r := chi.NewRouter()
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, len(r.TLS.VerifiedChains) > 0)
+	})
+	go http.Serve(l, r)
+	url := "https://" + l.Addr().String() + "/"
+
+	cCertPEM, cKeyPEM, cSecretBytes := genKeyPair(t, sCertPEM, sKeyPEM, sSecretBytes)
+	client := &TLSConfig{Name: "kraken", CAs: []Secret{sCA}}
+	client.Client.Cert.Path = tempFile(cCertPEM)
+	client.Client.Key.Path = tempFile(cKeyPEM)
+	client.Client.Passphrase.Path = tempFile(cSecretBytes)
+	clientTLS, err := client.BuildClient()
+	require.NoError(err)
+
+	resp, err := Get(url, SendTLS(clientTLS), DisableHTTPFallback())
+	require.NoError(err)
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(err)
+	require.Equal("true", string(b))
+
+	// Client certs are optional.
+	anon := &TLSConfig{Name: "kraken", CAs: []Secret{sCA}}
+	anonTLS, err := anon.BuildClient()
+	require.NoError(err)
+
+	resp, err = Get(url, SendTLS(anonTLS), DisableHTTPFallback())
+	require.NoError(err)
+	b, err = ioutil.ReadAll(resp.Body)
+	require.NoError(err)
+	require.Equal("false", string(b))
+}
+
+func TestTLSServerIncompleteKeyPair(t *testing.T) {
+	c, cleanup := genCerts(t)
+	defer cleanup()
+
+	config := &TLSConfig{CAs: c.CAs}
+	config.Server.Cert = c.Client.Cert
+	_, err := config.BuildServer()
+	require.Error(t, err)
+}
@@ -64,7 +64,9 @@ func GetIP(host string) (net.IP, error) {
 	return nil, errors.New("no ips found")
 }
 
-// GetLocalIP returns the ip address of the local machine.
+// GetLocalIP returns the ip address of the local machine. IPv4 addresses are
+// preferred, falling back to global unicast IPv6 addresses on IPv6-only
+// interfaces.
 func GetLocalIP() (string, error) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
@@ -76,24 +78,24 @@ func GetLocalIP() (string, error) {
 		if err != nil {
 			return "", fmt.Errorf("addrs: %s", err)
 		}
+		var ips6 []net.IP
 		for _, addr := range addrs {
-			var ip net.IP
-			switch v := addr.(type) {
-			case *net.IPNet:
-				ip = v.IP
-			case *net.IPAddr:
-				ip = v.IP
-			}
+			ip := addrIP(addr)
 			if ip == nil || ip.IsLoopback() {
 				continue
 			}
-			ip = ip.To4()
-			if ip == nil {
+			if ip.To4() == nil {
+				if ip.IsGlobalUnicast() {
+					ips6 = append(ips6, ip)
+				}
 				continue
 			}
-			ips[i.Name] = ip.String()
+			ips[i.Name] = ip.To4().String()
 			break
 		}
+		if _, ok := ips[i.Name]; !ok && len(ips6) > 0 {
+			ips[i.Name] = ips6[0].String()
+		}
 	}
 	for _, i := range _supportedInterfaces {
 		if ip, ok := ips[i]; ok {
@@ -102,3 +104,13 @@ func GetLocalIP() (string, error) {
 	}
 	return "", errors.New("no ip found")
 }
+
+func addrIP(addr net.Addr) net.IP {
+	switch v := addr.(type) {
+	case *net.IPNet:
+		return v.IP
+	case *net.IPAddr:
+		return v.IP
+	}
+	return nil
+}
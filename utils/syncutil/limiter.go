@@ -0,0 +1,123 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package syncutil
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// Limiter errors.
+var (
+	ErrQueueFull    = errors.New("limiter queue is full")
+	ErrQueueTimeout = errors.New("timed out waiting in limiter queue")
+)
+
+// LimiterConfig defines Limiter configuration.
+type LimiterConfig struct {
+	// MaxConcurrent is the number of operations which may run concurrently.
+	// Zero disables the limit.
+	MaxConcurrent int `yaml:"max_concurrent"`
+
+	// MaxQueued is the number of operations which may wait for others to
+	// finish once MaxConcurrent operations are running. Operations beyond the
+	// queue are rejected immediately.
+	MaxQueued int `yaml:"max_queued"`
+
+	// QueueTimeout bounds how long a queued operation waits before it is
+	// rejected.
+	QueueTimeout time.Duration `yaml:"queue_timeout"`
+}
+
+func (c LimiterConfig) applyDefaults() LimiterConfig {
+	if c.QueueTimeout == 0 {
+		c.QueueTimeout = 30 * time.Second
+	}
+	return c
+}
+
+// Limiter limits the number of concurrently running operations, queueing a
+// bounded number of excess operations.
+type Limiter struct {
+	config   LimiterConfig
+	slots    chan struct{}
+	queued   *atomic.Int64
+	inFlight *atomic.Int64
+}
+
+// NewLimiter creates a new Limiter.
+func NewLimiter(config LimiterConfig) *Limiter {
+	config = config.applyDefaults()
+	l := &Limiter{
+		config:   config,
+		queued:   atomic.NewInt64(0),
+		inFlight: atomic.NewInt64(0),
+	}
+	if config.MaxConcurrent > 0 {
+		l.slots = make(chan struct{}, config.MaxConcurrent)
+	}
+	return l
+}
+
+// Acquire blocks until an operation may run. Returns ErrQueueFull if the queue
+// is full, or ErrQueueTimeout if the operation waited in the queue for too
+// long. Release must be called once the operation finishes if Acquire
+// succeeds.
+func (l *Limiter) Acquire() error {
+	if l.slots == nil {
+		l.inFlight.Inc()
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		l.inFlight.Inc()
+		return nil
+	default:
+	}
+	if l.queued.Inc() > int64(l.config.MaxQueued) {
+		l.queued.Dec()
+		return ErrQueueFull
+	}
+	defer l.queued.Dec()
+
+	timer := time.NewTimer(l.config.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		l.inFlight.Inc()
+		return nil
+	case <-timer.C:
+		return ErrQueueTimeout
+	}
+}
+
+// Release marks an operation started by Acquire as finished.
+func (l *Limiter) Release() {
+	l.inFlight.Dec()
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// InFlight returns the number of running operations.
+func (l *Limiter) InFlight() int {
+	return int(l.inFlight.Load())
+}
+
+// Queued returns the number of operations waiting to run.
+func (l *Limiter) Queued() int {
+	return int(l.queued.Load())
+}
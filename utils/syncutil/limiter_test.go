@@ -0,0 +1,86 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package syncutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterUnlimited(t *testing.T) {
+	require := require.New(t)
+
+	l := NewLimiter(LimiterConfig{})
+	for i := 0; i < 100; i++ {
+		require.NoError(l.Acquire())
+	}
+	require.Equal(100, l.InFlight())
+
+	l.Release()
+	require.Equal(99, l.InFlight())
+}
+
+func TestLimiterRejectsWhenQueueFull(t *testing.T) {
+	require := require.New(t)
+
+	l := NewLimiter(LimiterConfig{MaxConcurrent: 2})
+	require.NoError(l.Acquire())
+	require.NoError(l.Acquire())
+	require.Equal(ErrQueueFull, l.Acquire())
+	require.Equal(2, l.InFlight())
+
+	l.Release()
+	require.NoError(l.Acquire())
+}
+
+func TestLimiterQueueTimeout(t *testing.T) {
+	require := require.New(t)
+
+	l := NewLimiter(LimiterConfig{
+		MaxConcurrent: 1,
+		MaxQueued:     1,
+		QueueTimeout:  50 * time.Millisecond,
+	})
+	require.NoError(l.Acquire())
+	require.Equal(ErrQueueTimeout, l.Acquire())
+	require.Equal(0, l.Queued())
+}
+
+func TestLimiterQueuedAcquireSucceedsOnRelease(t *testing.T) {
+	require := require.New(t)
+
+	l := NewLimiter(LimiterConfig{
+		MaxConcurrent: 1,
+		MaxQueued:     1,
+		QueueTimeout:  5 * time.Second,
+	})
+	require.NoError(l.Acquire())
+
+	errc := make(chan error)
+	go func() { errc <- l.Acquire() }()
+
+	require.NoError(testutil.PollUntilTrue(time.Second, func() bool { return l.Queued() == 1 }))
+
+	// The queue is full, so further operations are rejected.
+	require.Equal(ErrQueueFull, l.Acquire())
+
+	l.Release()
+	require.NoError(<-errc)
+	require.Equal(1, l.InFlight())
+	require.Equal(0, l.Queued())
+}